@@ -15,14 +15,18 @@
 package main
 
 import (
+	"database/sql"
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
 
 	"github.com/lf-edge/ekuiper/extensions/sqldatabase"
 	"github.com/lf-edge/ekuiper/extensions/sqldatabase/driver"
+	"github.com/lf-edge/ekuiper/extensions/sqldatabase/sqlgen"
 	"github.com/lf-edge/ekuiper/extensions/util"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
 	"github.com/lf-edge/ekuiper/internal/topo/transform"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/ast"
@@ -39,60 +43,143 @@ type sqlConfig struct {
 	DataField      string   `json:"dataField"`
 	RowkindField   string   `json:"rowkindField"`
 	KeyField       string   `json:"keyField"`
+	Upsert         bool     `json:"upsert"`
+	CreateTable    bool     `json:"createTable"`
 }
 
-func (t *sqlConfig) buildInsertSql(ctx api.StreamContext, mapData map[string]interface{}) ([]string, string, error) {
-	keys, vals, err := t.getKeyValues(ctx, mapData)
-	if err != nil {
-		return keys, "", err
-	}
+func (t *sqlConfig) quote(key string) string {
 	if strings.HasPrefix(strings.ToLower(t.Url), "dm://") {
-		for i, key := range keys {
-			keys[i] = fmt.Sprintf(`"%v"`, key)
-		}
+		return fmt.Sprintf(`"%v"`, key)
 	}
-	sqlStr := "(" + strings.Join(vals, ",") + ")"
-	return keys, sqlStr, nil
+	return key
 }
 
-func (t *sqlConfig) getKeyValues(ctx api.StreamContext, mapData map[string]interface{}) ([]string, []string, error) {
-	if 0 == len(mapData) {
-		return nil, nil, fmt.Errorf("data is empty.")
+func (t *sqlConfig) quoteKeys(keys []string) []string {
+	result := make([]string, len(keys))
+	for i, key := range keys {
+		result[i] = t.quote(key)
 	}
-	logger := ctx.GetLogger()
-	var keys, vals []string
+	return result
+}
 
+// getKeys returns the columns to write. If fields are not specified, all the keys except the rowkind field are written
+// in sorted order so that the rows of a batch share the same columns.
+func (t *sqlConfig) getKeys(mapData map[string]interface{}) []string {
 	if len(t.Fields) != 0 {
-		for _, k := range t.Fields {
-			keys = append(keys, k)
-			if v, ok := mapData[k]; ok && v != nil {
-				if reflect.String == reflect.TypeOf(v).Kind() {
-					vals = append(vals, fmt.Sprintf("'%v'", v))
-				} else {
-					vals = append(vals, fmt.Sprintf(`%v`, v))
-				}
-			} else {
-				logger.Warnln("not found field:", k)
-				vals = append(vals, fmt.Sprintf(`NULL`))
-			}
+		return t.Fields
+	}
+	keys := make([]string, 0, len(mapData))
+	for k := range mapData {
+		if t.RowkindField != "" && k == t.RowkindField {
+			continue
 		}
-	} else {
-		for k, v := range mapData {
-			keys = append(keys, k)
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// getGroupKeys returns the union of the columns of the rows to be written in one statement
+func (t *sqlConfig) getGroupKeys(rows []map[string]interface{}) []string {
+	if len(t.Fields) != 0 || len(rows) == 1 {
+		return t.getKeys(rows[0])
+	}
+	all := make(map[string]interface{})
+	for _, data := range rows {
+		for k, v := range data {
+			all[k] = v
+		}
+	}
+	return t.getKeys(all)
+}
+
+type keyGroup struct {
+	keys []string
+	rows []map[string]interface{}
+}
+
+// groupByKeys groups the rows by their columns in the order of the first row of each group
+func (t *sqlConfig) groupByKeys(rows []map[string]interface{}) []*keyGroup {
+	if len(t.Fields) != 0 || len(rows) == 1 {
+		return []*keyGroup{{keys: t.getKeys(rows[0]), rows: rows}}
+	}
+	var groups []*keyGroup
+	index := make(map[string]*keyGroup)
+	for _, data := range rows {
+		keys := t.getKeys(data)
+		// the keys are sorted, so the same set of columns has the same signature
+		sig := strings.Join(keys, "\x00")
+		g, ok := index[sig]
+		if !ok {
+			g = &keyGroup{keys: keys}
+			index[sig] = g
+			groups = append(groups, g)
+		}
+		g.rows = append(g.rows, data)
+	}
+	return groups
+}
+
+func (t *sqlConfig) getValues(ctx api.StreamContext, keys []string, mapData map[string]interface{}) ([]string, error) {
+	if 0 == len(mapData) {
+		return nil, fmt.Errorf("data is empty.")
+	}
+	vals := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if v, ok := mapData[k]; ok && v != nil {
 			if reflect.String == reflect.TypeOf(v).Kind() {
 				vals = append(vals, fmt.Sprintf("'%v'", v))
 			} else {
 				vals = append(vals, fmt.Sprintf(`%v`, v))
 			}
+		} else {
+			if !ok && len(t.Fields) != 0 {
+				ctx.GetLogger().Warnln("not found field:", k)
+			}
+			vals = append(vals, `NULL`)
 		}
 	}
+	return vals, nil
+}
+
+func (t *sqlConfig) getKeyValues(ctx api.StreamContext, mapData map[string]interface{}) ([]string, []string, error) {
+	keys := t.getKeys(mapData)
+	vals, err := t.getValues(ctx, keys, mapData)
+	if err != nil {
+		return nil, nil, err
+	}
 	return keys, vals, nil
 }
 
+func (t *sqlConfig) getRowkind(data map[string]interface{}) (string, error) {
+	rowkind := ast.RowkindInsert
+	if t.RowkindField != "" {
+		// the selected fields fill the missing rowkind field with nil
+		c, ok := data[t.RowkindField]
+		if ok && c != nil {
+			rowkind, ok = c.(string)
+			if !ok {
+				return "", fmt.Errorf("rowkind field %s is not a string in data %v", t.RowkindField, data)
+			}
+			if rowkind != ast.RowkindInsert && rowkind != ast.RowkindUpdate && rowkind != ast.RowkindDelete && rowkind != ast.RowkindUpsert {
+				return "", fmt.Errorf("invalid rowkind %s", rowkind)
+			}
+		}
+	}
+	if rowkind == ast.RowkindInsert && t.Upsert {
+		rowkind = ast.RowkindUpsert
+	}
+	return rowkind, nil
+}
+
 type sqlSink struct {
 	conf *sqlConfig
 	// The db connection instance
 	db sqldatabase.DB
+	// The dialect specific statement generator for upsert and create table. It is nil if the driver is not supported.
+	writer sqlgen.WriteGenerator
+	// The tables which are created or checked to exist, only used when createTable is set
+	tables map[string]struct{}
 }
 
 func (m *sqlSink) Configure(props map[string]interface{}) error {
@@ -113,7 +200,22 @@ func (m *sqlSink) Configure(props map[string]interface{}) error {
 	if cfg.RowkindField != "" && cfg.KeyField == "" {
 		return fmt.Errorf("keyField is required when rowkindField is set")
 	}
+	if cfg.Upsert && cfg.KeyField == "" {
+		return fmt.Errorf("keyField is required when upsert is set")
+	}
+	if cfg.Upsert || cfg.CreateTable || cfg.RowkindField != "" {
+		driver, err := util.ParseDriver(cfg.Url)
+		if err != nil {
+			return err
+		}
+		m.writer, err = sqlgen.GetWriteGenerator(driver)
+		// rowkind upsert is reported when writing
+		if err != nil && (cfg.Upsert || cfg.CreateTable) {
+			return err
+		}
+	}
 	m.conf = cfg
+	m.tables = make(map[string]struct{})
 	return nil
 }
 
@@ -143,6 +245,45 @@ func (m *sqlSink) writeToDB(ctx api.StreamContext, sqlStr *string) error {
 	return nil
 }
 
+type txBeginner interface {
+	Begin() (*sql.Tx, error)
+}
+
+// writeInTx writes all statements of a batch in one transaction so that a batch is either fully written or not at all
+func (m *sqlSink) writeInTx(ctx api.StreamContext, sqls []string) error {
+	switch len(sqls) {
+	case 0:
+		return nil
+	case 1:
+		return m.writeToDB(ctx, &sqls[0])
+	}
+	db, ok := m.db.(txBeginner)
+	if !ok {
+		for i := range sqls {
+			if err := m.writeToDB(ctx, &sqls[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+	}
+	for _, sqlStr := range sqls {
+		ctx.GetLogger().Debugf(sqlStr)
+		if _, err := tx.Exec(sqlStr); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+	}
+	ctx.GetLogger().Debugf("Committed %d statements", len(sqls))
+	return nil
+}
+
 func (m *sqlSink) Collect(ctx api.StreamContext, item interface{}) error {
 	ctx.GetLogger().Debugf("sql sink receive %s", item)
 	if m.conf.DataTemplate != "" {
@@ -157,162 +298,239 @@ func (m *sqlSink) Collect(ctx api.StreamContext, item interface{}) error {
 		}
 		item = tm
 	} else {
-		tm, _, err := transform.TransItem(item, m.conf.DataField, m.conf.Fields)
+		fields := m.conf.Fields
+		// keep the rowkind field to build the statements, it is not written because the columns are the fields
+		if m.conf.RowkindField != "" && len(fields) > 0 {
+			fields = append(fields[:len(fields):len(fields)], m.conf.RowkindField)
+		}
+		tm, _, err := transform.TransItem(item, m.conf.DataField, fields)
 		if err != nil {
 			return fmt.Errorf("fail to 1 transform data %v for error %v!!!!!", item, err)
 		}
 		item = tm
 	}
 
-	var (
-		table string
-		err   error
-	)
+	var rows []map[string]interface{}
 	switch v := item.(type) {
 	case map[string]interface{}:
-		table, err = ctx.ParseTemplate(m.conf.Table, v)
-		if err != nil {
-			ctx.GetLogger().Errorf("parse template for table %s error: %v", m.conf.Table, err)
-			return err
-		}
+		rows = []map[string]interface{}{v}
 	case []map[string]interface{}:
-		if len(v) == 0 {
-			ctx.GetLogger().Warnf("empty data array")
-			return nil
+		rows = v
+	case []interface{}:
+		rows = make([]map[string]interface{}, 0, len(v))
+		for _, data := range v {
+			mapData, ok := data.(map[string]interface{})
+			if !ok {
+				ctx.GetLogger().Errorf("unsupported type: %T", data)
+				return fmt.Errorf("unsupported type: %T", data)
+			}
+			rows = append(rows, mapData)
 		}
-		table, err = ctx.ParseTemplate(m.conf.Table, v[0])
-		if err != nil {
-			ctx.GetLogger().Errorf("parse template for table %s error: %v", m.conf.Table, err)
+	default: // never happen
+		return fmt.Errorf("unsupported type: %T", item)
+	}
+	if len(rows) == 0 {
+		ctx.GetLogger().Warnf("empty data array")
+		return nil
+	}
+	table, err := ctx.ParseTemplate(m.conf.Table, rows[0])
+	if err != nil {
+		ctx.GetLogger().Errorf("parse template for table %s error: %v", m.conf.Table, err)
+		return err
+	}
+	if m.conf.CreateTable {
+		if err := m.createTable(ctx, table, rows[0]); err != nil {
 			return err
 		}
 	}
+	sqls, err := m.buildSqls(ctx, table, rows)
+	if err != nil {
+		return err
+	}
+	return m.writeInTx(ctx, sqls)
+}
 
-	var keys []string = nil
-	var values []string = nil
-	var vars string
+// createTable creates the table if not exists with the columns of the first result. The column types are taken
+// from the rule output schema if known, otherwise they are inferred from the values of the first result.
+func (m *sqlSink) createTable(ctx api.StreamContext, table string, data map[string]interface{}) error {
+	if _, ok := m.tables[table]; ok {
+		return nil
+	}
+	var schema map[string]ast.DataType
+	// the output schema does not apply to the data transformed by the template or taken from a field
+	if m.conf.DataTemplate == "" && m.conf.DataField == "" {
+		schema = context.GetOutputSchema(ctx)
+	}
+	keys := m.conf.getKeys(data)
+	cols := make([]sqlgen.Column, len(keys))
+	for i, k := range keys {
+		ct := sqlgen.InferColumnType(data[k])
+		if t, ok := schema[k]; ok {
+			ct = sqlgen.SchemaColumnType(t)
+		}
+		cols[i] = sqlgen.Column{Name: m.conf.quote(k), Type: ct}
+	}
+	key := ""
+	if m.conf.KeyField != "" {
+		key = m.conf.quote(m.conf.KeyField)
+	}
+	sqlStr := m.writer.CreateTableStatement(table, key, cols)
+	if err := m.writeToDB(ctx, &sqlStr); err != nil {
+		return err
+	}
+	m.tables[table] = struct{}{}
+	return nil
+}
 
-	if m.conf.RowkindField == "" {
-		switch v := item.(type) {
-		case []map[string]interface{}:
-			for _, mapData := range v {
-				keys, vars, err = m.conf.buildInsertSql(ctx, mapData)
-				if err != nil {
-					return err
-				}
-				values = append(values, vars)
-			}
-			if keys != nil {
-				sqlStr := fmt.Sprintf("INSERT INTO %s (%s) values ", table, strings.Join(keys, ",")) + strings.Join(values, ",") + ";"
-				return m.writeToDB(ctx, &sqlStr)
-			}
-			return nil
-		case map[string]interface{}:
-			keys, vars, err = m.conf.buildInsertSql(ctx, v)
-			if err != nil {
-				return err
-			}
-			values = append(values, vars)
-			if keys != nil {
-				sqlStr := fmt.Sprintf("INSERT INTO %s (%s) values ", table, strings.Join(keys, ",")) + strings.Join(values, ",") + ";"
-				return m.writeToDB(ctx, &sqlStr)
-			}
+// buildSqls builds the statements for the rows in order. The consecutive inserts or upserts are merged into one
+// multi-row statement. In rowkind mode, an invalid row in a batch is logged and skipped.
+func (m *sqlSink) buildSqls(ctx api.StreamContext, table string, rows []map[string]interface{}) ([]string, error) {
+	var (
+		sqls      []string
+		group     []map[string]interface{}
+		groupKind string
+	)
+	skip := func(err error) error {
+		if m.conf.RowkindField == "" || len(rows) == 1 {
+			return err
+		}
+		ctx.GetLogger().Error(err)
+		return nil
+	}
+	flush := func() error {
+		if len(group) == 0 {
 			return nil
-		case []interface{}:
-			for _, data := range v {
-				mapData, ok := data.(map[string]interface{})
-				if !ok {
-					ctx.GetLogger().Errorf("unsupported type: %T", data)
-					return fmt.Errorf("unsupported type: %T", data)
-				}
-
-				keys, vars, err = m.conf.buildInsertSql(ctx, mapData)
-				if err != nil {
-					ctx.GetLogger().Errorf("sql sink build sql error %v for data", err, mapData)
-					return err
-				}
-				values = append(values, vars)
-			}
-
-			if keys != nil {
-				sqlStr := fmt.Sprintf("INSERT INTO %s (%s) values ", table, strings.Join(keys, ",")) + strings.Join(values, ",") + ";"
-				return m.writeToDB(ctx, &sqlStr)
+		}
+		writeSqls, err := m.buildWriteSql(ctx, table, groupKind, group)
+		group = nil
+		if err != nil {
+			return skip(err)
+		}
+		sqls = append(sqls, writeSqls...)
+		return nil
+	}
+	for _, data := range rows {
+		rowkind, err := m.conf.getRowkind(data)
+		if err != nil {
+			if err := skip(err); err != nil {
+				return nil, err
 			}
-			return nil
-		default: // never happen
-			return fmt.Errorf("unsupported type: %T", item)
+			continue
 		}
-	} else {
-		switch d := item.(type) {
-		case []map[string]interface{}:
-			for _, el := range d {
-				err := m.save(ctx, table, el)
-				if err != nil {
-					ctx.GetLogger().Error(err)
+		switch rowkind {
+		case ast.RowkindInsert, ast.RowkindUpsert:
+			if rowkind != groupKind {
+				if err := flush(); err != nil {
+					return nil, err
 				}
+				groupKind = rowkind
 			}
-		case map[string]interface{}:
-			err := m.save(ctx, table, d)
-			if err != nil {
-				return err
+			group = append(group, data)
+		default:
+			if err := flush(); err != nil {
+				return nil, err
 			}
-		case []interface{}:
-			for _, vv := range d {
-				el, ok := vv.(map[string]interface{})
-				if !ok {
-					ctx.GetLogger().Errorf("unsupported type: %T", vv)
-					return fmt.Errorf("unsupported type: %T", vv)
-				}
-				err := m.save(ctx, table, el)
-				if err != nil {
-					ctx.GetLogger().Error(err)
+			sqlStr, err := m.buildChangeSql(ctx, table, rowkind, data)
+			if err != nil {
+				if err := skip(err); err != nil {
+					return nil, err
 				}
+				continue
 			}
-		default:
-			return fmt.Errorf("unrecognized format of %s", item)
+			sqls = append(sqls, sqlStr)
 		}
-		return nil
 	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return sqls, nil
 }
 
-func (m *sqlSink) Close(_ api.StreamContext) error {
-	if m.db != nil {
-		return util.ReturnDBFromOneNode(util.GlobalPool, m.conf.Url)
+// buildWriteSql builds the multi-row insert or upsert statements
+func (m *sqlSink) buildWriteSql(ctx api.StreamContext, table string, rowkind string, rows []map[string]interface{}) ([]string, error) {
+	if rowkind != ast.RowkindUpsert {
+		keys := m.conf.getGroupKeys(rows)
+		values, err := m.getRowValues(ctx, keys, rows)
+		if err != nil {
+			return nil, err
+		}
+		tuples := make([]string, len(values))
+		for i, vals := range values {
+			tuples[i] = "(" + strings.Join(vals, ",") + ")"
+		}
+		return []string{fmt.Sprintf("INSERT INTO %s (%s) values ", table, strings.Join(m.conf.quoteKeys(keys), ",")) + strings.Join(tuples, ",") + ";"}, nil
 	}
-	return nil
+	if m.writer == nil {
+		return nil, fmt.Errorf("upsert is not supported for url %s", m.conf.Url)
+	}
+	rows, err := m.dedupByKey(rows)
+	if err != nil {
+		return nil, err
+	}
+	// A missing column would overwrite the existing value with NULL, so the rows are upserted by their own columns
+	var sqls []string
+	for _, g := range m.conf.groupByKeys(rows) {
+		values, err := m.getRowValues(ctx, g.keys, g.rows)
+		if err != nil {
+			return nil, err
+		}
+		sqls = append(sqls, m.writer.UpsertStatement(table, m.conf.quote(m.conf.KeyField), m.conf.quoteKeys(g.keys), values))
+	}
+	return sqls, nil
 }
 
-// save save updatable data only to db
-func (m *sqlSink) save(ctx api.StreamContext, table string, data map[string]interface{}) error {
-	rowkind := ast.RowkindInsert
-	c, ok := data[m.conf.RowkindField]
-	if ok {
-		rowkind, ok = c.(string)
+func (m *sqlSink) getRowValues(ctx api.StreamContext, keys []string, rows []map[string]interface{}) ([][]string, error) {
+	values := make([][]string, 0, len(rows))
+	for _, data := range rows {
+		vals, err := m.conf.getValues(ctx, keys, data)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, vals)
+	}
+	return values, nil
+}
+
+// dedupByKey keeps the last row of each key because a single upsert statement cannot affect the same row twice
+func (m *sqlSink) dedupByKey(rows []map[string]interface{}) ([]map[string]interface{}, error) {
+	if len(rows) == 1 {
+		if _, ok := rows[0][m.conf.KeyField]; !ok {
+			return nil, fmt.Errorf("field %s does not exist in data %v", m.conf.KeyField, rows[0])
+		}
+		return rows, nil
+	}
+	last := make(map[string]int, len(rows))
+	for i, data := range rows {
+		keyval, ok := data[m.conf.KeyField]
 		if !ok {
-			return fmt.Errorf("rowkind field %s is not a string in data %v", m.conf.RowkindField, data)
+			return nil, fmt.Errorf("field %s does not exist in data %v", m.conf.KeyField, data)
 		}
-		if rowkind != ast.RowkindInsert && rowkind != ast.RowkindUpdate && rowkind != ast.RowkindDelete {
-			return fmt.Errorf("invalid rowkind %s", rowkind)
+		last[fmt.Sprintf("%v", keyval)] = i
+	}
+	if len(last) == len(rows) {
+		return rows, nil
+	}
+	result := make([]map[string]interface{}, 0, len(last))
+	for i, data := range rows {
+		if last[fmt.Sprintf("%v", data[m.conf.KeyField])] == i {
+			result = append(result, data)
 		}
 	}
+	return result, nil
+}
+
+// buildChangeSql builds the update or delete statement against the key field
+func (m *sqlSink) buildChangeSql(ctx api.StreamContext, table string, rowkind string, data map[string]interface{}) (string, error) {
 	var sqlStr string
 	switch rowkind {
-	case ast.RowkindInsert:
-		keys, vars, err := m.conf.buildInsertSql(ctx, data)
-		if err != nil {
-			return err
-		}
-		values := []string{vars}
-		if keys != nil {
-			sqlStr = fmt.Sprintf("INSERT INTO %s (%s) values ", table, strings.Join(keys, ",")) + strings.Join(values, ",") + ";"
-		}
 	case ast.RowkindUpdate:
 		keyval, ok := data[m.conf.KeyField]
 		if !ok {
-			return fmt.Errorf("field %s does not exist in data %v", m.conf.KeyField, data)
+			return "", fmt.Errorf("field %s does not exist in data %v", m.conf.KeyField, data)
 		}
 		keys, vals, err := m.conf.getKeyValues(ctx, data)
 		if err != nil {
-			return err
+			return "", err
 		}
 		sqlStr = fmt.Sprintf("UPDATE %s SET ", table)
 		for i, key := range keys {
@@ -329,7 +547,7 @@ func (m *sqlSink) save(ctx api.StreamContext, table string, data map[string]inte
 	case ast.RowkindDelete:
 		keyval, ok := data[m.conf.KeyField]
 		if !ok {
-			return fmt.Errorf("field %s does not exist in data %v", m.conf.KeyField, data)
+			return "", fmt.Errorf("field %s does not exist in data %v", m.conf.KeyField, data)
 		}
 		if _, ok := keyval.(string); ok {
 			sqlStr = fmt.Sprintf("DELETE FROM %s WHERE %s = '%s';", table, m.conf.KeyField, keyval)
@@ -337,9 +555,25 @@ func (m *sqlSink) save(ctx api.StreamContext, table string, data map[string]inte
 			sqlStr = fmt.Sprintf("DELETE FROM %s WHERE %s = %v;", table, m.conf.KeyField, keyval)
 		}
 	default:
-		return fmt.Errorf("invalid rowkind %s", rowkind)
+		return "", fmt.Errorf("invalid rowkind %s", rowkind)
+	}
+	return sqlStr, nil
+}
+
+func (m *sqlSink) Close(_ api.StreamContext) error {
+	if m.db != nil {
+		return util.ReturnDBFromOneNode(util.GlobalPool, m.conf.Url)
+	}
+	return nil
+}
+
+// save save updatable data only to db
+func (m *sqlSink) save(ctx api.StreamContext, table string, data map[string]interface{}) error {
+	sqls, err := m.buildSqls(ctx, table, []map[string]interface{}{data})
+	if err != nil {
+		return err
 	}
-	return m.writeToDB(ctx, &sqlStr)
+	return m.writeInTx(ctx, sqls)
 }
 
 func Sql() api.Sink {
@@ -27,6 +27,7 @@ import (
 	"github.com/lf-edge/ekuiper/extensions/sqldatabase"
 	econf "github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/pkg/ast"
 )
 
 func TestSingle(t *testing.T) {
@@ -298,6 +299,185 @@ func TestSaveSql(t *testing.T) {
 	}
 }
 
+func TestUpsert(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:test.db")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	contextLogger := econf.Log.WithField("rule", "test")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	s := &sqlSink{}
+	defer func() {
+		db.Close()
+		s.Close(ctx)
+		err := os.Remove("test.db")
+		if err != nil {
+			fmt.Println(err)
+		}
+	}()
+	err = s.Configure(map[string]interface{}{
+		"url":         "sqlite://test.db",
+		"table":       "upsertTable",
+		"keyField":    "id",
+		"upsert":      true,
+		"createTable": true,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	err = s.Open(ctx)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// The score column is created by the output schema instead of the integer in the first row
+	ctx = context.WithValue(ctx, context.OutputSchemaKey, map[string]ast.DataType{"score": ast.FLOAT})
+	test := [][]map[string]interface{}{
+		{
+			{"id": 1, "name": "John", "score": 80},
+			{"id": 2, "name": "Susan", "score": 90.0},
+			{"id": 1, "name": "Lizz", "score": 70.5},
+		},
+		{
+			{"id": 2, "score": 60.5},
+			{"id": 3, "name": "Charles", "score": 50.5},
+		},
+	}
+	for _, d := range test {
+		err = s.Collect(ctx, d)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	rows, err := db.Query("SELECT id, name, score FROM upsertTable ORDER BY id")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	act, _ := rowsToMap(rows)
+	exp := []map[string]interface{}{
+		{"id": int64(1), "name": "Lizz", "score": 70.5},
+		{"id": int64(2), "name": "Susan", "score": 60.5},
+		{"id": int64(3), "name": "Charles", "score": 50.5},
+	}
+	if !reflect.DeepEqual(act, exp) {
+		t.Errorf("Expect %v but got %v", exp, act)
+	}
+	var scoreType string
+	err = db.QueryRow("SELECT type FROM pragma_table_info('upsertTable') WHERE name = 'score'").Scan(&scoreType)
+	assert.NoError(t, err)
+	assert.Equal(t, "REAL", scoreType)
+}
+
+func TestBuildSqls(t *testing.T) {
+	contextLogger := econf.Log.WithField("rule", "test")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	s := &sqlSink{}
+	err := s.Configure(map[string]interface{}{
+		"url":          "sqlite://test.db",
+		"table":        "test",
+		"keyField":     "id",
+		"rowkindField": "action",
+	})
+	assert.NoError(t, err)
+	tests := []struct {
+		name string
+		d    []map[string]interface{}
+		s    []string
+		err  string
+	}{
+		{
+			name: "merge inserts",
+			d: []map[string]interface{}{
+				{"id": 1, "name": "John"},
+				{"action": "insert", "id": 2, "name": "Susan", "score": 80},
+				{"action": "update", "id": 2, "name": "Diana"},
+				{"action": "insert", "id": 3},
+				{"action": "delete", "id": 1},
+			},
+			s: []string{
+				"INSERT INTO test (id,name,score) values (1,'John',NULL),(2,'Susan',80);",
+				"UPDATE test SET id=2,name='Diana' WHERE id = 2;",
+				"INSERT INTO test (id) values (3);",
+				"DELETE FROM test WHERE id = 1;",
+			},
+		},
+		{
+			name: "upsert",
+			d: []map[string]interface{}{
+				{"action": "upsert", "id": "a", "name": "John"},
+				{"action": "upsert", "id": "b", "name": "Susan"},
+				{"action": "upsert", "id": "a", "name": "Lizz"},
+				{"action": "insert", "id": "c", "name": nil},
+			},
+			s: []string{
+				"INSERT INTO test (id,name) values ('b','Susan'),('a','Lizz') ON CONFLICT (id) DO UPDATE SET name=excluded.name;",
+				"INSERT INTO test (id,name) values ('c',NULL);",
+			},
+		},
+		{
+			name: "upsert different columns",
+			d: []map[string]interface{}{
+				{"action": "upsert", "id": "a", "name": "John"},
+				{"action": "upsert", "id": "b", "score": 80},
+				{"action": "upsert", "id": "c", "name": "Diana"},
+			},
+			s: []string{
+				"INSERT INTO test (id,name) values ('a','John'),('c','Diana') ON CONFLICT (id) DO UPDATE SET name=excluded.name;",
+				"INSERT INTO test (id,score) values ('b',80) ON CONFLICT (id) DO UPDATE SET score=excluded.score;",
+			},
+		},
+		{
+			name: "skip invalid",
+			d: []map[string]interface{}{
+				{"action": "replace", "id": 1, "name": "John"},
+				{"action": "delete", "name": "Susan"},
+				{"action": "insert", "id": 3, "name": "Diana"},
+			},
+			s: []string{
+				"INSERT INTO test (id,name) values (3,'Diana');",
+			},
+		},
+		{
+			name: "single invalid",
+			d: []map[string]interface{}{
+				{"action": "replace", "id": 1, "name": "John"},
+			},
+			err: "invalid rowkind replace",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sqls, err := s.buildSqls(ctx, "test", tt.d)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.s, sqls)
+		})
+	}
+}
+
+func TestConfigureUpsert(t *testing.T) {
+	s := &sqlSink{}
+	err := s.Configure(map[string]interface{}{
+		"url":    "sqlite://test.db",
+		"table":  "test",
+		"upsert": true,
+	})
+	assert.EqualError(t, err, "keyField is required when upsert is set")
+	err = s.Configure(map[string]interface{}{
+		"url":         "clickhouse://localhost:9000/test",
+		"table":       "test",
+		"createTable": true,
+	})
+	assert.EqualError(t, err, "upsert and create table are not supported for driver clickhouse")
+}
+
 func rowsToMap(rows *sql.Rows) ([]map[string]interface{}, error) {
 	cols, _ := rows.Columns()
 
@@ -0,0 +1,607 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/cert"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+const (
+	SASL_NONE  = "none"
+	SASL_PLAIN = "plain"
+	SASL_SCRAM = "scram"
+
+	OFFSET_EARLIEST = "earliest"
+	OFFSET_LATEST   = "latest"
+)
+
+type sourceConf struct {
+	Brokers string `json:"brokers"`
+	// The consumer group id, default to ekuiper_<ruleId>_<opId>
+	GroupId string `json:"groupId"`
+	// If true, the datasource is a regular expression to match the topics
+	TopicRegex bool `json:"topicRegex"`
+	// Where to start when the group has no committed offset for the partition: earliest or latest
+	StartOffset string `json:"startOffset"`
+	// The interval in milliseconds to commit the offsets to the group
+	CommitInterval int `json:"commitInterval"`
+	MaxBytes       int `json:"maxBytes"`
+
+	SaslAuthType string `json:"saslAuthType"`
+	SaslUserName string `json:"saslUserName"`
+	SaslPassword string `json:"saslPassword"`
+
+	UseTls             bool   `json:"useTls"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	Certification      string `json:"certificationPath"`
+	PrivateKPath       string `json:"privateKeyPath"`
+	RootCaPath         string `json:"rootCaPath"`
+}
+
+// partitionReader reads the messages of one topic partition from the offset
+type partitionReader interface {
+	ReadMessage(ctx context.Context) (kafkago.Message, error)
+	Close() error
+}
+
+// consumerGroup joins the group and returns a generation for each rebalance
+type consumerGroup interface {
+	Next(ctx context.Context) (generation, error)
+	Close() error
+}
+
+// generation is the partitions assigned to the member until the next rebalance
+type generation interface {
+	GenerationId() int32
+	PartitionAssignments() map[string][]kafkago.PartitionAssignment
+	Start(fn func(ctx context.Context))
+	CommitOffsets(offsets map[string]map[int]int64) error
+}
+
+type kafkaGroup struct {
+	*kafkago.ConsumerGroup
+}
+
+func (g *kafkaGroup) Next(ctx context.Context) (generation, error) {
+	gen, err := g.ConsumerGroup.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaGeneration{gen}, nil
+}
+
+type kafkaGeneration struct {
+	*kafkago.Generation
+}
+
+func (g *kafkaGeneration) GenerationId() int32 {
+	return g.ID
+}
+
+func (g *kafkaGeneration) PartitionAssignments() map[string][]kafkago.PartitionAssignment {
+	return g.Assignments
+}
+
+type kafkaSource struct {
+	c       *sourceConf
+	topics  []string
+	pattern *regexp.Regexp
+	dialer  *kafkago.Dialer
+
+	mu sync.Mutex
+	// The offset after the last processed message of each assigned partition. It is the offset saved by the checkpoint
+	offsets map[string]int64
+	// The offsets saved by the last completed checkpoint which are committed to the group. It is nil if the rule has no
+	// checkpoint, then the processed offsets are committed.
+	checkpointed map[string]int64
+	// If the offsets are restored by Rewind and not used by a generation yet
+	rewound bool
+	// Increased by each assignment so that the acks of the tuples read in the previous generations are ignored
+	generation int
+
+	newReader func(topic string, partition int, offset int64) (partitionReader, error)
+	newGroup  func(groupId string, topics []string, startOffset int64) (consumerGroup, error)
+	cancel    context.CancelFunc
+}
+
+func (s *kafkaSource) Configure(datasource string, props map[string]interface{}) error {
+	c := &sourceConf{
+		Brokers:        "localhost:9092",
+		StartOffset:    OFFSET_LATEST,
+		CommitInterval: 5000,
+		MaxBytes:       1e6,
+		SaslAuthType:   SASL_NONE,
+	}
+	if err := cast.MapToStruct(props, c); err != nil {
+		return err
+	}
+	if strings.TrimSpace(c.Brokers) == "" {
+		return fmt.Errorf("brokers can not be empty")
+	}
+	if strings.TrimSpace(datasource) == "" {
+		return fmt.Errorf("topic can not be empty")
+	}
+	if c.TopicRegex {
+		p, err := regexp.Compile(datasource)
+		if err != nil {
+			return fmt.Errorf("invalid topic regex %s: %v", datasource, err)
+		}
+		s.pattern = p
+	} else {
+		s.topics = nil
+		for _, t := range strings.Split(datasource, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				s.topics = append(s.topics, t)
+			}
+		}
+	}
+	if c.StartOffset != OFFSET_EARLIEST && c.StartOffset != OFFSET_LATEST {
+		return fmt.Errorf("startOffset must be earliest or latest but got %s", c.StartOffset)
+	}
+	if c.CommitInterval <= 0 {
+		return fmt.Errorf("commitInterval must be positive but got %d", c.CommitInterval)
+	}
+	if !(c.SaslAuthType == SASL_NONE || c.SaslAuthType == SASL_SCRAM || c.SaslAuthType == SASL_PLAIN) {
+		return fmt.Errorf("saslAuthType incorrect")
+	}
+	if (c.SaslAuthType == SASL_SCRAM || c.SaslAuthType == SASL_PLAIN) && (c.SaslUserName == "" || c.SaslPassword == "") {
+		return fmt.Errorf("username and password can not be empty")
+	}
+	dialer, err := newDialer(c)
+	if err != nil {
+		return err
+	}
+	s.dialer = dialer
+	s.c = c
+	if s.offsets == nil {
+		s.offsets = make(map[string]int64)
+	}
+	if s.newReader == nil {
+		s.newReader = s.kafkaReader
+	}
+	if s.newGroup == nil {
+		s.newGroup = s.kafkaGroup
+	}
+	return nil
+}
+
+func newDialer(c *sourceConf) (*kafkago.Dialer, error) {
+	var (
+		mechanism sasl.Mechanism
+		err       error
+	)
+	switch c.SaslAuthType {
+	case SASL_PLAIN:
+		mechanism = plain.Mechanism{
+			Username: c.SaslUserName,
+			Password: c.SaslPassword,
+		}
+	case SASL_SCRAM:
+		mechanism, err = scram.Mechanism(scram.SHA512, c.SaslUserName, c.SaslPassword)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var tlsConfig *tls.Config
+	if c.UseTls {
+		tlsConfig, err = cert.GenerateTLSForClient(cert.TlsConfigurationOptions{
+			SkipCertVerify: c.InsecureSkipVerify,
+			CertFile:       c.Certification,
+			KeyFile:        c.PrivateKPath,
+			CaFile:         c.RootCaPath,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &kafkago.Dialer{
+		Timeout:       10 * time.Second,
+		DualStack:     true,
+		SASLMechanism: mechanism,
+		TLS:           tlsConfig,
+	}, nil
+}
+
+func (s *kafkaSource) kafkaReader(topic string, partition int, offset int64) (partitionReader, error) {
+	r := kafkago.NewReader(kafkago.ReaderConfig{
+		Brokers:   strings.Split(s.c.Brokers, ","),
+		Topic:     topic,
+		Partition: partition,
+		Dialer:    s.dialer,
+		MaxBytes:  s.c.MaxBytes,
+	})
+	if err := r.SetOffset(offset); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *kafkaSource) kafkaGroup(groupId string, topics []string, startOffset int64) (consumerGroup, error) {
+	group, err := kafkago.NewConsumerGroup(kafkago.ConsumerGroupConfig{
+		ID:          groupId,
+		Brokers:     strings.Split(s.c.Brokers, ","),
+		Dialer:      s.dialer,
+		Topics:      topics,
+		StartOffset: startOffset,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &kafkaGroup{group}, nil
+}
+
+func (s *kafkaSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	exeCtx, cancel := ctx.WithCancel()
+	s.cancel = cancel
+	topics, err := s.resolveTopics(exeCtx)
+	if err != nil {
+		errCh <- err
+		return
+	}
+	groupId := s.c.GroupId
+	if groupId == "" {
+		groupId = fmt.Sprintf("ekuiper_%s_%s", ctx.GetRuleId(), ctx.GetOpId())
+	}
+	startOffset := kafkago.LastOffset
+	if s.c.StartOffset == OFFSET_EARLIEST {
+		startOffset = kafkago.FirstOffset
+	}
+	group, err := s.newGroup(groupId, topics, startOffset)
+	if err != nil {
+		errCh <- fmt.Errorf("kafka source fails to create consumer group %s: %v", groupId, err)
+		return
+	}
+	defer group.Close()
+	logger.Infof("kafka source joins group %s for topics %v", groupId, topics)
+	for {
+		// Next blocks until the previous generation ends by a rebalance
+		gen, err := group.Next(exeCtx)
+		if err != nil {
+			if exeCtx.Err() != nil {
+				logger.Infof("kafka source done")
+				return
+			}
+			errCh <- fmt.Errorf("kafka source fails to join group %s: %v", groupId, err)
+			return
+		}
+		logger.Infof("kafka source gets generation %d with assignments %v", gen.GenerationId(), gen.PartitionAssignments())
+		starts, genSeq := s.assign(gen.PartitionAssignments())
+		for topic, assignments := range gen.PartitionAssignments() {
+			for _, a := range assignments {
+				topic, partition, offset := topic, a.ID, starts[offsetKey(topic, a.ID)]
+				gen.Start(func(genCtx context.Context) {
+					r, err := s.newReader(topic, partition, offset)
+					if err != nil {
+						logger.Errorf("kafka source fails to read %s/%d from %d: %v", topic, partition, offset, err)
+						return
+					}
+					defer r.Close()
+					s.consume(ctx, genCtx, genSeq, r, consumer)
+				})
+			}
+		}
+		gen.Start(func(genCtx context.Context) {
+			s.commit(ctx, genCtx, gen)
+		})
+	}
+}
+
+// resolveTopics returns the topic list or all topics matching the regex in the cluster
+func (s *kafkaSource) resolveTopics(ctx context.Context) ([]string, error) {
+	if s.pattern == nil {
+		return s.topics, nil
+	}
+	var (
+		partitions []kafkago.Partition
+		err        error
+	)
+	for _, broker := range strings.Split(s.c.Brokers, ",") {
+		var conn *kafkago.Conn
+		conn, err = s.dialer.DialContext(ctx, "tcp", strings.TrimSpace(broker))
+		if err != nil {
+			continue
+		}
+		partitions, err = conn.ReadPartitions()
+		_ = conn.Close()
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("kafka source fails to list topics: %v", err)
+	}
+	topics := matchTopics(s.pattern, partitions)
+	if len(topics) == 0 {
+		return nil, fmt.Errorf("no topic matches %s", s.pattern)
+	}
+	return topics, nil
+}
+
+func matchTopics(pattern *regexp.Regexp, partitions []kafkago.Partition) []string {
+	set := make(map[string]struct{})
+	for _, p := range partitions {
+		if pattern.MatchString(p.Topic) {
+			set[p.Topic] = struct{}{}
+		}
+	}
+	topics := make([]string, 0, len(set))
+	for t := range set {
+		topics = append(topics, t)
+	}
+	sort.Strings(topics)
+	return topics
+}
+
+// assign returns the offset to start reading of each assigned partition. The rewound offsets are preferred to the
+// committed offsets of the group only in the first generation after Rewind. After a rebalance, a partition may have
+// been read and committed further by another member, so the committed offset is used and the offsets of the revoked
+// partitions are dropped. It also returns the sequence of the assignment to ack the tuples.
+func (s *kafkaSource) assign(assignments map[string][]kafkago.PartitionAssignment) (map[string]int64, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	starts := make(map[string]int64)
+	offsets := make(map[string]int64)
+	for topic, as := range assignments {
+		for _, a := range as {
+			k := offsetKey(topic, a.ID)
+			starts[k] = a.Offset
+			if o, ok := s.offsets[k]; ok && s.rewound {
+				starts[k] = o
+				offsets[k] = o
+			} else if a.Offset >= 0 {
+				// FirstOffset and LastOffset are negative which means no committed offset
+				offsets[k] = a.Offset
+			}
+		}
+	}
+	s.offsets = offsets
+	s.rewound = false
+	s.generation++
+	return starts, s.generation
+}
+
+func (s *kafkaSource) consume(ctx api.StreamContext, genCtx context.Context, genSeq int, r partitionReader, consumer chan<- api.SourceTuple) {
+	logger := ctx.GetLogger()
+	for {
+		msg, err := r.ReadMessage(genCtx)
+		if err != nil {
+			if genCtx.Err() == nil {
+				logger.Errorf("kafka source fails to read message: %v", err)
+			}
+			return
+		}
+		rcvTime := conf.GetNow()
+		logger.Debugf("kafka source receive %s/%d@%d", msg.Topic, msg.Partition, msg.Offset)
+		meta := map[string]interface{}{
+			"topic":     msg.Topic,
+			"partition": msg.Partition,
+			"offset":    msg.Offset,
+			"key":       string(msg.Key),
+			"timestamp": msg.Time.UnixMilli(),
+		}
+		if len(msg.Headers) > 0 {
+			headers := make(map[string]interface{}, len(msg.Headers))
+			for _, h := range msg.Headers {
+				headers[h.Key] = string(h.Value)
+			}
+			meta["headers"] = headers
+		}
+		var tuples []api.SourceTuple
+		results, e := ctx.DecodeIntoList(msg.Value)
+		if e != nil {
+			tuples = []api.SourceTuple{&xsql.ErrorSourceTuple{
+				Error:   fmt.Errorf("Invalid data format, cannot decode %s with error %s", string(msg.Value), e),
+				Payload: msg.Value,
+			}}
+		} else {
+			for _, result := range results {
+				tuples = append(tuples, &kafkaTuple{DefaultSourceTuple: api.NewDefaultSourceTupleWithTime(result, meta, rcvTime)})
+			}
+			// The offset is advanced only when the last tuple of the message is processed by the source node. Other
+			// tuples emitted by then may still be in the buffer, so the saved offset must not cover them.
+			if len(tuples) > 0 {
+				key, next := offsetKey(msg.Topic, msg.Partition), msg.Offset+1
+				tuples[len(tuples)-1].(*kafkaTuple).ack = func() {
+					s.ack(genSeq, key, next)
+				}
+			}
+		}
+		for _, t := range tuples {
+			select {
+			case consumer <- t:
+			case <-genCtx.Done():
+				return
+			}
+		}
+	}
+}
+
+// kafkaTuple is the tuple of a message which advances the offset of the partition once processed
+type kafkaTuple struct {
+	*api.DefaultSourceTuple
+	ack func()
+}
+
+func (t *kafkaTuple) Ack() {
+	if t.ack != nil {
+		t.ack()
+	}
+}
+
+// ack advances the processed offset of the partition. The tuples are processed in order for each partition unless the
+// rule runs with concurrency, so the offset never goes back.
+func (s *kafkaSource) ack(genSeq int, key string, next int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if genSeq != s.generation {
+		return
+	}
+	if o, ok := s.offsets[key]; !ok || o < next {
+		s.offsets[key] = next
+	}
+}
+
+// commit commits the offsets of the last completed checkpoint to the group periodically until the generation ends.
+// Without checkpoint, the processed offsets are committed. The messages processed but not sent by the sinks yet are
+// lost if the rule restarts without state, so it is at-most-once.
+func (s *kafkaSource) commit(ctx api.StreamContext, genCtx context.Context, gen generation) {
+	ticker := time.NewTicker(time.Duration(s.c.CommitInterval) * time.Millisecond)
+	defer ticker.Stop()
+	doCommit := func() {
+		offsets := make(map[string]map[int]int64)
+		s.mu.Lock()
+		src := s.checkpointed
+		if src == nil {
+			src = s.offsets
+		}
+		for topic, assignments := range gen.PartitionAssignments() {
+			for _, a := range assignments {
+				// the checkpointed offset may be behind the offset committed by another member before the rebalance
+				if o, ok := src[offsetKey(topic, a.ID)]; ok && o >= a.Offset {
+					if offsets[topic] == nil {
+						offsets[topic] = make(map[int]int64)
+					}
+					offsets[topic][a.ID] = o
+				}
+			}
+		}
+		s.mu.Unlock()
+		if len(offsets) == 0 {
+			return
+		}
+		if err := gen.CommitOffsets(offsets); err != nil {
+			ctx.GetLogger().Warnf("kafka source fails to commit offsets %v: %v", offsets, err)
+		}
+	}
+	for {
+		select {
+		case <-ticker.C:
+			doCommit()
+		case <-genCtx.Done():
+			doCommit()
+			return
+		}
+	}
+}
+
+// GetOffset returns the offset after the last processed message of each partition keyed by topic:partition
+func (s *kafkaSource) GetOffset() (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	result := make(map[string]interface{}, len(s.offsets))
+	for k, v := range s.offsets {
+		result[k] = v
+	}
+	return result, nil
+}
+
+func (s *kafkaSource) Rewind(offset interface{}) error {
+	offsets, err := toOffsets(offset)
+	if err != nil {
+		return err
+	}
+	checkpointed := make(map[string]int64, len(offsets))
+	for k, v := range offsets {
+		checkpointed[k] = v
+	}
+	s.mu.Lock()
+	s.offsets = offsets
+	// the rewound offsets are saved by a completed checkpoint
+	s.checkpointed = checkpointed
+	s.rewound = true
+	s.mu.Unlock()
+	return nil
+}
+
+// Commit is called once a checkpoint which saves the offsets completes. The offsets are committed to the group in the
+// next commit interval.
+func (s *kafkaSource) Commit(offset interface{}) error {
+	offsets, err := toOffsets(offset)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	s.checkpointed = offsets
+	s.mu.Unlock()
+	return nil
+}
+
+func toOffsets(offset interface{}) (map[string]int64, error) {
+	m, ok := offset.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid kafka offset %v", offset)
+	}
+	offsets := make(map[string]int64, len(m))
+	for k, v := range m {
+		if _, _, err := parseOffsetKey(k); err != nil {
+			return nil, err
+		}
+		o, err := cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kafka offset of %s: %v", k, err)
+		}
+		offsets[k] = o
+	}
+	return offsets, nil
+}
+
+func offsetKey(topic string, partition int) string {
+	return topic + ":" + strconv.Itoa(partition)
+}
+
+func parseOffsetKey(key string) (string, int, error) {
+	i := strings.LastIndex(key, ":")
+	if i <= 0 {
+		return "", 0, fmt.Errorf("invalid kafka offset key %s", key)
+	}
+	p, err := strconv.Atoi(key[i+1:])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid kafka offset key %s", key)
+	}
+	return key[:i], p, nil
+}
+
+func (s *kafkaSource) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Closing kafka source")
+	if s.cancel != nil {
+		s.cancel()
+	}
+	return nil
+}
+
+func Kafka() api.Source {
+	return &kafkaSource{}
+}
@@ -0,0 +1,431 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/converter"
+	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// mockReader is an in-process stand-in of a partition of the broker
+type mockReader struct {
+	msgs []kafkago.Message
+}
+
+func (r *mockReader) ReadMessage(ctx context.Context) (kafkago.Message, error) {
+	if len(r.msgs) == 0 {
+		<-ctx.Done()
+		return kafkago.Message{}, io.EOF
+	}
+	m := r.msgs[0]
+	r.msgs = r.msgs[1:]
+	return m, nil
+}
+
+func (r *mockReader) Close() error {
+	return nil
+}
+
+// mockGroup returns a single generation and then blocks until the source is closed
+type mockGroup struct {
+	gen    *mockGeneration
+	called bool
+}
+
+func (g *mockGroup) Next(ctx context.Context) (generation, error) {
+	if !g.called {
+		g.called = true
+		return g.gen, nil
+	}
+	<-ctx.Done()
+	// the generation ends by the rebalance, wait for all the functions to exit like the kafka group
+	g.gen.cancel()
+	g.gen.wg.Wait()
+	return nil, ctx.Err()
+}
+
+func (g *mockGroup) Close() error {
+	return nil
+}
+
+type mockGeneration struct {
+	assignments map[string][]kafkago.PartitionAssignment
+	ctx         context.Context
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+
+	mu        sync.Mutex
+	committed map[string]map[int]int64
+}
+
+func (g *mockGeneration) GenerationId() int32 {
+	return 1
+}
+
+func (g *mockGeneration) PartitionAssignments() map[string][]kafkago.PartitionAssignment {
+	return g.assignments
+}
+
+func (g *mockGeneration) Start(fn func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		fn(g.ctx)
+	}()
+}
+
+func (g *mockGeneration) CommitOffsets(offsets map[string]map[int]int64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.committed = offsets
+	return nil
+}
+
+func TestConfigure(t *testing.T) {
+	tests := []struct {
+		ds    string
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			ds:    "t1, t2",
+			props: map[string]interface{}{"brokers": "localhost:9092"},
+		},
+		{
+			ds:    "t.*",
+			props: map[string]interface{}{"topicRegex": true},
+		},
+		{
+			ds:    "",
+			props: map[string]interface{}{},
+			err:   "topic can not be empty",
+		},
+		{
+			ds:    "t(",
+			props: map[string]interface{}{"topicRegex": true},
+			err:   "invalid topic regex t(: error parsing regexp: missing closing ): `t(`",
+		},
+		{
+			ds:    "t1",
+			props: map[string]interface{}{"startOffset": "middle"},
+			err:   "startOffset must be earliest or latest but got middle",
+		},
+		{
+			ds:    "t1",
+			props: map[string]interface{}{"saslAuthType": "plain"},
+			err:   "username and password can not be empty",
+		},
+		{
+			ds:    "t1",
+			props: map[string]interface{}{"saslAuthType": "kerberos"},
+			err:   "saslAuthType incorrect",
+		},
+		{
+			ds:    "t1",
+			props: map[string]interface{}{"saslAuthType": "scram", "saslUserName": "u", "saslPassword": "p", "useTls": true, "insecureSkipVerify": true},
+		},
+	}
+	for i, tt := range tests {
+		s := &kafkaSource{}
+		err := s.Configure(tt.ds, tt.props)
+		if tt.err == "" {
+			assert.NoError(t, err, "case %d", i)
+		} else {
+			assert.EqualError(t, err, tt.err, "case %d", i)
+		}
+	}
+	s := &kafkaSource{}
+	require.NoError(t, s.Configure("t1, t2", nil))
+	assert.Equal(t, []string{"t1", "t2"}, s.topics)
+}
+
+func TestMatchTopics(t *testing.T) {
+	partitions := []kafkago.Partition{
+		{Topic: "sensor_b", ID: 0},
+		{Topic: "sensor_a", ID: 0},
+		{Topic: "sensor_a", ID: 1},
+		{Topic: "other", ID: 0},
+	}
+	assert.Equal(t, []string{"sensor_a", "sensor_b"}, matchTopics(regexp.MustCompile("^sensor_.*"), partitions))
+	assert.Equal(t, []string{}, matchTopics(regexp.MustCompile("^none"), partitions))
+}
+
+func TestRewind(t *testing.T) {
+	s := &kafkaSource{}
+	require.NoError(t, s.Configure("t1", nil))
+	starts, _ := s.assign(map[string][]kafkago.PartitionAssignment{"t1": {{ID: 0, Offset: 8}}})
+	assert.Equal(t, map[string]int64{"t1:0": 8}, starts)
+
+	require.NoError(t, s.Rewind(map[string]interface{}{"t1:0": int64(10), "t1:1": 3}))
+	offset, err := s.GetOffset()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"t1:0": int64(10), "t1:1": int64(3)}, offset)
+
+	assert.EqualError(t, s.Rewind("t1:0"), "invalid kafka offset t1:0")
+	assert.EqualError(t, s.Rewind(map[string]interface{}{"t1": 1}), "invalid kafka offset key t1")
+}
+
+func TestAssign(t *testing.T) {
+	s := &kafkaSource{}
+	require.NoError(t, s.Configure("t1", nil))
+	require.NoError(t, s.Rewind(map[string]interface{}{"t1:0": int64(10), "t1:1": 3, "t1:2": 5}))
+	// the first generation after rewind starts from the rewound offsets
+	starts, genSeq := s.assign(map[string][]kafkago.PartitionAssignment{
+		"t1": {{ID: 0, Offset: 8}, {ID: 1, Offset: 8}, {ID: 3, Offset: kafkago.FirstOffset}},
+	})
+	assert.Equal(t, map[string]int64{"t1:0": 10, "t1:1": 3, "t1:3": kafkago.FirstOffset}, starts)
+	offset, _ := s.GetOffset()
+	assert.Equal(t, map[string]interface{}{"t1:0": int64(10), "t1:1": int64(3)}, offset)
+
+	// t1:1 is revoked and read by another member
+	starts, _ = s.assign(map[string][]kafkago.PartitionAssignment{"t1": {{ID: 0, Offset: 12}}})
+	assert.Equal(t, map[string]int64{"t1:0": 12}, starts)
+	// the tuple read in the previous generation is processed late
+	s.ack(genSeq, "t1:1", 4)
+	offset, _ = s.GetOffset()
+	assert.Equal(t, map[string]interface{}{"t1:0": int64(12)}, offset)
+
+	// t1:1 comes back with the offset committed by the other member
+	starts, _ = s.assign(map[string][]kafkago.PartitionAssignment{"t1": {{ID: 0, Offset: 12}, {ID: 1, Offset: 20}}})
+	assert.Equal(t, map[string]int64{"t1:0": 12, "t1:1": 20}, starts)
+	offset, _ = s.GetOffset()
+	assert.Equal(t, map[string]interface{}{"t1:0": int64(12), "t1:1": int64(20)}, offset)
+}
+
+func TestConsume(t *testing.T) {
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, conf.Log)
+	cv, _ := converter.GetOrCreateConverter(&ast.Options{FORMAT: "json"})
+	ctx = kctx.WithValue(ctx, kctx.DecodeKey, cv)
+	ts := time.UnixMilli(1690000000000)
+	r := &mockReader{msgs: []kafkago.Message{
+		{Topic: "t1", Partition: 1, Offset: 5, Key: []byte("k1"), Value: []byte(`{"a":1}`), Time: ts, Headers: []kafkago.Header{{Key: "h", Value: []byte("v")}}},
+		{Topic: "t1", Partition: 1, Offset: 6, Value: []byte(`invalid`), Time: ts},
+		{Topic: "t1", Partition: 1, Offset: 7, Value: []byte(`[{"a":2},{"a":3}]`), Time: ts},
+	}}
+	s := &kafkaSource{}
+	require.NoError(t, s.Configure("t1", nil))
+	consumer := make(chan api.SourceTuple, 10)
+	genCtx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.consume(ctx, genCtx, 0, r, consumer)
+		close(done)
+	}()
+	var result []api.SourceTuple
+	for i := 0; i < 3; i++ {
+		select {
+		case tuple := <-consumer:
+			result = append(result, tuple)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+	exp := []map[string]interface{}{{"a": 1.0}, {"a": 2.0}, {"a": 3.0}}
+	for i, tuple := range result {
+		if !reflect.DeepEqual(exp[i], tuple.Message()) {
+			t.Errorf("message %d mismatch, exp %v, got %v", i, exp[i], tuple.Message())
+		}
+	}
+	assert.Equal(t, map[string]interface{}{
+		"topic":     "t1",
+		"partition": 1,
+		"offset":    int64(5),
+		"key":       "k1",
+		"timestamp": int64(1690000000000),
+		"headers":   map[string]interface{}{"h": "v"},
+	}, result[0].Meta())
+	cancel()
+	<-done
+	// the offset only covers the processed tuples and the message is processed after its last tuple
+	offset, _ := s.GetOffset()
+	assert.Equal(t, map[string]interface{}{}, offset)
+	result[0].(api.AckableTuple).Ack()
+	result[1].(api.AckableTuple).Ack()
+	offset, _ = s.GetOffset()
+	assert.Equal(t, map[string]interface{}{"t1:1": int64(6)}, offset)
+	result[2].(api.AckableTuple).Ack()
+	offset, _ = s.GetOffset()
+	assert.Equal(t, map[string]interface{}{"t1:1": int64(8)}, offset)
+}
+
+func TestConsumerGroup(t *testing.T) {
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, conf.Log)
+	cv, _ := converter.GetOrCreateConverter(&ast.Options{FORMAT: "json"})
+	sctx := kctx.WithValue(ctx, kctx.DecodeKey, cv).WithMeta("rule1", "op1", &state.MemoryStore{})
+
+	genCtx, genCancel := context.WithCancel(context.Background())
+	gen := &mockGeneration{
+		assignments: map[string][]kafkago.PartitionAssignment{
+			"t1": {{ID: 0, Offset: 3}, {ID: 1, Offset: kafkago.FirstOffset}},
+		},
+		ctx:    genCtx,
+		cancel: genCancel,
+	}
+	var (
+		groupId     string
+		startOffset int64
+		mu          sync.Mutex
+		readFrom    = make(map[int]int64)
+	)
+	s := &kafkaSource{
+		newGroup: func(id string, topics []string, offset int64) (consumerGroup, error) {
+			groupId, startOffset = id, offset
+			assert.Equal(t, []string{"t1"}, topics)
+			return &mockGroup{gen: gen}, nil
+		},
+		newReader: func(topic string, partition int, offset int64) (partitionReader, error) {
+			mu.Lock()
+			readFrom[partition] = offset
+			mu.Unlock()
+			return &mockReader{msgs: []kafkago.Message{
+				{Topic: topic, Partition: partition, Offset: offset, Value: []byte(fmt.Sprintf(`{"p":%d}`, partition))},
+			}}, nil
+		},
+	}
+	require.NoError(t, s.Configure("t1", map[string]interface{}{"startOffset": "earliest", "commitInterval": 10}))
+	// the rewound offset is preferred to the committed offset of the group
+	require.NoError(t, s.Rewind(map[string]interface{}{"t1:1": 7}))
+
+	consumer := make(chan api.SourceTuple, 10)
+	errCh := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		s.Open(sctx, consumer, errCh)
+		close(done)
+	}()
+	received := make(map[float64]bool)
+	for i := 0; i < 2; i++ {
+		select {
+		case tuple := <-consumer:
+			received[tuple.Message()["p"].(float64)] = true
+			tuple.(api.AckableTuple).Ack()
+		case err := <-errCh:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout")
+		}
+	}
+	assert.Equal(t, map[float64]bool{0: true, 1: true}, received)
+	assert.Equal(t, "ekuiper_rule1_op1", groupId)
+	assert.Equal(t, int64(kafkago.FirstOffset), startOffset)
+	mu.Lock()
+	assert.Equal(t, map[int]int64{0: 3, 1: 7}, readFrom)
+	mu.Unlock()
+	// only t1:0 is covered by a completed checkpoint
+	require.NoError(t, s.Commit(map[string]interface{}{"t1:0": int64(4)}))
+
+	require.NoError(t, s.Close(sctx))
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("source is not closed")
+	}
+	offset, err := s.GetOffset()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"t1:0": int64(4), "t1:1": int64(8)}, offset)
+	gen.mu.Lock()
+	defer gen.mu.Unlock()
+	assert.Equal(t, map[string]map[int]int64{"t1": {0: 4}}, gen.committed)
+}
+
+func TestRewindBuffered(t *testing.T) {
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, conf.Log)
+	cv, _ := converter.GetOrCreateConverter(&ast.Options{FORMAT: "json"})
+	sctx := kctx.WithValue(ctx, kctx.DecodeKey, cv).WithMeta("rule1", "op1", &state.MemoryStore{})
+	// the messages of each partition in the broker
+	logs := map[int][]kafkago.Message{}
+	for p := 0; p < 2; p++ {
+		for o := 0; o < 3; o++ {
+			logs[p] = append(logs[p], kafkago.Message{Topic: "t1", Partition: p, Offset: int64(o), Value: []byte(fmt.Sprintf(`{"p":%d,"o":%d}`, p, o))})
+		}
+	}
+	run := func(s *kafkaSource, consumer chan api.SourceTuple, n int) []api.SourceTuple {
+		genCtx, genCancel := context.WithCancel(context.Background())
+		gen := &mockGeneration{
+			assignments: map[string][]kafkago.PartitionAssignment{
+				"t1": {{ID: 0, Offset: kafkago.FirstOffset}, {ID: 1, Offset: kafkago.FirstOffset}},
+			},
+			ctx:    genCtx,
+			cancel: genCancel,
+		}
+		s.newGroup = func(string, []string, int64) (consumerGroup, error) {
+			return &mockGroup{gen: gen}, nil
+		}
+		s.newReader = func(topic string, partition int, offset int64) (partitionReader, error) {
+			if offset < 0 {
+				offset = 0
+			}
+			return &mockReader{msgs: append([]kafkago.Message{}, logs[partition][offset:]...)}, nil
+		}
+		require.NoError(t, s.Configure("t1", map[string]interface{}{"startOffset": "earliest"}))
+		done := make(chan struct{})
+		go func() {
+			s.Open(sctx, consumer, make(chan error, 1))
+			close(done)
+		}()
+		var result []api.SourceTuple
+		for i := 0; i < n; i++ {
+			select {
+			case tuple := <-consumer:
+				result = append(result, tuple)
+			case <-time.After(5 * time.Second):
+				t.Fatal("timeout")
+			}
+		}
+		require.NoError(t, s.Close(sctx))
+		<-done
+		return result
+	}
+
+	// the source buffer holds the tuples which are not processed by the rule yet
+	s := &kafkaSource{}
+	read := run(s, make(chan api.SourceTuple, 10), 6)
+	processed := make(map[string]bool)
+	for _, tuple := range read {
+		// only the first message of each partition is processed when the rule stops
+		if tuple.Message()["o"].(float64) == 0 {
+			tuple.(api.AckableTuple).Ack()
+			processed[fmt.Sprintf("%v:%v", tuple.Message()["p"], tuple.Message()["o"])] = true
+		}
+	}
+	offset, err := s.GetOffset()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"t1:0": int64(1), "t1:1": int64(1)}, offset)
+
+	s = &kafkaSource{}
+	require.NoError(t, s.Rewind(offset))
+	reread := run(s, make(chan api.SourceTuple, 10), 4)
+	for _, tuple := range reread {
+		processed[fmt.Sprintf("%v:%v", tuple.Message()["p"], tuple.Message()["o"])] = true
+	}
+	assert.Len(t, processed, 6)
+}
@@ -0,0 +1,308 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/extensions/sqldatabase/sqlgen"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+const defaultCdcLimit = 1000
+
+type cdcConfig struct {
+	Table string `json:"table"`
+	// The field to put the rowkind(insert, update or delete) of the change, default to rowkind
+	RowkindField string `json:"rowkindField"`
+	// The max number of changes to read in each poll
+	Limit int `json:"limit"`
+	// The change table populated by the triggers. Only for sqlite, default to ekuiper_cdc_<rule>_<op>
+	ChangeTable string `json:"changeTable"`
+	// The logical replication slot. Only for postgres, default to ekuiper_cdc_<rule>_<op>
+	SlotName string `json:"slotName"`
+	// The server id to register as a replica. Only for mysql, default to a hash of ekuiper_cdc_<rule>_<op>
+	ServerId uint32 `json:"serverId"`
+}
+
+// change is a row change captured from the database
+type change struct {
+	rowkind string
+	data    map[string]interface{}
+	// the offset after this change
+	offset interface{}
+}
+
+// changeCapturer captures the row changes of a table. The index value is the offset of the last emitted change.
+// It is accessed by the source goroutine and the source node which gets the offset, so it must be thread safe.
+type changeCapturer interface {
+	sqlgen.IndexValuer
+	// init prepares the database for capturing like creating the triggers or the replication slot.
+	// The name is unique for each rule and is used as the default name of the created objects.
+	init(db *sql.DB, name string) error
+	// poll reads the changes after the offset
+	poll(db *sql.DB) ([]*change, error)
+	// commit records the offset which is saved by a completed checkpoint or rewound from it
+	commit(offset interface{})
+	// ack releases the changes until the committed offset in the database
+	ack(db *sql.DB) error
+}
+
+func newChangeCapturer(driver string, url string, c *cdcConfig) (changeCapturer, error) {
+	if c.Table == "" {
+		return nil, fmt.Errorf("cdc table is required")
+	}
+	if c.RowkindField == "" {
+		c.RowkindField = "rowkind"
+	}
+	if c.Limit <= 0 {
+		c.Limit = defaultCdcLimit
+	}
+	switch driver {
+	case "sqlite3", "moderncsqlite":
+		return &triggerCapturer{conf: c, dialect: sqliteTrigger}, nil
+	case "postgres", "pgx":
+		return newPgCapturer(c), nil
+	case "mysql":
+		return newBinlogCapturer(url, c), nil
+	default:
+		return nil, fmt.Errorf("cdc is not supported for driver %s", driver)
+	}
+}
+
+// maxCdcNameLen is the max length of the postgres identifiers like the slot name
+const maxCdcNameLen = 63
+
+// cdcName returns the default name of the objects created for capturing, which must not be shared by rules.
+// It only contains the lower case letters, digits and underscores to be valid for all databases.
+func cdcName(ruleId, opId string) string {
+	b := strings.Builder{}
+	b.WriteString("ekuiper_cdc_")
+	for _, r := range strings.ToLower(ruleId + "_" + opId) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	name := b.String()
+	// the sanitized name may conflict or be too long, so add the hash of the original ids
+	if len(name) > maxCdcNameLen || name != "ekuiper_cdc_"+ruleId+"_"+opId {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(ruleId + "\x00" + opId))
+		suffix := fmt.Sprintf("_%08x", h.Sum32())
+		if len(name)+len(suffix) > maxCdcNameLen {
+			name = name[:maxCdcNameLen-len(suffix)]
+		}
+		name += suffix
+	}
+	return name
+}
+
+// triggerDialect defines the statements to capture the changes by triggers into a change table
+type triggerDialect struct {
+	quote       string
+	changeTable string
+	// trigger statement with the name, the event, the table and the body
+	trigger string
+	// check if the trigger exists by the name
+	triggerExists string
+	columns       string
+	jsonObject    string
+}
+
+var sqliteTrigger = &triggerDialect{
+	quote:         `"`,
+	changeTable:   "CREATE TABLE IF NOT EXISTS %s (seq INTEGER PRIMARY KEY AUTOINCREMENT, rowkind TEXT NOT NULL, data TEXT NOT NULL)",
+	trigger:       "CREATE TRIGGER IF NOT EXISTS %s AFTER %s ON %s BEGIN %s; END",
+	triggerExists: "SELECT count(*) FROM sqlite_master WHERE type = 'trigger' AND name = ?",
+	columns:       "SELECT name FROM pragma_table_info(?)",
+	jsonObject:    "json_object",
+}
+
+func (d *triggerDialect) quoteName(name string) string {
+	return d.quote + strings.ReplaceAll(name, d.quote, d.quote+d.quote) + d.quote
+}
+
+// triggerCapturer captures the changes by the triggers which record the rows into a change table in json.
+// The rows of the change table are deleted once their seq is committed.
+type triggerCapturer struct {
+	conf    *cdcConfig
+	dialect *triggerDialect
+
+	mu sync.Mutex
+	// seq of the last emitted change
+	seq int64
+	// seq of the last committed change
+	committed int64
+	// the changes until this seq are deleted
+	purged int64
+}
+
+func (t *triggerCapturer) init(db *sql.DB, name string) error {
+	if t.conf.ChangeTable == "" {
+		t.conf.ChangeTable = name
+	}
+	d := t.dialect
+	if _, err := db.Exec(fmt.Sprintf(d.changeTable, d.quoteName(t.conf.ChangeTable))); err != nil {
+		return fmt.Errorf("fail to create change table %s: %v", t.conf.ChangeTable, err)
+	}
+	cols, err := queryStrings(db, d.columns, t.conf.Table)
+	if err != nil {
+		return fmt.Errorf("fail to get columns of table %s: %v", t.conf.Table, err)
+	}
+	if len(cols) == 0 {
+		return fmt.Errorf("table %s not found", t.conf.Table)
+	}
+	for _, ev := range []struct {
+		event   string
+		rowkind string
+		row     string
+	}{
+		{event: "INSERT", rowkind: ast.RowkindInsert, row: "NEW"},
+		{event: "UPDATE", rowkind: ast.RowkindUpdate, row: "NEW"},
+		{event: "DELETE", rowkind: ast.RowkindDelete, row: "OLD"},
+	} {
+		name := t.conf.ChangeTable + "_" + ev.rowkind
+		exists, err := queryStrings(db, d.triggerExists, name)
+		if err != nil {
+			return fmt.Errorf("fail to check trigger %s: %v", name, err)
+		}
+		if len(exists) > 0 && exists[0] != "0" {
+			continue
+		}
+		args := make([]string, 0, len(cols))
+		for _, c := range cols {
+			args = append(args, fmt.Sprintf("'%s', %s.%s", strings.ReplaceAll(c, "'", "''"), ev.row, d.quoteName(c)))
+		}
+		body := fmt.Sprintf("INSERT INTO %s (rowkind, data) VALUES ('%s', %s(%s))", d.quoteName(t.conf.ChangeTable), ev.rowkind, d.jsonObject, strings.Join(args, ", "))
+		if _, err := db.Exec(fmt.Sprintf(d.trigger, d.quoteName(name), ev.event, d.quoteName(t.conf.Table), body)); err != nil {
+			return fmt.Errorf("fail to create trigger %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (t *triggerCapturer) poll(db *sql.DB) ([]*change, error) {
+	t.mu.Lock()
+	from := t.seq
+	t.mu.Unlock()
+	rows, err := db.Query(fmt.Sprintf("SELECT seq, rowkind, data FROM %s WHERE seq > ? ORDER BY seq LIMIT %d", t.dialect.quoteName(t.conf.ChangeTable), t.conf.Limit), from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []*change
+	for rows.Next() {
+		var (
+			seq     int64
+			rowkind string
+			data    []byte
+		)
+		if err := rows.Scan(&seq, &rowkind, &data); err != nil {
+			return nil, err
+		}
+		m, err := decodeJsonRow(data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid change %d: %v", seq, err)
+		}
+		result = append(result, &change{rowkind: rowkind, data: m, offset: seq})
+	}
+	return result, rows.Err()
+}
+
+func (t *triggerCapturer) commit(v interface{}) {
+	if seq, err := cast.ToInt64(v, cast.CONVERT_SAMEKIND); err == nil {
+		t.mu.Lock()
+		t.committed = seq
+		t.mu.Unlock()
+	}
+}
+
+func (t *triggerCapturer) ack(db *sql.DB) error {
+	t.mu.Lock()
+	committed, purged := t.committed, t.purged
+	t.mu.Unlock()
+	if committed <= purged {
+		return nil
+	}
+	if _, err := db.Exec(fmt.Sprintf("DELETE FROM %s WHERE seq <= ?", t.dialect.quoteName(t.conf.ChangeTable)), committed); err != nil {
+		return fmt.Errorf("fail to purge change table %s until %d: %v", t.conf.ChangeTable, committed, err)
+	}
+	t.mu.Lock()
+	t.purged = committed
+	t.mu.Unlock()
+	return nil
+}
+
+func (t *triggerCapturer) SetIndexValue(v interface{}) {
+	if seq, err := cast.ToInt64(v, cast.CONVERT_SAMEKIND); err == nil {
+		t.mu.Lock()
+		t.seq = seq
+		t.mu.Unlock()
+	}
+}
+
+func (t *triggerCapturer) GetIndexValue() interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.seq
+}
+
+// decodeJsonRow decodes the json row and keeps the integers as int64
+func decodeJsonRow(data []byte) (map[string]interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	m := make(map[string]interface{})
+	if err := d.Decode(&m); err != nil {
+		return nil, err
+	}
+	for k, v := range m {
+		if n, ok := v.(json.Number); ok {
+			if i, err := n.Int64(); err == nil {
+				m[k] = i
+			} else if f, err := n.Float64(); err == nil {
+				m[k] = f
+			} else {
+				m[k] = n.String()
+			}
+		}
+	}
+	return m, nil
+}
+
+func queryStrings(db *sql.DB, query string, args ...interface{}) ([]string, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var result []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		result = append(result, s)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,374 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-mysql-org/go-mysql/mysql"
+	"github.com/go-mysql-org/go-mysql/replication"
+	"github.com/xo/dburl"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// binlogReadTimeout is the max time to wait for the next binlog event in a poll
+const binlogReadTimeout = 100 * time.Millisecond
+
+// binlogPos is the offset of a change in the binlog. The binlog can only be resumed from an event boundary and a
+// rows event may contain several changes, so it is the position of the transaction which the change belongs to and
+// the number of the changes of the captured table in the transaction until it.
+type binlogPos struct {
+	file  string
+	pos   uint32
+	count int
+}
+
+func (p binlogPos) String() string {
+	return fmt.Sprintf("%s:%d:%d", p.file, p.pos, p.count)
+}
+
+func parseBinlogPos(s string) (binlogPos, error) {
+	i := strings.LastIndex(s, ":")
+	if i <= 0 {
+		return binlogPos{}, fmt.Errorf("invalid binlog offset %s", s)
+	}
+	j := strings.LastIndex(s[:i], ":")
+	if j <= 0 {
+		return binlogPos{}, fmt.Errorf("invalid binlog offset %s", s)
+	}
+	pos, err := strconv.ParseUint(s[j+1:i], 10, 32)
+	if err != nil {
+		return binlogPos{}, fmt.Errorf("invalid binlog offset %s: %v", s, err)
+	}
+	count, err := strconv.Atoi(s[i+1:])
+	if err != nil || count < 0 {
+		return binlogPos{}, fmt.Errorf("invalid binlog offset %s: invalid count", s)
+	}
+	return binlogPos{file: s[:j], pos: uint32(pos), count: count}, nil
+}
+
+// binlogCapturer captures the changes by reading the row based binlog as a replica. The database must be configured
+// with binlog_format=ROW and binlog_row_image=FULL. The binlog is purged by the server according to its expiration,
+// so nothing is released by the capturer.
+type binlogCapturer struct {
+	conf   *cdcConfig
+	url    string
+	schema string
+	table  string
+	// the column names in case the binlog has no column metadata
+	columns []string
+
+	syncer   *replication.BinlogSyncer
+	streamer *replication.BinlogStreamer
+	// the transaction being read
+	tx binlogPos
+	// the number of the changes to skip in the first transaction, which are emitted before rewinding
+	skip int
+	// the changes read but not returned due to the limit
+	pending []*change
+
+	mu sync.Mutex
+	// offset of the last emitted change
+	offset string
+}
+
+func newBinlogCapturer(url string, c *cdcConfig) *binlogCapturer {
+	b := &binlogCapturer{conf: c, url: url, table: c.Table}
+	if i := strings.Index(c.Table, "."); i > 0 {
+		b.schema, b.table = c.Table[:i], c.Table[i+1:]
+	}
+	return b
+}
+
+func (b *binlogCapturer) init(db *sql.DB, name string) error {
+	if b.schema == "" {
+		s, err := queryStrings(db, "SELECT COALESCE(DATABASE(), '')")
+		if err != nil {
+			return fmt.Errorf("fail to get the current database: %v", err)
+		}
+		if len(s) == 0 || s[0] == "" {
+			return fmt.Errorf("database is required for table %s", b.table)
+		}
+		b.schema = s[0]
+	}
+	cols, err := queryStrings(db, "SELECT COLUMN_NAME FROM information_schema.COLUMNS WHERE TABLE_SCHEMA = ? AND TABLE_NAME = ? ORDER BY ORDINAL_POSITION", b.schema, b.table)
+	if err != nil {
+		return fmt.Errorf("fail to get columns of table %s: %v", b.conf.Table, err)
+	}
+	if len(cols) == 0 {
+		return fmt.Errorf("table %s not found", b.conf.Table)
+	}
+	b.columns = cols
+
+	b.mu.Lock()
+	offset := b.offset
+	b.mu.Unlock()
+	if offset != "" {
+		p, err := parseBinlogPos(offset)
+		if err != nil {
+			return err
+		}
+		b.tx, b.skip = binlogPos{file: p.file, pos: p.pos}, p.count
+	} else {
+		file, pos, err := binlogStatus(db)
+		if err != nil {
+			return err
+		}
+		b.tx = binlogPos{file: file, pos: pos}
+	}
+
+	cfg, err := b.syncerConfig(name)
+	if err != nil {
+		return err
+	}
+	b.syncer = replication.NewBinlogSyncer(cfg)
+	b.streamer, err = b.syncer.StartSync(mysql.Position{Name: b.tx.file, Pos: b.tx.pos})
+	if err != nil {
+		b.syncer.Close()
+		b.syncer = nil
+		return fmt.Errorf("fail to sync binlog from %s:%d: %v", b.tx.file, b.tx.pos, err)
+	}
+	return nil
+}
+
+func (b *binlogCapturer) syncerConfig(name string) (replication.BinlogSyncerConfig, error) {
+	u, err := dburl.Parse(b.url)
+	if err != nil {
+		return replication.BinlogSyncerConfig{}, err
+	}
+	cfg := replication.BinlogSyncerConfig{
+		ServerID: b.conf.ServerId,
+		Flavor:   mysql.MySQLFlavor,
+		Host:     u.Hostname(),
+		Port:     3306,
+		Logger:   conf.Log,
+	}
+	if p := u.Port(); p != "" {
+		port, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid port %s: %v", p, err)
+		}
+		cfg.Port = uint16(port)
+	}
+	if u.User != nil {
+		cfg.User = u.User.Username()
+		cfg.Password, _ = u.User.Password()
+	}
+	// the server id must be unique among the replicas, so derive it from the name which is unique per rule
+	if cfg.ServerID == 0 {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(name))
+		cfg.ServerID = h.Sum32()
+		if cfg.ServerID == 0 {
+			cfg.ServerID = 1
+		}
+	}
+	return cfg, nil
+}
+
+// binlogStatus returns the current binlog position of the server
+func binlogStatus(db *sql.DB) (string, uint32, error) {
+	rows, err := db.Query("SHOW MASTER STATUS")
+	if err != nil {
+		// renamed since mysql 8.2
+		rows, err = db.Query("SHOW BINARY LOG STATUS")
+		if err != nil {
+			return "", 0, fmt.Errorf("fail to get binlog status: %v", err)
+		}
+	}
+	defer rows.Close()
+	cols, err := rows.Columns()
+	if err != nil {
+		return "", 0, err
+	}
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return "", 0, err
+		}
+		return "", 0, fmt.Errorf("binlog is not enabled")
+	}
+	values := make([]interface{}, len(cols))
+	for i := range values {
+		values[i] = new(sql.NullString)
+	}
+	if err := rows.Scan(values...); err != nil {
+		return "", 0, err
+	}
+	if len(cols) < 2 {
+		return "", 0, fmt.Errorf("invalid binlog status columns %v", cols)
+	}
+	pos, err := strconv.ParseUint(values[1].(*sql.NullString).String, 10, 32)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid binlog position: %v", err)
+	}
+	return values[0].(*sql.NullString).String, uint32(pos), nil
+}
+
+func (b *binlogCapturer) poll(_ *sql.DB) ([]*change, error) {
+	for len(b.pending) < b.conf.Limit {
+		ctx, cancel := context.WithTimeout(context.Background(), binlogReadTimeout)
+		ev, err := b.streamer.GetEvent(ctx)
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) {
+				break
+			}
+			return nil, err
+		}
+		b.handle(ev)
+	}
+	n := len(b.pending)
+	if n > b.conf.Limit {
+		n = b.conf.Limit
+	}
+	result := b.pending[:n]
+	b.pending = append([]*change(nil), b.pending[n:]...)
+	return result, nil
+}
+
+// handle tracks the transaction position and collects the changes of the captured table
+func (b *binlogCapturer) handle(ev *replication.BinlogEvent) {
+	switch e := ev.Event.(type) {
+	case *replication.RotateEvent:
+		// also sent when the sync starts, so the changes to skip are kept
+		b.tx = binlogPos{file: string(e.NextLogName), pos: uint32(e.Position)}
+	case *replication.XIDEvent:
+		b.endTx(ev.Header.LogPos)
+	case *replication.QueryEvent:
+		// statements other than BEGIN like DDL or COMMIT of non-transactional tables end the transaction
+		if !strings.EqualFold(strings.TrimSpace(string(e.Query)), "BEGIN") {
+			b.endTx(ev.Header.LogPos)
+		}
+	case *replication.RowsEvent:
+		b.handleRows(ev.Header.EventType, e)
+	}
+}
+
+func (b *binlogCapturer) endTx(pos uint32) {
+	b.tx.pos, b.tx.count = pos, 0
+	b.skip = 0
+}
+
+func (b *binlogCapturer) handleRows(t replication.EventType, e *replication.RowsEvent) {
+	if e.Table == nil || string(e.Table.Schema) != b.schema || string(e.Table.Table) != b.table {
+		return
+	}
+	var (
+		rowkind string
+		// update rows are pairs of the before and after image
+		step = 1
+	)
+	switch t {
+	case replication.WRITE_ROWS_EVENTv0, replication.WRITE_ROWS_EVENTv1, replication.WRITE_ROWS_EVENTv2:
+		rowkind = ast.RowkindInsert
+	case replication.UPDATE_ROWS_EVENTv0, replication.UPDATE_ROWS_EVENTv1, replication.UPDATE_ROWS_EVENTv2:
+		rowkind, step = ast.RowkindUpdate, 2
+	case replication.DELETE_ROWS_EVENTv0, replication.DELETE_ROWS_EVENTv1, replication.DELETE_ROWS_EVENTv2:
+		rowkind = ast.RowkindDelete
+	default:
+		return
+	}
+	names := e.Table.ColumnNameString()
+	if len(names) == 0 {
+		names = b.columns
+	}
+	for i := step - 1; i < len(e.Rows); i += step {
+		b.tx.count++
+		if b.tx.count <= b.skip {
+			continue
+		}
+		b.pending = append(b.pending, &change{rowkind: rowkind, data: binlogRow(e.Rows[i], names), offset: b.tx.String()})
+	}
+}
+
+func binlogRow(row []interface{}, names []string) map[string]interface{} {
+	m := make(map[string]interface{}, len(row))
+	for i, v := range row {
+		if i >= len(names) {
+			break
+		}
+		m[names[i]] = binlogValue(v)
+	}
+	return m
+}
+
+// binlogValue converts the decoded binlog value to the types of the rule engine
+func binlogValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case int8:
+		return int64(t)
+	case int16:
+		return int64(t)
+	case int32:
+		return int64(t)
+	case uint8:
+		return int64(t)
+	case uint16:
+		return int64(t)
+	case uint32:
+		return int64(t)
+	case uint64:
+		if t > math.MaxInt64 {
+			return float64(t)
+		}
+		return int64(t)
+	case float32:
+		return float64(t)
+	case []byte:
+		return string(t)
+	default:
+		return v
+	}
+}
+
+// commit does nothing because the binlog is purged by the server
+func (b *binlogCapturer) commit(_ interface{}) {}
+
+func (b *binlogCapturer) ack(_ *sql.DB) error {
+	return nil
+}
+
+func (b *binlogCapturer) Close() error {
+	if b.syncer != nil {
+		b.syncer.Close()
+		b.syncer = nil
+	}
+	return nil
+}
+
+func (b *binlogCapturer) SetIndexValue(v interface{}) {
+	if s, ok := v.(string); ok {
+		b.mu.Lock()
+		b.offset = s
+		b.mu.Unlock()
+	}
+}
+
+func (b *binlogCapturer) GetIndexValue() interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.offset
+}
@@ -0,0 +1,312 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// pgCapturer captures the changes by postgres logical decoding with the built-in test_decoding output plugin.
+// The database must be configured with wal_level=logical. The changes are peeked so that they are kept in the slot
+// until the lsn is committed.
+type pgCapturer struct {
+	conf   *cdcConfig
+	schema string
+	table  string
+
+	mu sync.Mutex
+	// lsn of the last emitted change
+	lsn string
+	// lsn of the last committed change
+	committed string
+	// lsn of the last peeked change including the changes of other tables
+	peeked string
+	// the lsn which the slot is advanced to
+	advanced string
+}
+
+func newPgCapturer(c *cdcConfig) *pgCapturer {
+	schema, table := "public", c.Table
+	if i := strings.Index(c.Table, "."); i > 0 {
+		schema, table = c.Table[:i], c.Table[i+1:]
+	}
+	return &pgCapturer{conf: c, schema: schema, table: table}
+}
+
+func (p *pgCapturer) init(db *sql.DB, name string) error {
+	if p.conf.SlotName == "" {
+		p.conf.SlotName = name
+	}
+	exists, err := queryStrings(db, "SELECT slot_name FROM pg_replication_slots WHERE slot_name = $1", p.conf.SlotName)
+	if err != nil {
+		return fmt.Errorf("fail to check replication slot %s: %v", p.conf.SlotName, err)
+	}
+	if len(exists) == 0 {
+		if _, err := db.Exec("SELECT pg_create_logical_replication_slot($1, 'test_decoding')", p.conf.SlotName); err != nil {
+			return fmt.Errorf("fail to create replication slot %s: %v", p.conf.SlotName, err)
+		}
+	}
+	return nil
+}
+
+func (p *pgCapturer) poll(db *sql.DB) ([]*change, error) {
+	p.mu.Lock()
+	from := p.lsn
+	p.mu.Unlock()
+	rows, err := db.Query("SELECT lsn::text, data FROM pg_logical_slot_peek_changes($1, NULL, $2)", p.conf.SlotName, p.conf.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var (
+		result []*change
+		last   string
+	)
+	for rows.Next() {
+		var lsn, data string
+		if err := rows.Scan(&lsn, &data); err != nil {
+			return nil, err
+		}
+		last = lsn
+		// skip the changes emitted before rewinding
+		if from != "" && compareLsn(lsn, from) <= 0 {
+			continue
+		}
+		c, err := parseTestDecoding(data, p.schema, p.table)
+		if err != nil {
+			return nil, err
+		}
+		if c != nil {
+			c.offset = lsn
+			result = append(result, c)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if last != "" {
+		p.mu.Lock()
+		p.peeked = last
+		p.mu.Unlock()
+	}
+	return result, nil
+}
+
+func (p *pgCapturer) commit(v interface{}) {
+	if s, ok := v.(string); ok {
+		p.mu.Lock()
+		p.committed = s
+		p.mu.Unlock()
+	}
+}
+
+// ackTarget returns the lsn to advance the slot to, or empty if it needs not advance. It is the committed lsn, or the
+// last peeked lsn if all the emitted changes are committed to release the changes of other tables as well.
+func (p *pgCapturer) ackTarget() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	target := p.committed
+	if p.lsn == p.committed && compareLsn(p.peeked, target) > 0 {
+		target = p.peeked
+	}
+	if target == "" || (p.advanced != "" && compareLsn(target, p.advanced) <= 0) {
+		return ""
+	}
+	return target
+}
+
+func (p *pgCapturer) ack(db *sql.DB) error {
+	target := p.ackTarget()
+	if target == "" {
+		return nil
+	}
+	if _, err := db.Exec("SELECT pg_replication_slot_advance($1, $2::pg_lsn)", p.conf.SlotName, target); err != nil {
+		return fmt.Errorf("fail to advance replication slot %s to %s: %v", p.conf.SlotName, target, err)
+	}
+	p.mu.Lock()
+	p.advanced = target
+	p.mu.Unlock()
+	return nil
+}
+
+func (p *pgCapturer) SetIndexValue(v interface{}) {
+	if s, ok := v.(string); ok {
+		p.mu.Lock()
+		p.lsn = s
+		p.mu.Unlock()
+	}
+}
+
+func (p *pgCapturer) GetIndexValue() interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.lsn
+}
+
+// compareLsn compares the lsn in the X/Y hex format
+func compareLsn(a, b string) int {
+	pa, pb := parseLsn(a), parseLsn(b)
+	switch {
+	case pa < pb:
+		return -1
+	case pa > pb:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func parseLsn(lsn string) uint64 {
+	hi, lo, ok := strings.Cut(lsn, "/")
+	if !ok {
+		return 0
+	}
+	h, _ := strconv.ParseUint(hi, 16, 32)
+	l, _ := strconv.ParseUint(lo, 16, 32)
+	return h<<32 | l
+}
+
+// parseTestDecoding parses a change of the table in the test_decoding format like
+// table public.student: UPDATE: id[integer]:1 name[text]:'John'
+// It returns nil for the transaction boundaries and the changes of other tables.
+func parseTestDecoding(data string, schema string, table string) (*change, error) {
+	if !strings.HasPrefix(data, "table ") {
+		return nil, nil
+	}
+	rest := data[len("table "):]
+	i := strings.Index(rest, ": ")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid change %s", data)
+	}
+	s, t, _ := strings.Cut(rest[:i], ".")
+	if unquoteIdent(s) != schema || unquoteIdent(t) != table {
+		return nil, nil
+	}
+	rest = rest[i+2:]
+	kind, tuple, ok := strings.Cut(rest, ": ")
+	if !ok {
+		kind, tuple = strings.TrimSuffix(rest, ":"), ""
+	}
+	c := &change{}
+	switch kind {
+	case "INSERT":
+		c.rowkind = ast.RowkindInsert
+	case "UPDATE":
+		c.rowkind = ast.RowkindUpdate
+		// the old key is printed when the key is changed or replica identity is full
+		if _, n, found := strings.Cut(tuple, "new-tuple: "); found {
+			tuple = n
+		}
+	case "DELETE":
+		c.rowkind = ast.RowkindDelete
+		if tuple == "(no-tuple-data)" {
+			return nil, fmt.Errorf("no key data in delete change of %s, please set the replica identity of the table", table)
+		}
+	default:
+		// TRUNCATE and others
+		return nil, nil
+	}
+	m, err := parseTestDecodingTuple(tuple)
+	if err != nil {
+		return nil, fmt.Errorf("invalid change %s: %v", data, err)
+	}
+	c.data = m
+	return c, nil
+}
+
+// parseTestDecodingTuple parses the columns like id[integer]:1 name[text]:'John' into a map
+func parseTestDecodingTuple(tuple string) (map[string]interface{}, error) {
+	m := make(map[string]interface{})
+	for tuple = strings.TrimSpace(tuple); tuple != ""; tuple = strings.TrimLeft(tuple, " ") {
+		b := strings.Index(tuple, "[")
+		if b <= 0 {
+			return nil, fmt.Errorf("missing column type in %s", tuple)
+		}
+		name := unquoteIdent(tuple[:b])
+		e := strings.Index(tuple[b:], "]:")
+		if e < 0 {
+			return nil, fmt.Errorf("missing column value in %s", tuple)
+		}
+		typ := tuple[b+1 : b+e]
+		tuple = tuple[b+e+2:]
+		var raw string
+		quoted := strings.HasPrefix(tuple, "'")
+		if quoted {
+			var sb strings.Builder
+			j := 1
+			for ; j < len(tuple); j++ {
+				if tuple[j] == '\'' {
+					if j+1 < len(tuple) && tuple[j+1] == '\'' {
+						sb.WriteByte('\'')
+						j++
+						continue
+					}
+					break
+				}
+				sb.WriteByte(tuple[j])
+			}
+			if j >= len(tuple) {
+				return nil, fmt.Errorf("unterminated value of column %s", name)
+			}
+			raw, tuple = sb.String(), tuple[j+1:]
+		} else {
+			j := strings.Index(tuple, " ")
+			if j < 0 {
+				j = len(tuple)
+			}
+			raw, tuple = tuple[:j], tuple[j:]
+		}
+		switch {
+		case !quoted && raw == "null":
+			m[name] = nil
+		case !quoted && raw == "unchanged-toast-datum":
+			// the large value is not changed and not available
+		default:
+			m[name] = convertPgValue(typ, raw)
+		}
+	}
+	return m, nil
+}
+
+func convertPgValue(typ string, raw string) interface{} {
+	switch typ {
+	case "smallint", "integer", "bigint":
+		if i, err := strconv.ParseInt(raw, 10, 64); err == nil {
+			return i
+		}
+	case "real", "double precision", "numeric":
+		if f, err := strconv.ParseFloat(raw, 64); err == nil {
+			return f
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+func unquoteIdent(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return strings.ReplaceAll(s[1:len(s)-1], `""`, `"`)
+	}
+	return s
+}
@@ -17,6 +17,7 @@ package main
 import (
 	"database/sql"
 	"fmt"
+	"io"
 	"time"
 
 	driver2 "github.com/lf-edge/ekuiper/extensions/sqldatabase/driver"
@@ -28,13 +29,16 @@ import (
 )
 
 type sqlConConfig struct {
-	Interval int    `json:"interval"`
-	Url      string `json:"url"`
+	Interval int        `json:"interval"`
+	Url      string     `json:"url"`
+	CdcCfg   *cdcConfig `json:"cdcCfg"`
 }
 
 type sqlsource struct {
 	conf  *sqlConConfig
 	Query sqlgen.SqlQueryGenerator
+	// The change capturer in cdc mode, Query is not used in this mode
+	cdc changeCapturer
 	// The db connection instance
 	db *sql.DB
 }
@@ -58,12 +62,18 @@ func (m *sqlsource) Configure(_ string, props map[string]interface{}) error {
 		return fmt.Errorf("dburl.Parse %s fail with error: %v", cfg.Url, err)
 	}
 
-	generator, err := sqlgen.GetQueryGenerator(driver, props)
-	if err != nil {
-		return fmt.Errorf("GetQueryGenerator %s fail with error: %v", cfg.Url, err)
+	if cfg.CdcCfg != nil {
+		m.cdc, err = newChangeCapturer(driver, cfg.Url, cfg.CdcCfg)
+		if err != nil {
+			return err
+		}
+	} else {
+		generator, err := sqlgen.GetQueryGenerator(driver, props)
+		if err != nil {
+			return fmt.Errorf("GetQueryGenerator %s fail with error: %v", cfg.Url, err)
+		}
+		m.Query = generator
 	}
-
-	m.Query = generator
 	m.conf = cfg
 	db, err := util.FetchDBToOneNode(util.GlobalPool, m.conf.Url)
 	if err != nil {
@@ -75,6 +85,10 @@ func (m *sqlsource) Configure(_ string, props map[string]interface{}) error {
 }
 
 func (m *sqlsource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	if m.cdc != nil {
+		m.openCdc(ctx, consumer, errCh)
+		return
+	}
 	logger := ctx.GetLogger()
 	t := time.NewTicker(time.Duration(m.conf.Interval) * time.Millisecond)
 	defer t.Stop()
@@ -124,12 +138,75 @@ func (m *sqlsource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple,
 	}
 }
 
+// openCdc polls the changes and emits each change as a row with the rowkind field
+func (m *sqlsource) openCdc(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	if c, ok := m.cdc.(io.Closer); ok {
+		defer c.Close()
+	}
+	if err := m.cdc.init(m.db, cdcName(ctx.GetRuleId(), ctx.GetOpId())); err != nil {
+		logger.Errorf("Init cdc error %v", err)
+		errCh <- err
+		return
+	}
+	logger.Infof("Start to capture changes of table %s from offset %v", m.conf.CdcCfg.Table, m.cdc.GetIndexValue())
+	t := time.NewTicker(time.Duration(m.conf.Interval) * time.Millisecond)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			rcvTime := conf.GetNow()
+			changes, err := m.cdc.poll(m.db)
+			if err != nil {
+				logger.Errorf("Poll changes error %v", err)
+				errCh <- err
+				return
+			}
+			for _, c := range changes {
+				c.data[m.conf.CdcCfg.RowkindField] = c.rowkind
+				// update before emitting so that the offset got by the source node covers the emitted change
+				m.cdc.SetIndexValue(c.offset)
+				select {
+				case consumer <- api.NewDefaultSourceTupleWithTime(c.data, nil, rcvTime):
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err := m.cdc.ack(m.db); err != nil {
+				logger.Warnf("Ack changes error %v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *sqlsource) indexValuer() sqlgen.IndexValuer {
+	if m.cdc != nil {
+		return m.cdc
+	}
+	return m.Query
+}
+
 func (m *sqlsource) GetOffset() (interface{}, error) {
-	return m.Query.GetIndexValue(), nil
+	return m.indexValuer().GetIndexValue(), nil
 }
 
 func (m *sqlsource) Rewind(offset interface{}) error {
-	m.Query.SetIndexValue(offset)
+	m.indexValuer().SetIndexValue(offset)
+	// the rewound offset is saved by a completed checkpoint, so the changes until it can be released
+	if m.cdc != nil {
+		m.cdc.commit(offset)
+	}
+	return nil
+}
+
+// Commit is called once a checkpoint which saves the offset completes. The changes until it are released
+// in the next poll.
+func (m *sqlsource) Commit(offset interface{}) error {
+	if m.cdc != nil {
+		m.cdc.commit(offset)
+	}
 	return nil
 }
 
@@ -21,6 +21,7 @@ import (
 	zmq "github.com/pebbe/zmq4"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
@@ -80,7 +81,10 @@ func (s *zmqSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple,
 			}
 			results, e := ctx.DecodeIntoList(m)
 			if e != nil {
-				logger.Errorf("Invalid data format, cannot decode %v with error %s", m, e)
+				consumer <- &xsql.ErrorSourceTuple{
+					Error:   fmt.Errorf("Invalid data format, cannot decode %v with error %s", m, e),
+					Payload: m,
+				}
 			} else {
 				for _, result := range results {
 					consumer <- api.NewDefaultSourceTupleWithTime(result, meta, rcvTime)
@@ -0,0 +1,229 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlgen
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// ColumnType is the sql type category of a column to be created by the sink
+type ColumnType int
+
+const (
+	STRING_COLUMN ColumnType = iota
+	INT_COLUMN
+	FLOAT_COLUMN
+	BOOL_COLUMN
+)
+
+type Column struct {
+	Name string
+	Type ColumnType
+}
+
+// InferColumnType infers the column type from the value in the rule result
+func InferColumnType(v interface{}) ColumnType {
+	switch v.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return INT_COLUMN
+	case float32, float64:
+		return FLOAT_COLUMN
+	case bool:
+		return BOOL_COLUMN
+	default:
+		return STRING_COLUMN
+	}
+}
+
+// SchemaColumnType maps the type in the rule output schema to the column type
+func SchemaColumnType(t ast.DataType) ColumnType {
+	switch t {
+	case ast.BIGINT:
+		return INT_COLUMN
+	case ast.FLOAT:
+		return FLOAT_COLUMN
+	case ast.BOOLEAN:
+		return BOOL_COLUMN
+	default:
+		return STRING_COLUMN
+	}
+}
+
+// WriteGenerator generates the dialect specific statements for writing.
+// All the column names and values are passed in as sql literals which are already quoted if needed.
+type WriteGenerator interface {
+	// UpsertStatement inserts the rows or updates them if the key already exists.
+	// The rows must not contain duplicate keys.
+	UpsertStatement(table string, key string, cols []string, rows [][]string) string
+	CreateTableStatement(table string, key string, cols []Column) string
+}
+
+func GetWriteGenerator(driver string) (WriteGenerator, error) {
+	switch driver {
+	case "sqlite", "sqlite3", "moderncsqlite":
+		return &conflictWriteGenerator{types: sqliteTypes}, nil
+	case "postgres", "pgx":
+		return &conflictWriteGenerator{types: postgresTypes}, nil
+	case "mysql", "mymysql":
+		return &mysqlWriteGenerator{}, nil
+	case "sqlserver":
+		return &sqlServerWriteGenerator{}, nil
+	case "godror", "oracle", "dm":
+		return &oracleWriteGenerator{}, nil
+	default:
+		return nil, fmt.Errorf("upsert and create table are not supported for driver %s", driver)
+	}
+}
+
+// typeMapping maps the column types to the sql types of a dialect.
+// The key type is used for string primary key because some databases cannot index unbounded text.
+type typeMapping struct {
+	str, integer, float, boolean, key string
+}
+
+func (m typeMapping) of(t ColumnType, isKey bool) string {
+	switch t {
+	case INT_COLUMN:
+		return m.integer
+	case FLOAT_COLUMN:
+		return m.float
+	case BOOL_COLUMN:
+		return m.boolean
+	default:
+		if isKey {
+			return m.key
+		}
+		return m.str
+	}
+}
+
+var (
+	sqliteTypes    = typeMapping{str: "TEXT", integer: "INTEGER", float: "REAL", boolean: "BOOLEAN", key: "TEXT"}
+	postgresTypes  = typeMapping{str: "TEXT", integer: "BIGINT", float: "DOUBLE PRECISION", boolean: "BOOLEAN", key: "TEXT"}
+	mysqlTypes     = typeMapping{str: "TEXT", integer: "BIGINT", float: "DOUBLE", boolean: "BOOLEAN", key: "VARCHAR(255)"}
+	sqlServerTypes = typeMapping{str: "NVARCHAR(MAX)", integer: "BIGINT", float: "FLOAT", boolean: "BIT", key: "NVARCHAR(255)"}
+	oracleTypes    = typeMapping{str: "VARCHAR2(4000)", integer: "NUMBER(19)", float: "BINARY_DOUBLE", boolean: "NUMBER(1)", key: "VARCHAR2(255)"}
+)
+
+func columnDefinitions(types typeMapping, key string, cols []Column) string {
+	defs := make([]string, 0, len(cols)+1)
+	for _, c := range cols {
+		defs = append(defs, c.Name+" "+types.of(c.Type, c.Name == key))
+	}
+	if key != "" {
+		defs = append(defs, "PRIMARY KEY ("+key+")")
+	}
+	return strings.Join(defs, ",")
+}
+
+func valuesList(rows [][]string) string {
+	values := make([]string, len(rows))
+	for i, row := range rows {
+		values[i] = "(" + strings.Join(row, ",") + ")"
+	}
+	return strings.Join(values, ",")
+}
+
+// updateAssignments returns the assignments of all the non key columns with the value format like excluded.%s
+func updateAssignments(key string, cols []string, format string) []string {
+	var result []string
+	for _, c := range cols {
+		if c == key {
+			continue
+		}
+		result = append(result, c+"="+fmt.Sprintf(format, c))
+	}
+	return result
+}
+
+// conflictWriteGenerator is for databases supporting INSERT ... ON CONFLICT like sqlite and postgres
+type conflictWriteGenerator struct {
+	types typeMapping
+}
+
+func (g *conflictWriteGenerator) UpsertStatement(table string, key string, cols []string, rows [][]string) string {
+	sqlStr := fmt.Sprintf("INSERT INTO %s (%s) values %s ON CONFLICT (%s) ", table, strings.Join(cols, ","), valuesList(rows), key)
+	if sets := updateAssignments(key, cols, "excluded.%s"); len(sets) > 0 {
+		sqlStr += "DO UPDATE SET " + strings.Join(sets, ",")
+	} else {
+		sqlStr += "DO NOTHING"
+	}
+	return sqlStr + ";"
+}
+
+func (g *conflictWriteGenerator) CreateTableStatement(table string, key string, cols []Column) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", table, columnDefinitions(g.types, key, cols))
+}
+
+type mysqlWriteGenerator struct{}
+
+func (g *mysqlWriteGenerator) UpsertStatement(table string, key string, cols []string, rows [][]string) string {
+	sets := updateAssignments(key, cols, "VALUES(%s)")
+	if len(sets) == 0 {
+		sets = []string{key + "=" + key}
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s) values %s ON DUPLICATE KEY UPDATE %s;", table, strings.Join(cols, ","), valuesList(rows), strings.Join(sets, ","))
+}
+
+func (g *mysqlWriteGenerator) CreateTableStatement(table string, key string, cols []Column) string {
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s (%s);", table, columnDefinitions(mysqlTypes, key, cols))
+}
+
+// mergeClauses generates the common part of the MERGE statement after the source
+func mergeClauses(key string, cols []string) string {
+	sourceCols := make([]string, len(cols))
+	for i, c := range cols {
+		sourceCols[i] = "source." + c
+	}
+	sqlStr := fmt.Sprintf("ON (target.%s = source.%s) ", key, key)
+	if sets := updateAssignments(key, cols, "source.%s"); len(sets) > 0 {
+		sqlStr += "WHEN MATCHED THEN UPDATE SET " + strings.Join(sets, ",") + " "
+	}
+	return sqlStr + fmt.Sprintf("WHEN NOT MATCHED THEN INSERT (%s) VALUES (%s)", strings.Join(cols, ","), strings.Join(sourceCols, ","))
+}
+
+type sqlServerWriteGenerator struct{}
+
+func (g *sqlServerWriteGenerator) UpsertStatement(table string, key string, cols []string, rows [][]string) string {
+	// sql server requires the MERGE statement to be terminated by semicolon
+	return fmt.Sprintf("MERGE INTO %s AS target USING (VALUES %s) AS source (%s) %s;", table, valuesList(rows), strings.Join(cols, ","), mergeClauses(key, cols))
+}
+
+func (g *sqlServerWriteGenerator) CreateTableStatement(table string, key string, cols []Column) string {
+	return fmt.Sprintf("IF OBJECT_ID(N'%s', N'U') IS NULL CREATE TABLE %s (%s);", table, table, columnDefinitions(sqlServerTypes, key, cols))
+}
+
+type oracleWriteGenerator struct{}
+
+func (g *oracleWriteGenerator) UpsertStatement(table string, key string, cols []string, rows [][]string) string {
+	selects := make([]string, len(rows))
+	for i, row := range rows {
+		fields := make([]string, len(row))
+		for j, v := range row {
+			fields[j] = v + " " + cols[j]
+		}
+		selects[i] = "SELECT " + strings.Join(fields, ",") + " FROM dual"
+	}
+	return fmt.Sprintf("MERGE INTO %s target USING (%s) source %s", table, strings.Join(selects, " UNION ALL "), mergeClauses(key, cols))
+}
+
+// CreateTableStatement ignores the error ORA-00955 which means the table already exists
+func (g *oracleWriteGenerator) CreateTableStatement(table string, key string, cols []Column) string {
+	ddl := fmt.Sprintf("CREATE TABLE %s (%s)", table, columnDefinitions(oracleTypes, key, cols))
+	return fmt.Sprintf("BEGIN EXECUTE IMMEDIATE '%s'; EXCEPTION WHEN OTHERS THEN IF SQLCODE != -955 THEN RAISE; END IF; END;", strings.ReplaceAll(ddl, "'", "''"))
+}
@@ -0,0 +1,112 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sqlgen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertStatement(t *testing.T) {
+	cols := []string{"id", "name", "score"}
+	rows := [][]string{{"1", "'John'", "80.5"}, {"2", "'Susan'", "NULL"}}
+	tests := []struct {
+		driver  string
+		exp     string
+		keyOnly string
+	}{
+		{
+			driver:  "sqlite3",
+			exp:     "INSERT INTO test (id,name,score) values (1,'John',80.5),(2,'Susan',NULL) ON CONFLICT (id) DO UPDATE SET name=excluded.name,score=excluded.score;",
+			keyOnly: "INSERT INTO test (id) values (1),(2) ON CONFLICT (id) DO NOTHING;",
+		},
+		{
+			driver:  "postgres",
+			exp:     "INSERT INTO test (id,name,score) values (1,'John',80.5),(2,'Susan',NULL) ON CONFLICT (id) DO UPDATE SET name=excluded.name,score=excluded.score;",
+			keyOnly: "INSERT INTO test (id) values (1),(2) ON CONFLICT (id) DO NOTHING;",
+		},
+		{
+			driver:  "mysql",
+			exp:     "INSERT INTO test (id,name,score) values (1,'John',80.5),(2,'Susan',NULL) ON DUPLICATE KEY UPDATE name=VALUES(name),score=VALUES(score);",
+			keyOnly: "INSERT INTO test (id) values (1),(2) ON DUPLICATE KEY UPDATE id=id;",
+		},
+		{
+			driver:  "sqlserver",
+			exp:     "MERGE INTO test AS target USING (VALUES (1,'John',80.5),(2,'Susan',NULL)) AS source (id,name,score) ON (target.id = source.id) WHEN MATCHED THEN UPDATE SET name=source.name,score=source.score WHEN NOT MATCHED THEN INSERT (id,name,score) VALUES (source.id,source.name,source.score);",
+			keyOnly: "MERGE INTO test AS target USING (VALUES (1),(2)) AS source (id) ON (target.id = source.id) WHEN NOT MATCHED THEN INSERT (id) VALUES (source.id);",
+		},
+		{
+			driver:  "oracle",
+			exp:     "MERGE INTO test target USING (SELECT 1 id,'John' name,80.5 score FROM dual UNION ALL SELECT 2 id,'Susan' name,NULL score FROM dual) source ON (target.id = source.id) WHEN MATCHED THEN UPDATE SET name=source.name,score=source.score WHEN NOT MATCHED THEN INSERT (id,name,score) VALUES (source.id,source.name,source.score)",
+			keyOnly: "MERGE INTO test target USING (SELECT 1 id FROM dual UNION ALL SELECT 2 id FROM dual) source ON (target.id = source.id) WHEN NOT MATCHED THEN INSERT (id) VALUES (source.id)",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			g, err := GetWriteGenerator(tt.driver)
+			require.NoError(t, err)
+			assert.Equal(t, tt.exp, g.UpsertStatement("test", "id", cols, rows))
+			assert.Equal(t, tt.keyOnly, g.UpsertStatement("test", "id", []string{"id"}, [][]string{{"1"}, {"2"}}))
+		})
+	}
+	_, err := GetWriteGenerator("clickhouse")
+	assert.EqualError(t, err, "upsert and create table are not supported for driver clickhouse")
+}
+
+func TestCreateTableStatement(t *testing.T) {
+	cols := []Column{
+		{Name: "id", Type: InferColumnType("a")},
+		{Name: "count", Type: InferColumnType(int64(1))},
+		{Name: "score", Type: InferColumnType(1.5)},
+		{Name: "valid", Type: InferColumnType(true)},
+		{Name: "info", Type: InferColumnType(map[string]interface{}{})},
+	}
+	tests := []struct {
+		driver string
+		exp    string
+	}{
+		{
+			driver: "sqlite",
+			exp:    "CREATE TABLE IF NOT EXISTS test (id TEXT,count INTEGER,score REAL,valid BOOLEAN,info TEXT,PRIMARY KEY (id));",
+		},
+		{
+			driver: "pgx",
+			exp:    "CREATE TABLE IF NOT EXISTS test (id TEXT,count BIGINT,score DOUBLE PRECISION,valid BOOLEAN,info TEXT,PRIMARY KEY (id));",
+		},
+		{
+			driver: "mysql",
+			exp:    "CREATE TABLE IF NOT EXISTS test (id VARCHAR(255),count BIGINT,score DOUBLE,valid BOOLEAN,info TEXT,PRIMARY KEY (id));",
+		},
+		{
+			driver: "sqlserver",
+			exp:    "IF OBJECT_ID(N'test', N'U') IS NULL CREATE TABLE test (id NVARCHAR(255),count BIGINT,score FLOAT,valid BIT,info NVARCHAR(MAX),PRIMARY KEY (id));",
+		},
+		{
+			driver: "godror",
+			exp:    "BEGIN EXECUTE IMMEDIATE 'CREATE TABLE test (id VARCHAR2(255),count NUMBER(19),score BINARY_DOUBLE,valid NUMBER(1),info VARCHAR2(4000),PRIMARY KEY (id))'; EXCEPTION WHEN OTHERS THEN IF SQLCODE != -955 THEN RAISE; END IF; END;",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driver, func(t *testing.T) {
+			g, err := GetWriteGenerator(tt.driver)
+			require.NoError(t, err)
+			assert.Equal(t, tt.exp, g.CreateTableStatement("test", "id", cols))
+		})
+	}
+	g, _ := GetWriteGenerator("sqlite")
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS test (id INTEGER);", g.CreateTableStatement("test", "", []Column{{Name: "id", Type: INT_COLUMN}}))
+}
@@ -15,10 +15,12 @@
 package function
 
 import (
+	"encoding/gob"
 	"fmt"
 	"reflect"
 	"strconv"
 
+	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/ast"
 	"github.com/lf-edge/ekuiper/pkg/cast"
@@ -237,4 +239,213 @@ func registerAnalyticFunc() {
 			return nil
 		},
 	}
+	for _, name := range []string{"acc_sum", "acc_avg", "acc_count", "acc_min", "acc_max"} {
+		builtins[name] = accFunc(name)
+	}
+	builtins["moving_avg"] = builtinFunc{
+		fType: ast.FuncTypeScalar,
+		exec: func(ctx api.FunctionContext, args []interface{}) (interface{}, bool) {
+			if len(args) != 5 {
+				return fmt.Errorf("expect two args but got %d", len(args)-3), false
+			}
+			key := args[len(args)-1].(string)
+			validData, ok := args[len(args)-2].(bool)
+			if !ok {
+				return fmt.Errorf("when arg is not a bool but got %v", args[len(args)-2]), false
+			}
+			size, err := cast.ToInt64(args[1], cast.CONVERT_SAMEKIND)
+			if err != nil || size <= 0 {
+				return fmt.Errorf("the range %v of moving_avg must be a positive integer", args[1]), false
+			}
+			isTime, _ := args[2].(bool)
+			v, err := ctx.GetState(key)
+			if err != nil {
+				return fmt.Errorf("error getting state for %s: %v", key, err), false
+			}
+			ms, _ := v.(*MovingState)
+			if ms == nil {
+				ms = &MovingState{}
+			}
+			now := conf.GetNowInMilli()
+			if validData && args[0] != nil {
+				f, err := cast.ToFloat64(args[0], cast.CONVERT_SAMEKIND)
+				if err != nil {
+					return fmt.Errorf("requires number but found %[1]T(%[1]v)", args[0]), false
+				}
+				ms.Values = append(ms.Values, f)
+				ms.Times = append(ms.Times, now)
+			}
+			// Evict the values out of the range
+			start := 0
+			if isTime {
+				for start < len(ms.Times) && ms.Times[start] <= now-size {
+					start++
+				}
+			} else if int64(len(ms.Values)) > size {
+				start = len(ms.Values) - int(size)
+			}
+			ms.Values, ms.Times = ms.Values[start:], ms.Times[start:]
+			if err := ctx.PutState(key, ms); err != nil {
+				return fmt.Errorf("error setting state for %s: %v", key, err), false
+			}
+			if len(ms.Values) == 0 {
+				return nil, true
+			}
+			var total float64
+			for _, f := range ms.Values {
+				total += f
+			}
+			return total / float64(len(ms.Values)), true
+		},
+		val: func(_ api.FunctionContext, args []ast.Expr) error {
+			if err := ValidateLen(2, len(args)); err != nil {
+				return err
+			}
+			if ast.IsStringArg(args[0]) || ast.IsTimeArg(args[0]) || ast.IsBooleanArg(args[0]) {
+				return ProduceErrInfo(0, "number - float or int")
+			}
+			switch r := args[1].(type) {
+			case *ast.IntegerLiteral:
+				if r.Val <= 0 {
+					return fmt.Errorf("the rows of moving_avg must be a positive integer")
+				}
+			case *ast.IntervalLiteral:
+				if r.Val <= 0 {
+					return fmt.Errorf("the time range of moving_avg must be positive")
+				}
+			default:
+				return fmt.Errorf("the second arg of moving_avg must be like 10 ROWS or 5 MINUTES")
+			}
+			return nil
+		},
+	}
+}
+
+func init() {
+	gob.Register(&AccState{})
+	gob.Register(&MovingState{})
+}
+
+// AccState is the state of the accumulating functions since the last reset
+type AccState struct {
+	Count    int64
+	IntSum   int64
+	FloatSum float64
+	IsFloat  bool
+	Min      interface{}
+	Max      interface{}
+}
+
+func (s *AccState) add(v interface{}) error {
+	f, err := cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return fmt.Errorf("requires number but found %[1]T(%[1]v)", v)
+	}
+	switch vt := v.(type) {
+	case int:
+		s.IntSum += int64(vt)
+	case int64:
+		s.IntSum += vt
+	default:
+		s.IsFloat = true
+	}
+	s.FloatSum += f
+	if s.Count == 0 {
+		s.Min, s.Max = v, v
+	} else {
+		if m, _ := cast.ToFloat64(s.Min, cast.CONVERT_SAMEKIND); f < m {
+			s.Min = v
+		}
+		if m, _ := cast.ToFloat64(s.Max, cast.CONVERT_SAMEKIND); f > m {
+			s.Max = v
+		}
+	}
+	s.Count++
+	return nil
+}
+
+func (s *AccState) result(name string) interface{} {
+	if name == "acc_count" {
+		return int(s.Count)
+	}
+	if s.Count == 0 {
+		return nil
+	}
+	switch name {
+	case "acc_sum":
+		if s.IsFloat {
+			return s.FloatSum
+		}
+		return s.IntSum
+	case "acc_avg":
+		return s.FloatSum / float64(s.Count)
+	case "acc_min":
+		return s.Min
+	default:
+		return s.Max
+	}
+}
+
+// accFunc accumulates the values since the start or the last reset. The optional second arg is the reset condition.
+// If it is true, the accumulation restarts from the current row.
+func accFunc(name string) builtinFunc {
+	return builtinFunc{
+		fType: ast.FuncTypeScalar,
+		exec: func(ctx api.FunctionContext, args []interface{}) (interface{}, bool) {
+			l := len(args) - 2
+			if l != 1 && l != 2 {
+				return fmt.Errorf("expect one or two args but got %d", l), false
+			}
+			key := args[len(args)-1].(string)
+			validData, ok := args[len(args)-2].(bool)
+			if !ok {
+				return fmt.Errorf("when arg is not a bool but got %v", args[len(args)-2]), false
+			}
+			v, err := ctx.GetState(key)
+			if err != nil {
+				return fmt.Errorf("error getting state for %s: %v", key, err), false
+			}
+			s, _ := v.(*AccState)
+			if s == nil {
+				s = &AccState{}
+			}
+			if validData {
+				if l == 2 {
+					if reset, ok := args[1].(bool); ok && reset {
+						s = &AccState{}
+					}
+				}
+				if args[0] != nil {
+					if name == "acc_count" {
+						s.Count++
+					} else if err := s.add(args[0]); err != nil {
+						return err, false
+					}
+				}
+				if err := ctx.PutState(key, s); err != nil {
+					return fmt.Errorf("error setting state for %s: %v", key, err), false
+				}
+			}
+			return s.result(name), true
+		},
+		val: func(_ api.FunctionContext, args []ast.Expr) error {
+			l := len(args)
+			if l != 1 && l != 2 {
+				return fmt.Errorf("expect one or two args but got %d", l)
+			}
+			if name != "acc_count" && (ast.IsStringArg(args[0]) || ast.IsTimeArg(args[0]) || ast.IsBooleanArg(args[0])) {
+				return ProduceErrInfo(0, "number - float or int")
+			}
+			if l == 2 && (ast.IsNumericArg(args[1]) || ast.IsTimeArg(args[1]) || ast.IsStringArg(args[1])) {
+				return ProduceErrInfo(1, "bool")
+			}
+			return nil
+		},
+	}
+}
+
+// MovingState is the values and their arrival time in the range of moving_avg
+type MovingState struct {
+	Values []float64
+	Times  []int64
 }
@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -18,6 +18,9 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	kctx "github.com/lf-edge/ekuiper/internal/topo/context"
@@ -1349,3 +1352,184 @@ func TestLatestPartition(t *testing.T) {
 		}
 	}
 }
+
+func TestAccValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		args []ast.Expr
+		err  error
+	}{
+		{
+			name: "acc_sum",
+			args: []ast.Expr{},
+			err:  fmt.Errorf("expect one or two args but got 0"),
+		}, {
+			name: "acc_sum",
+			args: []ast.Expr{
+				&ast.StringLiteral{Val: "foo"},
+			},
+			err: fmt.Errorf("Expect number - float or int type for parameter 1"),
+		}, {
+			name: "acc_count",
+			args: []ast.Expr{
+				&ast.StringLiteral{Val: "foo"},
+			},
+		}, {
+			name: "acc_max",
+			args: []ast.Expr{
+				&ast.FieldRef{Name: "foo"},
+				&ast.IntegerLiteral{Val: 1},
+			},
+			err: fmt.Errorf("Expect bool type for parameter 2"),
+		}, {
+			name: "acc_avg",
+			args: []ast.Expr{
+				&ast.FieldRef{Name: "foo"},
+				&ast.BooleanLiteral{Val: true},
+				&ast.BooleanLiteral{Val: true},
+			},
+			err: fmt.Errorf("expect one or two args but got 3"),
+		}, {
+			name: "moving_avg",
+			args: []ast.Expr{
+				&ast.FieldRef{Name: "foo"},
+				&ast.FieldRef{Name: "bar"},
+			},
+			err: fmt.Errorf("the second arg of moving_avg must be like 10 ROWS or 5 MINUTES"),
+		}, {
+			name: "moving_avg",
+			args: []ast.Expr{
+				&ast.FieldRef{Name: "foo"},
+				&ast.IntegerLiteral{Val: 0},
+			},
+			err: fmt.Errorf("the rows of moving_avg must be a positive integer"),
+		}, {
+			name: "moving_avg",
+			args: []ast.Expr{
+				&ast.FieldRef{Name: "foo"},
+				&ast.IntervalLiteral{Val: 5, Unit: ast.MI},
+			},
+		},
+	}
+	for i, tt := range tests {
+		f, ok := builtins[tt.name]
+		if !ok {
+			t.Fatalf("builtin %s not found", tt.name)
+		}
+		err := f.val(nil, tt.args)
+		if !reflect.DeepEqual(err, tt.err) {
+			t.Errorf("%d result mismatch,\ngot:\t%v \nwant:\t%v", i, err, tt.err)
+		}
+	}
+}
+
+func TestAccExec(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "testExec")
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger)
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	fctx := kctx.NewDefaultFuncContext(ctx.WithMeta("mockRule0", "test", tempStore), 2)
+	// The args are the value, the reset condition, the when condition and the partition key
+	inputs := [][]interface{}{
+		{1, false, true, "self"},
+		{nil, false, true, "self"},
+		{3.5, false, true, "self"},
+		{10, false, false, "self"},
+		{2, true, true, "self"},
+		{4, false, true, "other"},
+	}
+	tests := []struct {
+		name    string
+		results []interface{}
+	}{
+		{
+			name:    "acc_sum",
+			results: []interface{}{int64(1), int64(1), 4.5, 4.5, int64(2), int64(4)},
+		}, {
+			name:    "acc_avg",
+			results: []interface{}{1.0, 1.0, 2.25, 2.25, 2.0, 4.0},
+		}, {
+			name:    "acc_count",
+			results: []interface{}{1, 1, 2, 2, 1, 1},
+		}, {
+			name:    "acc_min",
+			results: []interface{}{1, 1, 1, 1, 2, 4},
+		}, {
+			name:    "acc_max",
+			results: []interface{}{1, 1, 3.5, 3.5, 2, 4},
+		},
+	}
+	for _, tt := range tests {
+		f, ok := builtins[tt.name]
+		if !ok {
+			t.Fatalf("builtin %s not found", tt.name)
+		}
+		for i, args := range inputs {
+			result, _ := f.exec(fctx, args)
+			if !reflect.DeepEqual(result, tt.results[i]) {
+				t.Errorf("%s %d result mismatch,\ngot:\t%v \nwant:\t%v", tt.name, i, result, tt.results[i])
+			}
+		}
+		fctx = kctx.NewDefaultFuncContext(ctx.WithMeta("mockRule0", "test", tempStore), fctx.GetFuncId()+1)
+	}
+}
+
+func TestMovingAvgExec(t *testing.T) {
+	f, ok := builtins["moving_avg"]
+	if !ok {
+		t.Fatal("builtin not found")
+	}
+	conf.IsTesting = true
+	conf.InitClock()
+	mc := conf.Clock.(*clock.Mock)
+	contextLogger := conf.Log.WithField("rule", "testExec")
+	ctx := kctx.WithValue(kctx.Background(), kctx.LoggerKey, contextLogger)
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	fctx := kctx.NewDefaultFuncContext(ctx.WithMeta("mockRule0", "test", tempStore), 2)
+	tests := []struct {
+		args    []interface{}
+		advance time.Duration
+		result  interface{}
+	}{
+		{ // 1
+			args:   []interface{}{1, int64(2), false, true, "self"},
+			result: 1.0,
+		}, { // 2
+			args:   []interface{}{nil, int64(2), false, true, "self"},
+			result: 1.0,
+		}, { // 3
+			args:   []interface{}{2, int64(2), false, true, "self"},
+			result: 1.5,
+		}, { // 4
+			args:   []interface{}{6, int64(2), false, false, "self"},
+			result: 1.5,
+		}, { // 5
+			args:   []interface{}{6, int64(2), false, true, "self"},
+			result: 4.0,
+		}, { // 6
+			args:   []interface{}{10, int64(2), false, true, "other"},
+			result: 10.0,
+		}, { // 7 time based
+			args:   []interface{}{1, int64(1000), true, true, "time"},
+			result: 1.0,
+		}, { // 8
+			args:    []interface{}{3, int64(1000), true, true, "time"},
+			advance: 500 * time.Millisecond,
+			result:  2.0,
+		}, { // 9
+			args:    []interface{}{8, int64(1000), true, true, "time"},
+			advance: 600 * time.Millisecond,
+			result:  5.5,
+		}, { // 10
+			args:    []interface{}{nil, int64(1000), true, true, "time"},
+			advance: 1000 * time.Millisecond,
+			result:  nil,
+		},
+	}
+	for i, tt := range tests {
+		mc.Add(tt.advance)
+		result, _ := f.exec(fctx, tt.args)
+		if !reflect.DeepEqual(result, tt.result) {
+			t.Errorf("%d result mismatch,\ngot:\t%v \nwant:\t%v", i, result, tt.result)
+		}
+	}
+}
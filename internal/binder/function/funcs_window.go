@@ -0,0 +1,136 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package function
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// windowFunc calculates the results for all rows of an ordered partition of the window at once.
+// The args are the evaluated arguments of each row. The peers marks whether the row has the same
+// order by values as its previous row.
+type windowFunc func(args [][]interface{}, peers []bool) ([]interface{}, error)
+
+var windowFuncs = map[string]windowFunc{
+	"row_number": func(args [][]interface{}, _ []bool) ([]interface{}, error) {
+		r := make([]interface{}, len(args))
+		for i := range args {
+			r[i] = i + 1
+		}
+		return r, nil
+	},
+	"rank": func(args [][]interface{}, peers []bool) ([]interface{}, error) {
+		r := make([]interface{}, len(args))
+		rank := 0
+		for i := range args {
+			if !peers[i] {
+				rank = i + 1
+			}
+			r[i] = rank
+		}
+		return r, nil
+	},
+	"dense_rank": func(args [][]interface{}, peers []bool) ([]interface{}, error) {
+		r := make([]interface{}, len(args))
+		rank := 0
+		for i := range args {
+			if !peers[i] {
+				rank++
+			}
+			r[i] = rank
+		}
+		return r, nil
+	},
+	"first_value": func(args [][]interface{}, _ []bool) ([]interface{}, error) {
+		return nthValue(args, 0), nil
+	},
+	"last_value": func(args [][]interface{}, _ []bool) ([]interface{}, error) {
+		return nthValue(args, len(args)-1), nil
+	},
+	"nth_value": func(args [][]interface{}, _ []bool) ([]interface{}, error) {
+		if len(args) == 0 {
+			return nil, nil
+		}
+		n, err := cast.ToInt(args[0][1], cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return nil, fmt.Errorf("the second arg of nth_value must be an integer but got %v", args[0][1])
+		}
+		return nthValue(args, n-1), nil
+	},
+}
+
+// nthValue returns the first arg of the row at index for all rows. If the index is out of range, the results are nil.
+func nthValue(args [][]interface{}, index int) []interface{} {
+	r := make([]interface{}, len(args))
+	if index >= 0 && index < len(args) {
+		for i := range r {
+			r[i] = args[index][0]
+		}
+	}
+	return r
+}
+
+const WindowFuncPrefix = "$$w"
+
+func IsWindowFunc(name string) bool {
+	_, ok := windowFuncs[name]
+	return ok
+}
+
+// ExecWindowFunc calculates the window function over an ordered partition
+func ExecWindowFunc(name string, args [][]interface{}, peers []bool) ([]interface{}, error) {
+	f, ok := windowFuncs[name]
+	if !ok {
+		return nil, fmt.Errorf("window function %s not found", name)
+	}
+	return f(args, peers)
+}
+
+func registerWindowFunc() {
+	windowExec := func(_ api.FunctionContext, _ []interface{}) (interface{}, bool) {
+		return fmt.Errorf("window function can only be calculated over a window"), false
+	}
+	for _, name := range []string{"row_number", "rank", "dense_rank"} {
+		builtins[name] = builtinFunc{
+			fType: ast.FuncTypeScalar,
+			exec:  windowExec,
+			val:   ValidateNoArg,
+		}
+	}
+	for _, name := range []string{"first_value", "last_value"} {
+		builtins[name] = builtinFunc{
+			fType: ast.FuncTypeScalar,
+			exec:  windowExec,
+			val:   ValidateOneArg,
+		}
+	}
+	builtins["nth_value"] = builtinFunc{
+		fType: ast.FuncTypeScalar,
+		exec:  windowExec,
+		val: func(_ api.FunctionContext, args []ast.Expr) error {
+			if err := ValidateLen(2, len(args)); err != nil {
+				return err
+			}
+			if n, ok := args[1].(*ast.IntegerLiteral); !ok || n.Val <= 0 {
+				return fmt.Errorf("the second arg of nth_value must be a positive integer literal")
+			}
+			return nil
+		},
+	}
+}
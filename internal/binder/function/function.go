@@ -52,6 +52,7 @@ func init() {
 	registerSetReturningFunc()
 	registerArrayFunc()
 	registerObjectFunc()
+	registerWindowFunc()
 }
 
 //var funcWithAsteriskSupportMap = map[string]string{
@@ -64,6 +65,12 @@ var analyticFuncs = map[string]struct{}{
 	"changed_col": {},
 	"had_changed": {},
 	"latest":      {},
+	"acc_sum":     {},
+	"acc_avg":     {},
+	"acc_count":   {},
+	"acc_min":     {},
+	"acc_max":     {},
+	"moving_avg":  {},
 }
 
 const AnalyticPrefix = "$$a"
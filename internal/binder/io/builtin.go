@@ -22,6 +22,7 @@ import (
 	"github.com/lf-edge/ekuiper/internal/io/neuron"
 	"github.com/lf-edge/ekuiper/internal/io/sink"
 	"github.com/lf-edge/ekuiper/internal/io/video"
+	"github.com/lf-edge/ekuiper/internal/io/websocket"
 	plugin2 "github.com/lf-edge/ekuiper/internal/plugin"
 	"github.com/lf-edge/ekuiper/pkg/api"
 )
@@ -34,23 +35,26 @@ type (
 
 var (
 	sources = map[string]NewSourceFunc{
-		"mqtt":     func() api.Source { return &mqtt.MQTTSource{} },
-		"httppull": func() api.Source { return &http.PullSource{} },
-		"httppush": func() api.Source { return &http.PushSource{} },
-		"file":     func() api.Source { return &file.FileSource{} },
-		"memory":   func() api.Source { return memory.GetSource() },
-		"neuron":   func() api.Source { return neuron.GetSource() },
-		"video":    func() api.Source { return &video.VideoPullSource{} },
+		"mqtt":      func() api.Source { return &mqtt.MQTTSource{} },
+		"httppull":  func() api.Source { return &http.PullSource{} },
+		"httppush":  func() api.Source { return &http.PushSource{} },
+		"file":      func() api.Source { return &file.FileSource{} },
+		"memory":    func() api.Source { return memory.GetSource() },
+		"neuron":    func() api.Source { return neuron.GetSource() },
+		"video":     func() api.Source { return &video.VideoPullSource{} },
+		"websocket": func() api.Source { return &websocket.WebsocketSource{} },
 	}
 	sinks = map[string]NewSinkFunc{
 		"log":         sink.NewLogSink,
 		"logToMemory": sink.NewLogSinkToMemory,
 		"mqtt":        func() api.Sink { return &mqtt.MQTTSink{} },
 		"rest":        func() api.Sink { return &http.RestSink{} },
+		"httpreply":   func() api.Sink { return &http.ReplySink{} },
 		"nop":         func() api.Sink { return &sink.NopSink{} },
 		"memory":      func() api.Sink { return memory.GetSink() },
 		"neuron":      func() api.Sink { return neuron.GetSink() },
 		"file":        func() api.Sink { return file.File() },
+		"websocket":   func() api.Sink { return &websocket.WebsocketSink{} },
 	}
 	lookupSources = map[string]NewLookupSourceFunc{
 		"memory": func() api.LookupSource { return memory.GetLookupSource() },
@@ -38,6 +38,8 @@ var (
 type tlsConf struct {
 	Certfile string `yaml:"certfile"`
 	Keyfile  string `yaml:"keyfile"`
+	// Cafile is the CA to verify the client certificates. Only used by the http data server
+	Cafile string `yaml:"cafile"`
 }
 
 type SinkConf struct {
@@ -114,28 +116,100 @@ func (sc *SourceConf) Validate() error {
 	return errs
 }
 
+// PortableLimits is the resource quota of a portable plugin process.
+// The zero value of each field means no limit.
+type PortableLimits struct {
+	// Memory is the max memory in MB
+	Memory int64 `json:"memory,omitempty" yaml:"memory"`
+	// Cpu is the cpu quota in number of cores, e.g. 0.5 means half of a core. Only supported by cgroup v2
+	Cpu          float64 `json:"cpu,omitempty" yaml:"cpu"`
+	MaxOpenFiles uint64  `json:"maxOpenFiles,omitempty" yaml:"maxOpenFiles"`
+	Nice         int     `json:"nice,omitempty" yaml:"nice"`
+}
+
+// Validate the limits and reset to no limit for invalid values.
+func (pl *PortableLimits) Validate() error {
+	var errs error
+	if pl.Memory < 0 {
+		pl.Memory = 0
+		Log.Warnf("portable memory limit is negative, set to 0")
+		errs = errors.Join(errs, errors.New("invalidMemory:memory must be positive"))
+	}
+	if pl.Cpu < 0 {
+		pl.Cpu = 0
+		Log.Warnf("portable cpu limit is negative, set to 0")
+		errs = errors.Join(errs, errors.New("invalidCpu:cpu must be positive"))
+	}
+	if pl.Nice < -20 || pl.Nice > 19 {
+		pl.Nice = 0
+		Log.Warnf("portable nice must between -20 and 19, set to 0")
+		errs = errors.Join(errs, errors.New("invalidNice:nice must between -20 and 19"))
+	}
+	return errs
+}
+
+// IsEmpty returns true if no limit is set
+func (pl *PortableLimits) IsEmpty() bool {
+	return pl == nil || (pl.Memory == 0 && pl.Cpu == 0 && pl.MaxOpenFiles == 0 && pl.Nice == 0)
+}
+
+// Merge returns a new limits whose unset fields are filled by the default limits
+func (pl *PortableLimits) Merge(defaults *PortableLimits) *PortableLimits {
+	r := &PortableLimits{}
+	if defaults != nil {
+		*r = *defaults
+	}
+	if pl == nil {
+		return r
+	}
+	if pl.Memory != 0 {
+		r.Memory = pl.Memory
+	}
+	if pl.Cpu != 0 {
+		r.Cpu = pl.Cpu
+	}
+	if pl.MaxOpenFiles != 0 {
+		r.MaxOpenFiles = pl.MaxOpenFiles
+	}
+	if pl.Nice != 0 {
+		r.Nice = pl.Nice
+	}
+	return r
+}
+
 type SQLConf struct {
 	MaxConnections int `yaml:"maxConnections"`
 }
 
+// PluginRepoConf is the local plugin repository for offline installation
+type PluginRepoConf struct {
+	// Location is the directory or file url of the repository index file
+	Location string `yaml:"location"`
+	// TrustedKeys is the directory of the trusted ed25519 public keys in PEM format
+	TrustedKeys string `yaml:"trustedKeys"`
+	// AllowUnsigned allows installing packages without signature. The checksum is still verified.
+	AllowUnsigned bool `yaml:"allowUnsigned"`
+}
+
 type KuiperConf struct {
 	Basic struct {
-		Debug          bool     `yaml:"debug"`
-		ConsoleLog     bool     `yaml:"consoleLog"`
-		FileLog        bool     `yaml:"fileLog"`
-		RotateTime     int      `yaml:"rotateTime"`
-		MaxAge         int      `yaml:"maxAge"`
-		Ip             string   `yaml:"ip"`
-		Port           int      `yaml:"port"`
-		RestIp         string   `yaml:"restIp"`
-		RestPort       int      `yaml:"restPort"`
-		RestTls        *tlsConf `yaml:"restTls"`
-		Prometheus     bool     `yaml:"prometheus"`
-		PrometheusPort int      `yaml:"prometheusPort"`
-		PluginHosts    string   `yaml:"pluginHosts"`
-		Authentication bool     `yaml:"authentication"`
-		IgnoreCase     bool     `yaml:"ignoreCase"`
-		SQLConf        *SQLConf `yaml:"sql"`
+		Debug            bool            `yaml:"debug"`
+		ConsoleLog       bool            `yaml:"consoleLog"`
+		FileLog          bool            `yaml:"fileLog"`
+		RotateTime       int             `yaml:"rotateTime"`
+		MaxAge           int             `yaml:"maxAge"`
+		Ip               string          `yaml:"ip"`
+		Port             int             `yaml:"port"`
+		RestIp           string          `yaml:"restIp"`
+		RestPort         int             `yaml:"restPort"`
+		RestTls          *tlsConf        `yaml:"restTls"`
+		Prometheus       bool            `yaml:"prometheus"`
+		PrometheusPort   int             `yaml:"prometheusPort"`
+		PluginHosts      string          `yaml:"pluginHosts"`
+		PluginRepository *PluginRepoConf `yaml:"pluginRepository"`
+		Authentication   bool            `yaml:"authentication"`
+		IgnoreCase       bool            `yaml:"ignoreCase"`
+		SQLConf          *SQLConf        `yaml:"sql"`
 	}
 	Rule   api.RuleOption
 	Sink   *SinkConf
@@ -155,8 +229,10 @@ type KuiperConf struct {
 		}
 	}
 	Portable struct {
-		PythonBin   string `yaml:"pythonBin"`
-		InitTimeout int    `yaml:"initTimeout"`
+		PythonBin   string          `yaml:"pythonBin"`
+		InitTimeout int             `yaml:"initTimeout"`
+		Limits      *PortableLimits `yaml:"limits"`
+		CgroupRoot  string          `yaml:"cgroupRoot"`
 	}
 }
 
@@ -241,6 +317,10 @@ func InitConf() {
 	if Config.Portable.InitTimeout <= 0 {
 		Config.Portable.InitTimeout = 5000
 	}
+	if Config.Portable.Limits == nil {
+		Config.Portable.Limits = &PortableLimits{}
+	}
+	_ = Config.Portable.Limits.Validate()
 	if Config.Source == nil {
 		Config.Source = &SourceConf{}
 	}
@@ -302,6 +382,30 @@ func ValidateRuleOption(option *api.RuleOption) error {
 			errs = errors.Join(errs, errors.New("invalidRestartJitterFactor:restart jitterFactor must between [0, 1)"))
 		}
 	}
+	if option.ColumnarBatchSize < 0 {
+		option.ColumnarBatchSize = 0
+		Log.Warnf("columnarBatchSize is negative, set to 0")
+		errs = errors.Join(errs, errors.New("invalidColumnarBatchSize:columnarBatchSize must not be negative"))
+	}
+	if option.ColumnarLinger < 0 {
+		option.ColumnarLinger = 10
+		Log.Warnf("columnarLinger is negative, set to 10")
+		errs = errors.Join(errs, errors.New("invalidColumnarLinger:columnarLinger must be greater than 0"))
+	}
+	if option.DedupMaxKeys < 0 {
+		errs = errors.Join(errs, errors.New("invalidDedupMaxKeys:dedupMaxKeys must not be negative"))
+	}
+	if option.DedupFalsePositiveRate < 0 || option.DedupFalsePositiveRate >= 1 {
+		errs = errors.Join(errs, errors.New("invalidDedupFalsePositiveRate:dedupFalsePositiveRate must between [0, 1)"))
+	}
+	if option.DeadLetter != nil {
+		if len(option.DeadLetter.Action) != 1 {
+			errs = errors.Join(errs, errors.New("invalidDeadLetterAction:deadLetter must have exactly one action"))
+		}
+		if option.DeadLetter.RateLimit < 0 {
+			errs = errors.Join(errs, errors.New("invalidDeadLetterRateLimit:deadLetter rateLimit must not be negative"))
+		}
+	}
 	return errs
 }
 
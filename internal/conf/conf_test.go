@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -213,11 +213,60 @@ func TestRuleOptionValidate(t *testing.T) {
 			},
 			err: "multiple errors",
 		},
+		{
+			s: &api.RuleOption{
+				LateTol:           1000,
+				Concurrency:       1,
+				BufferLength:      1024,
+				ColumnarBatchSize: -1,
+				ColumnarLinger:    -5,
+			},
+			e: &api.RuleOption{
+				LateTol:           1000,
+				Concurrency:       1,
+				BufferLength:      1024,
+				ColumnarBatchSize: 0,
+				ColumnarLinger:    10,
+			},
+			err: "multiple errors",
+		},
+		{
+			s: &api.RuleOption{
+				LateTol:      1000,
+				Concurrency:  1,
+				BufferLength: 1024,
+				DeadLetter:   &api.DeadLetterOption{Action: map[string]interface{}{"log": map[string]interface{}{}}, RateLimit: -1},
+			},
+			e: &api.RuleOption{
+				LateTol:      1000,
+				Concurrency:  1,
+				BufferLength: 1024,
+				DeadLetter:   &api.DeadLetterOption{Action: map[string]interface{}{"log": map[string]interface{}{}}, RateLimit: -1},
+			},
+			err: "invalidDeadLetterRateLimit:deadLetter rateLimit must not be negative",
+		},
+		{
+			s: &api.RuleOption{
+				LateTol:                1000,
+				Concurrency:            1,
+				BufferLength:           1024,
+				DedupMaxKeys:           -1,
+				DedupFalsePositiveRate: 1.5,
+			},
+			e: &api.RuleOption{
+				LateTol:                1000,
+				Concurrency:            1,
+				BufferLength:           1024,
+				DedupMaxKeys:           -1,
+				DedupFalsePositiveRate: 1.5,
+			},
+			err: "multiple errors",
+		},
 	}
 	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
 	for i, tt := range tests {
 		err := ValidateRuleOption(tt.s)
-		if err != nil && tt.err == "" {
+		if (err != nil && tt.err == "") || (err == nil && tt.err != "") {
 			t.Errorf("%d: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.err, err)
 		}
 		if !reflect.DeepEqual(tt.s, tt.e) {
@@ -225,3 +274,90 @@ func TestRuleOptionValidate(t *testing.T) {
 		}
 	}
 }
+
+func TestPortableLimitsValidate(t *testing.T) {
+	tests := []struct {
+		s   *PortableLimits
+		e   *PortableLimits
+		err string
+	}{
+		{
+			s: &PortableLimits{},
+			e: &PortableLimits{},
+		}, {
+			s: &PortableLimits{
+				Memory:       128,
+				Cpu:          0.5,
+				MaxOpenFiles: 1024,
+				Nice:         10,
+			},
+			e: &PortableLimits{
+				Memory:       128,
+				Cpu:          0.5,
+				MaxOpenFiles: 1024,
+				Nice:         10,
+			},
+		}, {
+			s: &PortableLimits{
+				Memory: -1,
+				Nice:   5,
+			},
+			e: &PortableLimits{
+				Nice: 5,
+			},
+			err: "invalidMemory:memory must be positive",
+		}, {
+			s: &PortableLimits{
+				Cpu:  -2,
+				Nice: 20,
+			},
+			e:   &PortableLimits{},
+			err: "invalidCpu:cpu must be positive\ninvalidNice:nice must between -20 and 19",
+		},
+	}
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	for i, tt := range tests {
+		err := tt.s.Validate()
+		if (err == nil && tt.err != "") || (err != nil && tt.err != err.Error()) {
+			t.Errorf("%d: error mismatch:\n  exp=%s\n  got=%v\n\n", i, tt.err, err)
+		}
+		if !reflect.DeepEqual(tt.s, tt.e) {
+			t.Errorf("%d\n\nstmt mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.e, tt.s)
+		}
+	}
+}
+
+func TestPortableLimitsMerge(t *testing.T) {
+	defaults := &PortableLimits{
+		Memory:       256,
+		MaxOpenFiles: 1024,
+	}
+	tests := []struct {
+		l *PortableLimits
+		d *PortableLimits
+		e *PortableLimits
+	}{
+		{
+			l: nil,
+			d: nil,
+			e: &PortableLimits{},
+		}, {
+			l: nil,
+			d: defaults,
+			e: &PortableLimits{Memory: 256, MaxOpenFiles: 1024},
+		}, {
+			l: &PortableLimits{Memory: 64, Cpu: 0.5, Nice: 10},
+			d: defaults,
+			e: &PortableLimits{Memory: 64, Cpu: 0.5, MaxOpenFiles: 1024, Nice: 10},
+		},
+	}
+	for i, tt := range tests {
+		r := tt.l.Merge(tt.d)
+		if !reflect.DeepEqual(tt.e, r) {
+			t.Errorf("%d\n\nmerge mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.e, r)
+		}
+	}
+	if !reflect.DeepEqual(&PortableLimits{Memory: 256, MaxOpenFiles: 1024}, defaults) {
+		t.Errorf("defaults should not be changed, got %#v", defaults)
+	}
+}
@@ -140,6 +140,7 @@ func (es *EdgexSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTup
 							l = 200
 						}
 						log.Warnf("payload %s unmarshal fail: %v", env.Payload[0:(l-1)], err)
+						es.sendError(ctx, consumer, fmt.Errorf("payload unmarshal fail: %v", err), env.Payload)
 						break
 					}
 				} else if strings.EqualFold(env.ContentType, "application/cbor") {
@@ -149,10 +150,12 @@ func (es *EdgexSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTup
 							l = 200
 						}
 						log.Warnf("payload %s unmarshal fail: %v", env.Payload[0:(l-1)], err)
+						es.sendError(ctx, consumer, fmt.Errorf("payload unmarshal fail: %v", err), env.Payload)
 						break
 					}
 				} else {
 					log.Errorf("Unsupported data type %s.", env.ContentType)
+					es.sendError(ctx, consumer, fmt.Errorf("unsupported data type %s", env.ContentType), env.Payload)
 					break
 				}
 
@@ -218,6 +221,14 @@ func (es *EdgexSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTup
 	}
 }
 
+// sendError sends the payload failing to decode with the error so that it can be routed to the dead letter
+func (es *EdgexSource) sendError(ctx api.StreamContext, consumer chan<- api.SourceTuple, err error, payload []byte) {
+	select {
+	case consumer <- &xsql.ErrorSourceTuple{Error: err, Payload: payload}:
+	case <-ctx.Done():
+	}
+}
+
 func (es *EdgexSource) getValue(r dtos.BaseReading, logger api.Logger) (interface{}, error) {
 	t := r.ValueType
 	logger.Debugf("name %s with type %s", r.ResourceName, r.ValueType)
@@ -16,7 +16,6 @@ package file
 
 import (
 	"bufio"
-	"encoding/csv"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -50,6 +49,8 @@ type FileSourceConfig struct {
 	IgnoreEndLines   int      `json:"ignoreEndLines"`
 	Delimiter        string   `json:"delimiter"`
 	Decompression    string   `json:"decompression"`
+	// Tail follows the appended lines of the file instead of reading it as a whole
+	Tail bool `json:"tail"`
 }
 
 // FileSource The BATCH to load data from file at once
@@ -57,6 +58,9 @@ type FileSource struct {
 	file   string
 	isDir  bool
 	config *FileSourceConfig
+	// the position of the next line to read in tail mode
+	offsetLock sync.Mutex
+	pos        tailPos
 }
 
 func (fs *FileSource) Close(ctx api.StreamContext) error {
@@ -137,12 +141,21 @@ func (fs *FileSource) Configure(fileName string, props map[string]interface{}) e
 	if _, ok := compressionTypes[cfg.Decompression]; !ok && cfg.Decompression != "" {
 		return fmt.Errorf("decompression must be one of gzip, zstd")
 	}
+	if cfg.Tail {
+		if err := validateTail(cfg, fs.isDir); err != nil {
+			return err
+		}
+	}
 
 	fs.config = cfg
 	return nil
 }
 
 func (fs *FileSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	if fs.config.Tail {
+		fs.tail(ctx, consumer, errCh)
+		return
+	}
 	err := fs.Load(ctx, consumer)
 	if err != nil {
 		select {
@@ -267,12 +280,20 @@ func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer ch
 	rcvTime := conf.GetNow()
 	switch fs.config.FileType {
 	case JSON_TYPE:
-		r := json.NewDecoder(file)
-		resultMap := make([]map[string]interface{}, 0)
-		err := r.Decode(&resultMap)
+		content, err := io.ReadAll(file)
 		if err != nil {
 			return fmt.Errorf("loaded %s, check error %s", fs.file, err)
 		}
+		resultMap := make([]map[string]interface{}, 0)
+		err = json.Unmarshal(content, &resultMap)
+		if err != nil {
+			// send the content with the error so that it can be routed to the dead letter
+			select {
+			case consumer <- &xsql.ErrorSourceTuple{Error: fmt.Errorf("loaded %s, check error %s", fs.file, err), Payload: content}:
+			case <-ctx.Done():
+			}
+			return nil
+		}
 		ctx.GetLogger().Debug("Sending tuples")
 		for _, m := range resultMap {
 			select {
@@ -286,10 +307,7 @@ func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer ch
 		}
 		return nil
 	case CSV_TYPE:
-		r := csv.NewReader(file)
-		r.Comma = rune(fs.config.Delimiter[0])
-		r.TrimLeadingSpace = true
-		r.FieldsPerRecord = -1
+		r := fs.newCsvReader(file)
 		cols := fs.config.Columns
 		if fs.config.HasHeader {
 			var err error
@@ -314,20 +332,15 @@ func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer ch
 				continue
 			}
 			ctx.GetLogger().Debugf("Read" + strings.Join(record, ","))
-			var m map[string]interface{}
-			if cols == nil {
-				m = make(map[string]interface{}, len(record))
-				for i, v := range record {
-					m["cols"+strconv.Itoa(i)] = v
-				}
+			var tuple api.SourceTuple
+			m, err := csvRecordToMap(record, cols)
+			if err != nil {
+				tuple = &xsql.ErrorSourceTuple{Error: err, Payload: []byte(strings.Join(record, fs.config.Delimiter))}
 			} else {
-				m = make(map[string]interface{}, len(cols))
-				for i, v := range cols {
-					m[v] = record[i]
-				}
+				tuple = api.NewDefaultSourceTupleWithTime(m, meta, rcvTime)
 			}
 			select {
-			case consumer <- api.NewDefaultSourceTupleWithTime(m, meta, rcvTime):
+			case consumer <- tuple:
 			case <-ctx.Done():
 				return nil
 			}
@@ -343,7 +356,8 @@ func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer ch
 			m, err := ctx.DecodeIntoList(scanner.Bytes())
 			if err != nil {
 				tuples = []api.SourceTuple{&xsql.ErrorSourceTuple{
-					Error: fmt.Errorf("Invalid data format, cannot decode %s with error %s", scanner.Text(), err),
+					Error:   fmt.Errorf("Invalid data format, cannot decode %s with error %s", scanner.Text(), err),
+					Payload: []byte(scanner.Text()),
 				}}
 			} else {
 				for _, t := range m {
@@ -367,6 +381,26 @@ func (fs *FileSource) publish(ctx api.StreamContext, file io.Reader, consumer ch
 	return nil
 }
 
+// csvRecordToMap converts the record by the columns. It fails if the record has fewer fields than the columns.
+func csvRecordToMap(record []string, cols []string) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if cols == nil {
+		m = make(map[string]interface{}, len(record))
+		for i, v := range record {
+			m["cols"+strconv.Itoa(i)] = v
+		}
+	} else {
+		if len(record) < len(cols) {
+			return nil, fmt.Errorf("csv record %v has %d fields but %d columns %v are expected", record, len(record), len(cols), cols)
+		}
+		m = make(map[string]interface{}, len(cols))
+		for i, v := range cols {
+			m[v] = record[i]
+		}
+	}
+	return m, nil
+}
+
 // prepareFile prepare file by deleting ignore lines
 func (fs *FileSource) prepareFile(ctx api.StreamContext, file string) (io.Reader, error) {
 	f, err := os.Open(file)
@@ -27,6 +27,7 @@ import (
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/io/mock"
+	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
@@ -56,6 +57,29 @@ func TestJsonFile(t *testing.T) {
 	mock.TestSourceOpen(r, exp, t)
 }
 
+func TestJsonFileInvalid(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte(`[{"id": 1,`)
+	err := os.WriteFile(filepath.Join(dir, "invalid.json"), content, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &FileSource{}
+	err = r.Configure("invalid.json", map[string]interface{}{"path": dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := mock.RunMockSource(r, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	et, ok := result[0].(*xsql.ErrorSourceTuple)
+	if !ok {
+		t.Fatalf("expect error tuple but got %v", result[0])
+	}
+	assert.Equal(t, content, et.Payload)
+}
+
 func TestJsonFolder(t *testing.T) {
 	path, err := os.Getwd()
 	if err != nil {
@@ -251,6 +275,28 @@ func TestCSVFile(t *testing.T) {
 	mock.TestSourceOpen(r, exp, t)
 }
 
+func TestCSVFileShortRecord(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "short.csv"), []byte("id,temp\n1\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &FileSource{}
+	err = r.Configure("short.csv", map[string]interface{}{"path": dir, "fileType": "csv", "hasHeader": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := mock.RunMockSource(r, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	et, ok := result[0].(*xsql.ErrorSourceTuple)
+	if !ok {
+		t.Fatalf("expect error tuple but got %v", result[0])
+	}
+	assert.Equal(t, []byte("1"), et.Payload)
+}
+
 func TestJsonLines(t *testing.T) {
 	path, err := os.Getwd()
 	if err != nil {
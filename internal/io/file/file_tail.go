@@ -0,0 +1,390 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+const defaultTailInterval = 1000
+
+// errIncompleteHead means the ignored start lines or the header is not fully written yet
+var errIncompleteHead = errors.New("incomplete head lines")
+
+func validateTail(cfg *FileSourceConfig, isDir bool) error {
+	if isDir {
+		return errors.New("tail mode only supports a single file")
+	}
+	if cfg.FileType != CSV_TYPE && cfg.FileType != LINES_TYPE {
+		return fmt.Errorf("tail mode only supports csv and lines file type, but got %s", cfg.FileType)
+	}
+	if cfg.ActionAfterRead != 0 {
+		return errors.New("actionAfterRead is not supported in tail mode")
+	}
+	if cfg.Decompression != "" {
+		return errors.New("decompression is not supported in tail mode")
+	}
+	if cfg.IgnoreEndLines > 0 {
+		return errors.New("ignoreEndLines is not supported in tail mode")
+	}
+	return nil
+}
+
+// tailPos is the byte offset of the next line to read. The identity of the file is recorded with the offset so that
+// a saved offset is not applied to another file when resuming.
+type tailPos struct {
+	offset int64
+	// the device and inode of the file, zero if not supported by the os
+	dev uint64
+	ino uint64
+	// the size of the file which has been read, the file must be at least this size to resume from the offset
+	size int64
+}
+
+// fileTailer holds the file being followed. Only the offset is shared with GetOffset, the others are accessed by the tail goroutine only.
+type fileTailer struct {
+	fs      *FileSource
+	f       *os.File
+	fi      os.FileInfo
+	cols    []string
+	headEnd int64
+}
+
+// tail polls the file every interval for the appended lines. A line is only read when it ends with a newline.
+// It reopens the file from the beginning when the file is rotated (replaced by a new file) or truncated.
+func (fs *FileSource) tail(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	logger := ctx.GetLogger()
+	interval := fs.config.Interval
+	if interval <= 0 {
+		interval = defaultTailInterval
+	}
+	ticker := time.NewTicker(time.Duration(interval) * time.Millisecond)
+	defer ticker.Stop()
+	t := &fileTailer{fs: fs}
+	defer t.close()
+	for {
+		err := t.poll(ctx, consumer)
+		if err != nil {
+			logger.Errorf("tail file %s error: %v", fs.file, err)
+			errCh <- err
+			return
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			logger.Infof("stop tailing file %s", fs.file)
+			return
+		}
+	}
+}
+
+func (t *fileTailer) poll(ctx api.StreamContext, consumer chan<- api.SourceTuple) error {
+	if t.f == nil {
+		opened, err := t.open(ctx)
+		if err != nil || !opened {
+			return err
+		}
+	}
+	if err := t.read(ctx, consumer); err != nil {
+		return err
+	}
+	fi, err := os.Stat(t.fs.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// the file is moved away, wait for the new file
+			return nil
+		}
+		return err
+	}
+	switch {
+	case !os.SameFile(t.fi, fi):
+		ctx.GetLogger().Infof("file %s is rotated", t.fs.file)
+		// drain the lines written to the old file before rotation
+		if err := t.read(ctx, consumer); err != nil {
+			return err
+		}
+	case fi.Size() < t.fs.tailOffset():
+		ctx.GetLogger().Infof("file %s is truncated", t.fs.file)
+	default:
+		return nil
+	}
+	t.close()
+	t.fs.resetTail()
+	return nil
+}
+
+// open opens the file and positions the offset after the head lines. It returns false if the file is not ready yet.
+func (t *fileTailer) open(ctx api.StreamContext) (bool, error) {
+	f, err := os.Open(t.fs.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			ctx.GetLogger().Debugf("wait for file %s to be created", t.fs.file)
+			return false, nil
+		}
+		return false, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return false, err
+	}
+	headEnd, cols, err := t.fs.readHead(f)
+	if err != nil {
+		_ = f.Close()
+		if err == errIncompleteHead {
+			return false, nil
+		}
+		return false, err
+	}
+	dev, ino := fileId(fi)
+	pos := t.fs.tailPos()
+	offset := pos.offset
+	switch {
+	case pos.ino != 0 && ino != 0 && (pos.dev != dev || pos.ino != ino):
+		ctx.GetLogger().Infof("file %s is not the file of the offset %d, read from the beginning", t.fs.file, offset)
+		offset = 0
+	case pos.size > fi.Size() || offset > fi.Size():
+		ctx.GetLogger().Infof("offset %d exceeds the size of file %s, read from the beginning", offset, t.fs.file)
+		offset = 0
+	}
+	if offset < headEnd {
+		offset = headEnd
+	}
+	t.fs.setTailPos(tailPos{offset: offset, dev: dev, ino: ino, size: maxInt64(fi.Size(), offset)})
+	t.f, t.fi, t.cols, t.headEnd = f, fi, cols, headEnd
+	ctx.GetLogger().Infof("tail file %s from offset %d", t.fs.file, offset)
+	return true, nil
+}
+
+func (t *fileTailer) read(ctx api.StreamContext, consumer chan<- api.SourceTuple) error {
+	offset := t.fs.tailOffset()
+	if _, err := t.f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	r := bufio.NewReader(t.f)
+	meta := map[string]interface{}{
+		"file": t.fs.file,
+	}
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// the partial line will be read again in the next poll
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		offset += int64(len(line))
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) > 0 {
+			for _, tuple := range t.decode(ctx, line, meta) {
+				select {
+				case consumer <- tuple:
+				case <-ctx.Done():
+					return nil
+				}
+			}
+		}
+		t.fs.setTailOffset(offset)
+		if t.fs.config.SendInterval > 0 {
+			time.Sleep(time.Millisecond * time.Duration(t.fs.config.SendInterval))
+		}
+	}
+}
+
+func (t *fileTailer) decode(ctx api.StreamContext, line []byte, meta map[string]interface{}) []api.SourceTuple {
+	rcvTime := conf.GetNow()
+	switch t.fs.config.FileType {
+	case CSV_TYPE:
+		record, err := t.fs.newCsvReader(bytes.NewReader(line)).Read()
+		if err != nil {
+			ctx.GetLogger().Warnf("Read file %s encounter error: %v", t.fs.file, err)
+			return nil
+		}
+		m, err := csvRecordToMap(record, t.cols)
+		if err != nil {
+			return []api.SourceTuple{&xsql.ErrorSourceTuple{Error: err, Payload: line}}
+		}
+		return []api.SourceTuple{api.NewDefaultSourceTupleWithTime(m, meta, rcvTime)}
+	default:
+		m, err := ctx.DecodeIntoList(line)
+		if err != nil {
+			return []api.SourceTuple{&xsql.ErrorSourceTuple{
+				Error:   fmt.Errorf("Invalid data format, cannot decode %s with error %s", string(line), err),
+				Payload: line,
+			}}
+		}
+		tuples := make([]api.SourceTuple, 0, len(m))
+		for _, v := range m {
+			tuples = append(tuples, api.NewDefaultSourceTupleWithTime(v, meta, rcvTime))
+		}
+		return tuples
+	}
+}
+
+func (t *fileTailer) close() {
+	if t.f != nil {
+		_ = t.f.Close()
+		t.f = nil
+	}
+}
+
+// readHead reads the ignored start lines and the csv header. It returns the offset after them and the csv columns.
+func (fs *FileSource) readHead(f *os.File) (int64, []string, error) {
+	cols := fs.config.Columns
+	n := fs.config.IgnoreStartLines
+	hasHeader := fs.config.FileType == CSV_TYPE && fs.config.HasHeader
+	if hasHeader {
+		n++
+	}
+	if n == 0 {
+		return 0, cols, nil
+	}
+	r := bufio.NewReader(f)
+	var (
+		offset int64
+		line   []byte
+		err    error
+	)
+	for i := 0; i < n; i++ {
+		line, err = r.ReadBytes('\n')
+		if err == io.EOF {
+			return 0, nil, errIncompleteHead
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		offset += int64(len(line))
+	}
+	if hasHeader {
+		cols, err = fs.newCsvReader(bytes.NewReader(bytes.TrimRight(line, "\r\n"))).Read()
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid csv header %s: %v", string(line), err)
+		}
+	}
+	return offset, cols, nil
+}
+
+func (fs *FileSource) newCsvReader(r io.Reader) *csv.Reader {
+	cr := csv.NewReader(r)
+	cr.Comma = rune(fs.config.Delimiter[0])
+	cr.TrimLeadingSpace = true
+	cr.FieldsPerRecord = -1
+	return cr
+}
+
+func (fs *FileSource) tailOffset() int64 {
+	fs.offsetLock.Lock()
+	defer fs.offsetLock.Unlock()
+	return fs.pos.offset
+}
+
+func (fs *FileSource) setTailOffset(offset int64) {
+	fs.offsetLock.Lock()
+	defer fs.offsetLock.Unlock()
+	fs.pos.offset = offset
+	fs.pos.size = maxInt64(fs.pos.size, offset)
+}
+
+func (fs *FileSource) tailPos() tailPos {
+	fs.offsetLock.Lock()
+	defer fs.offsetLock.Unlock()
+	return fs.pos
+}
+
+func (fs *FileSource) setTailPos(pos tailPos) {
+	fs.offsetLock.Lock()
+	defer fs.offsetLock.Unlock()
+	fs.pos = pos
+}
+
+// resetTail reads the next file from the beginning
+func (fs *FileSource) resetTail() {
+	fs.setTailPos(tailPos{})
+}
+
+func maxInt64(a, b int64) int64 {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// GetOffset returns the file, the identity of the file and the byte offset of the next line in tail mode
+func (fs *FileSource) GetOffset() (interface{}, error) {
+	if fs.config == nil || !fs.config.Tail {
+		return nil, nil
+	}
+	pos := fs.tailPos()
+	return map[string]interface{}{
+		"file":   fs.file,
+		"offset": pos.offset,
+		"dev":    pos.dev,
+		"ino":    pos.ino,
+		"size":   pos.size,
+	}, nil
+}
+
+// Rewind sets the offset to resume from. The identity of the file is checked when opening the file, if the file is
+// replaced or truncated, it is read from the beginning.
+func (fs *FileSource) Rewind(offset interface{}) error {
+	if fs.config == nil || !fs.config.Tail {
+		return nil
+	}
+	m, ok := offset.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid file offset %v", offset)
+	}
+	// the offset of another file is useless
+	if file, _ := m["file"].(string); file != fs.file {
+		return nil
+	}
+	pos := tailPos{}
+	var err error
+	pos.offset, err = cast.ToInt64(m["offset"], cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return fmt.Errorf("invalid file offset %v: %v", m["offset"], err)
+	}
+	// the identity is not saved by the previous versions
+	if v, ok := m["dev"]; ok {
+		if pos.dev, err = cast.ToUint64(v, cast.CONVERT_SAMEKIND); err != nil {
+			return fmt.Errorf("invalid file dev %v: %v", v, err)
+		}
+	}
+	if v, ok := m["ino"]; ok {
+		if pos.ino, err = cast.ToUint64(v, cast.CONVERT_SAMEKIND); err != nil {
+			return fmt.Errorf("invalid file ino %v: %v", v, err)
+		}
+	}
+	if v, ok := m["size"]; ok {
+		if pos.size, err = cast.ToInt64(v, cast.CONVERT_SAMEKIND); err != nil {
+			return fmt.Errorf("invalid file size %v: %v", v, err)
+		}
+	}
+	fs.setTailPos(pos)
+	return nil
+}
@@ -0,0 +1,291 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/converter"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+type tailRun struct {
+	consumer chan api.SourceTuple
+	errCh    chan error
+	cancel   func()
+}
+
+func startTail(t *testing.T, r *FileSource) *tailRun {
+	ctx, cancel := mockContext.NewMockContext("ruleTail", "op1").WithCancel()
+	cv, _ := converter.GetOrCreateConverter(&ast.Options{FORMAT: "json"})
+	ctx = context.WithValue(ctx.(*context.DefaultContext), context.DecodeKey, cv)
+	tr := &tailRun{
+		consumer: make(chan api.SourceTuple),
+		errCh:    make(chan error),
+		cancel:   cancel,
+	}
+	go r.Open(ctx, tr.consumer, tr.errCh)
+	return tr
+}
+
+func (tr *tailRun) expect(t *testing.T, exp []map[string]interface{}) {
+	t.Helper()
+	var result []map[string]interface{}
+	timeout := time.After(5 * time.Second)
+	for len(result) < len(exp) {
+		select {
+		case tuple := <-tr.consumer:
+			result = append(result, tuple.Message())
+		case err := <-tr.errCh:
+			t.Fatal(err)
+		case <-timeout:
+			t.Fatalf("timeout, expect %v but got %v", exp, result)
+		}
+	}
+	if !reflect.DeepEqual(exp, result) {
+		t.Errorf("result mismatch:\n  exp=%v\n  got=%v", exp, result)
+	}
+}
+
+func appendFile(t *testing.T, file string, content string) {
+	f, err := os.OpenFile(file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	require.NoError(t, err)
+	_, err = f.WriteString(content)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+}
+
+func TestTailConfigure(t *testing.T) {
+	dir := t.TempDir()
+	appendFile(t, filepath.Join(dir, "a.csv"), "")
+	tests := []struct {
+		name  string
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			name:  "a.csv",
+			props: map[string]interface{}{"fileType": "csv", "tail": true},
+		},
+		{
+			name:  "",
+			props: map[string]interface{}{"fileType": "csv", "tail": true},
+			err:   "tail mode only supports a single file",
+		},
+		{
+			name:  "a.csv",
+			props: map[string]interface{}{"fileType": "json", "tail": true},
+			err:   "tail mode only supports csv and lines file type, but got json",
+		},
+		{
+			name:  "a.csv",
+			props: map[string]interface{}{"fileType": "csv", "tail": true, "actionAfterRead": 1},
+			err:   "actionAfterRead is not supported in tail mode",
+		},
+		{
+			name:  "a.csv",
+			props: map[string]interface{}{"fileType": "csv", "tail": true, "decompression": "gzip"},
+			err:   "decompression is not supported in tail mode",
+		},
+		{
+			name:  "a.csv",
+			props: map[string]interface{}{"fileType": "csv", "tail": true, "ignoreEndLines": 1},
+			err:   "ignoreEndLines is not supported in tail mode",
+		},
+	}
+	for _, tt := range tests {
+		tt.props["path"] = dir
+		err := (&FileSource{}).Configure(tt.name, tt.props)
+		if tt.err == "" {
+			assert.NoError(t, err)
+		} else {
+			assert.EqualError(t, err, tt.err)
+		}
+	}
+}
+
+func TestTailCsv(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "plc.csv")
+	appendFile(t, file, "# plc log\nid,temp\n1,20\n2,21\n3,2")
+	props := map[string]interface{}{
+		"path":             dir,
+		"fileType":         "csv",
+		"hasHeader":        true,
+		"ignoreStartLines": 1,
+		"tail":             true,
+		"interval":         50,
+	}
+	r := &FileSource{}
+	require.NoError(t, r.Configure("plc.csv", props))
+	tr := startTail(t, r)
+	tr.expect(t, []map[string]interface{}{{"id": "1", "temp": "20"}, {"id": "2", "temp": "21"}})
+	// complete the partial line and append more
+	appendFile(t, file, "2\n4,23\n")
+	tr.expect(t, []map[string]interface{}{{"id": "3", "temp": "22"}, {"id": "4", "temp": "23"}})
+	// the offset is updated after the tuple is consumed
+	for i := 0; i < 100 && r.tailOffset() != 38; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	tr.cancel()
+
+	offset, err := r.GetOffset()
+	require.NoError(t, err)
+	fi, err := os.Stat(file)
+	require.NoError(t, err)
+	dev, ino := fileId(fi)
+	assert.Equal(t, map[string]interface{}{"file": file, "offset": int64(38), "dev": dev, "ino": ino, "size": int64(38)}, offset)
+
+	// restart from the offset
+	appendFile(t, file, "5,24\n")
+	r = &FileSource{}
+	require.NoError(t, r.Configure("plc.csv", props))
+	require.NoError(t, r.Rewind(offset))
+	tr = startTail(t, r)
+	tr.expect(t, []map[string]interface{}{{"id": "5", "temp": "24"}})
+
+	// rotation: the old file is drained before reading the new one
+	require.NoError(t, os.Rename(file, file+".1"))
+	appendFile(t, file+".1", "6,25\n")
+	appendFile(t, file, "# plc log\nid,temp\n7,26\n")
+	tr.expect(t, []map[string]interface{}{{"id": "6", "temp": "25"}, {"id": "7", "temp": "26"}})
+
+	// truncation
+	require.NoError(t, os.Truncate(file, 0))
+	time.Sleep(200 * time.Millisecond)
+	appendFile(t, file, "# plc log\nid,temp\n8,27\n")
+	tr.expect(t, []map[string]interface{}{{"id": "8", "temp": "27"}})
+	tr.cancel()
+}
+
+func TestTailCsvShortLine(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "short.csv")
+	appendFile(t, file, "id,temp\n1\n2,21\n")
+	r := &FileSource{}
+	require.NoError(t, r.Configure("short.csv", map[string]interface{}{
+		"path":      dir,
+		"fileType":  "csv",
+		"hasHeader": true,
+		"tail":      true,
+		"interval":  50,
+	}))
+	tr := startTail(t, r)
+	defer tr.cancel()
+	select {
+	case tuple := <-tr.consumer:
+		et, ok := tuple.(*xsql.ErrorSourceTuple)
+		require.True(t, ok, "expect error tuple but got %v", tuple)
+		assert.Equal(t, []byte("1"), et.Payload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout")
+	}
+	tr.expect(t, []map[string]interface{}{{"id": "2", "temp": "21"}})
+}
+
+func TestTailLines(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.lines")
+	appendFile(t, file, "{\"id\": 1}\n\n{\"id\": 2}\n")
+	r := &FileSource{}
+	require.NoError(t, r.Configure("test.lines", map[string]interface{}{
+		"path":     dir,
+		"fileType": "lines",
+		"tail":     true,
+		"interval": 50,
+	}))
+	// the offset of other files is ignored
+	require.NoError(t, r.Rewind(map[string]interface{}{"file": "other", "offset": 100}))
+	tr := startTail(t, r)
+	defer tr.cancel()
+	tr.expect(t, []map[string]interface{}{{"id": 1.0}, {"id": 2.0}})
+	appendFile(t, file, "{\"id\": 3}\n")
+	tr.expect(t, []map[string]interface{}{{"id": 3.0}})
+}
+
+func TestTailRewindIdentity(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "test.lines")
+	appendFile(t, file, "{\"id\": 1}\n{\"id\": 2}\n")
+	props := map[string]interface{}{
+		"path":     dir,
+		"fileType": "lines",
+		"tail":     true,
+		"interval": 50,
+	}
+	r := &FileSource{}
+	require.NoError(t, r.Configure("test.lines", props))
+	tr := startTail(t, r)
+	tr.expect(t, []map[string]interface{}{{"id": 1.0}, {"id": 2.0}})
+	for i := 0; i < 100 && r.tailOffset() != 20; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	tr.cancel()
+	offset, err := r.GetOffset()
+	require.NoError(t, err)
+
+	// the same file is resumed from the offset
+	appendFile(t, file, "{\"id\": 3}\n")
+	r = &FileSource{}
+	require.NoError(t, r.Configure("test.lines", props))
+	require.NoError(t, r.Rewind(offset))
+	tr = startTail(t, r)
+	tr.expect(t, []map[string]interface{}{{"id": 3.0}})
+	tr.cancel()
+
+	// the file is replaced by another file which is longer than the offset
+	require.NoError(t, os.Rename(file, file+".1"))
+	appendFile(t, file, "{\"id\": 4}\n{\"id\": 5}\n{\"id\": 6}\n")
+	r = &FileSource{}
+	require.NoError(t, r.Configure("test.lines", props))
+	require.NoError(t, r.Rewind(offset))
+	tr = startTail(t, r)
+	tr.expect(t, []map[string]interface{}{{"id": 4.0}, {"id": 5.0}, {"id": 6.0}})
+	for i := 0; i < 100 && r.tailOffset() != 30; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	tr.cancel()
+
+	// the file is truncated and rewritten, so it is smaller than the size when saving the offset
+	offset, err = r.GetOffset()
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(file, 0))
+	appendFile(t, file, "{\"id\": 7}\n{\"id\": 8}\n")
+	r = &FileSource{}
+	require.NoError(t, r.Configure("test.lines", props))
+	require.NoError(t, r.Rewind(offset))
+	tr = startTail(t, r)
+	tr.expect(t, []map[string]interface{}{{"id": 7.0}, {"id": 8.0}})
+	tr.cancel()
+
+	// the offset saved by the previous version without identity
+	r = &FileSource{}
+	require.NoError(t, r.Configure("test.lines", props))
+	require.NoError(t, r.Rewind(map[string]interface{}{"file": file, "offset": 10}))
+	tr = startTail(t, r)
+	tr.expect(t, []map[string]interface{}{{"id": 8.0}})
+	tr.cancel()
+}
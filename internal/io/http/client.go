@@ -61,6 +61,10 @@ type RawConf struct {
 	// source specific properties
 	Interval    int  `json:"interval"`
 	Incremental bool `json:"incremental"`
+	// Pagination, DataPath and LastValueField are parsed by the pull source
+	Pagination     map[string]interface{} `json:"pagination"`
+	DataPath       string                 `json:"dataPath"`
+	LastValueField string                 `json:"lastValueField"`
 	// sink specific properties
 	SendSingle bool `json:"sendSingle"`
 	// inferred properties
@@ -0,0 +1,243 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+const (
+	PageTypePage   = "page"
+	PageTypeOffset = "offset"
+	PageTypeCursor = "cursor"
+	PageTypeLink   = "link"
+
+	defaultMaxPages = 100
+	// the keys of the pull state which are also the template variables
+	stateCursor    = "cursor"
+	stateLastValue = "lastValue"
+)
+
+// PaginationConf is the configuration to follow the pages of a poll
+type PaginationConf struct {
+	Type string `json:"type"`
+	// PageParam is the query parameter of the page number or offset
+	PageParam string `json:"pageParam"`
+	// SizeParam is the query parameter of the page size
+	SizeParam string `json:"sizeParam"`
+	PageSize  int    `json:"pageSize"`
+	StartPage int    `json:"startPage"`
+	// CursorPath is the json path of the next cursor in the response
+	CursorPath string `json:"cursorPath"`
+	// CursorParam is the query parameter to send the cursor
+	CursorParam string `json:"cursorParam"`
+	// MaxPages is the max number of requests in a poll
+	MaxPages int `json:"maxPages"`
+}
+
+// pageRequest is the state of the page to request in a poll
+type pageRequest struct {
+	num int
+	// link is the next url from the Link header
+	link string
+}
+
+var linkNextRe = regexp.MustCompile(`<([^>]*)>\s*;[^,]*rel="?next"?`)
+
+func parsePagination(props map[string]interface{}) (*PaginationConf, error) {
+	pc := &PaginationConf{MaxPages: defaultMaxPages}
+	if err := cast.MapToStruct(props, pc); err != nil {
+		return nil, fmt.Errorf("fail to parse the pagination properties: %v", err)
+	}
+	switch pc.Type {
+	case PageTypePage, PageTypeOffset:
+		if pc.PageSize <= 0 {
+			return nil, fmt.Errorf("pagination pageSize must be greater than 0 for %s pagination", pc.Type)
+		}
+		if pc.PageParam == "" {
+			pc.PageParam = pc.Type
+		}
+		if pc.SizeParam == "" {
+			if pc.Type == PageTypePage {
+				pc.SizeParam = "size"
+			} else {
+				pc.SizeParam = "limit"
+			}
+		}
+		if pc.Type == PageTypePage && pc.StartPage == 0 {
+			pc.StartPage = 1
+		}
+	case PageTypeCursor:
+		if pc.CursorPath == "" {
+			return nil, fmt.Errorf("pagination cursorPath is required for cursor pagination")
+		}
+		if pc.CursorParam == "" {
+			pc.CursorParam = "cursor"
+		}
+	case PageTypeLink:
+	default:
+		return nil, fmt.Errorf("pagination type %s is not supported, must be page, offset, cursor or link", pc.Type)
+	}
+	if pc.MaxPages <= 0 {
+		return nil, fmt.Errorf("pagination maxPages must be greater than 0")
+	}
+	return pc, nil
+}
+
+// first returns the first page to request in a poll
+func (pc *PaginationConf) first() *pageRequest {
+	if pc == nil {
+		return &pageRequest{}
+	}
+	return &pageRequest{num: pc.StartPage}
+}
+
+// requestUrl sets the pagination query parameters to the url
+func (pc *PaginationConf) requestUrl(u string, p *pageRequest, cursor interface{}, isFirst bool) (string, error) {
+	if pc == nil {
+		return u, nil
+	}
+	if pc.Type == PageTypeLink {
+		if p.link != "" {
+			return p.link, nil
+		}
+		return u, nil
+	}
+	pu, err := url.Parse(u)
+	if err != nil {
+		return "", fmt.Errorf("invalid url %s: %v", u, err)
+	}
+	q := pu.Query()
+	switch pc.Type {
+	case PageTypePage, PageTypeOffset:
+		q.Set(pc.PageParam, strconv.Itoa(p.num))
+		q.Set(pc.SizeParam, strconv.Itoa(pc.PageSize))
+	case PageTypeCursor:
+		// The first page uses the url as it is, the cursor can be set by the url template if needed
+		if isFirst {
+			return u, nil
+		}
+		q.Set(pc.CursorParam, cast.ToStringAlways(cursor))
+	}
+	pu.RawQuery = q.Encode()
+	return pu.String(), nil
+}
+
+// next returns the next page to request or nil if it is the last page
+func (pc *PaginationConf) next(ctx api.StreamContext, p *pageRequest, resp *http.Response, rows int, cursor interface{}, cursorChanged bool) *pageRequest {
+	if pc == nil {
+		return nil
+	}
+	switch pc.Type {
+	case PageTypePage:
+		if rows < pc.PageSize {
+			return nil
+		}
+		return &pageRequest{num: p.num + 1}
+	case PageTypeOffset:
+		if rows < pc.PageSize {
+			return nil
+		}
+		return &pageRequest{num: p.num + rows}
+	case PageTypeCursor:
+		if cursor == nil || cast.ToStringAlways(cursor) == "" || !cursorChanged {
+			return nil
+		}
+		return &pageRequest{}
+	case PageTypeLink:
+		link := nextLink(resp)
+		if link == "" {
+			return nil
+		}
+		// resolve the relative link
+		if base := resp.Request; base != nil && base.URL != nil {
+			if lu, err := base.URL.Parse(link); err == nil {
+				link = lu.String()
+			}
+		}
+		ctx.GetLogger().Debugf("follow next link %s", link)
+		return &pageRequest{link: link}
+	}
+	return nil
+}
+
+func nextLink(resp *http.Response) string {
+	for _, h := range resp.Header.Values("Link") {
+		if m := linkNextRe.FindStringSubmatch(h); m != nil {
+			return strings.TrimSpace(m[1])
+		}
+	}
+	return ""
+}
+
+// parseBody decodes the json response for the data path and the cursor path
+func parseBody(body []byte) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, fmt.Errorf("response is not a valid json: %v", err)
+	}
+	return v, nil
+}
+
+// selectRows selects the rows from the response by the data path
+func selectRows(ctx api.StreamContext, dataPath string, v interface{}) ([]map[string]interface{}, error) {
+	r, err := ctx.ParseJsonPath(dataPath, v)
+	if err != nil {
+		return nil, fmt.Errorf("fail to select data by %s: %v", dataPath, err)
+	}
+	switch rt := r.(type) {
+	case nil:
+		return nil, nil
+	case map[string]interface{}:
+		return []map[string]interface{}{rt}, nil
+	case []interface{}:
+		rows := make([]map[string]interface{}, 0, len(rt))
+		for _, item := range rt {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("the item %v selected by %s is not an object", item, dataPath)
+			}
+			rows = append(rows, m)
+		}
+		return rows, nil
+	default:
+		return nil, fmt.Errorf("the data %v selected by %s is not an object or array", r, dataPath)
+	}
+}
+
+// greater compares the values of the last value field. Numbers are compared by value and the others as strings such as the time strings.
+func greater(a, b interface{}) bool {
+	if b == nil {
+		return a != nil
+	}
+	if a == nil {
+		return false
+	}
+	fa, ea := cast.ToFloat64(a, cast.STRICT)
+	fb, eb := cast.ToFloat64(b, cast.STRICT)
+	if ea == nil && eb == nil {
+		return fa > fb
+	}
+	return cast.ToStringAlways(a) > cast.ToStringAlways(b)
+}
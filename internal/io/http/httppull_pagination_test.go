@@ -0,0 +1,273 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/converter"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// mockPageServer serves 5 items with ids 1 to 5 by page, offset, cursor and link pagination
+func mockPageServer() *httptest.Server {
+	items := make([]map[string]interface{}, 5)
+	for i := range items {
+		items[i] = map[string]interface{}{"id": i + 1, "name": fmt.Sprintf("item%d", i+1)}
+	}
+	// slice returns the items after the id since in the range [start, start+size)
+	slice := func(r *http.Request, start, size int) []map[string]interface{} {
+		since, _ := strconv.Atoi(r.URL.Query().Get("since"))
+		var result []map[string]interface{}
+		for _, item := range items {
+			if item["id"].(int) > since {
+				result = append(result, item)
+			}
+		}
+		if start >= len(result) {
+			return []map[string]interface{}{}
+		}
+		end := start + size
+		if end > len(result) {
+			end = len(result)
+		}
+		return result[start:end]
+	}
+	router := http.NewServeMux()
+	router.HandleFunc("/page", func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		size, _ := strconv.Atoi(r.URL.Query().Get("size"))
+		jsonOut(w, map[string]interface{}{"data": map[string]interface{}{"items": slice(r, (page-1)*size, size)}})
+	})
+	router.HandleFunc("/offset", func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+		jsonOut(w, slice(r, offset, limit))
+	})
+	router.HandleFunc("/cursor", func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+		result := slice(r, start, 2)
+		next := ""
+		if len(result) == 2 {
+			next = strconv.Itoa(start + 2)
+		}
+		jsonOut(w, map[string]interface{}{"items": result, "meta": map[string]interface{}{"next": next}})
+	})
+	router.HandleFunc("/link", func(w http.ResponseWriter, r *http.Request) {
+		start, _ := strconv.Atoi(r.URL.Query().Get("start"))
+		result := slice(r, start, 2)
+		if start+2 < len(items) {
+			w.Header().Set("Link", fmt.Sprintf(`</link?start=%d>; rel="next"`, start+2))
+		}
+		jsonOut(w, result)
+	})
+	return httptest.NewServer(router)
+}
+
+func pollContext() api.StreamContext {
+	ctx := mockContext.NewMockContext("ruleP", "op1")
+	cv, _ := converter.GetOrCreateConverter(&ast.Options{FORMAT: "json"})
+	return context.WithValue(ctx.(*context.DefaultContext), context.DecodeKey, cv)
+}
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		props map[string]interface{}
+		conf  *PaginationConf
+		err   string
+	}{
+		{
+			props: map[string]interface{}{"type": "page", "pageSize": 10},
+			conf:  &PaginationConf{Type: "page", PageParam: "page", SizeParam: "size", PageSize: 10, StartPage: 1, MaxPages: 100},
+		}, {
+			props: map[string]interface{}{"type": "offset", "pageSize": 10, "pageParam": "skip", "maxPages": 5},
+			conf:  &PaginationConf{Type: "offset", PageParam: "skip", SizeParam: "limit", PageSize: 10, MaxPages: 5},
+		}, {
+			props: map[string]interface{}{"type": "cursor", "cursorPath": "$.next"},
+			conf:  &PaginationConf{Type: "cursor", CursorPath: "$.next", CursorParam: "cursor", MaxPages: 100},
+		}, {
+			props: map[string]interface{}{"type": "link"},
+			conf:  &PaginationConf{Type: "link", MaxPages: 100},
+		}, {
+			props: map[string]interface{}{"type": "page"},
+			err:   "pagination pageSize must be greater than 0 for page pagination",
+		}, {
+			props: map[string]interface{}{"type": "cursor"},
+			err:   "pagination cursorPath is required for cursor pagination",
+		}, {
+			props: map[string]interface{}{"type": "token"},
+			err:   "pagination type token is not supported, must be page, offset, cursor or link",
+		}, {
+			props: map[string]interface{}{"type": "link", "maxPages": -1},
+			err:   "pagination maxPages must be greater than 0",
+		},
+	}
+	for i, tt := range tests {
+		c, err := parsePagination(tt.props)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%d: error mismatch:\n  exp=%s\n  got=%v", i, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(tt.conf, c) {
+			t.Errorf("%d: result mismatch:\n  exp=%v\n  got=%v", i, tt.conf, c)
+		}
+	}
+}
+
+func TestPullPages(t *testing.T) {
+	server := mockPageServer()
+	defer server.Close()
+	tests := []struct {
+		name  string
+		props map[string]interface{}
+	}{
+		{
+			name: "page",
+			props: map[string]interface{}{
+				"url":        server.URL + "/page",
+				"dataPath":   "$.data.items",
+				"pagination": map[string]interface{}{"type": "page", "pageSize": 2},
+			},
+		}, {
+			name: "offset",
+			props: map[string]interface{}{
+				"url":        server.URL + "/offset",
+				"pagination": map[string]interface{}{"type": "offset", "pageSize": 2},
+			},
+		}, {
+			name: "cursor",
+			props: map[string]interface{}{
+				"url":        server.URL + "/cursor",
+				"dataPath":   "$.items",
+				"pagination": map[string]interface{}{"type": "cursor", "cursorPath": "$.meta.next"},
+			},
+		}, {
+			name: "link",
+			props: map[string]interface{}{
+				"url":        server.URL + "/link",
+				"pagination": map[string]interface{}{"type": "link"},
+			},
+		},
+	}
+	var exp []map[string]interface{}
+	for i := 1; i <= 5; i++ {
+		exp = append(exp, map[string]interface{}{"id": float64(i), "name": fmt.Sprintf("item%d", i)})
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.props["method"] = "get"
+			r := &PullSource{}
+			if err := r.Configure("", tt.props); err != nil {
+				t.Fatal(err)
+			}
+			consumer := make(chan api.SourceTuple, 10)
+			omd5 := ""
+			r.poll(pollContext(), consumer, &omd5)
+			close(consumer)
+			var result []map[string]interface{}
+			for tuple := range consumer {
+				result = append(result, tuple.Message())
+			}
+			if !reflect.DeepEqual(exp, result) {
+				t.Errorf("result mismatch:\n  exp=%v\n  got=%v", exp, result)
+			}
+		})
+	}
+}
+
+func TestPullMaxPages(t *testing.T) {
+	server := mockPageServer()
+	defer server.Close()
+	r := &PullSource{}
+	err := r.Configure("", map[string]interface{}{
+		"url":        server.URL + "/offset",
+		"method":     "get",
+		"pagination": map[string]interface{}{"type": "offset", "pageSize": 2, "maxPages": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer := make(chan api.SourceTuple, 10)
+	omd5 := ""
+	r.poll(pollContext(), consumer, &omd5)
+	if len(consumer) != 4 {
+		t.Errorf("expect 4 rows of 2 pages but got %d", len(consumer))
+	}
+}
+
+func TestPullLastValue(t *testing.T) {
+	server := mockPageServer()
+	defer server.Close()
+	r := &PullSource{}
+	err := r.Configure("", map[string]interface{}{
+		"url":            server.URL + "/offset?since={{.lastValue}}",
+		"method":         "get",
+		"lastValueField": "id",
+		"pagination":     map[string]interface{}{"type": "offset", "pageSize": 2},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset, err := r.GetOffset()
+	if err != nil || offset != nil {
+		t.Errorf("expect nil offset before pulling but got %v, %v", offset, err)
+	}
+	// Restored from the state of the last run
+	err = r.Rewind(map[string]interface{}{"lastValue": 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer := make(chan api.SourceTuple, 10)
+	omd5 := ""
+	r.poll(pollContext(), consumer, &omd5)
+	close(consumer)
+	var ids []interface{}
+	for tuple := range consumer {
+		ids = append(ids, tuple.Message()["id"])
+	}
+	if !reflect.DeepEqual([]interface{}{float64(4), float64(5)}, ids) {
+		t.Errorf("expect the rows after the last value but got %v", ids)
+	}
+	offset, err = r.GetOffset()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(map[string]interface{}{"lastValue": float64(5)}, offset) {
+		t.Errorf("offset mismatch, got %v", offset)
+	}
+	// No new data
+	consumer = make(chan api.SourceTuple, 10)
+	r.poll(pollContext(), consumer, &omd5)
+	if len(consumer) != 0 {
+		t.Errorf("expect no rows but got %d", len(consumer))
+	}
+	if err = r.Rewind("invalid"); err == nil {
+		t.Errorf("expect error for invalid offset")
+	}
+}
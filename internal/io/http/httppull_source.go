@@ -15,21 +15,39 @@
 package http
 
 import (
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/pkg/httpx"
 	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
 	"github.com/lf-edge/ekuiper/pkg/infra"
 )
 
 type PullSource struct {
 	ClientConf
+	pagination *PaginationConf
+	// state is the cursor and last value which are saved as the offset to survive restarts
+	stateLock sync.Mutex
+	state     map[string]interface{}
 }
 
 func (hps *PullSource) Configure(device string, props map[string]interface{}) error {
 	conf.Log.Infof("Initialized Httppull source with configurations %#v.", props)
-	return hps.InitConf(device, props)
+	err := hps.InitConf(device, props)
+	if err != nil {
+		return err
+	}
+	if hps.config.Pagination != nil {
+		hps.pagination, err = parsePagination(hps.config.Pagination)
+		if err != nil {
+			return err
+		}
+	}
+	hps.state = make(map[string]interface{})
+	return nil
 }
 
 func (hps *PullSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
@@ -70,37 +88,191 @@ func (hps *PullSource) initTimerPull(ctx api.StreamContext, consumer chan<- api.
 	for {
 		select {
 		case <-ticker.C:
-			rcvTime := conf.GetNow()
-			headers, err := hps.parseHeaders(ctx, hps.tokens)
+			if !hps.poll(ctx, consumer, &omd5) {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// poll requests the pages of a poll and sends the rows. It returns false if the rule is stopped.
+func (hps *PullSource) poll(ctx api.StreamContext, consumer chan<- api.SourceTuple, omd5 *string) bool {
+	logger := ctx.GetLogger()
+	rcvTime := conf.GetNow()
+	page := hps.pagination.first()
+	for i := 0; page != nil; i++ {
+		if hps.pagination != nil && i >= hps.pagination.MaxPages {
+			logger.Warnf("stop following the pages after reaching maxPages %d", hps.pagination.MaxPages)
+			break
+		}
+		data := hps.templateData(page)
+		headers, err := hps.parseHeaders(ctx, data)
+		if err != nil {
+			logger.Errorf("%v", err)
+			return true
+		}
+		u, body, err := hps.parseRequest(ctx, data)
+		if err != nil {
+			logger.Errorf("%v", err)
+			return true
+		}
+		u, err = hps.pagination.requestUrl(u, page, data[stateCursor], i == 0)
+		if err != nil {
+			logger.Errorf("%v", err)
+			return true
+		}
+		ctx.GetLogger().Debugf("httppull source sending request url: %s, headers: %v, body %s", u, headers, body)
+		resp, e := httpx.Send(logger, hps.client, hps.config.BodyType, hps.config.Method, u, headers, true, []byte(body))
+		if e != nil {
+			logger.Warnf("Found error %s when trying to reach %v ", e, hps)
+			return true
+		}
+		logger.Debugf("httppull source got response %v", resp)
+		// Only check the change of the first page for incremental
+		md5 := omd5
+		if i > 0 {
+			md5 = new(string)
+		}
+		results, raw, e := hps.parseResponse(ctx, resp, true, md5)
+		if e != nil {
+			logger.Errorf("Parse response error %v", e)
+			return true
+		}
+		if results == nil && raw == nil {
+			logger.Debugf("no data to send for incremental")
+			return true
+		}
+		var (
+			cursor        interface{}
+			cursorChanged bool
+		)
+		if hps.config.DataPath != "" || hps.pagination != nil && hps.pagination.Type == PageTypeCursor {
+			v, err := parseBody(raw)
 			if err != nil {
-				continue
+				logger.Errorf("%v", err)
+				return true
 			}
-			ctx.GetLogger().Debugf("rest sink sending request url: %s, headers: %v, body %s", hps.config.Url, headers, hps.config.Body)
-			if resp, e := httpx.Send(logger, hps.client, hps.config.BodyType, hps.config.Method, hps.config.Url, headers, true, []byte(hps.config.Body)); e != nil {
-				logger.Warnf("Found error %s when trying to reach %v ", e, hps)
-			} else {
-				logger.Debugf("rest sink got response %v", resp)
-				results, _, e := hps.parseResponse(ctx, resp, true, &omd5)
-				if e != nil {
-					logger.Errorf("Parse response error %v", e)
-					continue
-				}
-				if results == nil {
-					logger.Debugf("no data to send for incremental")
-					continue
+			if hps.config.DataPath != "" {
+				results, err = selectRows(ctx, hps.config.DataPath, v)
+				if err != nil {
+					logger.Errorf("%v", err)
+					return true
 				}
-				meta := make(map[string]interface{})
-				for _, result := range results {
-					select {
-					case consumer <- api.NewDefaultSourceTupleWithTime(result, meta, rcvTime):
-						logger.Debugf("send data to device node")
-					case <-ctx.Done():
-						return
-					}
+			}
+			if hps.pagination != nil && hps.pagination.Type == PageTypeCursor {
+				cursor, err = ctx.ParseJsonPath(hps.pagination.CursorPath, v)
+				if err != nil {
+					// no cursor means the last page
+					logger.Debugf("no cursor found by %s: %v", hps.pagination.CursorPath, err)
+					cursor = nil
 				}
 			}
-		case <-ctx.Done():
-			return
 		}
+		meta := make(map[string]interface{})
+		for _, result := range results {
+			select {
+			case consumer <- api.NewDefaultSourceTupleWithTime(result, meta, rcvTime):
+				logger.Debugf("send data to device node")
+			case <-ctx.Done():
+				return false
+			}
+			hps.updateLastValue(result)
+		}
+		if cursor != nil && cast.ToStringAlways(cursor) != "" {
+			cursorChanged = hps.updateCursor(cursor)
+		}
+		page = hps.pagination.next(ctx, page, resp, len(results), cursor, cursorChanged)
 	}
+	return true
+}
+
+// templateData returns the data for the url, body and headers templates
+func (hps *PullSource) templateData(page *pageRequest) map[string]interface{} {
+	data := make(map[string]interface{}, len(hps.tokens)+4)
+	for k, v := range hps.tokens {
+		data[k] = v
+	}
+	hps.stateLock.Lock()
+	for k, v := range hps.state {
+		data[k] = v
+	}
+	hps.stateLock.Unlock()
+	if hps.pagination != nil {
+		switch hps.pagination.Type {
+		case PageTypePage:
+			data["page"] = page.num
+		case PageTypeOffset:
+			data["offset"] = page.num
+		}
+	}
+	return data
+}
+
+func (hps *PullSource) parseRequest(ctx api.StreamContext, data map[string]interface{}) (string, string, error) {
+	u, err := ctx.ParseTemplate(hps.config.Url, data)
+	if err != nil {
+		return "", "", fmt.Errorf("fail to parse the url template %s: %v", hps.config.Url, err)
+	}
+	body, err := ctx.ParseTemplate(hps.config.Body, data)
+	if err != nil {
+		return "", "", fmt.Errorf("fail to parse the body template %s: %v", hps.config.Body, err)
+	}
+	return u, body, nil
+}
+
+func (hps *PullSource) updateLastValue(row map[string]interface{}) {
+	if hps.config.LastValueField == "" {
+		return
+	}
+	v, ok := row[hps.config.LastValueField]
+	if !ok {
+		return
+	}
+	hps.stateLock.Lock()
+	defer hps.stateLock.Unlock()
+	if greater(v, hps.state[stateLastValue]) {
+		hps.state[stateLastValue] = v
+	}
+}
+
+// updateCursor saves the cursor and returns whether it is changed
+func (hps *PullSource) updateCursor(cursor interface{}) bool {
+	hps.stateLock.Lock()
+	defer hps.stateLock.Unlock()
+	if old, ok := hps.state[stateCursor]; ok && cast.ToStringAlways(old) == cast.ToStringAlways(cursor) {
+		return false
+	}
+	hps.state[stateCursor] = cursor
+	return true
+}
+
+// GetOffset returns the cursor and the last value to continue the pulling after restart
+func (hps *PullSource) GetOffset() (interface{}, error) {
+	hps.stateLock.Lock()
+	defer hps.stateLock.Unlock()
+	if len(hps.state) == 0 {
+		return nil, nil
+	}
+	offset := make(map[string]interface{}, len(hps.state))
+	for k, v := range hps.state {
+		offset[k] = v
+	}
+	return offset, nil
+}
+
+func (hps *PullSource) Rewind(offset interface{}) error {
+	m, ok := offset.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("invalid httppull offset %v", offset)
+	}
+	hps.stateLock.Lock()
+	defer hps.stateLock.Unlock()
+	for _, k := range []string{stateCursor, stateLastValue} {
+		if v, ok := m[k]; ok {
+			hps.state[k] = v
+		}
+	}
+	return nil
 }
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/io/http/httpserver"
@@ -32,6 +33,18 @@ type PushConf struct {
 	ContentType  string `json:"contentType"`
 	BufferLength int    `json:"bufferLength"`
 	Endpoint     string `json:"endpoint"`
+	// authentication: none, bearer, basic or cert
+	AuthType string `json:"authType"`
+	Token    string `json:"token"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	// limits, 0 means no limit
+	MaxBodySize int64   `json:"maxBodySize"`
+	RateLimit   float64 `json:"rateLimit"`
+	RateBurst   int     `json:"rateBurst"`
+	// sync mode replies the request with the rule result
+	Sync         bool `json:"sync"`
+	ReplyTimeout int  `json:"replyTimeout"`
 }
 
 type PushSource struct {
@@ -43,6 +56,8 @@ func (hps *PushSource) Configure(endpoint string, props map[string]interface{})
 		Method:       http.MethodPost,
 		ContentType:  "application/json",
 		BufferLength: 1024,
+		AuthType:     httpserver.AuthNone,
+		ReplyTimeout: 5000,
 	}
 	err := cast.MapToStruct(props, cfg)
 	if err != nil {
@@ -57,6 +72,29 @@ func (hps *PushSource) Configure(endpoint string, props map[string]interface{})
 	if !strings.HasPrefix(endpoint, "/") {
 		return fmt.Errorf("property `endpoint` must start with /")
 	}
+	switch cfg.AuthType {
+	case httpserver.AuthNone:
+	case httpserver.AuthBearer:
+		if cfg.Token == "" {
+			return fmt.Errorf("property `token` is required for bearer auth")
+		}
+	case httpserver.AuthBasic:
+		if cfg.Username == "" {
+			return fmt.Errorf("property `username` is required for basic auth")
+		}
+	case httpserver.AuthCert:
+		if conf.Config == nil || conf.Config.Source.HttpServerTls == nil || conf.Config.Source.HttpServerTls.Cafile == "" {
+			return fmt.Errorf("cert auth requires httpServerTls with cafile in the server configuration")
+		}
+	default:
+		return fmt.Errorf("authType %s is not supported, must be none, bearer, basic or cert", cfg.AuthType)
+	}
+	if cfg.MaxBodySize < 0 || cfg.RateLimit < 0 || cfg.RateBurst < 0 {
+		return fmt.Errorf("properties `maxBodySize`, `rateLimit` and `rateBurst` must not be negative")
+	}
+	if cfg.Sync && cfg.ReplyTimeout <= 0 {
+		return fmt.Errorf("property `replyTimeout` must be positive")
+	}
 
 	cfg.Endpoint = endpoint
 	hps.conf = cfg
@@ -65,12 +103,23 @@ func (hps *PushSource) Configure(endpoint string, props map[string]interface{})
 }
 
 func (hps *PushSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
-	t, done, err := httpserver.RegisterEndpoint(hps.conf.Endpoint, hps.conf.Method, hps.conf.ContentType)
+	t, done, err := httpserver.RegisterEndpointWithOptions(hps.conf.Endpoint, &httpserver.EndpointOptions{
+		Method:       hps.conf.Method,
+		AuthType:     hps.conf.AuthType,
+		Token:        hps.conf.Token,
+		Username:     hps.conf.Username,
+		Password:     hps.conf.Password,
+		MaxBodySize:  hps.conf.MaxBodySize,
+		RateLimit:    hps.conf.RateLimit,
+		RateBurst:    hps.conf.RateBurst,
+		Sync:         hps.conf.Sync,
+		ReplyTimeout: time.Duration(hps.conf.ReplyTimeout) * time.Millisecond,
+	})
 	if err != nil {
 		infra.DrainError(ctx, err, errCh)
 		return
 	}
-	defer httpserver.UnregisterEndpoint(hps.conf.Endpoint)
+	defer httpserver.UnregisterEndpoint(hps.conf.Endpoint, hps.conf.Method)
 	ch := pubsub.CreateSub(t, nil, fmt.Sprintf("%s_%s_%d", ctx.GetRuleId(), ctx.GetOpId(), ctx.GetInstanceId()), hps.conf.BufferLength)
 	defer pubsub.CloseSourceConsumerChannel(t, fmt.Sprintf("%s_%s_%d", ctx.GetRuleId(), ctx.GetOpId(), ctx.GetInstanceId()))
 	for {
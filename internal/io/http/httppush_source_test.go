@@ -0,0 +1,88 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPushConfigure(t *testing.T) {
+	tests := []struct {
+		name     string
+		endpoint string
+		props    map[string]interface{}
+		err      string
+	}{
+		{
+			name:     "default",
+			endpoint: "/api/data",
+			props:    map[string]interface{}{},
+		},
+		{
+			name:     "bearer with limits",
+			endpoint: "/devices/{id}",
+			props: map[string]interface{}{
+				"authType":    "bearer",
+				"token":       "secret",
+				"maxBodySize": 1024,
+				"rateLimit":   10,
+				"rateBurst":   20,
+				"sync":        true,
+			},
+		},
+		{
+			name:     "bearer without token",
+			endpoint: "/api/data",
+			props:    map[string]interface{}{"authType": "bearer"},
+			err:      "property `token` is required for bearer auth",
+		},
+		{
+			name:     "basic without username",
+			endpoint: "/api/data",
+			props:    map[string]interface{}{"authType": "basic"},
+			err:      "property `username` is required for basic auth",
+		},
+		{
+			name:     "unknown auth",
+			endpoint: "/api/data",
+			props:    map[string]interface{}{"authType": "oauth"},
+			err:      "authType oauth is not supported, must be none, bearer, basic or cert",
+		},
+		{
+			name:     "negative limit",
+			endpoint: "/api/data",
+			props:    map[string]interface{}{"maxBodySize": -1},
+			err:      "properties `maxBodySize`, `rateLimit` and `rateBurst` must not be negative",
+		},
+		{
+			name:     "invalid reply timeout",
+			endpoint: "/api/data",
+			props:    map[string]interface{}{"sync": true, "replyTimeout": 0},
+			err:      "property `replyTimeout` must be positive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&PushSource{}).Configure(tt.endpoint, tt.props)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
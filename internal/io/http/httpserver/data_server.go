@@ -15,9 +15,11 @@
 package httpserver
 
 import (
-	"encoding/json"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
@@ -72,34 +74,85 @@ func registerInit() error {
 }
 
 func RegisterEndpoint(endpoint string, method string, _ string) (string, chan struct{}, error) {
+	return RegisterEndpointWithOptions(endpoint, &EndpointOptions{Method: method})
+}
+
+// RegisterEndpointWithOptions registers a push endpoint. The endpoint can have path variables like /devices/{id}
+// which are set into the meta of the received messages. The sources of the same path and method share the endpoint,
+// so they must have the same options.
+func RegisterEndpointWithOptions(path string, opts *EndpointOptions) (string, chan struct{}, error) {
 	err := registerInit()
 	if err != nil {
 		return "", nil, err
 	}
-	topic := TopicPrefix + endpoint
+	topic := TopicPrefix + path
 	pubsub.CreatePub(topic)
-	router.HandleFunc(endpoint, func(w http.ResponseWriter, r *http.Request) {
-		sctx.GetLogger().Debugf("receive http request: %s", r.URL.String())
-		defer r.Body.Close()
-		m := make(map[string]interface{})
-		err := json.NewDecoder(r.Body).Decode(&m)
-		if err != nil {
-			handleError(w, err, "Fail to decode data")
-			pubsub.ProduceError(sctx, topic, fmt.Errorf("fail to decode data %s: %v", r.Body, err))
+	lock.Lock()
+	ep, ok := endpoints[path]
+	if ok {
+		if h, exists := ep.methods[opts.Method]; exists && *h.opts != *opts {
+			lock.Unlock()
+			pubsub.RemovePub(topic)
+			unregisterRef()
+			return "", nil, fmt.Errorf("endpoint %s %s is already registered with different options", opts.Method, path)
+		}
+	} else {
+		ep = &endpoint{
+			path:    path,
+			topic:   topic,
+			methods: make(map[string]*methodHandler),
+		}
+		endpoints[path] = ep
+	}
+	defer lock.Unlock()
+	ep.refCount++
+	if h, exists := ep.methods[opts.Method]; exists {
+		h.refCount++
+	} else {
+		ep.methods[opts.Method] = newMethodHandler(opts)
+	}
+	if _, ok := routed[path]; ok {
+		return topic, done, nil
+	}
+	routed[path] = struct{}{}
+	// The mux router does not support removing routes, so the route looks up the endpoint for each request
+	router.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		lock.RLock()
+		e, ok := endpoints[path]
+		lock.RUnlock()
+		if !ok {
+			http.NotFound(w, r)
 			return
 		}
-		sctx.GetLogger().Debugf("httppush received message %s", m)
-		pubsub.Produce(sctx, topic, m)
-		w.WriteHeader(http.StatusOK)
-		_, _ = w.Write([]byte("ok"))
-	}).Methods(method)
+		e.serve(w, r)
+	})
 	return topic, done, nil
 }
 
-func UnregisterEndpoint(endpoint string) {
+// UnregisterEndpoint releases the endpoint of the method. The options of the method are removed when it is no longer referred.
+func UnregisterEndpoint(endpoint string, method string) {
+	pubsub.RemovePub(TopicPrefix + endpoint)
+	lock.Lock()
+	if ep, ok := endpoints[endpoint]; ok {
+		if h, exists := ep.methods[method]; exists {
+			h.refCount--
+			if h.refCount == 0 {
+				delete(ep.methods, method)
+			}
+		}
+		ep.refCount--
+		if ep.refCount == 0 {
+			delete(endpoints, endpoint)
+		}
+	}
+	lock.Unlock()
+	unregisterRef()
+}
+
+// unregisterRef decreases the reference of the data server and shuts it down if it is not referred
+func unregisterRef() {
 	lock.Lock()
 	defer lock.Unlock()
-	pubsub.RemovePub(TopicPrefix + endpoint)
 	refCount--
 	// TODO async close server
 	if refCount == 0 {
@@ -110,6 +163,8 @@ func UnregisterEndpoint(endpoint string) {
 		sctx.GetLogger().Infof("http data server exiting")
 		server = nil
 		router = nil
+		routed = make(map[string]struct{})
+		wsRouted = make(map[string]struct{})
 	}
 }
 
@@ -124,7 +179,24 @@ func createDataServer() (*http.Server, *mux.Router, error) {
 		IdleTimeout:  time.Second * 60,
 		Handler:      handlers.CORS(handlers.AllowedHeaders([]string{"Accept", "Accept-Language", "Content-Type", "Content-Language", "Origin", "Authorization"}), handlers.AllowedMethods([]string{"POST", "GET", "PUT", "DELETE", "HEAD"}))(r),
 	}
-	done = make(chan struct{})
+	if tlsConf := conf.Config.Source.HttpServerTls; tlsConf != nil && tlsConf.Cafile != "" {
+		caCert, err := os.ReadFile(tlsConf.Cafile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("fail to read cafile %s: %v", tlsConf.Cafile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, nil, fmt.Errorf("invalid cafile %s", tlsConf.Cafile)
+		}
+		// Verify the client certificates if given so that only the endpoints with cert auth require them
+		s.TLSConfig = &tls.Config{
+			ClientCAs:  pool,
+			ClientAuth: tls.VerifyClientCertIfGiven,
+		}
+	}
+	// The server goroutine closes its own channel which is replaced when the server restarts
+	d := make(chan struct{})
+	done = d
 	go func() {
 		var err error
 		if conf.Config.Source.HttpServerTls == nil {
@@ -134,7 +206,7 @@ func createDataServer() (*http.Server, *mux.Router, error) {
 		}
 		if err != nil {
 			sctx.GetLogger().Errorf("http data server error: %v", err)
-			close(done)
+			close(d)
 		}
 	}()
 	sctx.GetLogger().Infof("Serving http data server on port http://%s:%d", conf.Config.Source.HttpServerIp, conf.Config.Source.HttpServerPort)
@@ -46,9 +46,9 @@ func TestEndpoints(t *testing.T) {
 		t.Error("refCount is not 3 after registering")
 		return
 	}
-	UnregisterEndpoint(endpoints[0])
-	UnregisterEndpoint(endpoints[1])
-	UnregisterEndpoint(endpoints[2])
+	UnregisterEndpoint(endpoints[0], "POST")
+	UnregisterEndpoint(endpoints[1], "PUT")
+	UnregisterEndpoint(endpoints[2], "POST")
 	if refCount != 0 {
 		t.Error("refCount is not 0 after unregistering")
 		return
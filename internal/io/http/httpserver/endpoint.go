@@ -0,0 +1,220 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpserver
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"golang.org/x/time/rate"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+const (
+	AuthNone   = "none"
+	AuthBearer = "bearer"
+	AuthBasic  = "basic"
+	AuthCert   = "cert"
+	// RequestIdMeta is the meta key of the request id in sync mode
+	RequestIdMeta = "requestId"
+)
+
+// EndpointOptions is the options of a push endpoint for a http method
+type EndpointOptions struct {
+	Method   string
+	AuthType string
+	Token    string
+	Username string
+	Password string
+	// MaxBodySize is the max bytes of the request body, 0 means no limit
+	MaxBodySize int64
+	// RateLimit is the max requests per second, 0 means no limit
+	RateLimit float64
+	RateBurst int
+	// Sync makes the request wait for the rule result as the response
+	Sync         bool
+	ReplyTimeout time.Duration
+}
+
+// Reply is the response of a sync request
+type Reply struct {
+	ContentType string
+	Body        []byte
+}
+
+// endpoint is the state of a registered push endpoint. The options are kept per method
+// and shared by all the registrations of the method.
+type endpoint struct {
+	path     string
+	topic    string
+	refCount int
+	methods  map[string]*methodHandler
+}
+
+type methodHandler struct {
+	opts     *EndpointOptions
+	limiter  *rate.Limiter
+	refCount int
+}
+
+var (
+	endpoints = make(map[string]*endpoint)
+	// the paths routed in the current router. A path is routed only once since the route can't be removed
+	routed = make(map[string]struct{})
+	// pendingReplies is the reply channel of the waiting sync requests by request id
+	pendingReplies sync.Map
+)
+
+// ReplyRequest sends the rule result to the waiting sync request. It returns false if the
+// request is not found, which means it has timed out or been answered by another rule.
+func ReplyRequest(requestId string, r *Reply) bool {
+	if v, ok := pendingReplies.LoadAndDelete(requestId); ok {
+		v.(chan *Reply) <- r
+		return true
+	}
+	return false
+}
+
+func newMethodHandler(opts *EndpointOptions) *methodHandler {
+	h := &methodHandler{opts: opts, refCount: 1}
+	if opts.RateLimit > 0 {
+		burst := opts.RateBurst
+		if burst <= 0 {
+			burst = 1
+		}
+		h.limiter = rate.NewLimiter(rate.Limit(opts.RateLimit), burst)
+	}
+	return h
+}
+
+func (ep *endpoint) serve(w http.ResponseWriter, r *http.Request) {
+	sctx.GetLogger().Debugf("receive http request: %s", r.URL.String())
+	lock.RLock()
+	h, ok := ep.methods[r.Method]
+	lock.RUnlock()
+	if !ok {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if code, err := h.authenticate(r); err != nil {
+		if h.opts.AuthType == AuthBasic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="ekuiper"`)
+		}
+		http.Error(w, err.Error(), code)
+		return
+	}
+	if h.limiter != nil && !h.limiter.Allow() {
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+	if h.opts.MaxBodySize > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, h.opts.MaxBodySize)
+	}
+	defer r.Body.Close()
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			http.Error(w, fmt.Sprintf("request body exceeds the limit of %d bytes", h.opts.MaxBodySize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		handleError(w, err, "Fail to read data")
+		return
+	}
+	m := make(map[string]interface{})
+	err = json.Unmarshal(body, &m)
+	if err != nil {
+		handleError(w, err, "Fail to decode data")
+		pubsub.ProduceError(sctx, ep.topic, fmt.Errorf("fail to decode data %s: %v", body, err), body)
+		return
+	}
+	sctx.GetLogger().Debugf("httppush received message %s", m)
+	meta := map[string]interface{}{
+		"topic":  ep.topic,
+		"method": r.Method,
+	}
+	for k, v := range mux.Vars(r) {
+		meta[k] = v
+	}
+	if !h.opts.Sync {
+		if err := pubsub.ProduceTuple(sctx, ep.topic, api.NewDefaultSourceTupleWithTime(m, meta, conf.GetNow())); err != nil {
+			http.Error(w, fmt.Sprintf("Fail to save data: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+		return
+	}
+	requestId := uuid.New().String()
+	meta[RequestIdMeta] = requestId
+	ch := make(chan *Reply, 1)
+	pendingReplies.Store(requestId, ch)
+	defer pendingReplies.Delete(requestId)
+	if err := pubsub.ProduceTuple(sctx, ep.topic, api.NewDefaultSourceTupleWithTime(m, meta, conf.GetNow())); err != nil {
+		http.Error(w, fmt.Sprintf("Fail to save data: %v", err), http.StatusInternalServerError)
+		return
+	}
+	timer := time.NewTimer(h.opts.ReplyTimeout)
+	defer timer.Stop()
+	select {
+	case reply := <-ch:
+		if reply.ContentType != "" {
+			w.Header().Set("Content-Type", reply.ContentType)
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(reply.Body)
+	case <-timer.C:
+		http.Error(w, "timeout to wait for the rule result", http.StatusGatewayTimeout)
+	case <-r.Context().Done():
+	}
+}
+
+// authenticate returns the status code and error if the request is not authorized
+func (h *methodHandler) authenticate(r *http.Request) (int, error) {
+	switch h.opts.AuthType {
+	case AuthBearer:
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !secureEqual(token, h.opts.Token) {
+			return http.StatusUnauthorized, fmt.Errorf("invalid bearer token")
+		}
+	case AuthBasic:
+		u, p, ok := r.BasicAuth()
+		if !ok || !secureEqual(u, h.opts.Username) || !secureEqual(p, h.opts.Password) {
+			return http.StatusUnauthorized, fmt.Errorf("invalid username or password")
+		}
+	case AuthCert:
+		// The certificates are verified by the server with the configured client CA
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+			return http.StatusUnauthorized, fmt.Errorf("client certificate is required")
+		}
+	}
+	return http.StatusOK, nil
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
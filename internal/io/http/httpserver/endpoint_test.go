@@ -0,0 +1,180 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpserver
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+func doRequest(t *testing.T, req *http.Request) (int, string) {
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(b)
+}
+
+func newRequest(t *testing.T, url string, body string) *http.Request {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(body))
+	require.NoError(t, err)
+	return req
+}
+
+func TestEndpointOptions(t *testing.T) {
+	testx.InitEnv()
+	topic, _, err := RegisterEndpointWithOptions("/devices/{id}/data", &EndpointOptions{
+		Method:      http.MethodPost,
+		AuthType:    AuthBearer,
+		Token:       "secret",
+		MaxBodySize: 20,
+		RateLimit:   0.001,
+		RateBurst:   2,
+	})
+	require.NoError(t, err)
+	defer UnregisterEndpoint("/devices/{id}/data", http.MethodPost)
+	ch := pubsub.CreateSub(topic, nil, "TestEndpointOptions", 10)
+	defer pubsub.CloseSourceConsumerChannel(topic, "TestEndpointOptions")
+	url := "http://localhost:10081/devices/d1/data"
+	time.Sleep(100 * time.Millisecond)
+
+	code, _ := doRequest(t, newRequest(t, url, `{"a":1}`))
+	assert.Equal(t, http.StatusUnauthorized, code)
+
+	req := newRequest(t, url, `{"a":1}`)
+	req.Header.Set("Authorization", "Bearer secret")
+	code, _ = doRequest(t, req)
+	assert.Equal(t, http.StatusOK, code)
+	select {
+	case tuple := <-ch:
+		assert.Equal(t, map[string]interface{}{"a": float64(1)}, tuple.Message())
+		assert.Equal(t, "d1", tuple.Meta()["id"])
+		assert.Equal(t, http.MethodPost, tuple.Meta()["method"])
+	case <-time.After(time.Second):
+		t.Fatal("timeout to receive the message")
+	}
+
+	req = newRequest(t, url, `{"a":"this body is too large"}`)
+	req.Header.Set("Authorization", "Bearer secret")
+	code, _ = doRequest(t, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+
+	// The burst is used up
+	req = newRequest(t, url, `{"a":1}`)
+	req.Header.Set("Authorization", "Bearer secret")
+	code, _ = doRequest(t, req)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+}
+
+func TestEndpointBasicAuth(t *testing.T) {
+	testx.InitEnv()
+	_, _, err := RegisterEndpointWithOptions("/basic", &EndpointOptions{
+		Method:   http.MethodPost,
+		AuthType: AuthBasic,
+		Username: "user",
+		Password: "pass",
+	})
+	require.NoError(t, err)
+	defer UnregisterEndpoint("/basic", http.MethodPost)
+	time.Sleep(100 * time.Millisecond)
+	req := newRequest(t, "http://localhost:10081/basic", `{"a":1}`)
+	req.SetBasicAuth("user", "wrong")
+	code, _ := doRequest(t, req)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	req = newRequest(t, "http://localhost:10081/basic", `{"a":1}`)
+	req.SetBasicAuth("user", "pass")
+	code, _ = doRequest(t, req)
+	assert.Equal(t, http.StatusOK, code)
+	req, _ = http.NewRequest(http.MethodPut, "http://localhost:10081/basic", bytes.NewBufferString(`{"a":1}`))
+	code, _ = doRequest(t, req)
+	assert.Equal(t, http.StatusMethodNotAllowed, code)
+}
+
+func TestEndpointSync(t *testing.T) {
+	testx.InitEnv()
+	topic, _, err := RegisterEndpointWithOptions("/sync", &EndpointOptions{
+		Method:       http.MethodPost,
+		Sync:         true,
+		ReplyTimeout: 500 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	defer UnregisterEndpoint("/sync", http.MethodPost)
+	ch := pubsub.CreateSub(topic, nil, "TestEndpointSync", 10)
+	defer pubsub.CloseSourceConsumerChannel(topic, "TestEndpointSync")
+	time.Sleep(100 * time.Millisecond)
+	go func() {
+		tuple := <-ch
+		id := tuple.Meta()[RequestIdMeta].(string)
+		ReplyRequest(id, &Reply{ContentType: "application/json", Body: []byte(`{"result":2}`)})
+	}()
+	code, body := doRequest(t, newRequest(t, "http://localhost:10081/sync", `{"a":1}`))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `{"result":2}`, body)
+
+	// no reply
+	code, _ = doRequest(t, newRequest(t, "http://localhost:10081/sync", `{"a":1}`))
+	assert.Equal(t, http.StatusGatewayTimeout, code)
+	assert.False(t, ReplyRequest("unknown", &Reply{}))
+}
+
+func TestEndpointConflict(t *testing.T) {
+	testx.InitEnv()
+	opts := &EndpointOptions{Method: http.MethodPost, AuthType: AuthBearer, Token: "secret"}
+	_, _, err := RegisterEndpointWithOptions("/shared", opts)
+	require.NoError(t, err)
+	defer UnregisterEndpoint("/shared", http.MethodPost)
+	// the same options share the endpoint
+	_, _, err = RegisterEndpointWithOptions("/shared", &EndpointOptions{Method: http.MethodPost, AuthType: AuthBearer, Token: "secret"})
+	require.NoError(t, err)
+	_, _, err = RegisterEndpointWithOptions("/shared", &EndpointOptions{Method: http.MethodPost})
+	assert.EqualError(t, err, "endpoint POST /shared is already registered with different options")
+	_, _, err = RegisterEndpointWithOptions("/shared", &EndpointOptions{Method: http.MethodPut})
+	require.NoError(t, err)
+	UnregisterEndpoint("/shared", http.MethodPut)
+	UnregisterEndpoint("/shared", http.MethodPost)
+	lock.RLock()
+	assert.Equal(t, 1, endpoints["/shared"].refCount)
+	assert.Len(t, endpoints["/shared"].methods, 1)
+	lock.RUnlock()
+
+	// the path is routed once after the endpoint is registered again while the server is kept by another endpoint
+	_, _, err = RegisterEndpointWithOptions("/other", &EndpointOptions{Method: http.MethodPost})
+	require.NoError(t, err)
+	defer UnregisterEndpoint("/other", http.MethodPost)
+	UnregisterEndpoint("/shared", http.MethodPost)
+	_, _, err = RegisterEndpointWithOptions("/shared", &EndpointOptions{Method: http.MethodPost})
+	require.NoError(t, err)
+	count := 0
+	lock.RLock()
+	_ = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if p, _ := route.GetPathTemplate(); p == "/shared" {
+			count++
+		}
+		return nil
+	})
+	lock.RUnlock()
+	assert.Equal(t, 1, count)
+}
@@ -0,0 +1,287 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpserver
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = wsPongWait * 9 / 10
+)
+
+var (
+	wsUpgrader = websocket.Upgrader{
+		// The data server already allows cross-origin requests
+		CheckOrigin: func(_ *http.Request) bool { return true },
+	}
+	wsEndpoints = make(map[string]*WebsocketEndpoint)
+	// the paths routed in the current router. A path is routed only once since the route can't be removed
+	wsRouted = make(map[string]struct{})
+)
+
+// WebsocketMessage is a message received from a websocket client
+type WebsocketMessage struct {
+	Data       []byte
+	RemoteAddr string
+}
+
+// WebsocketEndpoint is a websocket endpoint on the data server. It is shared by all the sources and sinks
+// bound to the same path. The messages from the clients are broadcast to the subscribed sources and the
+// sinks broadcast the results to all the connected clients.
+type WebsocketEndpoint struct {
+	path        string
+	refCount    int
+	sendBuffer  int
+	mu          sync.RWMutex
+	conns       map[*wsConn]struct{}
+	subscribers map[string]chan *WebsocketMessage
+	closed      bool
+}
+
+// wsConn is a client connection with its own send queue so that a slow client won't block the others
+type wsConn struct {
+	conn   *websocket.Conn
+	remote string
+	send   chan wsFrame
+	closed chan struct{}
+	once   sync.Once
+}
+
+type wsFrame struct {
+	messageType int
+	data        []byte
+}
+
+// RegisterWebsocketEndpoint registers a websocket endpoint on the data server. If the endpoint is already
+// registered, the existing one is returned and the sendBuffer of the first registration is kept.
+func RegisterWebsocketEndpoint(endpoint string, sendBuffer int) (*WebsocketEndpoint, chan struct{}, error) {
+	err := registerInit()
+	if err != nil {
+		return nil, nil, err
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if ep, ok := wsEndpoints[endpoint]; ok {
+		ep.refCount++
+		return ep, done, nil
+	}
+	if sendBuffer <= 0 {
+		sendBuffer = 1024
+	}
+	ep := &WebsocketEndpoint{
+		path:        endpoint,
+		refCount:    1,
+		sendBuffer:  sendBuffer,
+		conns:       make(map[*wsConn]struct{}),
+		subscribers: make(map[string]chan *WebsocketMessage),
+	}
+	wsEndpoints[endpoint] = ep
+	if _, ok := wsRouted[endpoint]; ok {
+		return ep, done, nil
+	}
+	wsRouted[endpoint] = struct{}{}
+	// The mux router does not support removing routes, so the route looks up the endpoint for each request
+	router.HandleFunc(endpoint, func(w http.ResponseWriter, r *http.Request) {
+		lock.RLock()
+		e, ok := wsEndpoints[endpoint]
+		lock.RUnlock()
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		e.serve(w, r)
+	})
+	return ep, done, nil
+}
+
+// UnregisterWebsocketEndpoint releases the endpoint. The client connections are closed when it is no longer referred.
+func UnregisterWebsocketEndpoint(endpoint string) {
+	lock.Lock()
+	ep, ok := wsEndpoints[endpoint]
+	shouldClose := false
+	if ok {
+		ep.refCount--
+		if ep.refCount == 0 {
+			delete(wsEndpoints, endpoint)
+			shouldClose = true
+		}
+	}
+	lock.Unlock()
+	if shouldClose {
+		ep.close()
+	}
+	unregisterRef()
+}
+
+func (ep *WebsocketEndpoint) serve(w http.ResponseWriter, r *http.Request) {
+	conn, err := wsUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		sctx.GetLogger().Errorf("websocket endpoint %s upgrade error: %v", ep.path, err)
+		return
+	}
+	c := &wsConn{
+		conn:   conn,
+		remote: conn.RemoteAddr().String(),
+		send:   make(chan wsFrame, ep.sendBuffer),
+		closed: make(chan struct{}),
+	}
+	ep.mu.Lock()
+	if ep.closed {
+		ep.mu.Unlock()
+		_ = conn.Close()
+		return
+	}
+	ep.conns[c] = struct{}{}
+	ep.mu.Unlock()
+	sctx.GetLogger().Infof("websocket client %s connected to %s", c.remote, ep.path)
+	go c.writeLoop()
+	go ep.readLoop(c)
+}
+
+func (ep *WebsocketEndpoint) readLoop(c *wsConn) {
+	defer ep.removeConn(c)
+	_ = c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNormalClosure) {
+				sctx.GetLogger().Warnf("websocket client %s of %s read error: %v", c.remote, ep.path, err)
+			}
+			return
+		}
+		msg := &WebsocketMessage{Data: data, RemoteAddr: c.remote}
+		ep.mu.RLock()
+		for id, ch := range ep.subscribers {
+			select {
+			case ch <- msg:
+			default:
+				sctx.GetLogger().Errorf("websocket endpoint %s drop message to %s", ep.path, id)
+			}
+		}
+		ep.mu.RUnlock()
+	}
+}
+
+func (c *wsConn) writeLoop() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		_ = c.conn.Close()
+	}()
+	for {
+		select {
+		case f := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(f.messageType, f.data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-c.closed:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			_ = c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}
+}
+
+func (c *wsConn) close() {
+	c.once.Do(func() {
+		close(c.closed)
+	})
+}
+
+func (ep *WebsocketEndpoint) removeConn(c *wsConn) {
+	ep.mu.Lock()
+	delete(ep.conns, c)
+	ep.mu.Unlock()
+	c.close()
+	sctx.GetLogger().Infof("websocket client %s disconnected from %s", c.remote, ep.path)
+}
+
+func (ep *WebsocketEndpoint) close() {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	ep.closed = true
+	for c := range ep.conns {
+		c.close()
+	}
+	ep.conns = make(map[*wsConn]struct{})
+	for id, ch := range ep.subscribers {
+		close(ch)
+		delete(ep.subscribers, id)
+	}
+}
+
+// Subscribe returns a channel to receive the messages sent by all the clients of the endpoint
+func (ep *WebsocketEndpoint) Subscribe(id string, bufferLength int) chan *WebsocketMessage {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	ch := make(chan *WebsocketMessage, bufferLength)
+	ep.subscribers[id] = ch
+	return ch
+}
+
+func (ep *WebsocketEndpoint) Unsubscribe(id string) {
+	ep.mu.Lock()
+	defer ep.mu.Unlock()
+	if ch, ok := ep.subscribers[id]; ok {
+		close(ch)
+		delete(ep.subscribers, id)
+	}
+}
+
+// Broadcast sends the data to all the connected clients without blocking. If the send queue of a client
+// is full, the message is dropped for that client, or the client is disconnected if closeSlow is set.
+// It returns the number of the clients which the message is queued to.
+func (ep *WebsocketEndpoint) Broadcast(messageType int, data []byte, closeSlow bool) int {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	sent := 0
+	for c := range ep.conns {
+		select {
+		case c.send <- wsFrame{messageType: messageType, data: data}:
+			sent++
+		default:
+			if closeSlow {
+				sctx.GetLogger().Warnf("websocket client %s of %s is too slow, disconnect it", c.remote, ep.path)
+				c.close()
+			} else {
+				sctx.GetLogger().Warnf("websocket client %s of %s is too slow, drop message", c.remote, ep.path)
+			}
+		}
+	}
+	return sent
+}
+
+// ConnCount returns the number of the connected clients
+func (ep *WebsocketEndpoint) ConnCount() int {
+	ep.mu.RLock()
+	defer ep.mu.RUnlock()
+	return len(ep.conns)
+}
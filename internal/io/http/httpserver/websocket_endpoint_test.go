@@ -0,0 +1,138 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package httpserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/testx"
+)
+
+func TestWebsocketEndpoint(t *testing.T) {
+	testx.InitEnv()
+	ep, _, err := RegisterWebsocketEndpoint("/ws/test", 2)
+	require.NoError(t, err)
+	sub := ep.Subscribe("test", 10)
+
+	var conn *websocket.Conn
+	// The data server is started asynchronously
+	for i := 0; i < 10; i++ {
+		conn, _, err = websocket.DefaultDialer.Dial("ws://localhost:10081/ws/test", nil)
+		if err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return ep.ConnCount() == 1 }, time.Second, 10*time.Millisecond)
+
+	// client to source
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"a":1}`)))
+	select {
+	case msg := <-sub:
+		assert.Equal(t, `{"a":1}`, string(msg.Data))
+		assert.NotEmpty(t, msg.RemoteAddr)
+	case <-time.After(time.Second):
+		t.Fatal("timeout to receive the client message")
+	}
+
+	// sink to client
+	assert.Equal(t, 1, ep.Broadcast(websocket.TextMessage, []byte("hello"), false))
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, data, err := conn.ReadMessage()
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(data))
+
+	// Register again to share the endpoint
+	ep2, _, err := RegisterWebsocketEndpoint("/ws/test", 10)
+	require.NoError(t, err)
+	assert.Same(t, ep, ep2)
+	UnregisterWebsocketEndpoint("/ws/test")
+	assert.Equal(t, 1, ep.ConnCount())
+
+	UnregisterWebsocketEndpoint("/ws/test")
+	_, opened := <-sub
+	assert.False(t, opened)
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure))
+}
+
+func TestWebsocketReRegister(t *testing.T) {
+	testx.InitEnv()
+	// keep the data server running between the registrations
+	require.NoError(t, registerInit())
+	defer unregisterRef()
+	_, _, err := RegisterWebsocketEndpoint("/ws/re", 0)
+	require.NoError(t, err)
+	UnregisterWebsocketEndpoint("/ws/re")
+	ep, _, err := RegisterWebsocketEndpoint("/ws/re", 0)
+	require.NoError(t, err)
+	defer UnregisterWebsocketEndpoint("/ws/re")
+
+	routes := 0
+	lock.RLock()
+	_ = router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		if tpl, _ := route.GetPathTemplate(); tpl == "/ws/re" {
+			routes++
+		}
+		return nil
+	})
+	lock.RUnlock()
+	assert.Equal(t, 1, routes)
+
+	var conn *websocket.Conn
+	for i := 0; i < 10; i++ {
+		conn, _, err = websocket.DefaultDialer.Dial("ws://localhost:10081/ws/re", nil)
+		if err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	require.NoError(t, err)
+	defer conn.Close()
+	require.Eventually(t, func() bool { return ep.ConnCount() == 1 }, time.Second, 10*time.Millisecond)
+}
+
+func TestWebsocketSlowClient(t *testing.T) {
+	ep := &WebsocketEndpoint{
+		path:        "/ws/slow",
+		sendBuffer:  1,
+		conns:       make(map[*wsConn]struct{}),
+		subscribers: make(map[string]chan *WebsocketMessage),
+	}
+	// The connection is not served, so its queue is never consumed
+	c := &wsConn{send: make(chan wsFrame, 1), closed: make(chan struct{})}
+	ep.conns[c] = struct{}{}
+	assert.Equal(t, 1, ep.Broadcast(websocket.TextMessage, []byte("1"), false))
+	assert.Equal(t, 0, ep.Broadcast(websocket.TextMessage, []byte("2"), false))
+	select {
+	case <-c.closed:
+		t.Fatal("slow client should not be closed with drop policy")
+	default:
+	}
+	assert.Equal(t, 0, ep.Broadcast(websocket.TextMessage, []byte("3"), true))
+	select {
+	case <-c.closed:
+	default:
+		t.Fatal("slow client should be closed with disconnect policy")
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/io/http/httpserver"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+type ReplyConf struct {
+	RequestIdField string `json:"requestIdField"`
+	ContentType    string `json:"contentType"`
+}
+
+// ReplySink sends the rule results as the responses of the sync httppush requests.
+// The result must have the request id which is selected from meta(requestId).
+type ReplySink struct {
+	conf *ReplyConf
+}
+
+func (rs *ReplySink) Configure(props map[string]interface{}) error {
+	cfg := &ReplyConf{
+		RequestIdField: httpserver.RequestIdMeta,
+		ContentType:    "application/json",
+	}
+	if err := cast.MapToStruct(props, cfg); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if cfg.RequestIdField == "" {
+		return fmt.Errorf("property requestIdField is required")
+	}
+	rs.conf = cfg
+	return nil
+}
+
+func (rs *ReplySink) Open(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Opening http reply sink")
+	return nil
+}
+
+func (rs *ReplySink) Collect(ctx api.StreamContext, item interface{}) error {
+	switch v := item.(type) {
+	case map[string]interface{}:
+		id, row, err := rs.splitId(v)
+		if err != nil {
+			return err
+		}
+		return rs.reply(ctx, id, row)
+	case []map[string]interface{}:
+		// The rows of a window may belong to different requests, group them by the request id
+		var ids []string
+		groups := make(map[string][]map[string]interface{})
+		for _, m := range v {
+			id, row, err := rs.splitId(m)
+			if err != nil {
+				return err
+			}
+			if _, ok := groups[id]; !ok {
+				ids = append(ids, id)
+			}
+			groups[id] = append(groups[id], row)
+		}
+		for _, id := range ids {
+			if err := rs.reply(ctx, id, groups[id]); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("http reply sink receives unsupported data %v", item)
+	}
+}
+
+// splitId returns the request id and a copy of the row without the request id field
+func (rs *ReplySink) splitId(m map[string]interface{}) (string, map[string]interface{}, error) {
+	v, ok := m[rs.conf.RequestIdField]
+	if !ok {
+		return "", nil, fmt.Errorf("result does not have the request id field %s, please select meta(requestId) as %s", rs.conf.RequestIdField, rs.conf.RequestIdField)
+	}
+	row := make(map[string]interface{}, len(m)-1)
+	for k, val := range m {
+		if k != rs.conf.RequestIdField {
+			row[k] = val
+		}
+	}
+	return cast.ToStringAlways(v), row, nil
+}
+
+func (rs *ReplySink) reply(ctx api.StreamContext, id string, data interface{}) error {
+	body, _, err := ctx.TransformOutput(data)
+	if err != nil {
+		return err
+	}
+	if !httpserver.ReplyRequest(id, &httpserver.Reply{ContentType: rs.conf.ContentType, Body: body}) {
+		ctx.GetLogger().Warnf("http request %s is not waiting for reply, it may have timed out", id)
+	}
+	return nil
+}
+
+func (rs *ReplySink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Closing http reply sink")
+	return nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package http
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/io/http/httpserver"
+	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
+	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+)
+
+func TestReplySink(t *testing.T) {
+	testx.InitEnv()
+	topic, _, err := httpserver.RegisterEndpointWithOptions("/rpc", &httpserver.EndpointOptions{
+		Method:       http.MethodPost,
+		Sync:         true,
+		ReplyTimeout: time.Second,
+	})
+	require.NoError(t, err)
+	defer httpserver.UnregisterEndpoint("/rpc", http.MethodPost)
+	ch := pubsub.CreateSub(topic, nil, "TestReplySink", 10)
+	defer pubsub.CloseSourceConsumerChannel(topic, "TestReplySink")
+
+	contextLogger := conf.Log.WithField("rule", "TestReplySink")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	tf, _ := transform.GenTransform("", "json", "", "", "", []string{})
+	ctx = context.WithValue(ctx, context.TransKey, tf)
+	rs := &ReplySink{}
+	require.NoError(t, rs.Configure(map[string]interface{}{}))
+	require.NoError(t, rs.Open(ctx))
+	// mock a rule which doubles the value
+	go func() {
+		tuple := <-ch
+		_ = rs.Collect(ctx, map[string]interface{}{
+			"requestId": tuple.Meta()["requestId"],
+			"b":         tuple.Message()["a"].(float64) * 2,
+		})
+	}()
+	time.Sleep(100 * time.Millisecond)
+	resp, err := http.Post("http://localhost:10081/rpc", "application/json", bytes.NewBufferString(`{"a":21}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.Equal(t, `{"b":42}`, string(body))
+
+	err = rs.Collect(ctx, map[string]interface{}{"b": 1})
+	assert.EqualError(t, err, "result does not have the request id field requestId, please select meta(requestId) as requestId")
+}
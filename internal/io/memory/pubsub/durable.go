@@ -0,0 +1,291 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+const (
+	PositionEarliest = "earliest"
+	PositionLatest   = "latest"
+	// purgeInterval is the min interval in milliseconds to check the retention time
+	purgeInterval = 1000
+	// DefaultRetentionSize is the retention size of a durable topic which is not configured by any producer
+	DefaultRetentionSize = 100000
+)
+
+// DurableOptions is the retention of a durable topic. The zero value means no limit.
+type DurableOptions struct {
+	// RetentionSize is the max number of the messages to keep
+	RetentionSize int64
+	// RetentionTime is the max age of the messages in milliseconds
+	RetentionTime int64
+}
+
+// durableRecord is the persisted form of a tuple
+type durableRecord struct {
+	Timestamp int64
+	Data      map[string]interface{}
+	Meta      map[string]interface{}
+	Rowkind   string
+	Keyval    interface{}
+}
+
+// DurableTopic saves the messages of a topic to the time series store with a sequence number as the key,
+// so that the consumers can replay the messages from any position and continue after restart.
+// The sequence numbers of the saved messages are contiguous in [first, last]. The topic is empty if first > last.
+type DurableTopic struct {
+	topic string
+	db    kv.Tskv
+	// configured is true if the options are set by a producer
+	configured bool
+	opts       DurableOptions
+	refCount   int
+
+	mu        sync.RWMutex
+	first     int64
+	last      int64
+	lastPurge int64
+	// notify is closed and replaced when new messages arrive
+	notify chan struct{}
+}
+
+var (
+	durableTopics = make(map[string]*DurableTopic)
+	durableMu     = sync.Mutex{}
+	// getTs is the function to get the store of a durable topic, replaced in tests
+	getTs          = store.GetTS
+	tableInvalidRe = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+func init() {
+	gob.Register(map[string]interface{}{})
+	gob.Register([]interface{}{})
+	gob.Register([]map[string]interface{}{})
+}
+
+// OpenDurableTopic gets or creates a durable topic. The producer provides the options and
+// the consumer provides nil. The options of the first producer take effect. Until then,
+// the topic keeps DefaultRetentionSize messages so that it does not grow without bound.
+func OpenDurableTopic(topic string, opts *DurableOptions) (*DurableTopic, error) {
+	durableMu.Lock()
+	defer durableMu.Unlock()
+	t, ok := durableTopics[topic]
+	if !ok {
+		db, err := getTs(durableTable(topic))
+		if err != nil {
+			return nil, fmt.Errorf("fail to open the store of durable memory topic %s: %v", topic, err)
+		}
+		t = &DurableTopic{
+			topic:  topic,
+			db:     db,
+			opts:   DurableOptions{RetentionSize: DefaultRetentionSize},
+			notify: make(chan struct{}),
+		}
+		if err := t.load(); err != nil {
+			return nil, err
+		}
+		durableTopics[topic] = t
+	}
+	if opts != nil && !t.configured {
+		t.opts = *opts
+		t.configured = true
+	}
+	t.refCount++
+	return t, nil
+}
+
+// CloseDurableTopic releases the topic. The saved messages are kept in the store.
+func CloseDurableTopic(topic string) {
+	durableMu.Lock()
+	defer durableMu.Unlock()
+	if t, ok := durableTopics[topic]; ok {
+		t.refCount--
+		if t.refCount <= 0 {
+			delete(durableTopics, topic)
+		}
+	}
+}
+
+func getDurableTopic(topic string) *DurableTopic {
+	durableMu.Lock()
+	defer durableMu.Unlock()
+	return durableTopics[topic]
+}
+
+// durableTable returns a valid table name for the topic. The hash avoids the conflict after replacing the invalid chars.
+func durableTable(topic string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(topic))
+	return fmt.Sprintf("memtopic_%s_%x", tableInvalidRe.ReplaceAllString(topic, "_"), h.Sum32())
+}
+
+// load finds the range of the saved messages
+func (t *DurableTopic) load() error {
+	rec := &durableRecord{}
+	last, err := t.db.Last(rec)
+	if err != nil {
+		return fmt.Errorf("fail to load durable memory topic %s: %v", t.topic, err)
+	}
+	t.last = last
+	if last == 0 {
+		t.first = 1
+		return nil
+	}
+	// The messages are only deleted from the head, so the first one can be found by binary search
+	lo, hi := int64(1), last
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		found, err := t.db.Get(mid, &durableRecord{})
+		if err != nil {
+			return fmt.Errorf("fail to load durable memory topic %s: %v", t.topic, err)
+		}
+		if found {
+			hi = mid
+		} else {
+			lo = mid + 1
+		}
+	}
+	t.first = lo
+	return nil
+}
+
+// append saves the message and purges the expired messages by the retention
+func (t *DurableTopic) append(data api.SourceTuple) error {
+	rec := &durableRecord{
+		Timestamp: data.Timestamp().UnixMilli(),
+		Data:      data.Message(),
+		Meta:      data.Meta(),
+	}
+	if ut, ok := data.(*UpdatableTuple); ok {
+		rec.Rowkind = ut.Rowkind
+		rec.Keyval = ut.Keyval
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	seq := t.last + 1
+	if _, err := t.db.Set(seq, rec); err != nil {
+		return fmt.Errorf("fail to save message to durable memory topic %s: %v", t.topic, err)
+	}
+	t.last = seq
+	first := t.first
+	if t.opts.RetentionSize > 0 && t.last-first+1 > t.opts.RetentionSize {
+		first = t.last - t.opts.RetentionSize + 1
+	}
+	if t.opts.RetentionTime > 0 {
+		now := conf.GetNowInMilli()
+		if now-t.lastPurge >= purgeInterval {
+			t.lastPurge = now
+			f, err := t.seekTime(first, now-t.opts.RetentionTime)
+			if err != nil {
+				return err
+			}
+			first = f
+		}
+	}
+	if first > t.first {
+		if err := t.db.DeleteBefore(first); err != nil {
+			return fmt.Errorf("fail to purge durable memory topic %s: %v", t.topic, err)
+		}
+		t.first = first
+	}
+	close(t.notify)
+	t.notify = make(chan struct{})
+	return nil
+}
+
+// seekTime finds the first message not earlier than the timestamp from the start sequence.
+// It returns last + 1 if all messages are earlier.
+func (t *DurableTopic) seekTime(start int64, ts int64) (int64, error) {
+	lo, hi := start, t.last+1
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		rec := &durableRecord{}
+		found, err := t.db.Get(mid, rec)
+		if err != nil {
+			return 0, fmt.Errorf("fail to read durable memory topic %s: %v", t.topic, err)
+		}
+		if !found || rec.Timestamp < ts {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// Seek returns the sequence to start consuming by the position which is earliest, latest or a timestamp in milliseconds
+func (t *DurableTopic) Seek(position string) (int64, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	switch position {
+	case PositionEarliest:
+		return t.first, nil
+	case PositionLatest, "":
+		return t.last + 1, nil
+	default:
+		ts, err := cast.ToInt64(position, cast.CONVERT_ALL)
+		if err != nil {
+			return 0, fmt.Errorf("invalid start position %s, must be earliest, latest or a timestamp in milliseconds", position)
+		}
+		return t.seekTime(t.first, ts)
+	}
+}
+
+// Read returns the message of the sequence and the actual sequence read. If the message has been purged,
+// the earliest message is returned instead. It returns nil if there is no new message.
+func (t *DurableTopic) Read(seq int64) (api.SourceTuple, int64, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if seq < t.first {
+		conf.Log.Warnf("messages before %d of durable memory topic %s are purged, skip to %d", seq, t.topic, t.first)
+		seq = t.first
+	}
+	if seq > t.last {
+		return nil, seq, nil
+	}
+	rec := &durableRecord{}
+	found, err := t.db.Get(seq, rec)
+	if err != nil {
+		return nil, seq, fmt.Errorf("fail to read durable memory topic %s: %v", t.topic, err)
+	}
+	if !found {
+		return nil, seq, fmt.Errorf("message %d of durable memory topic %s is missing", seq, t.topic)
+	}
+	st := api.NewDefaultSourceTupleWithTime(rec.Data, rec.Meta, time.UnixMilli(rec.Timestamp))
+	if rec.Rowkind != "" {
+		return &UpdatableTuple{DefaultSourceTuple: st, Rowkind: rec.Rowkind, Keyval: rec.Keyval}, seq, nil
+	}
+	return st, seq, nil
+}
+
+// Updated returns a channel which is closed when new messages arrive. Get it before reading to not miss any update.
+func (t *DurableTopic) Updated() <-chan struct{} {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.notify
+}
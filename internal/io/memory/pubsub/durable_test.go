@@ -0,0 +1,277 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pubsub
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/internal/pkg/store/encoding"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/kv"
+)
+
+// memTs is an in memory time series store which encodes the values like the real store
+type memTs struct {
+	sync.Mutex
+	data map[int64][]byte
+	last int64
+}
+
+func (m *memTs) Set(k int64, v interface{}) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	if k <= m.last {
+		return false, nil
+	}
+	b, err := encoding.Encode(v)
+	if err != nil {
+		return false, err
+	}
+	m.data[k] = b
+	m.last = k
+	return true, nil
+}
+
+func (m *memTs) Get(k int64, v interface{}) (bool, error) {
+	m.Lock()
+	defer m.Unlock()
+	b, ok := m.data[k]
+	if !ok {
+		return false, nil
+	}
+	return true, gob.NewDecoder(bytes.NewBuffer(b)).Decode(v)
+}
+
+func (m *memTs) Last(v interface{}) (int64, error) {
+	_, err := m.Get(m.last, v)
+	return m.last, err
+}
+
+func (m *memTs) Delete(k int64) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.data, k)
+	return nil
+}
+
+func (m *memTs) DeleteBefore(k int64) error {
+	m.Lock()
+	defer m.Unlock()
+	for key := range m.data {
+		if key < k {
+			delete(m.data, key)
+		}
+	}
+	return nil
+}
+
+func (m *memTs) Close() error { return nil }
+
+func (m *memTs) Drop() error { return nil }
+
+func (m *memTs) keys() []int64 {
+	m.Lock()
+	defer m.Unlock()
+	var keys []int64
+	for k := range m.data {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+func mockTs() map[string]*memTs {
+	stores := make(map[string]*memTs)
+	getTs = func(table string) (kv.Tskv, error) {
+		if _, ok := stores[table]; !ok {
+			stores[table] = &memTs{data: make(map[int64][]byte)}
+		}
+		return stores[table], nil
+	}
+	return stores
+}
+
+func TestDurableTable(t *testing.T) {
+	if durableTable("a/b") == durableTable("a_b") {
+		t.Errorf("table names conflict")
+	}
+	if exp, got := "memtopic_a_b_", durableTable("a/b")[:len("memtopic_a_b_")]; exp != got {
+		t.Errorf("table name mismatch, exp %s, got %s", exp, got)
+	}
+}
+
+func TestDurableTopic(t *testing.T) {
+	Reset()
+	stores := mockTs()
+	topic := "durable/t1"
+	dt, err := OpenDurableTopic(topic, &DurableOptions{RetentionSize: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	CreatePub(topic)
+	updated := dt.Updated()
+	ctx := mockContext.NewMockContext("rule1", "op1")
+	for i := 1; i <= 5; i++ {
+		ProduceTuple(ctx, topic, api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": int64(i), "tags": []interface{}{"a"}}, map[string]interface{}{"topic": topic}, time.UnixMilli(int64(i*1000))))
+	}
+	ProduceUpdatable(ctx, topic, map[string]interface{}{"id": int64(6)}, "delete", int64(6))
+	select {
+	case <-updated:
+	default:
+		t.Errorf("updated channel is not closed")
+	}
+	if exp, got := []int64{4, 5, 6}, stores[durableTable(topic)].keys(); !reflect.DeepEqual(exp, got) {
+		t.Errorf("retention mismatch, exp %v, got %v", exp, got)
+	}
+	tests := []struct {
+		position string
+		seq      int64
+	}{
+		{position: PositionEarliest, seq: 4},
+		{position: PositionLatest, seq: 7},
+		{position: "", seq: 7},
+		{position: "4500", seq: 5},
+		{position: "100", seq: 4},
+	}
+	for _, tt := range tests {
+		seq, err := dt.Seek(tt.position)
+		if err != nil {
+			t.Errorf("seek %s error: %v", tt.position, err)
+		} else if seq != tt.seq {
+			t.Errorf("seek %s mismatch, exp %d, got %d", tt.position, tt.seq, seq)
+		}
+	}
+	_, err = dt.Seek("yesterday")
+	if err == nil {
+		t.Errorf("expect error for invalid position")
+	}
+	// Read the purged message will skip to the earliest
+	tuple, seq, err := dt.Read(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := api.NewDefaultSourceTupleWithTime(map[string]interface{}{"id": int64(4), "tags": []interface{}{"a"}}, map[string]interface{}{"topic": topic}, time.UnixMilli(4000))
+	if seq != 4 || !reflect.DeepEqual(exp, tuple) {
+		t.Errorf("read mismatch, exp %v at 4, got %v at %d", exp, tuple, seq)
+	}
+	tuple, _, err = dt.Read(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ut, ok := tuple.(*UpdatableTuple); !ok || ut.Rowkind != "delete" || ut.Keyval != int64(6) {
+		t.Errorf("expect updatable tuple but got %v", tuple)
+	}
+	tuple, seq, err = dt.Read(7)
+	if err != nil || tuple != nil || seq != 7 {
+		t.Errorf("expect no new message but got %v, %d, %v", tuple, seq, err)
+	}
+	RemovePub(topic)
+	CloseDurableTopic(topic)
+	// Reload from the store like restarting
+	Reset()
+	dt, err = OpenDurableTopic(topic, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer CloseDurableTopic(topic)
+	if dt.first != 4 || dt.last != 6 {
+		t.Errorf("reload mismatch, exp [4, 6], got [%d, %d]", dt.first, dt.last)
+	}
+}
+
+func TestDurableRetentionTime(t *testing.T) {
+	Reset()
+	mockTs()
+	topic := "durable/t2"
+	dt, err := OpenDurableTopic(topic, &DurableOptions{RetentionTime: 2000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer CloseDurableTopic(topic)
+	mc := conf.Clock.(*clock.Mock)
+	ctx := mockContext.NewMockContext("rule1", "op1")
+	for i := 1; i <= 5; i++ {
+		mc.Set(time.UnixMilli(int64(i * 1000)))
+		Produce(ctx, topic, map[string]interface{}{"id": i})
+	}
+	// At 5000, the messages before 3000 are purged
+	if dt.first != 3 || dt.last != 5 {
+		t.Errorf("retention mismatch, exp [3, 5], got [%d, %d]", dt.first, dt.last)
+	}
+}
+
+// errTs fails to save any message
+type errTs struct {
+	memTs
+}
+
+func (m *errTs) Set(int64, interface{}) (bool, error) {
+	return false, errors.New("disk full")
+}
+
+func TestDurableAppendError(t *testing.T) {
+	Reset()
+	origTs := getTs
+	defer func() { getTs = origTs }()
+	getTs = func(string) (kv.Tskv, error) {
+		return &errTs{memTs{data: make(map[int64][]byte)}}, nil
+	}
+	topic := "durable/t3"
+	_, err := OpenDurableTopic(topic, &DurableOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer CloseDurableTopic(topic)
+	ctx := mockContext.NewMockContext("rule1", "op1")
+	err = Produce(ctx, topic, map[string]interface{}{"id": 1})
+	if err == nil {
+		t.Errorf("expect error when the message is not saved")
+	}
+}
+
+func TestDurableDefaultRetention(t *testing.T) {
+	Reset()
+	mockTs()
+	topic := "durable/t4"
+	// Only the consumer opens the topic
+	dt, err := OpenDurableTopic(topic, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (DurableOptions{RetentionSize: DefaultRetentionSize}); dt.opts != exp {
+		t.Errorf("default retention mismatch, exp %v, got %v", exp, dt.opts)
+	}
+	// The producer overrides the default
+	_, err = OpenDurableTopic(topic, &DurableOptions{RetentionTime: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := (DurableOptions{RetentionTime: 1000}); dt.opts != exp {
+		t.Errorf("retention mismatch, exp %v, got %v", exp, dt.opts)
+	}
+	CloseDurableTopic(topic)
+	CloseDurableTopic(topic)
+}
@@ -110,22 +110,35 @@ func RemovePub(topic string) {
 	}
 }
 
-func Produce(ctx api.StreamContext, topic string, data map[string]interface{}) {
-	doProduce(ctx, topic, api.NewDefaultSourceTupleWithTime(data, map[string]interface{}{"topic": topic}, conf.GetNow()))
+func Produce(ctx api.StreamContext, topic string, data map[string]interface{}) error {
+	return doProduce(ctx, topic, api.NewDefaultSourceTupleWithTime(data, map[string]interface{}{"topic": topic}, conf.GetNow()))
 }
 
-func ProduceUpdatable(ctx api.StreamContext, topic string, data map[string]interface{}, rowkind string, keyval interface{}) {
-	doProduce(ctx, topic, &UpdatableTuple{
+func ProduceUpdatable(ctx api.StreamContext, topic string, data map[string]interface{}, rowkind string, keyval interface{}) error {
+	return doProduce(ctx, topic, &UpdatableTuple{
 		DefaultSourceTuple: api.NewDefaultSourceTupleWithTime(data, map[string]interface{}{"topic": topic}, conf.GetNow()),
 		Rowkind:            rowkind,
 		Keyval:             keyval,
 	})
 }
 
-func doProduce(ctx api.StreamContext, topic string, data api.SourceTuple) {
+// ProduceTuple produces a tuple with customized meta
+func ProduceTuple(ctx api.StreamContext, topic string, data api.SourceTuple) error {
+	return doProduce(ctx, topic, data)
+}
+
+// doProduce saves the data to the durable topic if any and broadcasts it. It returns the error of saving
+// so that the producer knows the data is lost.
+func doProduce(ctx api.StreamContext, topic string, data api.SourceTuple) error {
+	// save to the durable topic even if there is no consumer now
+	if dt := getDurableTopic(topic); dt != nil {
+		if err := dt.append(data); err != nil {
+			return err
+		}
+	}
 	c, exists := pubTopics[topic]
 	if !exists {
-		return
+		return nil
 	}
 	logger := ctx.GetLogger()
 	mu.RLock()
@@ -141,9 +154,11 @@ func doProduce(ctx api.StreamContext, topic string, data api.SourceTuple) {
 			logger.Errorf("memory source topic %s drop message to %s", topic, name)
 		}
 	}
+	return nil
 }
 
-func ProduceError(ctx api.StreamContext, topic string, err error) {
+// ProduceError broadcasts the error to the consumers of the topic. The payload is the raw data failing to decode, if any.
+func ProduceError(ctx api.StreamContext, topic string, err error, payload []byte) {
 	c, exists := pubTopics[topic]
 	if !exists {
 		return
@@ -154,7 +169,7 @@ func ProduceError(ctx api.StreamContext, topic string, err error) {
 	// broadcast to all consumers
 	for name, out := range c.consumers {
 		select {
-		case out <- &xsql.ErrorSourceTuple{Error: err}:
+		case out <- &xsql.ErrorSourceTuple{Error: err, Payload: payload}:
 			logger.Debugf("memory source broadcast error from topic %s to %s done", topic, name)
 		case <-ctx.Done():
 			// rule stop so stop waiting
@@ -194,4 +209,5 @@ func removePubConsumer(topic string, sourceId string, c *pubConsumers) {
 func Reset() {
 	pubTopics = make(map[string]*pubConsumers)
 	subExps = make(map[string]*subChan)
+	durableTopics = make(map[string]*DurableTopic)
 }
@@ -33,6 +33,11 @@ type config struct {
 	KeyField     string   `json:"keyField"`
 	Fields       []string `json:"fields"`
 	DataField    string   `json:"dataField"`
+	Durable      bool     `json:"durable"`
+	// RetentionSize is the max number of messages to keep for durable topic
+	RetentionSize int64 `json:"retentionSize"`
+	// RetentionTime is the max age of the messages in milliseconds for durable topic
+	RetentionTime int64 `json:"retentionTime"`
 }
 
 type sink struct {
@@ -42,16 +47,22 @@ type sink struct {
 	rowkindField string
 	fields       []string
 	dataField    string
+	durable      *pubsub.DurableOptions
 }
 
 func (s *sink) Open(ctx api.StreamContext) error {
 	ctx.GetLogger().Debugf("Opening memory sink: %v", s.topic)
+	if s.durable != nil {
+		if _, err := pubsub.OpenDurableTopic(s.topic, s.durable); err != nil {
+			return err
+		}
+	}
 	pubsub.CreatePub(s.topic)
 	return nil
 }
 
 func (s *sink) Configure(props map[string]interface{}) error {
-	cfg := &config{}
+	cfg := &config{RetentionSize: pubsub.DefaultRetentionSize}
 	err := cast.MapToStruct(props, cfg)
 	if err != nil {
 		return err
@@ -70,6 +81,18 @@ func (s *sink) Configure(props map[string]interface{}) error {
 	if s.rowkindField != "" && s.keyField == "" {
 		return fmt.Errorf("keyField is required when rowkindField is set")
 	}
+	if cfg.Durable {
+		if strings.Contains(cfg.Topic, "{{") {
+			return fmt.Errorf("durable memory topic %s cannot be a template", cfg.Topic)
+		}
+		if cfg.RetentionSize < 0 || cfg.RetentionTime < 0 {
+			return fmt.Errorf("retentionSize and retentionTime must not be negative")
+		}
+		s.durable = &pubsub.DurableOptions{
+			RetentionSize: cfg.RetentionSize,
+			RetentionTime: cfg.RetentionTime,
+		}
+	}
 	return nil
 }
 
@@ -119,6 +142,9 @@ func (s *sink) Collect(ctx api.StreamContext, data interface{}) error {
 func (s *sink) Close(ctx api.StreamContext) error {
 	ctx.GetLogger().Debugf("closing memory sink")
 	pubsub.RemovePub(s.topic)
+	if s.durable != nil {
+		pubsub.CloseDurableTopic(s.topic)
+	}
 	return nil
 }
 
@@ -141,9 +167,7 @@ func (s *sink) publish(ctx api.StreamContext, topic string, el map[string]interf
 		if !ok {
 			return fmt.Errorf("key field %s not found in data %v", s.keyField, el)
 		}
-		pubsub.ProduceUpdatable(ctx, topic, el, rowkind, key)
-	} else {
-		pubsub.Produce(ctx, topic, el)
+		return pubsub.ProduceUpdatable(ctx, topic, el, rowkind, key)
 	}
-	return nil
+	return pubsub.Produce(ctx, topic, el)
 }
@@ -18,19 +18,30 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync/atomic"
 
 	"github.com/lf-edge/ekuiper/internal/io/memory/pubsub"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
 )
 
 type source struct {
 	topic        string
 	topicRegex   *regexp.Regexp
 	bufferLength int
+	// durable is true to consume the durable topic from the start position
+	durable       bool
+	startPosition string
+	// offset is the sequence of the last consumed message of the durable topic, 0 means not consumed yet
+	offset atomic.Int64
 }
 
-func (s *source) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, _ chan<- error) {
+func (s *source) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	if s.durable {
+		s.openDurable(ctx, consumer, errCh)
+		return
+	}
 	ch := pubsub.CreateSub(s.topic, s.topicRegex, fmt.Sprintf("%s_%s_%d", ctx.GetRuleId(), ctx.GetOpId(), ctx.GetInstanceId()), s.bufferLength)
 	for {
 		select {
@@ -45,6 +56,47 @@ func (s *source) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, _
 	}
 }
 
+func (s *source) openDurable(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	t, err := pubsub.OpenDurableTopic(s.topic, nil)
+	if err != nil {
+		infra.DrainError(ctx, err, errCh)
+		return
+	}
+	defer pubsub.CloseDurableTopic(s.topic)
+	seq := s.offset.Load() + 1
+	if seq == 1 {
+		seq, err = t.Seek(s.startPosition)
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+			return
+		}
+	}
+	ctx.GetLogger().Infof("memory source consumes durable topic %s from %d", s.topic, seq)
+	for {
+		updated := t.Updated()
+		tuple, read, err := t.Read(seq)
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+			return
+		}
+		if tuple == nil {
+			select {
+			case <-updated:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		select {
+		case consumer <- tuple:
+			s.offset.Store(read)
+			seq = read + 1
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 func (s *source) Configure(datasource string, props map[string]interface{}) error {
 	s.topic = datasource
 	s.bufferLength = 1024
@@ -53,7 +105,27 @@ func (s *source) Configure(datasource string, props map[string]interface{}) erro
 			s.bufferLength = bl
 		}
 	}
+	if c, ok := props["durable"]; ok {
+		d, err := cast.ToBool(c, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			return fmt.Errorf("invalid durable %v: %v", c, err)
+		}
+		s.durable = d
+	}
+	if c, ok := props["startPosition"]; ok {
+		s.startPosition = cast.ToStringAlways(c)
+		switch s.startPosition {
+		case pubsub.PositionEarliest, pubsub.PositionLatest:
+		default:
+			if _, err := cast.ToInt64(s.startPosition, cast.CONVERT_ALL); err != nil {
+				return fmt.Errorf("invalid startPosition %s, must be earliest, latest or a timestamp in milliseconds", s.startPosition)
+			}
+		}
+	}
 	if strings.ContainsAny(datasource, "+#") {
+		if s.durable {
+			return fmt.Errorf("durable memory source does not support topic wildcard %s", datasource)
+		}
 		r, err := getRegexp(datasource)
 		if err != nil {
 			return err
@@ -63,6 +135,24 @@ func (s *source) Configure(datasource string, props map[string]interface{}) erro
 	return nil
 }
 
+// GetOffset returns the sequence of the last consumed message of the durable topic
+func (s *source) GetOffset() (interface{}, error) {
+	if !s.durable {
+		return nil, nil
+	}
+	return s.offset.Load(), nil
+}
+
+// Rewind continues consuming the durable topic after the offset
+func (s *source) Rewind(offset interface{}) error {
+	o, err := cast.ToInt64(offset, cast.CONVERT_SAMEKIND)
+	if err != nil {
+		return fmt.Errorf("invalid memory source offset %v", offset)
+	}
+	s.offset.Store(o)
+	return nil
+}
+
 func getRegexp(topic string) (*regexp.Regexp, error) {
 	if len(topic) == 0 {
 		return nil, fmt.Errorf("invalid empty topic")
@@ -80,6 +170,9 @@ func getRegexp(topic string) (*regexp.Regexp, error) {
 
 func (s *source) Close(ctx api.StreamContext) error {
 	ctx.GetLogger().Debugf("closing memory source")
+	if s.durable {
+		return nil
+	}
 	pubsub.CloseSourceConsumerChannel(s.topic, fmt.Sprintf("%s_%s_%d", ctx.GetRuleId(), ctx.GetOpId(), ctx.GetInstanceId()))
 	return nil
 }
@@ -88,3 +88,42 @@ func TestTopic(t *testing.T) {
 		}
 	}
 }
+
+func TestDurableConfigure(t *testing.T) {
+	tests := []struct {
+		topic string
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			topic: "a/b",
+			props: map[string]interface{}{"durable": true, "startPosition": "earliest"},
+		}, {
+			topic: "a/b",
+			props: map[string]interface{}{"durable": true, "startPosition": 1672531200000},
+		}, {
+			topic: "a/b",
+			props: map[string]interface{}{"durable": true, "startPosition": "yesterday"},
+			err:   "invalid startPosition yesterday, must be earliest, latest or a timestamp in milliseconds",
+		}, {
+			topic: "a/#",
+			props: map[string]interface{}{"durable": true},
+			err:   "durable memory source does not support topic wildcard a/#",
+		},
+	}
+	for i, tt := range tests {
+		s := &source{}
+		err := s.Configure(tt.topic, tt.props)
+		if !reflect.DeepEqual(tt.err, testx.Errstring(err)) {
+			t.Errorf("%d: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.err, err)
+		}
+	}
+	s := &source{}
+	_ = s.Configure("a/b", map[string]interface{}{"durable": true})
+	if err := s.Rewind(int64(10)); err != nil {
+		t.Fatal(err)
+	}
+	if offset, _ := s.GetOffset(); offset != int64(10) {
+		t.Errorf("offset mismatch, got %v", offset)
+	}
+}
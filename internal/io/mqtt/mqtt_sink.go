@@ -16,6 +16,7 @@ package mqtt
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/lf-edge/ekuiper/internal/compressor"
 	"github.com/lf-edge/ekuiper/internal/topo/connection/clients"
@@ -31,6 +32,15 @@ type AdConf struct {
 	Qos         byte   `json:"qos"`
 	Retained    bool   `json:"retained"`
 	Compression string `json:"compression"`
+	// MQTT v5 properties, the string values can be data templates
+	UserProperties  map[string]string `json:"userProperties"`
+	ResponseTopic   string            `json:"responseTopic"`
+	CorrelationData string            `json:"correlationData"`
+	ContentType     string            `json:"contentType"`
+	MessageExpiry   uint32            `json:"messageExpiry"`
+	// Batch publishes the rows of a window in one message per topic
+	Batch         bool `json:"batch"`
+	BatchMaxBytes int  `json:"batchMaxBytes"`
 }
 
 type MQTTSink struct {
@@ -38,6 +48,16 @@ type MQTTSink struct {
 	config     map[string]interface{}
 	cli        api.MessageClient
 	compressor message.Compressor
+	// qos and retained templates which are evaluated for each message
+	qosTemplate      string
+	retainedTemplate string
+}
+
+// publishGroup is the rows of a batch which share the same topic, qos and retained flag
+type publishGroup struct {
+	topic string
+	para  map[string]interface{}
+	rows  []map[string]interface{}
 }
 
 func (ms *MQTTSink) hasKeys(str []string, ps map[string]interface{}) bool {
@@ -51,15 +71,43 @@ func (ms *MQTTSink) hasKeys(str []string, ps map[string]interface{}) bool {
 
 func (ms *MQTTSink) Configure(ps map[string]interface{}) error {
 	adconf := &AdConf{}
-	cast.MapToStruct(ps, adconf)
+	props := ps
+	qosTmpl, isQosTmpl := ps["qos"].(string)
+	retainedTmpl, isRetainedTmpl := ps["retained"].(string)
+	if isQosTmpl || isRetainedTmpl {
+		props = make(map[string]interface{}, len(ps))
+		for k, v := range ps {
+			props[k] = v
+		}
+		delete(props, "qos")
+		delete(props, "retained")
+	}
+	cast.MapToStruct(props, adconf)
 
 	if adconf.Tpc == "" {
 		return fmt.Errorf("mqtt sink is missing property topic")
 	}
+	var err error
+	if isQosTmpl {
+		if isTemplate(qosTmpl) {
+			ms.qosTemplate = qosTmpl
+		} else if adconf.Qos, err = parseQos(qosTmpl); err != nil {
+			return err
+		}
+	}
 	if adconf.Qos != 0 && adconf.Qos != 1 && adconf.Qos != 2 {
 		return fmt.Errorf("invalid qos value %v, the value could be only int 0 or 1 or 2", adconf.Qos)
 	}
-	var err error
+	if isRetainedTmpl {
+		if isTemplate(retainedTmpl) {
+			ms.retainedTemplate = retainedTmpl
+		} else if adconf.Retained, err = parseRetained(retainedTmpl); err != nil {
+			return err
+		}
+	}
+	if adconf.BatchMaxBytes < 0 {
+		return fmt.Errorf("invalid batchMaxBytes %d, the value must not be negative", adconf.BatchMaxBytes)
+	}
 	if adconf.Compression != "" {
 		ms.compressor, err = compressor.GetCompressor(adconf.Compression)
 		if err != nil {
@@ -84,35 +132,160 @@ func (ms *MQTTSink) Open(ctx api.StreamContext) error {
 }
 
 func (ms *MQTTSink) Collect(ctx api.StreamContext, item interface{}) error {
-	logger := ctx.GetLogger()
-	jsonBytes, _, err := ctx.TransformOutput(item)
+	if rows, ok := item.([]map[string]interface{}); ok && ms.adconf.Batch {
+		return ms.collectBatch(ctx, rows)
+	}
+	tpc, para, err := ms.publishParams(ctx, item)
 	if err != nil {
 		return err
 	}
-	logger.Debugf("%s publish %s", ctx.GetOpId(), jsonBytes)
-	if ms.compressor != nil {
-		jsonBytes, err = ms.compressor.Compress(jsonBytes)
+	jsonBytes, err := ms.encode(ctx, item)
+	if err != nil {
+		return err
+	}
+	return ms.publish(ctx, tpc, jsonBytes, para)
+}
+
+// collectBatch evaluates the topic, qos and retained flag for each row and publishes the rows sharing
+// the same values in one message. The v5 properties of a group are evaluated by its first row.
+func (ms *MQTTSink) collectBatch(ctx api.StreamContext, rows []map[string]interface{}) error {
+	var groups []*publishGroup
+	index := make(map[string]*publishGroup)
+	for _, row := range rows {
+		tpc, para, err := ms.publishParams(ctx, row)
 		if err != nil {
 			return err
 		}
+		key := fmt.Sprintf("%s|%v|%v", tpc, para["qos"], para["retained"])
+		g, ok := index[key]
+		if !ok {
+			g = &publishGroup{topic: tpc, para: para}
+			index[key] = g
+			groups = append(groups, g)
+		}
+		g.rows = append(g.rows, row)
+	}
+	for _, g := range groups {
+		if err := ms.publishRows(ctx, g, g.rows); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	tpc, err := ctx.ParseTemplate(ms.adconf.Tpc, item)
+// publishRows publishes the rows in one message. If the payload exceeds batchMaxBytes, the rows are split
+// into halves until each message fits. A single row exceeding the limit is still sent.
+func (ms *MQTTSink) publishRows(ctx api.StreamContext, g *publishGroup, rows []map[string]interface{}) error {
+	payload, err := ms.encode(ctx, rows)
 	if err != nil {
 		return err
 	}
+	if ms.adconf.BatchMaxBytes > 0 && len(payload) > ms.adconf.BatchMaxBytes {
+		if len(rows) > 1 {
+			half := len(rows) / 2
+			if err := ms.publishRows(ctx, g, rows[:half]); err != nil {
+				return err
+			}
+			return ms.publishRows(ctx, g, rows[half:])
+		}
+		ctx.GetLogger().Warnf("payload size %d of a single row exceeds batchMaxBytes %d", len(payload), ms.adconf.BatchMaxBytes)
+	}
+	return ms.publish(ctx, g.topic, payload, g.para)
+}
 
-	para := map[string]interface{}{
-		"qos":      ms.adconf.Qos,
-		"retained": ms.adconf.Retained,
+func (ms *MQTTSink) encode(ctx api.StreamContext, item interface{}) ([]byte, error) {
+	jsonBytes, _, err := ctx.TransformOutput(item)
+	if err != nil {
+		return nil, err
+	}
+	ctx.GetLogger().Debugf("%s publish %s", ctx.GetOpId(), jsonBytes)
+	if ms.compressor != nil {
+		jsonBytes, err = ms.compressor.Compress(jsonBytes)
+		if err != nil {
+			return nil, err
+		}
 	}
+	return jsonBytes, nil
+}
 
-	if err := ms.cli.Publish(ctx, tpc, jsonBytes, para); err != nil {
+func (ms *MQTTSink) publish(ctx api.StreamContext, tpc string, payload []byte, para map[string]interface{}) error {
+	if err := ms.cli.Publish(ctx, tpc, payload, para); err != nil {
 		return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
 	}
 	return nil
 }
 
+// publishParams evaluates the topic, qos, retained flag and v5 properties by the data
+func (ms *MQTTSink) publishParams(ctx api.StreamContext, data interface{}) (string, map[string]interface{}, error) {
+	tpc, err := ctx.ParseTemplate(ms.adconf.Tpc, data)
+	if err != nil {
+		return "", nil, err
+	}
+	qos := ms.adconf.Qos
+	if ms.qosTemplate != "" {
+		v, err := ctx.ParseTemplate(ms.qosTemplate, data)
+		if err != nil {
+			return "", nil, fmt.Errorf("parse template for qos error: %v", err)
+		}
+		if qos, err = parseQos(v); err != nil {
+			return "", nil, err
+		}
+	}
+	retained := ms.adconf.Retained
+	if ms.retainedTemplate != "" {
+		v, err := ctx.ParseTemplate(ms.retainedTemplate, data)
+		if err != nil {
+			return "", nil, fmt.Errorf("parse template for retained error: %v", err)
+		}
+		if retained, err = parseRetained(v); err != nil {
+			return "", nil, err
+		}
+	}
+	para := map[string]interface{}{
+		"qos":      qos,
+		"retained": retained,
+	}
+	if err := ms.setProperties(ctx, data, para); err != nil {
+		return "", nil, err
+	}
+	return tpc, para, nil
+}
+
+// setProperties parses the templates of the v5 properties and puts them into the publish params
+func (ms *MQTTSink) setProperties(ctx api.StreamContext, item interface{}, para map[string]interface{}) error {
+	if len(ms.adconf.UserProperties) > 0 {
+		up := make(map[string]string, len(ms.adconf.UserProperties))
+		for k, v := range ms.adconf.UserProperties {
+			pv, err := ctx.ParseTemplate(v, item)
+			if err != nil {
+				return fmt.Errorf("parse template for user property %s error: %v", k, err)
+			}
+			up[k] = pv
+		}
+		para["userProperties"] = up
+	}
+	for key, tmpl := range map[string]string{
+		"responseTopic":   ms.adconf.ResponseTopic,
+		"correlationData": ms.adconf.CorrelationData,
+	} {
+		if tmpl == "" {
+			continue
+		}
+		v, err := ctx.ParseTemplate(tmpl, item)
+		if err != nil {
+			return fmt.Errorf("parse template for %s error: %v", key, err)
+		}
+		para[key] = v
+	}
+	if ms.adconf.ContentType != "" {
+		para["contentType"] = ms.adconf.ContentType
+	}
+	if ms.adconf.MessageExpiry > 0 {
+		para["messageExpiry"] = ms.adconf.MessageExpiry
+	}
+	return nil
+}
+
 func (ms *MQTTSink) Close(ctx api.StreamContext) error {
 	logger := ctx.GetLogger()
 	logger.Infof("Closing mqtt sink")
@@ -121,3 +294,28 @@ func (ms *MQTTSink) Close(ctx api.StreamContext) error {
 	}
 	return nil
 }
+
+func isTemplate(s string) bool {
+	return strings.Contains(s, "{{")
+}
+
+func parseQos(s string) (byte, error) {
+	switch strings.TrimSpace(s) {
+	case "0":
+		return 0, nil
+	case "1":
+		return 1, nil
+	case "2":
+		return 2, nil
+	default:
+		return 0, fmt.Errorf("invalid qos value %s, the value could be only int 0 or 1 or 2", s)
+	}
+}
+
+func parseRetained(s string) (bool, error) {
+	r, err := cast.ToBool(strings.TrimSpace(s), cast.CONVERT_ALL)
+	if err != nil {
+		return false, fmt.Errorf("invalid retained value %s, the value could be only true or false", s)
+	}
+	return r, nil
+}
@@ -18,6 +18,11 @@ import (
 	"fmt"
 	"reflect"
 	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
 func TestSinkConfigure(t *testing.T) {
@@ -95,3 +100,171 @@ func TestSinkConfigure(t *testing.T) {
 		})
 	}
 }
+
+func TestSinkProperties(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "TestSinkProperties")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	ms := &MQTTSink{}
+	err := ms.Configure(map[string]interface{}{
+		"topic":           "data",
+		"userProperties":  map[string]interface{}{"device": "{{.id}}", "site": "s1"},
+		"responseTopic":   "reply/{{.id}}",
+		"correlationData": "{{.seq}}",
+		"contentType":     "application/json",
+		"messageExpiry":   60,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	para := map[string]interface{}{}
+	err = ms.setProperties(ctx, map[string]interface{}{"id": "d1", "seq": 3}, para)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := map[string]interface{}{
+		"userProperties":  map[string]string{"device": "d1", "site": "s1"},
+		"responseTopic":   "reply/d1",
+		"correlationData": "3",
+		"contentType":     "application/json",
+		"messageExpiry":   uint32(60),
+	}
+	if !reflect.DeepEqual(para, exp) {
+		t.Errorf("\n Expected params: \t%v\n \t\t\tgot: \t%v", exp, para)
+	}
+}
+
+type published struct {
+	topic    string
+	payload  string
+	qos      interface{}
+	retained interface{}
+}
+
+type mockPublisher struct {
+	msgs []published
+}
+
+func (m *mockPublisher) Subscribe(_ api.StreamContext, _ []api.TopicChannel, _ chan error, _ map[string]interface{}) error {
+	return nil
+}
+
+func (m *mockPublisher) Publish(_ api.StreamContext, topic string, message []byte, params map[string]interface{}) error {
+	m.msgs = append(m.msgs, published{topic: topic, payload: string(message), qos: params["qos"], retained: params["retained"]})
+	return nil
+}
+
+func TestSinkTemplateConfigure(t *testing.T) {
+	ms := &MQTTSink{}
+	err := ms.Configure(map[string]interface{}{
+		"topic":    "devices/{{.id}}/alarm",
+		"qos":      "{{.level}}",
+		"retained": "true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms.qosTemplate != "{{.level}}" || ms.retainedTemplate != "" || !ms.adconf.Retained {
+		t.Errorf("unexpected templates qos %s, retained %s, static retained %v", ms.qosTemplate, ms.retainedTemplate, ms.adconf.Retained)
+	}
+	err = (&MQTTSink{}).Configure(map[string]interface{}{
+		"topic": "data",
+		"qos":   "3",
+	})
+	if !reflect.DeepEqual(err, fmt.Errorf("invalid qos value 3, the value could be only int 0 or 1 or 2")) {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestSinkCollectTemplate(t *testing.T) {
+	tests := []struct {
+		name  string
+		props map[string]interface{}
+		data  interface{}
+		exp   []published
+		err   string
+	}{
+		{
+			name: "dynamic topic and qos",
+			props: map[string]interface{}{
+				"topic":    "devices/{{.id}}/alarm",
+				"qos":      "{{.level}}",
+				"retained": "{{if gt .level 1}}true{{else}}false{{end}}",
+			},
+			data: map[string]interface{}{"id": "d1", "level": 2},
+			exp: []published{
+				{topic: "devices/d1/alarm", payload: `{"id":"d1","level":2}`, qos: byte(2), retained: true},
+			},
+		},
+		{
+			name: "invalid qos",
+			props: map[string]interface{}{
+				"topic": "data",
+				"qos":   "{{.level}}",
+			},
+			data: map[string]interface{}{"id": "d1", "level": 5},
+			err:  "invalid qos value 5, the value could be only int 0 or 1 or 2",
+		},
+		{
+			name: "batch by topic",
+			props: map[string]interface{}{
+				"topic": "devices/{{.id}}",
+				"qos":   1,
+				"batch": true,
+			},
+			data: []map[string]interface{}{
+				{"id": "d1", "v": 1},
+				{"id": "d2", "v": 2},
+				{"id": "d1", "v": 3},
+			},
+			exp: []published{
+				{topic: "devices/d1", payload: `[{"id":"d1","v":1},{"id":"d1","v":3}]`, qos: byte(1), retained: false},
+				{topic: "devices/d2", payload: `[{"id":"d2","v":2}]`, qos: byte(1), retained: false},
+			},
+		},
+		{
+			name: "batch with size limit",
+			props: map[string]interface{}{
+				"topic":         "data",
+				"batch":         true,
+				"batchMaxBytes": 30,
+			},
+			data: []map[string]interface{}{
+				{"id": "d1", "v": 1},
+				{"id": "d2", "v": 2},
+				{"id": "d3", "v": 3},
+			},
+			exp: []published{
+				{topic: "data", payload: `[{"id":"d1","v":1}]`, qos: byte(0), retained: false},
+				{topic: "data", payload: `[{"id":"d2","v":2}]`, qos: byte(0), retained: false},
+				{topic: "data", payload: `[{"id":"d3","v":3}]`, qos: byte(0), retained: false},
+			},
+		},
+	}
+	contextLogger := conf.Log.WithField("rule", "TestSinkCollectTemplate")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	tf, _ := transform.GenTransform("", "json", "", "", "", []string{})
+	vCtx := context.WithValue(ctx, context.TransKey, tf)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ms := &MQTTSink{}
+			if err := ms.Configure(tt.props); err != nil {
+				t.Fatal(err)
+			}
+			cli := &mockPublisher{}
+			ms.cli = cli
+			err := ms.Collect(vCtx, tt.data)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("\n Expected error: \t%v\n \t\t\tgot: \t%v", tt.err, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(cli.msgs, tt.exp) {
+				t.Errorf("\n Expected messages: \t%v\n \t\t\tgot: \t%v", tt.exp, cli.msgs)
+			}
+		})
+	}
+}
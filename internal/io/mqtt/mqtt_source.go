@@ -44,6 +44,11 @@ type MQTTSource struct {
 	decompressor message.Decompressor
 }
 
+// v5Message is implemented by the messages received from MQTT v5 connections to expose the v5 properties
+type v5Message interface {
+	Properties() map[string]interface{}
+}
+
 type MQTTConfig struct {
 	Format            string `json:"format"`
 	Qos               int    `json:"qos"`
@@ -171,7 +176,8 @@ func getTuples(ctx api.StreamContext, ms *MQTTSource, env interface{}) []api.Sou
 		if err != nil {
 			return []api.SourceTuple{
 				&xsql.ErrorSourceTuple{
-					Error: fmt.Errorf("can not decompress mqtt message %v.", err),
+					Error:   fmt.Errorf("can not decompress mqtt message %v.", err),
+					Payload: msg.Payload(),
 				},
 			}
 		}
@@ -181,13 +187,19 @@ func getTuples(ctx api.StreamContext, ms *MQTTSource, env interface{}) []api.Sou
 	if e != nil {
 		return []api.SourceTuple{
 			&xsql.ErrorSourceTuple{
-				Error: fmt.Errorf("Invalid data format, cannot decode %s with error %s", string(msg.Payload()), e),
+				Error:   fmt.Errorf("Invalid data format, cannot decode %s with error %s", string(msg.Payload()), e),
+				Payload: msg.Payload(),
 			},
 		}
 	}
 	meta := make(map[string]interface{})
 	meta["topic"] = msg.Topic()
 	meta["messageid"] = strconv.Itoa(int(msg.MessageID()))
+	if m5, ok := msg.(v5Message); ok {
+		for k, v := range m5.Properties() {
+			meta[k] = v
+		}
+	}
 
 	tuples := make([]api.SourceTuple, 0, len(results))
 	for _, result := range results {
@@ -69,6 +69,46 @@ func TestGetTupleWithZlibCompressor(t *testing.T) {
 	}
 }
 
+func TestGetTupleWithV5Properties(t *testing.T) {
+	contextLogger := conf.Log.WithField("rule", "TestTupleV5_Apply")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	cv, _ := converter.GetOrCreateConverter(&ast.Options{FORMAT: "json"})
+	ctx = context.WithValue(ctx, context.DecodeKey, cv)
+	ms := &MQTTSource{}
+	msg := MockV5Message{
+		MockMessage: MockMessage{
+			payload: []byte(`{"key": "value"}`),
+			topic:   "test/topic",
+		},
+		props: map[string]interface{}{
+			"userProperties": map[string]interface{}{"device": "d1"},
+			"responseTopic":  "reply/d1",
+		},
+	}
+	results := getTuples(ctx, ms, msg)
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, but got %d", len(results))
+	}
+	exp := map[string]interface{}{
+		"topic":          "test/topic",
+		"messageid":      "1",
+		"userProperties": map[string]interface{}{"device": "d1"},
+		"responseTopic":  "reply/d1",
+	}
+	if !reflect.DeepEqual(results[0].(api.SourceTuple).Meta(), exp) {
+		t.Errorf("Expected metadata to be %v, but got %v", exp, results[0].(api.SourceTuple).Meta())
+	}
+}
+
+type MockV5Message struct {
+	MockMessage
+	props map[string]interface{}
+}
+
+func (mm MockV5Message) Properties() map[string]interface{} {
+	return mm.props
+}
+
 type MockMessage struct {
 	payload []byte
 	topic   string
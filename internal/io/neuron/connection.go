@@ -131,7 +131,7 @@ func connect(ctx api.StreamContext, url string, info *conninfo) error {
 		case mangos.PipeEventDetached:
 			atomic.StoreInt32(&info.opened, 0)
 			conf.Log.Warnf("neuron connection detached")
-			pubsub.ProduceError(ctx, TopicPrefix+url, fmt.Errorf("neuron connection detached"))
+			pubsub.ProduceError(ctx, TopicPrefix+url, fmt.Errorf("neuron connection detached"), nil)
 		}
 	})
 	// sock.SetOption(mangos.OptionWriteQLen, 100)
@@ -162,7 +162,9 @@ func run(ctx api.StreamContext, info *conninfo, url string) {
 				ctx.GetLogger().Errorf("neuron decode message error %v", err)
 				continue
 			}
-			pubsub.Produce(ctx, TopicPrefix+url, result)
+			if err := pubsub.Produce(ctx, TopicPrefix+url, result); err != nil {
+				ctx.GetLogger().Errorf("neuron produce message error %v", err)
+			}
 		} else if err == mangos.ErrClosed {
 			ctx.GetLogger().Infof("neuron connection closed, exit receiving loop")
 			return
@@ -0,0 +1,69 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package websocket
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lf-edge/ekuiper/internal/pkg/cert"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// clientConf is the configuration to connect to a websocket server as a client
+type clientConf struct {
+	Headers            map[string]string `json:"headers"`
+	InsecureSkipVerify bool              `json:"insecureSkipVerify"`
+	CertificationPath  string            `json:"certificationPath"`
+	PrivateKeyPath     string            `json:"privateKeyPath"`
+	RootCaPath         string            `json:"rootCaPath"`
+}
+
+func isClientUrl(addr string) bool {
+	return strings.HasPrefix(addr, "ws://") || strings.HasPrefix(addr, "wss://")
+}
+
+func (c *clientConf) dial(ctx api.StreamContext, url string) (*websocket.Conn, error) {
+	tlscfg, err := cert.GenerateTLSForClient(cert.TlsConfigurationOptions{
+		SkipCertVerify: c.InsecureSkipVerify,
+		CertFile:       c.CertificationPath,
+		KeyFile:        c.PrivateKeyPath,
+		CaFile:         c.RootCaPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: websocket.DefaultDialer.HandshakeTimeout,
+		TLSClientConfig:  tlscfg,
+	}
+	header := make(http.Header, len(c.Headers))
+	for k, v := range c.Headers {
+		header.Set(k, v)
+	}
+	conn, resp, err := dialer.DialContext(ctx, url, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("dial %s error with status %d: %v", url, resp.StatusCode, err)
+		}
+		return nil, fmt.Errorf("dial %s error: %v", url, err)
+	}
+	ctx.GetLogger().Infof("websocket connected to %s", url)
+	return conn, nil
+}
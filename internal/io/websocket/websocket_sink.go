@@ -0,0 +1,171 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package websocket
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lf-edge/ekuiper/internal/io/http/httpserver"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+type sinkConf struct {
+	clientConf  `json:",squash"`
+	Url         string `json:"url"`
+	Path        string `json:"path"`
+	MessageType string `json:"messageType"`
+	// SendBufferLength is the queue length of each client connected to the endpoint
+	SendBufferLength int `json:"sendBufferLength"`
+	// SlowClientPolicy is the action when the queue of a client is full: drop or disconnect
+	SlowClientPolicy string `json:"slowClientPolicy"`
+	// WriteTimeout is the timeout in milliseconds to write a message in client mode
+	WriteTimeout int `json:"writeTimeout"`
+}
+
+// WebsocketSink sends the results by websocket. With the url property, it connects to the server as a client.
+// With the path property, it serves an endpoint on the http data server and broadcasts to all the clients.
+type WebsocketSink struct {
+	conf        *sinkConf
+	messageType int
+	// server mode
+	endpoint *httpserver.WebsocketEndpoint
+	// client mode
+	mu   sync.Mutex
+	conn *websocket.Conn
+}
+
+func (ws *WebsocketSink) Configure(props map[string]interface{}) error {
+	cfg := &sinkConf{
+		MessageType:      "text",
+		SendBufferLength: 1024,
+		SlowClientPolicy: "drop",
+		WriteTimeout:     5000,
+	}
+	if err := cast.MapToStruct(props, cfg); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if (cfg.Url == "") == (cfg.Path == "") {
+		return fmt.Errorf("one of the properties url and path must be set")
+	}
+	if cfg.Url != "" && !isClientUrl(cfg.Url) {
+		return fmt.Errorf("property url %s must start with ws:// or wss://", cfg.Url)
+	}
+	if cfg.Path != "" && !strings.HasPrefix(cfg.Path, "/") {
+		return fmt.Errorf("property path must start with /")
+	}
+	switch strings.ToLower(cfg.MessageType) {
+	case "text":
+		ws.messageType = websocket.TextMessage
+	case "binary":
+		ws.messageType = websocket.BinaryMessage
+	default:
+		return fmt.Errorf("invalid messageType %s, must be text or binary", cfg.MessageType)
+	}
+	if cfg.SendBufferLength <= 0 {
+		return fmt.Errorf("property sendBufferLength must be positive")
+	}
+	if cfg.SlowClientPolicy != "drop" && cfg.SlowClientPolicy != "disconnect" {
+		return fmt.Errorf("invalid slowClientPolicy %s, must be drop or disconnect", cfg.SlowClientPolicy)
+	}
+	if cfg.WriteTimeout <= 0 {
+		return fmt.Errorf("property writeTimeout must be positive")
+	}
+	ws.conf = cfg
+	return nil
+}
+
+func (ws *WebsocketSink) Open(ctx api.StreamContext) error {
+	if ws.conf.Path != "" {
+		ep, _, err := httpserver.RegisterWebsocketEndpoint(ws.conf.Path, ws.conf.SendBufferLength)
+		if err != nil {
+			return err
+		}
+		ws.endpoint = ep
+		ctx.GetLogger().Infof("websocket sink serves on endpoint %s", ws.conf.Path)
+		return nil
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.connect(ctx)
+}
+
+// connect dials the server in client mode. Must run inside the lock
+func (ws *WebsocketSink) connect(ctx api.StreamContext) error {
+	conn, err := ws.conf.dial(ctx, ws.conf.Url)
+	if err != nil {
+		return err
+	}
+	// Read the connection to process the control messages such as ping and close
+	go func() {
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+	ws.conn = conn
+	return nil
+}
+
+func (ws *WebsocketSink) Collect(ctx api.StreamContext, item interface{}) error {
+	data, _, err := ctx.TransformOutput(item)
+	if err != nil {
+		return err
+	}
+	if ws.endpoint != nil {
+		n := ws.endpoint.Broadcast(ws.messageType, data, ws.conf.SlowClientPolicy == "disconnect")
+		ctx.GetLogger().Debugf("websocket sink broadcast %s to %d clients", data, n)
+		return nil
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.conn == nil {
+		if err := ws.connect(ctx); err != nil {
+			return fmt.Errorf("%s: %v", errorx.IOErr, err)
+		}
+	}
+	_ = ws.conn.SetWriteDeadline(time.Now().Add(time.Duration(ws.conf.WriteTimeout) * time.Millisecond))
+	if err := ws.conn.WriteMessage(ws.messageType, data); err != nil {
+		_ = ws.conn.Close()
+		ws.conn = nil
+		return fmt.Errorf("%s: %v", errorx.IOErr, err)
+	}
+	return nil
+}
+
+func (ws *WebsocketSink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Closing websocket sink")
+	if ws.endpoint != nil {
+		httpserver.UnregisterWebsocketEndpoint(ws.conf.Path)
+		ws.endpoint = nil
+		return nil
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.conn != nil {
+		_ = ws.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+		err := ws.conn.Close()
+		ws.conn = nil
+		return err
+	}
+	return nil
+}
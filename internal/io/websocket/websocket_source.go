@@ -0,0 +1,177 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package websocket
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/io/http/httpserver"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+type sourceConf struct {
+	clientConf   `json:",squash"`
+	BufferLength int `json:"bufferLength"`
+	// ReconnectInterval is the interval in milliseconds to reconnect in client mode
+	ReconnectInterval int `json:"reconnectInterval"`
+}
+
+// WebsocketSource receives messages by websocket. If the datasource is a ws:// or wss:// url, it connects to
+// the server as a client. Otherwise, the datasource is the path of an endpoint on the http data server.
+type WebsocketSource struct {
+	conf     *sourceConf
+	url      string
+	endpoint string
+}
+
+func (s *WebsocketSource) Configure(datasource string, props map[string]interface{}) error {
+	cfg := &sourceConf{
+		BufferLength:      1024,
+		ReconnectInterval: 5000,
+	}
+	if err := cast.MapToStruct(props, cfg); err != nil {
+		return fmt.Errorf("read properties %v fail with error: %v", props, err)
+	}
+	if cfg.BufferLength <= 0 {
+		return fmt.Errorf("property bufferLength must be positive")
+	}
+	if cfg.ReconnectInterval <= 0 {
+		return fmt.Errorf("property reconnectInterval must be positive")
+	}
+	switch {
+	case isClientUrl(datasource):
+		s.url = datasource
+	case strings.HasPrefix(datasource, "/"):
+		s.endpoint = datasource
+	default:
+		return fmt.Errorf("datasource %s must be a ws:// or wss:// url or an endpoint starting with /", datasource)
+	}
+	s.conf = cfg
+	conf.Log.Debugf("Initialized websocket source with configurations %#v.", cfg)
+	return nil
+}
+
+func (s *WebsocketSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	if s.url != "" {
+		s.runClient(ctx, consumer)
+	} else {
+		s.runServer(ctx, consumer, errCh)
+	}
+}
+
+func (s *WebsocketSource) runServer(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	ep, done, err := httpserver.RegisterWebsocketEndpoint(s.endpoint, 0)
+	if err != nil {
+		infra.DrainError(ctx, err, errCh)
+		return
+	}
+	defer httpserver.UnregisterWebsocketEndpoint(s.endpoint)
+	id := fmt.Sprintf("%s_%s_%d", ctx.GetRuleId(), ctx.GetOpId(), ctx.GetInstanceId())
+	ch := ep.Subscribe(id, s.conf.BufferLength)
+	defer ep.Unsubscribe(id)
+	ctx.GetLogger().Infof("websocket source listens on endpoint %s", s.endpoint)
+	for {
+		select {
+		case <-done: // http data server error
+			infra.DrainError(ctx, fmt.Errorf("http data server shutdown"), errCh)
+			return
+		case msg, opened := <-ch:
+			if !opened {
+				return
+			}
+			s.produce(ctx, msg.Data, map[string]interface{}{"remoteAddr": msg.RemoteAddr}, consumer)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// runClient connects to the server and reconnects when the connection is lost until the rule stops
+func (s *WebsocketSource) runClient(ctx api.StreamContext, consumer chan<- api.SourceTuple) {
+	logger := ctx.GetLogger()
+	interval := time.Duration(s.conf.ReconnectInterval) * time.Millisecond
+	for {
+		conn, err := s.conf.dial(ctx, s.url)
+		if err != nil {
+			logger.Errorf("websocket source %v, retry in %v", err, interval)
+		} else {
+			s.read(ctx, conn, consumer)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+	}
+}
+
+// read reads the messages from the connection until it is broken or the rule stops
+func (s *WebsocketSource) read(ctx api.StreamContext, conn *websocket.Conn, consumer chan<- api.SourceTuple) {
+	stop := make(chan struct{})
+	defer close(stop)
+	defer conn.Close()
+	// ReadMessage blocks, so close the connection to interrupt it when the rule stops
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			_ = conn.Close()
+		case <-stop:
+		}
+	}()
+	meta := map[string]interface{}{"url": s.url}
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() == nil {
+				ctx.GetLogger().Errorf("websocket source read from %s error: %v", s.url, err)
+			}
+			return
+		}
+		s.produce(ctx, data, meta, consumer)
+	}
+}
+
+func (s *WebsocketSource) produce(ctx api.StreamContext, data []byte, meta map[string]interface{}, consumer chan<- api.SourceTuple) {
+	rcvTime := conf.GetNow()
+	results, err := ctx.DecodeIntoList(data)
+	if err != nil {
+		select {
+		case consumer <- &xsql.ErrorSourceTuple{Error: fmt.Errorf("invalid data format, cannot decode %s with error %s", string(data), err), Payload: data}:
+		case <-ctx.Done():
+		}
+		return
+	}
+	for _, result := range results {
+		select {
+		case consumer <- api.NewDefaultSourceTupleWithTime(result, meta, rcvTime):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *WebsocketSource) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Closing websocket source")
+	return nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package websocket
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/converter"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestSourceConfigure(t *testing.T) {
+	tests := []struct {
+		name       string
+		datasource string
+		props      map[string]interface{}
+		url        string
+		endpoint   string
+		err        string
+	}{
+		{
+			name:       "client mode",
+			datasource: "wss://example.com/data",
+			props:      map[string]interface{}{"headers": map[string]interface{}{"Authorization": "token"}},
+			url:        "wss://example.com/data",
+		},
+		{
+			name:       "server mode",
+			datasource: "/api/ws",
+			props:      map[string]interface{}{},
+			endpoint:   "/api/ws",
+		},
+		{
+			name:       "invalid datasource",
+			datasource: "http://example.com/data",
+			props:      map[string]interface{}{},
+			err:        "datasource http://example.com/data must be a ws:// or wss:// url or an endpoint starting with /",
+		},
+		{
+			name:       "invalid buffer length",
+			datasource: "/api/ws",
+			props:      map[string]interface{}{"bufferLength": -1},
+			err:        "property bufferLength must be positive",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &WebsocketSource{}
+			err := s.Configure(tt.datasource, tt.props)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.url, s.url)
+			assert.Equal(t, tt.endpoint, s.endpoint)
+		})
+	}
+}
+
+func TestSinkConfigure(t *testing.T) {
+	tests := []struct {
+		name  string
+		props map[string]interface{}
+		err   string
+	}{
+		{
+			name:  "client mode",
+			props: map[string]interface{}{"url": "ws://localhost:8080/result", "messageType": "binary"},
+		},
+		{
+			name:  "server mode",
+			props: map[string]interface{}{"path": "/ws/result", "slowClientPolicy": "disconnect"},
+		},
+		{
+			name:  "missing address",
+			props: map[string]interface{}{},
+			err:   "one of the properties url and path must be set",
+		},
+		{
+			name:  "both address",
+			props: map[string]interface{}{"url": "ws://localhost:8080/result", "path": "/ws/result"},
+			err:   "one of the properties url and path must be set",
+		},
+		{
+			name:  "invalid url",
+			props: map[string]interface{}{"url": "http://localhost:8080/result"},
+			err:   "property url http://localhost:8080/result must start with ws:// or wss://",
+		},
+		{
+			name:  "invalid message type",
+			props: map[string]interface{}{"path": "/ws/result", "messageType": "json"},
+			err:   "invalid messageType json, must be text or binary",
+		},
+		{
+			name:  "invalid policy",
+			props: map[string]interface{}{"path": "/ws/result", "slowClientPolicy": "block"},
+			err:   "invalid slowClientPolicy block, must be drop or disconnect",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&WebsocketSink{}).Configure(tt.props)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestClientMode(t *testing.T) {
+	received := make(chan string, 10)
+	auth := make(chan string, 1)
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if r.URL.Path == "/source" {
+			auth <- r.Header.Get("Authorization")
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"temperature":20}`))
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(`[{"temperature":21},{"temperature":22}]`))
+		}
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			received <- string(data)
+		}
+	}))
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	contextLogger := conf.Log.WithField("rule", "TestClientMode")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	cv, _ := converter.GetOrCreateConverter(&ast.Options{FORMAT: "json"})
+	ctx = context.WithValue(ctx, context.DecodeKey, cv)
+	tf, _ := transform.GenTransform("", "json", "", "", "", []string{})
+	ctx = context.WithValue(ctx, context.TransKey, tf)
+
+	// source
+	s := &WebsocketSource{}
+	require.NoError(t, s.Configure(url+"/source", map[string]interface{}{
+		"headers": map[string]interface{}{"Authorization": "token"},
+	}))
+	sctx, cancel := ctx.WithCancel()
+	consumer := make(chan api.SourceTuple, 10)
+	errCh := make(chan error, 1)
+	go s.Open(sctx, consumer, errCh)
+	exp := []map[string]interface{}{
+		{"temperature": float64(20)},
+		{"temperature": float64(21)},
+		{"temperature": float64(22)},
+	}
+	for _, e := range exp {
+		select {
+		case tuple := <-consumer:
+			assert.Equal(t, e, tuple.Message())
+			assert.Equal(t, map[string]interface{}{"url": url + "/source"}, tuple.Meta())
+		case <-time.After(time.Second):
+			t.Fatal("timeout to receive the source tuples")
+		}
+	}
+	assert.Equal(t, "token", <-auth)
+	cancel()
+
+	// sink
+	ws := &WebsocketSink{}
+	require.NoError(t, ws.Configure(map[string]interface{}{"url": url + "/sink"}))
+	require.NoError(t, ws.Open(ctx))
+	require.NoError(t, ws.Collect(ctx, map[string]interface{}{"a": 1}))
+	require.NoError(t, ws.Collect(ctx, []map[string]interface{}{{"a": 2}}))
+	for _, e := range []string{`{"a":1}`, `[{"a":2}]`} {
+		select {
+		case data := <-received:
+			assert.Equal(t, e, data)
+		case <-time.After(time.Second):
+			t.Fatal("timeout to receive the sink messages")
+		}
+	}
+	assert.NoError(t, ws.Close(ctx))
+}
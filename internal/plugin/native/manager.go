@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -27,6 +27,7 @@ import (
 	"path/filepath"
 	"plugin"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -309,7 +310,11 @@ func (rr *Manager) Register(t plugin2.PluginType, j plugin2.Plugin) error {
 	}
 	rr.store(t, name, version)
 	rr.storePluginInstallScript(name, t, j)
+	rr.readMeta(t, name)
+	return nil
+}
 
+func (rr *Manager) readMeta(t plugin2.PluginType, name string) {
 	switch t {
 	case plugin2.SINK:
 		if err := meta.ReadSinkMetaFile(path.Join(rr.pluginConfDir, plugin2.PluginTypes[t], name+`.json`), true); nil != err {
@@ -330,9 +335,258 @@ func (rr *Manager) Register(t plugin2.PluginType, j plugin2.Plugin) error {
 			conf.Log.Errorf("readSourceFile:%v", err)
 		}
 	}
+}
+
+// Update downloads the new version of an existing plugin and verifies it without touching the running version.
+// The returned upgrade installs the new version side by side and switches the plugin to it when applied. A loaded go
+// plugin cannot be reloaded from the same file, so the so file of the new version must carry a different version
+// like Random@v1.0.1.so. The rules must be restarted to use the new version.
+func (rr *Manager) Update(t plugin2.PluginType, j plugin2.Plugin) (_ plugin2.Upgrade, e error) {
+	name, uri := j.GetName(), j.GetFile()
+	name = strings.Trim(name, " ")
+	if name == "" {
+		return nil, fmt.Errorf("invalid name %s: should not be empty", name)
+	}
+	if !httpx.IsValidUrl(uri) || !strings.HasSuffix(uri, ".zip") {
+		return nil, fmt.Errorf("invalid uri %s", uri)
+	}
+	oldVersion, ok := rr.get(t, name)
+	if !ok || oldVersion == DELETED {
+		return nil, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("%s plugin %s is not found", plugin2.PluginTypes[t], name))
+	}
+	soPath, err := rr.getSoFilePath(t, name, true)
+	if err != nil {
+		return nil, err
+	}
+	if t == plugin2.FUNCTION {
+		if err := rr.checkSymbols(name, j.GetSymbols()); err != nil {
+			return nil, err
+		}
+	}
+
+	zipPath := path.Join(rr.pluginDir, name+".zip")
+	defer func() {
+		if e != nil {
+			_ = os.Remove(zipPath)
+		}
+	}()
+	err = httpx.DownloadFile(zipPath, uri)
+	if err != nil {
+		return nil, fmt.Errorf("fail to download file %s: %s", uri, err)
+	}
+	version, err := soVersionInZip(zipPath, name)
+	if err != nil {
+		return nil, err
+	}
+	if version == oldVersion {
+		return nil, fmt.Errorf("version %s of %s plugin %s is already installed, the so file must have a new version", strings.TrimPrefix(version, "v"), plugin2.PluginTypes[t], name)
+	}
+	return &upgrade{
+		m:       rr,
+		t:       t,
+		name:    name,
+		version: oldVersion,
+		soPath:  soPath,
+		confs:   make(map[string][]byte),
+		symbols: []string{name},
+		plugin:  j,
+		zipPath: zipPath,
+	}, nil
+}
+
+// checkSymbols fails if the function symbols are used by other plugins
+func (rr *Manager) checkSymbols(name string, symbols []string) error {
+	rr.RLock()
+	defer rr.RUnlock()
+	for _, s := range symbols {
+		if p, ok := rr.symbols[s]; ok && p != name {
+			return fmt.Errorf("function name %s already exists", s)
+		}
+	}
 	return nil
 }
 
+// replaceSymbols replaces the function symbols of a plugin. It fails if the new symbols are used by other plugins.
+func (rr *Manager) replaceSymbols(name string, old []string, symbols []string) error {
+	rr.Lock()
+	defer rr.Unlock()
+	for _, s := range symbols {
+		if p, ok := rr.symbols[s]; ok && p != name {
+			return fmt.Errorf("function name %s already exists", s)
+		}
+	}
+	for _, s := range old {
+		delete(rr.symbols, s)
+	}
+	for _, s := range symbols {
+		rr.symbols[s] = name
+	}
+	return nil
+}
+
+func (rr *Manager) unloadRuntime(t plugin2.PluginType, symbols []string) {
+	rr.Lock()
+	defer rr.Unlock()
+	for _, s := range symbols {
+		delete(rr.runtime, plugin2.PluginTypes[t]+"/"+s)
+	}
+}
+
+// soVersionInZip finds the version of the so file in the plugin zip
+func soVersionInZip(src string, name string) (string, error) {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return "", err
+	}
+	defer r.Close()
+	soPrefix := soPattern(name)
+	for _, file := range r.File {
+		if soPrefix.MatchString(file.Name) {
+			_, version := parseName(file.Name)
+			return version, nil
+		}
+	}
+	return "", fmt.Errorf("invalid zip file: so file is missing")
+}
+
+func soPattern(name string) *regexp.Regexp {
+	return regexp.MustCompile(fmt.Sprintf(`^((%s)|(%s))(@.*)?\.so$`, name, ucFirst(name)))
+}
+
+// upgrade keeps the previous version of a native plugin during update
+type upgrade struct {
+	m    *Manager
+	t    plugin2.PluginType
+	name string
+	// the verified zip of the new version
+	plugin  plugin2.Plugin
+	zipPath string
+	// previous version
+	version      string
+	soPath       string
+	symbols      []string
+	hasSymbolsDb bool
+	script       string
+	// the content of the conf files of the previous version, nil if not exist
+	confs map[string][]byte
+	// new version
+	newSoPath  string
+	newSymbols []string
+}
+
+func (u *upgrade) Apply() error {
+	rr, t, name, j := u.m, u.t, u.name, u.plugin
+	defer os.Remove(u.zipPath)
+	_, _ = rr.plgInstallDb.Get(plugin2.PluginTypes[t]+"_"+name, &u.script)
+	for _, ext := range []string{".yaml", ".json"} {
+		p := path.Join(rr.pluginConfDir, plugin2.PluginTypes[t], name+ext)
+		c, err := os.ReadFile(p)
+		if err != nil {
+			c = nil
+		}
+		u.confs[p] = c
+	}
+	newSymbols := u.symbols
+	if t == plugin2.FUNCTION {
+		old := make([]string, 0)
+		if ok, err := rr.funcSymbolsDb.Get(name, &old); err != nil {
+			return err
+		} else if ok {
+			u.symbols = old
+			u.hasSymbolsDb = true
+		}
+		newSymbols = u.symbols
+		if len(j.GetSymbols()) > 0 {
+			newSymbols = j.GetSymbols()
+		}
+		if err := rr.replaceSymbols(name, u.symbols, newSymbols); err != nil {
+			return err
+		}
+	}
+	u.newSymbols = newSymbols
+	// the cached runtime is keyed by symbol, drop it so that the new so file is opened
+	rr.unloadRuntime(t, append([]string{name}, newSymbols...))
+
+	newVersion, err := rr.install(t, name, u.zipPath, j.GetShellParas())
+	if err == nil && t == plugin2.FUNCTION && len(j.GetSymbols()) > 0 {
+		err = rr.funcSymbolsDb.Set(name, j.GetSymbols())
+	}
+	if err != nil {
+		u.restore()
+		return fmt.Errorf("fail to install plugin: %s", err)
+	}
+	rr.store(t, name, newVersion)
+	u.newSoPath, _ = rr.getSoFilePath(t, name, true)
+	rr.storePluginInstallScript(name, t, j)
+	rr.readMeta(t, name)
+	conf.Log.Infof("update %s plugin %s from version %s to %s", plugin2.PluginTypes[t], name, u.version, newVersion)
+	return nil
+}
+
+func (u *upgrade) Commit() {
+	if u.newSoPath != u.soPath {
+		_ = os.Remove(u.soPath)
+	}
+	conf.Log.Infof("commit the update of %s plugin %s", plugin2.PluginTypes[u.t], u.name)
+}
+
+func (u *upgrade) Rollback() error {
+	if u.newSoPath != "" && u.newSoPath != u.soPath {
+		_ = os.Remove(u.newSoPath)
+	}
+	u.m.store(u.t, u.name, u.version)
+	if u.script != "" {
+		_ = u.m.plgInstallDb.Set(plugin2.PluginTypes[u.t]+"_"+u.name, u.script)
+	}
+	if u.t == plugin2.FUNCTION {
+		var err error
+		if u.hasSymbolsDb {
+			err = u.m.funcSymbolsDb.Set(u.name, u.symbols)
+		} else {
+			err = u.m.funcSymbolsDb.Delete(u.name)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	u.restore()
+	u.m.readMeta(u.t, u.name)
+	conf.Log.Infof("rollback %s plugin %s to version %s", plugin2.PluginTypes[u.t], u.name, u.version)
+	return nil
+}
+
+// restore the conf files and symbols of the previous version
+func (u *upgrade) restore() {
+	for p, c := range u.confs {
+		if c == nil {
+			_ = os.Remove(p)
+		} else if err := os.WriteFile(p, c, 0o644); err != nil {
+			conf.Log.Errorf("fail to restore %s: %v", p, err)
+		}
+	}
+	if u.t == plugin2.FUNCTION {
+		_ = u.m.replaceSymbols(u.name, u.newSymbols, u.symbols)
+	}
+	u.m.unloadRuntime(u.t, append([]string{u.name}, u.newSymbols...))
+}
+
+// GetPluginSymbols returns the symbols exported by the plugin
+func (rr *Manager) GetPluginSymbols(t plugin2.PluginType, name string) []string {
+	if t != plugin2.FUNCTION {
+		return []string{name}
+	}
+	rr.RLock()
+	defer rr.RUnlock()
+	var result []string
+	for s, p := range rr.symbols {
+		if p == name {
+			result = append(result, s)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
 // RegisterFuncs prerequisite：function plugin of name exists
 func (rr *Manager) RegisterFuncs(name string, functions []string) error {
 	if len(functions) == 0 {
@@ -473,7 +727,7 @@ func (rr *Manager) install(t plugin2.PluginType, name, src string, shellParas []
 		return "", fmt.Errorf("have shell parameters : %s but no install.sh file", shellParas)
 	}
 
-	soPrefix := regexp.MustCompile(fmt.Sprintf(`^((%s)|(%s))(@.*)?\.so$`, name, ucFirst(name)))
+	soPrefix := soPattern(name)
 	var soPath string
 	var yamlFile, yamlPath, version, soName string
 	expFiles := 1
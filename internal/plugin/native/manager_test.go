@@ -239,6 +239,71 @@ func TestManager_Desc(t *testing.T) {
 	}
 }
 
+func TestManager_Update(t *testing.T) {
+	s := httptest.NewServer(
+		http.FileServer(http.Dir("../testzips")),
+	)
+	defer s.Close()
+	endpoint := s.URL
+
+	_, err := manager.Update(plugin.SOURCE, &plugin.IOPlugin{Name: "random3", File: endpoint + "/sources/random3.zip"})
+	if !reflect.DeepEqual(errors.New("version 1.0.0 of sources plugin random3 is already installed, the so file must have a new version"), err) {
+		t.Errorf("same version error mismatch, got %v", err)
+	}
+	_, err = manager.Update(plugin.SOURCE, &plugin.IOPlugin{Name: "random4", File: endpoint + "/sources/random3v2.zip"})
+	if err == nil || err.Error() != "sources plugin random4 is not found" {
+		t.Errorf("not found error mismatch, got %v", err)
+	}
+	// update and rollback
+	u, err := manager.Update(plugin.SOURCE, &plugin.IOPlugin{Name: "random3", File: endpoint + "/sources/random3v2.zip"})
+	if err != nil {
+		t.Fatalf("update error: %v", err)
+	}
+	if v, _ := manager.get(plugin.SOURCE, "random3"); v != "v1.0.0" {
+		t.Errorf("version mismatch before apply, expect v1.0.0 but got %s", v)
+	}
+	if err := u.Apply(); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	if err := checkFile(manager.pluginDir, manager.pluginConfDir, plugin.SOURCE, "random3", "1.0.1", false); err != nil {
+		t.Errorf("new version is not installed: %v", err)
+	}
+	if err := checkFile(manager.pluginDir, manager.pluginConfDir, plugin.SOURCE, "random3", "1.0.0", false); err != nil {
+		t.Errorf("previous version is not kept: %v", err)
+	}
+	if v, _ := manager.get(plugin.SOURCE, "random3"); v != "v1.0.1" {
+		t.Errorf("version mismatch, expect v1.0.1 but got %s", v)
+	}
+	if err := u.Rollback(); err != nil {
+		t.Fatalf("rollback error: %v", err)
+	}
+	if v, _ := manager.get(plugin.SOURCE, "random3"); v != "v1.0.0" {
+		t.Errorf("version mismatch after rollback, expect v1.0.0 but got %s", v)
+	}
+	if err := checkFile(manager.pluginDir, manager.pluginConfDir, plugin.SOURCE, "random3", "1.0.1", false); err == nil {
+		t.Errorf("new version is not removed after rollback")
+	}
+	if err := checkFile(manager.pluginDir, manager.pluginConfDir, plugin.SOURCE, "random3", "1.0.0", false); err != nil {
+		t.Errorf("previous version is not restored: %v", err)
+	}
+	// update and commit
+	u, err = manager.Update(plugin.SOURCE, &plugin.IOPlugin{Name: "random3", File: endpoint + "/sources/random3v2.zip"})
+	if err != nil {
+		t.Fatalf("update error: %v", err)
+	}
+	if err := u.Apply(); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	u.Commit()
+	if err := checkFile(manager.pluginDir, manager.pluginConfDir, plugin.SOURCE, "random3", "1.0.0", false); err == nil {
+		t.Errorf("previous version is not removed after commit")
+	}
+	r, _ := manager.GetPluginInfo(plugin.SOURCE, "random3")
+	if !reflect.DeepEqual(map[string]interface{}{"name": "random3", "version": "1.0.1"}, r) {
+		t.Errorf("desc mismatch, got %v", r)
+	}
+}
+
 func TestManager_Delete(t *testing.T) {
 	data := []struct {
 		t   plugin.PluginType
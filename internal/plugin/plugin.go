@@ -1,4 +1,4 @@
-// Copyright 2021 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -101,6 +101,18 @@ func (fp *FuncPlugin) GetSymbols() []string {
 	return fp.Functions
 }
 
+// Upgrade is a plugin update in progress. The new version is downloaded and verified when the upgrade is created.
+// Apply installs it side by side and it takes effect for the newly created rules. The previous version is kept so
+// that Rollback can restore it until Commit is called.
+type Upgrade interface {
+	// Apply installs the new version and switches the plugin to it. The previous version is restored if it fails
+	Apply() error
+	// Commit removes the previous version
+	Commit()
+	// Rollback uninstalls the new version and restores the previous one
+	Rollback() error
+}
+
 type EXTENSION_TYPE int
 
 const (
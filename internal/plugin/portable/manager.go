@@ -34,6 +34,7 @@ import (
 	"github.com/lf-edge/ekuiper/internal/pkg/store"
 	"github.com/lf-edge/ekuiper/internal/plugin"
 	"github.com/lf-edge/ekuiper/internal/plugin/portable/runtime"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
 	"github.com/lf-edge/ekuiper/pkg/kv"
 )
 
@@ -242,32 +243,8 @@ func (m *Manager) install(name, src string, shellParas []string) (resultErr erro
 		return err
 	}
 	defer r.Close()
-	var pi *PluginInfo
-	filesNumber := 0
-	// Parse json file
-	for _, file := range r.File {
-		filesNumber++
-		if file.Name == jsonName {
-			jf, err := file.Open()
-			if err != nil {
-				err = fmt.Errorf("invalid json file %s: %s", jsonName, err)
-				return err
-			}
-			pi = &PluginInfo{PluginMeta: runtime.PluginMeta{Name: name}}
-			allBytes, err := io.ReadAll(jf)
-			if err != nil {
-				return err
-			}
-			err = json.Unmarshal(allBytes, pi)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	if pi == nil {
-		return fmt.Errorf("missing or invalid json file %s, found %d files in total", jsonName, filesNumber)
-	}
-	if err = pi.Validate(name); err != nil {
+	pi, err := readZipJson(name, r)
+	if err != nil {
 		return err
 	}
 	if _, ok := m.reg.Get(pi.Name); ok {
@@ -366,6 +343,11 @@ func (m *Manager) GetPluginInfo(pluginName string) (*PluginInfo, bool) {
 	return pinfo, true
 }
 
+// GetPluginStatus returns the process status of the plugin including the violation of resource limits
+func (m *Manager) GetPluginStatus(pluginName string) *runtime.PluginStatus {
+	return runtime.GetPluginInsManager().GetStatus(pluginName)
+}
+
 func (m *Manager) Delete(name string) error {
 	pinfo, ok := m.reg.Get(name)
 	if !ok {
@@ -374,24 +356,10 @@ func (m *Manager) Delete(name string) error {
 	// unregister the plugin
 	m.reg.Delete(name)
 	// delete files and uninstall metas
-	for _, s := range pinfo.Sources {
-		p := path.Join(m.pluginConfDir, plugin.PluginTypes[plugin.SOURCE], s+".yaml")
-		os.Remove(p)
-		p = path.Join(m.pluginConfDir, plugin.PluginTypes[plugin.SOURCE], s+".json")
-		os.Remove(p)
-		meta.UninstallSource(s)
-	}
-	for _, s := range pinfo.Sinks {
-		p := path.Join(m.pluginConfDir, plugin.PluginTypes[plugin.SINK], s+".yaml")
-		os.Remove(p)
-		p = path.Join(m.pluginConfDir, plugin.PluginTypes[plugin.SINK], s+".json")
-		os.Remove(p)
-		meta.UninstallSink(s)
-	}
-	for _, s := range pinfo.Functions {
-		p := path.Join(m.pluginConfDir, plugin.PluginTypes[plugin.FUNCTION], s+".json")
+	for _, p := range m.confFiles(pinfo) {
 		os.Remove(p)
 	}
+	m.uninstallMetas(pinfo)
 	_ = os.RemoveAll(path.Join(m.pluginDir, name))
 	m.removePluginInstallScript(name)
 	// Kill the process in the end, and return error if it cannot be deleted
@@ -403,6 +371,238 @@ func (m *Manager) Delete(name string) error {
 	return nil
 }
 
+// confFiles returns the paths of the conf files of all symbols in the plugin
+func (m *Manager) confFiles(pinfo *PluginInfo) []string {
+	var result []string
+	for _, s := range pinfo.Sources {
+		result = append(result, path.Join(m.pluginConfDir, plugin.PluginTypes[plugin.SOURCE], s+".yaml"), path.Join(m.pluginConfDir, plugin.PluginTypes[plugin.SOURCE], s+".json"))
+	}
+	for _, s := range pinfo.Sinks {
+		result = append(result, path.Join(m.pluginConfDir, plugin.PluginTypes[plugin.SINK], s+".yaml"), path.Join(m.pluginConfDir, plugin.PluginTypes[plugin.SINK], s+".json"))
+	}
+	for _, s := range pinfo.Functions {
+		result = append(result, path.Join(m.pluginConfDir, plugin.PluginTypes[plugin.FUNCTION], s+".json"))
+	}
+	return result
+}
+
+func (m *Manager) uninstallMetas(pinfo *PluginInfo) {
+	for _, s := range pinfo.Sources {
+		meta.UninstallSource(s)
+	}
+	for _, s := range pinfo.Sinks {
+		meta.UninstallSink(s)
+	}
+}
+
+// Update downloads the new version of the plugin and verifies it without touching the running version. The returned
+// upgrade switches to the new version when applied. The previous version is moved aside and kept until the upgrade is
+// committed. The process of the previous version is killed so that the rules start the new process when restarting.
+func (m *Manager) Update(p plugin.Plugin) (_ plugin.Upgrade, e error) {
+	name, uri := p.GetName(), p.GetFile()
+	name = strings.Trim(name, " ")
+	if name == "" {
+		return nil, fmt.Errorf("invalid name %s: should not be empty", name)
+	}
+	if !httpx.IsValidUrl(uri) || !strings.HasSuffix(uri, ".zip") {
+		return nil, fmt.Errorf("invalid uri %s", uri)
+	}
+	pinfo, ok := m.reg.Get(name)
+	if !ok {
+		return nil, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("portable plugin %s is not found", name))
+	}
+
+	zipPath := path.Join(m.pluginDir, name+".zip")
+	defer func() {
+		if e != nil {
+			_ = os.Remove(zipPath)
+		}
+	}()
+	err := httpx.DownloadFile(zipPath, uri)
+	if err != nil {
+		return nil, fmt.Errorf("fail to download file %s: %s", uri, err)
+	}
+	if err := verifyZip(name, zipPath); err != nil {
+		return nil, err
+	}
+	return &upgrade{
+		m:       m,
+		name:    name,
+		pinfo:   pinfo,
+		backup:  filepath.Join(filepath.Dir(m.pluginDir), "temp", "portable", name),
+		confs:   make(map[string][]byte),
+		plugin:  p,
+		zipPath: zipPath,
+	}, nil
+}
+
+// verifyZip checks the plugin json and the required files in the zip before installing
+func verifyZip(name, src string) error {
+	r, err := zip.OpenReader(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	pi, err := readZipJson(name, r)
+	if err != nil {
+		return err
+	}
+	files := make(map[string]bool, len(r.File))
+	for _, file := range r.File {
+		files[file.Name] = true
+	}
+	requiredFiles := []string{pi.Executable}
+	for _, src := range pi.Sources {
+		requiredFiles = append(requiredFiles, fmt.Sprintf("sources/%s.yaml", src))
+	}
+	for _, rf := range requiredFiles {
+		if !files[rf] {
+			return fmt.Errorf("missing %s", rf)
+		}
+	}
+	return nil
+}
+
+// readZipJson parses and validates the plugin json file in the zip
+func readZipJson(name string, r *zip.ReadCloser) (*PluginInfo, error) {
+	jsonName := name + ".json"
+	var pi *PluginInfo
+	filesNumber := 0
+	for _, file := range r.File {
+		filesNumber++
+		if file.Name == jsonName {
+			jf, err := file.Open()
+			if err != nil {
+				return nil, fmt.Errorf("invalid json file %s: %s", jsonName, err)
+			}
+			pi = &PluginInfo{PluginMeta: runtime.PluginMeta{Name: name}}
+			allBytes, err := io.ReadAll(jf)
+			_ = jf.Close()
+			if err != nil {
+				return nil, err
+			}
+			err = json.Unmarshal(allBytes, pi)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if pi == nil {
+		return nil, fmt.Errorf("missing or invalid json file %s, found %d files in total", jsonName, filesNumber)
+	}
+	if err := pi.Validate(name); err != nil {
+		return nil, err
+	}
+	return pi, nil
+}
+
+// upgrade keeps the previous version of a portable plugin during update
+type upgrade struct {
+	m     *Manager
+	name  string
+	pinfo *PluginInfo
+	// the verified zip of the new version
+	plugin  plugin.Plugin
+	zipPath string
+	// the folder of the previous version
+	backup string
+	script string
+	// the content of the conf files of the previous version, nil if not exist
+	confs   map[string][]byte
+	newInfo *PluginInfo
+}
+
+func (u *upgrade) Apply() error {
+	m, name, pinfo := u.m, u.name, u.pinfo
+	defer os.Remove(u.zipPath)
+	_, _ = m.plgInstallDb.Get(name, &u.script)
+	for _, p := range m.confFiles(pinfo) {
+		c, err := os.ReadFile(p)
+		if err != nil {
+			c = nil
+		}
+		u.confs[p] = c
+	}
+	// move the previous version aside
+	_ = os.RemoveAll(u.backup)
+	if err := os.MkdirAll(filepath.Dir(u.backup), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(path.Join(m.pluginDir, name), u.backup); err != nil {
+		return fmt.Errorf("fail to backup portable plugin %s: %v", name, err)
+	}
+	m.reg.Delete(name)
+	for p := range u.confs {
+		_ = os.Remove(p)
+	}
+	m.uninstallMetas(pinfo)
+	if err := runtime.GetPluginInsManager().Kill(name); err != nil {
+		conf.Log.Warnf("fail to kill portable plugin %s process: %v", name, err)
+	}
+
+	err := m.install(name, u.zipPath, u.plugin.GetShellParas())
+	if err != nil {
+		if rerr := u.restore(); rerr != nil {
+			conf.Log.Errorf("fail to restore portable plugin %s: %v", name, rerr)
+		}
+		return fmt.Errorf("fail to install plugin: %s", err)
+	}
+	u.newInfo, _ = m.reg.Get(name)
+	m.storePluginInstallScript(name, u.plugin)
+	conf.Log.Infof("update portable plugin %s from version %s to %s", name, pinfo.Version, u.newInfo.Version)
+	return nil
+}
+
+func (u *upgrade) Commit() {
+	_ = os.RemoveAll(u.backup)
+	conf.Log.Infof("commit the update of portable plugin %s", u.name)
+}
+
+func (u *upgrade) Rollback() error {
+	m := u.m
+	m.reg.Delete(u.name)
+	if u.newInfo != nil {
+		for _, p := range m.confFiles(u.newInfo) {
+			_ = os.Remove(p)
+		}
+		m.uninstallMetas(u.newInfo)
+	}
+	if err := runtime.GetPluginInsManager().Kill(u.name); err != nil {
+		conf.Log.Warnf("fail to kill portable plugin %s process: %v", u.name, err)
+	}
+	if err := u.restore(); err != nil {
+		return err
+	}
+	if u.script != "" {
+		_ = m.plgInstallDb.Set(u.name, u.script)
+	}
+	conf.Log.Infof("rollback portable plugin %s to version %s", u.name, u.pinfo.Version)
+	return nil
+}
+
+// restore moves back the previous version and registers it again
+func (u *upgrade) restore() error {
+	m := u.m
+	// remove the leftover of the new version
+	_ = os.RemoveAll(path.Join(m.pluginDir, u.name))
+	if err := os.Rename(u.backup, path.Join(m.pluginDir, u.name)); err != nil {
+		return fmt.Errorf("fail to restore portable plugin %s: %v", u.name, err)
+	}
+	for p, c := range u.confs {
+		if c != nil {
+			if err := os.WriteFile(p, c, 0o644); err != nil {
+				conf.Log.Errorf("fail to restore %s: %v", p, err)
+			}
+		}
+	}
+	// parse again because the executable path is changed during registering
+	pi, err := m.parsePluginJson(u.name)
+	if err != nil {
+		return err
+	}
+	return m.doRegister(u.name, pi, false)
+}
+
 func (m *Manager) UninstallAllPlugins() {
 	keys, err := m.plgInstallDb.Keys()
 	if err != nil {
@@ -162,6 +162,62 @@ func TestManager_Install(t *testing.T) {
 //	}
 //}
 
+func TestUpdate(t *testing.T) {
+	s := httptest.NewServer(
+		http.FileServer(http.Dir("../testzips")),
+	)
+	defer s.Close()
+	endpoint := s.URL
+
+	_, err := manager.Update(&plugin.IOPlugin{Name: "mirror3", File: endpoint + "/portables/mirror.zip"})
+	if err == nil || err.Error() != "portable plugin mirror3 is not found" {
+		t.Errorf("not found error mismatch, got %v", err)
+	}
+	// the wrong zip fails to verify without touching the previous version
+	_, err = manager.Update(&plugin.IOPlugin{Name: "mirror2", File: endpoint + "/portables/wrong.zip"})
+	if err == nil {
+		t.Errorf("should fail to update with wrong zip")
+	}
+	if _, ok := manager.GetPluginInfo("mirror2"); !ok {
+		t.Errorf("previous version is changed after failure")
+	}
+	if err := checkFileForMirror(manager.pluginDir, manager.pluginConfDir, true); err != nil {
+		t.Errorf("error : %s\n\n", err)
+	}
+	// update and rollback
+	u, err := manager.Update(&plugin.IOPlugin{Name: "mirror2", File: endpoint + "/portables/mirror.zip"})
+	if err != nil {
+		t.Fatalf("update error: %v", err)
+	}
+	if err := u.Apply(); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	if err := u.Rollback(); err != nil {
+		t.Fatalf("rollback error: %v", err)
+	}
+	if _, ok := manager.GetPluginInfo("mirror2"); !ok {
+		t.Errorf("previous version is not registered after rollback")
+	}
+	if err := checkFileForMirror(manager.pluginDir, manager.pluginConfDir, true); err != nil {
+		t.Errorf("error : %s\n\n", err)
+	}
+	// update and commit
+	u, err = manager.Update(&plugin.IOPlugin{Name: "mirror2", File: endpoint + "/portables/mirror.zip"})
+	if err != nil {
+		t.Fatalf("update error: %v", err)
+	}
+	if err := u.Apply(); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	u.Commit()
+	if _, err := os.Stat(u.(*upgrade).backup); err == nil {
+		t.Errorf("backup is not removed after commit")
+	}
+	if err := checkFileForMirror(manager.pluginDir, manager.pluginConfDir, true); err != nil {
+		t.Errorf("error : %s\n\n", err)
+	}
+}
+
 func TestDelete(t *testing.T) {
 	err := manager.Delete("mirror2")
 	if err != nil {
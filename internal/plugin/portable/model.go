@@ -1,4 +1,4 @@
-// Copyright 2021 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -49,5 +49,10 @@ func (p *PluginInfo) Validate(expectedName string) error {
 	if l, ok := langMap[p.Language]; !ok || !l {
 		return fmt.Errorf("invalid plugin, language '%s' is not supported", p.Language)
 	}
+	if p.Limits != nil {
+		if err := p.Limits.Validate(); err != nil {
+			return fmt.Errorf("invalid plugin, invalid limits: %v", err)
+		}
+	}
 	return nil
 }
@@ -111,6 +111,9 @@ func (f *PortableFunc) Exec(args []interface{}, ctx api.FunctionContext) (interf
 	}
 	res, err := f.dataCh.Req(jsonArg)
 	if err != nil {
+		if pe := GetPluginInsManager().GetErr(f.reg.Name); pe != nil {
+			return pe, false
+		}
 		return err, false
 	}
 	fr := &FuncReply{}
@@ -0,0 +1,56 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"os"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+// procLimiter keeps the resource limits applied to a plugin process
+type procLimiter struct {
+	name   string
+	limits *conf.PortableLimits
+	// the cgroup directory of the process, empty if limits are applied by rlimit
+	cgroup string
+	// the opened cgroup directory to start the process in, closed once the process starts
+	cgroupFile *os.File
+	// the reason why the cgroup is not used
+	cgroupErr error
+	// whether the memory limit is applied by the RLIMIT_AS fallback
+	rlimitMemory bool
+}
+
+// resolveLimits returns the limits of the plugin which are merged with the global default
+func resolveLimits(pluginMeta *PluginMeta) *conf.PortableLimits {
+	var defaults *conf.PortableLimits
+	if conf.Config != nil {
+		defaults = conf.Config.Portable.Limits
+	}
+	return pluginMeta.Limits.Merge(defaults)
+}
+
+// release cleans up the resources for limiting after the process exits
+func (pl *procLimiter) release() {
+	pl.closeCgroupFile()
+	if pl.cgroup != "" {
+		err := os.Remove(pl.cgroup)
+		if err != nil {
+			conf.Log.Warnf("fail to remove cgroup %s of plugin %s: %v", pl.cgroup, pl.name, err)
+		}
+		pl.cgroup = ""
+	}
+}
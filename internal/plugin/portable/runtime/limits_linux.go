@@ -0,0 +1,195 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package runtime
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+const (
+	cgroupMount     = "/sys/fs/cgroup"
+	cgroupCpuPeriod = 100000
+)
+
+// newLimiter creates the cgroup of the plugin before the process starts and lets the process start inside it
+// so that there is no time window without limits. If the cgroup is not available, the memory limit falls back to
+// rlimit which is applied once the process starts.
+func newLimiter(name string, cmd *exec.Cmd, limits *conf.PortableLimits) *procLimiter {
+	pl := &procLimiter{name: name, limits: limits}
+	if limits.Memory <= 0 && limits.Cpu <= 0 {
+		return pl
+	}
+	cg, err := createCgroup(name, limits)
+	if err != nil {
+		pl.cgroupErr = err
+		conf.Log.Warnf("cannot limit plugin %s by cgroup, fall back to rlimit: %v", name, err)
+		return pl
+	}
+	f, err := os.Open(cg)
+	if err != nil {
+		_ = os.Remove(cg)
+		pl.cgroupErr = err
+		conf.Log.Warnf("cannot open cgroup %s of plugin %s, fall back to rlimit: %v", cg, name, err)
+		return pl
+	}
+	pl.cgroup = cg
+	pl.cgroupFile = f
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(f.Fd())
+	return pl
+}
+
+// apply sets the limits which can only be applied to a running process
+func (pl *procLimiter) apply(pid int) error {
+	pl.closeCgroupFile()
+	limits := pl.limits
+	if limits.IsEmpty() {
+		return nil
+	}
+	var errs error
+	if limits.Nice != 0 {
+		if err := unix.Setpriority(unix.PRIO_PROCESS, pid, limits.Nice); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("set nice to %d error: %v", limits.Nice, err))
+		}
+	}
+	if limits.MaxOpenFiles > 0 {
+		if err := setRlimit(pid, unix.RLIMIT_NOFILE, limits.MaxOpenFiles); err != nil {
+			errs = errors.Join(errs, fmt.Errorf("set max open files to %d error: %v", limits.MaxOpenFiles, err))
+		}
+	}
+	if pl.cgroup == "" {
+		if limits.Memory > 0 {
+			if err := setRlimit(pid, unix.RLIMIT_AS, uint64(limits.Memory)<<20); err != nil {
+				errs = errors.Join(errs, fmt.Errorf("set memory limit to %dMB error: %v", limits.Memory, err))
+			} else {
+				pl.rlimitMemory = true
+			}
+		}
+		if limits.Cpu > 0 {
+			errs = errors.Join(errs, fmt.Errorf("cpu limit requires cgroup v2: %v", pl.cgroupErr))
+		}
+	}
+	return errs
+}
+
+func (pl *procLimiter) closeCgroupFile() {
+	if pl.cgroupFile != nil {
+		_ = pl.cgroupFile.Close()
+		pl.cgroupFile = nil
+	}
+}
+
+func setRlimit(pid int, resource int, value uint64) error {
+	return unix.Prlimit(pid, resource, &unix.Rlimit{Cur: value, Max: value}, nil)
+}
+
+func createCgroup(name string, limits *conf.PortableLimits) (_ string, e error) {
+	if conf.Config == nil || conf.Config.Portable.CgroupRoot == "" {
+		return "", errors.New("cgroupRoot is not set")
+	}
+	root := conf.Config.Portable.CgroupRoot
+	if _, err := os.Stat(filepath.Join(cgroupMount, "cgroup.controllers")); err != nil {
+		return "", errors.New("cgroup v2 is not mounted")
+	}
+	if !filepath.IsAbs(root) {
+		root = filepath.Join(cgroupMount, root)
+	}
+	// Enable the controllers for the children. It may fail if already enabled by the delegation so just ignore.
+	_ = os.WriteFile(filepath.Join(root, "cgroup.subtree_control"), []byte("+memory +cpu"), 0o644)
+	cg := filepath.Join(root, name)
+	if err := os.Mkdir(cg, 0o755); err != nil && !os.IsExist(err) {
+		return "", err
+	}
+	defer func() {
+		if e != nil {
+			_ = os.Remove(cg)
+		}
+	}()
+	if limits.Memory > 0 {
+		if err := writeCgroupFile(cg, "memory.max", strconv.FormatInt(limits.Memory<<20, 10)); err != nil {
+			return "", err
+		}
+	}
+	if limits.Cpu > 0 {
+		quota := int64(limits.Cpu * cgroupCpuPeriod)
+		if err := writeCgroupFile(cg, "cpu.max", fmt.Sprintf("%d %d", quota, cgroupCpuPeriod)); err != nil {
+			return "", err
+		}
+	}
+	return cg, nil
+}
+
+func writeCgroupFile(cg string, file string, value string) error {
+	err := os.WriteFile(filepath.Join(cg, file), []byte(value), 0o644)
+	if err != nil {
+		return fmt.Errorf("write %s to %s error: %v", value, file, err)
+	}
+	return nil
+}
+
+// violation returns the description of the violated limit which caused the process exit, or empty if not found.
+func (pl *procLimiter) violation(state *os.ProcessState) string {
+	if pl.limits.IsEmpty() {
+		return ""
+	}
+	if pl.cgroup != "" && pl.limits.Memory > 0 {
+		if n := readOOMKills(pl.cgroup); n > 0 {
+			return fmt.Sprintf("exceeded memory limit %dMB", pl.limits.Memory)
+		}
+	}
+	// Without the cgroup, a SIGKILL can only be attributed to the memory limit if the rlimit is in effect
+	if state == nil || !pl.rlimitMemory {
+		return ""
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() && ws.Signal() == syscall.SIGKILL {
+		return fmt.Sprintf("killed, probably exceeded memory limit %dMB", pl.limits.Memory)
+	}
+	return ""
+}
+
+func readOOMKills(cg string) int {
+	f, err := os.Open(filepath.Join(cg, "memory.events"))
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			n, _ := strconv.Atoi(fields[1])
+			return n
+		}
+	}
+	return 0
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+// +build linux
+
+package runtime
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+func TestApplyRlimits(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	limits := &conf.PortableLimits{
+		Memory:       512,
+		MaxOpenFiles: 64,
+		Nice:         5,
+	}
+	// cgroupRoot is not set, so the memory limit falls back to rlimit
+	pl := newLimiter("test", cmd, limits)
+	defer pl.release()
+	assert.Equal(t, "", pl.cgroup)
+	assert.Nil(t, cmd.SysProcAttr)
+	require.NoError(t, cmd.Start())
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+	require.NoError(t, pl.apply(cmd.Process.Pid))
+	assert.True(t, pl.rlimitMemory)
+
+	content, err := os.ReadFile("/proc/" + strconv.Itoa(cmd.Process.Pid) + "/limits")
+	require.NoError(t, err)
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case strings.HasPrefix(line, "Max open files"):
+			assert.Equal(t, "64", fields[3])
+		case strings.HasPrefix(line, "Max address space"):
+			assert.Equal(t, strconv.Itoa(512<<20), fields[3])
+		}
+	}
+}
+
+func TestViolation(t *testing.T) {
+	cmd := exec.Command("sleep", "10")
+	require.NoError(t, cmd.Start())
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+
+	pl := &procLimiter{name: "test", limits: &conf.PortableLimits{Memory: 64}, rlimitMemory: true}
+	assert.Equal(t, "killed, probably exceeded memory limit 64MB", pl.violation(cmd.ProcessState))
+	// The rlimit is not applied, so the kill is not caused by the memory limit
+	pl = &procLimiter{name: "test", limits: &conf.PortableLimits{Memory: 64}}
+	assert.Equal(t, "", pl.violation(cmd.ProcessState))
+	pl = &procLimiter{name: "test", limits: &conf.PortableLimits{MaxOpenFiles: 10}}
+	assert.Equal(t, "", pl.violation(cmd.ProcessState))
+	pl = &procLimiter{name: "test", limits: &conf.PortableLimits{}}
+	assert.Equal(t, "", pl.violation(cmd.ProcessState))
+}
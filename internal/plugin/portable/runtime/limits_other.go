@@ -0,0 +1,43 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+// +build !linux
+
+package runtime
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+func newLimiter(name string, _ *exec.Cmd, limits *conf.PortableLimits) *procLimiter {
+	return &procLimiter{name: name, limits: limits}
+}
+
+func (pl *procLimiter) apply(_ int) error {
+	if pl.limits.IsEmpty() {
+		return nil
+	}
+	return errors.New("resource limits are only supported on linux")
+}
+
+func (pl *procLimiter) closeCgroupFile() {}
+
+func (pl *procLimiter) violation(_ *os.ProcessState) string {
+	return ""
+}
@@ -49,8 +49,26 @@ type PluginIns struct {
 	// audit the commands, so that when restarting the plugin, we can replay the commands
 	commands map[Meta][]byte
 	process  *os.Process // created when used by rule and deleted when no rule uses it
+	limits   *conf.PortableLimits
+	// the error of the last unexpected process exit, reset when the process restarts
+	exitErr  error
+	stopping bool
 }
 
+// PluginStatus is the runtime status of the plugin process
+type PluginStatus struct {
+	Status string               `json:"status"`
+	Pid    int                  `json:"pid,omitempty"`
+	Limits *conf.PortableLimits `json:"limits,omitempty"`
+	ErrMsg string               `json:"errMsg,omitempty"`
+}
+
+const (
+	StatusRunning = "running"
+	StatusStopped = "stopped"
+	StatusError   = "error"
+)
+
 func NewPluginIns(name string, ctrlChan ControlChannel, process *os.Process) *PluginIns {
 	return &PluginIns{
 		process:  process,
@@ -149,14 +167,36 @@ func (i *PluginIns) StopSymbol(ctx api.StreamContext, ctrl *Control) error {
 // Stop intentionally
 func (i *PluginIns) Stop() error {
 	var err error
-	i.RLock()
-	defer i.RUnlock()
+	i.Lock()
+	defer i.Unlock()
 	if i.process != nil { // will also trigger process exit clean up
+		i.stopping = true
 		err = i.process.Kill()
 	}
 	return err
 }
 
+// Err returns the error if the plugin process exits unexpectedly such as violating the resource limits
+func (i *PluginIns) Err() error {
+	i.RLock()
+	defer i.RUnlock()
+	return i.exitErr
+}
+
+func (i *PluginIns) Status() *PluginStatus {
+	i.RLock()
+	defer i.RUnlock()
+	s := &PluginStatus{Status: StatusStopped, Limits: i.limits}
+	if i.process != nil {
+		s.Status = StatusRunning
+		s.Pid = i.process.Pid
+	} else if i.exitErr != nil {
+		s.Status = StatusError
+		s.ErrMsg = i.exitErr.Error()
+	}
+	return s
+}
+
 // Manager plugin process and control socket
 type pluginInsManager struct {
 	instances map[string]*PluginIns
@@ -179,6 +219,22 @@ func (p *pluginInsManager) getPluginIns(name string) (*PluginIns, bool) {
 	return ins, ok
 }
 
+// GetStatus returns the process status of the plugin
+func (p *pluginInsManager) GetStatus(name string) *PluginStatus {
+	if ins, ok := p.getPluginIns(name); ok {
+		return ins.Status()
+	}
+	return &PluginStatus{Status: StatusStopped}
+}
+
+// GetErr returns the error of the plugin process if it exits unexpectedly
+func (p *pluginInsManager) GetErr(name string) error {
+	if ins, ok := p.getPluginIns(name); ok {
+		return ins.Err()
+	}
+	return nil
+}
+
 // deletePluginIns should only run when there is no state aka. commands
 func (p *pluginInsManager) deletePluginIns(name string) {
 	p.Lock()
@@ -275,9 +331,13 @@ func (p *pluginInsManager) getOrStartProcess(pluginMeta *PluginMeta, pconf *Port
 	cmd.Stderr = conf.Log.Out
 	cmd.Dir = filepath.Dir(pluginMeta.Executable)
 
+	limits := resolveLimits(pluginMeta)
+	limiter := newLimiter(pluginMeta.Name, cmd, limits)
+
 	conf.Log.Println("plugin starting")
 	err = cmd.Start()
 	if err != nil {
+		limiter.release()
 		return nil, fmt.Errorf("plugin executable %s stops with error %v", pluginMeta.Executable, err)
 	}
 	process := cmd.Process
@@ -287,22 +347,56 @@ func (p *pluginInsManager) getOrStartProcess(pluginMeta *PluginMeta, pconf *Port
 			_ = process.Kill()
 		}
 	}()
+	err = limiter.apply(process.Pid)
+	if err != nil {
+		conf.Log.Warnf("fail to apply resource limits %+v to plugin %s: %v", limits, pluginMeta.Name, err)
+	} else if !limits.IsEmpty() {
+		conf.Log.Infof("applied resource limits %+v to plugin %s", limits, pluginMeta.Name)
+	}
 	go infra.SafeRun(func() error { // just print out error inside
-		err = cmd.Wait()
+		err := cmd.Wait()
+		ins, ok := p.getPluginIns(pluginMeta.Name)
+		stopping := false
+		if ok {
+			ins.RLock()
+			// The process may exit before it is set to the ins if the handshake fails
+			if ins.process == cmd.Process || ins.process == nil {
+				stopping = ins.stopping
+			}
+			ins.RUnlock()
+		}
+		// Stop kills the process by itself, so the kill is not caused by the limits
+		if !stopping {
+			if reason := limiter.violation(cmd.ProcessState); reason != "" {
+				conf.Log.Errorf("plugin %s violates resource limits: %s", pluginMeta.Name, reason)
+				err = fmt.Errorf("%s: %v", reason, err)
+			}
+		}
+		limiter.release()
 		if err != nil {
 			conf.Log.Printf("plugin executable %s stops with error %v", pluginMeta.Executable, err)
 		}
 		// must make sure the plugin ins is not cleaned up yet by checking the process identity
 		// clean up for stop unintentionally
-		if ins, ok := p.getPluginIns(pluginMeta.Name); ok && ins.process == cmd.Process {
+		if ok {
 			ins.Lock()
+			if ins.process != cmd.Process {
+				ins.Unlock()
+				return nil
+			}
 			if len(ins.commands) == 0 {
 				if ins.ctrlChan != nil {
 					_ = ins.ctrlChan.Close()
 				}
 				p.deletePluginIns(pluginMeta.Name)
+			} else if !ins.stopping {
+				if err == nil {
+					err = fmt.Errorf("exit unexpectedly")
+				}
+				ins.exitErr = fmt.Errorf("plugin %s process stopped: %v", pluginMeta.Name, err)
 			}
 			ins.process = nil
+			ins.stopping = false
 			ins.Unlock()
 		}
 		return nil
@@ -312,7 +406,11 @@ func (p *pluginInsManager) getOrStartProcess(pluginMeta *PluginMeta, pconf *Port
 	if err != nil {
 		return nil, fmt.Errorf("plugin %s control handshake error: %v", pluginMeta.Executable, err)
 	}
+	ins.Lock()
 	ins.process = process
+	ins.limits = limits
+	ins.exitErr = nil
+	ins.Unlock()
 	p.instances[pluginMeta.Name] = ins
 	conf.Log.Println("plugin start running")
 	// restore symbols by sending commands when restarting plugin
@@ -358,4 +456,6 @@ type PluginMeta struct {
 	Executable  string `json:"executable"`
 	VirtualType string `json:"virtualEnvType,omitempty"`
 	Env         string `json:"env,omitempty"`
+	// Limits override the default resource limits in the portable configuration
+	Limits *conf.PortableLimits `json:"limits,omitempty"`
 }
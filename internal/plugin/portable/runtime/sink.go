@@ -96,6 +96,9 @@ func (ps *PortableSink) Collect(ctx api.StreamContext, item interface{}) error {
 		ctx.GetLogger().Debugf("Send %s", val)
 		e := ps.dataCh.Send(val)
 		if e != nil {
+			if pe := GetPluginInsManager().GetErr(ps.reg.Name); pe != nil {
+				e = pe
+			}
 			return fmt.Errorf("%s:%s", errorx.IOErr, e)
 		}
 		return nil
@@ -103,6 +103,10 @@ func (ps *PortableSource) Open(ctx api.StreamContext, consumer chan<- api.Source
 			return
 		case mangos.ErrRecvTimeout:
 			ctx.GetLogger().Debug("source receive timeout, retry")
+			if e := ins.Err(); e != nil {
+				infra.DrainError(ctx, e, errCh)
+				return
+			}
 			select {
 			case <-ctx.Done():
 				ctx.GetLogger().Info("stop source")
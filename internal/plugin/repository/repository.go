@@ -0,0 +1,353 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package repository reads the local plugin repository for offline installation. The repository is a directory with
+// an index.json file which describes the plugins, their versions, checksums and signatures. The package files are
+// referred by path relative to the index file.
+package repository
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/plugin"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+const IndexFile = "index.json"
+
+type Index struct {
+	Plugins []*Plugin `json:"plugins"`
+}
+
+type Plugin struct {
+	Name string `json:"name"`
+	// Type is one of sources, sinks, functions, portable and wasm
+	Type        string     `json:"type"`
+	Description string     `json:"description,omitempty"`
+	Versions    []*Version `json:"versions"`
+}
+
+type Version struct {
+	Version string `json:"version"`
+	// File is the path of the zip file relative to the index file
+	File string `json:"file"`
+	// Sha256 is the hex encoded sha256 checksum of the zip file
+	Sha256 string `json:"sha256"`
+	// Signature is the base64 encoded ed25519 signature of the zip file
+	Signature string `json:"signature,omitempty"`
+	// Os and Arch are the platform of native plugins. Empty means any platform.
+	Os   string `json:"os,omitempty"`
+	Arch string `json:"arch,omitempty"`
+	// Functions are the exported functions of a function plugin
+	Functions []string `json:"functions,omitempty"`
+}
+
+type Repository struct {
+	dir           string
+	index         *Index
+	keys          []ed25519.PublicKey
+	allowUnsigned bool
+}
+
+// Open reads the repository index and the trusted keys by the configuration
+func Open(c *conf.PluginRepoConf) (*Repository, error) {
+	if c == nil || c.Location == "" {
+		return nil, fmt.Errorf("plugin repository is not configured")
+	}
+	p, err := indexPath(c.Location)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read plugin repository index %s: %v", p, err)
+	}
+	index := &Index{}
+	if err := json.Unmarshal(content, index); err != nil {
+		return nil, fmt.Errorf("invalid plugin repository index %s: %v", p, err)
+	}
+	for _, pl := range index.Plugins {
+		if _, ok := plugin.PluginTypeMap[pl.Type]; !ok {
+			return nil, fmt.Errorf("invalid type %s of plugin %s in the repository index", pl.Type, pl.Name)
+		}
+	}
+	r := &Repository{
+		dir:           filepath.Dir(p),
+		index:         index,
+		allowUnsigned: c.AllowUnsigned,
+	}
+	if c.TrustedKeys != "" {
+		r.keys, err = readKeys(c.TrustedKeys)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func indexPath(location string) (string, error) {
+	p := location
+	if strings.HasPrefix(location, "file://") {
+		u, err := url.Parse(location)
+		if err != nil {
+			return "", fmt.Errorf("invalid plugin repository location %s: %v", location, err)
+		}
+		p = u.Path
+	} else if strings.Contains(location, "://") {
+		return "", fmt.Errorf("invalid plugin repository location %s: only directory or file url is supported", location)
+	}
+	fi, err := os.Stat(p)
+	if err != nil {
+		return "", fmt.Errorf("cannot find plugin repository %s: %v", location, err)
+	}
+	if fi.IsDir() {
+		p = filepath.Join(p, IndexFile)
+	}
+	return p, nil
+}
+
+// readKeys reads all ed25519 public keys in PEM format from the directory
+func readKeys(dir string) ([]ed25519.PublicKey, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read trusted keys folder %s: %v", dir, err)
+	}
+	var keys []ed25519.PublicKey
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		for {
+			var block *pem.Block
+			block, content = pem.Decode(content)
+			if block == nil {
+				break
+			}
+			if block.Type != "PUBLIC KEY" {
+				continue
+			}
+			k, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid public key in %s: %v", f.Name(), err)
+			}
+			pk, ok := k.(ed25519.PublicKey)
+			if !ok {
+				return nil, fmt.Errorf("public key in %s is not an ed25519 key", f.Name())
+			}
+			keys = append(keys, pk)
+		}
+	}
+	return keys, nil
+}
+
+// List returns the plugins with the versions available for the current platform
+func (r *Repository) List() []*Plugin {
+	result := make([]*Plugin, 0, len(r.index.Plugins))
+	for _, p := range r.index.Plugins {
+		np := &Plugin{
+			Name:        p.Name,
+			Type:        p.Type,
+			Description: p.Description,
+			Versions:    make([]*Version, 0, len(p.Versions)),
+		}
+		for _, v := range p.Versions {
+			if v.matchPlatform() {
+				np.Versions = append(np.Versions, v)
+			}
+		}
+		result = append(result, np)
+	}
+	return result
+}
+
+// Find returns the plugin and the version. If version is empty, the latest version is returned.
+// If the plugin names are duplicate in different types, the type is required.
+func (r *Repository) Find(name, version, t string) (*Plugin, *Version, error) {
+	var found *Plugin
+	for _, p := range r.index.Plugins {
+		if p.Name != name || (t != "" && p.Type != t) {
+			continue
+		}
+		if found != nil {
+			return nil, nil, fmt.Errorf("plugin %s is found in both %s and %s, please specify the type", name, found.Type, p.Type)
+		}
+		found = p
+	}
+	if found == nil {
+		return nil, nil, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("plugin %s is not found in the repository", name))
+	}
+	var result *Version
+	for _, v := range found.Versions {
+		if !v.matchPlatform() {
+			continue
+		}
+		if version != "" {
+			if strings.TrimPrefix(v.Version, "v") == strings.TrimPrefix(version, "v") {
+				return found, v, nil
+			}
+		} else if result == nil || compareVersion(v.Version, result.Version) > 0 {
+			result = v
+		}
+	}
+	if result == nil {
+		if version != "" {
+			return nil, nil, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("version %s of plugin %s is not found in the repository for %s/%s", version, name, runtime.GOOS, runtime.GOARCH))
+		}
+		return nil, nil, errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("no version of plugin %s is available for %s/%s", name, runtime.GOOS, runtime.GOARCH))
+	}
+	return found, result, nil
+}
+
+// Fetch verifies the package of the version and writes it to the target path. Nothing is written if the
+// verification fails.
+func (r *Repository) Fetch(v *Version, target string) error {
+	p := v.File
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(r.dir, p)
+	}
+	content, err := os.ReadFile(p)
+	if err != nil {
+		return fmt.Errorf("cannot read package %s: %v", v.File, err)
+	}
+	if err := r.verify(content, v); err != nil {
+		return fmt.Errorf("verify package %s failed: %v", v.File, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(target, content, 0o644)
+}
+
+func (r *Repository) verify(content []byte, v *Version) error {
+	if v.Sha256 == "" {
+		return fmt.Errorf("missing sha256 checksum")
+	}
+	sum := sha256.Sum256(content)
+	expected, err := hex.DecodeString(v.Sha256)
+	if err != nil {
+		return fmt.Errorf("invalid sha256 checksum %s", v.Sha256)
+	}
+	if !bytes.Equal(sum[:], expected) {
+		return fmt.Errorf("checksum mismatch, expect %s but got %s", v.Sha256, hex.EncodeToString(sum[:]))
+	}
+	if v.Signature == "" {
+		if r.allowUnsigned {
+			conf.Log.Warnf("install unsigned package %s", v.File)
+			return nil
+		}
+		return fmt.Errorf("missing signature")
+	}
+	sig, err := base64.StdEncoding.DecodeString(v.Signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %v", err)
+	}
+	if len(r.keys) == 0 {
+		return fmt.Errorf("no trusted key is configured")
+	}
+	for _, k := range r.keys {
+		if ed25519.Verify(k, content, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("signature is not signed by any trusted key")
+}
+
+func (v *Version) matchPlatform() bool {
+	return (v.Os == "" || v.Os == runtime.GOOS) && (v.Arch == "" || v.Arch == runtime.GOARCH)
+}
+
+// compareVersion compares the versions by the semver precedence. The dot separated parts are compared numerically and
+// the non-numeric parts are compared as string. A pre-release such as 1.0.0-rc1 is lower than its release 1.0.0.
+// The build metadata after + is ignored.
+func compareVersion(a, b string) int {
+	acore, apre := splitVersion(a)
+	bcore, bpre := splitVersion(b)
+	if c := compareParts(strings.Split(acore, "."), strings.Split(bcore, ".")); c != 0 {
+		return c
+	}
+	switch {
+	case apre == bpre:
+		return 0
+	case apre == "":
+		return 1
+	case bpre == "":
+		return -1
+	}
+	return compareParts(strings.Split(apre, "."), strings.Split(bpre, "."))
+}
+
+// splitVersion returns the core version and the pre-release of the version
+func splitVersion(v string) (string, string) {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexByte(v, '+'); i >= 0 {
+		v = v[:i]
+	}
+	if i := strings.IndexByte(v, '-'); i >= 0 {
+		return v[:i], v[i+1:]
+	}
+	return v, ""
+}
+
+// compareParts compares the numeric parts numerically which are lower than the non-numeric parts.
+// If all the parts are equal, the one with more parts is higher.
+func compareParts(as, bs []string) int {
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		if i >= len(as) {
+			return -1
+		}
+		if i >= len(bs) {
+			return 1
+		}
+		x, y := as[i], bs[i]
+		xi, xerr := strconv.Atoi(x)
+		yi, yerr := strconv.Atoi(y)
+		switch {
+		case xerr == nil && yerr == nil:
+			if xi != yi {
+				if xi > yi {
+					return 1
+				}
+				return -1
+			}
+		case xerr == nil:
+			return -1
+		case yerr == nil:
+			return 1
+		default:
+			if c := strings.Compare(x, y); c != 0 {
+				return c
+			}
+		}
+	}
+	return 0
+}
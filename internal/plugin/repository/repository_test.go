@@ -0,0 +1,147 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repository
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+)
+
+func prepareRepo(t *testing.T) (string, string, ed25519.PrivateKey) {
+	dir := t.TempDir()
+	keyDir := t.TempDir()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(keyDir, "trusted.pem"), pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o644)
+	require.NoError(t, err)
+
+	content := []byte("fake zip content")
+	sum := sha256.Sum256(content)
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "sources"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sources", "random.zip"), content, 0o644))
+	index := &Index{Plugins: []*Plugin{
+		{
+			Name: "random",
+			Type: "sources",
+			Versions: []*Version{
+				{Version: "1.0.0", File: "sources/random.zip", Sha256: hex.EncodeToString(sum[:]), Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, content))},
+				{Version: "1.10.0", File: "sources/random.zip", Sha256: hex.EncodeToString(sum[:]), Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, content)), Os: runtime.GOOS},
+				{Version: "1.2.0", File: "sources/random.zip", Sha256: hex.EncodeToString(sum[:])},
+				{Version: "2.0.0", File: "sources/random.zip", Sha256: hex.EncodeToString(sum[:]), Os: "unknownOS"},
+				{Version: "3.0.0", File: "sources/random.zip", Sha256: "abcd", Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, content)), Arch: runtime.GOARCH},
+				{Version: "4.0.0", File: "sources/random.zip", Sha256: hex.EncodeToString(sum[:]), Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte("other")))},
+			},
+		},
+		{
+			Name: "random",
+			Type: "portable",
+		},
+	}}
+	b, err := json.Marshal(index)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, IndexFile), b, 0o644))
+	return dir, keyDir, priv
+}
+
+func TestRepository(t *testing.T) {
+	dir, keyDir, _ := prepareRepo(t)
+	_, err := Open(&conf.PluginRepoConf{})
+	assert.EqualError(t, err, "plugin repository is not configured")
+	_, err = Open(&conf.PluginRepoConf{Location: "http://127.0.0.1/index.json"})
+	assert.EqualError(t, err, "invalid plugin repository location http://127.0.0.1/index.json: only directory or file url is supported")
+
+	r, err := Open(&conf.PluginRepoConf{Location: "file://" + filepath.Join(dir, IndexFile), TrustedKeys: keyDir})
+	require.NoError(t, err)
+	l := r.List()
+	require.Len(t, l, 2)
+	assert.Len(t, l[0].Versions, 5)
+
+	_, _, err = r.Find("random", "", "")
+	assert.EqualError(t, err, "plugin random is found in both sources and portable, please specify the type")
+	_, _, err = r.Find("none", "", "")
+	assert.EqualError(t, err, "plugin none is not found in the repository")
+	_, _, err = r.Find("random", "2.0.0", "sources")
+	assert.Error(t, err)
+	p, v, err := r.Find("random", "", "sources")
+	require.NoError(t, err)
+	assert.Equal(t, "sources", p.Type)
+	assert.Equal(t, "4.0.0", v.Version)
+
+	tests := []struct {
+		version string
+		err     string
+	}{
+		{version: "1.0.0"},
+		{version: "v1.10.0"},
+		{version: "1.2.0", err: "verify package sources/random.zip failed: missing signature"},
+		{version: "3.0.0", err: "verify package sources/random.zip failed: checksum mismatch, expect abcd but got "},
+		{version: "4.0.0", err: "verify package sources/random.zip failed: signature is not signed by any trusted key"},
+	}
+	for _, tt := range tests {
+		_, v, err := r.Find("random", tt.version, "sources")
+		require.NoError(t, err, tt.version)
+		target := filepath.Join(t.TempDir(), "random.zip")
+		err = r.Fetch(v, target)
+		if tt.err == "" {
+			assert.NoError(t, err, tt.version)
+			assert.FileExists(t, target)
+		} else {
+			require.Error(t, err, tt.version)
+			assert.Contains(t, err.Error(), tt.err, tt.version)
+			assert.NoFileExists(t, target)
+		}
+	}
+
+	// allow unsigned
+	r, err = Open(&conf.PluginRepoConf{Location: dir, AllowUnsigned: true})
+	require.NoError(t, err)
+	_, v, err = r.Find("random", "1.2.0", "sources")
+	require.NoError(t, err)
+	assert.NoError(t, r.Fetch(v, filepath.Join(t.TempDir(), "random.zip")))
+	_, v, err = r.Find("random", "1.0.0", "sources")
+	require.NoError(t, err)
+	assert.EqualError(t, r.Fetch(v, filepath.Join(t.TempDir(), "random.zip")), "verify package sources/random.zip failed: no trusted key is configured")
+}
+
+func TestCompareVersion(t *testing.T) {
+	assert.Equal(t, 1, compareVersion("1.10.0", "1.9.0"))
+	assert.Equal(t, -1, compareVersion("v1.0.0", "1.0.1"))
+	assert.Equal(t, 0, compareVersion("v1.0.0", "1.0.0"))
+	assert.Equal(t, 1, compareVersion("1.0.0-rc2", "1.0.0-rc1"))
+	assert.Equal(t, -1, compareVersion("1.0.0-rc1", "1.0.0"))
+	assert.Equal(t, 1, compareVersion("v1.0.0", "1.0.0-rc1"))
+	assert.Equal(t, 1, compareVersion("1.0.1-alpha", "1.0.0"))
+	assert.Equal(t, -1, compareVersion("1.0.0-alpha", "1.0.0-alpha.1"))
+	assert.Equal(t, -1, compareVersion("1.0.0-alpha.2", "1.0.0-alpha.10"))
+	assert.Equal(t, -1, compareVersion("1.0.0-1", "1.0.0-alpha"))
+	assert.Equal(t, 0, compareVersion("1.0.0+build1", "1.0.0+build2"))
+}
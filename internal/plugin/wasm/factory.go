@@ -21,12 +21,50 @@ import (
 	"github.com/lf-edge/ekuiper/pkg/api"
 )
 
+func (m *Manager) Source(name string) (api.Source, error) {
+	meta, ok := m.GetPluginMeta(plugin.SOURCE, name)
+	if !ok {
+		return nil, nil
+	}
+	return runtime.NewWasmSource(name, meta), nil
+}
+
+func (m *Manager) SourcePluginInfo(name string) (plugin.EXTENSION_TYPE, string, string) {
+	pluginName, ok := m.reg.GetSymbol(plugin.SOURCE, name)
+	if ok {
+		return plugin.WASM_EXTENSION, pluginName, ""
+	} else {
+		return plugin.NONE_EXTENSION, "", ""
+	}
+}
+
+func (m *Manager) LookupSource(_ string) (api.LookupSource, error) {
+	return nil, nil
+}
+
+func (m *Manager) Sink(name string) (api.Sink, error) {
+	meta, ok := m.GetPluginMeta(plugin.SINK, name)
+	if !ok {
+		return nil, nil
+	}
+	return runtime.NewWasmSink(name, meta), nil
+}
+
+func (m *Manager) SinkPluginInfo(name string) (plugin.EXTENSION_TYPE, string, string) {
+	pluginName, ok := m.reg.GetSymbol(plugin.SINK, name)
+	if ok {
+		return plugin.WASM_EXTENSION, pluginName, ""
+	} else {
+		return plugin.NONE_EXTENSION, "", ""
+	}
+}
+
 func (m *Manager) Function(name string) (api.Function, error) {
 	meta, ok := m.GetPluginMeta(plugin.FUNCTION, name)
 	if !ok {
 		return nil, nil
 	}
-	f, err := runtime.NewWasmFunc(name, meta)
+	f, err := runtime.NewWasmFunc(name, meta, m.GetFuncDef(meta.Name, name))
 	if err != nil {
 		conf.Log.Errorf("Error creating function %v", err)
 		return nil, err
@@ -60,6 +60,8 @@ func InitManager() (*Manager, error) {
 	registry := &registry{
 		RWMutex:   sync.RWMutex{},
 		plugins:   make(map[string]*PluginInfo),
+		sources:   make(map[string]string),
+		sinks:     make(map[string]string),
 		functions: make(map[string]string),
 	}
 	// Read plugin info from file system
@@ -85,6 +87,8 @@ func MockManager(plugins map[string]*PluginInfo) (*Manager, error) {
 	registry := &registry{
 		RWMutex:   sync.RWMutex{},
 		plugins:   make(map[string]*PluginInfo),
+		sources:   make(map[string]string),
+		sinks:     make(map[string]string),
 		functions: make(map[string]string),
 	}
 	for name, pi := range plugins {
@@ -96,6 +100,12 @@ func MockManager(plugins map[string]*PluginInfo) (*Manager, error) {
 		fmt.Println("wasmPath:", wasmPath)
 		pi.WasmFile = wasmPath
 		registry.plugins[name] = pi
+		for _, s := range pi.Sources {
+			registry.sources[s] = name
+		}
+		for _, s := range pi.Sinks {
+			registry.sinks[s] = name
+		}
 		for _, s := range pi.Functions {
 			registry.functions[s] = name
 		}
@@ -209,6 +219,33 @@ func (m *Manager) GetPluginMeta(pt plugin.PluginType, symbolName string) (*runti
 	return &pinfo.PluginMeta, true
 }
 
+// GetFuncDef reads the function definition from the function metadata file functions/{name}.json or
+// functions/{pluginName}.json. Return nil if not found.
+func (m *Manager) GetFuncDef(pluginName string, funcName string) *runtime.FuncDef {
+	if m.etcDir == "" {
+		return nil
+	}
+	for _, fname := range []string{funcName, pluginName} {
+		fm := &struct {
+			Functions []*runtime.FuncDef `json:"functions"`
+		}{}
+		p := path.Join(m.etcDir, plugin.PluginTypes[plugin.FUNCTION], fname+".json")
+		if _, err := os.Stat(p); err != nil {
+			continue
+		}
+		if err := filex.ReadJsonUnmarshal(p, fm); err != nil {
+			conf.Log.Warnf("invalid function metadata file %s: %v", p, err)
+			continue
+		}
+		for _, def := range fm.Functions {
+			if def != nil && def.Name == funcName {
+				return def
+			}
+		}
+	}
+	return nil
+}
+
 func (m *Manager) GetPluginInfo(pluginName string) (*PluginInfo, bool) {
 	pinfo, ok := m.reg.Get(pluginName)
 	if !ok {
@@ -346,6 +383,16 @@ func (m *Manager) Delete(name string) error {
 	// unregister the plugin
 	m.reg.Delete(name)
 	// delete files and uninstall metas
+	for _, s := range pinfo.Sources {
+		p := path.Join(m.etcDir, plugin.PluginTypes[plugin.SOURCE], s+".yaml")
+		os.Remove(p)
+		p = path.Join(m.etcDir, plugin.PluginTypes[plugin.SOURCE], s+".json")
+		os.Remove(p)
+	}
+	for _, s := range pinfo.Sinks {
+		p := path.Join(m.etcDir, plugin.PluginTypes[plugin.SINK], s+".json")
+		os.Remove(p)
+	}
 	for _, s := range pinfo.Functions {
 		p := path.Join(m.etcDir, plugin.PluginTypes[plugin.FUNCTION], s+".json")
 		os.Remove(p)
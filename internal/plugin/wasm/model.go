@@ -22,6 +22,8 @@ import (
 
 type PluginInfo struct {
 	runtime.PluginMeta
+	Sources   []string `json:"sources"`
+	Sinks     []string `json:"sinks"`
 	Functions []string `json:"functions"`
 }
 
@@ -29,11 +31,16 @@ func (p *PluginInfo) Validate(expectedName string) error {
 	if p.Name != expectedName {
 		return fmt.Errorf("invalid plugin, expect name '%s' but got '%s'", expectedName, p.Name)
 	}
-	if len(p.Functions) == 0 {
-		return fmt.Errorf("invalid plugin, must define at lease one function")
+	if len(p.Sources)+len(p.Sinks)+len(p.Functions) == 0 {
+		return fmt.Errorf("invalid plugin, must define at lease one source, sink or function")
 	}
 	if p.WasmEngine == "" {
 		return fmt.Errorf("invalid WasmEngine")
 	}
+	switch p.Abi {
+	case "", runtime.AbiRaw, runtime.AbiJson:
+	default:
+		return fmt.Errorf("invalid plugin, abi '%s' is not supported", p.Abi)
+	}
 	return nil
 }
@@ -46,7 +46,7 @@ func TestValidate(t *testing.T) {
 					WasmEngine: "wasmedge",
 				},
 			},
-			err: "invalid plugin, must define at lease one function",
+			err: "invalid plugin, must define at lease one source, sink or function",
 		}, { // 2
 			p: &PluginInfo{
 				PluginMeta: runtime.PluginMeta{
@@ -67,6 +67,29 @@ func TestValidate(t *testing.T) {
 				Functions: []string{"fib"},
 			},
 			err: "invalid plugin, expect name 'fibonacci' but got 'wrong'",
+		}, { // 4
+			p: &PluginInfo{
+				PluginMeta: runtime.PluginMeta{
+					Name:       "fibonacci",
+					Version:    "1.0.0",
+					WasmEngine: "wasmedge",
+					Abi:        "json",
+				},
+				Sources: []string{"fibs"},
+				Sinks:   []string{"fibk"},
+			},
+			err: "",
+		}, { // 5
+			p: &PluginInfo{
+				PluginMeta: runtime.PluginMeta{
+					Name:       "fibonacci",
+					Version:    "1.0.0",
+					WasmEngine: "wasmedge",
+					Abi:        "protobuf",
+				},
+				Functions: []string{"fib"},
+			},
+			err: "invalid plugin, abi 'protobuf' is not supported",
 		},
 	}
 	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
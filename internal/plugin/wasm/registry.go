@@ -26,6 +26,8 @@ import (
 type registry struct {
 	sync.RWMutex
 	plugins   map[string]*PluginInfo
+	sources   map[string]string
+	sinks     map[string]string
 	functions map[string]string
 }
 
@@ -40,6 +42,12 @@ func (r *registry) Set(name string, pi *PluginInfo) {
 	wasmPath := filepath.Join(pluginDir, "wasm", name, name+".wasm")
 	pi.WasmFile = wasmPath
 	r.plugins[name] = pi
+	for _, s := range pi.Sources {
+		r.sources[s] = name
+	}
+	for _, s := range pi.Sinks {
+		r.sinks[s] = name
+	}
 	for _, s := range pi.Functions {
 		r.functions[s] = name
 	}
@@ -53,7 +61,15 @@ func (r *registry) Get(name string) (*PluginInfo, bool) {
 }
 
 func (r *registry) GetSymbol(pt plugin.PluginType, symbolName string) (string, bool) {
+	r.RLock()
+	defer r.RUnlock()
 	switch pt {
+	case plugin.SOURCE:
+		s, ok := r.sources[symbolName]
+		return s, ok
+	case plugin.SINK:
+		s, ok := r.sinks[symbolName]
+		return s, ok
 	case plugin.FUNCTION:
 		s, ok := r.functions[symbolName]
 		return s, ok
@@ -81,6 +97,12 @@ func (r *registry) Delete(name string) {
 		return
 	}
 	delete(r.plugins, name)
+	for _, s := range pi.Sources {
+		delete(r.sources, s)
+	}
+	for _, s := range pi.Sinks {
+		delete(r.sinks, s)
+	}
 	for _, s := range pi.Functions {
 		delete(r.functions, s)
 	}
@@ -0,0 +1,126 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/internal/topo/transform"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// The module is built from testdata/abi.wat
+var abiMeta = &PluginMeta{
+	Name:       "abi",
+	Version:    "v1",
+	WasmFile:   "testdata/abi.wasm",
+	WasmEngine: "wasmedge",
+	Abi:        AbiJson,
+}
+
+func abiContext(t *testing.T) api.StreamContext {
+	tf, err := transform.GenTransform("", "json", "", "", "", []string{})
+	require.NoError(t, err)
+	c := context.WithValue(context.Background(), context.LoggerKey, conf.Log)
+	c = context.WithValue(c, context.TransKey, tf)
+	return c.WithMeta("rule1", "op1", &state.MemoryStore{}).WithInstance(0)
+}
+
+func TestWasmSource(t *testing.T) {
+	ctx, cancel := abiContext(t).WithCancel()
+	defer cancel()
+	src := NewWasmSource("demo", abiMeta)
+	require.NoError(t, src.Configure("topic1", map[string]interface{}{"interval": 10}))
+	consumer := make(chan api.SourceTuple)
+	errCh := make(chan error, 1)
+	go src.Open(ctx, consumer, errCh)
+	for i := 0; i < 2; i++ {
+		select {
+		case tuple := <-consumer:
+			assert.Equal(t, map[string]interface{}{"n": float64(1)}, tuple.Message())
+			assert.Equal(t, map[string]interface{}{"topic": "demo"}, tuple.Meta())
+		case err := <-errCh:
+			t.Fatal(err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timeout to pull from the wasm source")
+		}
+	}
+	cancel()
+	require.NoError(t, src.Close(ctx))
+}
+
+func TestWasmSourceOpenError(t *testing.T) {
+	ctx := abiContext(t)
+	src := NewWasmSource("missing", abiMeta)
+	require.NoError(t, src.Configure("topic1", map[string]interface{}{}))
+	errCh := make(chan error, 1)
+	src.Open(ctx, make(chan api.SourceTuple), errCh)
+	select {
+	case err := <-errCh:
+		assert.Contains(t, err.Error(), "open wasm source missing error")
+	default:
+		t.Fatal("expect open error")
+	}
+	assert.Nil(t, src.ins)
+	// The instance is released, so close does nothing
+	require.NoError(t, src.Close(ctx))
+}
+
+func TestWasmSink(t *testing.T) {
+	ctx := abiContext(t)
+	sink := NewWasmSink("keep", abiMeta)
+	require.NoError(t, sink.Configure(map[string]interface{}{}))
+	require.NoError(t, sink.Open(ctx))
+	require.NoError(t, sink.Collect(ctx, map[string]interface{}{"a": 1}))
+	last, err := ctx.GetState("last")
+	require.NoError(t, err)
+	assert.Equal(t, `[{"a":1}]`, last)
+	require.NoError(t, sink.Collect(ctx, []map[string]interface{}{{"a": 2}, {"a": 3}}))
+	last, err = ctx.GetState("last")
+	require.NoError(t, err)
+	assert.Equal(t, `[{"a":2},{"a":3}]`, last)
+	require.NoError(t, sink.Close(ctx))
+}
+
+func TestWasmStatefulFunc(t *testing.T) {
+	ctx := abiContext(t)
+	f, err := NewWasmFunc("count", abiMeta, nil)
+	require.NoError(t, err)
+	fctx := context.NewDefaultFuncContext(ctx, 1)
+	for i := 1; i <= 3; i++ {
+		r, ok := f.Exec([]interface{}{}, fctx)
+		require.True(t, ok, r)
+		assert.Equal(t, float64(i), r)
+	}
+	v, err := fctx.GetState("count")
+	require.NoError(t, err)
+	assert.Equal(t, "3", v)
+	require.NoError(t, f.Close())
+	assert.Nil(t, f.ins)
+
+	f, err = NewWasmFunc("fail", abiMeta, nil)
+	require.NoError(t, err)
+	r, ok := f.Exec([]interface{}{}, fctx)
+	assert.False(t, ok)
+	assert.EqualError(t, r.(error), "boom")
+	require.NoError(t, f.Close())
+}
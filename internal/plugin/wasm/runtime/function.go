@@ -17,7 +17,7 @@ package runtime
 
 import (
 	"fmt"
-	"log"
+	"sync"
 
 	"github.com/second-state/WasmEdge-go/wasmedge"
 
@@ -28,28 +28,41 @@ import (
 type WasmFunc struct {
 	symbolName string
 	reg        *PluginMeta
+	def        *FuncDef // the function definition from the metadata file, may be nil
 	isAgg      int
+	// the long-running instance for json abi, created at the first execution
+	ins *wasmInstance
+	// guard the creation and release of ins
+	insLock sync.Mutex
 }
 
-func NewWasmFunc(symbolName string, reg *PluginMeta) (*WasmFunc, error) {
+func NewWasmFunc(symbolName string, reg *PluginMeta, def *FuncDef) (*WasmFunc, error) {
 	// Setup channel and route the data
 	conf.Log.Infof("Start running  wasm function meta %+v", reg)
-
-	return &WasmFunc{
+	f := &WasmFunc{
 		symbolName: symbolName,
 		reg:        reg,
-	}, nil
+		def:        def,
+	}
+	if def != nil && def.Aggregate {
+		f.isAgg = 2
+	}
+	return f, nil
 }
 
+// Validate the arguments by the function definition in the metadata file
 func (f *WasmFunc) Validate(args []interface{}) error {
-	if len(args) == 0 {
-		fmt.Println("[plugin][wasm][runtime][Validate] args is null")
-	}
-	var err error
-	return err
+	return validateArgs(f.def, args)
 }
 
 func (f *WasmFunc) Exec(args []interface{}, ctx api.FunctionContext) (interface{}, bool) {
+	if f.reg.Abi == AbiJson {
+		res, err := f.execJson(args, ctx)
+		if err != nil {
+			return err, false
+		}
+		return res, true
+	}
 	res, err := f.ExecWasmFunc(args)
 	if err != nil {
 		return err, false
@@ -57,6 +70,30 @@ func (f *WasmFunc) Exec(args []interface{}, ctx api.FunctionContext) (interface{
 	return res, true
 }
 
+// execJson runs the function in a long-running instance so that it can keep state by the host functions
+func (f *WasmFunc) execJson(args []interface{}, ctx api.FunctionContext) (interface{}, error) {
+	ins, err := f.instance()
+	if err != nil {
+		return nil, err
+	}
+	ins.Lock()
+	defer ins.Unlock()
+	return ins.call(ctx, f.symbolName, args)
+}
+
+func (f *WasmFunc) instance() (*wasmInstance, error) {
+	f.insLock.Lock()
+	defer f.insLock.Unlock()
+	if f.ins == nil {
+		ins, err := newWasmInstance(f.reg)
+		if err != nil {
+			return nil, err
+		}
+		f.ins = ins
+	}
+	return f.ins, nil
+}
+
 func (f *WasmFunc) IsAggregate() bool {
 	if f.isAgg > 0 {
 		return f.isAgg > 1
@@ -64,6 +101,19 @@ func (f *WasmFunc) IsAggregate() bool {
 	return false
 }
 
+// Close releases the long-running instance if created
+func (f *WasmFunc) Close() error {
+	f.insLock.Lock()
+	defer f.insLock.Unlock()
+	if f.ins != nil {
+		f.ins.Lock()
+		f.ins.release()
+		f.ins.Unlock()
+		f.ins = nil
+	}
+	return nil
+}
+
 func toWasmEdgeValueSlideBindgen(vm *wasmedge.VM, modname *string, vals ...interface{}) ([]interface{}, error) {
 	rvals := []interface{}{}
 
@@ -175,28 +225,28 @@ func (f *WasmFunc) ExecWasmFunc(args []interface{}) ([]interface{}, error) {
 	funcname := f.symbolName
 
 	WasmFile := f.reg.WasmFile
-	fmt.Println("[wasm][ExecWasmFunc] WasmFile: ", WasmFile)
+	conf.Log.Debugf("[wasm][ExecWasmFunc] WasmFile: %s", WasmFile)
 	conf1 := wasmedge.NewConfigure(wasmedge.WASI)
+	defer conf1.Release()
 	store := wasmedge.NewStore()
+	defer store.Release()
 	vm := wasmedge.NewVMWithConfigAndStore(conf1, store)
+	defer vm.Release()
 	wasi := vm.GetImportModule(wasmedge.WASI)
 	// step 1: Load WASM file
 	err := vm.LoadWasmFile(WasmFile)
 	if err != nil {
-		fmt.Print("[wasm][ExecWasmFunc] Load WASM from file FAILED: ")
-		return nil, err
+		return nil, fmt.Errorf("load wasm file %s error: %v", WasmFile, err)
 	}
 	// step 2: Validate the WASM module
 	err = vm.Validate()
 	if err != nil {
-		fmt.Print("[wasm][manager-AddWasmPlugin-NewWasmPlugin] Validate FAILED: ")
-		return nil, err
+		return nil, fmt.Errorf("validate wasm file %s error: %v", WasmFile, err)
 	}
 	// step 3: Instantiate the WASM moudle
 	err = vm.Instantiate()
 	if err != nil {
-		fmt.Print("[wasm][manager-AddWasmPlugin-NewWasmPlugin] Instantiate FAILED: ")
-		return nil, err
+		return nil, fmt.Errorf("instantiate wasm file %s error: %v", WasmFile, err)
 	}
 	// step 4: Execute WASM functions.Parameters(1)
 	Args, err := toWasmEdgeValueSlideBindgen(vm, nil, args...)
@@ -207,16 +257,11 @@ func (f *WasmFunc) ExecWasmFunc(args []interface{}) ([]interface{}, error) {
 	var res []interface{}
 	res, err = vm.Execute(funcname, Args...)
 	if err != nil {
-		log.Fatalln("[wasm][manager-AddWasmPlugin-NewWasmPlugin] Run function failed： ", err.Error())
-		return nil, err
-	} else {
-		fmt.Print("[wasm][manager-AddWasmPlugin-NewWasmPlugin] Get res: ")
-		fmt.Println(res[0])
+		return nil, fmt.Errorf("run wasm function %s error: %v", funcname, err)
 	}
 	exitcode := wasi.WasiGetExitCode()
 	if exitcode != 0 {
-		fmt.Println("Go: Running wasm failed, exit code:", exitcode)
+		conf.Log.Warnf("running wasm function %s failed, exit code: %d", funcname, exitcode)
 	}
-	vm.Release()
 	return res, nil
 }
@@ -0,0 +1,340 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/second-state/WasmEdge-go/wasmedge"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// HostModule is the name of the import module provided by eKuiper to the wasm plugins
+const HostModule = "ekuiper"
+
+// wasmInstance is a long-running wasm vm of a plugin. It keeps the memory of the wasm module between calls,
+// so that the sources, sinks and stateful functions can keep their state.
+//
+// The json abi of the wasm module:
+//   - The module must export `malloc(size i32) i32` and may export `free(ptr i32, size i32)`.
+//   - The functions receive (ptr i32, len i32) of a json input in the module memory and return an i64 in which the
+//     high 32 bits is the pointer and the low 32 bits is the length of a json FuncReply.
+//   - The module can import the host functions in the `ekuiper` module to access the state and log.
+//
+// The instance is not thread safe, the caller must lock it.
+type wasmInstance struct {
+	sync.Mutex
+	name string
+	vm   *wasmedge.VM
+	conf *wasmedge.Configure
+	host *wasmedge.Module
+	// the context of the current call, used by the host functions
+	ctx api.StreamContext
+}
+
+func newWasmInstance(reg *PluginMeta) (_ *wasmInstance, e error) {
+	w := &wasmInstance{name: reg.Name}
+	w.conf = wasmedge.NewConfigure(wasmedge.WASI)
+	w.vm = wasmedge.NewVMWithConfig(w.conf)
+	defer func() {
+		if e != nil {
+			w.release()
+		}
+	}()
+	wasi := w.vm.GetImportModule(wasmedge.WASI)
+	wasi.InitWasi(nil, nil, nil)
+	w.host = w.newHostModule()
+	if err := w.vm.RegisterModule(w.host); err != nil {
+		return nil, fmt.Errorf("register host module error: %v", err)
+	}
+	if err := w.vm.LoadWasmFile(reg.WasmFile); err != nil {
+		return nil, fmt.Errorf("load wasm file %s error: %v", reg.WasmFile, err)
+	}
+	if err := w.vm.Validate(); err != nil {
+		return nil, fmt.Errorf("validate wasm file %s error: %v", reg.WasmFile, err)
+	}
+	if err := w.vm.Instantiate(); err != nil {
+		return nil, fmt.Errorf("instantiate wasm file %s error: %v", reg.WasmFile, err)
+	}
+	if !w.hasFunc("malloc") {
+		return nil, fmt.Errorf("wasm plugin %s must export malloc function", reg.Name)
+	}
+	return w, nil
+}
+
+func (w *wasmInstance) hasFunc(name string) bool {
+	mod := w.vm.GetActiveModule()
+	return mod != nil && mod.FindFunction(name) != nil
+}
+
+// call runs the exported function with the json abi and returns the result of the reply
+func (w *wasmInstance) call(ctx api.StreamContext, funcName string, arg interface{}) (interface{}, error) {
+	if w.vm == nil {
+		return nil, fmt.Errorf("wasm plugin %s instance is released", w.name)
+	}
+	w.ctx = ctx
+	defer func() {
+		w.ctx = nil
+	}()
+	input, err := json.Marshal(arg)
+	if err != nil {
+		return nil, fmt.Errorf("encode input error: %v", err)
+	}
+	ptr, err := w.writeBytes(input)
+	if err != nil {
+		return nil, err
+	}
+	defer w.free(ptr, int32(len(input)))
+	rets, err := w.vm.Execute(funcName, ptr, int32(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("run wasm function %s error: %v", funcName, err)
+	}
+	if len(rets) != 1 {
+		return nil, fmt.Errorf("wasm function %s must return a single i64", funcName)
+	}
+	packed, ok := rets[0].(int64)
+	if !ok {
+		return nil, fmt.Errorf("wasm function %s must return i64 but got %T", funcName, rets[0])
+	}
+	rptr, rlen := int32(uint64(packed)>>32), int32(uint64(packed)&0xffffffff)
+	output, err := w.readBytes(rptr, rlen)
+	if err != nil {
+		return nil, err
+	}
+	w.free(rptr, rlen)
+	reply := &FuncReply{}
+	if err := json.Unmarshal(output, reply); err != nil {
+		return nil, fmt.Errorf("invalid reply %s of wasm function %s: %v", string(output), funcName, err)
+	}
+	if !reply.State {
+		return nil, fmt.Errorf("%v", reply.Result)
+	}
+	return reply.Result, nil
+}
+
+func (w *wasmInstance) memory() (*wasmedge.Memory, error) {
+	mod := w.vm.GetActiveModule()
+	if mod == nil {
+		return nil, fmt.Errorf("wasm module is not instantiated")
+	}
+	memnames := mod.ListMemory()
+	if len(memnames) <= 0 {
+		return nil, fmt.Errorf("memory instance not found")
+	}
+	return mod.FindMemory(memnames[0]), nil
+}
+
+func (w *wasmInstance) writeBytes(data []byte) (int32, error) {
+	rets, err := w.vm.Execute("malloc", int32(len(data)))
+	if err != nil {
+		return 0, fmt.Errorf("malloc failed with error %v", err)
+	}
+	if len(rets) <= 0 {
+		return 0, fmt.Errorf("malloc function signature unexpected")
+	}
+	ptr, ok := rets[0].(int32)
+	if !ok {
+		return 0, fmt.Errorf("malloc must return i32 but got %T", rets[0])
+	}
+	mem, err := w.memory()
+	if err != nil {
+		return 0, err
+	}
+	if err := mem.SetData(data, uint(ptr), uint(len(data))); err != nil {
+		return 0, err
+	}
+	return ptr, nil
+}
+
+func (w *wasmInstance) readBytes(ptr int32, size int32) ([]byte, error) {
+	mem, err := w.memory()
+	if err != nil {
+		return nil, err
+	}
+	return readMemory(mem, ptr, size)
+}
+
+func (w *wasmInstance) free(ptr int32, size int32) {
+	if w.hasFunc("free") {
+		_, _ = w.vm.Execute("free", ptr, size)
+	}
+}
+
+func (w *wasmInstance) release() {
+	if w.vm != nil {
+		w.vm.Release()
+		w.vm = nil
+	}
+	if w.host != nil {
+		w.host.Release()
+		w.host = nil
+	}
+	if w.conf != nil {
+		w.conf.Release()
+		w.conf = nil
+	}
+}
+
+func readMemory(mem *wasmedge.Memory, ptr int32, size int32) ([]byte, error) {
+	data, err := mem.GetData(uint(ptr), uint(size))
+	if err != nil {
+		return nil, fmt.Errorf("read memory at %d with size %d error: %v", ptr, size, err)
+	}
+	// copy the data as the memory may be changed later
+	result := make([]byte, len(data))
+	copy(result, data)
+	return result, nil
+}
+
+// newHostModule creates the host functions which can be imported by the wasm module
+//   - state_get(keyPtr, keyLen, bufPtr, bufCap i32) i32: write the json value of the key into the buffer and return the
+//     length of the value. Return -1 if not found. If the length is bigger than bufCap, nothing is written and the
+//     caller should retry with a bigger buffer.
+//   - state_put(keyPtr, keyLen, valPtr, valLen i32) i32: save the json value of the key. Return 0 if success.
+//   - state_delete(keyPtr, keyLen i32) i32: delete the state of the key. Return 0 if success.
+//   - log(level, ptr, len i32): print the log. Level 0 is debug, 1 is info, 2 is warn and 3 is error.
+func (w *wasmInstance) newHostModule() *wasmedge.Module {
+	mod := wasmedge.NewModule(HostModule)
+	i32 := wasmedge.ValType_I32
+	w.addHostFunc(mod, "state_get", []wasmedge.ValType{i32, i32, i32, i32}, []wasmedge.ValType{i32}, w.stateGet)
+	w.addHostFunc(mod, "state_put", []wasmedge.ValType{i32, i32, i32, i32}, []wasmedge.ValType{i32}, w.statePut)
+	w.addHostFunc(mod, "state_delete", []wasmedge.ValType{i32, i32}, []wasmedge.ValType{i32}, w.stateDelete)
+	w.addHostFunc(mod, "log", []wasmedge.ValType{i32, i32, i32}, []wasmedge.ValType{}, w.log)
+	return mod
+}
+
+type hostFunc func(mem *wasmedge.Memory, params []interface{}) ([]interface{}, error)
+
+func (w *wasmInstance) addHostFunc(mod *wasmedge.Module, name string, params []wasmedge.ValType, returns []wasmedge.ValType, f hostFunc) {
+	ftype := wasmedge.NewFunctionType(params, returns)
+	fn := wasmedge.NewFunction(ftype, func(_ interface{}, callframe *wasmedge.CallingFrame, params []interface{}) ([]interface{}, wasmedge.Result) {
+		mem := callframe.GetMemoryByIndex(0)
+		if mem == nil {
+			return nil, wasmedge.Result_Fail
+		}
+		r, err := f(mem, params)
+		if err != nil {
+			if w.ctx != nil {
+				w.ctx.GetLogger().Errorf("wasm host function %s error: %v", name, err)
+			}
+			return nil, wasmedge.Result_Fail
+		}
+		return r, wasmedge.Result_Success
+	}, nil, 0)
+	mod.AddFunction(name, fn)
+	ftype.Release()
+}
+
+// readString reads the string whose pointer and length are the i32 params
+func (w *wasmInstance) readString(mem *wasmedge.Memory, ptr interface{}, size interface{}) (string, error) {
+	p, ok1 := ptr.(int32)
+	l, ok2 := size.(int32)
+	if !ok1 || !ok2 {
+		return "", fmt.Errorf("invalid pointer %v and length %v", ptr, size)
+	}
+	data, err := readMemory(mem, p, l)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func (w *wasmInstance) stateGet(mem *wasmedge.Memory, params []interface{}) ([]interface{}, error) {
+	if w.ctx == nil {
+		return nil, fmt.Errorf("state is not available")
+	}
+	key, err := w.readString(mem, params[0], params[1])
+	if err != nil {
+		return nil, err
+	}
+	v, err := w.ctx.GetState(key)
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return []interface{}{int32(-1)}, nil
+	}
+	val, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("state %s is not a json string but %T", key, v)
+	}
+	bufPtr, bufCap := params[2].(int32), params[3].(int32)
+	if int32(len(val)) <= bufCap {
+		if err := mem.SetData([]byte(val), uint(bufPtr), uint(len(val))); err != nil {
+			return nil, err
+		}
+	}
+	return []interface{}{int32(len(val))}, nil
+}
+
+func (w *wasmInstance) statePut(mem *wasmedge.Memory, params []interface{}) ([]interface{}, error) {
+	if w.ctx == nil {
+		return nil, fmt.Errorf("state is not available")
+	}
+	key, err := w.readString(mem, params[0], params[1])
+	if err != nil {
+		return nil, err
+	}
+	val, err := w.readString(mem, params[2], params[3])
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid([]byte(val)) {
+		return []interface{}{int32(-1)}, nil
+	}
+	if err := w.ctx.PutState(key, val); err != nil {
+		return nil, err
+	}
+	return []interface{}{int32(0)}, nil
+}
+
+func (w *wasmInstance) stateDelete(mem *wasmedge.Memory, params []interface{}) ([]interface{}, error) {
+	if w.ctx == nil {
+		return nil, fmt.Errorf("state is not available")
+	}
+	key, err := w.readString(mem, params[0], params[1])
+	if err != nil {
+		return nil, err
+	}
+	if err := w.ctx.DeleteState(key); err != nil {
+		return nil, err
+	}
+	return []interface{}{int32(0)}, nil
+}
+
+func (w *wasmInstance) log(mem *wasmedge.Memory, params []interface{}) ([]interface{}, error) {
+	if w.ctx == nil {
+		return []interface{}{}, nil
+	}
+	msg, err := w.readString(mem, params[1], params[2])
+	if err != nil {
+		return nil, err
+	}
+	logger := w.ctx.GetLogger()
+	switch params[0].(int32) {
+	case 0:
+		logger.Debugf("[%s] %s", w.name, msg)
+	case 2:
+		logger.Warnf("[%s] %s", w.name, msg)
+	case 3:
+		logger.Errorf("[%s] %s", w.name, msg)
+	default:
+		logger.Infof("[%s] %s", w.name, msg)
+	}
+	return []interface{}{}, nil
+}
@@ -44,9 +44,36 @@ type FuncData struct {
 	Arg  interface{} `json:"arg"`
 }
 
+type FuncReply struct {
+	State  bool        `json:"state"`
+	Result interface{} `json:"result"`
+}
+
+const (
+	// AbiRaw passes the function arguments as wasm values directly. It is the default abi for functions.
+	AbiRaw = "raw"
+	// AbiJson passes the arguments as a json array and returns a json FuncReply. Sources and sinks always use it.
+	AbiJson = "json"
+)
+
 type PluginMeta struct {
 	Name       string `json:"name"`
 	Version    string `json:"version"`
 	WasmFile   string `json:"wasmFile"`
 	WasmEngine string `json:"wasmEngine"`
+	// Abi is the calling convention of the functions, raw or json
+	Abi string `json:"abi,omitempty"`
+}
+
+// FuncDef is the function definition in the function metadata file
+type FuncDef struct {
+	Name      string    `json:"name"`
+	Aggregate bool      `json:"aggregate"`
+	Args      []*ArgDef `json:"args"`
+}
+
+type ArgDef struct {
+	Name     string `json:"name"`
+	Optional bool   `json:"optional"`
+	Type     string `json:"type"`
 }
@@ -0,0 +1,104 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+// WasmSink collects the data in batches. The wasm module must export the json abi functions:
+//   - {symbol}_open: receive the props object to initialize the sink
+//   - {symbol}_collect: receive a list of rows. A single row is also wrapped as a list.
+//   - {symbol}_close: receive null to release the resources
+type WasmSink struct {
+	symbolName string
+	reg        *PluginMeta
+	props      map[string]interface{}
+	ins        *wasmInstance
+}
+
+func NewWasmSink(symbolName string, reg *PluginMeta) *WasmSink {
+	return &WasmSink{
+		symbolName: symbolName,
+		reg:        reg,
+	}
+}
+
+func (ws *WasmSink) Configure(props map[string]interface{}) error {
+	ws.props = props
+	return nil
+}
+
+func (ws *WasmSink) Open(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Start running wasm sink %s with conf %+v", ws.symbolName, ws.props)
+	ins, err := newWasmInstance(ws.reg)
+	if err != nil {
+		return err
+	}
+	ins.Lock()
+	defer ins.Unlock()
+	_, err = ins.call(ctx, ws.symbolName+"_open", ws.props)
+	if err != nil {
+		ins.release()
+		return fmt.Errorf("open wasm sink %s error: %v", ws.symbolName, err)
+	}
+	ws.ins = ins
+	return nil
+}
+
+func (ws *WasmSink) Collect(ctx api.StreamContext, item interface{}) error {
+	ctx.GetLogger().Debugf("Receive %+v", item)
+	val, _, err := ctx.TransformOutput(item)
+	if err != nil {
+		ctx.GetLogger().Errorf("Found error %s", err.Error())
+		return err
+	}
+	ws.ins.Lock()
+	defer ws.ins.Unlock()
+	_, err = ws.ins.call(ctx, ws.symbolName+"_collect", toBatch(val))
+	if err != nil {
+		return fmt.Errorf("%s:%s", errorx.IOErr, err)
+	}
+	return nil
+}
+
+// toBatch wraps the transformed output as a json list
+func toBatch(val []byte) interface{} {
+	if !json.Valid(val) {
+		return []string{string(val)}
+	}
+	trimmed := bytes.TrimSpace(val)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return json.RawMessage(trimmed)
+	}
+	return []json.RawMessage{trimmed}
+}
+
+func (ws *WasmSink) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Closing wasm sink %s", ws.symbolName)
+	if ws.ins == nil {
+		return nil
+	}
+	ws.ins.Lock()
+	defer ws.ins.Unlock()
+	_, err := ws.ins.call(ctx, ws.symbolName+"_close", nil)
+	ws.ins.release()
+	return err
+}
@@ -0,0 +1,150 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+// WasmSource runs the source in the pull model. The wasm module must export the json abi functions:
+//   - {symbol}_open: receive {"datasource": string, "props": object} to initialize the source
+//   - {symbol}_pull: receive null and return a list of {"message": object, "meta": object} which may be empty
+//   - {symbol}_close: receive null to release the resources
+type WasmSource struct {
+	symbolName string
+	reg        *PluginMeta
+	ins        *wasmInstance
+
+	topic    string
+	props    map[string]interface{}
+	interval time.Duration
+}
+
+type sourceOpenArg struct {
+	DataSource string                 `json:"datasource"`
+	Props      map[string]interface{} `json:"props"`
+}
+
+func NewWasmSource(symbolName string, reg *PluginMeta) *WasmSource {
+	return &WasmSource{
+		symbolName: symbolName,
+		reg:        reg,
+	}
+}
+
+func (ws *WasmSource) Configure(topic string, props map[string]interface{}) error {
+	ws.topic = topic
+	ws.props = props
+	ws.interval = time.Second
+	if v, ok := props["interval"]; ok {
+		i, err := cast.ToInt(v, cast.CONVERT_SAMEKIND)
+		if err != nil || i <= 0 {
+			return fmt.Errorf("property interval must be a positive integer but got %v", v)
+		}
+		ws.interval = time.Duration(i) * time.Millisecond
+	}
+	return nil
+}
+
+func (ws *WasmSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, errCh chan<- error) {
+	ctx.GetLogger().Infof("Start running wasm source %s with datasource %s and conf %+v", ws.symbolName, ws.topic, ws.props)
+	ins, err := newWasmInstance(ws.reg)
+	if err != nil {
+		infra.DrainError(ctx, err, errCh)
+		return
+	}
+	ins.Lock()
+	_, err = ins.call(ctx, ws.symbolName+"_open", &sourceOpenArg{DataSource: ws.topic, Props: ws.props})
+	ins.Unlock()
+	if err != nil {
+		// the source is not opened, so release the instance without calling close
+		ins.release()
+		infra.DrainError(ctx, fmt.Errorf("open wasm source %s error: %v", ws.symbolName, err), errCh)
+		return
+	}
+	ws.ins = ins
+	ticker := time.NewTicker(ws.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			tuples, err := ws.pull(ctx)
+			if err != nil {
+				infra.DrainError(ctx, err, errCh)
+				return
+			}
+			for _, t := range tuples {
+				select {
+				case consumer <- t:
+				case <-ctx.Done():
+					return
+				}
+			}
+		case <-ctx.Done():
+			ctx.GetLogger().Info("stop wasm source")
+			return
+		}
+	}
+}
+
+func (ws *WasmSource) pull(ctx api.StreamContext) ([]api.SourceTuple, error) {
+	ws.ins.Lock()
+	r, err := ws.ins.call(ctx, ws.symbolName+"_pull", nil)
+	ws.ins.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("pull wasm source %s error: %v", ws.symbolName, err)
+	}
+	if r == nil {
+		return nil, nil
+	}
+	// convert the generic result to source tuples
+	bs, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	var list []*api.DefaultSourceTuple
+	if err := json.Unmarshal(bs, &list); err != nil {
+		return nil, fmt.Errorf("wasm source %s must pull a list of {\"message\":{}, \"meta\":{}} but got %s", ws.symbolName, string(bs))
+	}
+	rcvTime := conf.GetNow()
+	result := make([]api.SourceTuple, 0, len(list))
+	for _, t := range list {
+		if t == nil || t.Mess == nil {
+			continue
+		}
+		t.Time = rcvTime
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+func (ws *WasmSource) Close(ctx api.StreamContext) error {
+	ctx.GetLogger().Infof("Closing wasm source %s", ws.symbolName)
+	if ws.ins == nil {
+		return nil
+	}
+	ws.ins.Lock()
+	defer ws.ins.Unlock()
+	_, err := ws.ins.call(ctx, ws.symbolName+"_close", nil)
+	ws.ins.release()
+	return err
+}
@@ -0,0 +1,63 @@
+;; The test module of the json abi. Build with: wat2wasm abi.wat -o abi.wasm
+;;   - source "demo": pull a single message {"n":1} each time
+;;   - sink "keep": save the last collected batch into the state "last"
+;;   - function "count": count the calls in the state "count" with a single digit
+;;   - function "fail": always reply an error
+(module
+  (import "ekuiper" "state_get" (func $state_get (param i32 i32 i32 i32) (result i32)))
+  (import "ekuiper" "state_put" (func $state_put (param i32 i32 i32 i32) (result i32)))
+  (import "ekuiper" "log" (func $log (param i32 i32 i32)))
+  (memory (export "memory") 2)
+  (global $heap (mut i32) (i32.const 1024))
+  (data (i32.const 0) "{\"state\":true,\"result\":null}")
+  (data (i32.const 64) "{\"state\":true,\"result\":[{\"message\":{\"n\":1},\"meta\":{\"topic\":\"demo\"}}]}")
+  (data (i32.const 192) "{\"state\":true,\"result\":0}")
+  (data (i32.const 256) "{\"state\":false,\"result\":\"boom\"}")
+  (data (i32.const 320) "count")
+  (data (i32.const 336) "last")
+
+  ;; a bump allocator which never frees
+  (func (export "malloc") (param $size i32) (result i32)
+    (local $ptr i32)
+    (local.set $ptr (global.get $heap))
+    (global.set $heap (i32.add (global.get $heap) (local.get $size)))
+    (local.get $ptr))
+
+  (func $reply (param $ptr i32) (param $len i32) (result i64)
+    (i64.or
+      (i64.shl (i64.extend_i32_u (local.get $ptr)) (i64.const 32))
+      (i64.extend_i32_u (local.get $len))))
+
+  (func $ok (result i64)
+    (call $reply (i32.const 0) (i32.const 28)))
+
+  (func (export "demo_open") (param i32 i32) (result i64)
+    (call $log (i32.const 1) (local.get 0) (local.get 1))
+    (call $ok))
+  (func (export "demo_pull") (param i32 i32) (result i64)
+    (call $reply (i32.const 64) (i32.const 69)))
+  (func (export "demo_close") (param i32 i32) (result i64)
+    (call $ok))
+
+  (func (export "keep_open") (param i32 i32) (result i64)
+    (call $ok))
+  (func (export "keep_collect") (param $ptr i32) (param $len i32) (result i64)
+    (if (i32.ne (call $state_put (i32.const 336) (i32.const 4) (local.get $ptr) (local.get $len)) (i32.const 0))
+      (then (return (call $reply (i32.const 256) (i32.const 31)))))
+    (call $ok))
+  (func (export "keep_close") (param i32 i32) (result i64)
+    (call $ok))
+
+  (func (export "count") (param i32 i32) (result i64)
+    (local $n i32)
+    (if (i32.eq (call $state_get (i32.const 320) (i32.const 5) (i32.const 400) (i32.const 1)) (i32.const 1))
+      (then (local.set $n (i32.sub (i32.load8_u (i32.const 400)) (i32.const 48)))))
+    (local.set $n (i32.add (local.get $n) (i32.const 1)))
+    (i32.store8 (i32.const 400) (i32.add (local.get $n) (i32.const 48)))
+    (drop (call $state_put (i32.const 320) (i32.const 5) (i32.const 400) (i32.const 1)))
+    (i32.store8 (i32.const 215) (i32.add (local.get $n) (i32.const 48)))
+    (call $reply (i32.const 192) (i32.const 25)))
+
+  (func (export "fail") (param i32 i32) (result i64)
+    (call $reply (i32.const 256) (i32.const 31)))
+)
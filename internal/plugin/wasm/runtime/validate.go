@@ -0,0 +1,67 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/binder/function"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// validateArgs validates the argument number and the literal argument types by the function definition.
+// If the definition is not found, the validation is skipped.
+func validateArgs(def *FuncDef, args []interface{}) error {
+	if def == nil {
+		return nil
+	}
+	required := 0
+	for _, a := range def.Args {
+		if !a.Optional {
+			required++
+		}
+	}
+	if len(args) < required || len(args) > len(def.Args) {
+		if required == len(def.Args) {
+			return function.ValidateLen(required, len(args))
+		}
+		return fmt.Errorf("Expect %d to %d arguments but found %d.", required, len(def.Args), len(args))
+	}
+	for i, arg := range args {
+		expr, ok := arg.(ast.Expr)
+		if !ok {
+			continue
+		}
+		switch def.Args[i].Type {
+		case "int":
+			if ast.IsFloatArg(expr) || ast.IsStringArg(expr) || ast.IsTimeArg(expr) || ast.IsBooleanArg(expr) {
+				return function.ProduceErrInfo(i, "int")
+			}
+		case "float", "number":
+			if ast.IsStringArg(expr) || ast.IsTimeArg(expr) || ast.IsBooleanArg(expr) {
+				return function.ProduceErrInfo(i, "number - float or int")
+			}
+		case "string":
+			if ast.IsNumericArg(expr) || ast.IsTimeArg(expr) || ast.IsBooleanArg(expr) {
+				return function.ProduceErrInfo(i, "string")
+			}
+		case "bool", "boolean":
+			if ast.IsNumericArg(expr) || ast.IsStringArg(expr) || ast.IsTimeArg(expr) {
+				return function.ProduceErrInfo(i, "bool")
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestValidateArgs(t *testing.T) {
+	def := &FuncDef{
+		Name: "fib",
+		Args: []*ArgDef{
+			{Name: "n", Type: "int"},
+			{Name: "label", Type: "string", Optional: true},
+		},
+	}
+	tests := []struct {
+		def  *FuncDef
+		args []interface{}
+		err  string
+	}{
+		{
+			def:  nil,
+			args: []interface{}{&ast.StringLiteral{Val: "a"}, &ast.StringLiteral{Val: "b"}, &ast.StringLiteral{Val: "c"}},
+			err:  "",
+		}, {
+			def:  def,
+			args: []interface{}{&ast.IntegerLiteral{Val: 10}},
+			err:  "",
+		}, {
+			def:  def,
+			args: []interface{}{&ast.FieldRef{Name: "a"}, &ast.StringLiteral{Val: "b"}},
+			err:  "",
+		}, {
+			def:  def,
+			args: []interface{}{},
+			err:  "Expect 1 to 2 arguments but found 0.",
+		}, {
+			def:  def,
+			args: []interface{}{&ast.StringLiteral{Val: "a"}},
+			err:  "Expect int type for parameter 1",
+		}, {
+			def:  def,
+			args: []interface{}{&ast.IntegerLiteral{Val: 10}, &ast.IntegerLiteral{Val: 10}},
+			err:  "Expect string type for parameter 2",
+		}, {
+			def:  &FuncDef{Name: "one", Args: []*ArgDef{{Name: "a", Type: "bool"}}},
+			args: []interface{}{},
+			err:  "Expect 1 arguments but found 0.",
+		},
+	}
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	for i, tt := range tests {
+		err := validateArgs(tt.def, tt.args)
+		if !reflect.DeepEqual(tt.err, testx.Errstring(err)) {
+			t.Errorf("%d error mismatch:\n\nexp=%s\n\ngot=%s\n\n", i, tt.err, testx.Errstring(err))
+		}
+	}
+}
+
+func TestToBatch(t *testing.T) {
+	tests := []struct {
+		val    string
+		result string
+	}{
+		{val: `{"a":1}`, result: `[{"a":1}]`},
+		{val: `[{"a":1},{"a":2}]`, result: `[{"a":1},{"a":2}]`},
+		{val: `hello`, result: `["hello"]`},
+	}
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	for i, tt := range tests {
+		r, err := json.Marshal(toBatch([]byte(tt.val)))
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+			continue
+		}
+		if string(r) != tt.result {
+			t.Errorf("%d result mismatch:\n\nexp=%s\n\ngot=%s\n\n", i, tt.result, r)
+		}
+	}
+}
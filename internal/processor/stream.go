@@ -39,6 +39,23 @@ type StreamProcessor struct {
 	db             kv.KeyValue
 	streamStatusDb kv.KeyValue
 	tableStatusDb  kv.KeyValue
+	// notified after a stream schema is altered, returns the notes of the affected rules
+	schemaChangeHandler func(name string) []string
+	// checks the rules against the new statement of a stream before the statement is saved
+	schemaChangeValidator func(name string, statement string) error
+}
+
+// SchemaVersionInfo is a schema revision with its inferred json schema
+type SchemaVersionInfo struct {
+	*xsql.SchemaVersion
+	Schema map[string]*ast.JsonStreamField `json:"schema"`
+}
+
+// SchemaHistory is the current schema of a source and all its revisions from the oldest
+type SchemaHistory struct {
+	Version int                             `json:"version"`
+	Schema  map[string]*ast.JsonStreamField `json:"schema"`
+	History []*SchemaVersionInfo            `json:"history"`
 }
 
 func NewStreamProcessor() *StreamProcessor {
@@ -62,6 +79,16 @@ func NewStreamProcessor() *StreamProcessor {
 	return processor
 }
 
+// SetSchemaChangeHandler registers the handler to update the rules when a stream schema is altered
+func (p *StreamProcessor) SetSchemaChangeHandler(h func(name string) []string) {
+	p.schemaChangeHandler = h
+}
+
+// SetSchemaChangeValidator registers the validator to reject the altered stream schema which is incompatible with the rules
+func (p *StreamProcessor) SetSchemaChangeValidator(v func(name string, statement string) error) {
+	p.schemaChangeValidator = v
+}
+
 func (p *StreamProcessor) ExecStmt(statement string) (result []string, err error) {
 	parser := xsql.NewParser(strings.NewReader(statement))
 	stmt, err := xsql.Language.Parse(parser)
@@ -104,6 +131,8 @@ func (p *StreamProcessor) ExecStmt(statement string) (result []string, err error
 		var r string
 		r, err = p.execDrop(s, ast.TypeStream)
 		result = append(result, r)
+	case *ast.AlterStreamStatement:
+		result, err = p.execAlter(s, statement)
 	case *ast.DropTableStatement:
 		var r string
 		r, err = p.execDrop(s, ast.TypeTable)
@@ -158,22 +187,190 @@ func (p *StreamProcessor) execSave(stmt *ast.StreamStmt, statement string, repla
 			return err
 		}
 	}
-	s, err := json.Marshal(xsql.StreamInfo{
+	info := &xsql.StreamInfo{
 		StreamType: stmt.StreamType,
 		Statement:  statement,
 		StreamKind: stmt.Options.KIND,
+	}
+	if replace {
+		if old, err := xsql.GetDataSourceStatement(p.db, string(stmt.Name)); err == nil && old.StreamType == stmt.StreamType {
+			info.Versions = versionsOf(old)
+		}
+	}
+	info.Versions = append(info.Versions, &xsql.SchemaVersion{
+		Version:   len(info.Versions) + 1,
+		Statement: statement,
+		Change:    statement,
+		Timestamp: conf.GetNowInMilli(),
 	})
+	return p.saveInfo(string(stmt.Name), info, replace)
+}
+
+func (p *StreamProcessor) saveInfo(name string, info *xsql.StreamInfo, replace bool) error {
+	s, err := json.Marshal(info)
 	if err != nil {
 		return fmt.Errorf("error when saving to db: %v.", err)
 	}
 	if replace {
-		err = p.db.Set(string(stmt.Name), string(s))
+		err = p.db.Set(name, string(s))
 	} else {
-		err = p.db.Setnx(string(stmt.Name), string(s))
+		err = p.db.Setnx(name, string(s))
 	}
 	return err
 }
 
+// versionsOf returns the recorded versions. The source created before versioning is regarded as version 1.
+func versionsOf(info *xsql.StreamInfo) []*xsql.SchemaVersion {
+	if len(info.Versions) > 0 {
+		return info.Versions
+	}
+	return []*xsql.SchemaVersion{{Version: 1, Statement: info.Statement, Change: info.Statement}}
+}
+
+func (p *StreamProcessor) execAlter(stmt *ast.AlterStreamStatement, statement string) ([]string, error) {
+	r, err := p.alterStream(stmt, statement)
+	if err != nil {
+		return nil, fmt.Errorf("Alter stream fails: %v.", err)
+	}
+	log.Info(r)
+	result := []string{r}
+	if p.schemaChangeHandler != nil {
+		result = append(result, p.schemaChangeHandler(stmt.Name)...)
+	}
+	return result, nil
+}
+
+func (p *StreamProcessor) alterStream(stmt *ast.AlterStreamStatement, statement string) (string, error) {
+	info, err := xsql.GetDataSourceStatement(p.db, stmt.Name)
+	if err != nil {
+		return "", err
+	}
+	if info.StreamType != ast.TypeStream {
+		return "", errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("stream %s is not found", stmt.Name))
+	}
+	parsed, err := xsql.NewParser(strings.NewReader(info.Statement)).ParseCreateStmt()
+	if err != nil {
+		return "", err
+	}
+	streamStmt, ok := parsed.(*ast.StreamStmt)
+	if !ok {
+		return "", fmt.Errorf("the data in db may be corrupted")
+	}
+	fields, err := alterFields(streamStmt, stmt)
+	if err != nil {
+		return "", err
+	}
+	newStatement, err := replaceStreamFields(info.Statement, fields)
+	if err != nil {
+		return "", err
+	}
+	// Validate the new definition, such as the field constraints of the format
+	if _, err := xsql.NewParser(strings.NewReader(newStatement)).ParseCreateStmt(); err != nil {
+		return "", err
+	}
+	if p.schemaChangeValidator != nil {
+		if err := p.schemaChangeValidator(stmt.Name, newStatement); err != nil {
+			return "", err
+		}
+	}
+	info.Versions = versionsOf(info)
+	version := len(info.Versions) + 1
+	info.Statement = newStatement
+	info.Versions = append(info.Versions, &xsql.SchemaVersion{
+		Version:   version,
+		Statement: newStatement,
+		Change:    statement,
+		Timestamp: conf.GetNowInMilli(),
+	})
+	if err := p.saveInfo(stmt.Name, info, true); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Stream %s is altered to schema version %d.", stmt.Name, version), nil
+}
+
+func alterFields(s *ast.StreamStmt, alter *ast.AlterStreamStatement) (ast.StreamFields, error) {
+	if s.Options.SCHEMAID != "" {
+		return nil, fmt.Errorf("the schema is defined by schemaid %s, update the schema file instead", s.Options.SCHEMAID)
+	}
+	if len(s.StreamFields) == 0 {
+		return nil, fmt.Errorf("schemaless stream cannot be altered")
+	}
+	name := alter.Field.Name
+	index := -1
+	for i, f := range s.StreamFields {
+		if strings.EqualFold(f.Name, name) {
+			index = i
+			break
+		}
+	}
+	if index < 0 && alter.Action != ast.AlterAddColumn {
+		return nil, fmt.Errorf("column %s is not found", name)
+	}
+	fields := make(ast.StreamFields, len(s.StreamFields), len(s.StreamFields)+1)
+	copy(fields, s.StreamFields)
+	switch alter.Action {
+	case ast.AlterAddColumn:
+		if index >= 0 {
+			return nil, fmt.Errorf("column %s already exists", name)
+		}
+		fields = append(fields, alter.Field)
+	case ast.AlterDropColumn:
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("cannot drop the only column %s", name)
+		}
+		if strings.EqualFold(s.Options.TIMESTAMP, name) {
+			return nil, fmt.Errorf("cannot drop the timestamp column %s", name)
+		}
+		fields = append(fields[:index], fields[index+1:]...)
+	case ast.AlterModifyColumn:
+		fields[index] = ast.StreamField{Name: fields[index].Name, FieldType: alter.Field.FieldType}
+	}
+	return fields, nil
+}
+
+// replaceStreamFields replaces the field list, which is the first parenthesis, of the create statement
+func replaceStreamFields(statement string, fields ast.StreamFields) (string, error) {
+	start, depth := -1, 0
+	var quote rune
+	for i, c := range statement {
+		if quote != 0 {
+			if c == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch c {
+		case '`', '"', '\'':
+			quote = c
+		case '(':
+			if start < 0 {
+				start = i
+			}
+			depth++
+		case ')':
+			if depth--; depth == 0 && start >= 0 {
+				return statement[:start] + printStreamFields(fields) + statement[i+1:], nil
+			}
+		}
+	}
+	return "", fmt.Errorf("cannot find the fields in statement %s", statement)
+}
+
+func printStreamFields(fields ast.StreamFields) string {
+	var buff bytes.Buffer
+	buff.WriteString("(")
+	for i, f := range fields {
+		if i > 0 {
+			buff.WriteString(", ")
+		}
+		buff.WriteString(printFieldName(f.Name))
+		buff.WriteString(" ")
+		buff.WriteString(printFieldType(f.FieldType))
+	}
+	buff.WriteString(")")
+	return buff.String()
+}
+
 func (p *StreamProcessor) ExecReplaceStream(name string, statement string, st ast.StreamType) (string, error) {
 	parser := xsql.NewParser(strings.NewReader(statement))
 	stmt, err := xsql.Language.Parse(parser)
@@ -375,6 +572,35 @@ func (p *StreamProcessor) GetInferredJsonSchema(name string, st ast.StreamType)
 	if err != nil {
 		return nil, fmt.Errorf("Describe %s fails, %s.", ast.StreamTypeMap[st], err)
 	}
+	return inferJsonSchema(statement, st)
+}
+
+// GetSchemaHistory return the current schema along with all the schema versions
+func (p *StreamProcessor) GetSchemaHistory(name string, st ast.StreamType) (*SchemaHistory, error) {
+	vs, err := xsql.GetDataSourceStatement(p.db, name)
+	if err == nil && vs.StreamType != st {
+		err = errorx.NewWithCode(errorx.NOT_FOUND, fmt.Sprintf("%s %s is not found", ast.StreamTypeMap[st], name))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Describe %s fails, %s.", ast.StreamTypeMap[st], err)
+	}
+	versions := versionsOf(vs)
+	result := &SchemaHistory{
+		Version: versions[len(versions)-1].Version,
+		History: make([]*SchemaVersionInfo, 0, len(versions)),
+	}
+	for _, v := range versions {
+		sch, err := inferJsonSchema(v.Statement, st)
+		if err != nil {
+			return nil, err
+		}
+		result.History = append(result.History, &SchemaVersionInfo{SchemaVersion: v, Schema: sch})
+	}
+	result.Schema = result.History[len(result.History)-1].Schema
+	return result, nil
+}
+
+func inferJsonSchema(statement string, st ast.StreamType) (map[string]*ast.JsonStreamField, error) {
 	parser := xsql.NewParser(strings.NewReader(statement))
 	stream, err := xsql.Language.Parse(parser)
 	if err != nil {
@@ -451,13 +677,21 @@ func printFieldType(ft ast.FieldType) (result string) {
 			} else {
 				result += ", "
 			}
-			result = result + f.Name + " " + printFieldType(f.FieldType)
+			result = result + printFieldName(f.Name) + " " + printFieldType(f.FieldType)
 		}
 		result += ")"
 	}
 	return
 }
 
+// printFieldName quotes the field name if it cannot be scanned as an identifier, such as a keyword
+func printFieldName(name string) string {
+	if tok, lit := xsql.NewScanner(strings.NewReader(name)).Scan(); tok != ast.IDENT || lit != name {
+		return "`" + name + "`"
+	}
+	return name
+}
+
 // GetAll return all streams and tables defined to export.
 func (p *StreamProcessor) GetAll() (result map[string]map[string]string, e error) {
 	defs, err := p.db.All()
@@ -20,6 +20,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/gdexlab/go-render/render"
@@ -123,6 +124,136 @@ func TestStreamCreateProcessor(t *testing.T) {
 	}
 }
 
+func TestStreamAlterProcessor(t *testing.T) {
+	tests := []struct {
+		s   string
+		r   []string
+		err string
+	}{
+		{
+			s: `CREATE STREAM alter1 (id BIGINT, temp FLOAT) WITH (DATASOURCE="devices", FORMAT="JSON", TIMESTAMP="id");`,
+			r: []string{"Stream alter1 is created."},
+		},
+		{
+			s: `ALTER STREAM alter1 ADD COLUMN ` + "`order`" + ` STRUCT(name STRING, ` + "`from`" + ` STRING);`,
+			r: []string{"Stream alter1 is altered to schema version 2."},
+		},
+		{
+			s: `ALTER STREAM alter1 MODIFY COLUMN TEMP BIGINT;`,
+			r: []string{"Stream alter1 is altered to schema version 3."},
+		},
+		{
+			s: `DESCRIBE STREAM alter1;`,
+			r: []string{"Fields\n--------------------------------------------------------------------------------\nid\tbigint\ntemp\tbigint\norder\tstruct(name string, `from` string)\n\n" +
+				"DATASOURCE: devices\nFORMAT: JSON\nTIMESTAMP: id\n"},
+		},
+		{
+			s: `ALTER STREAM alter1 DROP COLUMN temp;`,
+			r: []string{"Stream alter1 is altered to schema version 4."},
+		},
+		{
+			s:   `ALTER STREAM alter1 ADD COLUMN ID STRING;`,
+			err: "Alter stream fails: column ID already exists.",
+		},
+		{
+			s:   `ALTER STREAM alter1 DROP COLUMN temp;`,
+			err: "Alter stream fails: column temp is not found.",
+		},
+		{
+			s:   `ALTER STREAM alter1 DROP COLUMN id;`,
+			err: "Alter stream fails: cannot drop the timestamp column id.",
+		},
+		{
+			s:   `ALTER STREAM alter2 DROP COLUMN id;`,
+			err: "Alter stream fails: alter2 is not found.",
+		},
+		{
+			s: `CREATE STREAM alter2 () WITH (DATASOURCE="devices", FORMAT="JSON");`,
+			r: []string{"Stream alter2 is created."},
+		},
+		{
+			s:   `ALTER STREAM alter2 ADD COLUMN id BIGINT;`,
+			err: "Alter stream fails: schemaless stream cannot be altered.",
+		},
+	}
+
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+
+	p := NewStreamProcessor()
+	defer func() {
+		_, _ = p.DropStream("alter1", ast.TypeStream)
+		_, _ = p.DropStream("alter2", ast.TypeStream)
+	}()
+	for i, tt := range tests {
+		results, err := p.ExecStmt(tt.s)
+		if !reflect.DeepEqual(tt.err, testx.Errstring(err)) {
+			t.Errorf("%d. %q: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.s, tt.err, err)
+		} else if tt.err == "" {
+			if !reflect.DeepEqual(tt.r, results) {
+				t.Errorf("%d. %q\n\nstmt mismatch:\nexp=%s\ngot=%#v\n\n", i, tt.s, tt.r, results)
+			}
+		}
+	}
+
+	h, err := p.GetSchemaHistory("alter1", ast.TypeStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Version != 4 || len(h.History) != 4 {
+		t.Fatalf("expect 4 versions but got %d: %v", h.Version, render.AsCode(h))
+	}
+	expSchemas := []map[string]*ast.JsonStreamField{
+		{"id": {Type: "bigint"}, "temp": {Type: "float"}},
+		{"id": {Type: "bigint"}, "temp": {Type: "float"}, "order": {Type: "struct", Properties: map[string]*ast.JsonStreamField{"name": {Type: "string"}, "from": {Type: "string"}}}},
+		{"id": {Type: "bigint"}, "temp": {Type: "bigint"}, "order": {Type: "struct", Properties: map[string]*ast.JsonStreamField{"name": {Type: "string"}, "from": {Type: "string"}}}},
+		{"id": {Type: "bigint"}, "order": {Type: "struct", Properties: map[string]*ast.JsonStreamField{"name": {Type: "string"}, "from": {Type: "string"}}}},
+	}
+	changes := []string{tests[0].s, tests[1].s, tests[2].s, tests[4].s}
+	for i, v := range h.History {
+		if v.Version != i+1 || v.Change != changes[i] {
+			t.Errorf("%d. version mismatch: %v", i, render.AsCode(v))
+		}
+		if !reflect.DeepEqual(expSchemas[i], v.Schema) {
+			t.Errorf("%d. schema mismatch:\nexp=%s\ngot=%s", i, render.AsCode(expSchemas[i]), render.AsCode(v.Schema))
+		}
+	}
+	if !reflect.DeepEqual(expSchemas[3], h.Schema) {
+		t.Errorf("current schema mismatch:\nexp=%s\ngot=%s", render.AsCode(expSchemas[3]), render.AsCode(h.Schema))
+	}
+}
+
+func TestStreamAlterValidator(t *testing.T) {
+	p := NewStreamProcessor()
+	p.SetSchemaChangeValidator(func(name string, statement string) error {
+		if !strings.Contains(statement, "temp") {
+			return fmt.Errorf("rule r1 refers to %s.temp", name)
+		}
+		return nil
+	})
+	defer func() {
+		_, _ = p.DropStream("alter3", ast.TypeStream)
+	}()
+	_, err := p.ExecStmt(`CREATE STREAM alter3 (id BIGINT, temp FLOAT) WITH (DATASOURCE="devices", FORMAT="JSON");`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.ExecStmt(`ALTER STREAM alter3 DROP COLUMN temp;`)
+	if exp := "Alter stream fails: rule r1 refers to alter3.temp."; testx.Errstring(err) != exp {
+		t.Errorf("error mismatch:\n  exp=%s\n  got=%s", exp, err)
+	}
+	_, err = p.ExecStmt(`ALTER STREAM alter3 ADD COLUMN name STRING;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := p.GetSchemaHistory("alter3", ast.TypeStream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.Version != 2 {
+		t.Errorf("expect the rejected change not saved but got version %d", h.Version)
+	}
+}
+
 func TestTableProcessor(t *testing.T) {
 	tests := []struct {
 		s   string
@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -48,6 +48,12 @@ func (p pluginComp) register() {
 		panic(err)
 	}
 	entries = append(entries, binder.FactoryEntry{Name: "native plugin", Factory: nativeManager, Weight: 9})
+	for _, t := range []plugin.PluginType{plugin.SOURCE, plugin.SINK, plugin.FUNCTION} {
+		pt := t
+		pluginInstallers[pt] = func(p plugin.Plugin) error {
+			return nativeManager.Register(pt, p)
+		}
+	}
 }
 
 func (p pluginComp) rest(r *mux.Router) {
@@ -55,11 +61,14 @@ func (p pluginComp) rest(r *mux.Router) {
 	r.HandleFunc("/plugins/sinks/prebuild", prebuildSinkPlugins).Methods(http.MethodGet)
 	r.HandleFunc("/plugins/functions/prebuild", prebuildFuncsPlugins).Methods(http.MethodGet)
 	r.HandleFunc("/plugins/sources", sourcesHandler).Methods(http.MethodGet, http.MethodPost)
-	r.HandleFunc("/plugins/sources/{name}", sourceHandler).Methods(http.MethodDelete, http.MethodGet)
+	r.HandleFunc("/plugins/sources/{name}", sourceHandler).Methods(http.MethodDelete, http.MethodGet, http.MethodPut)
+	r.HandleFunc("/plugins/sources/{name}/dependents", sourceDependentsHandler).Methods(http.MethodGet)
 	r.HandleFunc("/plugins/sinks", sinksHandler).Methods(http.MethodGet, http.MethodPost)
-	r.HandleFunc("/plugins/sinks/{name}", sinkHandler).Methods(http.MethodDelete, http.MethodGet)
+	r.HandleFunc("/plugins/sinks/{name}", sinkHandler).Methods(http.MethodDelete, http.MethodGet, http.MethodPut)
+	r.HandleFunc("/plugins/sinks/{name}/dependents", sinkDependentsHandler).Methods(http.MethodGet)
 	r.HandleFunc("/plugins/functions", functionsHandler).Methods(http.MethodGet, http.MethodPost)
-	r.HandleFunc("/plugins/functions/{name}", functionHandler).Methods(http.MethodDelete, http.MethodGet)
+	r.HandleFunc("/plugins/functions/{name}", functionHandler).Methods(http.MethodDelete, http.MethodGet, http.MethodPut)
+	r.HandleFunc("/plugins/functions/{name}/dependents", functionDependentsHandler).Methods(http.MethodGet)
 	r.HandleFunc("/plugins/functions/{name}/register", functionRegisterHandler).Methods(http.MethodPost)
 	r.HandleFunc("/plugins/udfs", functionsListHandler).Methods(http.MethodGet)
 	r.HandleFunc("/plugins/udfs/{name}", functionsGetHandler).Methods(http.MethodGet)
@@ -117,9 +126,74 @@ func pluginHandler(w http.ResponseWriter, r *http.Request, t plugin.PluginType)
 			return
 		}
 		jsonResponse(j, w, logger)
+	case http.MethodPut:
+		sd := plugin.NewPluginByType(t)
+		err := json.NewDecoder(r.Body).Decode(sd)
+		// Problems decoding
+		if err != nil {
+			handleError(w, err, fmt.Sprintf("Invalid body: Error decoding the %s plugin json", plugin.PluginTypes[t]), logger)
+			return
+		}
+		sd.SetName(name)
+		dependents, err := pluginDependents(nativePluginSymbols(t, name))
+		if err != nil {
+			handleError(w, err, fmt.Sprintf("find dependent rules of %s plugin %s error", plugin.PluginTypes[t], name), logger)
+			return
+		}
+		err = reloadPlugin(dependents, func() (plugin.Upgrade, error) {
+			return nativeManager.Update(t, sd)
+		})
+		if err != nil {
+			handleError(w, err, fmt.Sprintf("%s plugins update command error", plugin.PluginTypes[t]), logger)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf("%s plugin %s is updated", plugin.PluginTypes[t], name)))
 	}
 }
 
+func nativePluginSymbols(t plugin.PluginType, name string) *pluginSymbols {
+	ps := &pluginSymbols{}
+	switch t {
+	case plugin.SOURCE:
+		ps.sources = []string{name}
+	case plugin.SINK:
+		ps.sinks = []string{name}
+	case plugin.FUNCTION:
+		ps.functions = nativeManager.GetPluginSymbols(t, name)
+	}
+	return ps
+}
+
+// list the rules which depend on the plugin
+func pluginDependentsHandler(w http.ResponseWriter, r *http.Request, t plugin.PluginType) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+	if _, ok := nativeManager.GetPluginInfo(t, name); !ok {
+		handleError(w, errorx.NewWithCode(errorx.NOT_FOUND, "not found"), fmt.Sprintf("describe %s plugin %s error", plugin.PluginTypes[t], name), logger)
+		return
+	}
+	dependents, err := pluginDependents(nativePluginSymbols(t, name))
+	if err != nil {
+		handleError(w, err, fmt.Sprintf("find dependent rules of %s plugin %s error", plugin.PluginTypes[t], name), logger)
+		return
+	}
+	jsonResponse(dependents, w, logger)
+}
+
+func sourceDependentsHandler(w http.ResponseWriter, r *http.Request) {
+	pluginDependentsHandler(w, r, plugin.SOURCE)
+}
+
+func sinkDependentsHandler(w http.ResponseWriter, r *http.Request) {
+	pluginDependentsHandler(w, r, plugin.SINK)
+}
+
+func functionDependentsHandler(w http.ResponseWriter, r *http.Request) {
+	pluginDependentsHandler(w, r, plugin.FUNCTION)
+}
+
 // list or create source plugin
 func sourcesHandler(w http.ResponseWriter, r *http.Request) {
 	pluginsHandler(w, r, plugin.SOURCE)
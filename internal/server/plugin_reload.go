@@ -0,0 +1,138 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/plugin"
+	"github.com/lf-edge/ekuiper/internal/topo/rule"
+)
+
+// reloadCheckInterval is the time to wait before checking if the restarted rules are running
+var reloadCheckInterval = 500 * time.Millisecond
+
+// pluginSymbols are the symbols exported by a plugin
+type pluginSymbols struct {
+	sources   []string
+	sinks     []string
+	functions []string
+}
+
+// pluginDependents returns the sorted ids of the rules which refer to any symbol of the plugin
+func pluginDependents(ps *pluginSymbols) ([]string, error) {
+	ruleIds, err := ruleProcessor.GetAllRules()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(ruleIds)
+	result := make([]string, 0)
+	for _, id := range ruleIds {
+		r, err := ruleProcessor.GetRuleById(id)
+		if err != nil || r == nil {
+			continue
+		}
+		de := newDependencies()
+		ruleTraverse(r, de)
+		if containsAny(de.sources, ps.sources) || containsAny(de.sinks, ps.sinks) || containsAny(de.functions, ps.functions) {
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+func containsAny(refs []string, symbols []string) bool {
+	for _, r := range refs {
+		for _, s := range symbols {
+			if strings.EqualFold(r, s) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reloadPlugin updates the plugin and restarts the running dependent rules in order to use the new version.
+// The new version is downloaded and verified before stopping the rules, so the rules are only stopped for the swap.
+// If any rule fails to restart, the update is rolled back and the rules are restarted with the previous version.
+// The rules are restarted in the order of the dependents which is sorted by id. The data flow between the rules
+// chained by memory topics is not considered.
+func reloadPlugin(dependents []string, update func() (plugin.Upgrade, error)) error {
+	u, err := update()
+	if err != nil {
+		return err
+	}
+	running := make([]*rule.RuleState, 0, len(dependents))
+	for _, id := range dependents {
+		rs, ok := registry.Load(id)
+		if !ok {
+			continue
+		}
+		if s, err := rs.GetState(); err == nil && s == "Running" {
+			running = append(running, rs)
+		}
+	}
+	stopRules(running)
+	if err := u.Apply(); err != nil {
+		if rerr := startRules(running); rerr != nil {
+			conf.Log.Errorf("fail to restart rules after plugin update failure: %v", rerr)
+		}
+		return err
+	}
+	if err := startRules(running); err != nil {
+		conf.Log.Errorf("fail to restart rules with the new plugin version, rollback: %v", err)
+		stopRules(running)
+		if rerr := u.Rollback(); rerr != nil {
+			return fmt.Errorf("restart rule error: %v, and rollback error: %v", err, rerr)
+		}
+		if rerr := startRules(running); rerr != nil {
+			conf.Log.Errorf("fail to restart rules after rollback: %v", rerr)
+		}
+		return fmt.Errorf("plugin update is rolled back for restart rule error: %v", err)
+	}
+	u.Commit()
+	return nil
+}
+
+func stopRules(rules []*rule.RuleState) {
+	for _, rs := range rules {
+		if err := rs.Stop(); err != nil {
+			conf.Log.Warnf("stop rule %s error: %v", rs.RuleId, err)
+		}
+	}
+}
+
+// startRules starts the rules in order and confirm they are still running after a while
+func startRules(rules []*rule.RuleState) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	for _, rs := range rules {
+		if err := rs.Start(); err != nil {
+			return fmt.Errorf("rule %s: %v", rs.RuleId, err)
+		}
+	}
+	time.Sleep(reloadCheckInterval)
+	for _, rs := range rules {
+		if s, err := rs.GetState(); err != nil || s != "Running" {
+			return fmt.Errorf("rule %s: %s", rs.RuleId, s)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/binder"
+	"github.com/lf-edge/ekuiper/internal/binder/function"
+	"github.com/lf-edge/ekuiper/internal/binder/mock"
+	"github.com/lf-edge/ekuiper/internal/plugin"
+)
+
+type mockUpgrade struct {
+	applied    bool
+	committed  bool
+	rolledBack bool
+	// called when applying to switch to the new version
+	onApply func() error
+	// called when rolling back to restore the previous version
+	onRollback func()
+}
+
+func (m *mockUpgrade) Apply() error {
+	m.applied = true
+	if m.onApply != nil {
+		return m.onApply()
+	}
+	return nil
+}
+
+func (m *mockUpgrade) Commit() {
+	m.committed = true
+}
+
+func (m *mockUpgrade) Rollback() error {
+	m.rolledBack = true
+	if m.onRollback != nil {
+		m.onRollback()
+	}
+	return nil
+}
+
+func TestPluginDependents(t *testing.T) {
+	// register the mock functions so that the rule sql can be parsed
+	require.NoError(t, function.Initialize([]binder.FactoryEntry{{Name: "mock", Factory: mock.NewMockFactory()}}))
+	_, err := streamProcessor.ExecStmt(`CREATE STREAM reloadDemo() WITH (DATASOURCE="reload", TYPE="random2")`)
+	assert.NoError(t, err)
+	defer streamProcessor.ExecStmt(`DROP STREAM reloadDemo`)
+	err = ruleProcessor.ExecCreate("reloadRule2", `{"id":"reloadRule2","triggered":false,"sql":"SELECT mockEcho3(a) FROM reloadDemo","actions":[{"file2":{}}]}`)
+	assert.NoError(t, err)
+	defer ruleProcessor.ExecDrop("reloadRule2")
+	err = ruleProcessor.ExecCreate("reloadRule1", `{"id":"reloadRule1","triggered":false,"sql":"SELECT abs(a) FROM reloadDemo","actions":[{"log":{}}]}`)
+	assert.NoError(t, err)
+	defer ruleProcessor.ExecDrop("reloadRule1")
+
+	tests := []struct {
+		name string
+		ps   *pluginSymbols
+		r    []string
+	}{
+		{
+			name: "source",
+			ps:   &pluginSymbols{sources: []string{"random2"}},
+			r:    []string{"reloadRule1", "reloadRule2"},
+		}, {
+			name: "sink",
+			ps:   &pluginSymbols{sinks: []string{"file2"}},
+			r:    []string{"reloadRule2"},
+		}, {
+			name: "function case insensitive",
+			ps:   &pluginSymbols{functions: []string{"mockEcho2", "MOCKECHO3"}},
+			r:    []string{"reloadRule2"},
+		}, {
+			name: "none",
+			ps:   &pluginSymbols{sources: []string{"random3"}, functions: []string{"mockEcho2"}},
+			r:    []string{},
+		},
+	}
+	for _, tt := range tests {
+		r, err := pluginDependents(tt.ps)
+		assert.NoError(t, err, tt.name)
+		assert.Equal(t, tt.r, r, tt.name)
+	}
+}
+
+func TestReloadPlugin(t *testing.T) {
+	u := &mockUpgrade{}
+	err := reloadPlugin([]string{"notExist"}, func() (plugin.Upgrade, error) {
+		return u, nil
+	})
+	assert.NoError(t, err)
+	assert.True(t, u.applied)
+	assert.True(t, u.committed)
+	assert.False(t, u.rolledBack)
+
+	err = reloadPlugin(nil, func() (plugin.Upgrade, error) {
+		return nil, errors.New("verify error")
+	})
+	assert.EqualError(t, err, "verify error")
+
+	u = &mockUpgrade{onApply: func() error {
+		return errors.New("install error")
+	}}
+	err = reloadPlugin(nil, func() (plugin.Upgrade, error) {
+		return u, nil
+	})
+	assert.EqualError(t, err, "install error")
+	assert.False(t, u.committed)
+}
+
+func TestReloadPluginRollback(t *testing.T) {
+	reloadCheckInterval = 100 * time.Millisecond
+	defer func() {
+		reloadCheckInterval = 500 * time.Millisecond
+	}()
+	createStream := `CREATE STREAM reloadRollback() WITH (DATASOURCE="reloadRollback", TYPE="memory", FORMAT="json")`
+	_, err := streamProcessor.ExecStmt(createStream)
+	require.NoError(t, err)
+	defer streamProcessor.ExecStmt(`DROP STREAM reloadRollback`)
+	_, err = createRule("reloadRule3", `{"id":"reloadRule3","sql":"SELECT * FROM reloadRollback","actions":[{"log":{}}]}`)
+	require.NoError(t, err)
+	defer deleteRule("reloadRule3")
+	require.Eventually(t, func() bool {
+		s, err := getRuleState("reloadRule3")
+		return err == nil && s == "Running"
+	}, time.Second, 10*time.Millisecond)
+
+	// The rules keep running if the new version fails to verify
+	err = reloadPlugin([]string{"reloadRule3"}, func() (plugin.Upgrade, error) {
+		s, err := getRuleState("reloadRule3")
+		assert.NoError(t, err)
+		assert.Equal(t, "Running", s)
+		return nil, errors.New("verify error")
+	})
+	require.EqualError(t, err, "verify error")
+
+	// The new version cannot start which is simulated by dropping the stream. Rollback restores it.
+	u := &mockUpgrade{
+		onApply: func() error {
+			_, err := streamProcessor.ExecStmt(`DROP STREAM reloadRollback`)
+			return err
+		},
+		onRollback: func() {
+			_, err := streamProcessor.ExecStmt(createStream)
+			assert.NoError(t, err)
+		},
+	}
+	err = reloadPlugin([]string{"reloadRule3"}, func() (plugin.Upgrade, error) {
+		return u, nil
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "plugin update is rolled back for restart rule error: rule reloadRule3")
+	assert.True(t, u.rolledBack)
+	assert.False(t, u.committed)
+	s, err := getRuleState("reloadRule3")
+	require.NoError(t, err)
+	assert.Equal(t, "Running", s)
+}
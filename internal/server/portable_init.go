@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -25,9 +25,9 @@ import (
 	"github.com/gorilla/mux"
 
 	"github.com/lf-edge/ekuiper/internal/binder"
-	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/plugin"
 	"github.com/lf-edge/ekuiper/internal/plugin/portable"
+	"github.com/lf-edge/ekuiper/internal/plugin/portable/runtime"
 	"github.com/lf-edge/ekuiper/pkg/errorx"
 )
 
@@ -46,11 +46,13 @@ func (p portableComp) register() {
 		panic(err)
 	}
 	entries = append(entries, binder.FactoryEntry{Name: "portable plugin", Factory: portableManager, Weight: 8})
+	pluginInstallers[plugin.PORTABLE] = portableManager.Register
 }
 
 func (p portableComp) rest(r *mux.Router) {
 	r.HandleFunc("/plugins/portables", portablesHandler).Methods(http.MethodGet, http.MethodPost)
 	r.HandleFunc("/plugins/portables/{name}", portableHandler).Methods(http.MethodGet, http.MethodDelete, http.MethodPut)
+	r.HandleFunc("/plugins/portables/{name}/dependents", portableDependentsHandler).Methods(http.MethodGet)
 }
 
 func portablesHandler(w http.ResponseWriter, r *http.Request) {
@@ -77,6 +79,11 @@ func portablesHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+type portableDesc struct {
+	*portable.PluginInfo
+	Status *runtime.PluginStatus `json:"status"`
+}
+
 func portableHandler(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
 	vars := mux.Vars(r)
@@ -97,7 +104,7 @@ func portableHandler(w http.ResponseWriter, r *http.Request) {
 			handleError(w, errorx.NewWithCode(errorx.NOT_FOUND, "not found"), fmt.Sprintf("describe portable plugin %s error", name), logger)
 			return
 		}
-		jsonResponse(j, w, logger)
+		jsonResponse(&portableDesc{PluginInfo: j, Status: portableManager.GetPluginStatus(name)}, w, logger)
 	case http.MethodPut:
 		sd := plugin.NewPluginByType(plugin.PORTABLE)
 		err := json.NewDecoder(r.Body).Decode(sd)
@@ -106,11 +113,19 @@ func portableHandler(w http.ResponseWriter, r *http.Request) {
 			handleError(w, err, "Invalid body: Error decoding the portable plugin json", logger)
 			return
 		}
-		err = portableManager.Delete(name)
-		if err != nil {
-			conf.Log.Errorf("delete portable plugin %s error: %v", name, err)
+		sd.SetName(name)
+		pinfo, ok := portableManager.GetPluginInfo(name)
+		if !ok {
+			err = portableManager.Register(sd)
+		} else {
+			var dependents []string
+			dependents, err = pluginDependents(portablePluginSymbols(pinfo))
+			if err == nil {
+				err = reloadPlugin(dependents, func() (plugin.Upgrade, error) {
+					return portableManager.Update(sd)
+				})
+			}
 		}
-		err = portableManager.Register(sd)
 		if err != nil {
 			handleError(w, err, "portable plugin update command error", logger)
 			return
@@ -120,6 +135,32 @@ func portableHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func portablePluginSymbols(pinfo *portable.PluginInfo) *pluginSymbols {
+	return &pluginSymbols{
+		sources:   pinfo.Sources,
+		sinks:     pinfo.Sinks,
+		functions: pinfo.Functions,
+	}
+}
+
+// list the rules which depend on the portable plugin
+func portableDependentsHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	vars := mux.Vars(r)
+	name := vars["name"]
+	pinfo, ok := portableManager.GetPluginInfo(name)
+	if !ok {
+		handleError(w, errorx.NewWithCode(errorx.NOT_FOUND, "not found"), fmt.Sprintf("describe portable plugin %s error", name), logger)
+		return
+	}
+	dependents, err := pluginDependents(portablePluginSymbols(pinfo))
+	if err != nil {
+		handleError(w, err, fmt.Sprintf("find dependent rules of portable plugin %s error", name), logger)
+		return
+	}
+	jsonResponse(dependents, w, logger)
+}
+
 func portablePluginsReset() {
 	portableManager.UninstallAllPlugins()
 }
@@ -0,0 +1,112 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gorilla/mux"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/plugin"
+	"github.com/lf-edge/ekuiper/internal/plugin/repository"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+// pluginInstallers install a plugin by its type. They are registered by the plugin components of the build.
+var pluginInstallers = make(map[plugin.PluginType]func(p plugin.Plugin) error)
+
+func init() {
+	components["repository"] = repositoryComp{}
+}
+
+type repositoryComp struct{}
+
+func (p repositoryComp) register() {}
+
+func (p repositoryComp) rest(r *mux.Router) {
+	r.HandleFunc("/plugins/repository", repositoryHandler).Methods(http.MethodGet)
+	r.HandleFunc("/plugins/install", repositoryInstallHandler).Methods(http.MethodPost)
+}
+
+// list the plugins in the local repository
+func repositoryHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	repo, err := repository.Open(conf.Config.Basic.PluginRepository)
+	if err != nil {
+		handleError(w, err, "open plugin repository error", logger)
+		return
+	}
+	jsonResponse(repo.List(), w, logger)
+}
+
+// install a plugin from the local repository after verifying the package
+func repositoryInstallHandler(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	name := r.URL.Query().Get("name")
+	version := r.URL.Query().Get("version")
+	t := r.URL.Query().Get("type")
+	if name == "" {
+		handleError(w, errorx.New("missing query parameter name"), "install plugin from repository error", logger)
+		return
+	}
+	err := installFromRepository(name, version, t)
+	if err != nil {
+		handleError(w, err, fmt.Sprintf("install plugin %s from repository error", name), logger)
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(fmt.Sprintf("plugin %s is installed", name)))
+}
+
+func installFromRepository(name, version, t string) error {
+	repo, err := repository.Open(conf.Config.Basic.PluginRepository)
+	if err != nil {
+		return err
+	}
+	p, v, err := repo.Find(name, version, t)
+	if err != nil {
+		return err
+	}
+	pt := plugin.PluginTypeMap[p.Type]
+	installer, ok := pluginInstallers[pt]
+	if !ok {
+		return fmt.Errorf("%s plugin is not supported in this build", p.Type)
+	}
+	dataDir, err := conf.GetDataLoc()
+	if err != nil {
+		return err
+	}
+	// keep the verified package so that the install script can be used for importing
+	target := filepath.Join(dataDir, "repository", p.Type, fmt.Sprintf("%s@%s.zip", name, v.Version))
+	if err := repo.Fetch(v, target); err != nil {
+		return err
+	}
+	base := plugin.IOPlugin{
+		Name: name,
+		File: "file://" + filepath.ToSlash(target),
+	}
+	var pl plugin.Plugin
+	switch pt {
+	case plugin.FUNCTION, plugin.WASM:
+		pl = &plugin.FuncPlugin{IOPlugin: base, Functions: v.Functions}
+	default:
+		pl = &base
+	}
+	conf.Log.Infof("install %s plugin %s version %s from repository", p.Type, name, v.Version)
+	return installer(pl)
+}
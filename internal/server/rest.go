@@ -429,6 +429,15 @@ func tableSchemaHandler(w http.ResponseWriter, r *http.Request) {
 func sourceSchemaHandler(w http.ResponseWriter, r *http.Request, st ast.StreamType) {
 	vars := mux.Vars(r)
 	name := vars["name"]
+	if r.URL.Query().Get("history") == "true" {
+		content, err := streamProcessor.GetSchemaHistory(name, st)
+		if err != nil {
+			handleError(w, err, fmt.Sprintf("get schema history of %s error", ast.StreamTypeMap[st]), logger)
+			return
+		}
+		jsonResponse(content, w, logger)
+		return
+	}
 	content, err := streamProcessor.GetInferredJsonSchema(name, st)
 	if err != nil {
 		handleError(w, err, fmt.Sprintf("get schema of %s error", ast.StreamTypeMap[st]), logger)
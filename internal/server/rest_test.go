@@ -143,6 +143,15 @@ func (suite *RestTestSuite) Test_sourcesManageHandler() {
 	suite.r.ServeHTTP(w, req)
 	assert.Equal(suite.T(), http.StatusOK, w.Code)
 
+	req, _ = http.NewRequest(http.MethodGet, "http://localhost:8080/streams/alert/schema?history=true", bytes.NewBufferString("any"))
+	w = httptest.NewRecorder()
+	suite.r.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	history := map[string]interface{}{}
+	_ = json.NewDecoder(w.Result().Body).Decode(&history)
+	assert.Equal(suite.T(), float64(1), history["version"])
+	assert.Len(suite.T(), history["history"], 1)
+
 	// get table
 	req, _ = http.NewRequest(http.MethodGet, "http://localhost:8080/tables/alertTable", bytes.NewBufferString("any"))
 	w = httptest.NewRecorder()
@@ -19,11 +19,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/planner"
 	"github.com/lf-edge/ekuiper/internal/topo/rule"
+	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/errorx"
 	"github.com/lf-edge/ekuiper/pkg/infra"
@@ -219,6 +222,66 @@ func restartRule(name string) error {
 	return startRule(name)
 }
 
+// rulesOfStream returns the SQL rules which read the stream in the order of rule id
+func rulesOfStream(name string) []*rule.RuleState {
+	var states []*rule.RuleState
+	registry.RLock()
+	for _, rs := range registry.internal {
+		if rs.Rule == nil || rs.Rule.Sql == "" {
+			continue
+		}
+		stmt, err := xsql.GetStatementFromSql(rs.Rule.Sql)
+		if err != nil {
+			continue
+		}
+		for _, s := range xsql.GetStreams(stmt) {
+			if s == name {
+				states = append(states, rs)
+				break
+			}
+		}
+	}
+	registry.RUnlock()
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].RuleId < states[j].RuleId
+	})
+	return states
+}
+
+// validateRulesOfStream plans the rules which read the stream against its new statement. The schema change is
+// rejected if any rule is incompatible with it, such as referring to a dropped column, because the rule would fail
+// on its next start.
+func validateRulesOfStream(name string, statement string) error {
+	var errs []string
+	for _, rs := range rulesOfStream(name) {
+		if _, err := planner.PlanWithStream(rs.Rule, name, statement); err != nil {
+			errs = append(errs, fmt.Sprintf("rule %s: %v", rs.RuleId, err))
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("the new schema is incompatible with the rules, %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// updateRulesOfStream replans the running rules which read the stream to apply its new schema.
+// The rules have been validated against the new schema, so a failure here is unexpected and the rule keeps running with the old one.
+func updateRulesOfStream(name string) []string {
+	var result []string
+	for _, rs := range rulesOfStream(name) {
+		if s, _ := rs.GetState(); s != "Running" {
+			continue
+		}
+		if err := rs.UpdateTopo(rs.Rule); err != nil {
+			conf.Log.Warnf("rule %s keeps the previous schema of stream %s: %v", rs.RuleId, name, err)
+			result = append(result, fmt.Sprintf("Rule %s keeps the previous schema: %v.", rs.RuleId, err))
+		} else {
+			result = append(result, fmt.Sprintf("Rule %s is updated.", rs.RuleId))
+		}
+	}
+	return result
+}
+
 func getRuleStatus(name string) (string, error) {
 	if rs, ok := registry.Load(name); ok {
 		result, err := rs.GetState()
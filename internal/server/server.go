@@ -119,6 +119,8 @@ func StartUp(Version, LoadFileType string) {
 	initRuleset()
 
 	registry = &RuleRegistry{internal: make(map[string]*rule.RuleState)}
+	streamProcessor.SetSchemaChangeValidator(validateRulesOfStream)
+	streamProcessor.SetSchemaChangeHandler(updateRulesOfStream)
 	// Start lookup tables
 	streamProcessor.RecoverLookupTable()
 	// Start rules
@@ -45,6 +45,7 @@ func (p wasmComp) register() {
 		panic(err)
 	}
 	entries = append(entries, binder.FactoryEntry{Name: "wasm plugin", Factory: wasmManager, Weight: 8})
+	pluginInstallers[plugin.WASM] = wasmManager.Register
 }
 
 func (p wasmComp) rest(r *mux.Router) {
@@ -89,6 +89,7 @@ func (s *checkpointStore) getLatest() *completedCheckpoint {
 type Coordinator struct {
 	tasksToTrigger          []Responder
 	tasksToWaitFor          []Responder
+	sourceTasks             []StreamTask
 	sinkTasks               []SinkTask
 	pendingCheckpoints      *sync.Map
 	completedCheckpoints    *checkpointStore
@@ -135,6 +136,7 @@ func NewCoordinator(ruleId string, sources []StreamTask, operators []NonSourceTa
 	return &Coordinator{
 		tasksToTrigger:     sourceResponders,
 		tasksToWaitFor:     allResponders,
+		sourceTasks:        sources,
 		sinkTasks:          sinks,
 		pendingCheckpoints: new(sync.Map),
 		completedCheckpoints: &checkpointStore{
@@ -266,6 +268,12 @@ func (c *Coordinator) complete(checkpointId int64) {
 		}
 		c.completedCheckpoints.add(ccp.(*pendingCheckpoint).finalize())
 		c.pendingCheckpoints.Delete(checkpointId)
+		// Notify the sources so that they can release the data covered by the checkpoint
+		for _, t := range c.sourceTasks {
+			if l, ok := t.(CheckpointListener); ok {
+				l.CompleteCheckpoint(checkpointId)
+			}
+		}
 		// Drop the previous pendingCheckpoints
 		c.pendingCheckpoints.Range(func(a1 interface{}, a2 interface{}) bool {
 			cid := a1.(int64)
@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -25,6 +25,14 @@ type StreamTask interface {
 	SetQos(api.Qos)
 }
 
+// CheckpointListener is notified of the checkpoint lifecycle by the coordinator. It is implemented by the source tasks.
+type CheckpointListener interface {
+	// PrepareCheckpoint is called before the task state is snapshot
+	PrepareCheckpoint(checkpointId int64)
+	// CompleteCheckpoint is called after the checkpoint is saved. It must not block.
+	CompleteCheckpoint(checkpointId int64)
+}
+
 type NonSourceTask interface {
 	StreamTask
 	GetInputCount() int
@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -39,6 +39,7 @@ type MQTTConnectionConfig struct {
 	PrivateKPath       string `json:"privateKeyPath"`
 	RootCaPath         string `json:"rootCaPath"`
 	InsecureSkipVerify bool   `json:"insecureSkipVerify"`
+	TopicAliasMaximum  uint16 `json:"topicAliasMaximum"`
 }
 
 type MQTTClient struct {
@@ -48,8 +49,21 @@ type MQTTClient struct {
 	uName    string
 	password string
 	tls      *tls.Config
+	// The max number of topic aliases for each direction, only for MQTT v5
+	topicAliasMax uint16
 
-	conn MQTT.Client
+	conn  MQTT.Client
+	conn5 *mqtt5Conn
+}
+
+// PublishProperties are the MQTT v5 properties of a message to publish. They are ignored by MQTT v3 connections.
+type PublishProperties struct {
+	UserProperties  map[string]string
+	ResponseTopic   string
+	CorrelationData []byte
+	ContentType     string
+	// The message expiry interval in seconds, 0 means never expire
+	MessageExpiry uint32
 }
 
 func (ms *MQTTClient) CfgValidate(props map[string]interface{}) error {
@@ -77,9 +91,13 @@ func (ms *MQTTClient) CfgValidate(props map[string]interface{}) error {
 	}
 	// Default to MQTT 3.1.1 or NanoMQ cannot connect
 	ms.pVersion = 4
-	if cfg.PVersion == "3.1" {
+	switch cfg.PVersion {
+	case "3.1":
 		ms.pVersion = 3
+	case "5", "5.0":
+		ms.pVersion = 5
 	}
+	ms.topicAliasMax = cfg.TopicAliasMaximum
 
 	tlsOpts := cert.TlsConfigurationOptions{
 		SkipCertVerify: cfg.InsecureSkipVerify,
@@ -99,7 +117,17 @@ func (ms *MQTTClient) CfgValidate(props map[string]interface{}) error {
 	return nil
 }
 
-func (ms *MQTTClient) Connect(connHandler MQTT.OnConnectHandler, lostHandler MQTT.ConnectionLostHandler) error {
+func (ms *MQTTClient) Connect(connHandler func(), lostHandler func(error)) error {
+	if ms.pVersion == 5 {
+		c := newMqtt5Conn(ms, connHandler, lostHandler)
+		if err := c.connect(); err != nil {
+			conf.Log.Errorf("The connection to mqtt broker %s failed : %s ", ms.srv, err)
+			return fmt.Errorf("found error when connecting for %s: %s", ms.srv, err)
+		}
+		conf.Log.Infof("The connection to mqtt v5 broker is established successfully for %s.", ms.srv)
+		ms.conn5 = c
+		return nil
+	}
 	if conf.Config.Basic.Debug {
 		MQTT.DEBUG = conf.Log
 		MQTT.ERROR = conf.Log
@@ -116,8 +144,12 @@ func (ms *MQTTClient) Connect(connHandler MQTT.OnConnectHandler, lostHandler MQT
 	}
 	opts = opts.SetClientID(ms.clientid)
 	opts = opts.SetAutoReconnect(true)
-	opts.OnConnect = connHandler
-	opts.OnConnectionLost = lostHandler
+	opts.OnConnect = func(MQTT.Client) {
+		connHandler()
+	}
+	opts.OnConnectionLost = func(_ MQTT.Client, err error) {
+		lostHandler(err)
+	}
 	opts.OnReconnecting = func(MQTT.Client, *MQTT.ClientOptions) {
 		conf.Log.Infof("Reconnecting to mqtt broker %s client id %s", ms.srv, ms.clientid)
 	}
@@ -138,13 +170,29 @@ func (ms *MQTTClient) Connect(connHandler MQTT.OnConnectHandler, lostHandler MQT
 }
 
 func (ms *MQTTClient) Subscribe(topic string, qos byte, handler MQTT.MessageHandler) error {
+	if ms.conn5 != nil {
+		return ms.conn5.subscribe(topic, qos, handler)
+	}
 	if token := ms.conn.Subscribe(topic, qos, handler); token.WaitTimeout(5*time.Second) && token.Error() != nil {
 		return fmt.Errorf("%s: %s", errorx.IOErr, token.Error())
 	}
 	return nil
 }
 
-func (ms *MQTTClient) Publish(topic string, qos byte, retained bool, message []byte) error {
+func (ms *MQTTClient) Unsubscribe(topic string) error {
+	if ms.conn5 != nil {
+		return ms.conn5.unsubscribe(topic)
+	}
+	if token := ms.conn.Unsubscribe(topic); token.WaitTimeout(5*time.Second) && token.Error() != nil {
+		return fmt.Errorf("%s: %s", errorx.IOErr, token.Error())
+	}
+	return nil
+}
+
+func (ms *MQTTClient) Publish(topic string, qos byte, retained bool, message []byte, props *PublishProperties) error {
+	if ms.conn5 != nil {
+		return ms.conn5.publish(topic, qos, retained, message, props)
+	}
 	if token := ms.conn.Publish(topic, qos, retained, message); token.WaitTimeout(5*time.Second) && token.Error() != nil {
 		return fmt.Errorf("%s: %s", errorx.IOErr, token.Error())
 	}
@@ -153,6 +201,10 @@ func (ms *MQTTClient) Publish(topic string, qos byte, retained bool, message []b
 
 func (ms *MQTTClient) Disconnect() error {
 	conf.Log.Infof("Closing the connection to mqtt broker for %s", ms.srv)
+	if ms.conn5 != nil {
+		ms.conn5.disconnect()
+		return nil
+	}
 	if ms.conn != nil && ms.conn.IsConnected() {
 		ms.conn.Disconnect(5000)
 	}
@@ -0,0 +1,431 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mqtt
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/eclipse/paho.golang/paho"
+	MQTT "github.com/eclipse/paho.mqtt.golang"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/pkg/errorx"
+)
+
+const (
+	mqtt5Timeout        = 5 * time.Second
+	mqtt5ReconnectDelay = 5 * time.Second
+)
+
+// mqtt5Conn is the MQTT v5 connection. Unlike the v3 client, the paho v5 client does not reconnect by itself,
+// so the connection is recreated in the background once lost and the handlers are notified like the v3 client.
+type mqtt5Conn struct {
+	cli       *MQTTClient
+	onConnect func()
+	onLost    func(error)
+	done      chan struct{}
+
+	sync.RWMutex
+	conn      *paho.Client
+	connected bool
+	closed    bool
+	// subscription topic filter: handler
+	handlers map[string]MQTT.MessageHandler
+
+	aliasLock sync.Mutex
+	// topic aliases set by the broker for the incoming messages
+	inAliases map[uint16]string
+	// topic aliases for the outgoing messages, an alias is only used after the first message which sets it is sent
+	outAliases  map[string]uint16
+	outAliasSet map[string]bool
+	outAliasMax uint16
+}
+
+func newMqtt5Conn(cli *MQTTClient, onConnect func(), onLost func(error)) *mqtt5Conn {
+	return &mqtt5Conn{
+		cli:       cli,
+		onConnect: onConnect,
+		onLost:    onLost,
+		done:      make(chan struct{}),
+		handlers:  make(map[string]MQTT.MessageHandler),
+	}
+}
+
+func (c *mqtt5Conn) dial() (net.Conn, error) {
+	u, err := url.Parse(c.cli.srv)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: mqtt5Timeout}
+	switch strings.ToLower(u.Scheme) {
+	case "tcp", "mqtt":
+		return dialer.Dial("tcp", hostWithPort(u, "1883"))
+	case "ssl", "tls", "tcps", "mqtts":
+		return tls.DialWithDialer(dialer, "tcp", hostWithPort(u, "8883"), c.cli.tls)
+	default:
+		return nil, fmt.Errorf("unsupported scheme %s for mqtt v5, only tcp, mqtt, ssl, tls, tcps and mqtts are supported", u.Scheme)
+	}
+}
+
+func hostWithPort(u *url.URL, defaultPort string) string {
+	if u.Port() == "" {
+		return net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	return u.Host
+}
+
+func (c *mqtt5Conn) connect() error {
+	nc, err := c.dial()
+	if err != nil {
+		return err
+	}
+	pc := paho.NewClient(paho.ClientConfig{
+		ClientID:      c.cli.clientid,
+		Conn:          nc,
+		Router:        paho.NewSingleHandlerRouter(c.route),
+		OnClientError: c.lost,
+		OnServerDisconnect: func(d *paho.Disconnect) {
+			c.lost(fmt.Errorf("disconnected by server with reason code %d", d.ReasonCode))
+		},
+	})
+	cp := &paho.Connect{
+		KeepAlive:  30,
+		ClientID:   c.cli.clientid,
+		CleanStart: true,
+	}
+	if c.cli.uName != "" {
+		cp.Username = c.cli.uName
+		cp.UsernameFlag = true
+	}
+	if c.cli.password != "" {
+		cp.Password = []byte(c.cli.password)
+		cp.PasswordFlag = true
+	}
+	if c.cli.topicAliasMax > 0 {
+		max := c.cli.topicAliasMax
+		cp.Properties = &paho.ConnectProperties{TopicAliasMaximum: &max}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mqtt5Timeout)
+	defer cancel()
+	ca, err := pc.Connect(ctx, cp)
+	if err != nil {
+		_ = nc.Close()
+		return err
+	}
+	// The aliases only live in a network connection
+	c.aliasLock.Lock()
+	c.inAliases = make(map[uint16]string)
+	c.outAliases = make(map[string]uint16)
+	c.outAliasSet = make(map[string]bool)
+	c.outAliasMax = 0
+	if ca.Properties != nil && ca.Properties.TopicAliasMaximum != nil {
+		c.outAliasMax = *ca.Properties.TopicAliasMaximum
+		if c.outAliasMax > c.cli.topicAliasMax {
+			c.outAliasMax = c.cli.topicAliasMax
+		}
+	}
+	c.aliasLock.Unlock()
+
+	c.Lock()
+	c.conn = pc
+	c.connected = true
+	c.Unlock()
+	return nil
+}
+
+func (c *mqtt5Conn) lost(err error) {
+	c.Lock()
+	if !c.connected || c.closed {
+		c.Unlock()
+		return
+	}
+	c.connected = false
+	c.Unlock()
+	c.onLost(err)
+	go c.reconnect()
+}
+
+func (c *mqtt5Conn) reconnect() {
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-time.After(mqtt5ReconnectDelay):
+		}
+		conf.Log.Infof("Reconnecting to mqtt broker %s client id %s", c.cli.srv, c.cli.clientid)
+		if err := c.connect(); err != nil {
+			conf.Log.Warnf("Reconnect to mqtt broker %s failed: %v", c.cli.srv, err)
+			continue
+		}
+		c.RLock()
+		closed := c.closed
+		c.RUnlock()
+		if closed {
+			c.disconnect()
+			return
+		}
+		c.onConnect()
+		return
+	}
+}
+
+func (c *mqtt5Conn) getConn() (*paho.Client, error) {
+	c.RLock()
+	defer c.RUnlock()
+	if !c.connected {
+		return nil, fmt.Errorf("%s: %s", errorx.IOErr, "mqtt client is not connected")
+	}
+	return c.conn, nil
+}
+
+func (c *mqtt5Conn) route(p *paho.Publish) {
+	topic := p.Topic
+	if p.Properties != nil && p.Properties.TopicAlias != nil {
+		alias := *p.Properties.TopicAlias
+		c.aliasLock.Lock()
+		if topic != "" {
+			c.inAliases[alias] = topic
+		} else {
+			topic = c.inAliases[alias]
+		}
+		c.aliasLock.Unlock()
+	}
+	msg := &message5{p: p, topic: topic}
+	var handlers []MQTT.MessageHandler
+	c.RLock()
+	for filter, h := range c.handlers {
+		if matchTopic(filter, topic) {
+			handlers = append(handlers, h)
+		}
+	}
+	c.RUnlock()
+	for _, h := range handlers {
+		h(nil, msg)
+	}
+}
+
+func (c *mqtt5Conn) subscribe(topic string, qos byte, handler MQTT.MessageHandler) error {
+	c.Lock()
+	c.handlers[topic] = handler
+	c.Unlock()
+	pc, err := c.getConn()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mqtt5Timeout)
+	defer cancel()
+	sa, err := pc.Subscribe(ctx, &paho.Subscribe{
+		Subscriptions: []paho.SubscribeOptions{{Topic: topic, QoS: qos}},
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+	}
+	if sa != nil && len(sa.Reasons) > 0 && sa.Reasons[0] >= 0x80 {
+		return fmt.Errorf("subscribe topic %s failed with reason code %d", topic, sa.Reasons[0])
+	}
+	return nil
+}
+
+func (c *mqtt5Conn) unsubscribe(topic string) error {
+	c.Lock()
+	delete(c.handlers, topic)
+	c.Unlock()
+	pc, err := c.getConn()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), mqtt5Timeout)
+	defer cancel()
+	if _, err := pc.Unsubscribe(ctx, &paho.Unsubscribe{Topics: []string{topic}}); err != nil {
+		return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+	}
+	return nil
+}
+
+func (c *mqtt5Conn) publish(topic string, qos byte, retained bool, message []byte, props *PublishProperties) error {
+	pc, err := c.getConn()
+	if err != nil {
+		return err
+	}
+	p := &paho.Publish{
+		Topic:      topic,
+		QoS:        qos,
+		Retain:     retained,
+		Payload:    message,
+		Properties: &paho.PublishProperties{},
+	}
+	if props != nil {
+		for k, v := range props.UserProperties {
+			p.Properties.User = append(p.Properties.User, paho.UserProperty{Key: k, Value: v})
+		}
+		p.Properties.ResponseTopic = props.ResponseTopic
+		p.Properties.CorrelationData = props.CorrelationData
+		p.Properties.ContentType = props.ContentType
+		if props.MessageExpiry > 0 {
+			expiry := props.MessageExpiry
+			p.Properties.MessageExpiry = &expiry
+		}
+	}
+	newAlias := c.applyAlias(p)
+	ctx, cancel := context.WithTimeout(context.Background(), mqtt5Timeout)
+	defer cancel()
+	if _, err := pc.Publish(ctx, p); err != nil {
+		return fmt.Errorf("%s: %s", errorx.IOErr, err.Error())
+	}
+	if newAlias {
+		c.aliasLock.Lock()
+		c.outAliasSet[topic] = true
+		c.aliasLock.Unlock()
+	}
+	return nil
+}
+
+// applyAlias replaces the topic with its alias if it has been set. Otherwise, allocate an alias and send it along
+// with the topic if there are aliases left. Return whether a new alias is set by this message.
+func (c *mqtt5Conn) applyAlias(p *paho.Publish) bool {
+	c.aliasLock.Lock()
+	defer c.aliasLock.Unlock()
+	if c.outAliasMax == 0 {
+		return false
+	}
+	alias, ok := c.outAliases[p.Topic]
+	if ok {
+		if c.outAliasSet[p.Topic] {
+			p.Properties.TopicAlias = &alias
+			p.Topic = ""
+		}
+		// the message setting the alias is in flight, send the full topic
+		return false
+	}
+	if len(c.outAliases) >= int(c.outAliasMax) {
+		return false
+	}
+	alias = uint16(len(c.outAliases) + 1)
+	c.outAliases[p.Topic] = alias
+	p.Properties.TopicAlias = &alias
+	return true
+}
+
+func (c *mqtt5Conn) disconnect() {
+	c.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.done)
+	}
+	pc, connected := c.conn, c.connected
+	c.connected = false
+	c.Unlock()
+	if pc != nil && connected {
+		_ = pc.Disconnect(&paho.Disconnect{ReasonCode: 0})
+	}
+}
+
+// matchTopic checks if the topic matches the subscription filter which may be a shared subscription
+func matchTopic(filter, topic string) bool {
+	if strings.HasPrefix(filter, "$share/") {
+		parts := strings.SplitN(filter, "/", 3)
+		if len(parts) < 3 {
+			return false
+		}
+		filter = parts[2]
+	}
+	fs := strings.Split(filter, "/")
+	ts := strings.Split(topic, "/")
+	// The topics beginning with $ do not match the wildcards at the first level
+	if strings.HasPrefix(topic, "$") && (fs[0] == "+" || fs[0] == "#") {
+		return false
+	}
+	for i, f := range fs {
+		if f == "#" {
+			return true
+		}
+		if i >= len(ts) {
+			return false
+		}
+		if f != "+" && f != ts[i] {
+			return false
+		}
+	}
+	return len(fs) == len(ts)
+}
+
+// message5 adapts the MQTT v5 message to the message interface of the v3 client so that the subscribers can
+// handle messages from both versions. The v5 properties are available by the Properties method.
+type message5 struct {
+	p     *paho.Publish
+	topic string
+}
+
+func (m *message5) Duplicate() bool {
+	return false
+}
+
+func (m *message5) Qos() byte {
+	return m.p.QoS
+}
+
+func (m *message5) Retained() bool {
+	return m.p.Retain
+}
+
+func (m *message5) Topic() string {
+	return m.topic
+}
+
+func (m *message5) MessageID() uint16 {
+	return m.p.PacketID
+}
+
+func (m *message5) Payload() []byte {
+	return m.p.Payload
+}
+
+func (m *message5) Ack() {}
+
+// Properties returns the v5 properties set in the message to be used as the metadata
+func (m *message5) Properties() map[string]interface{} {
+	result := make(map[string]interface{})
+	props := m.p.Properties
+	if props == nil {
+		return result
+	}
+	if len(props.User) > 0 {
+		up := make(map[string]interface{}, len(props.User))
+		for _, u := range props.User {
+			up[u.Key] = u.Value
+		}
+		result["userProperties"] = up
+	}
+	if props.ResponseTopic != "" {
+		result["responseTopic"] = props.ResponseTopic
+	}
+	if len(props.CorrelationData) > 0 {
+		result["correlationData"] = string(props.CorrelationData)
+	}
+	if props.ContentType != "" {
+		result["contentType"] = props.ContentType
+	}
+	if props.MessageExpiry != nil {
+		result["messageExpiry"] = *props.MessageExpiry
+	}
+	return result
+}
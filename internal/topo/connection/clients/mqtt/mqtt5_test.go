@@ -0,0 +1,117 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mqtt
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/eclipse/paho.golang/paho"
+)
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		filter string
+		topic  string
+		match  bool
+	}{
+		{"a/b", "a/b", true},
+		{"a/b", "a/c", false},
+		{"a/+", "a/b", true},
+		{"a/+", "a/b/c", false},
+		{"a/#", "a/b/c", true},
+		{"a/#", "a", true},
+		{"#", "a/b", true},
+		{"+/b", "a/b", true},
+		{"#", "$SYS/clients", false},
+		{"$SYS/#", "$SYS/clients", true},
+		{"$share/g1/a/+", "a/b", true},
+		{"$share/g1/a/+", "b/b", false},
+		{"$share/g1", "g1", false},
+	}
+	for _, tt := range tests {
+		if got := matchTopic(tt.filter, tt.topic); got != tt.match {
+			t.Errorf("matchTopic(%s, %s) = %v, want %v", tt.filter, tt.topic, got, tt.match)
+		}
+	}
+}
+
+func TestMessage5Properties(t *testing.T) {
+	expiry := uint32(60)
+	m := &message5{
+		p: &paho.Publish{
+			QoS:     1,
+			Payload: []byte("hello"),
+			Properties: &paho.PublishProperties{
+				User:            paho.UserProperties{paho.UserProperty{Key: "device", Value: "d1"}, paho.UserProperty{Key: "site", Value: "s1"}},
+				ResponseTopic:   "reply/d1",
+				CorrelationData: []byte("req1"),
+				MessageExpiry:   &expiry,
+			},
+		},
+		topic: "data/d1",
+	}
+	if m.Topic() != "data/d1" || m.Qos() != 1 || string(m.Payload()) != "hello" {
+		t.Errorf("message mismatch: %s %d %s", m.Topic(), m.Qos(), m.Payload())
+	}
+	exp := map[string]interface{}{
+		"userProperties":  map[string]interface{}{"device": "d1", "site": "s1"},
+		"responseTopic":   "reply/d1",
+		"correlationData": "req1",
+		"messageExpiry":   uint32(60),
+	}
+	if got := m.Properties(); !reflect.DeepEqual(got, exp) {
+		t.Errorf("result mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", exp, got)
+	}
+}
+
+func TestGetPublishProperties(t *testing.T) {
+	if props := getPublishProperties(map[string]interface{}{"qos": byte(1), "retained": true}); props != nil {
+		t.Errorf("expect no properties but got %v", props)
+	}
+	exp := &PublishProperties{
+		UserProperties:  map[string]string{"device": "d1"},
+		ResponseTopic:   "reply/d1",
+		CorrelationData: []byte("req1"),
+		ContentType:     "application/json",
+		MessageExpiry:   30,
+	}
+	props := getPublishProperties(map[string]interface{}{
+		"qos":             byte(1),
+		"userProperties":  map[string]string{"device": "d1"},
+		"responseTopic":   "reply/d1",
+		"correlationData": "req1",
+		"contentType":     "application/json",
+		"messageExpiry":   uint32(30),
+	})
+	if !reflect.DeepEqual(props, exp) {
+		t.Errorf("result mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", exp, props)
+	}
+}
+
+func TestMQTTClient_CfgV5(t *testing.T) {
+	ms := &MQTTClient{}
+	err := ms.CfgValidate(map[string]interface{}{
+		"server":            "tcp://127.0.0.1:1883",
+		"protocolVersion":   "5",
+		"topicAliasMaximum": 10,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ms.pVersion != 5 || ms.topicAliasMax != 10 {
+		t.Errorf("result mismatch: version %d, topic alias maximum %d", ms.pVersion, ms.topicAliasMax)
+	}
+}
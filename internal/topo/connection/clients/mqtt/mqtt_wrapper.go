@@ -80,7 +80,7 @@ func NewMqttClientWrapper(props map[string]interface{}) (clients.ClientWrapper,
 	return cliWpr, nil
 }
 
-func (mc *mqttClientWrapper) onConnectHandler(_ pahoMqtt.Client) {
+func (mc *mqttClientWrapper) onConnectHandler() {
 	// activeSubscriptions will be empty on the first connection.
 	// On a re-connect is when the subscriptions must be re-created.
 	conf.Log.Infof("The connection to mqtt broker %s client id %s established", mc.cli.srv, mc.cli.clientid)
@@ -88,11 +88,11 @@ func (mc *mqttClientWrapper) onConnectHandler(_ pahoMqtt.Client) {
 	defer mc.subLock.Unlock()
 	mc.connected = true
 	for topic, subscription := range mc.topicSubscriptions {
-		token := mc.cli.conn.Subscribe(topic, subscription.qos, subscription.topicHandler)
-		if token.Error() != nil {
+		err := mc.cli.Subscribe(topic, subscription.qos, subscription.topicHandler)
+		if err != nil {
 			for _, con := range subscription.topicConsumers {
 				select {
-				case con.SubErrors <- token.Error():
+				case con.SubErrors <- err:
 					break
 				default:
 					conf.Log.Warnf("consumer SubErrors channel full for request id %s", con.ConsumerId)
@@ -102,7 +102,7 @@ func (mc *mqttClientWrapper) onConnectHandler(_ pahoMqtt.Client) {
 	}
 }
 
-func (mc *mqttClientWrapper) onConnectLost(_ pahoMqtt.Client, err error) {
+func (mc *mqttClientWrapper) onConnectLost(err error) {
 	mc.subLock.Lock()
 	defer mc.subLock.Unlock()
 	mc.connected = false
@@ -157,7 +157,7 @@ func (mc *mqttClientWrapper) Publish(_ api.StreamContext, topic string, message
 		}
 	}
 
-	err = mc.cli.Publish(topic, Qos, retained, message)
+	err = mc.cli.Publish(topic, Qos, retained, message, getPublishProperties(params))
 	if err != nil {
 		return err
 	}
@@ -165,6 +165,36 @@ func (mc *mqttClientWrapper) Publish(_ api.StreamContext, topic string, message
 	return nil
 }
 
+// getPublishProperties reads the MQTT v5 properties from the publish params
+func getPublishProperties(params map[string]interface{}) *PublishProperties {
+	props := &PublishProperties{}
+	found := false
+	if v, ok := params["userProperties"].(map[string]string); ok && len(v) > 0 {
+		props.UserProperties = v
+		found = true
+	}
+	if v, ok := params["responseTopic"].(string); ok && v != "" {
+		props.ResponseTopic = v
+		found = true
+	}
+	if v, ok := params["correlationData"].(string); ok && v != "" {
+		props.CorrelationData = []byte(v)
+		found = true
+	}
+	if v, ok := params["contentType"].(string); ok && v != "" {
+		props.ContentType = v
+		found = true
+	}
+	if v, ok := params["messageExpiry"].(uint32); ok && v > 0 {
+		props.MessageExpiry = v
+		found = true
+	}
+	if !found {
+		return nil
+	}
+	return props
+}
+
 func (mc *mqttClientWrapper) checkConn() error {
 	mc.subLock.RLock()
 	defer mc.subLock.RUnlock()
@@ -221,9 +251,8 @@ func (mc *mqttClientWrapper) Subscribe(c api.StreamContext, subChan []api.TopicC
 			}
 			sub.topicHandler = mc.newMessageHandler(sub)
 			log.Infof("new subscription for topic %s, reqId is %s", tpc, subId)
-			token := mc.cli.conn.Subscribe(tpc, Qos, sub.topicHandler)
-			if token.Error() != nil {
-				return token.Error()
+			if err := mc.cli.Subscribe(tpc, Qos, sub.topicHandler); err != nil {
+				return err
 			}
 			mc.topicSubscriptions[tpc] = sub
 		}
@@ -256,7 +285,9 @@ func (mc *mqttClientWrapper) unsubscribe(c api.StreamContext) {
 			if 0 == len(sub.topicConsumers) {
 				delete(mc.topicSubscriptions, tpc)
 				log.Infof("delete subscription for topic %s", tpc)
-				mc.cli.conn.Unsubscribe(tpc)
+				if err := mc.cli.Unsubscribe(tpc); err != nil {
+					log.Warnf("unsubscribe topic %s error: %v", tpc, err)
+				}
 			}
 		}
 	}
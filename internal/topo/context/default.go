@@ -33,6 +33,12 @@ import (
 
 const LoggerKey = "$$logger"
 
+// StateSnapshotter is implemented by the state values which are changed in place. The snapshot saves the copy
+// returned by SnapshotState instead of the value so that it stays consistent while the value keeps changing.
+type StateSnapshotter interface {
+	SnapshotState() interface{}
+}
+
 type DefaultContext struct {
 	ruleId     string
 	opId       string
@@ -249,7 +255,15 @@ func (c *DefaultContext) DeleteState(key string) error {
 }
 
 func (c *DefaultContext) Snapshot() error {
-	c.snapshot = cast.SyncMapToMap(c.state)
+	m := make(map[string]interface{})
+	c.state.Range(func(k interface{}, v interface{}) bool {
+		if s, ok := v.(StateSnapshotter); ok {
+			v = s.SnapshotState()
+		}
+		m[fmt.Sprintf("%v", k)] = v
+		return true
+	})
+	c.snapshot = m
 	return nil
 }
 
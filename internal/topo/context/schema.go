@@ -0,0 +1,29 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package context
+
+import (
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+const OutputSchemaKey = "$$outputSchema"
+
+// GetOutputSchema returns the types of the rule output fields resolved by the planner for the sinks.
+// It is nil if the types are unknown.
+func GetOutputSchema(ctx api.StreamContext) map[string]ast.DataType {
+	s, _ := ctx.Value(OutputSchemaKey).(map[string]ast.DataType)
+	return s
+}
@@ -159,4 +159,24 @@ var OpIO = map[string][]*IOType{
 		{Type: IOINPUT_TYPE_ROW, RowType: IOROW_TYPE_ANY, CollectionType: IOCOLLECTION_TYPE_ANY},
 		{Type: IOINPUT_TYPE_SAME},
 	},
+	"dedup": {
+		{Type: IOINPUT_TYPE_ROW, RowType: IOROW_TYPE_ANY, CollectionType: IOCOLLECTION_TYPE_ANY},
+		{Type: IOINPUT_TYPE_SAME},
+	},
+	"sample": {
+		{Type: IOINPUT_TYPE_ROW, RowType: IOROW_TYPE_ANY, CollectionType: IOCOLLECTION_TYPE_ANY},
+		{Type: IOINPUT_TYPE_SAME},
+	},
+	"throttle": {
+		{Type: IOINPUT_TYPE_ROW, RowType: IOROW_TYPE_SINGLE, CollectionType: IOCOLLECTION_TYPE_ANY},
+		{Type: IOINPUT_TYPE_SAME},
+	},
+	"debounce": {
+		{Type: IOINPUT_TYPE_ROW, RowType: IOROW_TYPE_SINGLE, CollectionType: IOCOLLECTION_TYPE_ANY},
+		{Type: IOINPUT_TYPE_SAME},
+	},
+	"pattern": {
+		{Type: IOINPUT_TYPE_ROW, RowType: IOROW_TYPE_SINGLE, CollectionType: IOCOLLECTION_TYPE_ANY},
+		{Type: IOINPUT_TYPE_COLLECTION, CollectionType: IOCOLLECTION_TYPE_SINGLE, RowType: IOROW_TYPE_SINGLE},
+	},
 }
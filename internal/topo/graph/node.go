@@ -58,6 +58,38 @@ type Switch struct {
 	StopAtFirstMatch bool     `json:"stopAtFirstMatch"`
 }
 
+type Dedup struct {
+	Keys              []string `json:"keys"`
+	Within            string   `json:"within"`
+	MaxKeys           int      `json:"maxKeys"`
+	FalsePositiveRate float64  `json:"falsePositiveRate"`
+}
+
+type Pattern struct {
+	Pattern   string            `json:"pattern"`
+	Within    string            `json:"within"`
+	Partition []string          `json:"partition"`
+	Define    map[string]string `json:"define"`
+}
+
+type Sample struct {
+	Every   int      `json:"every"`
+	Percent float64  `json:"percent"`
+	Keys    []string `json:"keys"`
+}
+
+type Throttle struct {
+	Limit    int      `json:"limit"`
+	Interval string   `json:"interval"`
+	Keys     []string `json:"keys"`
+	Policy   string   `json:"policy"`
+}
+
+type Debounce struct {
+	Quiet string   `json:"quiet"`
+	Keys  []string `json:"keys"`
+}
+
 type Script struct {
 	Script string `json:"script"`
 	IsAgg  bool   `json:"isAgg"`
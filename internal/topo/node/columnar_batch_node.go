@@ -0,0 +1,250 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/benbjohnson/clock"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+const COLUMNAR_PENDING_KEY = "$$columnarPending"
+
+type ColumnarBatchConf struct {
+	// Condition is nil if the rows are not filtered in the batches
+	Condition ast.Expr
+	// Projections are the select expressions whose results are cached in the rows
+	Projections []*ast.BinaryExpr
+	// Types are the types of the columns referred by the condition and the projections
+	Types     map[string]ast.DataType
+	BatchSize int
+	// Linger is the max time in milliseconds to wait for a batch to be full
+	Linger int64
+}
+
+// ColumnarBatchNode collects the tuples into columnar batches and filters each batch by evaluating the condition
+// over the column vectors at once. Then the projections are calculated over the selected rows and cached in them.
+// The batch is sent to the window. If a batch cannot be evaluated as vectors, such as a value not matching the column
+// type or being divided by zero, it falls back to evaluate the condition row by row and leaves the projections to the
+// project op.
+type ColumnarBatchNode struct {
+	*defaultSinkNode
+	conf        *ColumnarBatchConf
+	statManager metric.StatManager
+	pending     []*xsql.Tuple
+}
+
+func NewColumnarBatchNode(name string, c ColumnarBatchConf, options *api.RuleOption) (*ColumnarBatchNode, error) {
+	if c.BatchSize <= 0 {
+		return nil, fmt.Errorf("invalid columnar batch size %d", c.BatchSize)
+	}
+	if c.Linger <= 0 {
+		c.Linger = 10
+	}
+	n := &ColumnarBatchNode{
+		conf:    &c,
+		pending: make([]*xsql.Tuple, 0, c.BatchSize),
+	}
+	n.defaultSinkNode = &defaultSinkNode{
+		input: make(chan interface{}, options.BufferLength),
+		defaultNode: &defaultNode{
+			outputs:   make(map[string]chan<- interface{}),
+			name:      name,
+			sendError: options.SendError,
+		},
+	}
+	return n, nil
+}
+
+func (n *ColumnarBatchNode) Exec(ctx api.StreamContext, errCh chan<- error) {
+	n.ctx = ctx
+	log := ctx.GetLogger()
+	log.Debugf("ColumnarBatchNode %s is started", n.name)
+
+	if len(n.outputs) <= 0 {
+		infra.DrainError(ctx, fmt.Errorf("no output channel found"), errCh)
+		return
+	}
+	stats, err := metric.NewStatManager(ctx, "op")
+	if err != nil {
+		infra.DrainError(ctx, err, errCh)
+		return
+	}
+	n.statManager = stats
+	if s, err := ctx.GetState(COLUMNAR_PENDING_KEY); err == nil {
+		if pending, ok := s.([]*xsql.Tuple); ok {
+			n.pending = append(n.pending, pending...)
+			log.Infof("Restore %d pending tuples of the columnar batch", len(pending))
+		}
+	} else {
+		log.Warnf("Restore columnar batch state fails: %s", err)
+	}
+	go func() {
+		err := infra.SafeRun(func() error {
+			fv, _ := xsql.NewFunctionValuersForOp(ctx)
+			var (
+				lingerTimer *clock.Timer
+				lingerC     <-chan time.Time
+			)
+			flush := func() {
+				if lingerTimer != nil {
+					lingerTimer.Stop()
+					lingerTimer, lingerC = nil, nil
+				}
+				if len(n.pending) == 0 {
+					return
+				}
+				n.statManager.ProcessTimeStart()
+				n.flush(ctx, fv)
+				n.statManager.ProcessTimeEnd()
+				_ = ctx.PutState(COLUMNAR_PENDING_KEY, n.pending)
+			}
+			if len(n.pending) > 0 {
+				lingerTimer = conf.GetTimer(n.conf.Linger)
+				lingerC = lingerTimer.C
+			}
+			for {
+				log.Debugf("ColumnarBatchNode %s is looping", n.name)
+				select {
+				case item, opened := <-n.input:
+					processed := false
+					if item, processed = n.preprocess(item); processed {
+						break
+					}
+					n.statManager.IncTotalRecordsIn()
+					if !opened {
+						n.statManager.IncTotalExceptions("input channel closed")
+						break
+					}
+					switch d := item.(type) {
+					case error:
+						n.Broadcast(d)
+						n.statManager.IncTotalExceptions(d.Error())
+					case *xsql.Tuple:
+						n.pending = append(n.pending, d)
+						if len(n.pending) >= n.conf.BatchSize {
+							flush()
+						} else {
+							if lingerTimer == nil {
+								lingerTimer = conf.GetTimer(n.conf.Linger)
+								lingerC = lingerTimer.C
+							}
+							_ = ctx.PutState(COLUMNAR_PENDING_KEY, n.pending)
+						}
+					default:
+						e := fmt.Errorf("run columnar batch error: invalid input type but got %[1]T(%[1]v)", d)
+						n.Broadcast(e)
+						n.statManager.IncTotalExceptions(e.Error())
+					}
+					n.statManager.SetBufferLength(int64(len(n.input)))
+				case <-lingerC:
+					flush()
+				case <-ctx.Done():
+					log.Infoln("Cancelling columnar batch node....")
+					if lingerTimer != nil {
+						lingerTimer.Stop()
+					}
+					return nil
+				}
+			}
+		})
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+		}
+	}()
+}
+
+// flush filters the pending tuples and sends out the selected ones as a batch
+func (n *ColumnarBatchNode) flush(ctx api.StreamContext, fv *xsql.FunctionValuer) {
+	b, err := n.filterVector()
+	if err != nil {
+		ctx.GetLogger().Debugf("Columnar batch falls back to rows: %v", err)
+		b = n.filterRows(fv)
+	} else {
+		for _, p := range n.conf.Projections {
+			// The rows without the cached value will evaluate the expression in the project op
+			if err := b.Project(p); err != nil {
+				ctx.GetLogger().Debugf("Columnar projection %s falls back to rows: %v", p.CachedField, err)
+			}
+		}
+	}
+	n.pending = make([]*xsql.Tuple, 0, n.conf.BatchSize)
+	if len(b.Selected()) > 0 {
+		n.Broadcast(b)
+		n.statManager.IncTotalRecordsOut()
+	}
+}
+
+func (n *ColumnarBatchNode) filterVector() (*xsql.ColumnBatch, error) {
+	b := xsql.NewColumnBatch(n.conf.Types, len(n.pending))
+	for _, t := range n.pending {
+		if err := b.AddTuple(t); err != nil {
+			return nil, err
+		}
+	}
+	if n.conf.Condition != nil {
+		if err := b.Filter(n.conf.Condition); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// filterRows evaluates the condition row by row like the filter op
+func (n *ColumnarBatchNode) filterRows(fv *xsql.FunctionValuer) *xsql.ColumnBatch {
+	if n.conf.Condition == nil {
+		return &xsql.ColumnBatch{Tuples: n.pending}
+	}
+	b := &xsql.ColumnBatch{Tuples: n.pending, Sel: make([]int, 0, len(n.pending))}
+	for i, t := range n.pending {
+		ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(t, fv)}
+		switch r := ve.Eval(n.conf.Condition).(type) {
+		case error:
+			e := fmt.Errorf("run Where error: %s", r)
+			n.sendDeadLetter(t, e)
+			n.Broadcast(e)
+			n.statManager.IncTotalExceptions(e.Error())
+		case bool:
+			if r {
+				b.Sel = append(b.Sel, i)
+			}
+		case nil: // nil is false
+		default:
+			e := fmt.Errorf("run Where error: invalid condition that returns non-bool value %[1]T(%[1]v)", r)
+			n.sendDeadLetter(t, e)
+			n.Broadcast(e)
+			n.statManager.IncTotalExceptions(e.Error())
+		}
+	}
+	return b
+}
+
+func (n *ColumnarBatchNode) GetMetrics() [][]interface{} {
+	if n.statManager != nil {
+		return [][]interface{}{
+			n.statManager.GetMetrics(),
+		}
+	} else {
+		return nil
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"fmt"
+
+	"golang.org/x/time/rate"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+// DeadLetter is a failed event reported by a node of the rule
+type DeadLetter struct {
+	Payload   interface{}
+	Err       error
+	Node      string
+	Timestamp int64
+}
+
+// DeadLetterNode collects the failed events of all nodes in a rule and emits them to the dead letter sink.
+// It is not part of the checkpoint, so the dead letters are sent in best effort.
+type DeadLetterNode struct {
+	*defaultSinkNode
+	limit int
+}
+
+// NewDeadLetterNode creates the dead letter node which emits at most limit events per second. A non-positive limit means no limit.
+func NewDeadLetterNode(name string, limit int, options *api.RuleOption) *DeadLetterNode {
+	return &DeadLetterNode{
+		defaultSinkNode: &defaultSinkNode{
+			input: make(chan interface{}, options.BufferLength),
+			defaultNode: &defaultNode{
+				name:        name,
+				outputs:     make(map[string]chan<- interface{}),
+				concurrency: 1,
+			},
+		},
+		limit: limit,
+	}
+}
+
+// Send puts the failed event to the dead letter without blocking the caller. The event is dropped if the buffer is full.
+func (d *DeadLetterNode) Send(nodeName string, payload interface{}, err error) {
+	// convert the rows to maps in case they are modified by the following nodes
+	switch pt := payload.(type) {
+	case xsql.Collection:
+		payload = pt.ToMaps()
+	case xsql.Row:
+		payload = pt.ToMap()
+	}
+	dl := &DeadLetter{
+		Payload:   payload,
+		Err:       err,
+		Node:      nodeName,
+		Timestamp: conf.GetNowInMilli(),
+	}
+	select {
+	case d.input <- dl:
+	default:
+		conf.Log.Warnf("dead letter %s buffer is full, drop the failed event of %s: %v", d.name, nodeName, err)
+	}
+}
+
+func (d *DeadLetterNode) Exec(ctx api.StreamContext, errCh chan<- error) {
+	d.ctx = ctx
+	ctx.GetLogger().Debugf("Dead letter %s is started", d.name)
+	if len(d.outputs) <= 0 {
+		infra.DrainError(ctx, fmt.Errorf("no output channel found"), errCh)
+		return
+	}
+	stats, err := metric.NewStatManager(ctx, "op")
+	if err != nil {
+		infra.DrainError(ctx, err, errCh)
+		return
+	}
+	d.statManagers = []metric.StatManager{stats}
+	var limiter *rate.Limiter
+	if d.limit > 0 {
+		limiter = rate.NewLimiter(rate.Limit(d.limit), d.limit)
+	}
+	go func() {
+		err := infra.SafeRun(func() error {
+			for {
+				select {
+				case item := <-d.input:
+					dl, ok := item.(*DeadLetter)
+					if !ok {
+						continue
+					}
+					stats.IncTotalRecordsIn()
+					if limiter != nil && !limiter.Allow() {
+						stats.IncTotalExceptions("dead letter rate limit exceeded")
+						continue
+					}
+					stats.ProcessTimeStart()
+					val := toDeadLetterTuple(d.name, dl)
+					d.Broadcast(val)
+					stats.ProcessTimeEnd()
+					stats.IncTotalRecordsOut()
+					stats.SetBufferLength(int64(len(d.input)))
+				case <-ctx.Done():
+					ctx.GetLogger().Infof("dead letter %s done", d.name)
+					return nil
+				}
+			}
+		})
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+		}
+	}()
+}
+
+func toDeadLetterTuple(emitter string, dl *DeadLetter) *xsql.Tuple {
+	payload := dl.Payload
+	// keep the raw payload readable so that it can be replayed
+	if b, ok := payload.([]byte); ok {
+		payload = string(b)
+	}
+	errMsg := ""
+	if dl.Err != nil {
+		errMsg = dl.Err.Error()
+	}
+	return &xsql.Tuple{
+		Emitter: emitter,
+		Message: xsql.Message{
+			"payload":   payload,
+			"error":     errMsg,
+			"node":      dl.Node,
+			"timestamp": dl.Timestamp,
+		},
+		Timestamp: dl.Timestamp,
+	}
+}
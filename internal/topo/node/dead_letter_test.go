@@ -0,0 +1,93 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestDeadLetter(t *testing.T) {
+	conf.InitClock()
+	ts := conf.GetNowInMilli()
+	tests := []struct {
+		name    string
+		limit   int
+		inputs  []*DeadLetter
+		outputs []*xsql.Tuple
+	}{
+		{
+			name:  "raw payload and rows",
+			limit: 0,
+			inputs: []*DeadLetter{
+				{Payload: []byte(`{"a":`), Err: errors.New("invalid json"), Node: "src"},
+				{Payload: &xsql.Tuple{Message: xsql.Message{"a": 1}}, Err: errors.New("divide by zero"), Node: "project"},
+			},
+			outputs: []*xsql.Tuple{
+				{Emitter: "deadLetter", Message: xsql.Message{"payload": `{"a":`, "error": "invalid json", "node": "src", "timestamp": ts}, Timestamp: ts},
+				{Emitter: "deadLetter", Message: xsql.Message{"payload": map[string]interface{}{"a": 1}, "error": "divide by zero", "node": "project", "timestamp": ts}, Timestamp: ts},
+			},
+		}, {
+			name:  "rate limit",
+			limit: 2,
+			inputs: []*DeadLetter{
+				{Payload: []byte("1"), Err: errors.New("e1"), Node: "src"},
+				{Payload: []byte("2"), Err: errors.New("e2"), Node: "src"},
+				{Payload: []byte("3"), Err: errors.New("e3"), Node: "src"},
+			},
+			outputs: []*xsql.Tuple{
+				{Emitter: "deadLetter", Message: xsql.Message{"payload": "1", "error": "e1", "node": "src", "timestamp": ts}, Timestamp: ts},
+				{Emitter: "deadLetter", Message: xsql.Message{"payload": "2", "error": "e2", "node": "src", "timestamp": ts}, Timestamp: ts},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			contextLogger := conf.Log.WithField("rule", "TestDeadLetter")
+			ctx, cancel := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithCancel()
+			defer cancel()
+			dl := NewDeadLetterNode("deadLetter", tt.limit, &api.RuleOption{BufferLength: 10})
+			output := make(chan interface{}, 10)
+			_ = dl.AddOutput(output, "output")
+			errCh := make(chan error)
+			dl.Exec(ctx, errCh)
+			for _, in := range tt.inputs {
+				dl.Send(in.Node, in.Payload, in.Err)
+			}
+			var actual []*xsql.Tuple
+		outer:
+			for {
+				select {
+				case err := <-errCh:
+					t.Fatalf("Error received: %v", err)
+				case out := <-output:
+					actual = append(actual, out.(*xsql.Tuple))
+				case <-time.After(100 * time.Millisecond):
+					break outer
+				}
+			}
+			if !reflect.DeepEqual(tt.outputs, actual) {
+				t.Errorf("Expected: %v, actual: %v", tt.outputs, actual)
+			}
+		})
+	}
+}
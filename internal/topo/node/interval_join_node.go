@@ -0,0 +1,388 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+	"github.com/lf-edge/ekuiper/pkg/infra"
+)
+
+const INTERVAL_JOIN_STATE_KEY = "$$intervalJoinState"
+
+type IntervalJoinConf struct {
+	JoinType ast.JoinType
+	// Left and Right are the name and alias of the streams
+	Left      []string
+	Right     []string
+	LeftKeys  []ast.Expr
+	RightKeys []ast.Expr
+	LeftTime  ast.Expr
+	RightTime ast.Expr
+	// Lower and Upper bound the right time relative to the left time in milliseconds
+	Lower     int64
+	Upper     int64
+	Condition ast.Expr
+}
+
+// IntervalJoinRow is a buffered row of one side
+type IntervalJoinRow struct {
+	Tuple   *xsql.Tuple
+	Key     string
+	Time    int64
+	Matched bool
+}
+
+// IntervalJoinState is the buffered rows of both sides by join key and the max time seen to calculate the watermark.
+// It is put into the context once and updated in place.
+type IntervalJoinState struct {
+	Buffers [2]map[string][]*IntervalJoinRow
+	MaxTime int64
+}
+
+func newIntervalJoinState() *IntervalJoinState {
+	return &IntervalJoinState{Buffers: [2]map[string][]*IntervalJoinRow{make(map[string][]*IntervalJoinRow), make(map[string][]*IntervalJoinRow)}}
+}
+
+func init() {
+	gob.Register(&IntervalJoinState{})
+}
+
+// IntervalJoinNode joins the rows of two streams whose time are in the bound. The rows are buffered by the
+// join key and evicted once the watermark passes the bound. The unmatched rows are emitted on eviction for outer join.
+type IntervalJoinNode struct {
+	*defaultSinkNode
+	conf        *IntervalJoinConf
+	lateTol     int64
+	statManager metric.StatManager
+	st          *IntervalJoinState
+	// evicted is the watermark of the last eviction
+	evicted int64
+}
+
+func NewIntervalJoinNode(name string, c IntervalJoinConf, options *api.RuleOption) (*IntervalJoinNode, error) {
+	if len(c.LeftKeys) != len(c.RightKeys) {
+		return nil, fmt.Errorf("the join keys of both sides mismatch")
+	}
+	n := &IntervalJoinNode{
+		conf:    &c,
+		lateTol: options.LateTol,
+		st:      newIntervalJoinState(),
+	}
+	n.defaultSinkNode = &defaultSinkNode{
+		input: make(chan interface{}, options.BufferLength),
+		defaultNode: &defaultNode{
+			outputs:   make(map[string]chan<- interface{}),
+			name:      name,
+			sendError: options.SendError,
+		},
+	}
+	return n, nil
+}
+
+func (n *IntervalJoinNode) Exec(ctx api.StreamContext, errCh chan<- error) {
+	n.ctx = ctx
+	log := ctx.GetLogger()
+	log.Debugf("IntervalJoinNode %s is started", n.name)
+
+	if len(n.outputs) <= 0 {
+		infra.DrainError(ctx, fmt.Errorf("no output channel found"), errCh)
+		return
+	}
+	stats, err := metric.NewStatManager(ctx, "op")
+	if err != nil {
+		infra.DrainError(ctx, err, errCh)
+		return
+	}
+	n.statManager = stats
+	if err := n.restore(ctx); err != nil {
+		infra.DrainError(ctx, err, errCh)
+		return
+	}
+	go func() {
+		err := infra.SafeRun(func() error {
+			fv, _ := xsql.NewFunctionValuersForOp(ctx)
+			for {
+				log.Debugf("IntervalJoinNode %s is looping", n.name)
+				select {
+				case item, opened := <-n.input:
+					processed := false
+					if item, processed = n.preprocess(item); processed {
+						break
+					}
+					n.statManager.IncTotalRecordsIn()
+					n.statManager.ProcessTimeStart()
+					if !opened {
+						n.statManager.IncTotalExceptions("input channel closed")
+						break
+					}
+					switch d := item.(type) {
+					case error:
+						n.Broadcast(d)
+						n.statManager.IncTotalExceptions(d.Error())
+					case *xsql.Tuple:
+						log.Debugf("IntervalJoinNode receive tuple input %s", d)
+						sets, err := n.process(d, fv)
+						if err != nil {
+							n.sendDeadLetter(d, err)
+							n.Broadcast(err)
+							n.statManager.IncTotalExceptions(err.Error())
+						} else if len(sets.Content) > 0 {
+							n.Broadcast(sets)
+							n.statManager.IncTotalRecordsOut()
+						}
+					default:
+						e := fmt.Errorf("run interval join error: invalid input type but got %[1]T(%[1]v)", d)
+						n.sendDeadLetter(d, e)
+						n.Broadcast(e)
+						n.statManager.IncTotalExceptions(e.Error())
+					}
+					n.statManager.ProcessTimeEnd()
+					n.statManager.SetBufferLength(int64(len(n.input)))
+				case <-ctx.Done():
+					log.Infoln("Cancelling interval join node....")
+					return nil
+				}
+			}
+		})
+		if err != nil {
+			infra.DrainError(ctx, err, errCh)
+		}
+	}()
+}
+
+func (n *IntervalJoinNode) restore(ctx api.StreamContext) error {
+	s, err := ctx.GetState(INTERVAL_JOIN_STATE_KEY)
+	if err != nil {
+		ctx.GetLogger().Warnf("Restore interval join state fails: %s", err)
+		return nil
+	}
+	switch st := s.(type) {
+	case *IntervalJoinState:
+		for i, buffer := range st.Buffers {
+			if buffer == nil {
+				st.Buffers[i] = make(map[string][]*IntervalJoinRow)
+			}
+		}
+		n.st = st
+		ctx.GetLogger().Infof("Restore interval join state with %d left keys and %d right keys", len(st.Buffers[0]), len(st.Buffers[1]))
+	case nil:
+		ctx.GetLogger().Debugf("Restore interval join state, nothing")
+		return ctx.PutState(INTERVAL_JOIN_STATE_KEY, n.st)
+	default:
+		return fmt.Errorf("restore interval join state %v error, invalid type", st)
+	}
+	return nil
+}
+
+// side returns 0 for the left stream, 1 for the right stream
+func (n *IntervalJoinNode) side(emitter string) int {
+	for _, name := range n.conf.Left {
+		if name != "" && name == emitter {
+			return 0
+		}
+	}
+	for _, name := range n.conf.Right {
+		if name != "" && name == emitter {
+			return 1
+		}
+	}
+	return -1
+}
+
+// process matches the row with the buffered rows of the other side, buffers it and evicts the expired rows
+func (n *IntervalJoinNode) process(d *xsql.Tuple, fv *xsql.FunctionValuer) (*xsql.JoinTuples, error) {
+	sets := &xsql.JoinTuples{Content: make([]*xsql.JoinTuple, 0)}
+	side := n.side(d.Emitter)
+	if side < 0 {
+		return sets, fmt.Errorf("interval join receives tuple from unknown stream %s", d.Emitter)
+	}
+	keyExprs, timeExpr := n.conf.LeftKeys, n.conf.LeftTime
+	if side == 1 {
+		keyExprs, timeExpr = n.conf.RightKeys, n.conf.RightTime
+	}
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(d, fv)}
+	keys := make([]interface{}, len(keyExprs))
+	nullKey := false
+	for i, e := range keyExprs {
+		keys[i] = ve.Eval(e)
+		switch k := keys[i].(type) {
+		case error:
+			return sets, k
+		case nil:
+			nullKey = true
+		}
+	}
+	tv := ve.Eval(timeExpr)
+	if err, ok := tv.(error); ok {
+		return sets, err
+	}
+	t, err := cast.InterfaceToUnixMilli(tv, "")
+	if err != nil {
+		return sets, fmt.Errorf("invalid interval join time %v: %v", tv, err)
+	}
+	// The bound of the other side's time
+	lower, upper := t+n.conf.Lower, t+n.conf.Upper
+	if side == 1 {
+		lower, upper = t-n.conf.Upper, t-n.conf.Lower
+	}
+	// A null key never equals any key, so the row is not buffered and is unmatched for outer join
+	if nullKey {
+		if n.emitUnmatched(side) {
+			merged := &xsql.JoinTuple{}
+			merged.AddTuple(d)
+			sets.Content = append(sets.Content, merged)
+		}
+		n.advance(sets, t, upper)
+		return sets, nil
+	}
+	row := &IntervalJoinRow{Tuple: d, Key: joinKey(keys), Time: t}
+	for _, other := range n.st.Buffers[1-side][row.Key] {
+		if other.Time < lower || other.Time > upper {
+			continue
+		}
+		merged := &xsql.JoinTuple{}
+		if side == 0 {
+			merged.AddTuples([]xsql.TupleRow{d, other.Tuple})
+		} else {
+			merged.AddTuples([]xsql.TupleRow{other.Tuple, d})
+		}
+		if n.conf.Condition != nil {
+			cve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(merged, fv)}
+			switch r := cve.Eval(n.conf.Condition).(type) {
+			case error:
+				return sets, r
+			case bool:
+				if !r {
+					continue
+				}
+			case nil:
+				continue
+			default:
+				return sets, fmt.Errorf("invalid join condition that returns non-bool value %[1]T(%[1]v)", r)
+			}
+		}
+		row.Matched = true
+		other.Matched = true
+		sets.Content = append(sets.Content, merged)
+	}
+	n.st.Buffers[side][row.Key] = append(n.st.Buffers[side][row.Key], row)
+	n.advance(sets, t, upper)
+	return sets, nil
+}
+
+// advance updates the max time by the row time and evicts the expired rows
+func (n *IntervalJoinNode) advance(sets *xsql.JoinTuples, t int64, upper int64) {
+	if t > n.st.MaxTime {
+		n.st.MaxTime = t
+	}
+	// Only scan the buffers when the watermark advances or the row is already late
+	if watermark := n.st.MaxTime - n.lateTol; watermark > n.evicted || upper < watermark {
+		n.evict(sets, watermark)
+	}
+}
+
+// emitUnmatched returns if the unmatched rows of the side are emitted by the join type
+func (n *IntervalJoinNode) emitUnmatched(side int) bool {
+	jt := n.conf.JoinType
+	return (side == 0 && (jt == ast.LEFT_JOIN || jt == ast.FULL_JOIN)) || (side == 1 && (jt == ast.RIGHT_JOIN || jt == ast.FULL_JOIN))
+}
+
+// joinKey builds the buffer key of the join key values. The numbers of the same value have the same key
+// like the comparison of the join condition, while the values of other types are distinguished by type.
+func joinKey(keys []interface{}) string {
+	var b strings.Builder
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		switch v := k.(type) {
+		case string:
+			b.WriteString("s:")
+			b.WriteString(strconv.Quote(v))
+		case bool:
+			b.WriteString("b:")
+			b.WriteString(strconv.FormatBool(v))
+		case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+			i64, _ := cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+			b.WriteString("n:")
+			b.WriteString(strconv.FormatInt(i64, 10))
+		case float32, float64:
+			f, _ := cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+			b.WriteString("n:")
+			if f == math.Trunc(f) && math.Abs(f) < 1<<63 {
+				b.WriteString(strconv.FormatInt(int64(f), 10))
+			} else {
+				b.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+			}
+		default:
+			b.WriteString(fmt.Sprintf("%T:%v", v, v))
+		}
+	}
+	return b.String()
+}
+
+// evict removes the rows that cannot match any future row of the other side, whose time is not earlier than the watermark
+func (n *IntervalJoinNode) evict(sets *xsql.JoinTuples, watermark int64) {
+	n.evicted = watermark
+	for side, buffer := range n.st.Buffers {
+		emit := n.emitUnmatched(side)
+		for key, rows := range buffer {
+			kept := rows[:0]
+			for _, r := range rows {
+				// A left row can match the right rows until left time + upper, a right row can match the left rows until right time - lower
+				var end int64
+				if side == 0 {
+					end = r.Time + n.conf.Upper
+				} else {
+					end = r.Time - n.conf.Lower
+				}
+				if end >= watermark {
+					kept = append(kept, r)
+					continue
+				}
+				if emit && !r.Matched {
+					merged := &xsql.JoinTuple{}
+					merged.AddTuple(r.Tuple)
+					sets.Content = append(sets.Content, merged)
+				}
+			}
+			if len(kept) == 0 {
+				delete(buffer, key)
+			} else {
+				buffer[key] = kept
+			}
+		}
+	}
+}
+
+func (n *IntervalJoinNode) GetMetrics() [][]interface{} {
+	if n.statManager != nil {
+		return [][]interface{}{
+			n.statManager.GetMetrics(),
+		}
+	} else {
+		return nil
+	}
+}
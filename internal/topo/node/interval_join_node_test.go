@@ -0,0 +1,228 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"bytes"
+	"encoding/gob"
+	"reflect"
+	"testing"
+
+	mockContext "github.com/lf-edge/ekuiper/internal/io/mock/context"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestIntervalJoin(t *testing.T) {
+	// b.ts BETWEEN a.ts - 5 AND a.ts + 10
+	newConf := func(jt ast.JoinType) IntervalJoinConf {
+		return IntervalJoinConf{
+			JoinType:  jt,
+			Left:      []string{"a", ""},
+			Right:     []string{"b", ""},
+			LeftKeys:  []ast.Expr{&ast.FieldRef{StreamName: "a", Name: "id"}},
+			RightKeys: []ast.Expr{&ast.FieldRef{StreamName: "b", Name: "id"}},
+			LeftTime:  &ast.FieldRef{StreamName: "a", Name: "ts"},
+			RightTime: &ast.FieldRef{StreamName: "b", Name: "ts"},
+			Lower:     -5,
+			Upper:     10,
+			Condition: &ast.BinaryExpr{
+				OP:  ast.NEQ,
+				LHS: &ast.FieldRef{StreamName: "b", Name: "status"},
+				RHS: &ast.StringLiteral{Val: "ignored"},
+			},
+		}
+	}
+	inputs := []*xsql.Tuple{
+		{Emitter: "a", Message: xsql.Message{"id": 1, "ts": int64(100)}},
+		{Emitter: "b", Message: xsql.Message{"id": 1, "ts": int64(96), "status": "ok"}},
+		{Emitter: "b", Message: xsql.Message{"id": 2, "ts": int64(101), "status": "ok"}},
+		{Emitter: "b", Message: xsql.Message{"id": 1, "ts": int64(103), "status": "ignored"}},
+		{Emitter: "a", Message: xsql.Message{"id": 2, "ts": int64(105)}},
+		{Emitter: "a", Message: xsql.Message{"id": 3, "ts": int64(108)}},
+		// watermark 120 evicts the first left row and all right rows
+		{Emitter: "b", Message: xsql.Message{"id": 1, "ts": int64(120), "status": "ok"}},
+		// late row which is evicted at once
+		{Emitter: "a", Message: xsql.Message{"id": 4, "ts": int64(90)}},
+	}
+	pair := func(l, r int) []xsql.TupleRow {
+		var rows []xsql.TupleRow
+		if l >= 0 {
+			rows = append(rows, inputs[l])
+		}
+		if r >= 0 {
+			rows = append(rows, inputs[r])
+		}
+		return rows
+	}
+	tests := []struct {
+		joinType ast.JoinType
+		// the tuples emitted after each input
+		exp [][][]xsql.TupleRow
+	}{
+		{
+			joinType: ast.INNER_JOIN,
+			exp: [][][]xsql.TupleRow{
+				nil,
+				{pair(0, 1)},
+				nil,
+				nil,
+				{pair(4, 2)},
+				nil,
+				nil,
+				nil,
+			},
+		}, {
+			joinType: ast.LEFT_JOIN,
+			exp: [][][]xsql.TupleRow{
+				nil,
+				{pair(0, 1)},
+				nil,
+				nil,
+				{pair(4, 2)},
+				nil,
+				{pair(5, -1)},
+				{pair(7, -1)},
+			},
+		}, {
+			joinType: ast.FULL_JOIN,
+			exp: [][][]xsql.TupleRow{
+				nil,
+				{pair(0, 1)},
+				nil,
+				nil,
+				{pair(4, 2)},
+				nil,
+				{pair(5, -1), pair(-1, 3)},
+				{pair(7, -1)},
+			},
+		},
+	}
+	ctx := mockContext.NewMockContext("ruleIntervalJoin", "op1")
+	fv, _ := xsql.NewFunctionValuersForOp(ctx)
+	for i, tt := range tests {
+		n, err := NewIntervalJoinNode("test", newConf(tt.joinType), &api.RuleOption{BufferLength: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for j, input := range inputs {
+			sets, err := n.process(input, fv)
+			if err != nil {
+				t.Errorf("%d.%d: process error %v", i, j, err)
+				continue
+			}
+			var got [][]xsql.TupleRow
+			for _, jt := range sets.Content {
+				got = append(got, jt.Tuples)
+			}
+			if !reflect.DeepEqual(tt.exp[j], got) {
+				t.Errorf("%d.%d: result mismatch:\n  exp=%v\n  got=%v", i, j, tt.exp[j], got)
+			}
+		}
+	}
+	// The state is put once and updated in place
+	n, _ := NewIntervalJoinNode("test", newConf(ast.INNER_JOIN), &api.RuleOption{BufferLength: 10})
+	if err := n.restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range inputs[:2] {
+		_, _ = n.process(input, fv)
+	}
+	s, _ := ctx.GetState(INTERVAL_JOIN_STATE_KEY)
+	if s != n.st || len(n.st.Buffers[0])+len(n.st.Buffers[1]) == 0 {
+		t.Errorf("state is not updated in place: %v", s)
+	}
+	// The state can be serialized and restored
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		t.Fatal(err)
+	}
+	var decoded *IntervalJoinState
+	if err := gob.NewDecoder(&buf).Decode(&decoded); err != nil {
+		t.Fatal(err)
+	}
+	ctx = mockContext.NewMockContext("ruleIntervalJoin", "op2")
+	_ = ctx.PutState(INTERVAL_JOIN_STATE_KEY, decoded)
+	restored, _ := NewIntervalJoinNode("test", newConf(ast.INNER_JOIN), &api.RuleOption{BufferLength: 10})
+	if err := restored.restore(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.st.Buffers[0]) != len(n.st.Buffers[0]) || len(restored.st.Buffers[1]) != len(n.st.Buffers[1]) || n.st.MaxTime != restored.st.MaxTime {
+		t.Errorf("restore mismatch:\n  exp=%v\n  got=%v", n.st, restored.st)
+	}
+}
+
+func TestIntervalJoinKey(t *testing.T) {
+	conf := IntervalJoinConf{
+		JoinType:  ast.LEFT_JOIN,
+		Left:      []string{"a", ""},
+		Right:     []string{"b", ""},
+		LeftKeys:  []ast.Expr{&ast.FieldRef{StreamName: "a", Name: "id"}},
+		RightKeys: []ast.Expr{&ast.FieldRef{StreamName: "b", Name: "id"}},
+		LeftTime:  &ast.FieldRef{StreamName: "a", Name: "ts"},
+		RightTime: &ast.FieldRef{StreamName: "b", Name: "ts"},
+		Lower:     -5,
+		Upper:     10,
+	}
+	inputs := []*xsql.Tuple{
+		{Emitter: "b", Message: xsql.Message{"id": nil, "ts": int64(100)}},
+		{Emitter: "b", Message: xsql.Message{"id": "1", "ts": int64(100)}},
+		{Emitter: "b", Message: xsql.Message{"id": 2.0, "ts": int64(100)}},
+		// null key is not matched and emitted at once for left join
+		{Emitter: "a", Message: xsql.Message{"id": nil, "ts": int64(100)}},
+		// number does not match string
+		{Emitter: "a", Message: xsql.Message{"id": int64(1), "ts": int64(100)}},
+		// numbers of different types match
+		{Emitter: "a", Message: xsql.Message{"id": 2, "ts": int64(100)}},
+	}
+	exp := [][][]xsql.TupleRow{
+		nil,
+		nil,
+		nil,
+		{{inputs[3]}},
+		nil,
+		{{inputs[5], inputs[2]}},
+	}
+	ctx := mockContext.NewMockContext("ruleIntervalJoinKey", "op1")
+	fv, _ := xsql.NewFunctionValuersForOp(ctx)
+	n, err := NewIntervalJoinNode("test", conf, &api.RuleOption{BufferLength: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, input := range inputs {
+		sets, err := n.process(input, fv)
+		if err != nil {
+			t.Errorf("%d: process error %v", i, err)
+			continue
+		}
+		var got [][]xsql.TupleRow
+		for _, jt := range sets.Content {
+			got = append(got, jt.Tuples)
+		}
+		if !reflect.DeepEqual(exp[i], got) {
+			t.Errorf("%d: result mismatch:\n  exp=%v\n  got=%v", i, exp[i], got)
+		}
+	}
+	if _, ok := n.st.Buffers[1][""]; ok || len(n.st.Buffers[1]) != 2 {
+		t.Errorf("null key should not be buffered: %v", n.st.Buffers[1])
+	}
+	if k1, k2 := joinKey([]interface{}{int64(1), "a,b"}), joinKey([]interface{}{1.0, "a,b"}); k1 != k2 {
+		t.Errorf("number keys mismatch: %s, %s", k1, k2)
+	}
+	if k1, k2 := joinKey([]interface{}{"1"}), joinKey([]interface{}{1}); k1 == k2 {
+		t.Errorf("string and number keys should be different: %s", k1)
+	}
+}
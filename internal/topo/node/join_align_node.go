@@ -125,6 +125,7 @@ func (n *JoinAlignNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 							_, ok := n.batch[emitter]
 							if !ok {
 								e := fmt.Errorf("run JoinAlignNode error: receive batch input from unknown emitter %[1]T(%[1]v)", d)
+								n.sendDeadLetter(d, e)
 								n.Broadcast(e)
 								n.statManager.IncTotalExceptions(e.Error())
 								break
@@ -134,6 +135,7 @@ func (n *JoinAlignNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 						}
 					default:
 						e := fmt.Errorf("run JoinAlignNode error: invalid input type but got %[1]T(%[1]v)", d)
+						n.sendDeadLetter(d, e)
 						n.Broadcast(e)
 						n.statManager.IncTotalExceptions(e.Error())
 					}
@@ -137,6 +137,7 @@ func (n *LookupNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 						sets := &xsql.JoinTuples{Content: make([]*xsql.JoinTuple, 0)}
 						err := n.lookup(ctx, d, fv, ns, sets, c)
 						if err != nil {
+							n.sendDeadLetter(d, err)
 							n.Broadcast(err)
 							n.statManager.IncTotalExceptions(err.Error())
 						} else {
@@ -161,6 +162,7 @@ func (n *LookupNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 							return true, nil
 						})
 						if err != nil {
+							n.sendDeadLetter(d, err)
 							n.Broadcast(err)
 							n.statManager.IncTotalExceptions(err.Error())
 						} else {
@@ -171,6 +173,7 @@ func (n *LookupNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 						n.statManager.SetBufferLength(int64(len(n.input)))
 					default:
 						e := fmt.Errorf("run lookup node error: invalid input type but got %[1]T(%[1]v)", d)
+						n.sendDeadLetter(d, e)
 						n.Broadcast(e)
 						n.statManager.IncTotalExceptions(e.Error())
 					}
@@ -56,6 +56,7 @@ type defaultNode struct {
 	statManagers []metric.StatManager
 	ctx          api.StreamContext
 	qos          api.Qos
+	deadLetter   *DeadLetterNode
 }
 
 func (o *defaultNode) AddOutput(output chan<- interface{}, name string) error {
@@ -133,6 +134,20 @@ func (o *defaultNode) doBroadcast(val interface{}) {
 	}
 }
 
+// SetDeadLetter sets the dead letter of the rule to receive the failed events of this node
+func (o *defaultNode) SetDeadLetter(dl *DeadLetterNode) {
+	o.deadLetter = dl
+}
+
+// sendDeadLetter routes the failed event to the dead letter. Return false if the rule has no dead letter.
+func (o *defaultNode) sendDeadLetter(payload interface{}, err error) bool {
+	if o.deadLetter == nil {
+		return false
+	}
+	o.deadLetter.Send(o.name, payload, err)
+	return true
+}
+
 func (o *defaultNode) GetStreamContext() api.StreamContext {
 	return o.ctx
 }
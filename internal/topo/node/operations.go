@@ -17,7 +17,9 @@ package node
 import (
 	"fmt"
 	"sync"
+	"time"
 
+	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/topo/node/metric"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
@@ -29,6 +31,15 @@ type UnOperation interface {
 	Apply(ctx api.StreamContext, data interface{}, fv *xsql.FunctionValuer, afv *xsql.AggregateFunctionValuer) interface{}
 }
 
+// TimedOperation is the operation which also emits the rows by time, such as the delayed rows of debounce
+type TimedOperation interface {
+	UnOperation
+	// TickInterval is the interval in milliseconds to call OnTick
+	TickInterval() int64
+	// OnTick returns the rows due at the time in milliseconds, nil if none
+	OnTick(ctx api.StreamContext, now int64) interface{}
+}
+
 // UnFunc implements UnOperation as type func (context.Context, interface{})
 type UnFunc func(api.StreamContext, interface{}) interface{}
 
@@ -119,6 +130,14 @@ func (o *UnaryOperator) doOp(ctx api.StreamContext, errCh chan<- error) {
 	o.mutex.Unlock()
 	fv, afv := xsql.NewFunctionValuersForOp(exeCtx)
 
+	var tickCh <-chan time.Time
+	top, timed := o.op.(TimedOperation)
+	if timed {
+		ticker := conf.GetTicker(top.TickInterval())
+		defer ticker.Stop()
+		tickCh = ticker.C
+	}
+
 	for {
 		select {
 		// process incoming item
@@ -130,29 +149,11 @@ func (o *UnaryOperator) doOp(ctx api.StreamContext, errCh chan<- error) {
 			stats.IncTotalRecordsIn()
 			stats.ProcessTimeStart()
 			result := o.op.Apply(exeCtx, item, fv, afv)
-
-			switch val := result.(type) {
-			case nil:
-				continue
-			case error:
-				logger.Errorf("Operation %s error: %s", ctx.GetOpId(), val)
-				o.Broadcast(val)
-				stats.IncTotalExceptions(val.Error())
-				continue
-			case []xsql.TupleRow:
-				stats.ProcessTimeEnd()
-				for _, v := range val {
-					o.Broadcast(v)
-					stats.IncTotalRecordsOut()
-				}
-				stats.SetBufferLength(int64(len(o.input)))
-			default:
-				stats.ProcessTimeEnd()
-				o.Broadcast(val)
-				stats.SetOutData(fmt.Sprintf("%s", val))
-				stats.IncTotalRecordsOut()
-				stats.SetBufferLength(int64(len(o.input)))
-			}
+			o.emit(ctx, item, result, stats)
+		case now := <-tickCh:
+			stats.ProcessTimeStart()
+			result := top.OnTick(exeCtx, now.UnixMilli())
+			o.emit(ctx, nil, result, stats)
 		// is cancelling
 		case <-ctx.Done():
 			logger.Infof("unary operator %s instance %d cancelling....", o.name, ctx.GetInstanceId())
@@ -164,3 +165,31 @@ func (o *UnaryOperator) doOp(ctx api.StreamContext, errCh chan<- error) {
 		}
 	}
 }
+
+func (o *UnaryOperator) emit(ctx api.StreamContext, item interface{}, result interface{}, stats metric.StatManager) {
+	switch val := result.(type) {
+	case nil:
+		return
+	case error:
+		ctx.GetLogger().Errorf("Operation %s error: %s", ctx.GetOpId(), val)
+		// errors from upstream have been routed to the dead letter already
+		if _, ok := item.(error); !ok && item != nil {
+			o.sendDeadLetter(item, val)
+		}
+		o.Broadcast(val)
+		stats.IncTotalExceptions(val.Error())
+	case []xsql.TupleRow:
+		stats.ProcessTimeEnd()
+		for _, v := range val {
+			o.Broadcast(v)
+			stats.IncTotalRecordsOut()
+		}
+		stats.SetBufferLength(int64(len(o.input)))
+	default:
+		stats.ProcessTimeEnd()
+		o.Broadcast(val)
+		stats.SetOutData(fmt.Sprintf("%s", val))
+		stats.IncTotalRecordsOut()
+		stats.SetBufferLength(int64(len(o.input)))
+	}
+}
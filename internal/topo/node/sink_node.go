@@ -29,6 +29,7 @@ import (
 	"github.com/lf-edge/ekuiper/internal/topo/transform"
 	"github.com/lf-edge/ekuiper/internal/xsql"
 	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
 	"github.com/lf-edge/ekuiper/pkg/cast"
 	"github.com/lf-edge/ekuiper/pkg/errorx"
 	"github.com/lf-edge/ekuiper/pkg/infra"
@@ -67,6 +68,8 @@ type SinkNode struct {
 	// configs (also static for sinks)
 	options map[string]interface{}
 	isMock  bool
+	// the types of the rule output fields, nil if unknown
+	schema map[string]ast.DataType
 	// states varies after restart
 	sinks []api.Sink
 }
@@ -94,6 +97,11 @@ func NewSinkNode(name string, sinkType string, props map[string]interface{}) *Si
 	}
 }
 
+// SetOutputSchema sets the types of the rule output fields which the sinks can get from the context
+func (m *SinkNode) SetOutputSchema(schema map[string]ast.DataType) {
+	m.schema = schema
+}
+
 // NewSinkNodeWithSink Only for mock source, do not use it in production
 func NewSinkNodeWithSink(name string, sink api.Sink, props map[string]interface{}) *SinkNode {
 	return &SinkNode{
@@ -129,6 +137,9 @@ func (m *SinkNode) Open(ctx api.StreamContext, result chan<- error) {
 				return fmt.Errorf(msg)
 			}
 			ctx = context.WithValue(ctx.(*context.DefaultContext), context.TransKey, tf)
+			if m.schema != nil {
+				ctx = context.WithValue(ctx.(*context.DefaultContext), context.OutputSchemaKey, m.schema)
+			}
 
 			m.reset()
 			logger.Infof("open sink node %d instances", m.concurrency)
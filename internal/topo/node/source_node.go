@@ -40,6 +40,8 @@ type SourceNode struct {
 	mutex        sync.RWMutex
 	sources      []api.Source
 	preprocessOp UnOperation
+	// offsets to be saved by the pending checkpoints, keyed by the checkpoint id
+	offsets map[int64]interface{}
 }
 
 func NewSourceNode(name string, st ast.StreamType, op UnOperation, options *ast.Options, props map[string]interface{}, sendError bool) *SourceNode {
@@ -147,6 +149,9 @@ func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 								if t, ok := data.(*xsql.ErrorSourceTuple); ok {
 									logger.Errorf("Source %s error: %v", ctx.GetOpId(), t.Error)
 									stats.IncTotalExceptions(t.Error.Error())
+									if t.Payload != nil {
+										m.sendDeadLetter(t.Payload, t.Error)
+									}
 									continue
 								}
 								stats.IncTotalRecordsIn()
@@ -166,15 +171,25 @@ func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 								// blocking
 								switch val := processedData.(type) {
 								case nil:
+									ackTuple(data)
+									continue
+								case *xsql.DeadLetterError:
+									stats.IncTotalExceptions(val.Error())
+									if !m.sendDeadLetter(tuple.Message, val.Err) {
+										logger.Warnf("Source %s drops the event to dead letter: %s", ctx.GetOpId(), val)
+									}
+									ackTuple(data)
 									continue
 								case error:
 									logger.Errorf("Source %s preprocess error: %s", ctx.GetOpId(), val)
+									m.sendDeadLetter(tuple.Message, val)
 									m.Broadcast(val)
 									stats.IncTotalExceptions(val.Error())
 								default: // table
 									m.Broadcast(val)
 									stats.SetOutData(fmt.Sprintf("%s", val))
 								}
+								ackTuple(data)
 								stats.IncTotalRecordsOut()
 								stats.SetBufferLength(int64(buffer.GetLength()))
 								if rw, ok := si.source.(api.Rewindable); ok {
@@ -204,6 +219,56 @@ func (m *SourceNode) Open(ctx api.StreamContext, errCh chan<- error) {
 	}()
 }
 
+// ackTuple notifies the source that the tuple is processed so that its offset can be saved
+func ackTuple(data api.SourceTuple) {
+	if a, ok := data.(api.AckableTuple); ok {
+		a.Ack()
+	}
+}
+
+// PrepareCheckpoint records the offset to be saved by the checkpoint. It is read before the state snapshot,
+// so it never goes beyond the saved offset.
+func (m *SourceNode) PrepareCheckpoint(checkpointId int64) {
+	// shared source instances serve several rules and are never rewound
+	if m.options.SHARED || m.ctx == nil {
+		return
+	}
+	offset, err := m.ctx.GetState(OffsetKey)
+	if err != nil || offset == nil {
+		return
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.offsets == nil {
+		m.offsets = make(map[int64]interface{})
+	}
+	m.offsets[checkpointId] = offset
+}
+
+// CompleteCheckpoint commits the offset saved by the checkpoint to the sources
+func (m *SourceNode) CompleteCheckpoint(checkpointId int64) {
+	m.mutex.Lock()
+	offset, ok := m.offsets[checkpointId]
+	for id := range m.offsets {
+		if id <= checkpointId {
+			delete(m.offsets, id)
+		}
+	}
+	sources := make([]api.Source, len(m.sources))
+	copy(sources, m.sources)
+	m.mutex.Unlock()
+	if !ok {
+		return
+	}
+	for _, s := range sources {
+		if c, ok := s.(api.Committable); ok {
+			if err := c.Commit(offset); err != nil {
+				m.ctx.GetLogger().Warnf("Source %s fails to commit offset %v: %v", m.name, offset, err)
+			}
+		}
+	}
+}
+
 func (m *SourceNode) reset() {
 	m.statManagers = nil
 }
@@ -18,7 +18,11 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
 	nodeConf "github.com/lf-edge/ekuiper/internal/topo/node/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/ast"
 	"github.com/lf-edge/ekuiper/pkg/cast"
 )
@@ -115,3 +119,50 @@ type httpPullSourceConfig struct {
 	InsecureSkipVerify bool                   `json:"insecureSkipVerify"`
 	Headers            map[string]interface{} `json:"headers"`
 }
+
+type committableSource struct {
+	api.Source
+	committed []interface{}
+}
+
+func (s *committableSource) Commit(offset interface{}) error {
+	s.committed = append(s.committed, offset)
+	return nil
+}
+
+func TestSourceNodeCommit(t *testing.T) {
+	n := NewSourceNode("test", ast.TypeStream, nil, &ast.Options{
+		DATASOURCE: "demo",
+		TYPE:       "mock",
+	}, make(map[string]interface{}), false)
+	contextLogger := conf.Log.WithField("rule", "commitRule")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	tempStore, _ := state.CreateStore("commitRule", api.AtLeastOnce)
+	n.ctx = ctx.WithMeta("commitRule", "test", tempStore)
+	s := &committableSource{}
+	n.sources = []api.Source{s}
+
+	// no offset saved yet
+	n.PrepareCheckpoint(1)
+	_ = n.ctx.PutState(OffsetKey, int64(10))
+	n.PrepareCheckpoint(2)
+	_ = n.ctx.PutState(OffsetKey, int64(20))
+	n.PrepareCheckpoint(3)
+	_ = n.ctx.PutState(OffsetKey, int64(30))
+	n.PrepareCheckpoint(4)
+	// nothing is committed before the checkpoint completes
+	if len(s.committed) != 0 {
+		t.Fatalf("committed before the checkpoint completes: %v", s.committed)
+	}
+	n.CompleteCheckpoint(1)
+	n.CompleteCheckpoint(3)
+	// the previous pending checkpoints are dropped
+	n.CompleteCheckpoint(2)
+	exp := []interface{}{int64(20)}
+	if !reflect.DeepEqual(exp, s.committed) {
+		t.Errorf("committed offsets mismatch, expect %v but got %v", exp, s.committed)
+	}
+	if len(n.offsets) != 1 {
+		t.Errorf("pending offsets are not cleaned: %v", n.offsets)
+	}
+}
@@ -121,6 +121,7 @@ func (n *SwitchNode) Exec(ctx api.StreamContext, errCh chan<- error) {
 						ve = &xsql.ValuerEval{Valuer: xsql.MultiAggregateValuer(d, fv, d, fv, afv, &xsql.WildcardValuer{Data: d})}
 					default:
 						e := fmt.Errorf("run switch node error: invalid input type but got %[1]T(%[1]v)", d)
+						n.sendDeadLetter(d, e)
 						n.Broadcast(e)
 						n.statManager.IncTotalExceptions(e.Error())
 						break
@@ -268,6 +268,7 @@ func (o *WindowOperator) execEventWindow(ctx api.StreamContext, inputs []*xsql.T
 			default:
 				o.statManager.IncTotalRecordsIn()
 				e := fmt.Errorf("run Window error: expect xsql.Event type but got %[1]T(%[1]v)", d)
+				o.sendDeadLetter(d, e)
 				o.Broadcast(e)
 				o.statManager.IncTotalExceptions(e.Error())
 			}
@@ -0,0 +1,484 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+const WINDOW_PANES_KEY = "$$windowPanes"
+
+func init() {
+	gob.Register([]*AggPane{})
+}
+
+// The kind of the first valid value which decides the result type like the aggregate functions do
+const (
+	kindNone = iota
+	kindInt
+	kindFloat
+	kindString
+	kindInvalid
+)
+
+// AggAccumulator is the partial result of an aggregate function over some rows. Partial results can be merged in
+// the order of the rows. The int, float and string results are all accumulated because the result type is decided
+// by the first valid value of the whole window.
+type AggAccumulator struct {
+	// Rows is the count of all rows, Count is the count of the valid values
+	Rows    int64
+	Count   int64
+	Kind    int
+	Invalid string
+
+	IntVal   int64
+	IntSet   bool
+	IntErr   string
+	FloatVal float64
+	FloatSet bool
+	FloatErr string
+	StrVal   string
+	StrSet   bool
+	StrErr   string
+
+	// Mean and M2 are the running mean and the sum of squares of differences for stddev and var
+	Mean   float64
+	M2     float64
+	NumErr string
+}
+
+func (a *AggAccumulator) add(name string, v interface{}) {
+	a.Rows++
+	switch name {
+	case "count":
+		if v != nil {
+			a.Count++
+		}
+		return
+	case "stddev", "stddevs", "var", "vars":
+		f, err := cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+		if err != nil {
+			if a.NumErr == "" {
+				a.NumErr = fmt.Sprintf("requires float64 slice but found %[1]T(%[1]v)", v)
+			}
+			return
+		}
+		a.Count++
+		d := f - a.Mean
+		a.Mean += d / float64(a.Count)
+		a.M2 += d * (f - a.Mean)
+		return
+	}
+	if v == nil {
+		return
+	}
+	a.Count++
+	if a.Kind == kindNone {
+		switch v.(type) {
+		case int, int64:
+			a.Kind = kindInt
+		case float64:
+			a.Kind = kindFloat
+		case string:
+			if name == "max" || name == "min" {
+				a.Kind = kindString
+			} else {
+				a.Kind = kindInvalid
+			}
+		default:
+			a.Kind = kindInvalid
+		}
+		if a.Kind == kindInvalid {
+			a.Invalid = fmt.Sprintf("%[1]T(%[1]v)", v)
+		}
+	}
+	if vi, err := cast.ToInt64(v, cast.CONVERT_SAMEKIND); err == nil {
+		a.mergeInt(name, vi)
+	} else if a.IntErr == "" {
+		a.IntErr = fmt.Sprintf("requires int but found %[1]T(%[1]v)", v)
+	}
+	if vf, ok := v.(float64); ok {
+		a.mergeFloat(name, vf)
+	} else if a.FloatErr == "" {
+		a.FloatErr = fmt.Sprintf("requires float64 but found %[1]T(%[1]v)", v)
+	}
+	if vs, ok := v.(string); ok {
+		a.mergeString(name, vs)
+	} else if a.StrErr == "" {
+		a.StrErr = fmt.Sprintf("requires string but found %[1]T(%[1]v)", v)
+	}
+}
+
+func (a *AggAccumulator) mergeInt(name string, v int64) {
+	switch {
+	case !a.IntSet:
+		a.IntVal = v
+	case name == "max":
+		if v > a.IntVal {
+			a.IntVal = v
+		}
+	case name == "min":
+		if v < a.IntVal {
+			a.IntVal = v
+		}
+	default:
+		a.IntVal += v
+	}
+	a.IntSet = true
+}
+
+func (a *AggAccumulator) mergeFloat(name string, v float64) {
+	switch {
+	case !a.FloatSet:
+		a.FloatVal = v
+	case name == "max":
+		if v > a.FloatVal {
+			a.FloatVal = v
+		}
+	case name == "min":
+		if v < a.FloatVal {
+			a.FloatVal = v
+		}
+	default:
+		a.FloatVal += v
+	}
+	a.FloatSet = true
+}
+
+func (a *AggAccumulator) mergeString(name string, v string) {
+	switch {
+	case !a.StrSet:
+		a.StrVal = v
+	case name == "max":
+		if v > a.StrVal {
+			a.StrVal = v
+		}
+	case name == "min":
+		if v < a.StrVal {
+			a.StrVal = v
+		}
+	}
+	a.StrSet = true
+}
+
+// merge the partial result of the later rows into this one
+func (a *AggAccumulator) merge(name string, o *AggAccumulator) {
+	switch name {
+	case "stddev", "stddevs", "var", "vars":
+		if o.Count > 0 {
+			n := a.Count + o.Count
+			d := o.Mean - a.Mean
+			a.M2 += o.M2 + d*d*float64(a.Count)*float64(o.Count)/float64(n)
+			a.Mean += d * float64(o.Count) / float64(n)
+		}
+	}
+	a.Rows += o.Rows
+	a.Count += o.Count
+	if a.Kind == kindNone {
+		a.Kind, a.Invalid = o.Kind, o.Invalid
+	}
+	if o.IntSet {
+		a.mergeInt(name, o.IntVal)
+	}
+	if o.FloatSet {
+		a.mergeFloat(name, o.FloatVal)
+	}
+	if o.StrSet {
+		a.mergeString(name, o.StrVal)
+	}
+	for _, p := range [][2]*string{{&a.IntErr, &o.IntErr}, {&a.FloatErr, &o.FloatErr}, {&a.StrErr, &o.StrErr}, {&a.NumErr, &o.NumErr}} {
+		if *p[0] == "" {
+			*p[0] = *p[1]
+		}
+	}
+}
+
+// result returns the same value as the aggregate function runs on all the rows
+func (a *AggAccumulator) result(name string) interface{} {
+	if a.Rows == 0 {
+		return nil
+	}
+	switch name {
+	case "count":
+		return int(a.Count)
+	case "stddev", "stddevs", "var", "vars":
+		if a.NumErr != "" {
+			return fmt.Errorf(a.NumErr)
+		}
+		n := float64(a.Count)
+		if name == "stddevs" || name == "vars" {
+			n--
+		}
+		r := a.M2 / n
+		if name == "stddev" || name == "stddevs" {
+			r = math.Sqrt(r)
+		}
+		return r
+	}
+	switch a.Kind {
+	case kindNone:
+		return nil
+	case kindInt:
+		if a.IntErr != "" {
+			return fmt.Errorf(a.IntErr)
+		}
+		if name == "avg" {
+			return a.IntVal / a.Count
+		}
+		return a.IntVal
+	case kindFloat:
+		if a.FloatErr != "" {
+			return fmt.Errorf(a.FloatErr)
+		}
+		if name == "avg" {
+			return a.FloatVal / float64(a.Count)
+		}
+		return a.FloatVal
+	case kindString:
+		if a.StrErr != "" {
+			return fmt.Errorf(a.StrErr)
+		}
+		return a.StrVal
+	default:
+		return fmt.Errorf("run %s function error: found invalid arg %s", name, a.Invalid)
+	}
+}
+
+// AggPane is the accumulators by group of the rows in a time slice of the window
+type AggPane struct {
+	End int64
+	// Keys are the group keys in the order of arrival
+	Keys   []string
+	Groups map[string]*AggGroup
+}
+
+type AggGroup struct {
+	// First is the first row of the group to evaluate the non-aggregate fields
+	First *xsql.Tuple
+	Accs  []*AggAccumulator
+}
+
+// incAggregator aggregates the rows into panes when they arrive instead of keeping them. A window is the merge of the
+// panes in its range so that the overlapped hopping or sliding windows share the panes.
+type incAggregator struct {
+	dimensions ast.Dimensions
+	funcs      []*ast.Call
+	fv         *xsql.FunctionValuer
+	// panes are sorted by the end time
+	panes []*AggPane
+}
+
+func newIncAggregator(dimensions ast.Dimensions, funcs []*ast.Call) *incAggregator {
+	return &incAggregator{
+		dimensions: dimensions,
+		funcs:      funcs,
+	}
+}
+
+func (a *incAggregator) add(tuple *xsql.Tuple, end int64) error {
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(tuple, a.fv, &xsql.WildcardValuer{Data: tuple})}
+	var key string
+	for _, d := range a.dimensions {
+		r := ve.Eval(d.Expr)
+		if _, ok := r.(error); ok {
+			return fmt.Errorf("run Group By error: %s", r)
+		}
+		key += fmt.Sprintf("%v,", r)
+	}
+	values := make([]interface{}, len(a.funcs))
+	for i, f := range a.funcs {
+		// count(*) counts all rows
+		if _, ok := f.Args[0].(*ast.Wildcard); ok {
+			values[i] = true
+			continue
+		}
+		values[i] = ve.Eval(f.Args[0])
+		if err, ok := values[i].(error); ok {
+			return fmt.Errorf("call %s error: %v", f.Name, err)
+		}
+	}
+	p := a.pane(end)
+	g, ok := p.Groups[key]
+	if !ok {
+		g = &AggGroup{First: tuple, Accs: make([]*AggAccumulator, len(a.funcs))}
+		for i := range g.Accs {
+			g.Accs[i] = &AggAccumulator{}
+		}
+		p.Groups[key] = g
+		p.Keys = append(p.Keys, key)
+	}
+	for i, f := range a.funcs {
+		g.Accs[i].add(f.Name, values[i])
+	}
+	return nil
+}
+
+// pane finds or creates the pane with the end time. The rows arrive in order mostly, so search from the last.
+func (a *incAggregator) pane(end int64) *AggPane {
+	i := len(a.panes)
+	for i > 0 && a.panes[i-1].End >= end {
+		if a.panes[i-1].End == end {
+			return a.panes[i-1]
+		}
+		i--
+	}
+	p := &AggPane{End: end, Groups: make(map[string]*AggGroup)}
+	a.panes = append(a.panes, nil)
+	copy(a.panes[i+1:], a.panes[i:])
+	a.panes[i] = p
+	return p
+}
+
+// collect merges the panes whose end time is in [start, end] into the collection which is the same as
+// the result of the aggregate operator. The aggregate results are set as the cached fields of the calls.
+func (a *incAggregator) collect(start, end int64, wr *xsql.WindowRange) interface{} {
+	var (
+		keys   []string
+		groups = make(map[string]*AggGroup)
+	)
+	for _, p := range a.panes {
+		if p.End < start || p.End > end {
+			continue
+		}
+		for _, k := range p.Keys {
+			pg := p.Groups[k]
+			g, ok := groups[k]
+			if !ok {
+				g = &AggGroup{First: pg.First, Accs: make([]*AggAccumulator, len(a.funcs))}
+				for i, acc := range pg.Accs {
+					c := *acc
+					g.Accs[i] = &c
+				}
+				groups[k] = g
+				keys = append(keys, k)
+				continue
+			}
+			for i, f := range a.funcs {
+				g.Accs[i].merge(f.Name, pg.Accs[i])
+			}
+		}
+	}
+	if len(a.dimensions) == 0 {
+		result := &xsql.WindowTuples{Content: make([]xsql.TupleRow, 0), WindowRange: wr}
+		g, ok := groups[""]
+		if !ok {
+			g = &AggGroup{Accs: make([]*AggAccumulator, len(a.funcs))}
+			for i := range g.Accs {
+				g.Accs[i] = &AggAccumulator{}
+			}
+		} else {
+			result.AddTuple(g.First)
+		}
+		for i, f := range a.funcs {
+			result.Set(f.CachedField, g.Accs[i].result(f.Name))
+		}
+		return result
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	gs := make([]*xsql.GroupedTuples, 0, len(keys))
+	for _, k := range keys {
+		g := groups[k]
+		gt := &xsql.GroupedTuples{Content: []xsql.TupleRow{g.First}, WindowRange: wr}
+		for i, f := range a.funcs {
+			gt.Set(f.CachedField, g.Accs[i].result(f.Name))
+		}
+		gs = append(gs, gt)
+	}
+	return &xsql.GroupedTuplesSet{Groups: gs}
+}
+
+// evict removes the panes whose end time is before the time
+func (a *incAggregator) evict(before int64) {
+	i := 0
+	for i < len(a.panes) && a.panes[i].End < before {
+		i++
+	}
+	a.panes = a.panes[i:]
+}
+
+// accumulate adds the tuple into its pane. For sliding window, the tuples of the same millisecond share a pane and
+// each tuple triggers the window which ends at its timestamp.
+func (o *WindowOperator) accumulate(ctx api.StreamContext, d *xsql.Tuple) {
+	var end int64
+	switch o.window.Type {
+	case ast.SLIDING_WINDOW:
+		end = d.Timestamp
+	default:
+		end = o.paneEnd(d.Timestamp)
+	}
+	if err := o.incAgg.add(d, end); err != nil {
+		o.sendDeadLetter(d, err)
+		o.Broadcast(err)
+		o.statManager.IncTotalExceptions(err.Error())
+		return
+	}
+	if o.window.Type == ast.SLIDING_WINDOW {
+		o.scanPanes(d.Timestamp, ctx)
+	} else {
+		_ = ctx.PutState(WINDOW_PANES_KEY, o.incAgg.panes)
+	}
+}
+
+// paneEnd returns the end time of the pane which the time belongs to. The panes which have been triggered
+// are closed, so the late rows go to the next pane.
+func (o *WindowOperator) paneEnd(ts int64) int64 {
+	if ts <= o.triggerTime {
+		ts = o.triggerTime + 1
+	}
+	n := (ts - o.paneAnchor) / o.paneSize
+	if (ts-o.paneAnchor)%o.paneSize > 0 {
+		n++
+	}
+	return o.paneAnchor + n*o.paneSize
+}
+
+// scanPanes emits the merged result of the panes in the window and evicts the panes which won't be in the next window
+func (o *WindowOperator) scanPanes(triggerTime int64, ctx api.StreamContext) {
+	var start, before int64
+	switch o.window.Type {
+	case ast.SLIDING_WINDOW:
+		// The sliding window includes the tuples at the start like the row based scan
+		start = triggerTime - o.window.Length
+		before = start
+	default:
+		start = triggerTime - o.window.Length + 1
+		before = start + o.interval
+	}
+	results := o.incAgg.collect(start, triggerTime, xsql.NewWindowRange(o.getWindowStart(triggerTime), triggerTime))
+	o.incAgg.evict(before)
+	if results != nil {
+		ctx.GetLogger().Debugf("Sent: %v", results)
+		o.Broadcast(results)
+		o.statManager.IncTotalRecordsOut()
+	}
+	o.triggerTime = triggerTime
+	_ = ctx.PutState(WINDOW_PANES_KEY, o.incAgg.panes)
+}
+
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
@@ -0,0 +1,143 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package node
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestAggAccumulator(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []interface{}
+		// split the values into two accumulators at the index and merge them
+		split int
+		exp   interface{}
+	}{
+		{name: "count", values: []interface{}{1, nil, 3}, split: 1, exp: 2},
+		{name: "count", values: []interface{}{}, exp: nil},
+		{name: "sum", values: []interface{}{1, int64(2), nil, 3}, split: 2, exp: int64(6)},
+		{name: "sum", values: []interface{}{1.5, 2.5, nil}, split: 1, exp: 4.0},
+		{name: "sum", values: []interface{}{1, 2.5}, split: 1, exp: int64(3)},
+		{name: "sum", values: []interface{}{1.5, 2}, split: 1, exp: errors.New("requires float64 but found int(2)")},
+		{name: "sum", values: []interface{}{nil, nil}, split: 1, exp: nil},
+		{name: "sum", values: []interface{}{"a"}, exp: errors.New("run sum function error: found invalid arg string(a)")},
+		{name: "avg", values: []interface{}{1, 2, 4}, split: 2, exp: int64(2)},
+		{name: "avg", values: []interface{}{1.0, 2.0, nil, 4.0}, split: 1, exp: 7.0 / 3},
+		{name: "max", values: []interface{}{3, 7, 5}, split: 2, exp: int64(7)},
+		{name: "max", values: []interface{}{"b", "c", "a"}, split: 1, exp: "c"},
+		{name: "min", values: []interface{}{3.5, 7.0, 1.5}, split: 2, exp: 1.5},
+		{name: "min", values: []interface{}{nil, 4, 2}, split: 1, exp: int64(2)},
+		{name: "var", values: []interface{}{1, 2, 3, 4}, split: 1, exp: 1.25},
+		{name: "vars", values: []interface{}{1, 2, 3, 4}, split: 3, exp: 5.0 / 3},
+		{name: "stddev", values: []interface{}{2, 4, 4, 4, 5, 5, 7, 9}, split: 5, exp: 2.0},
+		{name: "stddevs", values: []interface{}{1.0, 3.0}, split: 1, exp: math.Sqrt(2)},
+		{name: "stddev", values: []interface{}{1, nil}, split: 1, exp: errors.New("requires float64 slice but found <nil>(<nil>)")},
+	}
+	for i, tt := range tests {
+		a, b := &AggAccumulator{}, &AggAccumulator{}
+		for j, v := range tt.values {
+			if j < tt.split {
+				a.add(tt.name, v)
+			} else {
+				b.add(tt.name, v)
+			}
+		}
+		a.merge(tt.name, b)
+		r := a.result(tt.name)
+		if f, ok := r.(float64); ok {
+			if e, ok := tt.exp.(float64); ok && math.Abs(f-e) < 1e-9 {
+				continue
+			}
+		}
+		if !reflect.DeepEqual(tt.exp, r) {
+			t.Errorf("%d.%s: result mismatch:\n  exp=%#v\n  got=%#v", i, tt.name, tt.exp, r)
+		}
+	}
+}
+
+func TestIncAggregator(t *testing.T) {
+	funcs := []*ast.Call{
+		{Name: "count", FuncType: ast.FuncTypeAgg, Args: []ast.Expr{&ast.Wildcard{Token: ast.ASTERISK}}, CachedField: "$$inc_count_0", Cached: true},
+		{Name: "sum", FuncType: ast.FuncTypeAgg, Args: []ast.Expr{&ast.FieldRef{StreamName: "demo", Name: "size"}}, CachedField: "$$inc_sum_1", Cached: true},
+	}
+	dims := ast.Dimensions{{Expr: &ast.FieldRef{StreamName: "demo", Name: "color"}}}
+	tuples := []*xsql.Tuple{
+		{Emitter: "demo", Message: xsql.Message{"color": "red", "size": 3}, Timestamp: 100},
+		{Emitter: "demo", Message: xsql.Message{"color": "blue", "size": 6}, Timestamp: 150},
+		{Emitter: "demo", Message: xsql.Message{"color": "red", "size": 2}, Timestamp: 250},
+		{Emitter: "demo", Message: xsql.Message{"color": "red", "size": 1}, Timestamp: 350},
+	}
+	a := newIncAggregator(dims, funcs)
+	a.fv, _ = xsql.NewFunctionValuersForOp(nil)
+	for _, tuple := range tuples {
+		// panes of 100ms
+		end := (tuple.Timestamp/100 + 1) * 100
+		if err := a.add(tuple, end); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(a.panes) != 3 {
+		t.Fatalf("expect 3 panes but got %d", len(a.panes))
+	}
+	type group struct {
+		first *xsql.Tuple
+		count interface{}
+		sum   interface{}
+	}
+	getGroups := func(r interface{}) []group {
+		var result []group
+		gs, ok := r.(*xsql.GroupedTuplesSet)
+		if !ok {
+			return nil
+		}
+		for _, g := range gs.Groups {
+			c, _ := g.Value("$$inc_count_0", "")
+			s, _ := g.Value("$$inc_sum_1", "")
+			result = append(result, group{first: g.Content[0].(*xsql.Tuple), count: c, sum: s})
+		}
+		return result
+	}
+	wr := xsql.NewWindowRange(0, 300)
+	r := getGroups(a.collect(1, 300, wr))
+	exp := []group{{first: tuples[0], count: 2, sum: int64(5)}, {first: tuples[1], count: 1, sum: int64(6)}}
+	if !reflect.DeepEqual(exp, r) {
+		t.Errorf("result mismatch:\n  exp=%v\n  got=%v", exp, r)
+	}
+	a.evict(201)
+	r = getGroups(a.collect(201, 400, wr))
+	exp = []group{{first: tuples[2], count: 2, sum: int64(3)}}
+	if !reflect.DeepEqual(exp, r) {
+		t.Errorf("result after evict mismatch:\n  exp=%v\n  got=%v", exp, r)
+	}
+	if r := a.collect(500, 600, wr); r != nil {
+		t.Errorf("expect no result for empty window but got %v", r)
+	}
+	// Without group by, an empty window is still emitted
+	a = newIncAggregator(nil, funcs)
+	wt, ok := a.collect(0, 100, wr).(*xsql.WindowTuples)
+	if !ok || len(wt.Content) != 0 {
+		t.Fatalf("expect empty window tuples but got %v", wt)
+	}
+	if v, ok := wt.Value("$$inc_count_0", ""); !ok || v != nil {
+		t.Errorf("expect nil count but got %v", v)
+	}
+}
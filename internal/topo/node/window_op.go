@@ -37,6 +37,11 @@ type WindowConfig struct {
 	Interval    int64 // If the interval is not set, it is equals to Length
 	RawInterval int
 	TimeUnit    ast.Token
+	// Incremental is set by the planner when all the aggregate functions are decomposable. Then the window
+	// groups by the dimensions and keeps the accumulators of the aggregate functions instead of the tuples.
+	Incremental bool
+	Dimensions  ast.Dimensions
+	AggFuncs    []*ast.Call
 }
 
 type WindowOperator struct {
@@ -45,9 +50,13 @@ type WindowOperator struct {
 	interval           int64
 	isEventTime        bool
 	watermarkGenerator *WatermarkGenerator // For event time only
+	incAgg             *incAggregator      // For incremental aggregation only
 
 	statManager metric.StatManager
 	ticker      *clock.Ticker // For processing time only
+	// The pane size and the end time of a pane for incremental hopping and tumbling window
+	paneSize   int64
+	paneAnchor int64
 	// states
 	triggerTime int64
 	msgCount    int
@@ -81,6 +90,9 @@ func NewWindowOp(name string, w WindowConfig, streams []string, options *api.Rul
 		// if no interval value is set and it's count window, then set interval to length value.
 		o.window.Interval = o.window.Length
 	}
+	if w.Incremental {
+		o.incAgg = newIncAggregator(w.Dimensions, w.AggFuncs)
+	}
 	if options.IsEventTime {
 		// Create watermark generator
 		if w, err := NewWatermarkGenerator(o.window, options.LateTol, streams, o.input); err != nil {
@@ -125,6 +137,22 @@ func (o *WindowOperator) Exec(ctx api.StreamContext, errCh chan<- error) {
 	} else {
 		log.Warnf("Restore window state fails: %s", err)
 	}
+	if o.incAgg != nil {
+		if s, err := ctx.GetState(WINDOW_PANES_KEY); err == nil {
+			switch st := s.(type) {
+			case []*AggPane:
+				o.incAgg.panes = st
+				log.Infof("Restore window state with %d panes", len(st))
+			case nil:
+				log.Debugf("Restore window panes, nothing")
+			default:
+				infra.DrainError(ctx, fmt.Errorf("restore window state `panes` %v error, invalid type", st), errCh)
+				return
+			}
+		} else {
+			log.Warnf("Restore window panes fails: %s", err)
+		}
+	}
 	if !o.isEventTime {
 		o.triggerTime = conf.GetNowInMilli()
 	}
@@ -237,11 +265,18 @@ func (o *WindowOperator) execProcessingWindow(ctx api.StreamContext, inputs []*x
 	case ast.COUNT_WINDOW:
 		o.interval = o.window.Interval
 	}
+	if o.incAgg != nil {
+		o.incAgg.fv, _ = xsql.NewFunctionValuersForOp(ctx)
+		if firstTicker != nil {
+			o.paneSize = gcd(o.window.Length, o.interval)
+			o.paneAnchor = firstTime
+		}
+	}
 
 	if firstTicker != nil {
 		firstC = firstTicker.C
 		// resume previous window
-		if len(inputs) > 0 && o.triggerTime > 0 {
+		if (len(inputs) > 0 || (o.incAgg != nil && len(o.incAgg.panes) > 0)) && o.triggerTime > 0 {
 			nextTick := conf.GetNowInMilli() + o.interval
 			next := o.triggerTime
 			switch o.window.Type {
@@ -316,6 +351,12 @@ func (o *WindowOperator) execProcessingWindow(ctx api.StreamContext, inputs []*x
 				o.statManager.IncTotalExceptions(d.Error())
 			case *xsql.Tuple:
 				log.Debugf("Event window receive tuple %s", d.Message)
+				if o.incAgg != nil {
+					o.accumulate(ctx, d)
+					o.statManager.ProcessTimeEnd()
+					o.statManager.SetBufferLength(int64(len(o.input)))
+					break
+				}
 				inputs = append(inputs, d)
 				switch o.window.Type {
 				case ast.NOT_WINDOW:
@@ -365,8 +406,22 @@ func (o *WindowOperator) execProcessingWindow(ctx api.StreamContext, inputs []*x
 				o.statManager.SetBufferLength(int64(len(o.input)))
 				ctx.PutState(WINDOW_INPUTS_KEY, inputs)
 				ctx.PutState(MSG_COUNT_KEY, o.msgCount)
+			case *xsql.ColumnBatch:
+				// Columnar batches are only planned for tumbling and hopping window which only buffer the tuples
+				log.Debugf("Event window receive batch of %d rows", d.Len())
+				for _, t := range d.Selected() {
+					if o.incAgg != nil {
+						o.accumulate(ctx, t)
+					} else {
+						inputs = append(inputs, t)
+					}
+				}
+				o.statManager.ProcessTimeEnd()
+				o.statManager.SetBufferLength(int64(len(o.input)))
+				ctx.PutState(WINDOW_INPUTS_KEY, inputs)
 			default:
 				e := fmt.Errorf("run Window error: expect xsql.Tuple type but got %[1]T(%[1]v)", d)
+				o.sendDeadLetter(d, e)
 				o.Broadcast(e)
 				o.statManager.IncTotalExceptions(e.Error())
 			}
@@ -493,10 +548,13 @@ func (tl *TupleList) getRestTuples() []*xsql.Tuple {
 func (o *WindowOperator) scan(inputs []*xsql.Tuple, triggerTime int64, ctx api.StreamContext) []*xsql.Tuple {
 	log := ctx.GetLogger()
 	log.Debugf("window %s triggered at %s(%d)", o.name, time.Unix(triggerTime/1000, triggerTime%1000), triggerTime)
+	if o.incAgg != nil {
+		o.scanPanes(triggerTime, ctx)
+		return inputs
+	}
 	var (
-		delta       int64
-		windowStart int64
-		windowEnd   = triggerTime
+		delta     int64
+		windowEnd = triggerTime
 	)
 	if o.window.Type == ast.HOPPING_WINDOW || o.window.Type == ast.SLIDING_WINDOW {
 		delta = o.calDelta(triggerTime, log)
@@ -528,18 +586,7 @@ func (o *WindowOperator) scan(inputs []*xsql.Tuple, triggerTime int64, ctx api.S
 		}
 	}
 
-	switch o.window.Type {
-	case ast.TUMBLING_WINDOW, ast.SESSION_WINDOW:
-		windowStart = o.triggerTime
-	case ast.HOPPING_WINDOW:
-		windowStart = o.triggerTime - o.window.Interval
-	case ast.SLIDING_WINDOW:
-		windowStart = triggerTime - o.window.Length
-	}
-	if windowStart <= 0 {
-		windowStart = windowEnd - o.window.Length
-	}
-	results.WindowRange = xsql.NewWindowRange(windowStart, windowEnd)
+	results.WindowRange = xsql.NewWindowRange(o.getWindowStart(triggerTime), windowEnd)
 	log.Debugf("window %s triggered for %d tuples", o.name, len(inputs))
 	if o.isEventTime {
 		results.Sort()
@@ -553,6 +600,22 @@ func (o *WindowOperator) scan(inputs []*xsql.Tuple, triggerTime int64, ctx api.S
 	return inputs[:i]
 }
 
+func (o *WindowOperator) getWindowStart(triggerTime int64) int64 {
+	var windowStart int64
+	switch o.window.Type {
+	case ast.TUMBLING_WINDOW, ast.SESSION_WINDOW:
+		windowStart = o.triggerTime
+	case ast.HOPPING_WINDOW:
+		windowStart = o.triggerTime - o.window.Interval
+	case ast.SLIDING_WINDOW:
+		windowStart = triggerTime - o.window.Length
+	}
+	if windowStart <= 0 {
+		windowStart = triggerTime - o.window.Length
+	}
+	return windowStart
+}
+
 func (o *WindowOperator) calDelta(triggerTime int64, log api.Logger) int64 {
 	var delta int64
 	lastTriggerTime := o.triggerTime
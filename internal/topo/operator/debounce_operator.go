@@ -0,0 +1,135 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/gob"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+const debounceStateKey = "$$debounce"
+
+func init() {
+	gob.Register(&DebounceState{})
+}
+
+// DebounceOp holds the latest row of each key and emits it once no new row of the key arrives for the quiet period.
+// The quiet period is based on the processing time.
+type DebounceOp struct {
+	// Quiet period in milliseconds
+	Quiet int64
+	Keys  []ast.Expr
+	mu    sync.Mutex
+}
+
+// Apply
+/*
+ *  input: *xsql.Tuple
+ *  output: nil, the rows are emitted by OnTick
+ */
+func (p *DebounceOp) Apply(ctx api.StreamContext, data interface{}, fv *xsql.FunctionValuer, _ *xsql.AggregateFunctionValuer) interface{} {
+	ctx.GetLogger().Debugf("debounce plan receive %v", data)
+	switch input := data.(type) {
+	case error:
+		return input
+	case *xsql.Tuple:
+		key, err := evalKey(input, fv, p.Keys)
+		if err != nil {
+			return fmt.Errorf("run Debounce error: %s", err)
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		s, err := p.getState(ctx)
+		if err != nil {
+			return err
+		}
+		s.Pending[key] = &DebounceEntry{Row: input, Last: conf.GetNowInMilli()}
+		return nil
+	default:
+		return fmt.Errorf("run Debounce error: invalid input %[1]T(%[1]v)", data)
+	}
+}
+
+func (p *DebounceOp) TickInterval() int64 {
+	return tickInterval(p.Quiet)
+}
+
+// OnTick emits the held rows whose keys have been quiet for the period in the order of arrival
+func (p *DebounceOp) OnTick(ctx api.StreamContext, now int64) interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, err := p.getState(ctx)
+	if err != nil {
+		return err
+	}
+	var due []*DebounceEntry
+	for k, e := range s.Pending {
+		if now-e.Last >= p.Quiet {
+			due = append(due, e)
+			delete(s.Pending, k)
+		}
+	}
+	if len(due) == 0 {
+		return nil
+	}
+	sort.SliceStable(due, func(i, j int) bool {
+		return due[i].Last < due[j].Last
+	})
+	result := make([]xsql.TupleRow, len(due))
+	for i, e := range due {
+		result[i] = e.Row
+	}
+	return result
+}
+
+func (p *DebounceOp) getState(ctx api.StreamContext) (*DebounceState, error) {
+	v, err := getLockedState(ctx, debounceStateKey, &p.mu, func() stateCloner { return &DebounceState{} })
+	if err != nil {
+		return nil, fmt.Errorf("run Debounce error: fail to get state %v", err)
+	}
+	s := v.(*DebounceState)
+	if s.Pending == nil {
+		s.Pending = make(map[string]*DebounceEntry)
+	}
+	return s, nil
+}
+
+// DebounceState is the latest row of each key not emitted yet
+type DebounceState struct {
+	Pending map[string]*DebounceEntry
+}
+
+type DebounceEntry struct {
+	Row  *xsql.Tuple
+	Last int64
+}
+
+func (s *DebounceState) clone() interface{} {
+	c := &DebounceState{}
+	if s.Pending != nil {
+		c.Pending = make(map[string]*DebounceEntry, len(s.Pending))
+		for k, e := range s.Pending {
+			c.Pending[k] = &DebounceEntry{Row: e.Row, Last: e.Last}
+		}
+	}
+	return c
+}
@@ -0,0 +1,81 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/internal/topo/topotest/mockclock"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestDebounceOp(t *testing.T) {
+	mockclock.ResetClock(0)
+	mc := mockclock.GetMockClock()
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	ctx := context.WithValue(context.Background(), context.LoggerKey, conf.Log.WithField("rule", "TestDebounceOp")).WithMeta("mockRule0", "debounce", tempStore)
+	fv, afv := xsql.NewFunctionValuersForOp(ctx)
+	op := &DebounceOp{Quiet: 100, Keys: []ast.Expr{&ast.FieldRef{Name: "dev", StreamName: "src"}}}
+	tuple := func(dev string, id int) *xsql.Tuple {
+		return &xsql.Tuple{Emitter: "src", Message: xsql.Message{"dev": dev, "id": id}, Timestamp: conf.GetNowInMilli()}
+	}
+	ids := func(result interface{}) []int {
+		if result == nil {
+			return nil
+		}
+		rows, ok := result.([]xsql.TupleRow)
+		if !ok {
+			t.Fatalf("unexpected result %v", result)
+		}
+		var r []int
+		for _, row := range rows {
+			v, _ := row.Value("id", "")
+			r = append(r, v.(int))
+		}
+		return r
+	}
+	advance := func(ms int64) {
+		mc.Set(mc.Now().Add(time.Duration(ms) * time.Millisecond))
+	}
+
+	for i, d := range []string{"d1", "d2", "d1"} {
+		if r := op.Apply(ctx, tuple(d, i), fv, afv); r != nil {
+			t.Fatalf("expect the row to be held but got %v", r)
+		}
+		advance(40)
+	}
+	// d2 has been quiet for 80ms, d1 for 40ms
+	if r := ids(op.OnTick(ctx, conf.GetNowInMilli())); r != nil {
+		t.Errorf("expect nothing but got %v", r)
+	}
+	advance(20)
+	if r := ids(op.OnTick(ctx, conf.GetNowInMilli())); !reflect.DeepEqual([]int{1}, r) {
+		t.Errorf("expect row 1 of d2 but got %v", r)
+	}
+	advance(40)
+	if r := ids(op.OnTick(ctx, conf.GetNowInMilli())); !reflect.DeepEqual([]int{2}, r) {
+		t.Errorf("expect the latest row 2 of d1 but got %v", r)
+	}
+	if r := ids(op.OnTick(ctx, conf.GetNowInMilli())); r != nil {
+		t.Errorf("expect nothing after emitted but got %v", r)
+	}
+}
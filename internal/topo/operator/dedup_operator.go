@@ -0,0 +1,249 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/gob"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+const (
+	dedupStateKey = "$$dedup"
+	// DefaultDedupCapacity is the expected keys of the bloom filter if max keys is not set in approximate mode
+	DefaultDedupCapacity = 100000
+)
+
+func init() {
+	gob.Register(&DedupState{})
+}
+
+// DedupOp drops the rows whose keys have been seen within the TTL. The seen keys are saved in the state so that
+// they survive the rule restart when checkpoint is enabled.
+type DedupOp struct {
+	Keys []ast.Expr
+	// TTL in milliseconds
+	TTL int64
+	// MaxKeys bounds the memory. In exact mode, the oldest keys are evicted when exceeded. 0 means no limit.
+	// In approximate mode, it is the expected keys within the TTL to size the bloom filter.
+	MaxKeys int
+	// FalsePositiveRate enables the approximate mode with bloom filters if bigger than 0
+	FalsePositiveRate float64
+	mu                sync.Mutex
+}
+
+// Apply
+/*
+ *  input: *xsql.Tuple
+ *  output: *xsql.Tuple or nil if it is a duplicate
+ */
+func (p *DedupOp) Apply(ctx api.StreamContext, data interface{}, fv *xsql.FunctionValuer, _ *xsql.AggregateFunctionValuer) interface{} {
+	ctx.GetLogger().Debugf("dedup plan receive %v", data)
+	switch input := data.(type) {
+	case error:
+		return input
+	case xsql.TupleRow:
+		key, err := evalKey(input, fv, p.Keys)
+		if err != nil {
+			return fmt.Errorf("run Dedup error: %s", err)
+		}
+		ts := conf.GetNowInMilli()
+		if e, ok := input.(xsql.Event); ok {
+			ts = e.GetTimestamp()
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		s, err := p.getState(ctx)
+		if err != nil {
+			return err
+		}
+		var seen bool
+		if p.FalsePositiveRate > 0 {
+			seen = s.seenApprox(key, ts, p.TTL, p.MaxKeys, p.FalsePositiveRate)
+		} else {
+			seen = s.seen(key, ts, p.TTL, p.MaxKeys)
+		}
+		if seen {
+			return nil
+		}
+		return input
+	default:
+		return fmt.Errorf("run Dedup error: invalid input %[1]T(%[1]v)", data)
+	}
+}
+
+func (p *DedupOp) getState(ctx api.StreamContext) (*DedupState, error) {
+	v, err := getLockedState(ctx, dedupStateKey, &p.mu, func() stateCloner { return &DedupState{} })
+	if err != nil {
+		return nil, fmt.Errorf("run Dedup error: fail to get state %v", err)
+	}
+	s := v.(*DedupState)
+	if s.Expires == nil {
+		s.Expires = make(map[string]int64)
+	}
+	return s, nil
+}
+
+// DedupState is the keyed TTL set of the seen keys
+type DedupState struct {
+	// Exact mode: the expiry time of each key and the keys in insertion order
+	Expires map[string]int64
+	Entries []DedupEntry
+	// Approximate mode: the current filter and the filter of the previous TTL period
+	Current  *BloomFilter
+	Previous *BloomFilter
+	Start    int64
+}
+
+type DedupEntry struct {
+	Key    string
+	Expire int64
+}
+
+func (s *DedupState) clone() interface{} {
+	c := &DedupState{
+		Entries:  append([]DedupEntry(nil), s.Entries...),
+		Current:  s.Current.clone(),
+		Previous: s.Previous.clone(),
+		Start:    s.Start,
+	}
+	if s.Expires != nil {
+		c.Expires = make(map[string]int64, len(s.Expires))
+		for k, v := range s.Expires {
+			c.Expires[k] = v
+		}
+	}
+	return c
+}
+
+// seen checks and records the key. The keys expire in insertion order, and the oldest key is evicted if max keys is exceeded.
+func (s *DedupState) seen(key string, ts int64, ttl int64, maxKeys int) bool {
+	for len(s.Entries) > 0 && s.Entries[0].Expire <= ts {
+		s.evictFirst()
+	}
+	if exp, ok := s.Expires[key]; ok && exp > ts {
+		return true
+	}
+	for maxKeys > 0 && len(s.Expires) >= maxKeys && len(s.Entries) > 0 {
+		s.evictFirst()
+	}
+	s.Expires[key] = ts + ttl
+	s.Entries = append(s.Entries, DedupEntry{Key: key, Expire: ts + ttl})
+	return false
+}
+
+func (s *DedupState) evictFirst() {
+	e := s.Entries[0]
+	// the key may have been renewed with a later entry
+	if exp, ok := s.Expires[e.Key]; ok && exp == e.Expire {
+		delete(s.Expires, e.Key)
+	}
+	s.Entries = s.Entries[1:]
+}
+
+// seenApprox checks and records the key with two rotating bloom filters. A key is remembered for at least one TTL
+// and at most two TTLs. It may report a false positive at the given rate but never a false negative.
+func (s *DedupState) seenApprox(key string, ts int64, ttl int64, capacity int, fpr float64) bool {
+	if capacity <= 0 {
+		capacity = DefaultDedupCapacity
+	}
+	if s.Current == nil {
+		s.Current = NewBloomFilter(capacity, fpr)
+		s.Start = ts
+	} else if ts-s.Start >= ttl {
+		if ts-s.Start >= 2*ttl {
+			s.Previous = nil
+		} else {
+			s.Previous = s.Current
+		}
+		s.Current = NewBloomFilter(capacity, fpr)
+		s.Start = ts
+	}
+	if s.Current.Test(key) || (s.Previous != nil && s.Previous.Test(key)) {
+		return true
+	}
+	s.Current.Add(key)
+	return false
+}
+
+// BloomFilter is a plain bloom filter using double hashing
+type BloomFilter struct {
+	Bits []uint64
+	M    uint64
+	K    int
+}
+
+// NewBloomFilter creates the filter sized for n keys with the false positive rate p
+func NewBloomFilter(n int, p float64) *BloomFilter {
+	m := uint64(math.Ceil(-float64(n) * math.Log(p) / (math.Ln2 * math.Ln2)))
+	if m < 64 {
+		m = 64
+	}
+	k := int(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &BloomFilter{
+		Bits: make([]uint64, (m+63)/64),
+		M:    m,
+		K:    k,
+	}
+}
+
+func (b *BloomFilter) clone() *BloomFilter {
+	if b == nil {
+		return nil
+	}
+	return &BloomFilter{
+		Bits: append([]uint64(nil), b.Bits...),
+		M:    b.M,
+		K:    b.K,
+	}
+}
+
+func (b *BloomFilter) hashes(key string) (uint64, uint64) {
+	h1 := fnv.New64a()
+	_, _ = h1.Write([]byte(key))
+	h2 := fnv.New64()
+	_, _ = h2.Write([]byte(key))
+	// the second hash must not be zero, otherwise all the k positions are the same
+	return h1.Sum64(), h2.Sum64() | 1
+}
+
+func (b *BloomFilter) Add(key string) {
+	h1, h2 := b.hashes(key)
+	for i := 0; i < b.K; i++ {
+		pos := (h1 + uint64(i)*h2) % b.M
+		b.Bits[pos/64] |= 1 << (pos % 64)
+	}
+}
+
+func (b *BloomFilter) Test(key string) bool {
+	h1, h2 := b.hashes(key)
+	for i := 0; i < b.K; i++ {
+		pos := (h1 + uint64(i)*h2) % b.M
+		if b.Bits[pos/64]&(1<<(pos%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
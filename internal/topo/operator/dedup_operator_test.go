@@ -0,0 +1,102 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestDedupOp(t *testing.T) {
+	tuple := func(id interface{}, ts int64) *xsql.Tuple {
+		return &xsql.Tuple{Emitter: "src", Message: xsql.Message{"id": id, "dev": "d1"}, Timestamp: ts}
+	}
+	tests := []struct {
+		op     *DedupOp
+		data   []*xsql.Tuple
+		result []int64
+	}{
+		{ // 0 exact
+			op:     &DedupOp{Keys: []ast.Expr{&ast.FieldRef{Name: "id", StreamName: "src"}}, TTL: 100},
+			data:   []*xsql.Tuple{tuple(1, 0), tuple(2, 10), tuple(1, 50), tuple(2, 109), tuple(1, 100), tuple(1, 150)},
+			result: []int64{0, 10, 100},
+		},
+		{ // 1 multiple keys
+			op:     &DedupOp{Keys: []ast.Expr{&ast.FieldRef{Name: "id", StreamName: "src"}, &ast.FieldRef{Name: "dev", StreamName: "src"}}, TTL: 100},
+			data:   []*xsql.Tuple{tuple(1, 0), tuple(1, 10), tuple(2, 20)},
+			result: []int64{0, 20},
+		},
+		{ // 2 max keys evicts the oldest
+			op:     &DedupOp{Keys: []ast.Expr{&ast.FieldRef{Name: "id", StreamName: "src"}}, TTL: 1000, MaxKeys: 2},
+			data:   []*xsql.Tuple{tuple(1, 0), tuple(2, 10), tuple(3, 20), tuple(2, 30), tuple(1, 40)},
+			result: []int64{0, 10, 20, 40},
+		},
+		{ // 3 approximate
+			op:     &DedupOp{Keys: []ast.Expr{&ast.FieldRef{Name: "id", StreamName: "src"}}, TTL: 100, MaxKeys: 100, FalsePositiveRate: 0.001},
+			data:   []*xsql.Tuple{tuple(1, 0), tuple(2, 10), tuple(1, 50), tuple(1, 120), tuple(2, 180), tuple(2, 400)},
+			result: []int64{0, 10, 400},
+		},
+	}
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	contextLogger := conf.Log.WithField("rule", "TestDedupOp")
+	for i, tt := range tests {
+		tempStore, _ := state.CreateStore("mockRule"+strconv.Itoa(i), api.AtMostOnce)
+		ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("mockRule"+strconv.Itoa(i), "dedup", tempStore)
+		fv, afv := xsql.NewFunctionValuersForOp(ctx)
+		var r []int64
+		for _, d := range tt.data {
+			switch v := tt.op.Apply(ctx, d, fv, afv).(type) {
+			case nil:
+			case *xsql.Tuple:
+				r = append(r, v.Timestamp)
+			default:
+				t.Fatalf("%d. unexpected result %v", i, v)
+			}
+		}
+		if !reflect.DeepEqual(tt.result, r) {
+			t.Errorf("%d.\n\nresult mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.result, r)
+		}
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	b := NewBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		b.Add(strconv.Itoa(i))
+	}
+	for i := 0; i < 1000; i++ {
+		if !b.Test(strconv.Itoa(i)) {
+			t.Fatalf("false negative for %d", i)
+		}
+	}
+	fp := 0
+	for i := 1000; i < 11000; i++ {
+		if b.Test(strconv.Itoa(i)) {
+			fp++
+		}
+	}
+	if fp > 300 {
+		t.Errorf("false positive rate is too high: %d/10000", fp)
+	}
+}
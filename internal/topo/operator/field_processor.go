@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -30,6 +30,8 @@ import (
 type defaultFieldProcessor struct {
 	streamFields    map[string]*ast.JsonStreamField
 	timestampFormat string
+	// How to handle the values mismatching the schema, default to error
+	typeMismatch string
 }
 
 func (p *defaultFieldProcessor) validateAndConvert(tuple *xsql.Tuple) error {
@@ -41,17 +43,48 @@ func (p *defaultFieldProcessor) validateAndConvertMessage(schema map[string]*ast
 	for name, sf := range schema {
 		v, ok := message.Value(name, "")
 		if !ok {
+			// A missing field, usually sent by the legacy devices after a column is added, is regarded as null
+			if p.typeMismatch == ast.TypeMismatchCoerce || p.typeMismatch == ast.TypeMismatchNull {
+				message[name] = nil
+				continue
+			}
 			return nil, fmt.Errorf("field %s is not found", name)
 		}
-		if nv, err := p.validateAndConvertField(sf, v); err != nil {
+		nv, err := p.validateAndConvertField(sf, v)
+		if err != nil {
+			switch p.typeMismatch {
+			case ast.TypeMismatchCoerce:
+				nv, err = p.coerceField(sf, v)
+			case ast.TypeMismatchNull:
+				nv, err = nil, nil
+			}
+		}
+		if err != nil {
 			return nil, fmt.Errorf("field %s type mismatch: %v", name, err)
-		} else {
-			message[name] = nv
 		}
+		message[name] = nv
 	}
 	return message, nil
 }
 
+// Convert the value of a simple type across kinds, such as from string to number
+func (p *defaultFieldProcessor) coerceField(sf *ast.JsonStreamField, t interface{}) (interface{}, error) {
+	switch sf.Type {
+	case (ast.BIGINT).String():
+		return cast.ToInt64(t, cast.CONVERT_ALL)
+	case (ast.FLOAT).String():
+		return cast.ToFloat64(t, cast.CONVERT_ALL)
+	case (ast.BOOLEAN).String():
+		return cast.ToBool(t, cast.CONVERT_ALL)
+	case (ast.STRINGS).String():
+		return cast.ToString(t, cast.CONVERT_ALL)
+	case (ast.BYTEA).String():
+		return cast.ToByteA(t, cast.CONVERT_ALL)
+	default:
+		return nil, fmt.Errorf("cannot coerce %v to %s", t, sf.Type)
+	}
+}
+
 // Validate and convert field value to the type defined in schema
 func (p *defaultFieldProcessor) validateAndConvertField(sf *ast.JsonStreamField, t interface{}) (interface{}, error) {
 	v := reflect.ValueOf(t)
@@ -0,0 +1,44 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/json"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// evalKey evaluates the key expressions of the row as the key of the state. The values are encoded as a JSON array
+// so that the values containing the separator or of different types do not collide.
+func evalKey(row xsql.Row, fv *xsql.FunctionValuer, keys []ast.Expr) (string, error) {
+	if len(keys) == 0 {
+		return "", nil
+	}
+	ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(row, fv)}
+	values := make([]interface{}, len(keys))
+	for i, k := range keys {
+		r := ve.Eval(k)
+		if e, ok := r.(error); ok {
+			return "", e
+		}
+		values[i] = r
+	}
+	b, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
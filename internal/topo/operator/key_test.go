@@ -0,0 +1,46 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestEvalKeyNoCollision(t *testing.T) {
+	keys := []ast.Expr{&ast.FieldRef{Name: "a", StreamName: "src"}, &ast.FieldRef{Name: "b", StreamName: "src"}}
+	fv, _ := xsql.NewFunctionValuersForOp(context.Background())
+	tests := [][2]xsql.Message{
+		{{"a": "a,b", "b": "c"}, {"a": "a", "b": "b,c"}},
+		{{"a": nil, "b": "c"}, {"a": "<nil>", "b": "c"}},
+		{{"a": 1, "b": "c"}, {"a": "1", "b": "c"}},
+	}
+	for i, tt := range tests {
+		k1, err := evalKey(&xsql.Tuple{Emitter: "src", Message: tt[0]}, fv, keys)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		k2, err := evalKey(&xsql.Tuple{Emitter: "src", Message: tt[1]}, fv, keys)
+		if err != nil {
+			t.Fatalf("%d: %v", i, err)
+		}
+		if k1 == k2 {
+			t.Errorf("%d: keys of %v and %v collide as %s", i, tt[0], tt[1], k1)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"sync"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+// stateCloner is the state of a keyed operator which is changed in place. The clone must not share any map or slice
+// which is changed later with the state.
+type stateCloner interface {
+	clone() interface{}
+}
+
+// lockedState wraps the state in the context so that the checkpoint snapshots a copy of it taken under the operator
+// lock instead of the state which keeps changing.
+type lockedState struct {
+	mu    *sync.Mutex
+	state stateCloner
+}
+
+func (l *lockedState) SnapshotState() interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.state.clone()
+}
+
+// getLockedState returns the state of the key and must be called with the operator lock held. The state restored
+// from the checkpoint is copied so that the value in the pending snapshot is never changed.
+func getLockedState(ctx api.StreamContext, key string, mu *sync.Mutex, newState func() stateCloner) (stateCloner, error) {
+	v, err := ctx.GetState(key)
+	if err != nil {
+		return nil, err
+	}
+	var s stateCloner
+	switch vt := v.(type) {
+	case *lockedState:
+		return vt.state, nil
+	case stateCloner:
+		s = vt.clone().(stateCloner)
+	default:
+		s = newState()
+	}
+	_ = ctx.PutState(key, &lockedState{mu: mu, state: s})
+	return s, nil
+}
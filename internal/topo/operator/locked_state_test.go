@@ -0,0 +1,105 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/pkg/store"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// TestLockedStateCheckpoint runs the checkpoints while the rows flow. Run it with -race to detect the shared state.
+func TestLockedStateCheckpoint(t *testing.T) {
+	err := store.SetupDefault()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := &ast.FieldRef{Name: "id", StreamName: "src"}
+	dev := &ast.FieldRef{Name: "dev", StreamName: "src"}
+	tests := []struct {
+		name string
+		op   interface {
+			Apply(ctx api.StreamContext, data interface{}, fv *xsql.FunctionValuer, afv *xsql.AggregateFunctionValuer) interface{}
+		}
+	}{
+		{
+			name: "dedup",
+			op:   &DedupOp{Keys: []ast.Expr{id}, TTL: 1000, MaxKeys: 50},
+		},
+		{
+			name: "pattern",
+			op: &PatternOp{
+				Elements:   []ast.PatternElement{{Name: "A", Min: 1, Max: -1}, {Name: "B", Min: 1, Max: 1}},
+				Conditions: map[string]ast.Expr{"B": &ast.BinaryExpr{LHS: id, OP: ast.GT, RHS: &ast.IntegerLiteral{Val: 1990}}},
+				Within:     100,
+				Partition:  []ast.Expr{dev},
+			},
+		},
+		{
+			name: "throttle",
+			op:   &ThrottleOp{Interval: 5, Policy: ast.ThrottleDrop, Limit: 2, Keys: []ast.Expr{dev}},
+		},
+		{
+			name: "debounce",
+			op:   &DebounceOp{Quiet: 1000, Keys: []ast.Expr{id}},
+		},
+		{
+			name: "sample",
+			op:   &SampleOp{Every: 3, Keys: []ast.Expr{id}},
+		},
+	}
+	contextLogger := conf.Log.WithField("rule", "TestLockedStateCheckpoint")
+	for _, tt := range tests {
+		ruleId := "mockRuleCheckpoint" + tt.name
+		tempStore, err := state.CreateStore(ruleId, api.AtLeastOnce)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta(ruleId, tt.name, tempStore).(*context.DefaultContext)
+		fv, afv := xsql.NewFunctionValuersForOp(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; i < 2000; i++ {
+				tt.op.Apply(ctx, &xsql.Tuple{Emitter: "src", Message: xsql.Message{"id": i, "dev": strconv.Itoa(i % 10)}, Timestamp: int64(1000 + i)}, fv, afv)
+			}
+		}()
+	loop:
+		for cid := int64(1); ; cid++ {
+			select {
+			case <-done:
+				break loop
+			default:
+			}
+			if err := ctx.Snapshot(); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if err := ctx.SaveState(cid); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if err := tempStore.SaveCheckpoint(cid); err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+		}
+		_ = tempStore.Clean()
+	}
+}
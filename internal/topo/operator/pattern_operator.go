@@ -0,0 +1,247 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/gob"
+	"fmt"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+const patternStateKey = "$$pattern"
+
+func init() {
+	gob.Register(&PatternState{})
+}
+
+// PatternOp detects the sequence of rows matching the pattern within a time range for each partition.
+// It runs a NFA for each partition key whose partial matches are saved in the state so that they survive
+// the rule restart when checkpoint is enabled. The rows of each match are emitted as one collection.
+type PatternOp struct {
+	Elements []ast.PatternElement
+	// The conditions by pattern variable. The variables without condition match any row.
+	Conditions map[string]ast.Expr
+	// Within in milliseconds
+	Within    int64
+	Partition []ast.Expr
+	mu        sync.Mutex
+}
+
+// Apply
+/*
+ *  input: *xsql.Tuple
+ *  output: *xsql.WindowTuples of the matched rows or nil if no match is completed
+ */
+func (p *PatternOp) Apply(ctx api.StreamContext, data interface{}, fv *xsql.FunctionValuer, _ *xsql.AggregateFunctionValuer) interface{} {
+	ctx.GetLogger().Debugf("pattern plan receive %v", data)
+	switch input := data.(type) {
+	case error:
+		return input
+	case *xsql.Tuple:
+		key, err := evalKey(input, fv, p.Partition)
+		if err != nil {
+			return fmt.Errorf("run Pattern error: %s", err)
+		}
+		ve := &xsql.ValuerEval{Valuer: xsql.MultiValuer(input, fv)}
+		// Evaluate each condition only once for the row
+		matched := make([]bool, len(p.Elements))
+		for i, el := range p.Elements {
+			cond, ok := p.Conditions[el.Name]
+			if !ok {
+				matched[i] = true
+				continue
+			}
+			switch r := ve.Eval(cond).(type) {
+			case error:
+				return fmt.Errorf("run Pattern error: %s", r)
+			case bool:
+				matched[i] = r
+			case nil:
+				matched[i] = false
+			default:
+				return fmt.Errorf("run Pattern error: invalid condition of %s that returns non-bool value %[2]T(%[2]v)", el.Name, r)
+			}
+		}
+		ts := input.GetTimestamp()
+		if ts == 0 {
+			ts = conf.GetNowInMilli()
+		}
+
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		s, err := p.getState(ctx)
+		if err != nil {
+			return err
+		}
+		if ts-s.LastPrune >= p.Within {
+			s.prune(ts, p.Within)
+			s.LastPrune = ts
+		}
+		runs, result := p.advance(s.Runs[key], input, matched, ts)
+		if len(runs) > 0 {
+			s.Runs[key] = runs
+		} else {
+			delete(s.Runs, key)
+		}
+		if result != nil {
+			rows := make([]xsql.TupleRow, len(result.Rows))
+			for i, r := range result.Rows {
+				rows[i] = r
+			}
+			return &xsql.WindowTuples{
+				Content:     rows,
+				WindowRange: xsql.NewWindowRange(result.Start, ts),
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("run Pattern error: invalid input %[1]T(%[1]v)", data)
+	}
+}
+
+// advance feeds the row to all the partial matches of a partition and a new match starting from the row.
+// A match which cannot accept the row is dropped because the rows must be contiguous. Once a match is completed,
+// it is returned and all the partial matches are discarded so that the next match starts after the matched rows.
+func (p *PatternOp) advance(runs []*PatternRun, row *xsql.Tuple, matched []bool, ts int64) ([]*PatternRun, *PatternRun) {
+	var (
+		next []*PatternRun
+		seen = make(map[[2]int]bool)
+	)
+	// The existing runs are in the order of start time, so the earliest one wins when deduplicating
+	for _, r := range append(runs, &PatternRun{Pos: -1, Start: ts}) {
+		if ts-r.Start > p.Within {
+			continue
+		}
+		for _, st := range p.transitions(r, matched) {
+			el := p.Elements[st[0]]
+			// Runs with the same position and enough count behave the same afterwards
+			k := [2]int{st[0], st[1]}
+			if st[1] > el.Min {
+				k[1] = el.Min
+			}
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			rows := make([]*xsql.Tuple, len(r.Rows), len(r.Rows)+1)
+			copy(rows, r.Rows)
+			nr := &PatternRun{Pos: st[0], Count: st[1], Start: r.Start, Rows: append(rows, row)}
+			if p.isFinal(nr) {
+				return nil, nr
+			}
+			next = append(next, nr)
+		}
+	}
+	return next, nil
+}
+
+// transitions returns the (position, count) states the run can move to with the row
+func (p *PatternOp) transitions(r *PatternRun, matched []bool) [][2]int {
+	var result [][2]int
+	if r.Pos >= 0 {
+		el := p.Elements[r.Pos]
+		if matched[r.Pos] && (el.Max < 0 || r.Count < el.Max) {
+			result = append(result, [2]int{r.Pos, r.Count + 1})
+		}
+		if r.Count < el.Min {
+			return result
+		}
+	}
+	for j := r.Pos + 1; j < len(p.Elements); j++ {
+		if matched[j] {
+			result = append(result, [2]int{j, 1})
+		}
+		// Only the optional variables can be skipped
+		if p.Elements[j].Min > 0 {
+			break
+		}
+	}
+	return result
+}
+
+func (p *PatternOp) isFinal(r *PatternRun) bool {
+	if r.Count < p.Elements[r.Pos].Min {
+		return false
+	}
+	for j := r.Pos + 1; j < len(p.Elements); j++ {
+		if p.Elements[j].Min > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (p *PatternOp) getState(ctx api.StreamContext) (*PatternState, error) {
+	v, err := getLockedState(ctx, patternStateKey, &p.mu, func() stateCloner { return &PatternState{} })
+	if err != nil {
+		return nil, fmt.Errorf("run Pattern error: fail to get state %v", err)
+	}
+	s := v.(*PatternState)
+	if s.Runs == nil {
+		s.Runs = make(map[string][]*PatternRun)
+	}
+	return s, nil
+}
+
+// PatternState is the partial matches of each partition key
+type PatternState struct {
+	Runs      map[string][]*PatternRun
+	LastPrune int64
+}
+
+// PatternRun is a partial match which has matched Count rows of the pattern variable at Pos
+type PatternRun struct {
+	Pos   int
+	Count int
+	Start int64
+	Rows  []*xsql.Tuple
+}
+
+func (s *PatternState) clone() interface{} {
+	c := &PatternState{LastPrune: s.LastPrune}
+	if s.Runs != nil {
+		c.Runs = make(map[string][]*PatternRun, len(s.Runs))
+		for k, runs := range s.Runs {
+			cr := make([]*PatternRun, len(runs))
+			for i, r := range runs {
+				cr[i] = &PatternRun{Pos: r.Pos, Count: r.Count, Start: r.Start, Rows: append([]*xsql.Tuple(nil), r.Rows...)}
+			}
+			c.Runs[k] = cr
+		}
+	}
+	return c
+}
+
+// prune drops the expired partial matches of all partitions, including the partitions without new rows
+func (s *PatternState) prune(ts int64, within int64) {
+	for k, runs := range s.Runs {
+		var kept []*PatternRun
+		for _, r := range runs {
+			if ts-r.Start <= within {
+				kept = append(kept, r)
+			}
+		}
+		if len(kept) > 0 {
+			s.Runs[k] = kept
+		} else {
+			delete(s.Runs, k)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/internal/topo/topotest/mockclock"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestPatternOp(t *testing.T) {
+	// timestamp 0 means the row has no timestamp, so the timestamps are based on it
+	const base = 1000
+	tuple := func(dev string, temp int, ts int64) *xsql.Tuple {
+		return &xsql.Tuple{Emitter: "src", Message: xsql.Message{"dev": dev, "temp": temp}, Timestamp: base + ts}
+	}
+	cond := func(op ast.Token, v int) ast.Expr {
+		return &ast.BinaryExpr{LHS: &ast.FieldRef{Name: "temp", StreamName: "src"}, OP: op, RHS: &ast.IntegerLiteral{Val: v}}
+	}
+	conditions := map[string]ast.Expr{"A": cond(ast.GT, 80), "B": cond(ast.GT, 90), "C": cond(ast.LT, 50)}
+	abc := []ast.PatternElement{{Name: "A", Min: 1, Max: 1}, {Name: "B", Min: 1, Max: -1}, {Name: "C", Min: 1, Max: 1}}
+	partition := []ast.Expr{&ast.FieldRef{Name: "dev", StreamName: "src"}}
+	tests := []struct {
+		op     *PatternOp
+		data   []*xsql.Tuple
+		result [][]int64
+	}{
+		{ // 0 one or more
+			op:     &PatternOp{Elements: abc, Conditions: conditions, Within: 100},
+			data:   []*xsql.Tuple{tuple("d1", 85, 0), tuple("d1", 95, 10), tuple("d1", 96, 20), tuple("d1", 40, 30), tuple("d1", 40, 40)},
+			result: [][]int64{{0, 10, 20, 30}},
+		},
+		{ // 1 expired
+			op:   &PatternOp{Elements: abc, Conditions: conditions, Within: 100},
+			data: []*xsql.Tuple{tuple("d1", 85, 0), tuple("d1", 95, 10), tuple("d1", 40, 200)},
+		},
+		{ // 2 partition
+			op:     &PatternOp{Elements: abc, Conditions: conditions, Within: 100, Partition: partition},
+			data:   []*xsql.Tuple{tuple("d1", 85, 0), tuple("d2", 85, 5), tuple("d1", 95, 10), tuple("d2", 40, 15), tuple("d1", 40, 20), tuple("d2", 95, 25)},
+			result: [][]int64{{0, 10, 20}},
+		},
+		{ // 3 contiguous
+			op:   &PatternOp{Elements: abc, Conditions: conditions, Within: 100},
+			data: []*xsql.Tuple{tuple("d1", 85, 0), tuple("d1", 95, 10), tuple("d1", 60, 20), tuple("d1", 40, 30)},
+		},
+		{ // 4 zero or more and undefined variable
+			op:     &PatternOp{Elements: []ast.PatternElement{{Name: "A", Min: 1, Max: 1}, {Name: "B", Min: 0, Max: -1}, {Name: "C", Min: 1, Max: 1}, {Name: "D", Min: 1, Max: 1}}, Conditions: conditions, Within: 100},
+			data:   []*xsql.Tuple{tuple("d1", 85, 0), tuple("d1", 40, 10), tuple("d1", 60, 20), tuple("d1", 95, 30), tuple("d1", 92, 40), tuple("d1", 10, 50), tuple("d1", 10, 60)},
+			result: [][]int64{{0, 10, 20}, {30, 40, 50, 60}},
+		},
+	}
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	contextLogger := conf.Log.WithField("rule", "TestPatternOp")
+	for i, tt := range tests {
+		tempStore, _ := state.CreateStore("mockRule"+strconv.Itoa(i), api.AtMostOnce)
+		ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("mockRule"+strconv.Itoa(i), "pattern", tempStore)
+		fv, afv := xsql.NewFunctionValuersForOp(ctx)
+		var r [][]int64
+		for _, d := range tt.data {
+			switch v := tt.op.Apply(ctx, d, fv, afv).(type) {
+			case nil:
+			case *xsql.WindowTuples:
+				var ts []int64
+				for _, row := range v.Content {
+					ts = append(ts, row.(*xsql.Tuple).Timestamp-base)
+				}
+				r = append(r, ts)
+			default:
+				t.Fatalf("%d. unexpected result %v", i, v)
+			}
+		}
+		if !reflect.DeepEqual(tt.result, r) {
+			t.Errorf("%d.\n\nresult mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.result, r)
+		}
+	}
+}
+
+// TestPatternOpNoTimestamp checks the rows without timestamp are matched by the current time
+func TestPatternOpNoTimestamp(t *testing.T) {
+	cond := func(op ast.Token, v int) ast.Expr {
+		return &ast.BinaryExpr{LHS: &ast.FieldRef{Name: "temp", StreamName: "src"}, OP: op, RHS: &ast.IntegerLiteral{Val: v}}
+	}
+	op := &PatternOp{
+		Elements:   []ast.PatternElement{{Name: "A", Min: 1, Max: 1}, {Name: "B", Min: 1, Max: 1}},
+		Conditions: map[string]ast.Expr{"A": cond(ast.GT, 80), "B": cond(ast.LT, 50)},
+		Within:     100,
+	}
+	mockclock.ResetClock(1000)
+	mc := mockclock.GetMockClock()
+	contextLogger := conf.Log.WithField("rule", "TestPatternOpNoTimestamp")
+	tempStore, _ := state.CreateStore("mockRuleNoTimestamp", api.AtMostOnce)
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("mockRuleNoTimestamp", "pattern", tempStore)
+	fv, afv := xsql.NewFunctionValuersForOp(ctx)
+	apply := func(temp int) interface{} {
+		return op.Apply(ctx, &xsql.Tuple{Emitter: "src", Message: xsql.Message{"temp": temp}}, fv, afv)
+	}
+	// expired by the current time
+	if r := apply(85); r != nil {
+		t.Fatalf("unexpected result %v", r)
+	}
+	mc.Add(200 * time.Millisecond)
+	if r := apply(40); r != nil {
+		t.Errorf("expect the match expired but got %v", r)
+	}
+	// matched within the time range
+	if r := apply(85); r != nil {
+		t.Fatalf("unexpected result %v", r)
+	}
+	mc.Add(50 * time.Millisecond)
+	r, ok := apply(40).(*xsql.WindowTuples)
+	if !ok || len(r.Content) != 2 {
+		t.Fatalf("expect a match of 2 rows but got %v", r)
+	}
+	if !reflect.DeepEqual(xsql.NewWindowRange(1200, 1250), r.WindowRange) {
+		t.Errorf("expect window range [1200, 1250] but got %v", r.WindowRange)
+	}
+}
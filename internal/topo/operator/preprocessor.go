@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -16,6 +16,7 @@ package operator
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/xsql"
@@ -38,6 +39,10 @@ type Preprocessor struct {
 	timestampField string
 	checkSchema    bool
 	isBinary       bool
+	// How to handle the fields out of the schema, default to keep
+	unknownFields string
+	// All the fields defined in the schema in lower case to detect the unknown fields
+	definedFields map[string]struct{}
 }
 
 func NewPreprocessor(isSchemaless bool, fields map[string]*ast.JsonStreamField, _ bool, _ []string, iet bool, timestampField string, timestampFormat string, isBinary bool, strictValidation bool) (*Preprocessor, error) {
@@ -55,6 +60,22 @@ func NewPreprocessor(isSchemaless bool, fields map[string]*ast.JsonStreamField,
 	return p, nil
 }
 
+// SetSchemaPolicy sets how to handle the unknown fields and the mismatched values when validating the schema.
+// The schema must be the full stream schema instead of the pruned fields.
+func (p *Preprocessor) SetSchemaPolicy(unknownFields string, typeMismatch string, schema map[string]*ast.JsonStreamField) {
+	if !p.checkSchema || p.isBinary {
+		return
+	}
+	p.typeMismatch = typeMismatch
+	if unknownFields == ast.UnknownFieldsDrop || unknownFields == ast.UnknownFieldsError {
+		p.unknownFields = unknownFields
+		p.definedFields = make(map[string]struct{}, len(schema))
+		for k := range schema {
+			p.definedFields[strings.ToLower(k)] = struct{}{}
+		}
+	}
+}
+
 // Apply the preprocessor to the tuple
 /*	input: *xsql.Tuple
  *	output: *xsql.Tuple
@@ -69,8 +90,15 @@ func (p *Preprocessor) Apply(ctx api.StreamContext, data interface{}, _ *xsql.Fu
 	log.Debugf("preprocessor receive %s", tuple.Message)
 	if p.checkSchema {
 		if !p.isBinary {
-			err := p.validateAndConvert(tuple)
+			err := p.checkUnknownFields(tuple.Message)
+			if err != nil {
+				return fmt.Errorf("error in preprocessor: %s", err)
+			}
+			err = p.validateAndConvert(tuple)
 			if err != nil {
+				if p.typeMismatch == ast.TypeMismatchDeadLetter {
+					return &xsql.DeadLetterError{Err: fmt.Errorf("error in preprocessor: %s", err)}
+				}
 				return fmt.Errorf("error in preprocessor: %s", err)
 			}
 		} else {
@@ -105,3 +133,19 @@ func (p *Preprocessor) Apply(ctx api.StreamContext, data interface{}, _ *xsql.Fu
 	//}
 	return tuple
 }
+
+func (p *Preprocessor) checkUnknownFields(message xsql.Message) error {
+	if p.unknownFields == "" {
+		return nil
+	}
+	for k := range message {
+		if _, ok := p.definedFields[strings.ToLower(k)]; ok {
+			continue
+		}
+		if p.unknownFields == ast.UnknownFieldsError {
+			return fmt.Errorf("field %s is not defined in the schema", k)
+		}
+		delete(message, k)
+	}
+	return nil
+}
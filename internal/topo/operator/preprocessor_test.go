@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -984,6 +984,77 @@ func TestPreprocessorError(t *testing.T) {
 	}
 }
 
+func TestPreprocessorSchemaPolicy(t *testing.T) {
+	sfs := ast.StreamFields{
+		{Name: "id", FieldType: &ast.BasicType{Type: ast.BIGINT}},
+		{Name: "temp", FieldType: &ast.BasicType{Type: ast.FLOAT}},
+		{Name: "humidity", FieldType: &ast.BasicType{Type: ast.FLOAT}},
+	}
+	schema := sfs.ToJsonSchema()
+	tests := []struct {
+		unknownFields string
+		typeMismatch  string
+		data          []byte
+		result        interface{}
+	}{
+		{
+			unknownFields: ast.UnknownFieldsKeep,
+			data:          []byte(`{"id": 1, "temp": 20.5, "humidity": 50, "fw": "1.2"}`),
+			result:        &xsql.Tuple{Message: xsql.Message{"id": int64(1), "temp": 20.5, "humidity": 50.0, "fw": "1.2"}},
+		}, {
+			unknownFields: ast.UnknownFieldsDrop,
+			data:          []byte(`{"ID": 1, "temp": 20.5, "humidity": 50, "fw": "1.2"}`),
+			result:        &xsql.Tuple{Message: xsql.Message{"ID": 1.0, "id": int64(1), "temp": 20.5, "humidity": 50.0}},
+		}, {
+			unknownFields: ast.UnknownFieldsError,
+			data:          []byte(`{"id": 1, "temp": 20.5, "humidity": 50, "fw": "1.2"}`),
+			result:        errors.New("error in preprocessor: field fw is not defined in the schema"),
+		}, {
+			typeMismatch: ast.TypeMismatchError,
+			data:         []byte(`{"id": 1, "temp": "20.5", "humidity": 50}`),
+			result:       errors.New("error in preprocessor: field temp type mismatch: cannot convert string(20.5) to float64"),
+		}, {
+			typeMismatch: ast.TypeMismatchCoerce,
+			data:         []byte(`{"id": "1", "temp": "20.5"}`),
+			result:       &xsql.Tuple{Message: xsql.Message{"id": int64(1), "temp": 20.5, "humidity": nil}},
+		}, {
+			typeMismatch: ast.TypeMismatchCoerce,
+			data:         []byte(`{"id": 1, "temp": "hot", "humidity": 50}`),
+			result:       errors.New("error in preprocessor: field temp type mismatch: cannot convert string(hot) to float64"),
+		}, {
+			typeMismatch: ast.TypeMismatchNull,
+			data:         []byte(`{"id": 1, "temp": "hot"}`),
+			result:       &xsql.Tuple{Message: xsql.Message{"id": int64(1), "temp": nil, "humidity": nil}},
+		}, {
+			typeMismatch: ast.TypeMismatchDeadLetter,
+			data:         []byte(`{"id": 1, "temp": "hot", "humidity": 50}`),
+			result:       &xsql.DeadLetterError{Err: errors.New("error in preprocessor: field temp type mismatch: cannot convert string(hot) to float64")},
+		},
+	}
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+
+	defer conf.CloseLogger()
+	contextLogger := conf.Log.WithField("rule", "TestPreprocessorSchemaPolicy")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	for i, tt := range tests {
+		pp, err := NewPreprocessor(false, schema, true, nil, false, "", "", false, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pp.SetSchemaPolicy(tt.unknownFields, tt.typeMismatch, schema)
+		dm := make(map[string]interface{})
+		if e := json.Unmarshal(tt.data, &dm); e != nil {
+			t.Fatal(e)
+		}
+		tuple := &xsql.Tuple{Message: dm}
+		fv, afv := xsql.NewFunctionValuersForOp(nil)
+		result := pp.Apply(ctx, tuple, fv, afv)
+		if !reflect.DeepEqual(tt.result, result) {
+			t.Errorf("%d. %q\n\nresult mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tuple, tt.result, result)
+		}
+	}
+}
+
 func TestPreprocessorForBinary(t *testing.T) {
 	docsFolder, err := conf.GetLoc("docs/")
 	if err != nil {
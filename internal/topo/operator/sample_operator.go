@@ -0,0 +1,99 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/gob"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+const sampleStateKey = "$$sample"
+
+func init() {
+	gob.Register(&SampleState{})
+}
+
+// SampleOp keeps every Nth row of each key starting from the first one if Every is set, otherwise it keeps
+// each row randomly at the Percent probability.
+type SampleOp struct {
+	Every   int
+	Percent float64
+	Keys    []ast.Expr
+	mu      sync.Mutex
+}
+
+// Apply
+/*
+ *  input: *xsql.Tuple
+ *  output: *xsql.Tuple or nil if it is not sampled
+ */
+func (p *SampleOp) Apply(ctx api.StreamContext, data interface{}, fv *xsql.FunctionValuer, _ *xsql.AggregateFunctionValuer) interface{} {
+	ctx.GetLogger().Debugf("sample plan receive %v", data)
+	switch input := data.(type) {
+	case error:
+		return input
+	case xsql.TupleRow:
+		if p.Every <= 0 {
+			if rand.Float64()*100 < p.Percent {
+				return input
+			}
+			return nil
+		}
+		key, err := evalKey(input, fv, p.Keys)
+		if err != nil {
+			return fmt.Errorf("run Sample error: %s", err)
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		v, err := getLockedState(ctx, sampleStateKey, &p.mu, func() stateCloner { return &SampleState{} })
+		if err != nil {
+			return fmt.Errorf("run Sample error: fail to get state %v", err)
+		}
+		s := v.(*SampleState)
+		if s.Counts == nil {
+			s.Counts = make(map[string]int)
+		}
+		c := s.Counts[key]
+		s.Counts[key] = (c + 1) % p.Every
+		if c == 0 {
+			return input
+		}
+		return nil
+	default:
+		return fmt.Errorf("run Sample error: invalid input %[1]T(%[1]v)", data)
+	}
+}
+
+// SampleState is the count of the rows since the last sampled row of each key
+type SampleState struct {
+	Counts map[string]int
+}
+
+func (s *SampleState) clone() interface{} {
+	c := &SampleState{}
+	if s.Counts != nil {
+		c.Counts = make(map[string]int, len(s.Counts))
+		for k, v := range s.Counts {
+			c.Counts[k] = v
+		}
+	}
+	return c
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestSampleOp(t *testing.T) {
+	tuple := func(dev string, ts int64) *xsql.Tuple {
+		return &xsql.Tuple{Emitter: "src", Message: xsql.Message{"dev": dev}, Timestamp: ts}
+	}
+	tests := []struct {
+		op     *SampleOp
+		data   []*xsql.Tuple
+		result []int64
+	}{
+		{ // 0 every
+			op:     &SampleOp{Every: 3},
+			data:   []*xsql.Tuple{tuple("d1", 0), tuple("d1", 1), tuple("d1", 2), tuple("d1", 3), tuple("d1", 4), tuple("d1", 5), tuple("d1", 6)},
+			result: []int64{0, 3, 6},
+		},
+		{ // 1 every by key
+			op:     &SampleOp{Every: 2, Keys: []ast.Expr{&ast.FieldRef{Name: "dev", StreamName: "src"}}},
+			data:   []*xsql.Tuple{tuple("d1", 0), tuple("d2", 1), tuple("d1", 2), tuple("d2", 3), tuple("d1", 4)},
+			result: []int64{0, 1, 4},
+		},
+		{ // 2 all
+			op:     &SampleOp{Percent: 100},
+			data:   []*xsql.Tuple{tuple("d1", 0), tuple("d1", 1)},
+			result: []int64{0, 1},
+		},
+	}
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	contextLogger := conf.Log.WithField("rule", "TestSampleOp")
+	for i, tt := range tests {
+		tempStore, _ := state.CreateStore("mockRule"+strconv.Itoa(i), api.AtMostOnce)
+		ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("mockRule"+strconv.Itoa(i), "sample", tempStore)
+		fv, afv := xsql.NewFunctionValuersForOp(ctx)
+		var r []int64
+		for _, d := range tt.data {
+			switch v := tt.op.Apply(ctx, d, fv, afv).(type) {
+			case nil:
+			case *xsql.Tuple:
+				r = append(r, v.Timestamp)
+			default:
+				t.Fatalf("%d. unexpected result %v", i, v)
+			}
+		}
+		if !reflect.DeepEqual(tt.result, r) {
+			t.Errorf("%d.\n\nresult mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.result, r)
+		}
+	}
+}
+
+func TestSampleOpPercent(t *testing.T) {
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	ctx := context.WithValue(context.Background(), context.LoggerKey, conf.Log.WithField("rule", "TestSampleOpPercent")).WithMeta("mockRule0", "sample", tempStore)
+	fv, afv := xsql.NewFunctionValuersForOp(ctx)
+	op := &SampleOp{Percent: 10}
+	n := 0
+	for i := 0; i < 10000; i++ {
+		if op.Apply(ctx, &xsql.Tuple{Emitter: "src", Message: xsql.Message{"id": i}}, fv, afv) != nil {
+			n++
+		}
+	}
+	if n < 800 || n > 1200 {
+		t.Errorf("expect about 1000 rows sampled but got %d", n)
+	}
+}
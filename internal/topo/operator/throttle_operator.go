@@ -0,0 +1,236 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"encoding/gob"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+const throttleStateKey = "$$throttle"
+
+func init() {
+	gob.Register(&ThrottleState{})
+}
+
+// ThrottleOp limits the rows of each key to Limit per Interval. The policy decides which rows are kept:
+//   - first: the first rows of each interval are emitted immediately and the rest are dropped
+//   - last: the last rows of each interval are emitted when the interval ends
+//   - drop: a row is dropped if Limit rows have been emitted within the last interval, so the limit holds in any sliding interval
+type ThrottleOp struct {
+	Limit int
+	// Interval in milliseconds
+	Interval int64
+	Keys     []ast.Expr
+	Policy   string
+	mu       sync.Mutex
+}
+
+// Apply
+/*
+ *  input: *xsql.Tuple
+ *  output: *xsql.Tuple, []xsql.TupleRow of the last policy when a previous interval ends or nil if the row is held or dropped
+ */
+func (p *ThrottleOp) Apply(ctx api.StreamContext, data interface{}, fv *xsql.FunctionValuer, _ *xsql.AggregateFunctionValuer) interface{} {
+	ctx.GetLogger().Debugf("throttle plan receive %v", data)
+	switch input := data.(type) {
+	case error:
+		return input
+	case *xsql.Tuple:
+		key, err := evalKey(input, fv, p.Keys)
+		if err != nil {
+			return fmt.Errorf("run Throttle error: %s", err)
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		s, err := p.getState(ctx)
+		if err != nil {
+			return err
+		}
+		w := s.Windows[key]
+		switch p.Policy {
+		case ast.ThrottleLast:
+			// The held rows are emitted by processing time, so the interval is also based on processing time
+			ts := conf.GetNowInMilli()
+			var result []xsql.TupleRow
+			if w != nil && ts-w.Start >= p.Interval {
+				result = toTupleRows(w.Rows)
+				w = nil
+			}
+			if w == nil {
+				w = &ThrottleWindow{Start: ts}
+				s.Windows[key] = w
+			}
+			w.Rows = append(w.Rows, input)
+			if len(w.Rows) > p.Limit {
+				w.Rows = w.Rows[len(w.Rows)-p.Limit:]
+			}
+			if len(result) > 0 {
+				return result
+			}
+			return nil
+		case ast.ThrottleDrop:
+			ts := input.GetTimestamp()
+			s.updateLatest(ts)
+			if w == nil {
+				w = &ThrottleWindow{}
+				s.Windows[key] = w
+			}
+			i := 0
+			for i < len(w.Times) && ts-w.Times[i] >= p.Interval {
+				i++
+			}
+			w.Times = w.Times[i:]
+			if len(w.Times) >= p.Limit {
+				return nil
+			}
+			w.Times = append(w.Times, ts)
+			return input
+		default:
+			ts := input.GetTimestamp()
+			s.updateLatest(ts)
+			if w == nil || ts-w.Start >= p.Interval {
+				w = &ThrottleWindow{Start: ts}
+				s.Windows[key] = w
+			}
+			if w.Count >= p.Limit {
+				return nil
+			}
+			w.Count++
+			return input
+		}
+	default:
+		return fmt.Errorf("run Throttle error: invalid input %[1]T(%[1]v)", data)
+	}
+}
+
+func (p *ThrottleOp) TickInterval() int64 {
+	return tickInterval(p.Interval)
+}
+
+// OnTick emits the held rows of the ended intervals for the last policy. For other policies, it removes the
+// ended intervals so that the state does not grow with the keys.
+func (p *ThrottleOp) OnTick(ctx api.StreamContext, now int64) interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, err := p.getState(ctx)
+	if err != nil {
+		return err
+	}
+	// Only the last policy is based on processing time. Others are based on the timestamp of the rows which may be event time.
+	if p.Policy != ast.ThrottleLast {
+		now = s.Latest
+	}
+	var ended []*ThrottleWindow
+	for k, w := range s.Windows {
+		last := w.Start
+		if len(w.Times) > 0 {
+			last = w.Times[len(w.Times)-1]
+		}
+		if now-last >= p.Interval {
+			ended = append(ended, w)
+			delete(s.Windows, k)
+		}
+	}
+	if p.Policy != ast.ThrottleLast || len(ended) == 0 {
+		return nil
+	}
+	sort.SliceStable(ended, func(i, j int) bool {
+		return ended[i].Start < ended[j].Start
+	})
+	var result []xsql.TupleRow
+	for _, w := range ended {
+		result = append(result, toTupleRows(w.Rows)...)
+	}
+	return result
+}
+
+func (p *ThrottleOp) getState(ctx api.StreamContext) (*ThrottleState, error) {
+	v, err := getLockedState(ctx, throttleStateKey, &p.mu, func() stateCloner { return &ThrottleState{} })
+	if err != nil {
+		return nil, fmt.Errorf("run Throttle error: fail to get state %v", err)
+	}
+	s := v.(*ThrottleState)
+	if s.Windows == nil {
+		s.Windows = make(map[string]*ThrottleWindow)
+	}
+	return s, nil
+}
+
+// ThrottleState is the current interval of each key
+type ThrottleState struct {
+	Windows map[string]*ThrottleWindow
+	// Latest timestamp of the rows
+	Latest int64
+}
+
+func (s *ThrottleState) clone() interface{} {
+	c := &ThrottleState{Latest: s.Latest}
+	if s.Windows != nil {
+		c.Windows = make(map[string]*ThrottleWindow, len(s.Windows))
+		for k, w := range s.Windows {
+			c.Windows[k] = &ThrottleWindow{
+				Start: w.Start,
+				Count: w.Count,
+				Times: append([]int64(nil), w.Times...),
+				Rows:  append([]*xsql.Tuple(nil), w.Rows...),
+			}
+		}
+	}
+	return c
+}
+
+func (s *ThrottleState) updateLatest(ts int64) {
+	if ts > s.Latest {
+		s.Latest = ts
+	}
+}
+
+type ThrottleWindow struct {
+	Start int64
+	// Count of the emitted rows for the first policy
+	Count int
+	// Times of the emitted rows within the sliding interval for the drop policy
+	Times []int64
+	// Rows held until the interval ends for the last policy
+	Rows []*xsql.Tuple
+}
+
+func toTupleRows(rows []*xsql.Tuple) []xsql.TupleRow {
+	result := make([]xsql.TupleRow, len(rows))
+	for i, r := range rows {
+		result[i] = r
+	}
+	return result
+}
+
+// tickInterval is the interval to check the delayed rows. The rows are emitted at most a tenth of the duration late.
+func tickInterval(d int64) int64 {
+	t := d / 10
+	if t < 10 {
+		t = 10
+	}
+	if t > 1000 {
+		t = 1000
+	}
+	return t
+}
@@ -0,0 +1,123 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/topo/state"
+	"github.com/lf-edge/ekuiper/internal/topo/topotest/mockclock"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestThrottleOp(t *testing.T) {
+	tuple := func(dev string, ts int64) *xsql.Tuple {
+		return &xsql.Tuple{Emitter: "src", Message: xsql.Message{"dev": dev}, Timestamp: ts}
+	}
+	tests := []struct {
+		op     *ThrottleOp
+		data   []*xsql.Tuple
+		result []int64
+	}{
+		{ // 0 first
+			op:     &ThrottleOp{Limit: 2, Interval: 100, Policy: ast.ThrottleFirst},
+			data:   []*xsql.Tuple{tuple("d1", 0), tuple("d1", 10), tuple("d1", 20), tuple("d1", 100), tuple("d1", 150), tuple("d1", 210)},
+			result: []int64{0, 10, 100, 150, 210},
+		},
+		{ // 1 drop in sliding interval
+			op:     &ThrottleOp{Limit: 2, Interval: 100, Policy: ast.ThrottleDrop},
+			data:   []*xsql.Tuple{tuple("d1", 0), tuple("d1", 10), tuple("d1", 20), tuple("d1", 100), tuple("d1", 105), tuple("d1", 110)},
+			result: []int64{0, 10, 100, 110},
+		},
+		{ // 2 keys
+			op:     &ThrottleOp{Limit: 1, Interval: 100, Policy: ast.ThrottleFirst, Keys: []ast.Expr{&ast.FieldRef{Name: "dev", StreamName: "src"}}},
+			data:   []*xsql.Tuple{tuple("d1", 0), tuple("d2", 1), tuple("d1", 2), tuple("d2", 3)},
+			result: []int64{0, 1},
+		},
+	}
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	contextLogger := conf.Log.WithField("rule", "TestThrottleOp")
+	for i, tt := range tests {
+		tempStore, _ := state.CreateStore("mockRule"+strconv.Itoa(i), api.AtMostOnce)
+		ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger).WithMeta("mockRule"+strconv.Itoa(i), "throttle", tempStore)
+		fv, afv := xsql.NewFunctionValuersForOp(ctx)
+		var r []int64
+		for _, d := range tt.data {
+			switch v := tt.op.Apply(ctx, d, fv, afv).(type) {
+			case nil:
+			case *xsql.Tuple:
+				r = append(r, v.Timestamp)
+			default:
+				t.Fatalf("%d. unexpected result %v", i, v)
+			}
+		}
+		if !reflect.DeepEqual(tt.result, r) {
+			t.Errorf("%d.\n\nresult mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.result, r)
+		}
+	}
+}
+
+func TestThrottleOpLast(t *testing.T) {
+	mockclock.ResetClock(0)
+	mc := mockclock.GetMockClock()
+	tempStore, _ := state.CreateStore("mockRule0", api.AtMostOnce)
+	ctx := context.WithValue(context.Background(), context.LoggerKey, conf.Log.WithField("rule", "TestThrottleOpLast")).WithMeta("mockRule0", "throttle", tempStore)
+	fv, afv := xsql.NewFunctionValuersForOp(ctx)
+	op := &ThrottleOp{Limit: 1, Interval: 100, Policy: ast.ThrottleLast}
+	tuple := func(id int) *xsql.Tuple {
+		return &xsql.Tuple{Emitter: "src", Message: xsql.Message{"id": id}, Timestamp: conf.GetNowInMilli()}
+	}
+	ids := func(result interface{}) []int {
+		rows, ok := result.([]xsql.TupleRow)
+		if !ok {
+			t.Fatalf("unexpected result %v", result)
+		}
+		var r []int
+		for _, row := range rows {
+			v, _ := row.Value("id", "")
+			r = append(r, v.(int))
+		}
+		return r
+	}
+
+	if r := op.Apply(ctx, tuple(1), fv, afv); r != nil {
+		t.Fatalf("expect the row to be held but got %v", r)
+	}
+	mc.Set(mc.Now().Add(10 * time.Millisecond))
+	if r := op.Apply(ctx, tuple(2), fv, afv); r != nil {
+		t.Fatalf("expect the row to be held but got %v", r)
+	}
+	if r := op.OnTick(ctx, 50); r != nil {
+		t.Fatalf("expect nothing before the interval ends but got %v", r)
+	}
+	if r := ids(op.OnTick(ctx, 100)); !reflect.DeepEqual([]int{2}, r) {
+		t.Errorf("expect the last row 2 but got %v", r)
+	}
+	// The interval ends before the next tick
+	mc.Set(mc.Now().Add(110 * time.Millisecond))
+	_ = op.Apply(ctx, tuple(3), fv, afv)
+	mc.Set(mc.Now().Add(110 * time.Millisecond))
+	if r := ids(op.Apply(ctx, tuple(4), fv, afv)); !reflect.DeepEqual([]int{3}, r) {
+		t.Errorf("expect the last row 3 but got %v", r)
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/lf-edge/ekuiper/internal/binder/function"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// WindowFuncsOp calculates the window functions like rank over the rows of a window and saves the results in each row
+type WindowFuncsOp struct {
+	Funcs []*ast.Call
+}
+
+// windowFuncRow is the evaluated values of a row for a window function call
+type windowFuncRow struct {
+	key   string
+	sorts []interface{}
+	args  []interface{}
+}
+
+/**
+ *  input: xsql.WindowTuples | xsql.JoinTuples | xsql.GroupedTuplesSet
+ *  output: the input with the window function results set in each row
+ */
+func (p *WindowFuncsOp) Apply(ctx api.StreamContext, data interface{}, fv *xsql.FunctionValuer, afv *xsql.AggregateFunctionValuer) interface{} {
+	ctx.GetLogger().Debugf("WindowFuncsOp receive: %s", data)
+	switch input := data.(type) {
+	case error:
+		return input
+	case xsql.Collection:
+		_, isSingle := input.(xsql.SingleCollection)
+		var (
+			rows   []xsql.Row
+			values = make([][]*windowFuncRow, len(p.Funcs))
+		)
+		err := input.RangeSet(func(_ int, row xsql.Row) (bool, error) {
+			var ve *xsql.ValuerEval
+			if isSingle {
+				ve = &xsql.ValuerEval{Valuer: xsql.MultiValuer(row, &xsql.WindowRangeValuer{WindowRange: input.GetWindowRange()}, fv, &xsql.WildcardValuer{Data: row})}
+			} else {
+				aggRow, ok := row.(xsql.CollectionRow)
+				if !ok {
+					return false, fmt.Errorf("invalid group %[1]T(%[1]v)", row)
+				}
+				afv.SetData(aggRow)
+				ve = &xsql.ValuerEval{Valuer: xsql.MultiAggregateValuer(aggRow, fv, aggRow, fv, afv, &xsql.WildcardValuer{Data: aggRow})}
+			}
+			for i, f := range p.Funcs {
+				r, err := evalWindowFuncRow(ve, f)
+				if err != nil {
+					return false, err
+				}
+				values[i] = append(values[i], r)
+			}
+			rows = append(rows, row)
+			return true, nil
+		})
+		if err != nil {
+			return fmt.Errorf("run window functions error: %v", err)
+		}
+		for i, f := range p.Funcs {
+			if err := calWindowFunc(f, values[i], rows); err != nil {
+				return fmt.Errorf("run window functions error: %v", err)
+			}
+		}
+	default:
+		return fmt.Errorf("run window functions error: invalid input %[1]T(%[1]v)", input)
+	}
+	return data
+}
+
+func evalWindowFuncRow(ve *xsql.ValuerEval, f *ast.Call) (*windowFuncRow, error) {
+	r := &windowFuncRow{
+		sorts: make([]interface{}, len(f.SortFields)),
+		args:  make([]interface{}, len(f.Args)),
+	}
+	if f.Partition != nil {
+		for _, pe := range f.Partition.Exprs {
+			temp := ve.Eval(pe)
+			if e, ok := temp.(error); ok {
+				return nil, e
+			}
+			r.key += fmt.Sprintf("%v", temp)
+		}
+	}
+	for i, sf := range f.SortFields {
+		temp := ve.Eval(sf.FieldExpr)
+		if e, ok := temp.(error); ok {
+			return nil, e
+		}
+		r.sorts[i] = temp
+	}
+	for i, arg := range f.Args {
+		temp := ve.Eval(arg)
+		if e, ok := temp.(error); ok {
+			return nil, e
+		}
+		r.args[i] = temp
+	}
+	return r, nil
+}
+
+// calWindowFunc sorts each partition by the order by of the call and sets the results to the rows
+func calWindowFunc(f *ast.Call, values []*windowFuncRow, rows []xsql.Row) error {
+	var (
+		keys       []string
+		partitions = make(map[string][]int)
+	)
+	for i, v := range values {
+		if _, ok := partitions[v.key]; !ok {
+			keys = append(keys, v.key)
+		}
+		partitions[v.key] = append(partitions[v.key], i)
+	}
+	// compare returns -1, 0 or 1 like the order by clause. Null values are ordered at last.
+	compare := func(i, j int) int {
+		for k, sf := range f.SortFields {
+			vi, vj := values[i].sorts[k], values[j].sorts[k]
+			c := 0
+			switch {
+			case vi == nil && vj == nil:
+				continue
+			case vi == nil:
+				return 1
+			case vj == nil:
+				return -1
+			case xsql.LessValue(vi, vj):
+				c = -1
+			case xsql.LessValue(vj, vi):
+				c = 1
+			}
+			if !sf.Ascending {
+				c = -c
+			}
+			if c != 0 {
+				return c
+			}
+		}
+		return 0
+	}
+	for _, key := range keys {
+		indexes := partitions[key]
+		sort.SliceStable(indexes, func(i, j int) bool {
+			return compare(indexes[i], indexes[j]) < 0
+		})
+		args := make([][]interface{}, len(indexes))
+		peers := make([]bool, len(indexes))
+		for i, index := range indexes {
+			args[i] = values[index].args
+			peers[i] = i > 0 && compare(indexes[i-1], index) == 0
+		}
+		results, err := function.ExecWindowFunc(f.Name, args, peers)
+		if err != nil {
+			return err
+		}
+		for i, index := range indexes {
+			rows[index].Set(f.CachedField, results[i])
+		}
+	}
+	return nil
+}
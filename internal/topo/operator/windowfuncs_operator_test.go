@@ -0,0 +1,135 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package operator
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/binder/function"
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestWindowFuncs(t *testing.T) {
+	tests := []struct {
+		sql    string
+		data   xsql.Collection
+		result []map[string]interface{}
+	}{
+		{
+			sql: `SELECT row_number() OVER (PARTITION BY g ORDER BY d DESC), rank() OVER (ORDER BY d), dense_rank() OVER (ORDER BY d),
+				first_value(m) OVER (PARTITION BY g ORDER BY d DESC), nth_value(m, 2) OVER (ORDER BY d), last_value(m) OVER (PARTITION BY g) FROM src1 GROUP BY TumblingWindow(ss, 10)`,
+			data: &xsql.WindowTuples{
+				Content: []xsql.TupleRow{
+					&xsql.Tuple{
+						Emitter: "src1",
+						Message: xsql.Message{"m": "a", "g": "x", "d": 10},
+					}, &xsql.Tuple{
+						Emitter: "src1",
+						Message: xsql.Message{"m": "b", "g": "x", "d": 30},
+					}, &xsql.Tuple{
+						Emitter: "src1",
+						Message: xsql.Message{"m": "c", "g": "y", "d": 10},
+					}, &xsql.Tuple{
+						Emitter: "src1",
+						Message: xsql.Message{"m": "d", "g": "x", "d": 10},
+					}, &xsql.Tuple{
+						Emitter: "src1",
+						Message: xsql.Message{"m": "e", "g": "y"},
+					},
+				},
+			},
+			result: []map[string]interface{}{
+				{"$$w_row_number_0": 2, "$$w_rank_1": 1, "$$w_dense_rank_2": 1, "$$w_first_value_3": "b", "$$w_nth_value_4": "c", "$$w_last_value_5": "d"},
+				{"$$w_row_number_0": 1, "$$w_rank_1": 4, "$$w_dense_rank_2": 2, "$$w_first_value_3": "b", "$$w_nth_value_4": "c", "$$w_last_value_5": "d"},
+				{"$$w_row_number_0": 1, "$$w_rank_1": 1, "$$w_dense_rank_2": 1, "$$w_first_value_3": "c", "$$w_nth_value_4": "c", "$$w_last_value_5": "e"},
+				{"$$w_row_number_0": 3, "$$w_rank_1": 1, "$$w_dense_rank_2": 1, "$$w_first_value_3": "b", "$$w_nth_value_4": "c", "$$w_last_value_5": "d"},
+				{"$$w_row_number_0": 2, "$$w_rank_1": 5, "$$w_dense_rank_2": 3, "$$w_first_value_3": "c", "$$w_nth_value_4": "c", "$$w_last_value_5": "e"},
+			},
+		}, {
+			sql: `SELECT g, rank() OVER (ORDER BY sum(d) DESC), nth_value(g, 3) OVER () FROM src1 GROUP BY g, TumblingWindow(ss, 10)`,
+			data: &xsql.GroupedTuplesSet{
+				Groups: []*xsql.GroupedTuples{
+					{
+						Content: []xsql.TupleRow{
+							&xsql.Tuple{
+								Emitter: "src1",
+								Message: xsql.Message{"m": "a", "g": "x", "d": 10},
+							}, &xsql.Tuple{
+								Emitter: "src1",
+								Message: xsql.Message{"m": "b", "g": "x", "d": 30},
+							},
+						},
+					}, {
+						Content: []xsql.TupleRow{
+							&xsql.Tuple{
+								Emitter: "src1",
+								Message: xsql.Message{"m": "c", "g": "y", "d": 50},
+							},
+						},
+					},
+				},
+			},
+			result: []map[string]interface{}{
+				{"$$w_rank_0": 2, "$$w_nth_value_2": nil},
+				{"$$w_rank_0": 1, "$$w_nth_value_2": nil},
+			},
+		},
+	}
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	contextLogger := conf.Log.WithField("rule", "TestWindowFuncs")
+	ctx := context.WithValue(context.Background(), context.LoggerKey, contextLogger)
+	for i, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader(tt.sql)).Parse()
+		if err != nil {
+			t.Errorf("%d. statement %s parse error %s", i, tt.sql, err)
+			continue
+		}
+		var funcs []*ast.Call
+		ast.WalkFunc(stmt.Fields, func(n ast.Node) bool {
+			if c, ok := n.(*ast.Call); ok && function.IsWindowFunc(c.Name) {
+				c.CachedField = fmt.Sprintf("%s_%s_%d", function.WindowFuncPrefix, c.Name, c.FuncId)
+				funcs = append(funcs, c)
+			}
+			return true
+		})
+		pp := &WindowFuncsOp{Funcs: funcs}
+		fv, afv := xsql.NewFunctionValuersForOp(nil)
+		opResult := pp.Apply(ctx, tt.data, fv, afv)
+		if e, ok := opResult.(error); ok {
+			t.Errorf("%d. apply error %v", i, e)
+			continue
+		}
+		var r []map[string]interface{}
+		switch c := opResult.(type) {
+		case *xsql.WindowTuples:
+			for _, row := range c.Content {
+				r = append(r, row.(*xsql.Tuple).CalCols)
+			}
+		case *xsql.GroupedTuplesSet:
+			for _, g := range c.Groups {
+				r = append(r, g.CalCols)
+			}
+		}
+		if !reflect.DeepEqual(tt.result, r) {
+			t.Errorf("%d.\n\nresult mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.result, r)
+		}
+	}
+}
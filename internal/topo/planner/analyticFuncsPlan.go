@@ -14,7 +14,12 @@
 
 package planner
 
-import "github.com/lf-edge/ekuiper/pkg/ast"
+import (
+	"errors"
+
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
 
 type AnalyticFuncsPlan struct {
 	baseLogicalPlan
@@ -38,3 +43,20 @@ func (p *AnalyticFuncsPlan) PruneColumns(fields []ast.Expr) error {
 	}
 	return p.baseLogicalPlan.PruneColumns(fields)
 }
+
+// validateAnalyticFuncs checks the analytic functions against the rule option. The time range of moving_avg is
+// measured by the processing time when the function runs, so it is not supported for event time.
+func validateAnalyticFuncs(funcs []*ast.Call, opt *api.RuleOption) error {
+	if !opt.IsEventTime {
+		return nil
+	}
+	for _, f := range funcs {
+		if f.Name != "moving_avg" || len(f.Args) < 2 {
+			continue
+		}
+		if _, ok := f.Args[1].(*ast.IntervalLiteral); ok {
+			return errors.New("moving_avg with time range only supports processing time, use row range instead for event time")
+		}
+	}
+	return nil
+}
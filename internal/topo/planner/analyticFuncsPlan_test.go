@@ -0,0 +1,63 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestValidateAnalyticFuncs(t *testing.T) {
+	tests := []struct {
+		sql       string
+		eventTime bool
+		err       error
+	}{
+		{
+			sql: `SELECT moving_avg(size, 5 MINUTES) FROM demo`,
+		}, {
+			sql:       `SELECT moving_avg(size, 10 ROWS) FROM demo`,
+			eventTime: true,
+		}, {
+			sql:       `SELECT moving_avg(size, 5 MINUTES) FROM demo`,
+			eventTime: true,
+			err:       errors.New("moving_avg with time range only supports processing time, use row range instead for event time"),
+		},
+	}
+	for i, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader(tt.sql)).Parse()
+		if err != nil {
+			t.Errorf("%d. parse error: %v", i, err)
+			continue
+		}
+		var funcs []*ast.Call
+		ast.WalkFunc(stmt, func(n ast.Node) bool {
+			if c, ok := n.(*ast.Call); ok && c.Name == "moving_avg" {
+				funcs = append(funcs, c)
+			}
+			return true
+		})
+		err = validateAnalyticFuncs(funcs, &api.RuleOption{IsEventTime: tt.eventTime})
+		if !reflect.DeepEqual(tt.err, err) {
+			t.Errorf("%d. %s: error mismatch:\n  exp=%v\n  got=%v", i, tt.sql, tt.err, err)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+const vecPrefix = "$$vec_"
+
+// ColumnarBatchPlan filters the rows and calculates the select expressions in columnar batches before the window
+type ColumnarBatchPlan struct {
+	baseLogicalPlan
+	condition ast.Expr
+	// projections are the select expressions calculated in the batches. The results are cached in the rows.
+	projections []*ast.BinaryExpr
+	types       map[string]ast.DataType
+	batchSize   int
+	linger      int
+}
+
+func (p ColumnarBatchPlan) Init() *ColumnarBatchPlan {
+	p.baseLogicalPlan.self = &p
+	return &p
+}
+
+func (p *ColumnarBatchPlan) PruneColumns(fields []ast.Expr) error {
+	f := getFields(p.condition)
+	return p.baseLogicalPlan.PruneColumns(append(fields, f...))
+}
+
+// columnarBatch inserts the columnar batch between the source and a processing time window if the rule enables the
+// columnar batch. The filter is replaced if the condition can be vectorized with the stream schema, and the select
+// expressions which can be vectorized are calculated in the batches too. Otherwise, the rows are processed one by one as before.
+type columnarBatch struct{}
+
+func (r *columnarBatch) optimize(lp LogicalPlan) (LogicalPlan, error) {
+	r.apply(lp)
+	return lp, nil
+}
+
+func (r *columnarBatch) apply(lp LogicalPlan) {
+	if wp, ok := lp.(*WindowPlan); ok && wp.columnarBatchSize > 0 && !wp.isEventTime && (wp.wtype == ast.TUMBLING_WINDOW || wp.wtype == ast.HOPPING_WINDOW) && len(wp.Children()) == 1 {
+		var (
+			condition ast.Expr
+			ds        *DataSourcePlan
+			types     = make(map[string]ast.DataType)
+		)
+		switch c := wp.Children()[0].(type) {
+		case *FilterPlan:
+			if len(c.Children()) == 1 {
+				if d, ok := c.Children()[0].(*DataSourcePlan); ok && !d.isSchemaless && vectorTypes(c.condition, d.streamFields, types, ast.BOOLEAN) {
+					condition, ds = c.condition, d
+				}
+			}
+		case *DataSourcePlan:
+			if !c.isSchemaless {
+				ds = c
+			}
+		}
+		if ds != nil {
+			projections := vectorProjections(wp.fields, ds.streamFields, types)
+			if condition != nil || len(projections) > 0 {
+				p := ColumnarBatchPlan{
+					condition:   condition,
+					projections: projections,
+					types:       types,
+					batchSize:   wp.columnarBatchSize,
+					linger:      wp.columnarLinger,
+				}.Init()
+				p.SetChildren([]LogicalPlan{ds})
+				wp.SetChildren([]LogicalPlan{p})
+			}
+		}
+	}
+	for _, child := range lp.Children() {
+		r.apply(child)
+	}
+}
+
+func (r *columnarBatch) name() string {
+	return "columnarBatch"
+}
+
+// vectorProjections finds the outermost binary expressions in the select fields which can be vectorized and marks
+// them to be cached, so that the project only reads the results calculated in the batches
+func vectorProjections(fields ast.Fields, schema map[string]*ast.JsonStreamField, types map[string]ast.DataType) []*ast.BinaryExpr {
+	var result []*ast.BinaryExpr
+	for _, field := range fields {
+		ast.WalkFunc(field.Expr, func(n ast.Node) bool {
+			if be, ok := n.(*ast.BinaryExpr); ok && vectorTypes(be, schema, types, ast.UNKNOWN) {
+				be.CachedField = fmt.Sprintf("%s%d", vecPrefix, len(result))
+				be.Cached = true
+				result = append(result, be)
+				return false
+			}
+			return true
+		})
+	}
+	return result
+}
+
+// vectorTypes resolves the types of the fields in the expression by the schema and checks if the expression can be
+// evaluated over the typed columns. If the expected type is not UNKNOWN, the expression must return that type.
+// The resolved types are added to the types map only if the expression can be vectorized.
+func vectorTypes(expr ast.Expr, schema map[string]*ast.JsonStreamField, types map[string]ast.DataType, expected ast.DataType) bool {
+	exprTypes, found := resolveFieldTypes(expr, schema)
+	if !found {
+		return false
+	}
+	if t, ok := xsql.VectorType(expr, exprTypes); !ok || (expected != ast.UNKNOWN && t != expected) {
+		return false
+	}
+	for k, v := range exprTypes {
+		types[k] = v
+	}
+	return true
+}
+
+// resolveFieldTypes resolves the types of all the fields in the expression by the schema. It returns false if any
+// field is not in the schema.
+func resolveFieldTypes(expr ast.Expr, schema map[string]*ast.JsonStreamField) (map[string]ast.DataType, bool) {
+	exprTypes := make(map[string]ast.DataType)
+	found := true
+	ast.WalkFunc(expr, func(n ast.Node) bool {
+		if f, ok := n.(*ast.FieldRef); ok {
+			var sf *ast.JsonStreamField
+			for k, v := range schema {
+				if strings.EqualFold(k, f.Name) {
+					sf = v
+					break
+				}
+			}
+			if sf == nil {
+				found = false
+				return false
+			}
+			exprTypes[f.Name] = ast.GetDataType(sf.Type)
+		}
+		return found
+	})
+	return exprTypes, found
+}
@@ -0,0 +1,160 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestColumnarBatch(t *testing.T) {
+	schema := map[string]*ast.JsonStreamField{
+		"id":   {Type: "bigint"},
+		"temp": {Type: "float"},
+		"name": {Type: "string"},
+		"ts":   {Type: "datetime"},
+	}
+	tests := []struct {
+		cond      string
+		wtype     ast.WindowType
+		batchSize int
+		types     map[string]ast.DataType
+	}{
+		{
+			cond:      "temp * 1.8 + 32 > 100 AND name = \"a\"",
+			wtype:     ast.TUMBLING_WINDOW,
+			batchSize: 128,
+			types:     map[string]ast.DataType{"temp": ast.FLOAT, "name": ast.STRINGS},
+		}, {
+			cond:      "ID % 2 = 0",
+			wtype:     ast.HOPPING_WINDOW,
+			batchSize: 128,
+			types:     map[string]ast.DataType{"ID": ast.BIGINT},
+		}, {
+			cond:  "temp > 100",
+			wtype: ast.TUMBLING_WINDOW,
+		}, {
+			cond:      "temp > 100",
+			wtype:     ast.SLIDING_WINDOW,
+			batchSize: 128,
+		}, {
+			cond:      "abs(temp) > 100",
+			wtype:     ast.TUMBLING_WINDOW,
+			batchSize: 128,
+		}, {
+			cond:      "ts > 100",
+			wtype:     ast.TUMBLING_WINDOW,
+			batchSize: 128,
+		},
+	}
+	for i, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader("SELECT * FROM src WHERE " + tt.cond)).Parse()
+		if err != nil {
+			t.Errorf("%d. parse error: %v", i, err)
+			continue
+		}
+		ds := DataSourcePlan{streamFields: schema}.Init()
+		fp := FilterPlan{condition: stmt.Condition}.Init()
+		fp.SetChildren([]LogicalPlan{ds})
+		wp := WindowPlan{wtype: tt.wtype, columnarBatchSize: tt.batchSize}.Init()
+		wp.SetChildren([]LogicalPlan{fp})
+		_, _ = (&columnarBatch{}).optimize(wp)
+		cp, ok := wp.Children()[0].(*ColumnarBatchPlan)
+		if ok != (tt.types != nil) {
+			t.Errorf("%d. %s: expect columnar %v but got %v", i, tt.cond, tt.types != nil, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if !reflect.DeepEqual(tt.types, cp.types) {
+			t.Errorf("%d. %s: types mismatch:\n  exp=%v\n  got=%v", i, tt.cond, tt.types, cp.types)
+		}
+		if cp.batchSize != tt.batchSize || cp.Children()[0] != ds {
+			t.Errorf("%d. %s: invalid columnar batch plan %v", i, tt.cond, cp)
+		}
+	}
+}
+
+func TestColumnarBatchProjection(t *testing.T) {
+	schema := map[string]*ast.JsonStreamField{
+		"id":   {Type: "bigint"},
+		"temp": {Type: "float"},
+		"name": {Type: "string"},
+	}
+	tests := []struct {
+		sql string
+		// the operators of the outermost vectorized expressions
+		projections []ast.Token
+		types       map[string]ast.DataType
+	}{
+		{
+			sql:         "SELECT temp * 1.8 + 32 AS f, avg(id % 10), name FROM src GROUP BY TUMBLINGWINDOW(ss, 10)",
+			projections: []ast.Token{ast.ADD, ast.MOD},
+			types:       map[string]ast.DataType{"temp": ast.FLOAT, "id": ast.BIGINT},
+		}, {
+			sql:         "SELECT abs(temp - 1), name + 1 FROM src WHERE id > 2 GROUP BY TUMBLINGWINDOW(ss, 10)",
+			projections: []ast.Token{ast.SUB},
+			types:       map[string]ast.DataType{"temp": ast.FLOAT, "id": ast.BIGINT},
+		}, {
+			sql: "SELECT abs(temp), name FROM src GROUP BY TUMBLINGWINDOW(ss, 10)",
+		},
+	}
+	for i, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader(tt.sql)).Parse()
+		if err != nil {
+			t.Errorf("%d. parse error: %v", i, err)
+			continue
+		}
+		var child LogicalPlan = DataSourcePlan{streamFields: schema}.Init()
+		if stmt.Condition != nil {
+			fp := FilterPlan{condition: stmt.Condition}.Init()
+			fp.SetChildren([]LogicalPlan{child})
+			child = fp
+		}
+		wp := WindowPlan{wtype: ast.TUMBLING_WINDOW, columnarBatchSize: 64, fields: stmt.Fields}.Init()
+		wp.SetChildren([]LogicalPlan{child})
+		_, _ = (&columnarBatch{}).optimize(wp)
+		cp, ok := wp.Children()[0].(*ColumnarBatchPlan)
+		if ok != (tt.types != nil) {
+			t.Errorf("%d. %s: expect columnar %v but got %v", i, tt.sql, tt.types != nil, ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		var projections []ast.Token
+		for j, p := range cp.projections {
+			projections = append(projections, p.OP)
+			if !p.Cached || p.CachedField != fmt.Sprintf("$$vec_%d", j) {
+				t.Errorf("%d. projection %d is not cached", i, j)
+			}
+		}
+		if !reflect.DeepEqual(tt.projections, projections) {
+			t.Errorf("%d. %s: projections mismatch:\n  exp=%v\n  got=%v", i, tt.sql, tt.projections, projections)
+		}
+		if !reflect.DeepEqual(tt.types, cp.types) {
+			t.Errorf("%d. %s: types mismatch:\n  exp=%v\n  got=%v", i, tt.sql, tt.types, cp.types)
+		}
+		if (stmt.Condition != nil) != (cp.condition != nil) {
+			t.Errorf("%d. %s: condition mismatch %v", i, tt.sql, cp.condition)
+		}
+	}
+}
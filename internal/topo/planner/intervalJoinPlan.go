@@ -0,0 +1,174 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// IntervalJoinPlan joins two streams without window. The rows of the right stream are joined
+// if their time is in the interval relative to the time of the left row.
+type IntervalJoinPlan struct {
+	baseLogicalPlan
+	from *ast.Table
+	join ast.Join
+	// keys are the equi-join expressions of each side
+	leftKeys  []ast.Expr
+	rightKeys []ast.Expr
+	leftTime  ast.Expr
+	rightTime ast.Expr
+	// lower and upper bound the right time relative to the left time in milliseconds
+	lower int64
+	upper int64
+}
+
+// Init must run validateAndExtractCondition before this func
+func (p IntervalJoinPlan) Init() *IntervalJoinPlan {
+	p.baseLogicalPlan.self = &p
+	return &p
+}
+
+func (p *IntervalJoinPlan) PushDownPredicate(condition ast.Expr) (ast.Expr, LogicalPlan) {
+	// Only push down the single source conditions for inner join. For outer join, the filter must run after the null rows are emitted.
+	if p.join.JoinType != ast.INNER_JOIN {
+		return condition, p.self
+	}
+	multipleSourcesCondition, singleSourceCondition := extractCondition(condition)
+	rest, _ := p.baseLogicalPlan.PushDownPredicate(singleSourceCondition)
+	return combine(multipleSourcesCondition, rest), p.self
+}
+
+func (p *IntervalJoinPlan) PruneColumns(fields []ast.Expr) error {
+	f := getFields(p.join.Expr)
+	return p.baseLogicalPlan.PruneColumns(append(fields, f...))
+}
+
+func streamNames(name, alias string) map[ast.StreamName]struct{} {
+	m := map[ast.StreamName]struct{}{ast.StreamName(name): {}}
+	if alias != "" {
+		m[ast.StreamName(alias)] = struct{}{}
+	}
+	return m
+}
+
+// refSide returns 0 if the expr only refers to the left stream, 1 for the right stream or -1 otherwise
+func refSide(expr ast.Expr, left, right map[ast.StreamName]struct{}) int {
+	srcs, hasDefault := getRefSources(expr)
+	if hasDefault || len(srcs) != 1 {
+		return -1
+	}
+	if _, ok := left[srcs[0]]; ok {
+		return 0
+	}
+	if _, ok := right[srcs[0]]; ok {
+		return 1
+	}
+	return -1
+}
+
+// splitBound splits the bound like a.ts - INTERVAL 5s to the base expression and the offset in milliseconds
+func splitBound(expr ast.Expr) (ast.Expr, int64) {
+	if pe, ok := expr.(*ast.ParenExpr); ok {
+		return splitBound(pe.Expr)
+	}
+	if be, ok := expr.(*ast.BinaryExpr); ok && (be.OP == ast.ADD || be.OP == ast.SUB) {
+		var d int64
+		switch v := be.RHS.(type) {
+		case *ast.IntervalLiteral:
+			d = v.ToMilli()
+		case *ast.IntegerLiteral:
+			d = int64(v.Val)
+		default:
+			return expr, 0
+		}
+		if be.OP == ast.SUB {
+			d = -d
+		}
+		return be.LHS, d
+	}
+	return expr, 0
+}
+
+// validateAndExtractCondition finds the time bound and the equi-join keys in the join condition.
+// The time bound must be like b.ts BETWEEN a.ts - INTERVAL 5s AND a.ts + INTERVAL 5s
+func (p *IntervalJoinPlan) validateAndExtractCondition() error {
+	switch p.join.JoinType {
+	case ast.INNER_JOIN, ast.LEFT_JOIN, ast.RIGHT_JOIN, ast.FULL_JOIN:
+	default:
+		return fmt.Errorf("interval join only supports inner, left, right and full join")
+	}
+	left := streamNames(p.from.Name, p.from.Alias)
+	right := streamNames(p.join.Name, p.join.Alias)
+	var conditions []ast.Expr
+	var flat func(e ast.Expr)
+	flat = func(e ast.Expr) {
+		if be, ok := e.(*ast.BinaryExpr); ok && be.OP == ast.AND {
+			flat(be.LHS)
+			flat(be.RHS)
+			return
+		}
+		conditions = append(conditions, e)
+	}
+	flat(p.join.Expr)
+	found := false
+	for _, c := range conditions {
+		be, ok := c.(*ast.BinaryExpr)
+		if !ok {
+			continue
+		}
+		switch be.OP {
+		case ast.EQ:
+			ls, rs := refSide(be.LHS, left, right), refSide(be.RHS, left, right)
+			if ls == 0 && rs == 1 {
+				p.leftKeys = append(p.leftKeys, be.LHS)
+				p.rightKeys = append(p.rightKeys, be.RHS)
+			} else if ls == 1 && rs == 0 {
+				p.leftKeys = append(p.leftKeys, be.RHS)
+				p.rightKeys = append(p.rightKeys, be.LHS)
+			}
+		case ast.BETWEEN:
+			if found {
+				continue
+			}
+			bt, ok := be.RHS.(*ast.BetweenExpr)
+			if !ok {
+				continue
+			}
+			lb, lo := splitBound(bt.Lower)
+			hb, ho := splitBound(bt.Higher)
+			if !reflect.DeepEqual(lb, hb) || lo > ho {
+				continue
+			}
+			ts, bs := refSide(be.LHS, left, right), refSide(lb, left, right)
+			if ts == 1 && bs == 0 {
+				// right.ts BETWEEN left.ts + lo AND left.ts + ho
+				p.leftTime, p.rightTime, p.lower, p.upper = lb, be.LHS, lo, ho
+				found = true
+			} else if ts == 0 && bs == 1 {
+				// left.ts BETWEEN right.ts + lo AND right.ts + ho, so right.ts is in [left.ts - ho, left.ts - lo]
+				p.leftTime, p.rightTime, p.lower, p.upper = be.LHS, lb, -ho, -lo
+				found = true
+			}
+		}
+	}
+	if !found {
+		return errors.New("a time window or count window is required to join multiple streams, or a time bound like b.ts BETWEEN a.ts - INTERVAL 5s AND a.ts + INTERVAL 5s for interval join")
+	}
+	return nil
+}
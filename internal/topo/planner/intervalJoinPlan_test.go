@@ -0,0 +1,101 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/testx"
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestIntervalJoinExtract(t *testing.T) {
+	tests := []struct {
+		sql       string
+		leftKeys  []ast.Expr
+		rightKeys []ast.Expr
+		leftTime  ast.Expr
+		rightTime ast.Expr
+		lower     int64
+		upper     int64
+		err       string
+	}{
+		{
+			sql:       "SELECT * FROM a INNER JOIN b ON a.id = b.id AND b.ts BETWEEN a.ts - INTERVAL 5s AND a.ts + INTERVAL 1 m",
+			leftKeys:  []ast.Expr{&ast.FieldRef{StreamName: "a", Name: "id"}},
+			rightKeys: []ast.Expr{&ast.FieldRef{StreamName: "b", Name: "id"}},
+			leftTime:  &ast.FieldRef{StreamName: "a", Name: "ts"},
+			rightTime: &ast.FieldRef{StreamName: "b", Name: "ts"},
+			lower:     -5000,
+			upper:     60000,
+		}, {
+			// The time bound is on the left side and the key is reversed
+			sql:       "SELECT * FROM a LEFT JOIN b ON b.id = a.id AND a.ts BETWEEN b.ts AND b.ts + INTERVAL 10 ss",
+			leftKeys:  []ast.Expr{&ast.FieldRef{StreamName: "a", Name: "id"}},
+			rightKeys: []ast.Expr{&ast.FieldRef{StreamName: "b", Name: "id"}},
+			leftTime:  &ast.FieldRef{StreamName: "a", Name: "ts"},
+			rightTime: &ast.FieldRef{StreamName: "b", Name: "ts"},
+			lower:     -10000,
+			upper:     0,
+		}, {
+			// Alias and integer bound in milliseconds
+			sql:       "SELECT * FROM a AS x FULL JOIN b AS y ON y.ts BETWEEN x.ts - 100 AND x.ts + 200",
+			leftTime:  &ast.FieldRef{StreamName: "x", Name: "ts"},
+			rightTime: &ast.FieldRef{StreamName: "y", Name: "ts"},
+			lower:     -100,
+			upper:     200,
+		}, {
+			sql: "SELECT * FROM a INNER JOIN b ON a.id = b.id",
+			err: "a time window or count window is required to join multiple streams, or a time bound like b.ts BETWEEN a.ts - INTERVAL 5s AND a.ts + INTERVAL 5s for interval join",
+		}, {
+			sql: "SELECT * FROM a INNER JOIN b ON b.ts BETWEEN a.ts - INTERVAL 5s AND a.other + INTERVAL 5s",
+			err: "a time window or count window is required to join multiple streams, or a time bound like b.ts BETWEEN a.ts - INTERVAL 5s AND a.ts + INTERVAL 5s for interval join",
+		}, {
+			sql: "SELECT * FROM a CROSS JOIN b",
+			err: "interval join only supports inner, left, right and full join",
+		},
+	}
+	for i, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader(tt.sql)).Parse()
+		if err != nil {
+			t.Errorf("%d: parse error %v", i, err)
+			continue
+		}
+		p := IntervalJoinPlan{
+			from: stmt.Sources[0].(*ast.Table),
+			join: stmt.Joins[0],
+		}
+		err = p.validateAndExtractCondition()
+		if !reflect.DeepEqual(tt.err, testx.Errstring(err)) {
+			t.Errorf("%d: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.err, err)
+			continue
+		}
+		if tt.err != "" {
+			continue
+		}
+		if !reflect.DeepEqual(tt.leftKeys, p.leftKeys) || !reflect.DeepEqual(tt.rightKeys, p.rightKeys) {
+			t.Errorf("%d: keys mismatch:\n  exp=%v %v\n  got=%v %v", i, tt.leftKeys, tt.rightKeys, p.leftKeys, p.rightKeys)
+		}
+		if !reflect.DeepEqual(tt.leftTime, p.leftTime) || !reflect.DeepEqual(tt.rightTime, p.rightTime) {
+			t.Errorf("%d: time mismatch:\n  exp=%v %v\n  got=%v %v", i, tt.leftTime, tt.rightTime, p.leftTime, p.rightTime)
+		}
+		if tt.lower != p.lower || tt.upper != p.upper {
+			t.Errorf("%d: bound mismatch:\n  exp=[%d, %d]\n  got=[%d, %d]", i, tt.lower, tt.upper, p.lower, p.upper)
+		}
+	}
+}
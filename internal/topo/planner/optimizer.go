@@ -1,4 +1,4 @@
-// Copyright 2021 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -17,6 +17,7 @@ package planner
 var optRuleList = []logicalOptRule{
 	&columnPruner{},
 	&predicatePushDown{},
+	&columnarBatch{},
 }
 
 func optimize(p LogicalPlan) (LogicalPlan, error) {
@@ -0,0 +1,45 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import "github.com/lf-edge/ekuiper/pkg/ast"
+
+type PatternPlan struct {
+	baseLogicalPlan
+	elements   []ast.PatternElement
+	conditions map[string]ast.Expr
+	within     int64
+	partition  []ast.Expr
+}
+
+func (p PatternPlan) Init() *PatternPlan {
+	p.baseLogicalPlan.self = &p
+	return &p
+}
+
+// PushDownPredicate the condition above the pattern applies to the matches and cannot be pushed into the sequence
+func (p *PatternPlan) PushDownPredicate(condition ast.Expr) (ast.Expr, LogicalPlan) {
+	return condition, p
+}
+
+func (p *PatternPlan) PruneColumns(fields []ast.Expr) error {
+	for _, k := range p.partition {
+		fields = append(fields, getFields(k)...)
+	}
+	for _, c := range p.conditions {
+		fields = append(fields, getFields(c)...)
+	}
+	return p.baseLogicalPlan.PruneColumns(fields)
+}
@@ -15,6 +15,7 @@
 package planner
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 
@@ -39,6 +40,48 @@ func Plan(rule *api.Rule) (*topo.Topo, error) {
 
 // PlanSQLWithSourcesAndSinks For test only
 func PlanSQLWithSourcesAndSinks(rule *api.Rule, sources []*node.SourceNode, sinks []*node.SinkNode) (*topo.Topo, error) {
+	store, err := store2.GetKV("stream")
+	if err != nil {
+		return nil, err
+	}
+	return planSQL(rule, sources, sinks, store)
+}
+
+// PlanWithStream plans the SQL rule as if the stream were defined by the statement. It checks whether the rule
+// is compatible with a new stream definition before the definition is saved. The topo is not opened.
+func PlanWithStream(rule *api.Rule, name string, statement string) (*topo.Topo, error) {
+	store, err := store2.GetKV("stream")
+	if err != nil {
+		return nil, err
+	}
+	info, err := xsql.GetDataSourceStatement(store, name)
+	if err != nil {
+		return nil, err
+	}
+	info.Statement = statement
+	v, err := json.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+	return planSQL(rule, nil, nil, &streamOverlay{KeyValue: store, name: name, value: string(v)})
+}
+
+// streamOverlay reads the overridden definition of a stream and the others from the store
+type streamOverlay struct {
+	kv.KeyValue
+	name  string
+	value string
+}
+
+func (s *streamOverlay) Get(key string, val interface{}) (bool, error) {
+	if v, ok := val.(*string); ok && key == s.name {
+		*v = s.value
+		return true, nil
+	}
+	return s.KeyValue.Get(key, val)
+}
+
+func planSQL(rule *api.Rule, sources []*node.SourceNode, sinks []*node.SinkNode, store kv.KeyValue) (*topo.Topo, error) {
 	sql := rule.Sql
 
 	conf.Log.Infof("Init rule with options %+v", rule.Options)
@@ -51,13 +94,9 @@ func PlanSQLWithSourcesAndSinks(rule *api.Rule, sources []*node.SourceNode, sink
 	//if len(sources) > 0 && len(sources) != len(streamsFromStmt) {
 	//	return nil, fmt.Errorf("Invalid parameter sources or streams, the length cannot match the statement, expect %d sources.", len(streamsFromStmt))
 	//}
-	if rule.Options.SendMetaToSink && (len(streamsFromStmt) > 1 || stmt.Dimensions != nil) {
+	if rule.Options.SendMetaToSink && (len(streamsFromStmt) > 1 || stmt.Dimensions != nil || stmt.Pattern != nil) {
 		return nil, fmt.Errorf("Invalid option sendMetaToSink, it can not be applied to window")
 	}
-	store, err := store2.GetKV("stream")
-	if err != nil {
-		return nil, err
-	}
 	// Create logical plan and optimize. Logical plans are a linked list
 	lp, err := createLogicalPlan(stmt, rule.Options, store)
 	if err != nil {
@@ -88,20 +127,46 @@ func createTopo(rule *api.Rule, lp LogicalPlan, sources []*node.SourceNode, sink
 			tp.AddSink(inputs, sink)
 		}
 	} else {
+		schema := outputSchema(lp)
 		for i, m := range rule.Actions {
 			for name, action := range m {
 				props, ok := action.(map[string]interface{})
 				if !ok {
 					return nil, fmt.Errorf("expect map[string]interface{} type for the action properties, but found %v", action)
 				}
-				tp.AddSink(inputs, node.NewSinkNode(fmt.Sprintf("%s_%d", name, i), name, props))
+				sn := node.NewSinkNode(fmt.Sprintf("%s_%d", name, i), name, props)
+				sn.SetOutputSchema(schema)
+				tp.AddSink(inputs, sn)
 			}
 		}
 	}
+	if err := buildDeadLetter(tp, rule.Options); err != nil {
+		return nil, err
+	}
 
 	return tp, nil
 }
 
+// buildDeadLetter adds the dead letter node and its sink to the topo if the rule has a dead letter option
+func buildDeadLetter(tp *topo.Topo, options *api.RuleOption) error {
+	dlo := options.DeadLetter
+	if dlo == nil {
+		return nil
+	}
+	if len(dlo.Action) != 1 {
+		return fmt.Errorf("dead letter must have exactly one action, but found %d", len(dlo.Action))
+	}
+	for name, action := range dlo.Action {
+		props, ok := action.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expect map[string]interface{} type for the dead letter action properties, but found %v", action)
+		}
+		dl := node.NewDeadLetterNode("deadLetter", dlo.RateLimit, options)
+		tp.AddDeadLetter(dl, node.NewSinkNode(fmt.Sprintf("deadLetter_%s", name), name, props))
+	}
+	return nil
+}
+
 func buildOps(lp LogicalPlan, tp *topo.Topo, options *api.RuleOption, sources []*node.SourceNode, streamsFromStmt []string, index int) (api.Emitter, int, error) {
 	var inputs []api.Emitter
 	newIndex := index
@@ -150,22 +215,58 @@ func buildOps(lp LogicalPlan, tp *topo.Topo, options *api.RuleOption, sources []
 			Interval:    i,
 			RawInterval: rawInterval,
 			TimeUnit:    t.timeUnit,
+			Incremental: t.incremental,
+			Dimensions:  t.dimensions,
+			AggFuncs:    t.aggFuncs,
 		}, streamsFromStmt, options)
 		if err != nil {
 			return nil, 0, err
 		}
+	case *ColumnarBatchPlan:
+		op, err = node.NewColumnarBatchNode(fmt.Sprintf("%d_columnarBatch", newIndex), node.ColumnarBatchConf{
+			Condition:   t.condition,
+			Projections: t.projections,
+			Types:       t.types,
+			BatchSize:   t.batchSize,
+			Linger:      int64(t.linger),
+		}, options)
 	case *LookupPlan:
 		op, err = node.NewLookupNode(t.joinExpr.Name, t.fields, t.keys, t.joinExpr.JoinType, t.valvars, t.options, options)
 	case *JoinAlignPlan:
 		op, err = node.NewJoinAlignNode(fmt.Sprintf("%d_join_aligner", newIndex), t.Emitters, options)
 	case *JoinPlan:
 		op = Transform(&operator.JoinOp{Joins: t.joins, From: t.from}, fmt.Sprintf("%d_join", newIndex), options)
+	case *IntervalJoinPlan:
+		op, err = node.NewIntervalJoinNode(fmt.Sprintf("%d_interval_join", newIndex), node.IntervalJoinConf{
+			JoinType:  t.join.JoinType,
+			Left:      []string{t.from.Name, t.from.Alias},
+			Right:     []string{t.join.Name, t.join.Alias},
+			LeftKeys:  t.leftKeys,
+			RightKeys: t.rightKeys,
+			LeftTime:  t.leftTime,
+			RightTime: t.rightTime,
+			Lower:     t.lower,
+			Upper:     t.upper,
+			Condition: t.join.Expr,
+		}, options)
+	case *SamplePlan:
+		op = Transform(&operator.SampleOp{Every: t.every, Percent: t.percent, Keys: t.keys}, fmt.Sprintf("%d_sample", newIndex), options)
+	case *ThrottlePlan:
+		op = Transform(&operator.ThrottleOp{Limit: t.limit, Interval: t.interval, Keys: t.keys, Policy: t.policy}, fmt.Sprintf("%d_throttle", newIndex), options)
+	case *DebouncePlan:
+		op = Transform(&operator.DebounceOp{Quiet: t.quiet, Keys: t.keys}, fmt.Sprintf("%d_debounce", newIndex), options)
+	case *PatternPlan:
+		op = Transform(&operator.PatternOp{Elements: t.elements, Conditions: t.conditions, Within: t.within, Partition: t.partition}, fmt.Sprintf("%d_pattern", newIndex), options)
+	case *DedupPlan:
+		op = Transform(&operator.DedupOp{Keys: t.keys, TTL: t.ttl, MaxKeys: t.maxKeys, FalsePositiveRate: t.falsePositiveRate}, fmt.Sprintf("%d_dedup", newIndex), options)
 	case *FilterPlan:
 		op = Transform(&operator.FilterOp{Condition: t.condition}, fmt.Sprintf("%d_filter", newIndex), options)
 	case *AggregatePlan:
 		op = Transform(&operator.AggregateOp{Dimensions: t.dimensions}, fmt.Sprintf("%d_aggregate", newIndex), options)
 	case *HavingPlan:
 		op = Transform(&operator.HavingOp{Condition: t.condition}, fmt.Sprintf("%d_having", newIndex), options)
+	case *WindowFuncsPlan:
+		op = Transform(&operator.WindowFuncsOp{Funcs: t.funcs}, fmt.Sprintf("%d_windowFuncs", newIndex), options)
 	case *OrderPlan:
 		op = Transform(&operator.OrderOp{SortFields: t.SortFields}, fmt.Sprintf("%d_order", newIndex), options)
 	case *ProjectPlan:
@@ -208,15 +309,21 @@ func transformSourceNode(t *DataSourcePlan, sources []*node.SourceNode, options
 	isSchemaless := t.isSchemaless
 	switch t.streamStmt.StreamType {
 	case ast.TypeStream:
-		var (
-			pp  node.UnOperation
-			err error
-		)
+		var pp node.UnOperation
 		if t.iet || (!isSchemaless && (t.streamStmt.Options.STRICT_VALIDATION || t.isBinary)) {
-			pp, err = operator.NewPreprocessor(isSchemaless, t.streamFields, t.allMeta, t.metaFields, t.iet, t.timestampField, t.timestampFormat, t.isBinary, t.streamStmt.Options.STRICT_VALIDATION)
+			prep, err := operator.NewPreprocessor(isSchemaless, t.streamFields, t.allMeta, t.metaFields, t.iet, t.timestampField, t.timestampFormat, t.isBinary, t.streamStmt.Options.STRICT_VALIDATION)
 			if err != nil {
 				return nil, err
 			}
+			if !isSchemaless && (t.streamStmt.Options.UNKNOWN_FIELDS != "" || t.streamStmt.Options.TYPE_MISMATCH != "") {
+				// The stream fields are pruned, get the full schema to detect the unknown fields
+				sInfo, err := convertStreamInfo(t.streamStmt)
+				if err != nil {
+					return nil, err
+				}
+				prep.SetSchemaPolicy(t.streamStmt.Options.UNKNOWN_FIELDS, t.streamStmt.Options.TYPE_MISMATCH, sInfo.schema.ToJsonSchema())
+			}
+			pp = prep
 		}
 		var srcNode *node.SourceNode
 		if len(sources) == 0 {
@@ -266,12 +373,21 @@ func createLogicalPlan(stmt *ast.SelectStatement, opt *api.RuleOption, store kv.
 		scanTableEmitters   []string
 		w                   *ast.Window
 		ds                  ast.Dimensions
+		// If the window aggregates incrementally, the group by is done in the window
+		incremental bool
 	)
 
 	streamStmts, analyticFuncs, err := decorateStmt(stmt, store)
 	if err != nil {
 		return nil, err
 	}
+	if err := validateAnalyticFuncs(analyticFuncs, opt); err != nil {
+		return nil, err
+	}
+	windowFuncs, err := extractWindowFuncs(stmt)
+	if err != nil {
+		return nil, err
+	}
 
 	for _, sInfo := range streamStmts {
 		if sInfo.stmt.StreamType == ast.TypeTable && sInfo.stmt.Options.KIND == ast.StreamKindLookup {
@@ -296,6 +412,19 @@ func createLogicalPlan(stmt *ast.SelectStatement, opt *api.RuleOption, store kv.
 			}
 		}
 	}
+	if stmt.Dedup != nil {
+		if len(children) != 1 {
+			return nil, errors.New("DEDUP BY requires exactly one stream")
+		}
+		p = DedupPlan{
+			keys:              stmt.Dedup.Keys,
+			ttl:               stmt.Dedup.Within.ToMilli(),
+			maxKeys:           opt.DedupMaxKeys,
+			falsePositiveRate: opt.DedupFalsePositiveRate,
+		}.Init()
+		p.SetChildren(children)
+		children = []LogicalPlan{p}
+	}
 	if len(analyticFuncs) > 0 {
 		p = AnalyticFuncsPlan{
 			funcs: analyticFuncs,
@@ -303,6 +432,38 @@ func createLogicalPlan(stmt *ast.SelectStatement, opt *api.RuleOption, store kv.
 		p.SetChildren(children)
 		children = []LogicalPlan{p}
 	}
+	rateLimited := stmt.Sample != nil || stmt.Throttle != nil || stmt.Debounce != nil
+	if rateLimited && (len(children) != 1 || stmt.Joins != nil || dimensions != nil || stmt.Pattern != nil) {
+		return nil, errors.New("SAMPLE, THROTTLE and DEBOUNCE require exactly one stream without JOIN, GROUP BY or PATTERN")
+	}
+	if stmt.Pattern != nil {
+		if len(children) != 1 || stmt.Joins != nil {
+			return nil, errors.New("PATTERN requires exactly one stream")
+		}
+		if dimensions != nil {
+			return nil, errors.New("PATTERN cannot be used with GROUP BY")
+		}
+		// The WHERE clause filters the rows before matching the pattern
+		if stmt.Condition != nil {
+			p = FilterPlan{
+				condition: stmt.Condition,
+			}.Init()
+			p.SetChildren(children)
+			children = []LogicalPlan{p}
+		}
+		pp := PatternPlan{
+			elements:   stmt.Pattern.Elements,
+			conditions: make(map[string]ast.Expr, len(stmt.Pattern.Defines)),
+			within:     stmt.Pattern.Within.ToMilli(),
+			partition:  stmt.Pattern.Partition,
+		}.Init()
+		for _, d := range stmt.Pattern.Defines {
+			pp.conditions[d.Name] = d.Condition
+		}
+		pp.SetChildren(children)
+		children = []LogicalPlan{pp}
+		p = pp
+	}
 	if dimensions != nil {
 		w = dimensions.GetWindow()
 		if w != nil {
@@ -310,9 +471,11 @@ func createLogicalPlan(stmt *ast.SelectStatement, opt *api.RuleOption, store kv.
 				return nil, errors.New("cannot run window for TABLE sources")
 			}
 			wp := WindowPlan{
-				wtype:       w.WindowType,
-				length:      w.Length.Val,
-				isEventTime: opt.IsEventTime,
+				wtype:             w.WindowType,
+				length:            w.Length.Val,
+				isEventTime:       opt.IsEventTime,
+				columnarBatchSize: opt.ColumnarBatchSize,
+				columnarLinger:    opt.ColumnarLinger,
 			}.Init()
 			if w.Interval != nil {
 				wp.interval = w.Interval.Val
@@ -327,16 +490,38 @@ func createLogicalPlan(stmt *ast.SelectStatement, opt *api.RuleOption, store kv.
 				wp.condition = w.Filter
 			}
 			// TODO calculate limit
-			// TODO incremental aggregate
+			if len(children) == 1 && stmt.Joins == nil {
+				if aggFuncs, ok := extractIncAggFuncs(stmt, w, opt); ok {
+					wp.incremental = true
+					wp.dimensions = dimensions.GetGroups()
+					wp.aggFuncs = aggFuncs
+					incremental = true
+				}
+			}
+			if opt.ColumnarBatchSize > 0 && !wp.incremental {
+				wp.fields = stmt.Fields
+			}
 			wp.SetChildren(children)
 			children = []LogicalPlan{wp}
 			p = wp
 		}
 	}
-	if stmt.Joins != nil {
-		if len(lookupTableChildren) == 0 && len(scanTableChildren) == 0 && w == nil {
+	if stmt.Joins != nil && len(lookupTableChildren) == 0 && len(scanTableChildren) == 0 && w == nil {
+		// Without window, only the interval join of two streams is supported
+		if len(stmt.Joins) != 1 {
 			return nil, errors.New("a time window or count window is required to join multiple streams")
 		}
+		ijp := IntervalJoinPlan{
+			from: stmt.Sources[0].(*ast.Table),
+			join: stmt.Joins[0],
+		}
+		if err := ijp.validateAndExtractCondition(); err != nil {
+			return nil, err
+		}
+		p = ijp.Init()
+		p.SetChildren(children)
+		children = []LogicalPlan{p}
+	} else if stmt.Joins != nil {
 		if len(lookupTableChildren) > 0 {
 			var joins []ast.Join
 			for _, join := range stmt.Joins {
@@ -379,16 +564,46 @@ func createLogicalPlan(stmt *ast.SelectStatement, opt *api.RuleOption, store kv.
 			children = []LogicalPlan{p}
 		}
 	}
-	if stmt.Condition != nil {
+	if stmt.Condition != nil && stmt.Pattern == nil {
 		p = FilterPlan{
 			condition: stmt.Condition,
 		}.Init()
 		p.SetChildren(children)
 		children = []LogicalPlan{p}
 	}
+	if rateLimited {
+		// Thin the rows after filtering in the order of sample, throttle and debounce
+		if stmt.Sample != nil {
+			p = SamplePlan{
+				every:   stmt.Sample.Every,
+				percent: stmt.Sample.Percent,
+				keys:    stmt.Sample.Keys,
+			}.Init()
+			p.SetChildren(children)
+			children = []LogicalPlan{p}
+		}
+		if stmt.Throttle != nil {
+			p = ThrottlePlan{
+				limit:    stmt.Throttle.Limit,
+				interval: stmt.Throttle.Interval.ToMilli(),
+				keys:     stmt.Throttle.Keys,
+				policy:   stmt.Throttle.Policy,
+			}.Init()
+			p.SetChildren(children)
+			children = []LogicalPlan{p}
+		}
+		if stmt.Debounce != nil {
+			p = DebouncePlan{
+				quiet: stmt.Debounce.Quiet.ToMilli(),
+				keys:  stmt.Debounce.Keys,
+			}.Init()
+			p.SetChildren(children)
+			children = []LogicalPlan{p}
+		}
+	}
 	if dimensions != nil {
 		ds = dimensions.GetGroups()
-		if ds != nil && len(ds) > 0 {
+		if ds != nil && len(ds) > 0 && !incremental {
 			p = AggregatePlan{
 				dimensions: ds,
 			}.Init()
@@ -405,6 +620,17 @@ func createLogicalPlan(stmt *ast.SelectStatement, opt *api.RuleOption, store kv.
 		children = []LogicalPlan{p}
 	}
 
+	if len(windowFuncs) > 0 {
+		if w == nil {
+			return nil, errors.New("window functions must be used with a window")
+		}
+		p = WindowFuncsPlan{
+			funcs: windowFuncs,
+		}.Init()
+		p.SetChildren(children)
+		children = []LogicalPlan{p}
+	}
+
 	if stmt.SortFields != nil {
 		p = OrderPlan{
 			SortFields: stmt.SortFields,
@@ -416,7 +642,7 @@ func createLogicalPlan(stmt *ast.SelectStatement, opt *api.RuleOption, store kv.
 	if stmt.Fields != nil {
 		p = ProjectPlan{
 			fields:      stmt.Fields,
-			isAggregate: xsql.IsAggStatement(stmt),
+			isAggregate: xsql.IsAggStatement(stmt) || stmt.Pattern != nil, // each pattern match is projected to one row
 			sendMeta:    opt.SendMetaToSink,
 		}.Init()
 		p.SetChildren(children)
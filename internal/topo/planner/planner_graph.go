@@ -17,6 +17,7 @@ package planner
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/lf-edge/ekuiper/internal/binder/function"
@@ -132,6 +133,41 @@ func PlanByGraph(rule *api.Rule) (*topo.Topo, error) {
 				}
 				op := Transform(fop, nodeName, rule.Options)
 				nodeMap[nodeName] = op
+			case "dedup":
+				dop, err := parseDedup(gn.Props, sourceNames)
+				if err != nil {
+					return nil, fmt.Errorf("parse dedup %s with %v error: %w", nodeName, gn.Props, err)
+				}
+				op := Transform(dop, nodeName, rule.Options)
+				nodeMap[nodeName] = op
+			case "sample":
+				sop, err := parseSample(gn.Props, sourceNames)
+				if err != nil {
+					return nil, fmt.Errorf("parse sample %s with %v error: %w", nodeName, gn.Props, err)
+				}
+				op := Transform(sop, nodeName, rule.Options)
+				nodeMap[nodeName] = op
+			case "throttle":
+				top, err := parseThrottle(gn.Props, sourceNames)
+				if err != nil {
+					return nil, fmt.Errorf("parse throttle %s with %v error: %w", nodeName, gn.Props, err)
+				}
+				op := Transform(top, nodeName, rule.Options)
+				nodeMap[nodeName] = op
+			case "debounce":
+				dop, err := parseDebounce(gn.Props, sourceNames)
+				if err != nil {
+					return nil, fmt.Errorf("parse debounce %s with %v error: %w", nodeName, gn.Props, err)
+				}
+				op := Transform(dop, nodeName, rule.Options)
+				nodeMap[nodeName] = op
+			case "pattern":
+				pop, err := parsePattern(gn.Props, sourceNames)
+				if err != nil {
+					return nil, fmt.Errorf("parse pattern %s with %v error: %w", nodeName, gn.Props, err)
+				}
+				op := Transform(pop, nodeName, rule.Options)
+				nodeMap[nodeName] = op
 			case "pick":
 				pop, err := parsePick(gn.Props, sourceNames)
 				if err != nil {
@@ -390,6 +426,9 @@ func PlanByGraph(rule *api.Rule) (*topo.Topo, error) {
 			tp.AddOperator(inputs, n.(node.OperatorNode))
 		}
 	}
+	if err := buildDeadLetter(tp, rule.Options); err != nil {
+		return nil, err
+	}
 	return tp, nil
 }
 
@@ -719,6 +758,149 @@ func parseFilter(props map[string]interface{}, sourceNames []string) (*operator.
 	return nil, fmt.Errorf("expr %v is not a condition", m)
 }
 
+func parseDedup(props map[string]interface{}, sourceNames []string) (*operator.DedupOp, error) {
+	n := &graph.Dedup{}
+	err := cast.MapToStruct(props, n)
+	if err != nil {
+		return nil, err
+	}
+	if len(n.Keys) == 0 {
+		return nil, errors.New("no keys")
+	}
+	if n.MaxKeys < 0 {
+		return nil, errors.New("maxKeys must not be negative")
+	}
+	if n.FalsePositiveRate < 0 || n.FalsePositiveRate >= 1 {
+		return nil, errors.New("falsePositiveRate must between [0, 1)")
+	}
+	stmt := fmt.Sprintf("SELECT * FROM unknown DEDUP BY %s WITHIN %s", strings.Join(n.Keys, ","), n.Within)
+	p, err := xsql.NewParserWithSources(strings.NewReader(stmt), sourceNames).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid dedup statement error: %v", err)
+	}
+	return &operator.DedupOp{
+		Keys:              p.Dedup.Keys,
+		TTL:               p.Dedup.Within.ToMilli(),
+		MaxKeys:           n.MaxKeys,
+		FalsePositiveRate: n.FalsePositiveRate,
+	}, nil
+}
+
+func parseSample(props map[string]interface{}, sourceNames []string) (*operator.SampleOp, error) {
+	n := &graph.Sample{}
+	err := cast.MapToStruct(props, n)
+	if err != nil {
+		return nil, err
+	}
+	var stmt string
+	if n.Every > 0 {
+		stmt = fmt.Sprintf("SELECT * FROM unknown SAMPLE EVERY %d", n.Every)
+		if len(n.Keys) > 0 {
+			stmt += " BY " + strings.Join(n.Keys, ",")
+		}
+	} else if n.Percent > 0 {
+		stmt = fmt.Sprintf("SELECT * FROM unknown SAMPLE %v PERCENT", n.Percent)
+	} else {
+		return nil, errors.New("either every or percent must be set")
+	}
+	p, err := xsql.NewParserWithSources(strings.NewReader(stmt), sourceNames).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid sample statement error: %v", err)
+	}
+	return &operator.SampleOp{
+		Every:   p.Sample.Every,
+		Percent: p.Sample.Percent,
+		Keys:    p.Sample.Keys,
+	}, nil
+}
+
+func parseThrottle(props map[string]interface{}, sourceNames []string) (*operator.ThrottleOp, error) {
+	n := &graph.Throttle{}
+	err := cast.MapToStruct(props, n)
+	if err != nil {
+		return nil, err
+	}
+	stmt := fmt.Sprintf("SELECT * FROM unknown THROTTLE %d PER %s", n.Limit, n.Interval)
+	if len(n.Keys) > 0 {
+		stmt += " BY " + strings.Join(n.Keys, ",")
+	}
+	if n.Policy != "" {
+		stmt += " POLICY " + n.Policy
+	}
+	p, err := xsql.NewParserWithSources(strings.NewReader(stmt), sourceNames).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid throttle statement error: %v", err)
+	}
+	return &operator.ThrottleOp{
+		Limit:    p.Throttle.Limit,
+		Interval: p.Throttle.Interval.ToMilli(),
+		Keys:     p.Throttle.Keys,
+		Policy:   p.Throttle.Policy,
+	}, nil
+}
+
+func parseDebounce(props map[string]interface{}, sourceNames []string) (*operator.DebounceOp, error) {
+	n := &graph.Debounce{}
+	err := cast.MapToStruct(props, n)
+	if err != nil {
+		return nil, err
+	}
+	stmt := fmt.Sprintf("SELECT * FROM unknown DEBOUNCE %s", n.Quiet)
+	if len(n.Keys) > 0 {
+		stmt += " BY " + strings.Join(n.Keys, ",")
+	}
+	p, err := xsql.NewParserWithSources(strings.NewReader(stmt), sourceNames).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid debounce statement error: %v", err)
+	}
+	return &operator.DebounceOp{
+		Quiet: p.Debounce.Quiet.ToMilli(),
+		Keys:  p.Debounce.Keys,
+	}, nil
+}
+
+func parsePattern(props map[string]interface{}, sourceNames []string) (*operator.PatternOp, error) {
+	n := &graph.Pattern{}
+	err := cast.MapToStruct(props, n)
+	if err != nil {
+		return nil, err
+	}
+	if n.Pattern == "" {
+		return nil, errors.New("no pattern")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "SELECT * FROM unknown PATTERN (%s) WITHIN %s", n.Pattern, n.Within)
+	if len(n.Partition) > 0 {
+		fmt.Fprintf(&b, " PARTITION BY %s", strings.Join(n.Partition, ","))
+	}
+	if len(n.Define) > 0 {
+		names := make([]string, 0, len(n.Define))
+		for k := range n.Define {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		defines := make([]string, len(names))
+		for i, k := range names {
+			defines[i] = fmt.Sprintf("%s AS %s", k, n.Define[k])
+		}
+		fmt.Fprintf(&b, " DEFINE %s", strings.Join(defines, ","))
+	}
+	p, err := xsql.NewParserWithSources(strings.NewReader(b.String()), sourceNames).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern statement error: %v", err)
+	}
+	conditions := make(map[string]ast.Expr, len(p.Pattern.Defines))
+	for _, d := range p.Pattern.Defines {
+		conditions[d.Name] = d.Condition
+	}
+	return &operator.PatternOp{
+		Elements:   p.Pattern.Elements,
+		Conditions: conditions,
+		Within:     p.Pattern.Within.ToMilli(),
+		Partition:  p.Pattern.Partition,
+	}, nil
+}
+
 func parseHaving(props map[string]interface{}, sourceNames []string) (*operator.HavingOp, error) {
 	m, ok := props["expr"]
 	if !ok {
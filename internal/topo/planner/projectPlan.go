@@ -14,7 +14,10 @@
 
 package planner
 
-import "github.com/lf-edge/ekuiper/pkg/ast"
+import (
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
 
 type ProjectPlan struct {
 	baseLogicalPlan
@@ -61,3 +64,68 @@ func (p *ProjectPlan) PruneColumns(fields []ast.Expr) error {
 	f := getFields(p.fields)
 	return p.baseLogicalPlan.PruneColumns(append(fields, f...))
 }
+
+// outputSchema returns the types of the projected fields which can be resolved by the stream schemas, such as the
+// columns and the arithmetic of them. The fields of unknown types like the function results are not included.
+func outputSchema(lp LogicalPlan) map[string]ast.DataType {
+	var (
+		pp     *ProjectPlan
+		schema = make(map[string]*ast.JsonStreamField)
+		// the fields of the same name but different types in the joined streams are unknown
+		conflicts = make(map[string]bool)
+	)
+	var walk func(p LogicalPlan)
+	walk = func(p LogicalPlan) {
+		switch t := p.(type) {
+		case *ProjectPlan:
+			if pp == nil {
+				pp = t
+			}
+		case *DataSourcePlan:
+			for k, v := range t.streamFields {
+				if o, ok := schema[k]; ok && o.Type != v.Type {
+					conflicts[k] = true
+				}
+				schema[k] = v
+			}
+		}
+		for _, c := range p.Children() {
+			walk(c)
+		}
+	}
+	walk(lp)
+	for k := range conflicts {
+		delete(schema, k)
+	}
+	if pp == nil || len(schema) == 0 {
+		return nil
+	}
+	result := make(map[string]ast.DataType)
+	for _, f := range pp.fields {
+		expr := f.Expr
+		if _, ok := expr.(*ast.Wildcard); ok {
+			for k := range schema {
+				ref := &ast.FieldRef{Name: k}
+				if types, ok := resolveFieldTypes(ref, schema); ok {
+					if t, ok := xsql.VectorType(ref, types); ok {
+						result[k] = t
+					}
+				}
+			}
+			continue
+		}
+		for {
+			fr, ok := expr.(*ast.FieldRef)
+			if !ok || !fr.IsAlias() {
+				break
+			}
+			expr = fr.Expression
+		}
+		if types, ok := resolveFieldTypes(expr, schema); ok {
+			if t, ok := xsql.VectorType(expr, types); ok {
+				result[f.GetName()] = t
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,70 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestOutputSchema(t *testing.T) {
+	schema := map[string]*ast.JsonStreamField{
+		"id":   {Type: "bigint"},
+		"temp": {Type: "float"},
+		"name": {Type: "string"},
+		"ts":   {Type: "datetime"},
+	}
+	tests := []struct {
+		sql     string
+		schemas []map[string]*ast.JsonStreamField
+		exp     map[string]ast.DataType
+	}{
+		{
+			sql:     "SELECT temp * 1.8 + 32 AS f, abs(temp) AS a, id, name AS n, id > 2 AS b, ts FROM src",
+			schemas: []map[string]*ast.JsonStreamField{schema},
+			exp:     map[string]ast.DataType{"f": ast.FLOAT, "id": ast.BIGINT, "n": ast.STRINGS, "b": ast.BOOLEAN},
+		}, {
+			sql:     "SELECT * FROM src",
+			schemas: []map[string]*ast.JsonStreamField{schema},
+			exp:     map[string]ast.DataType{"id": ast.BIGINT, "temp": ast.FLOAT, "name": ast.STRINGS},
+		}, {
+			sql:     "SELECT id, temp FROM src1 INNER JOIN src2 ON src1.temp = src2.temp",
+			schemas: []map[string]*ast.JsonStreamField{schema, {"id": {Type: "string"}, "temp": {Type: "float"}}},
+			exp:     map[string]ast.DataType{"temp": ast.FLOAT},
+		}, {
+			sql: "SELECT * FROM src",
+		},
+	}
+	for i, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader(tt.sql)).Parse()
+		if err != nil {
+			t.Errorf("%d. parse error: %v", i, err)
+			continue
+		}
+		children := make([]LogicalPlan, 0, len(tt.schemas))
+		for _, s := range tt.schemas {
+			children = append(children, DataSourcePlan{streamFields: s}.Init())
+		}
+		pp := ProjectPlan{fields: stmt.Fields}.Init()
+		pp.SetChildren(children)
+		if got := outputSchema(pp); !reflect.DeepEqual(tt.exp, got) {
+			t.Errorf("%d. %s: schema mismatch:\n  exp=%v\n  got=%v", i, tt.sql, tt.exp, got)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import "github.com/lf-edge/ekuiper/pkg/ast"
+
+type SamplePlan struct {
+	baseLogicalPlan
+	every   int
+	percent float64
+	keys    []ast.Expr
+}
+
+func (p SamplePlan) Init() *SamplePlan {
+	p.baseLogicalPlan.self = &p
+	return &p
+}
+
+// PushDownPredicate the rows must be filtered before they are sampled
+func (p *SamplePlan) PushDownPredicate(condition ast.Expr) (ast.Expr, LogicalPlan) {
+	return condition, p
+}
+
+func (p *SamplePlan) PruneColumns(fields []ast.Expr) error {
+	for _, k := range p.keys {
+		fields = append(fields, getFields(k)...)
+	}
+	return p.baseLogicalPlan.PruneColumns(fields)
+}
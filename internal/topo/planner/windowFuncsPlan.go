@@ -0,0 +1,74 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/binder/function"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+type WindowFuncsPlan struct {
+	baseLogicalPlan
+	funcs []*ast.Call
+}
+
+func (p WindowFuncsPlan) Init() *WindowFuncsPlan {
+	p.baseLogicalPlan.self = &p
+	return &p
+}
+
+func (p *WindowFuncsPlan) PruneColumns(fields []ast.Expr) error {
+	for _, f := range p.funcs {
+		ff := getFields(f)
+		fields = append(fields, ff...)
+	}
+	return p.baseLogicalPlan.PruneColumns(fields)
+}
+
+// extractWindowFuncs collects the window function calls and marks them to read the result calculated by the window funcs op.
+// Window functions are calculated after the rows of the window are filtered and grouped, so they are only allowed in
+// the SELECT and ORDER BY clause.
+func extractWindowFuncs(stmt *ast.SelectStatement) ([]*ast.Call, error) {
+	var funcs []*ast.Call
+	collect := func(n ast.Node) bool {
+		if c, ok := n.(*ast.Call); ok && function.IsWindowFunc(c.Name) && !c.Cached {
+			c.CachedField = fmt.Sprintf("%s_%s_%d", function.WindowFuncPrefix, c.Name, c.FuncId)
+			c.Cached = true
+			funcs = append(funcs, &ast.Call{
+				Name:        c.Name,
+				FuncId:      c.FuncId,
+				FuncType:    c.FuncType,
+				Args:        c.Args,
+				CachedField: c.CachedField,
+				Partition:   c.Partition,
+				SortFields:  c.SortFields,
+			})
+		}
+		return true
+	}
+	ast.WalkFunc(stmt.Fields, collect)
+	ast.WalkFunc(stmt.SortFields, collect)
+	var err error
+	ast.WalkFunc(stmt, func(n ast.Node) bool {
+		if c, ok := n.(*ast.Call); ok && function.IsWindowFunc(c.Name) && !c.Cached {
+			err = fmt.Errorf("window function %s can only be used in SELECT and ORDER BY clause", c.Name)
+			return false
+		}
+		return err == nil
+	})
+	return funcs, err
+}
@@ -0,0 +1,61 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+)
+
+func TestExtractWindowFuncs(t *testing.T) {
+	tests := []struct {
+		sql    string
+		cached []string
+		err    error
+	}{
+		{
+			sql:    `SELECT row_number() OVER (PARTITION BY color ORDER BY ts) AS r, first_value(size) OVER (ORDER BY ts DESC) FROM demo GROUP BY TumblingWindow(ss, 10) ORDER BY rank() OVER (ORDER BY size)`,
+			cached: []string{"$$w_row_number_0", "$$w_first_value_1", "$$w_rank_2"},
+		}, {
+			sql: `SELECT size FROM demo GROUP BY TumblingWindow(ss, 10)`,
+		}, {
+			sql: `SELECT size FROM demo WHERE row_number() OVER (ORDER BY size) = 1 GROUP BY TumblingWindow(ss, 10)`,
+			err: errors.New("window function row_number can only be used in SELECT and ORDER BY clause"),
+		},
+	}
+	for i, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader(tt.sql)).Parse()
+		if err != nil {
+			t.Errorf("%d. parse error: %v", i, err)
+			continue
+		}
+		calls, err := extractWindowFuncs(stmt)
+		if !reflect.DeepEqual(tt.err, err) {
+			t.Errorf("%d. %s: error mismatch:\n  exp=%v\n  got=%v", i, tt.sql, tt.err, err)
+			continue
+		}
+		var cached []string
+		for _, c := range calls {
+			cached = append(cached, c.CachedField)
+		}
+		if !reflect.DeepEqual(tt.cached, cached) {
+			t.Errorf("%d. %s: cached fields mismatch:\n  exp=%v\n  got=%v", i, tt.sql, tt.cached, cached)
+		}
+	}
+}
@@ -14,7 +14,15 @@
 
 package planner
 
-import "github.com/lf-edge/ekuiper/pkg/ast"
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+const incAggPrefix = "$$inc"
 
 type WindowPlan struct {
 	baseLogicalPlan
@@ -25,6 +33,15 @@ type WindowPlan struct {
 	timeUnit    ast.Token
 	limit       int // If limit is not positive, there will be no limit
 	isEventTime bool
+	// If incremental, the window does the group by and calculates the aggregate functions
+	incremental bool
+	dimensions  ast.Dimensions
+	aggFuncs    []*ast.Call
+	// If the batch size is set, the rows before the window may be filtered in columnar batches
+	columnarBatchSize int
+	columnarLinger    int
+	// fields are the select fields which may be calculated in columnar batches
+	fields ast.Fields
 }
 
 func (p WindowPlan) Init() *WindowPlan {
@@ -54,5 +71,86 @@ func (p *WindowPlan) PushDownPredicate(condition ast.Expr) (ast.Expr, LogicalPla
 
 func (p *WindowPlan) PruneColumns(fields []ast.Expr) error {
 	f := getFields(p.condition)
+	if p.incremental {
+		f = append(f, getFields(p.dimensions)...)
+	}
 	return p.baseLogicalPlan.PruneColumns(append(fields, f...))
 }
+
+// incAggFuncs are the aggregate functions whose result can be calculated by merging the partial results
+var incAggFuncs = map[string]struct{}{
+	"avg":     {},
+	"count":   {},
+	"max":     {},
+	"min":     {},
+	"sum":     {},
+	"stddev":  {},
+	"stddevs": {},
+	"var":     {},
+	"vars":    {},
+}
+
+// extractIncAggFuncs checks if the window can aggregate incrementally and marks the aggregate calls to be cached.
+// It must be enabled by the rule option and only processing time tumbling, hopping and sliding window is supported.
+// All the aggregate functions must be decomposable and no wildcard field is selected because the non-aggregate fields
+// are evaluated from the first row.
+func extractIncAggFuncs(stmt *ast.SelectStatement, w *ast.Window, opt *api.RuleOption) ([]*ast.Call, bool) {
+	if !opt.IncrementalAggregate || opt.IsEventTime || !xsql.IsAggStatement(stmt) {
+		return nil, false
+	}
+	switch w.WindowType {
+	case ast.TUMBLING_WINDOW, ast.HOPPING_WINDOW:
+	case ast.SLIDING_WINDOW:
+		// The condition is filtered after the sliding window which triggers by each row
+		if stmt.Condition != nil || w.Filter != nil {
+			return nil, false
+		}
+	default:
+		return nil, false
+	}
+	for _, f := range stmt.Fields {
+		if _, ok := f.Expr.(*ast.Wildcard); ok {
+			return nil, false
+		}
+	}
+	valid := true
+	// The dimensions are evaluated when the rows arrive, so the window range is unknown
+	ast.WalkFunc(stmt.Dimensions.GetGroups(), func(n ast.Node) bool {
+		if c, ok := n.(*ast.Call); ok && (c.Name == "window_start" || c.Name == "window_end") {
+			valid = false
+		}
+		return valid
+	})
+	var calls []*ast.Call
+	ast.WalkFunc(stmt, func(n ast.Node) bool {
+		if !valid {
+			return false
+		}
+		switch f := n.(type) {
+		case *ast.Call:
+			if f.FuncType != ast.FuncTypeAgg {
+				return true
+			}
+			if _, ok := incAggFuncs[f.Name]; !ok || len(f.Args) != 1 {
+				valid = false
+				return false
+			}
+			for _, c := range calls {
+				if c == f {
+					return false
+				}
+			}
+			calls = append(calls, f)
+			return false
+		}
+		return true
+	})
+	if !valid {
+		return nil, false
+	}
+	for _, c := range calls {
+		c.CachedField = fmt.Sprintf("%s_%s_%d", incAggPrefix, c.Name, c.FuncId)
+		c.Cached = true
+	}
+	return calls, true
+}
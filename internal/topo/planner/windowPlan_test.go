@@ -0,0 +1,90 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package planner
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/internal/xsql"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+func TestExtractIncAggFuncs(t *testing.T) {
+	tests := []struct {
+		sql         string
+		eventTime   bool
+		disabled    bool
+		incremental bool
+		cached      []string
+	}{
+		{
+			sql:         `SELECT color, count(*) as c, avg(size) FROM demo GROUP BY TumblingWindow(ss, 10), color HAVING max(size) > 3`,
+			incremental: true,
+			cached:      []string{"$$inc_count_0", "$$inc_avg_1", "$$inc_max_2"},
+		}, {
+			sql:         `SELECT sum(size) + 1, stddev(temp) FROM demo WHERE size > 3 GROUP BY HoppingWindow(ss, 10, 5) ORDER BY var(temp)`,
+			incremental: true,
+			cached:      []string{"$$inc_sum_0", "$$inc_stddev_1", "$$inc_var_2"},
+		}, {
+			sql:         `SELECT color FROM demo GROUP BY TumblingWindow(ss, 10), color`,
+			incremental: true,
+		}, {
+			sql:      `SELECT count(*) FROM demo GROUP BY TumblingWindow(ss, 10)`,
+			disabled: true,
+		}, {
+			sql:         `SELECT color, count(*) FROM demo GROUP BY SlidingWindow(ss, 10), color`,
+			incremental: true,
+			cached:      []string{"$$inc_count_0"},
+		}, {
+			sql: `SELECT color, count(*) FROM demo WHERE size > 3 GROUP BY SlidingWindow(ss, 10), color`,
+		}, {
+			sql: `SELECT count(*) FROM demo GROUP BY SlidingWindow(ss, 10) FILTER(WHERE size > 3)`,
+		}, {
+			sql:       `SELECT count(*) FROM demo GROUP BY TumblingWindow(ss, 10)`,
+			eventTime: true,
+		}, {
+			sql: `SELECT count(*) FROM demo GROUP BY CountWindow(10)`,
+		}, {
+			sql: `SELECT *, count(*) FROM demo GROUP BY TumblingWindow(ss, 10)`,
+		}, {
+			sql: `SELECT collect(size) FROM demo GROUP BY TumblingWindow(ss, 10)`,
+		}, {
+			sql: `SELECT count(*), collect(size) FROM demo GROUP BY TumblingWindow(ss, 10)`,
+		}, {
+			sql: `SELECT size FROM demo GROUP BY TumblingWindow(ss, 10)`,
+		},
+	}
+	for i, tt := range tests {
+		stmt, err := xsql.NewParser(strings.NewReader(tt.sql)).Parse()
+		if err != nil {
+			t.Errorf("%d. parse error: %v", i, err)
+			continue
+		}
+		calls, ok := extractIncAggFuncs(stmt, stmt.Dimensions.GetWindow(), &api.RuleOption{IsEventTime: tt.eventTime, IncrementalAggregate: !tt.disabled})
+		if ok != tt.incremental {
+			t.Errorf("%d. %s: expect incremental %v but got %v", i, tt.sql, tt.incremental, ok)
+			continue
+		}
+		var cached []string
+		for _, c := range calls {
+			cached = append(cached, c.CachedField)
+		}
+		if !reflect.DeepEqual(tt.cached, cached) {
+			t.Errorf("%d. %s: cached fields mismatch:\n  exp=%v\n  got=%v", i, tt.sql, tt.cached, cached)
+		}
+	}
+}
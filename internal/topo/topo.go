@@ -45,6 +45,9 @@ type Topo struct {
 	coordinator        *checkpoint.Coordinator
 	topo               *api.PrintableTopo
 	mu                 sync.Mutex
+	// the dead letter is out of the checkpoint so that it won't block the barriers
+	deadLetter     *node.DeadLetterNode
+	deadLetterSink *node.SinkNode
 }
 
 func NewWithNameAndQos(name string, qos api.Qos, checkpointInterval int) (*Topo, error) {
@@ -103,6 +106,20 @@ func (s *Topo) AddOperator(inputs []api.Emitter, operator node.OperatorNode) *To
 	return s
 }
 
+// AddDeadLetter sets the dead letter node and its sink. All the sources and operators will send the failed events to it.
+func (s *Topo) AddDeadLetter(dl *node.DeadLetterNode, snk *node.SinkNode) *Topo {
+	dl.AddOutput(snk.GetInput())
+	snk.AddInputCount()
+	s.addEdge(dl, snk, "sink")
+	s.deadLetter = dl
+	s.deadLetterSink = snk
+	return s
+}
+
+type deadLetterSender interface {
+	SetDeadLetter(dl *node.DeadLetterNode)
+}
+
 func (s *Topo) addEdge(from api.TopNode, to api.TopNode, toType string) {
 	fromType := "op"
 	if _, ok := from.(node.DataSourceNode); ok {
@@ -146,6 +163,9 @@ func (s *Topo) Open() <-chan error {
 				return fmt.Errorf("topo %s create store error %v", s.name, err)
 			}
 			s.enableCheckpoint()
+			if s.deadLetter != nil {
+				s.openDeadLetter()
+			}
 			// open stream sink, after log sink is ready.
 			for _, snk := range s.sinks {
 				snk.Open(s.ctx.WithMeta(s.name, snk.GetName(), s.store), s.drain)
@@ -175,6 +195,21 @@ func (s *Topo) Open() <-chan error {
 	return s.drain
 }
 
+func (s *Topo) openDeadLetter() {
+	s.deadLetterSink.Open(s.ctx.WithMeta(s.name, s.deadLetterSink.GetName(), s.store), s.drain)
+	s.deadLetter.Exec(s.ctx.WithMeta(s.name, s.deadLetter.GetName(), s.store), s.drain)
+	for _, op := range s.ops {
+		if ds, ok := op.(deadLetterSender); ok {
+			ds.SetDeadLetter(s.deadLetter)
+		}
+	}
+	for _, source := range s.sources {
+		if ds, ok := source.(deadLetterSender); ok {
+			ds.SetDeadLetter(s.deadLetter)
+		}
+	}
+}
+
 func (s *Topo) enableCheckpoint() error {
 	if s.qos >= api.AtLeastOnce {
 		var sources []checkpoint.StreamTask
@@ -224,6 +259,20 @@ func (s *Topo) GetMetrics() (keys []string, values []interface{}) {
 			}
 		}
 	}
+	if s.deadLetter != nil {
+		for ins, metrics := range s.deadLetter.GetMetrics() {
+			for i, v := range metrics {
+				keys = append(keys, "op_"+s.deadLetter.GetName()+"_"+strconv.Itoa(ins)+"_"+metric.MetricNames[i])
+				values = append(values, v)
+			}
+		}
+		for ins, metrics := range s.deadLetterSink.GetMetrics() {
+			for i, v := range metrics {
+				keys = append(keys, "sink_"+s.deadLetterSink.GetName()+"_"+strconv.Itoa(ins)+"_"+metric.MetricNames[i])
+				values = append(values, v)
+			}
+		}
+	}
 	return
 }
 
@@ -291,6 +340,10 @@ func (s *Topo) RemoveMetrics() {
 	for _, sn := range s.sinks {
 		sn.RemoveMetrics(s.name)
 	}
+	if s.deadLetter != nil {
+		s.deadLetter.RemoveMetrics(s.name)
+		s.deadLetterSink.RemoveMetrics(s.name)
+	}
 }
 
 func (s *Topo) GetTopo() *api.PrintableTopo {
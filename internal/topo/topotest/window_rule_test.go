@@ -1485,3 +1485,91 @@ func TestWindowError(t *testing.T) {
 		SendError:    true,
 	}, 0)
 }
+
+// TestIncrementalWindow checks the sliding window which aggregates incrementally gets the same result as TestWindowRule4
+func TestIncrementalWindow(t *testing.T) {
+	// Reset
+	streamList := []string{"demo"}
+	HandleStream(false, streamList, t)
+	tests := []RuleTest{
+		{
+			Name: `TestIncrementalWindowRule1`,
+			Sql:  `SELECT color, count(*) as c FROM demo GROUP BY SlidingWindow(ss, 2), color ORDER BY color`,
+			R: [][]map[string]interface{}{
+				{{
+					"color": "red",
+					"c":     float64(1),
+				}}, {{
+					"color": "blue",
+					"c":     float64(1),
+				}, {
+					"color": "red",
+					"c":     float64(1),
+				}}, {{
+					"color": "blue",
+					"c":     float64(2),
+				}, {
+					"color": "red",
+					"c":     float64(1),
+				}}, {{
+					"color": "blue",
+					"c":     float64(2),
+				}, {
+					"color": "yellow",
+					"c":     float64(1),
+				}}, {{
+					"color": "blue",
+					"c":     float64(1),
+				}, {
+					"color": "red",
+					"c":     float64(1),
+				}, {
+					"color": "yellow",
+					"c":     float64(1),
+				}},
+			},
+			M: map[string]interface{}{
+				"op_4_project_0_exceptions_total":   int64(0),
+				"op_4_project_0_process_latency_us": int64(0),
+				"op_4_project_0_records_in_total":   int64(5),
+				"op_4_project_0_records_out_total":  int64(5),
+
+				"sink_mockSink_0_exceptions_total":  int64(0),
+				"sink_mockSink_0_records_in_total":  int64(5),
+				"sink_mockSink_0_records_out_total": int64(5),
+
+				"source_demo_0_exceptions_total":  int64(0),
+				"source_demo_0_records_in_total":  int64(5),
+				"source_demo_0_records_out_total": int64(5),
+
+				// The group by is done by the window which aggregates incrementally
+				"op_2_window_0_exceptions_total":   int64(0),
+				"op_2_window_0_process_latency_us": int64(0),
+				"op_2_window_0_records_in_total":   int64(5),
+				"op_2_window_0_records_out_total":  int64(5),
+
+				"op_3_order_0_exceptions_total":   int64(0),
+				"op_3_order_0_process_latency_us": int64(0),
+				"op_3_order_0_records_in_total":   int64(5),
+				"op_3_order_0_records_out_total":  int64(5),
+			},
+		},
+	}
+	HandleStream(true, streamList, t)
+	options := []*api.RuleOption{
+		{
+			BufferLength:         100,
+			SendError:            true,
+			IncrementalAggregate: true,
+		}, {
+			BufferLength:         100,
+			SendError:            true,
+			Qos:                  api.AtLeastOnce,
+			CheckpointInterval:   5000,
+			IncrementalAggregate: true,
+		},
+	}
+	for j, opt := range options {
+		DoRuleTest(t, tests, j, opt, 15)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xsql
+
+import (
+	"fmt"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+	"github.com/lf-edge/ekuiper/pkg/cast"
+)
+
+// ColumnVector is a typed column of a batch. Only the slice of the Kind is filled. A null row has the zero value
+// in the slice and is marked in Nulls.
+type ColumnVector struct {
+	Kind   ast.DataType
+	Ints   []int64
+	Floats []float64
+	Strs   []string
+	Bools  []bool
+	Nulls  []bool
+}
+
+func newColumnVector(kind ast.DataType, capacity int) *ColumnVector {
+	c := &ColumnVector{Kind: kind, Nulls: make([]bool, 0, capacity)}
+	switch kind {
+	case ast.BIGINT:
+		c.Ints = make([]int64, 0, capacity)
+	case ast.FLOAT:
+		c.Floats = make([]float64, 0, capacity)
+	case ast.STRINGS:
+		c.Strs = make([]string, 0, capacity)
+	case ast.BOOLEAN:
+		c.Bools = make([]bool, 0, capacity)
+	}
+	return c
+}
+
+func (c *ColumnVector) Len() int {
+	return len(c.Nulls)
+}
+
+func (c *ColumnVector) append(v interface{}) error {
+	isNull := v == nil
+	var err error
+	switch c.Kind {
+	case ast.BIGINT:
+		var i int64
+		if !isNull {
+			i, err = cast.ToInt64(v, cast.CONVERT_SAMEKIND)
+		}
+		c.Ints = append(c.Ints, i)
+	case ast.FLOAT:
+		var f float64
+		if !isNull {
+			f, err = cast.ToFloat64(v, cast.CONVERT_SAMEKIND)
+		}
+		c.Floats = append(c.Floats, f)
+	case ast.STRINGS:
+		var s string
+		if !isNull {
+			s, err = cast.ToString(v, cast.CONVERT_SAMEKIND)
+		}
+		c.Strs = append(c.Strs, s)
+	case ast.BOOLEAN:
+		var b bool
+		if !isNull {
+			b, err = cast.ToBool(v, cast.CONVERT_SAMEKIND)
+		}
+		c.Bools = append(c.Bools, b)
+	default:
+		return fmt.Errorf("unsupported column type %s", c.Kind)
+	}
+	c.Nulls = append(c.Nulls, isNull)
+	return err
+}
+
+// Value returns the value of the row i as the row based evaluation does
+func (c *ColumnVector) Value(i int) interface{} {
+	if c.Nulls[i] {
+		return nil
+	}
+	switch c.Kind {
+	case ast.BIGINT:
+		return c.Ints[i]
+	case ast.FLOAT:
+		return c.Floats[i]
+	case ast.STRINGS:
+		return c.Strs[i]
+	case ast.BOOLEAN:
+		return c.Bools[i]
+	default:
+		return nil
+	}
+}
+
+// ColumnBatch is a batch of tuples of the same stream with the typed columns extracted from the tuples.
+// The tuples are kept so that the batch can be converted back to rows with all the columns and metadata.
+type ColumnBatch struct {
+	Tuples  []*Tuple
+	Columns map[string]*ColumnVector
+	// Sel is the indexes of the selected rows after filtering. All rows are selected if it is nil.
+	Sel []int
+}
+
+// NewColumnBatch creates a batch with the columns of the types
+func NewColumnBatch(types map[string]ast.DataType, capacity int) *ColumnBatch {
+	b := &ColumnBatch{
+		Tuples:  make([]*Tuple, 0, capacity),
+		Columns: make(map[string]*ColumnVector, len(types)),
+	}
+	for name, t := range types {
+		b.Columns[name] = newColumnVector(t, capacity)
+	}
+	return b
+}
+
+// AddTuple appends the tuple as a row and extracts its values to the columns
+func (b *ColumnBatch) AddTuple(t *Tuple) error {
+	for name, c := range b.Columns {
+		v, _ := t.Value(name, "")
+		if err := c.append(v); err != nil {
+			// Keep the columns aligned
+			b.truncate(len(b.Tuples))
+			return fmt.Errorf("invalid value of column %s: %v", name, err)
+		}
+	}
+	b.Tuples = append(b.Tuples, t)
+	return nil
+}
+
+func (b *ColumnBatch) truncate(l int) {
+	for _, c := range b.Columns {
+		c.Nulls = c.Nulls[:l]
+		switch c.Kind {
+		case ast.BIGINT:
+			c.Ints = c.Ints[:l]
+		case ast.FLOAT:
+			c.Floats = c.Floats[:l]
+		case ast.STRINGS:
+			c.Strs = c.Strs[:l]
+		case ast.BOOLEAN:
+			c.Bools = c.Bools[:l]
+		}
+	}
+}
+
+// Len returns the count of all rows in the batch
+func (b *ColumnBatch) Len() int {
+	return len(b.Tuples)
+}
+
+// Selected returns the selected tuples in order
+func (b *ColumnBatch) Selected() []*Tuple {
+	if b.Sel == nil {
+		return b.Tuples
+	}
+	r := make([]*Tuple, len(b.Sel))
+	for i, index := range b.Sel {
+		r[i] = b.Tuples[index]
+	}
+	return r
+}
@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -15,9 +15,11 @@
 package xsql
 
 import (
+	"io"
 	"sync"
 
 	"github.com/lf-edge/ekuiper/internal/binder/function"
+	"github.com/lf-edge/ekuiper/internal/conf"
 	"github.com/lf-edge/ekuiper/internal/topo/context"
 	"github.com/lf-edge/ekuiper/pkg/api"
 	"github.com/lf-edge/ekuiper/pkg/errorx"
@@ -29,6 +31,8 @@ type funcRuntime struct {
 	sync.Mutex
 	regs      []*funcReg
 	parentCtx api.StreamContext
+	// whether the function instances will be closed when the parent context is done
+	closing bool
 }
 
 type funcReg struct {
@@ -71,8 +75,42 @@ func (fp *funcRuntime) Get(name string, funcId int) (api.Function, api.FunctionC
 			ins: nf,
 			ctx: fctx,
 		}
+		if _, ok := nf.(io.Closer); ok {
+			fp.closeOnDone()
+		}
 		return nf, fctx, nil
 	} else {
 		return reg.ins, reg.ctx, nil
 	}
 }
+
+// closeOnDone closes the function instances once the operator stops. Must be called with lock.
+func (fp *funcRuntime) closeOnDone() {
+	if fp.closing || fp.parentCtx == nil || fp.parentCtx.Done() == nil {
+		return
+	}
+	fp.closing = true
+	done := fp.parentCtx.Done()
+	go func() {
+		<-done
+		fp.Close()
+	}()
+}
+
+// Close releases the function instances which hold resources such as the plugin connection or the wasm vm
+func (fp *funcRuntime) Close() {
+	fp.Lock()
+	defer fp.Unlock()
+	for i, reg := range fp.regs {
+		if reg == nil {
+			continue
+		}
+		if c, ok := reg.ins.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				conf.Log.Warnf("close function %d error: %v", i, err)
+			}
+		}
+		fp.regs[i] = nil
+	}
+	fp.closing = false
+}
@@ -0,0 +1,92 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xsql
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lf-edge/ekuiper/internal/binder"
+	"github.com/lf-edge/ekuiper/internal/binder/function"
+	"github.com/lf-edge/ekuiper/internal/conf"
+	"github.com/lf-edge/ekuiper/internal/plugin"
+	"github.com/lf-edge/ekuiper/internal/topo/context"
+	"github.com/lf-edge/ekuiper/pkg/api"
+)
+
+type closableFunc struct {
+	closed *int32
+}
+
+func (f *closableFunc) Validate(_ []interface{}) error {
+	return nil
+}
+
+func (f *closableFunc) Exec(_ []interface{}, _ api.FunctionContext) (interface{}, bool) {
+	return nil, true
+}
+
+func (f *closableFunc) IsAggregate() bool {
+	return false
+}
+
+func (f *closableFunc) Close() error {
+	atomic.AddInt32(f.closed, 1)
+	return nil
+}
+
+type closableFactory struct {
+	closed int32
+}
+
+func (m *closableFactory) Function(name string) (api.Function, error) {
+	if name != "closable" {
+		return nil, nil
+	}
+	return &closableFunc{closed: &m.closed}, nil
+}
+
+func (m *closableFactory) HasFunctionSet(_ string) bool {
+	return false
+}
+
+func (m *closableFactory) ConvName(name string) (string, bool) {
+	return name, name == "closable"
+}
+
+func (m *closableFactory) FunctionPluginInfo(_ string) (plugin.EXTENSION_TYPE, string, string) {
+	return plugin.NONE_EXTENSION, "", ""
+}
+
+func TestFuncRuntimeClose(t *testing.T) {
+	fac := &closableFactory{}
+	require.NoError(t, function.Initialize([]binder.FactoryEntry{{Name: "closable", Factory: fac}}))
+	ctx, cancel := context.WithValue(context.Background(), context.LoggerKey, conf.Log).WithCancel()
+	fp := NewFuncRuntime(ctx)
+	f1, _, err := fp.Get("closable", 0)
+	require.NoError(t, err)
+	f2, _, err := fp.Get("closable", 1)
+	require.NoError(t, err)
+	assert.NotSame(t, f1, f2)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&fac.closed))
+	cancel()
+	assert.Eventually(t, func() bool {
+		return atomic.LoadInt32(&fac.closed) == 2
+	}, time.Second, 10*time.Millisecond)
+}
@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -83,4 +83,8 @@ func init() {
 	Language.Handle(ast.DROP, func(p *Parser) (statement ast.Statement, e error) {
 		return p.parseDropStmt()
 	})
+
+	Language.Handle(ast.ALTER, func(p *Parser) (statement ast.Statement, e error) {
+		return p.parseAlterStmt()
+	})
 }
@@ -155,6 +155,22 @@ func (p *Parser) Parse() (*ast.SelectStatement, error) {
 	} else {
 		selects.Joins = joins
 	}
+	p.clause = "dedup"
+	if dedup, err := p.parseDedup(); err != nil {
+		return nil, err
+	} else {
+		selects.Dedup = dedup
+	}
+	p.clause = "pattern"
+	if pattern, err := p.parsePattern(); err != nil {
+		return nil, err
+	} else {
+		selects.Pattern = pattern
+	}
+	p.clause = "ratelimit"
+	if err := p.parseRateLimits(selects); err != nil {
+		return nil, err
+	}
 	// The source names may be injected from outside to parse part of the sql
 	if p.sourceNames == nil {
 		p.sourceNames = getStreamNames(selects)
@@ -230,6 +246,9 @@ func (p *Parser) parseSourceLiteral() (string, string, error) {
 				} else {
 					return "", "", fmt.Errorf("found %q, expected JOIN key word.", lit)
 				}
+			} else if tok1 == ast.IDENT && isClauseKeyword(lit1) {
+				p.unscan()
+				break
 			} else if tok1.AllowedSourceToken() {
 				sourceSeg = append(sourceSeg, lit1)
 			} else {
@@ -244,6 +263,325 @@ func (p *Parser) parseSourceLiteral() (string, string, error) {
 	return strings.Join(sourceSeg, ""), alias, nil
 }
 
+// clauseKeywords are the non-reserved words which start a clause after the FROM clause.
+// They are not keywords so that they can still be used as field names.
+var clauseKeywords = map[string]bool{
+	"DEDUP":    true,
+	"PATTERN":  true,
+	"SAMPLE":   true,
+	"THROTTLE": true,
+	"DEBOUNCE": true,
+}
+
+func isClauseKeyword(lit string) bool {
+	return clauseKeywords[strings.ToUpper(lit)]
+}
+
+// parseDedup parses the optional DEDUP BY clause such as DEDUP BY msgId WITHIN 10 MINUTES
+func (p *Parser) parseDedup() (*ast.Dedup, error) {
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.IDENT || !strings.EqualFold(lit, "DEDUP") {
+		p.unscan()
+		return nil, nil
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.BY {
+		return nil, fmt.Errorf("found %q, expected BY after DEDUP.", lit)
+	}
+	d := &ast.Dedup{}
+	for {
+		exp, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		d.Keys = append(d.Keys, exp)
+		if tok, _ := p.scanIgnoreWhitespace(); tok != ast.COMMA {
+			p.unscan()
+			break
+		}
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.IDENT || !strings.EqualFold(lit, "WITHIN") {
+		return nil, fmt.Errorf("found %q, expected WITHIN after the DEDUP BY keys.", lit)
+	}
+	il, err := p.parseDuration("WITHIN")
+	if err != nil {
+		return nil, err
+	}
+	if il == nil {
+		return nil, fmt.Errorf("the interval of DEDUP BY must be a positive duration like 10 MINUTES.")
+	}
+	d.Within = il
+	return d, nil
+}
+
+// parseDuration parses a duration such as 10 MINUTES after the given word. It returns nil if the unit is not a time unit
+// or the duration is not positive, so that the caller can report the error of its clause.
+func (p *Parser) parseDuration(after string) (*ast.IntervalLiteral, error) {
+	tok, lit := p.scanIgnoreWhitespace()
+	if tok != ast.INTEGER {
+		return nil, fmt.Errorf("found %q, expected an integer after %s.", lit, after)
+	}
+	v, err := strconv.Atoi(lit)
+	if err != nil {
+		return nil, fmt.Errorf("found %q, invalid interval value.", lit)
+	}
+	il, ok := p.parseMovingRange(&ast.IntegerLiteral{Val: v}).(*ast.IntervalLiteral)
+	if !ok || il.Val <= 0 {
+		return nil, nil
+	}
+	return il, nil
+}
+
+// parsePattern parses the optional PATTERN clause such as
+// PATTERN (A B+ C) WITHIN 30 SECONDS PARTITION BY deviceId DEFINE A AS temperature > 80, C AS pressure < 2
+func (p *Parser) parsePattern() (*ast.Pattern, error) {
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.IDENT || !strings.EqualFold(lit, "PATTERN") {
+		p.unscan()
+		return nil, nil
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.LPAREN {
+		return nil, fmt.Errorf("found %q, expected ( after PATTERN.", lit)
+	}
+	pt := &ast.Pattern{}
+	for {
+		tok, lit := p.scanIgnoreWhitespace()
+		if tok == ast.RPAREN {
+			break
+		}
+		if tok != ast.IDENT {
+			return nil, fmt.Errorf("found %q, expected pattern variable.", lit)
+		}
+		el := ast.PatternElement{Name: lit, Min: 1, Max: 1}
+		switch tok1, _ := p.scanIgnoreWhitespace(); tok1 {
+		case ast.ADD:
+			el.Max = -1
+		case ast.ASTERISK:
+			el.Min, el.Max = 0, -1
+		default:
+			p.unscan()
+		}
+		pt.Elements = append(pt.Elements, el)
+	}
+	if len(pt.Elements) == 0 {
+		return nil, fmt.Errorf("PATTERN requires at least one pattern variable.")
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.IDENT || !strings.EqualFold(lit, "WITHIN") {
+		return nil, fmt.Errorf("found %q, expected WITHIN after PATTERN.", lit)
+	}
+	il, err := p.parseDuration("WITHIN")
+	if err != nil {
+		return nil, err
+	}
+	if il == nil {
+		return nil, fmt.Errorf("the interval of PATTERN must be a positive duration like 30 SECONDS.")
+	}
+	pt.Within = il
+	if tok, _ := p.scanIgnoreWhitespace(); tok == ast.PARTITION {
+		if tok1, lit1 := p.scanIgnoreWhitespace(); tok1 != ast.BY {
+			return nil, fmt.Errorf("found %q, expected BY after PARTITION.", lit1)
+		}
+		for {
+			exp, err := p.ParseExpr()
+			if err != nil {
+				return nil, err
+			}
+			pt.Partition = append(pt.Partition, exp)
+			if tok1, _ := p.scanIgnoreWhitespace(); tok1 != ast.COMMA {
+				p.unscan()
+				break
+			}
+		}
+	} else {
+		p.unscan()
+	}
+	if tok, lit := p.scanIgnoreWhitespace(); tok != ast.IDENT || !strings.EqualFold(lit, "DEFINE") {
+		p.unscan()
+		return pt, nil
+	}
+	defined := make(map[string]bool)
+	for {
+		tok, lit := p.scanIgnoreWhitespace()
+		if tok != ast.IDENT {
+			return nil, fmt.Errorf("found %q, expected pattern variable in DEFINE.", lit)
+		}
+		found := false
+		for _, el := range pt.Elements {
+			if el.Name == lit {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("pattern variable %s in DEFINE is not found in PATTERN.", lit)
+		}
+		if defined[lit] {
+			return nil, fmt.Errorf("pattern variable %s is defined more than once.", lit)
+		}
+		defined[lit] = true
+		if tok1, lit1 := p.scanIgnoreWhitespace(); tok1 != ast.AS {
+			return nil, fmt.Errorf("found %q, expected AS after pattern variable %s.", lit1, lit)
+		}
+		exp, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		pt.Defines = append(pt.Defines, ast.PatternDefine{Name: lit, Condition: exp})
+		if tok1, _ := p.scanIgnoreWhitespace(); tok1 != ast.COMMA {
+			p.unscan()
+			break
+		}
+	}
+	return pt, nil
+}
+
+// parseRateLimits parses the optional SAMPLE, THROTTLE and DEBOUNCE clauses in any order
+func (p *Parser) parseRateLimits(selects *ast.SelectStatement) error {
+	for {
+		tok, lit := p.scanIgnoreWhitespace()
+		if tok != ast.IDENT {
+			p.unscan()
+			return nil
+		}
+		var err error
+		switch kw := strings.ToUpper(lit); kw {
+		case "SAMPLE":
+			if selects.Sample != nil {
+				return fmt.Errorf("duplicate SAMPLE clause.")
+			}
+			selects.Sample, err = p.parseSample()
+		case "THROTTLE":
+			if selects.Throttle != nil {
+				return fmt.Errorf("duplicate THROTTLE clause.")
+			}
+			selects.Throttle, err = p.parseThrottle()
+		case "DEBOUNCE":
+			if selects.Debounce != nil {
+				return fmt.Errorf("duplicate DEBOUNCE clause.")
+			}
+			selects.Debounce, err = p.parseDebounce()
+		default:
+			p.unscan()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// parseSample parses the clause after SAMPLE such as EVERY 10 BY deviceId or 5 PERCENT
+func (p *Parser) parseSample() (*ast.Sample, error) {
+	s := &ast.Sample{}
+	tok, lit := p.scanIgnoreWhitespace()
+	if tok == ast.IDENT && strings.EqualFold(lit, "EVERY") {
+		tok1, lit1 := p.scanIgnoreWhitespace()
+		if tok1 != ast.INTEGER {
+			return nil, fmt.Errorf("found %q, expected an integer after EVERY.", lit1)
+		}
+		v, err := strconv.Atoi(lit1)
+		if err != nil || v <= 0 {
+			return nil, fmt.Errorf("the value of SAMPLE EVERY must be a positive integer.")
+		}
+		s.Every = v
+		keys, err := p.parseOptionalBy()
+		if err != nil {
+			return nil, err
+		}
+		s.Keys = keys
+		return s, nil
+	}
+	if tok != ast.INTEGER && tok != ast.NUMBER {
+		return nil, fmt.Errorf("found %q, expected EVERY or a percentage after SAMPLE.", lit)
+	}
+	v, err := strconv.ParseFloat(lit, 64)
+	if err != nil {
+		return nil, fmt.Errorf("found %q, invalid percentage value.", lit)
+	}
+	if tok1, lit1 := p.scanIgnoreWhitespace(); tok1 != ast.IDENT || !strings.EqualFold(lit1, "PERCENT") {
+		return nil, fmt.Errorf("found %q, expected PERCENT after %s.", lit1, lit)
+	}
+	if v <= 0 || v > 100 {
+		return nil, fmt.Errorf("the percentage of SAMPLE must be in (0, 100].")
+	}
+	s.Percent = v
+	return s, nil
+}
+
+// parseThrottle parses the clause after THROTTLE such as 10 PER 1 SECOND BY deviceId POLICY LAST
+func (p *Parser) parseThrottle() (*ast.Throttle, error) {
+	tok, lit := p.scanIgnoreWhitespace()
+	if tok != ast.INTEGER {
+		return nil, fmt.Errorf("found %q, expected an integer after THROTTLE.", lit)
+	}
+	v, err := strconv.Atoi(lit)
+	if err != nil || v <= 0 {
+		return nil, fmt.Errorf("the limit of THROTTLE must be a positive integer.")
+	}
+	t := &ast.Throttle{Limit: v, Policy: ast.ThrottleFirst}
+	if tok1, lit1 := p.scanIgnoreWhitespace(); tok1 != ast.IDENT || !strings.EqualFold(lit1, "PER") {
+		return nil, fmt.Errorf("found %q, expected PER after the THROTTLE limit.", lit1)
+	}
+	il, err := p.parseDuration("PER")
+	if err != nil {
+		return nil, err
+	}
+	if il == nil {
+		return nil, fmt.Errorf("the interval of THROTTLE must be a positive duration like 1 SECOND.")
+	}
+	t.Interval = il
+	t.Keys, err = p.parseOptionalBy()
+	if err != nil {
+		return nil, err
+	}
+	if tok1, lit1 := p.scanIgnoreWhitespace(); tok1 == ast.IDENT && strings.EqualFold(lit1, "POLICY") {
+		_, lit2 := p.scanIgnoreWhitespace()
+		switch policy := strings.ToLower(lit2); policy {
+		case ast.ThrottleFirst, ast.ThrottleLast, ast.ThrottleDrop:
+			t.Policy = policy
+		default:
+			return nil, fmt.Errorf("found %q, expected FIRST, LAST or DROP after POLICY.", lit2)
+		}
+	} else {
+		p.unscan()
+	}
+	return t, nil
+}
+
+// parseDebounce parses the clause after DEBOUNCE such as 500 MS BY deviceId
+func (p *Parser) parseDebounce() (*ast.Debounce, error) {
+	il, err := p.parseDuration("DEBOUNCE")
+	if err != nil {
+		return nil, err
+	}
+	if il == nil {
+		return nil, fmt.Errorf("the quiet period of DEBOUNCE must be a positive duration like 500 MS.")
+	}
+	d := &ast.Debounce{Quiet: il}
+	d.Keys, err = p.parseOptionalBy()
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// parseOptionalBy parses the optional key expressions after BY
+func (p *Parser) parseOptionalBy() ([]ast.Expr, error) {
+	if tok, _ := p.scanIgnoreWhitespace(); tok != ast.BY {
+		p.unscan()
+		return nil, nil
+	}
+	var keys []ast.Expr
+	for {
+		exp, err := p.ParseExpr()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, exp)
+		if tok, _ := p.scanIgnoreWhitespace(); tok != ast.COMMA {
+			p.unscan()
+			return keys, nil
+		}
+	}
+}
+
 func (p *Parser) parseFieldNameSections(isSubField bool) ([]string, error) {
 	var fieldNameSects []string
 	for {
@@ -598,7 +936,7 @@ func (p *Parser) ParseExpr() (ast.Expr, error) {
 }
 
 func (p *Parser) parseBetween(lhs ast.Expr, op ast.Token) (ast.Expr, error) {
-	alhs, err := p.parseUnaryExpr(false)
+	alhs, err := p.parseArithmeticExpr()
 	if err != nil {
 		return nil, err
 	}
@@ -606,7 +944,7 @@ func (p *Parser) parseBetween(lhs ast.Expr, op ast.Token) (ast.Expr, error) {
 	if opp != ast.AND {
 		return nil, fmt.Errorf("expect AND expression after between but found %s", opp)
 	}
-	arhs, err := p.parseUnaryExpr(false)
+	arhs, err := p.parseArithmeticExpr()
 	if err != nil {
 		return nil, err
 	}
@@ -620,6 +958,96 @@ func (p *Parser) parseBetween(lhs ast.Expr, op ast.Token) (ast.Expr, error) {
 	}, nil
 }
 
+// parseArithmeticExpr parses the expression with only the arithmetic operators such as the bounds of between.
+// The operators with lower precedence like AND are left to the caller.
+func (p *Parser) parseArithmeticExpr() (ast.Expr, error) {
+	var err error
+	root := &ast.BinaryExpr{}
+	root.RHS, err = p.parseUnaryExpr(false)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, _ := p.scanIgnoreWhitespace()
+		if op == ast.ASTERISK {
+			op = ast.MUL
+		}
+		switch op {
+		case ast.ADD, ast.SUB, ast.MUL, ast.DIV, ast.MOD, ast.BITWISE_AND, ast.BITWISE_OR, ast.BITWISE_XOR:
+		default:
+			p.unscan()
+			return root.RHS, nil
+		}
+		rhs, err := p.parseUnaryExpr(false)
+		if err != nil {
+			return nil, err
+		}
+		for node := root; ; {
+			r, ok := node.RHS.(*ast.BinaryExpr)
+			if !ok || r.OP.Precedence() >= op.Precedence() {
+				node.RHS = &ast.BinaryExpr{LHS: node.RHS, RHS: rhs, OP: op}
+				break
+			}
+			node = r
+		}
+	}
+}
+
+// parseInterval parses the unit of the interval such as INTERVAL 5s or INTERVAL 10 MI
+func (p *Parser) parseInterval(val string) (ast.Expr, error) {
+	v, err := strconv.Atoi(val)
+	if err != nil {
+		return nil, fmt.Errorf("found %q, invalid interval value.", val)
+	}
+	tok, lit := p.scanIgnoreWhitespace()
+	if tok.IsTimeLiteral() {
+		return &ast.IntervalLiteral{Val: v, Unit: tok}, nil
+	}
+	if tok == ast.IDENT {
+		switch strings.ToLower(lit) {
+		case "d":
+			return &ast.IntervalLiteral{Val: v, Unit: ast.DD}, nil
+		case "h":
+			return &ast.IntervalLiteral{Val: v, Unit: ast.HH}, nil
+		case "m":
+			return &ast.IntervalLiteral{Val: v, Unit: ast.MI}, nil
+		case "s":
+			return &ast.IntervalLiteral{Val: v, Unit: ast.SS}, nil
+		}
+	}
+	return nil, fmt.Errorf("found %q, expected interval unit d, h, m, s, ms, dd, hh, mi or ss.", lit)
+}
+
+// parseMovingRange parses the unit of the range such as 10 ROWS or 5 MINUTES
+func (p *Parser) parseMovingRange(exp ast.Expr) ast.Expr {
+	il, ok := exp.(*ast.IntegerLiteral)
+	if !ok {
+		return exp
+	}
+	tok, lit := p.scanIgnoreWhitespace()
+	if tok.IsTimeLiteral() {
+		return &ast.IntervalLiteral{Val: int(il.Val), Unit: tok}
+	}
+	if tok == ast.IDENT {
+		switch strings.ToLower(lit) {
+		case "row", "rows":
+			return exp
+		case "millisecond", "milliseconds":
+			return &ast.IntervalLiteral{Val: int(il.Val), Unit: ast.MS}
+		case "second", "seconds":
+			return &ast.IntervalLiteral{Val: int(il.Val), Unit: ast.SS}
+		case "minute", "minutes":
+			return &ast.IntervalLiteral{Val: int(il.Val), Unit: ast.MI}
+		case "hour", "hours":
+			return &ast.IntervalLiteral{Val: int(il.Val), Unit: ast.HH}
+		case "day", "days":
+			return &ast.IntervalLiteral{Val: int(il.Val), Unit: ast.DD}
+		}
+	}
+	p.unscan()
+	return exp
+}
+
 func (p *Parser) parseUnaryExpr(isSubField bool) (ast.Expr, error) {
 	if tok1, _ := p.scanIgnoreWhitespace(); tok1 == ast.LPAREN {
 		expr, err := p.ParseExpr()
@@ -644,6 +1072,13 @@ func (p *Parser) parseUnaryExpr(isSubField bool) (ast.Expr, error) {
 	if tok == ast.CASE {
 		return p.parseCaseExpr()
 	} else if tok == ast.IDENT {
+		// INTERVAL is not a keyword, it is an interval only if followed by an integer
+		if strings.EqualFold(lit, "INTERVAL") {
+			if tok1, lit1 := p.scanIgnoreWhitespace(); tok1 == ast.INTEGER {
+				return p.parseInterval(lit1)
+			}
+			p.unscan()
+		}
 		if tok1, _ := p.scanIgnoreWhitespace(); tok1 == ast.LPAREN {
 			return p.parseCall(lit)
 		}
@@ -876,6 +1311,9 @@ func (p *Parser) parseCall(n string) (ast.Expr, error) {
 		if exp, err := p.ParseExpr(); err != nil {
 			return nil, err
 		} else {
+			if name == "moving_avg" && len(args) == 1 {
+				exp = p.parseMovingRange(exp)
+			}
 			if ft == ast.FuncTypeCols {
 				field := &ast.ColFuncField{Expr: exp, Name: nameExpr(exp)}
 				args = append(args, field)
@@ -899,6 +1337,11 @@ func (p *Parser) parseCall(n string) (ast.Expr, error) {
 		if name == "deduplicate" {
 			args = append([]ast.Expr{&ast.Wildcard{Token: ast.ASTERISK}}, args...)
 		}
+		// Add the range type for moving_avg
+		if name == "moving_avg" {
+			_, isTime := args[1].(*ast.IntervalLiteral)
+			args = append(args, &ast.BooleanLiteral{Val: isTime})
+		}
 		c := &ast.Call{Name: name, Args: args, FuncId: p.fn, FuncType: ft}
 		p.fn += 1
 		e := p.parseOver(c)
@@ -1155,6 +1598,9 @@ func validateStream(stmt *ast.StreamStmt) error {
 			return fmt.Errorf("option 'format=%s' is invalid", f)
 		}
 	}
+	if (stmt.Options.UNKNOWN_FIELDS != "" || stmt.Options.TYPE_MISMATCH != "") && !stmt.Options.STRICT_VALIDATION {
+		return fmt.Errorf("option 'unknown_fields' and 'type_mismatch' require 'strict_validation=true'")
+	}
 	return nil
 }
 
@@ -1252,6 +1698,56 @@ func (p *Parser) parseExplainStmt() (ast.Statement, error) {
 	}
 }
 
+func (p *Parser) parseAlterStmt() (ast.Statement, error) {
+	_, lit := p.scanIgnoreWhitespace()
+	lit = strings.ToUpper(lit)
+	if lit != ast.ALTER {
+		p.unscan()
+		return nil, nil
+	}
+	if _, lit1 := p.scanIgnoreWhitespace(); strings.ToUpper(lit1) != ast.STREAM {
+		return nil, fmt.Errorf("found %q, expected keyword stream.", lit1)
+	}
+	stmt := &ast.AlterStreamStatement{}
+	if tok2, lit2 := p.scanIgnoreWhitespace(); tok2 == ast.IDENT {
+		stmt.Name = lit2
+	} else {
+		return nil, fmt.Errorf("found %q, expected stream name.", lit2)
+	}
+	_, lit3 := p.scanIgnoreWhitespace()
+	switch strings.ToUpper(lit3) {
+	case ast.ADD_LIT:
+		stmt.Action = ast.AlterAddColumn
+	case ast.DROP:
+		stmt.Action = ast.AlterDropColumn
+	case ast.MODIFY:
+		stmt.Action = ast.AlterModifyColumn
+	default:
+		return nil, fmt.Errorf("found %q, expected ADD, DROP or MODIFY.", lit3)
+	}
+	tok4, lit4 := p.scanIgnoreWhitespace()
+	if strings.ToUpper(lit4) == ast.COLUMN {
+		tok4, lit4 = p.scanIgnoreWhitespace()
+	}
+	if tok4 != ast.IDENT {
+		return nil, fmt.Errorf("found %q, expected column name.", lit4)
+	}
+	stmt.Field.Name = lit4
+	if stmt.Action != ast.AlterDropColumn {
+		if ft, err := p.parseStreamFieldType(); err != nil {
+			return nil, err
+		} else {
+			stmt.Field.FieldType = ft
+		}
+	}
+	if tok5, lit5 := p.scanIgnoreWhitespace(); tok5 == ast.SEMICOLON {
+		p.unscan()
+	} else if tok5 != ast.EOF {
+		return nil, fmt.Errorf("found %q, expected semicolon or EOF.", lit5)
+	}
+	return stmt, nil
+}
+
 func (p *Parser) parseDropStmt() (ast.Statement, error) {
 	_, lit := p.scanIgnoreWhitespace()
 	lit = strings.ToUpper(lit)
@@ -1323,7 +1819,7 @@ func (p *Parser) parseStreamFields() (ast.StreamFields, error) {
 					}
 					p.unscan()
 					break
-				} else if tok2 == ast.RPAREN { // The nested type definition of ARRAY and Struct, such as "field ARRAY(STRUCT(f BIGINT))"
+				} else if tok2 == ast.RPAREN || tok2 == ast.EOF || tok2 == ast.SEMICOLON { // The nested type definition of ARRAY and Struct, such as "field ARRAY(STRUCT(f BIGINT))" or the type at the end of ALTER STREAM
 					if lStack.Len() > 0 {
 						return nil, fmt.Errorf("Parenthesis is not matched.")
 					}
@@ -1350,23 +1846,10 @@ func (p *Parser) parseStreamField() (*ast.StreamField, error) {
 	field := &ast.StreamField{}
 	if tok, lit := p.scanIgnoreWhitespace(); tok == ast.IDENT {
 		field.Name = lit
-		_, lit1 := p.scanIgnoreWhitespace()
-		if t := ast.GetDataType(lit1); t != ast.UNKNOWN && t.IsSimpleType() {
-			field.FieldType = &ast.BasicType{Type: t}
-		} else if t == ast.ARRAY {
-			if f, e := p.parseStreamArrayType(); e != nil {
-				return nil, e
-			} else {
-				field.FieldType = f
-			}
-		} else if t == ast.STRUCT {
-			if f, e := p.parseStreamStructType(); e != nil {
-				return nil, e
-			} else {
-				field.FieldType = f
-			}
-		} else if t == ast.UNKNOWN {
-			return nil, fmt.Errorf("found %q, expect valid stream field types(BIGINT | FLOAT | STRINGS | DATETIME | BOOLEAN | BYTEA | ARRAY | STRUCT).", lit1)
+		if ft, err := p.parseStreamFieldType(); err != nil {
+			return nil, err
+		} else {
+			field.FieldType = ft
 		}
 
 		if tok2, lit2 := p.scanIgnoreWhitespace(); tok2 == ast.COMMA {
@@ -1382,6 +1865,19 @@ func (p *Parser) parseStreamField() (*ast.StreamField, error) {
 	return field, nil
 }
 
+func (p *Parser) parseStreamFieldType() (ast.FieldType, error) {
+	_, lit := p.scanIgnoreWhitespace()
+	if t := ast.GetDataType(lit); t != ast.UNKNOWN && t.IsSimpleType() {
+		return &ast.BasicType{Type: t}, nil
+	} else if t == ast.ARRAY {
+		return p.parseStreamArrayType()
+	} else if t == ast.STRUCT {
+		return p.parseStreamStructType()
+	} else {
+		return nil, fmt.Errorf("found %q, expect valid stream field types(BIGINT | FLOAT | STRINGS | DATETIME | BOOLEAN | BYTEA | ARRAY | STRUCT).", lit)
+	}
+}
+
 func (p *Parser) parseStreamArrayType() (ast.FieldType, error) {
 	lStack := &stack.Stack{}
 	if tok, _ := p.scanIgnoreWhitespace(); tok == ast.LPAREN {
@@ -1429,7 +1925,7 @@ func (p *Parser) parseStreamStructType() (ast.FieldType, error) {
 		if tok2, lit2 := p.scanIgnoreWhitespace(); tok2 == ast.COMMA {
 			rf.StreamFields = sfs
 			p.unscan()
-		} else if tok2 == ast.RPAREN {
+		} else if tok2 == ast.RPAREN || tok2 == ast.EOF || tok2 == ast.SEMICOLON {
 			rf.StreamFields = sfs
 			p.unscan()
 		} else {
@@ -1473,6 +1969,20 @@ func (p *Parser) parseStreamOptions() (*ast.Options, error) {
 						case ast.KIND:
 							val := strings.ToLower(lit3)
 							opts.KIND = val
+						case ast.UNKNOWN_FIELDS:
+							switch val := strings.ToLower(lit3); val {
+							case ast.UnknownFieldsKeep, ast.UnknownFieldsDrop, ast.UnknownFieldsError:
+								opts.UNKNOWN_FIELDS = val
+							default:
+								return nil, fmt.Errorf("found %q, expect keep/drop/error value in %s option.", lit3, lit1)
+							}
+						case ast.TYPE_MISMATCH:
+							switch val := strings.ToLower(lit3); val {
+							case ast.TypeMismatchCoerce, ast.TypeMismatchNull, ast.TypeMismatchError, ast.TypeMismatchDeadLetter:
+								opts.TYPE_MISMATCH = val
+							default:
+								return nil, fmt.Errorf("found %q, expect coerce/null/error/deadletter value in %s option.", lit3, lit1)
+							}
 						default:
 							f := v.Elem().FieldByName(lit1)
 							if f.IsValid() {
@@ -1546,7 +2056,7 @@ func (p *Parser) parseOver(c *ast.Call) error {
 	if tok, _ := p.scanIgnoreWhitespace(); tok != ast.OVER {
 		p.unscan()
 		return nil
-	} else if function.IsAnalyticFunc(c.Name) {
+	} else if function.IsAnalyticFunc(c.Name) || function.IsWindowFunc(c.Name) {
 		if tok1, _ := p.scanIgnoreWhitespace(); tok1 == ast.LPAREN {
 			if t, _ := p.scanIgnoreWhitespace(); t == ast.PARTITION {
 				if t1, l1 := p.scanIgnoreWhitespace(); t1 == ast.BY {
@@ -1573,6 +2083,18 @@ func (p *Parser) parseOver(c *ast.Call) error {
 			} else {
 				p.unscan()
 			}
+			// Window functions have an optional order by instead of when
+			if function.IsWindowFunc(c.Name) {
+				sorts, err := p.parseSorts()
+				if err != nil {
+					return err
+				}
+				c.SortFields = sorts
+				if ttt, lit := p.scanIgnoreWhitespace(); ttt != ast.RPAREN {
+					return fmt.Errorf("Found %q, expect right parentheses after OVER ", lit)
+				}
+				return nil
+			}
 
 			if t, _ := p.scanIgnoreWhitespace(); t == ast.WHEN {
 				if exp, err := p.ParseExpr(); err != nil {
@@ -694,6 +694,82 @@ func TestParser_ParseStatement(t *testing.T) {
 			},
 		},
 
+		{
+			s: `SELECT moving_avg(a, 10 ROWS), moving_avg(a, 5 MINUTES) FROM tbl`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						AName: "",
+						Name:  "moving_avg",
+						Expr: &ast.Call{
+							Name: "moving_avg",
+							Args: []ast.Expr{&ast.FieldRef{Name: "a", StreamName: ast.DefaultStream}, &ast.IntegerLiteral{Val: 10}, &ast.BooleanLiteral{Val: false}},
+						},
+					},
+					{
+						AName: "",
+						Name:  "moving_avg",
+						Expr: &ast.Call{
+							Name:   "moving_avg",
+							Args:   []ast.Expr{&ast.FieldRef{Name: "a", StreamName: ast.DefaultStream}, &ast.IntervalLiteral{Val: 5, Unit: ast.MI}, &ast.BooleanLiteral{Val: true}},
+							FuncId: 1,
+						},
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "tbl"}},
+			},
+		},
+
+		{
+			s:    `SELECT moving_avg(a, 10 apples) FROM tbl`,
+			stmt: nil,
+			err:  `found function call "moving_avg", expected ), but with "apples".`,
+		},
+
+		{
+			s: `SELECT acc_sum(a, b > 10) FROM tbl`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						AName: "",
+						Name:  "acc_sum",
+						Expr: &ast.Call{
+							Name: "acc_sum",
+							Args: []ast.Expr{&ast.FieldRef{Name: "a", StreamName: ast.DefaultStream}, &ast.BinaryExpr{OP: ast.GT, LHS: &ast.FieldRef{Name: "b", StreamName: ast.DefaultStream}, RHS: &ast.IntegerLiteral{Val: 10}}},
+						},
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "tbl"}},
+			},
+		},
+
+		{
+			s: `SELECT rank() OVER (PARTITION BY a ORDER BY b DESC, c) FROM tbl`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						AName: "",
+						Name:  "rank",
+						Expr: &ast.Call{
+							Name:      "rank",
+							Partition: &ast.PartitionExpr{Exprs: []ast.Expr{&ast.FieldRef{Name: "a", StreamName: ast.DefaultStream}}},
+							SortFields: []ast.SortField{
+								{Name: "b", Uname: "b", Ascending: false, FieldExpr: &ast.FieldRef{Name: "b", StreamName: ast.DefaultStream}},
+								{Name: "c", Uname: "c", Ascending: true, FieldExpr: &ast.FieldRef{Name: "c", StreamName: ast.DefaultStream}},
+							},
+						},
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "tbl"}},
+			},
+		},
+
+		{
+			s:    `SELECT rank() OVER (WHEN a > 1) FROM tbl`,
+			stmt: nil,
+			err:  `Found "WHEN", expect right parentheses after OVER `,
+		},
+
 		{
 			s: `SELECT deduplicate(temperature, false) FROM tbl`,
 			stmt: &ast.SelectStatement{
@@ -4033,6 +4109,342 @@ func TestParser_ParseJoins(t *testing.T) {
 				},
 			},
 		},
+		{
+			s: `SELECT * FROM a INNER JOIN b ON a.id = b.id AND b.ts BETWEEN a.ts - INTERVAL 5s AND a.ts + INTERVAL 500 ms`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.Wildcard{Token: ast.ASTERISK},
+						Name:  "*",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "a"}},
+				Joins: []ast.Join{
+					{
+						Name: "b", Alias: "", JoinType: ast.INNER_JOIN, Expr: &ast.BinaryExpr{
+							LHS: &ast.BinaryExpr{
+								LHS: &ast.FieldRef{StreamName: ast.StreamName("a"), Name: "id"},
+								OP:  ast.EQ,
+								RHS: &ast.FieldRef{StreamName: ast.StreamName("b"), Name: "id"},
+							},
+							OP: ast.AND,
+							RHS: &ast.BinaryExpr{
+								LHS: &ast.FieldRef{StreamName: ast.StreamName("b"), Name: "ts"},
+								OP:  ast.BETWEEN,
+								RHS: &ast.BetweenExpr{
+									Lower: &ast.BinaryExpr{
+										LHS: &ast.FieldRef{StreamName: ast.StreamName("a"), Name: "ts"},
+										OP:  ast.SUB,
+										RHS: &ast.IntervalLiteral{Val: 5, Unit: ast.SS},
+									},
+									Higher: &ast.BinaryExpr{
+										LHS: &ast.FieldRef{StreamName: ast.StreamName("a"), Name: "ts"},
+										OP:  ast.ADD,
+										RHS: &ast.IntervalLiteral{Val: 500, Unit: ast.MS},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			s:   `SELECT * FROM a INNER JOIN b ON b.ts BETWEEN a.ts - INTERVAL 5 weeks AND a.ts`,
+			err: `found "weeks", expected interval unit d, h, m, s, ms, dd, hh, mi or ss.`,
+		},
+		{
+			s: `SELECT interval FROM a`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.FieldRef{StreamName: ast.DefaultStream, Name: "interval"},
+						Name:  "interval",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "a"}},
+			},
+		},
+	}
+
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	for i, tt := range tests {
+		stmt, err := NewParser(strings.NewReader(tt.s)).Parse()
+		if !reflect.DeepEqual(tt.err, testx.Errstring(err)) {
+			t.Errorf("%d. %q: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.s, tt.err, err)
+		} else if tt.err == "" && !reflect.DeepEqual(tt.stmt, stmt) {
+			t.Errorf("%d. %q\n\nstmt mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.s, tt.stmt, stmt)
+		}
+	}
+}
+
+func TestParser_ParseDedup(t *testing.T) {
+	tests := []struct {
+		s    string
+		stmt *ast.SelectStatement
+		err  string
+	}{
+		{
+			s: `SELECT * FROM demo DEDUP BY msgId WITHIN 10 MINUTES WHERE temp > 20`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.Wildcard{Token: ast.ASTERISK},
+						Name:  "*",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "demo"}},
+				Dedup: &ast.Dedup{
+					Keys:   []ast.Expr{&ast.FieldRef{StreamName: ast.DefaultStream, Name: "msgId"}},
+					Within: &ast.IntervalLiteral{Val: 10, Unit: ast.MI},
+				},
+				Condition: &ast.BinaryExpr{
+					LHS: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "temp"},
+					OP:  ast.GT,
+					RHS: &ast.IntegerLiteral{Val: 20},
+				},
+			},
+		},
+		{
+			s: `SELECT dedup FROM demo dedup by deviceId, meta(topic) within 500 ms`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.FieldRef{StreamName: ast.DefaultStream, Name: "dedup"},
+						Name:  "dedup",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "demo"}},
+				Dedup: &ast.Dedup{
+					Keys: []ast.Expr{
+						&ast.FieldRef{StreamName: ast.DefaultStream, Name: "deviceId"},
+						&ast.Call{Name: "meta", FuncType: ast.FuncTypeScalar, Args: []ast.Expr{&ast.MetaRef{StreamName: ast.DefaultStream, Name: "topic"}}},
+					},
+					Within: &ast.IntervalLiteral{Val: 500, Unit: ast.MS},
+				},
+			},
+		},
+		{
+			s:   `SELECT * FROM demo DEDUP msgId WITHIN 10 MINUTES`,
+			err: `found "msgId", expected BY after DEDUP.`,
+		},
+		{
+			s:   `SELECT * FROM demo DEDUP BY msgId`,
+			err: `found "EOF", expected WITHIN after the DEDUP BY keys.`,
+		},
+		{
+			s:   `SELECT * FROM demo DEDUP BY msgId WITHIN 10 ROWS`,
+			err: `the interval of DEDUP BY must be a positive duration like 10 MINUTES.`,
+		},
+		{
+			s:   `SELECT * FROM demo DEDUP BY count(msgId) WITHIN 10 SS`,
+			err: `Not allowed to call aggregate functions in DEDUP BY clause.`,
+		},
+	}
+
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	for i, tt := range tests {
+		stmt, err := NewParser(strings.NewReader(tt.s)).Parse()
+		if !reflect.DeepEqual(tt.err, testx.Errstring(err)) {
+			t.Errorf("%d. %q: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.s, tt.err, err)
+		} else if tt.err == "" && !reflect.DeepEqual(tt.stmt, stmt) {
+			t.Errorf("%d. %q\n\nstmt mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.s, tt.stmt, stmt)
+		}
+	}
+}
+
+func TestParser_ParsePattern(t *testing.T) {
+	tests := []struct {
+		s    string
+		stmt *ast.SelectStatement
+		err  string
+	}{
+		{
+			s: `SELECT count(*) AS c FROM demo PATTERN (A B+ C*) WITHIN 30 SECONDS PARTITION BY deviceId DEFINE A AS temp > 80, B AS temp > 90`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.Call{Name: "count", FuncType: ast.FuncTypeAgg, Args: []ast.Expr{&ast.Wildcard{Token: ast.ASTERISK}}},
+						Name:  "count",
+						AName: "c",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "demo"}},
+				Pattern: &ast.Pattern{
+					Elements: []ast.PatternElement{
+						{Name: "A", Min: 1, Max: 1},
+						{Name: "B", Min: 1, Max: -1},
+						{Name: "C", Min: 0, Max: -1},
+					},
+					Within:    &ast.IntervalLiteral{Val: 30, Unit: ast.SS},
+					Partition: []ast.Expr{&ast.FieldRef{StreamName: ast.DefaultStream, Name: "deviceId"}},
+					Defines: []ast.PatternDefine{
+						{
+							Name: "A",
+							Condition: &ast.BinaryExpr{
+								LHS: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "temp"},
+								OP:  ast.GT,
+								RHS: &ast.IntegerLiteral{Val: 80},
+							},
+						},
+						{
+							Name: "B",
+							Condition: &ast.BinaryExpr{
+								LHS: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "temp"},
+								OP:  ast.GT,
+								RHS: &ast.IntegerLiteral{Val: 90},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			s: `SELECT * FROM demo PATTERN (A B) WITHIN 5 SS WHERE temp > 20`,
+			stmt: &ast.SelectStatement{
+				Fields: []ast.Field{
+					{
+						Expr:  &ast.Wildcard{Token: ast.ASTERISK},
+						Name:  "*",
+						AName: "",
+					},
+				},
+				Sources: []ast.Source{&ast.Table{Name: "demo"}},
+				Pattern: &ast.Pattern{
+					Elements: []ast.PatternElement{
+						{Name: "A", Min: 1, Max: 1},
+						{Name: "B", Min: 1, Max: 1},
+					},
+					Within: &ast.IntervalLiteral{Val: 5, Unit: ast.SS},
+				},
+				Condition: &ast.BinaryExpr{
+					LHS: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "temp"},
+					OP:  ast.GT,
+					RHS: &ast.IntegerLiteral{Val: 20},
+				},
+			},
+		},
+		{
+			s:   `SELECT * FROM demo PATTERN () WITHIN 5 SS`,
+			err: `PATTERN requires at least one pattern variable.`,
+		},
+		{
+			s:   `SELECT * FROM demo PATTERN (A B)`,
+			err: `found "EOF", expected WITHIN after PATTERN.`,
+		},
+		{
+			s:   `SELECT * FROM demo PATTERN (A B) WITHIN 5 SS DEFINE D AS temp > 1`,
+			err: `pattern variable D in DEFINE is not found in PATTERN.`,
+		},
+		{
+			s:   `SELECT * FROM demo PATTERN (A B) WITHIN 5 SS DEFINE A AS temp > 1, A AS temp < 1`,
+			err: `pattern variable A is defined more than once.`,
+		},
+		{
+			s:   `SELECT * FROM demo PATTERN (A B) WITHIN 5 SS DEFINE A AS avg(temp) > 1`,
+			err: `Not allowed to call aggregate functions in DEFINE clause.`,
+		},
+	}
+
+	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
+	for i, tt := range tests {
+		stmt, err := NewParser(strings.NewReader(tt.s)).Parse()
+		if !reflect.DeepEqual(tt.err, testx.Errstring(err)) {
+			t.Errorf("%d. %q: error mismatch:\n  exp=%s\n  got=%s\n\n", i, tt.s, tt.err, err)
+		} else if tt.err == "" && !reflect.DeepEqual(tt.stmt, stmt) {
+			t.Errorf("%d. %q\n\nstmt mismatch:\n\nexp=%#v\n\ngot=%#v\n\n", i, tt.s, tt.stmt, stmt)
+		}
+	}
+}
+
+func TestParser_ParseRateLimits(t *testing.T) {
+	star := []ast.Field{
+		{
+			Expr:  &ast.Wildcard{Token: ast.ASTERISK},
+			Name:  "*",
+			AName: "",
+		},
+	}
+	tests := []struct {
+		s    string
+		stmt *ast.SelectStatement
+		err  string
+	}{
+		{
+			s: `SELECT * FROM demo THROTTLE 10 PER 1 SECOND BY deviceId POLICY LAST WHERE temp > 20`,
+			stmt: &ast.SelectStatement{
+				Fields:  star,
+				Sources: []ast.Source{&ast.Table{Name: "demo"}},
+				Throttle: &ast.Throttle{
+					Limit:    10,
+					Interval: &ast.IntervalLiteral{Val: 1, Unit: ast.SS},
+					Keys:     []ast.Expr{&ast.FieldRef{StreamName: ast.DefaultStream, Name: "deviceId"}},
+					Policy:   ast.ThrottleLast,
+				},
+				Condition: &ast.BinaryExpr{
+					LHS: &ast.FieldRef{StreamName: ast.DefaultStream, Name: "temp"},
+					OP:  ast.GT,
+					RHS: &ast.IntegerLiteral{Val: 20},
+				},
+			},
+		},
+		{
+			s: `SELECT * FROM demo DEBOUNCE 500 MS BY deviceId SAMPLE EVERY 10 THROTTLE 5 PER 1 MI`,
+			stmt: &ast.SelectStatement{
+				Fields:  star,
+				Sources: []ast.Source{&ast.Table{Name: "demo"}},
+				Sample:  &ast.Sample{Every: 10},
+				Throttle: &ast.Throttle{
+					Limit:    5,
+					Interval: &ast.IntervalLiteral{Val: 1, Unit: ast.MI},
+					Policy:   ast.ThrottleFirst,
+				},
+				Debounce: &ast.Debounce{
+					Quiet: &ast.IntervalLiteral{Val: 500, Unit: ast.MS},
+					Keys:  []ast.Expr{&ast.FieldRef{StreamName: ast.DefaultStream, Name: "deviceId"}},
+				},
+			},
+		},
+		{
+			s: `SELECT * FROM demo sample 0.5 percent`,
+			stmt: &ast.SelectStatement{
+				Fields:  star,
+				Sources: []ast.Source{&ast.Table{Name: "demo"}},
+				Sample:  &ast.Sample{Percent: 0.5},
+			},
+		},
+		{
+			s:   `SELECT * FROM demo SAMPLE 150 PERCENT`,
+			err: `the percentage of SAMPLE must be in (0, 100].`,
+		},
+		{
+			s:   `SELECT * FROM demo SAMPLE EVERY 0`,
+			err: `the value of SAMPLE EVERY must be a positive integer.`,
+		},
+		{
+			s:   `SELECT * FROM demo THROTTLE 10 IN 1 SS`,
+			err: `found "IN", expected PER after the THROTTLE limit.`,
+		},
+		{
+			s:   `SELECT * FROM demo THROTTLE 10 PER 1 SS POLICY NEWEST`,
+			err: `found "NEWEST", expected FIRST, LAST or DROP after POLICY.`,
+		},
+		{
+			s:   `SELECT * FROM demo DEBOUNCE 10 ROWS`,
+			err: `the quiet period of DEBOUNCE must be a positive duration like 500 MS.`,
+		},
+		{
+			s:   `SELECT * FROM demo DEBOUNCE 1 SS DEBOUNCE 2 SS`,
+			err: `duplicate DEBOUNCE clause.`,
+		},
+		{
+			s:   `SELECT * FROM demo DEBOUNCE 1 SS BY count(*)`,
+			err: `Not allowed to call aggregate functions in the keys of SAMPLE, THROTTLE or DEBOUNCE clause.`,
+		},
 	}
 
 	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
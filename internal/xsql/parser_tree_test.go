@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -201,6 +201,75 @@ func TestParser_ParseTree(t *testing.T) {
 			},
 			err: ``,
 		},
+		{
+			s: `CREATE STREAM demo (
+					USERID BIGINT,
+				) WITH (DATASOURCE="users", STRICT_VALIDATION="true", UNKNOWN_FIELDS="Drop", TYPE_MISMATCH="deadletter");`,
+			stmt: &ast.StreamStmt{
+				Name: ast.StreamName("demo"),
+				StreamFields: []ast.StreamField{
+					{Name: "USERID", FieldType: &ast.BasicType{Type: ast.BIGINT}},
+				},
+				Options: &ast.Options{
+					DATASOURCE:        "users",
+					STRICT_VALIDATION: true,
+					UNKNOWN_FIELDS:    ast.UnknownFieldsDrop,
+					TYPE_MISMATCH:     ast.TypeMismatchDeadLetter,
+				},
+			},
+		},
+		{
+			s:   `CREATE STREAM demo (USERID BIGINT) WITH (DATASOURCE="users", TYPE_MISMATCH="null");`,
+			err: `option 'unknown_fields' and 'type_mismatch' require 'strict_validation=true'`,
+		},
+		{
+			s:   `CREATE STREAM demo (USERID BIGINT) WITH (DATASOURCE="users", STRICT_VALIDATION="true", TYPE_MISMATCH="ignore");`,
+			err: `found "ignore", expect coerce/null/error/deadletter value in TYPE_MISMATCH option.`,
+		},
+		{
+			s: `ALTER STREAM demo ADD COLUMN temperature float`,
+			stmt: &ast.AlterStreamStatement{
+				Name:   "demo",
+				Action: ast.AlterAddColumn,
+				Field:  ast.StreamField{Name: "temperature", FieldType: &ast.BasicType{Type: ast.FLOAT}},
+			},
+		},
+		{
+			s: `ALTER STREAM demo MODIFY tags ARRAY(STRUCT(k STRING, v BIGINT));`,
+			stmt: &ast.AlterStreamStatement{
+				Name:   "demo",
+				Action: ast.AlterModifyColumn,
+				Field: ast.StreamField{Name: "tags", FieldType: &ast.ArrayType{
+					Type: ast.STRUCT,
+					FieldType: &ast.RecType{
+						StreamFields: []ast.StreamField{
+							{Name: "k", FieldType: &ast.BasicType{Type: ast.STRINGS}},
+							{Name: "v", FieldType: &ast.BasicType{Type: ast.BIGINT}},
+						},
+					},
+				}},
+			},
+		},
+		{
+			s: `ALTER STREAM demo DROP COLUMN humidity`,
+			stmt: &ast.AlterStreamStatement{
+				Name:   "demo",
+				Action: ast.AlterDropColumn,
+				Field:  ast.StreamField{Name: "humidity"},
+			},
+		},
+		{
+			s:   `ALTER STREAM demo RENAME COLUMN humidity`,
+			err: `found "RENAME", expected ADD, DROP or MODIFY.`,
+		},
+		{
+			s:   `ALTER STREAM demo ADD COLUMN humidity`,
+			err: `found "EOF", expect valid stream field types(BIGINT | FLOAT | STRINGS | DATETIME | BOOLEAN | BYTEA | ARRAY | STRUCT).`,
+		},
+		{
+			s:   `ALTER TABLE demo DROP COLUMN humidity`,
+			err: `found "TABLE", expected keyword stream.`,
+		},
 	}
 
 	fmt.Printf("The test bucket size is %d.\n\n", len(tests))
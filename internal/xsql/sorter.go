@@ -1,4 +1,4 @@
-// Copyright 2022 EMQ Technologies Co., Ltd.
+// Copyright 2022-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -169,3 +169,10 @@ func validate(t string, v interface{}) error {
 		return fmt.Errorf("incompatible types for comparison: %s and %s", t, vt)
 	}
 }
+
+// LessValue reports whether the non-nil value a is ordered before b by the same rules of ORDER BY.
+// Incompatible values are not less than each other.
+func LessValue(a, b interface{}) bool {
+	r, _ := (&ValuerEval{}).simpleDataEval(a, b, ast.LT).(bool)
+	return r
+}
@@ -22,6 +22,21 @@ import (
 
 type ErrorSourceTuple struct {
 	Error error `json:"error"`
+	// Payload is the raw data which fails to decode, if any
+	Payload []byte `json:"-"`
+}
+
+// DeadLetterError marks an event which must not go downstream but to the dead letter of the rule
+type DeadLetterError struct {
+	Err error
+}
+
+func (e *DeadLetterError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *DeadLetterError) Unwrap() error {
+	return e.Err
 }
 
 func (t *ErrorSourceTuple) Message() map[string]interface{} {
@@ -33,6 +33,43 @@ func Validate(stmt *ast.SelectStatement) error {
 		}
 	}
 
+	if stmt.Dedup != nil {
+		for _, k := range stmt.Dedup.Keys {
+			if HasAggFuncs(k) {
+				return fmt.Errorf("Not allowed to call aggregate functions in DEDUP BY clause.")
+			}
+		}
+	}
+
+	var limitKeys []ast.Expr
+	if stmt.Sample != nil {
+		limitKeys = append(limitKeys, stmt.Sample.Keys...)
+	}
+	if stmt.Throttle != nil {
+		limitKeys = append(limitKeys, stmt.Throttle.Keys...)
+	}
+	if stmt.Debounce != nil {
+		limitKeys = append(limitKeys, stmt.Debounce.Keys...)
+	}
+	for _, k := range limitKeys {
+		if HasAggFuncs(k) {
+			return fmt.Errorf("Not allowed to call aggregate functions in the keys of SAMPLE, THROTTLE or DEBOUNCE clause.")
+		}
+	}
+
+	if stmt.Pattern != nil {
+		for _, k := range stmt.Pattern.Partition {
+			if HasAggFuncs(k) {
+				return fmt.Errorf("Not allowed to call aggregate functions in PATTERN PARTITION BY clause.")
+			}
+		}
+		for _, d := range stmt.Pattern.Defines {
+			if HasAggFuncs(d.Condition) {
+				return fmt.Errorf("Not allowed to call aggregate functions in DEFINE clause.")
+			}
+		}
+	}
+
 	if err := validateSRFNestedForbidden("select", stmt.Fields); err != nil {
 		return err
 	}
@@ -158,6 +195,9 @@ func validateExpr(expr ast.Expr, streamName []string) ast.Expr {
 		if e.WhenExpr != nil {
 			e.WhenExpr = validateExpr(e.WhenExpr, streamName)
 		}
+		for i, sf := range e.SortFields {
+			e.SortFields[i].FieldExpr = validateExpr(sf.FieldExpr, streamName)
+		}
 		return e
 	case *ast.BinaryExpr:
 		exp := ast.BinaryExpr{}
@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -58,6 +58,18 @@ type StreamInfo struct {
 	StreamType ast.StreamType `json:"streamType"`
 	StreamKind string         `json:"streamKind"`
 	Statement  string         `json:"statement"`
+	// The schema history, the last one is the current version. Empty for the sources created before versioning
+	Versions []*SchemaVersion `json:"versions,omitempty"`
+}
+
+// SchemaVersion is a revision of the source definition
+type SchemaVersion struct {
+	Version int `json:"version"`
+	// The full create statement of this version
+	Statement string `json:"statement"`
+	// The statement which produced this version, it is the create statement for the first version
+	Change    string `json:"change"`
+	Timestamp int64  `json:"timestamp"`
 }
 
 func GetDataSourceStatement(m kv.KeyValue, name string) (*StreamInfo, error) {
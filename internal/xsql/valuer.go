@@ -241,9 +241,16 @@ func (v *ValuerEval) Eval(expr ast.Expr) interface{} {
 	}
 	switch expr := expr.(type) {
 	case *ast.BinaryExpr:
+		if expr.Cached {
+			if val, ok := v.Valuer.Value(expr.CachedField, ""); ok {
+				return val
+			}
+		}
 		return v.evalBinaryExpr(expr)
 	case *ast.IntegerLiteral:
 		return expr.Val
+	case *ast.IntervalLiteral:
+		return expr.ToMilli()
 	case *ast.NumberLiteral:
 		return expr.Val
 	case *ast.ParenExpr:
@@ -1086,6 +1093,17 @@ func (v *ValuerEval) simpleDataEval(lhs, rhs interface{}, op ast.Token) interfac
 			return invalidOpError(lhs, op, rhs)
 		}
 	case time.Time:
+		// Add or subtract the duration in milliseconds such as an interval
+		if op == ast.ADD || op == ast.SUB {
+			d, ok := rhs.(int64)
+			if !ok {
+				return invalidOpError(lhs, op, rhs)
+			}
+			if op == ast.SUB {
+				d = -d
+			}
+			return lhs.Add(time.Duration(d) * time.Millisecond)
+		}
 		rt, err := cast.InterfaceToTime(rhs, "")
 		if err != nil {
 			return invalidOpError(lhs, op, rhs)
@@ -0,0 +1,357 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xsql
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+// VectorType returns the result type of the expression if it can be evaluated over the columns of the types.
+// Only the arithmetic, comparison and logical operators over the columns and literals are supported.
+func VectorType(expr ast.Expr, types map[string]ast.DataType) (ast.DataType, bool) {
+	switch e := expr.(type) {
+	case *ast.FieldRef:
+		if e.IsAlias() {
+			return ast.UNKNOWN, false
+		}
+		t, ok := types[e.Name]
+		return t, ok && isVectorKind(t)
+	case *ast.IntegerLiteral:
+		return ast.BIGINT, true
+	case *ast.NumberLiteral:
+		return ast.FLOAT, true
+	case *ast.StringLiteral:
+		return ast.STRINGS, true
+	case *ast.BooleanLiteral:
+		return ast.BOOLEAN, true
+	case *ast.ParenExpr:
+		return VectorType(e.Expr, types)
+	case *ast.BinaryExpr:
+		lt, ok := VectorType(e.LHS, types)
+		if !ok {
+			return ast.UNKNOWN, false
+		}
+		rt, ok := VectorType(e.RHS, types)
+		if !ok {
+			return ast.UNKNOWN, false
+		}
+		switch e.OP {
+		case ast.AND, ast.OR:
+			return ast.BOOLEAN, lt == ast.BOOLEAN && rt == ast.BOOLEAN
+		case ast.ADD, ast.SUB, ast.MUL, ast.DIV, ast.MOD:
+			if !isNumericKind(lt) || !isNumericKind(rt) {
+				return ast.UNKNOWN, false
+			}
+			if lt == ast.BIGINT && rt == ast.BIGINT {
+				return ast.BIGINT, true
+			}
+			return ast.FLOAT, true
+		case ast.EQ, ast.NEQ:
+			return ast.BOOLEAN, lt == rt || (isNumericKind(lt) && isNumericKind(rt))
+		case ast.LT, ast.LTE, ast.GT, ast.GTE:
+			return ast.BOOLEAN, (lt == ast.STRINGS && rt == ast.STRINGS) || (isNumericKind(lt) && isNumericKind(rt))
+		}
+	}
+	return ast.UNKNOWN, false
+}
+
+func isVectorKind(t ast.DataType) bool {
+	return t == ast.BIGINT || t == ast.FLOAT || t == ast.STRINGS || t == ast.BOOLEAN
+}
+
+func isNumericKind(t ast.DataType) bool {
+	return t == ast.BIGINT || t == ast.FLOAT
+}
+
+// EvalVector evaluates the expression over all the rows of the batch. The expression must be checked by VectorType.
+// The null values follow the same rules as the row based evaluation.
+func EvalVector(expr ast.Expr, b *ColumnBatch) (*ColumnVector, error) {
+	n := b.Len()
+	switch e := expr.(type) {
+	case *ast.FieldRef:
+		c, ok := b.Columns[e.Name]
+		if !ok {
+			return nil, fmt.Errorf("column %s not found in the batch", e.Name)
+		}
+		return c, nil
+	case *ast.IntegerLiteral:
+		c := &ColumnVector{Kind: ast.BIGINT, Ints: make([]int64, n), Nulls: make([]bool, n)}
+		for i := range c.Ints {
+			c.Ints[i] = int64(e.Val)
+		}
+		return c, nil
+	case *ast.NumberLiteral:
+		c := &ColumnVector{Kind: ast.FLOAT, Floats: make([]float64, n), Nulls: make([]bool, n)}
+		for i := range c.Floats {
+			c.Floats[i] = e.Val
+		}
+		return c, nil
+	case *ast.StringLiteral:
+		c := &ColumnVector{Kind: ast.STRINGS, Strs: make([]string, n), Nulls: make([]bool, n)}
+		for i := range c.Strs {
+			c.Strs[i] = e.Val
+		}
+		return c, nil
+	case *ast.BooleanLiteral:
+		c := &ColumnVector{Kind: ast.BOOLEAN, Bools: make([]bool, n), Nulls: make([]bool, n)}
+		for i := range c.Bools {
+			c.Bools[i] = e.Val
+		}
+		return c, nil
+	case *ast.ParenExpr:
+		return EvalVector(e.Expr, b)
+	case *ast.BinaryExpr:
+		l, err := EvalVector(e.LHS, b)
+		if err != nil {
+			return nil, err
+		}
+		r, err := EvalVector(e.RHS, b)
+		if err != nil {
+			return nil, err
+		}
+		switch e.OP {
+		case ast.AND, ast.OR:
+			return logicalVector(e.OP, l, r)
+		case ast.ADD, ast.SUB, ast.MUL, ast.DIV, ast.MOD:
+			return arithVector(e.OP, l, r)
+		case ast.EQ, ast.NEQ, ast.LT, ast.LTE, ast.GT, ast.GTE:
+			return compareVector(e.OP, l, r)
+		}
+	}
+	return nil, fmt.Errorf("unsupported expression %v for vectorized evaluation", expr)
+}
+
+// logicalVector follows the short circuit of the row evaluation: false AND x is false, true OR x is true
+// and other operations with null are null
+func logicalVector(op ast.Token, l, r *ColumnVector) (*ColumnVector, error) {
+	if l.Kind != ast.BOOLEAN || r.Kind != ast.BOOLEAN {
+		return nil, fmt.Errorf("invalid operation %s %s %s", l.Kind, op, r.Kind)
+	}
+	n := l.Len()
+	c := &ColumnVector{Kind: ast.BOOLEAN, Bools: make([]bool, n), Nulls: make([]bool, n)}
+	for i := 0; i < n; i++ {
+		if !l.Nulls[i] && l.Bools[i] == (op == ast.OR) {
+			c.Bools[i] = l.Bools[i]
+		} else if l.Nulls[i] || r.Nulls[i] {
+			c.Nulls[i] = true
+		} else {
+			c.Bools[i] = r.Bools[i]
+		}
+	}
+	return c, nil
+}
+
+func arithVector(op ast.Token, l, r *ColumnVector) (*ColumnVector, error) {
+	n := l.Len()
+	if l.Kind == ast.BIGINT && r.Kind == ast.BIGINT {
+		c := &ColumnVector{Kind: ast.BIGINT, Ints: make([]int64, n), Nulls: make([]bool, n)}
+		for i := 0; i < n; i++ {
+			if l.Nulls[i] || r.Nulls[i] {
+				c.Nulls[i] = true
+				continue
+			}
+			a, b := l.Ints[i], r.Ints[i]
+			switch op {
+			case ast.ADD:
+				c.Ints[i] = a + b
+			case ast.SUB:
+				c.Ints[i] = a - b
+			case ast.MUL:
+				c.Ints[i] = a * b
+			case ast.DIV, ast.MOD:
+				if b == 0 {
+					return nil, fmt.Errorf("divided by zero")
+				}
+				if op == ast.DIV {
+					c.Ints[i] = a / b
+				} else {
+					c.Ints[i] = a % b
+				}
+			}
+		}
+		return c, nil
+	}
+	lf, rf := floatValues(l), floatValues(r)
+	if lf == nil || rf == nil {
+		return nil, fmt.Errorf("invalid operation %s %s %s", l.Kind, op, r.Kind)
+	}
+	c := &ColumnVector{Kind: ast.FLOAT, Floats: make([]float64, n), Nulls: make([]bool, n)}
+	for i := 0; i < n; i++ {
+		if l.Nulls[i] || r.Nulls[i] {
+			c.Nulls[i] = true
+			continue
+		}
+		a, b := lf[i], rf[i]
+		switch op {
+		case ast.ADD:
+			c.Floats[i] = a + b
+		case ast.SUB:
+			c.Floats[i] = a - b
+		case ast.MUL:
+			c.Floats[i] = a * b
+		case ast.DIV, ast.MOD:
+			if b == 0 {
+				return nil, fmt.Errorf("divided by zero")
+			}
+			if op == ast.DIV {
+				c.Floats[i] = a / b
+			} else {
+				c.Floats[i] = math.Mod(a, b)
+			}
+		}
+	}
+	return c, nil
+}
+
+// floatValues returns the numeric values as floats or nil if the column is not numeric
+func floatValues(c *ColumnVector) []float64 {
+	switch c.Kind {
+	case ast.FLOAT:
+		return c.Floats
+	case ast.BIGINT:
+		r := make([]float64, len(c.Ints))
+		for i, v := range c.Ints {
+			r[i] = float64(v)
+		}
+		return r
+	default:
+		return nil
+	}
+}
+
+func compareVector(op ast.Token, l, r *ColumnVector) (*ColumnVector, error) {
+	n := l.Len()
+	c := &ColumnVector{Kind: ast.BOOLEAN, Bools: make([]bool, n), Nulls: make([]bool, n)}
+	// cmp returns -1, 0 or 1 for non-null values
+	var cmp func(i int) int
+	switch {
+	case l.Kind == ast.BIGINT && r.Kind == ast.BIGINT:
+		cmp = func(i int) int {
+			switch a, b := l.Ints[i], r.Ints[i]; {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			default:
+				return 0
+			}
+		}
+	case isNumericKind(l.Kind) && isNumericKind(r.Kind):
+		lf, rf := floatValues(l), floatValues(r)
+		cmp = func(i int) int {
+			switch a, b := lf[i], rf[i]; {
+			case a < b:
+				return -1
+			case a > b:
+				return 1
+			default:
+				return 0
+			}
+		}
+	case l.Kind == ast.STRINGS && r.Kind == ast.STRINGS:
+		cmp = func(i int) int {
+			return strings.Compare(l.Strs[i], r.Strs[i])
+		}
+	case l.Kind == ast.BOOLEAN && r.Kind == ast.BOOLEAN && (op == ast.EQ || op == ast.NEQ):
+		cmp = func(i int) int {
+			if l.Bools[i] == r.Bools[i] {
+				return 0
+			}
+			return 1
+		}
+	default:
+		return nil, fmt.Errorf("invalid operation %s %s %s", l.Kind, op, r.Kind)
+	}
+	for i := 0; i < n; i++ {
+		if l.Nulls[i] || r.Nulls[i] {
+			bothNull := l.Nulls[i] && r.Nulls[i]
+			switch op {
+			case ast.EQ, ast.LTE, ast.GTE:
+				c.Bools[i] = bothNull
+			case ast.NEQ:
+				c.Bools[i] = !bothNull
+			}
+			continue
+		}
+		v := cmp(i)
+		switch op {
+		case ast.EQ:
+			c.Bools[i] = v == 0
+		case ast.NEQ:
+			c.Bools[i] = v != 0
+		case ast.LT:
+			c.Bools[i] = v < 0
+		case ast.LTE:
+			c.Bools[i] = v <= 0
+		case ast.GT:
+			c.Bools[i] = v > 0
+		case ast.GTE:
+			c.Bools[i] = v >= 0
+		}
+	}
+	return c, nil
+}
+
+// Filter evaluates the condition over the batch and selects the rows whose result is true
+func (b *ColumnBatch) Filter(condition ast.Expr) error {
+	r, err := EvalVector(condition, b)
+	if err != nil {
+		return err
+	}
+	if r.Kind != ast.BOOLEAN {
+		return fmt.Errorf("invalid condition that returns non-bool value of type %s", r.Kind)
+	}
+	var sel []int
+	if b.Sel == nil {
+		sel = make([]int, 0, b.Len())
+		for i := 0; i < b.Len(); i++ {
+			if !r.Nulls[i] && r.Bools[i] {
+				sel = append(sel, i)
+			}
+		}
+	} else {
+		sel = make([]int, 0, len(b.Sel))
+		for _, i := range b.Sel {
+			if !r.Nulls[i] && r.Bools[i] {
+				sel = append(sel, i)
+			}
+		}
+	}
+	b.Sel = sel
+	return nil
+}
+
+// Project evaluates the arithmetic expression over the batch and sets the result into the cached field of each
+// selected row, so that the expression is not evaluated again for the row
+func (b *ColumnBatch) Project(expr *ast.BinaryExpr) error {
+	r, err := EvalVector(expr, b)
+	if err != nil {
+		return err
+	}
+	if b.Sel == nil {
+		for i, t := range b.Tuples {
+			t.Set(expr.CachedField, r.Value(i))
+		}
+	} else {
+		for _, i := range b.Sel {
+			b.Tuples[i].Set(expr.CachedField, r.Value(i))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package xsql
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/lf-edge/ekuiper/pkg/ast"
+)
+
+func TestVectorType(t *testing.T) {
+	types := map[string]ast.DataType{
+		"a": ast.BIGINT,
+		"b": ast.FLOAT,
+		"c": ast.STRINGS,
+		"d": ast.BOOLEAN,
+		"e": ast.DATETIME,
+	}
+	tests := []struct {
+		cond string
+		t    ast.DataType
+		ok   bool
+	}{
+		{cond: "a * 2 + 1", t: ast.BIGINT, ok: true},
+		{cond: "a / b", t: ast.FLOAT, ok: true},
+		{cond: "(a + b) > 3 AND c = \"x\" OR d", t: ast.BOOLEAN, ok: true},
+		{cond: "c < \"b\"", t: ast.BOOLEAN, ok: true},
+		{cond: "d > true"},
+		{cond: "c + 1"},
+		{cond: "e > 1"},
+		{cond: "f > 1"},
+		{cond: "abs(a) > 1"},
+		{cond: "a IN (1, 2)"},
+	}
+	for i, tt := range tests {
+		stmt, err := NewParser(strings.NewReader("SELECT * FROM src WHERE " + tt.cond)).Parse()
+		if err != nil {
+			t.Errorf("%d. parse error: %v", i, err)
+			continue
+		}
+		r, ok := VectorType(stmt.Condition, types)
+		if ok != tt.ok || (ok && r != tt.t) {
+			t.Errorf("%d. %s: expect %s %v but got %s %v", i, tt.cond, tt.t, tt.ok, r, ok)
+		}
+	}
+}
+
+// TestEvalVector checks the vectorized evaluation gets the same result as the row based evaluation
+func TestEvalVector(t *testing.T) {
+	types := map[string]ast.DataType{
+		"a": ast.BIGINT,
+		"b": ast.FLOAT,
+		"c": ast.STRINGS,
+		"d": ast.BOOLEAN,
+	}
+	messages := []Message{
+		{"a": int64(1), "b": 1.5, "c": "x", "d": true},
+		{"a": int64(4), "b": 0.5, "c": "y", "d": false},
+		{"a": int64(-3), "c": "x"},
+		{"b": 2.0, "d": true},
+		{"a": int64(7), "b": -1.0, "c": "z", "d": false},
+	}
+	conds := []string{
+		"a * 2 + 1",
+		"a / 2 - b",
+		"a % 3",
+		"a > b",
+		"a = 4 OR b <= 0.5",
+		"c = \"x\" AND a > 0",
+		"c != \"x\" OR d",
+		"d AND a < 5",
+		"d = false",
+		"(a + 1) * b >= 2",
+		"c > \"x\"",
+		"a = b",
+	}
+	b := NewColumnBatch(types, len(messages))
+	for _, m := range messages {
+		if err := b.AddTuple(&Tuple{Emitter: "src", Message: m}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, cond := range conds {
+		stmt, err := NewParser(strings.NewReader("SELECT * FROM src WHERE " + cond)).Parse()
+		if err != nil {
+			t.Errorf("%d. parse error: %v", i, err)
+			continue
+		}
+		if _, ok := VectorType(stmt.Condition, types); !ok {
+			t.Errorf("%d. %s cannot be vectorized", i, cond)
+			continue
+		}
+		r, err := EvalVector(stmt.Condition, b)
+		if err != nil {
+			t.Errorf("%d. %s eval error: %v", i, cond, err)
+			continue
+		}
+		for j, tuple := range b.Tuples {
+			ve := &ValuerEval{Valuer: MultiValuer(tuple)}
+			exp := ve.Eval(stmt.Condition)
+			if !reflect.DeepEqual(exp, r.Value(j)) {
+				t.Errorf("%d. %s row %d: expect %v but got %v", i, cond, j, exp, r.Value(j))
+			}
+		}
+	}
+}
+
+func TestColumnBatchFilter(t *testing.T) {
+	types := map[string]ast.DataType{"a": ast.BIGINT}
+	b := NewColumnBatch(types, 4)
+	for _, a := range []interface{}{int64(1), nil, int64(5), int64(3)} {
+		if err := b.AddTuple(&Tuple{Emitter: "src", Message: Message{"a": a}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.AddTuple(&Tuple{Emitter: "src", Message: Message{"a": "bad"}}); err == nil {
+		t.Error("expect error for invalid column value")
+	}
+	if b.Len() != 4 || b.Columns["a"].Len() != 4 {
+		t.Errorf("the batch should keep 4 rows after the invalid row but got %d", b.Len())
+	}
+	cond := &ast.BinaryExpr{OP: ast.GT, LHS: &ast.FieldRef{Name: "a"}, RHS: &ast.IntegerLiteral{Val: 2}}
+	if err := b.Filter(cond); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]int{2, 3}, b.Sel) {
+		t.Errorf("expect selection [2 3] but got %v", b.Sel)
+	}
+	cond = &ast.BinaryExpr{OP: ast.LT, LHS: &ast.FieldRef{Name: "a"}, RHS: &ast.IntegerLiteral{Val: 4}}
+	if err := b.Filter(cond); err != nil {
+		t.Fatal(err)
+	}
+	selected := b.Selected()
+	if len(selected) != 1 || selected[0].Message["a"] != int64(3) {
+		t.Errorf("expect the row with a=3 selected but got %v", selected)
+	}
+	div := &ast.BinaryExpr{OP: ast.DIV, LHS: &ast.FieldRef{Name: "a"}, RHS: &ast.IntegerLiteral{Val: 0}}
+	if _, err := EvalVector(div, b); err == nil || err.Error() != "divided by zero" {
+		t.Errorf("expect divided by zero error but got %v", err)
+	}
+}
+
+func TestColumnBatchProject(t *testing.T) {
+	types := map[string]ast.DataType{"a": ast.BIGINT, "b": ast.FLOAT}
+	b := NewColumnBatch(types, 3)
+	for _, m := range []Message{{"a": int64(1), "b": 0.5}, {"a": int64(2)}, {"a": int64(3), "b": 1.5}} {
+		if err := b.AddTuple(&Tuple{Emitter: "src", Message: m}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.Sel = []int{1, 2}
+	expr := &ast.BinaryExpr{OP: ast.ADD, LHS: &ast.FieldRef{Name: "a"}, RHS: &ast.FieldRef{Name: "b"}, CachedField: "$$vec_0", Cached: true}
+	if err := b.Project(expr); err != nil {
+		t.Fatal(err)
+	}
+	// only the selected rows are projected and the cached value is returned by the row evaluation
+	if _, ok := b.Tuples[0].Value("$$vec_0", ""); ok {
+		t.Error("the unselected row should not be projected")
+	}
+	for i, exp := range []interface{}{nil, 4.5} {
+		tuple := b.Tuples[i+1]
+		if v, ok := tuple.Value("$$vec_0", ""); !ok || !reflect.DeepEqual(exp, v) {
+			t.Errorf("row %d: expect %v but got %v", i+1, exp, v)
+		}
+		tuple.Message["a"] = int64(100)
+		ve := &ValuerEval{Valuer: MultiValuer(tuple)}
+		if v := ve.Eval(expr); !reflect.DeepEqual(exp, v) {
+			t.Errorf("row %d: expect cached %v but got %v", i+1, exp, v)
+		}
+	}
+	// the row without the cached field evaluates the expression
+	ve := &ValuerEval{Valuer: MultiValuer(b.Tuples[0])}
+	if v := ve.Eval(expr); !reflect.DeepEqual(1.5, v) {
+		t.Errorf("expect evaluated 1.5 but got %v", v)
+	}
+	div := &ast.BinaryExpr{OP: ast.DIV, LHS: &ast.FieldRef{Name: "a"}, RHS: &ast.IntegerLiteral{Val: 0}, CachedField: "$$vec_1", Cached: true}
+	if err := b.Project(div); err == nil {
+		t.Error("expect divided by zero error")
+	}
+}
@@ -134,18 +134,47 @@ type Rewindable interface {
 	Rewind(offset interface{}) error
 }
 
+// Committable is an optional interface for the Rewindable sources to release the data which are already covered by
+// a completed checkpoint. Commit is called with the offset saved by the checkpoint, so it is only called when qos is
+// enabled. It must not block.
+type Committable interface {
+	Commit(offset interface{}) error
+}
+
+// AckableTuple is an optional interface for the tuples of the Rewindable sources which emit concurrently or ahead of
+// the processing. Ack is called once the source node has processed the tuple, so that the offset got by GetOffset
+// afterwards only covers the processed tuples instead of the tuples still in the buffer. It must not block.
+type AckableTuple interface {
+	SourceTuple
+	Ack()
+}
+
 type RuleOption struct {
-	IsEventTime        bool             `json:"isEventTime" yaml:"isEventTime"`
-	LateTol            int64            `json:"lateTolerance" yaml:"lateTolerance"`
-	Concurrency        int              `json:"concurrency" yaml:"concurrency"`
-	BufferLength       int              `json:"bufferLength" yaml:"bufferLength"`
-	SendMetaToSink     bool             `json:"sendMetaToSink" yaml:"sendMetaToSink"`
-	SendError          bool             `json:"sendError" yaml:"sendError"`
-	Qos                Qos              `json:"qos" yaml:"qos"`
-	CheckpointInterval int              `json:"checkpointInterval" yaml:"checkpointInterval"`
-	Restart            *RestartStrategy `json:"restartStrategy" yaml:"restartStrategy"`
-	Cron               string           `json:"cron" yaml:"cron"`
-	Duration           string           `json:"duration" yaml:"duration"`
+	IsEventTime            bool              `json:"isEventTime" yaml:"isEventTime"`
+	LateTol                int64             `json:"lateTolerance" yaml:"lateTolerance"`
+	Concurrency            int               `json:"concurrency" yaml:"concurrency"`
+	BufferLength           int               `json:"bufferLength" yaml:"bufferLength"`
+	SendMetaToSink         bool              `json:"sendMetaToSink" yaml:"sendMetaToSink"`
+	SendError              bool              `json:"sendError" yaml:"sendError"`
+	Qos                    Qos               `json:"qos" yaml:"qos"`
+	CheckpointInterval     int               `json:"checkpointInterval" yaml:"checkpointInterval"`
+	Restart                *RestartStrategy  `json:"restartStrategy" yaml:"restartStrategy"`
+	Cron                   string            `json:"cron" yaml:"cron"`
+	Duration               string            `json:"duration" yaml:"duration"`
+	ColumnarBatchSize      int               `json:"columnarBatchSize" yaml:"columnarBatchSize"`
+	ColumnarLinger         int               `json:"columnarLinger" yaml:"columnarLinger"`
+	DeadLetter             *DeadLetterOption `json:"deadLetter" yaml:"deadLetter"`
+	DedupMaxKeys           int               `json:"dedupMaxKeys" yaml:"dedupMaxKeys"`
+	DedupFalsePositiveRate float64           `json:"dedupFalsePositiveRate" yaml:"dedupFalsePositiveRate"`
+	IncrementalAggregate   bool              `json:"incrementalAggregate" yaml:"incrementalAggregate"`
+}
+
+// DeadLetterOption defines where to send the events which fail to decode or evaluate
+type DeadLetterOption struct {
+	// Action is a single sink action such as {"memory": {"topic": "dlq"}}
+	Action map[string]interface{} `json:"action" yaml:"action"`
+	// RateLimit is the max dead letters per second. 0 means no limit
+	RateLimit int `json:"rateLimit" yaml:"rateLimit"`
 }
 
 type RestartStrategy struct {
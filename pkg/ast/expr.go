@@ -66,6 +66,28 @@ type IntegerLiteral struct {
 	Val int
 }
 
+// IntervalLiteral is a duration such as INTERVAL 5s. Unit is one of the time literal tokens.
+type IntervalLiteral struct {
+	Val  int
+	Unit Token
+}
+
+// ToMilli returns the duration in milliseconds
+func (il *IntervalLiteral) ToMilli() int64 {
+	switch il.Unit {
+	case DD:
+		return int64(il.Val) * 24 * 3600 * 1000
+	case HH:
+		return int64(il.Val) * 3600 * 1000
+	case MI:
+		return int64(il.Val) * 60 * 1000
+	case SS:
+		return int64(il.Val) * 1000
+	default:
+		return int64(il.Val)
+	}
+}
+
 type StringLiteral struct {
 	Val string
 }
@@ -108,6 +130,10 @@ func (il *IntegerLiteral) expr()    {}
 func (il *IntegerLiteral) literal() {}
 func (il *IntegerLiteral) node()    {}
 
+func (il *IntervalLiteral) expr()    {}
+func (il *IntervalLiteral) literal() {}
+func (il *IntervalLiteral) node()    {}
+
 func (nl *NumberLiteral) expr()    {}
 func (nl *NumberLiteral) literal() {}
 func (nl *NumberLiteral) node()    {}
@@ -138,6 +164,8 @@ type Call struct {
 	Cached      bool
 	Partition   *PartitionExpr
 	WhenExpr    Expr
+	// This is used for window functions to order the rows inside each partition.
+	SortFields SortFields
 }
 
 func (c *Call) expr()    {}
@@ -155,6 +183,10 @@ type BinaryExpr struct {
 	OP  Token
 	LHS Expr
 	RHS Expr
+	// This is used for the arithmetic expressions calculated in columnar batches before the window.
+	// If the row has the cachedField, just return it. Otherwise, the expression is evaluated as usual.
+	CachedField string
+	Cached      bool
 }
 
 func (be *BinaryExpr) expr() {}
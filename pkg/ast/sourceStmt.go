@@ -1,4 +1,4 @@
-// Copyright 2021-2022 EMQ Technologies Co., Ltd.
+// Copyright 2021-2023 EMQ Technologies Co., Ltd.
 //
 // Licensed under the Apache License, Version 2.0 (the "License");
 // you may not use this file except in compliance with the License.
@@ -34,6 +34,21 @@ const (
 	StreamKindScan   = "scan"
 )
 
+// Policies for the fields not defined in the stream schema
+const (
+	UnknownFieldsKeep  = "keep"
+	UnknownFieldsDrop  = "drop"
+	UnknownFieldsError = "error"
+)
+
+// Policies for the field values which cannot be validated against the stream schema
+const (
+	TypeMismatchCoerce     = "coerce"
+	TypeMismatchNull       = "null"
+	TypeMismatchError      = "error"
+	TypeMismatchDeadLetter = "deadletter"
+)
+
 type StreamType int
 
 type StreamStmt struct {
@@ -235,6 +250,9 @@ type Options struct {
 	KIND string `json:"kind,omitempty"`
 	// for delimited format only
 	DELIMITER string `json:"delimiter,omitempty"`
+	// for strict validation only, how to handle the fields out of the schema and the mismatched values
+	UNKNOWN_FIELDS string `json:"unknownFields,omitempty"`
+	TYPE_MISMATCH  string `json:"typeMismatch,omitempty"`
 }
 
 func (o Options) node() {}
@@ -267,6 +285,26 @@ func (ess *ExplainStreamStatement) GetName() string { return ess.Name }
 
 func (dss *DropStreamStatement) GetName() string { return dss.Name }
 
+type AlterAction int
+
+const (
+	AlterAddColumn AlterAction = iota
+	AlterDropColumn
+	AlterModifyColumn
+)
+
+// AlterStreamStatement changes one column of the stream schema. Field is the new column definition
+// for ADD and MODIFY, only its name is set for DROP.
+type AlterStreamStatement struct {
+	Name   string
+	Action AlterAction
+	Field  StreamField
+
+	Statement
+}
+
+func (ass *AlterStreamStatement) GetName() string { return ass.Name }
+
 type ShowTablesStatement struct {
 	Statement
 }
@@ -27,6 +27,11 @@ type SelectStatement struct {
 	Dimensions Dimensions
 	Having     Expr
 	SortFields SortFields
+	Dedup      *Dedup
+	Pattern    *Pattern
+	Sample     *Sample
+	Throttle   *Throttle
+	Debounce   *Debounce
 
 	Statement
 }
@@ -194,6 +199,73 @@ type SortFields []SortField
 
 func (d SortFields) node() {}
 
+// Dedup is the DEDUP BY clause such as DEDUP BY msgId WITHIN 10 MINUTES
+type Dedup struct {
+	Keys   []Expr
+	Within *IntervalLiteral
+}
+
+func (d *Dedup) node() {}
+
+// Pattern is the PATTERN clause to detect a sequence of rows such as
+// PATTERN (A B+ C) WITHIN 30 SECONDS PARTITION BY deviceId DEFINE A AS temperature > 80, C AS pressure < 2
+type Pattern struct {
+	Elements  []PatternElement
+	Within    *IntervalLiteral
+	Partition []Expr
+	Defines   []PatternDefine
+}
+
+func (p *Pattern) node() {}
+
+const (
+	ThrottleFirst = "first"
+	ThrottleLast  = "last"
+	ThrottleDrop  = "drop"
+)
+
+// Throttle is the THROTTLE clause to limit the rows of each key such as THROTTLE 10 PER 1 SECOND BY deviceId POLICY LAST
+type Throttle struct {
+	Limit    int
+	Interval *IntervalLiteral
+	Keys     []Expr
+	// Policy is one of first, last and drop
+	Policy string
+}
+
+func (t *Throttle) node() {}
+
+// Sample is the SAMPLE clause to keep every Nth row of each key such as SAMPLE EVERY 10 BY deviceId,
+// or a random percentage of the rows such as SAMPLE 5 PERCENT
+type Sample struct {
+	Every   int
+	Percent float64
+	Keys    []Expr
+}
+
+func (s *Sample) node() {}
+
+// Debounce is the DEBOUNCE clause to emit the latest row of each key after a quiet period such as DEBOUNCE 500 MS BY deviceId
+type Debounce struct {
+	Quiet *IntervalLiteral
+	Keys  []Expr
+}
+
+func (d *Debounce) node() {}
+
+// PatternElement is a pattern variable with its quantifier. Max is -1 if unbounded.
+type PatternElement struct {
+	Name string
+	Min  int
+	Max  int
+}
+
+// PatternDefine is the condition of a pattern variable. The variables without a define match any row.
+type PatternDefine struct {
+	Name      string
+	Condition Expr
+}
+
 const (
 	RowkindInsert = "insert"
 	RowkindUpdate = "update"
@@ -204,6 +204,10 @@ const (
 	SELECT_LIT = "SELECT"
 	CREATE     = "CREATE"
 	DROP       = "DROP"
+	ALTER      = "ALTER"
+	ADD_LIT    = "ADD"
+	MODIFY     = "MODIFY"
+	COLUMN     = "COLUMN"
 	EXPLAIN    = "EXPLAIN"
 	DESCRIBE   = "DESCRIBE"
 	SHOW       = "SHOW"
@@ -226,6 +230,8 @@ const (
 	SCHEMAID          = "SCHEMAID"
 	KIND              = "KIND"
 	DELIMITER         = "DELIMITER"
+	UNKNOWN_FIELDS    = "UNKNOWN_FIELDS"
+	TYPE_MISMATCH     = "TYPE_MISMATCH"
 
 	XBIGINT   = "BIGINT"
 	XFLOAT    = "FLOAT"
@@ -251,6 +257,8 @@ var StreamTokens = map[string]struct{}{
 	SCHEMAID:          {},
 	KIND:              {},
 	DELIMITER:         {},
+	UNKNOWN_FIELDS:    {},
+	TYPE_MISMATCH:     {},
 }
 
 var StreamDataTypes = map[string]DataType{
@@ -40,6 +40,11 @@ func Walk(v Visitor, node Node) {
 		Walk(v, n.Dimensions)
 		Walk(v, n.Having)
 		Walk(v, n.SortFields)
+		Walk(v, n.Dedup)
+		Walk(v, n.Pattern)
+		Walk(v, n.Sample)
+		Walk(v, n.Throttle)
+		Walk(v, n.Debounce)
 
 	case Fields:
 		for _, f := range n {
@@ -83,6 +88,34 @@ func Walk(v Visitor, node Node) {
 			Walk(v, sf.FieldExpr)
 		}
 
+	case *Dedup:
+		for _, k := range n.Keys {
+			Walk(v, k)
+		}
+
+	case *Sample:
+		for _, k := range n.Keys {
+			Walk(v, k)
+		}
+
+	case *Throttle:
+		for _, k := range n.Keys {
+			Walk(v, k)
+		}
+
+	case *Debounce:
+		for _, k := range n.Keys {
+			Walk(v, k)
+		}
+
+	case *Pattern:
+		for _, k := range n.Partition {
+			Walk(v, k)
+		}
+		for _, d := range n.Defines {
+			Walk(v, d.Condition)
+		}
+
 	// case *SortField:
 
 	case *BinaryExpr:
@@ -104,6 +137,10 @@ func Walk(v Visitor, node Node) {
 			Walk(v, n.WhenExpr)
 		}
 
+		if n.SortFields != nil {
+			Walk(v, n.SortFields)
+		}
+
 	case *ParenExpr:
 		Walk(v, n.Expr)
 
@@ -0,0 +1,248 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package harness plays the eKuiper side of the portable plugin protocol so that
+// plugins written by any SDK can be tested without a running eKuiper.
+//
+// The protocol runs over nanomsg ipc sockets:
+//   - control: eKuiper listens (rep) on ipc:///tmp/plugin_<name>.ipc. The plugin dials (req) and sends
+//     "handshake", then each command is delivered as the reply and acknowledged with "ok" or an error.
+//   - source: eKuiper listens (pull) on ipc:///tmp/<ruleId>_<opId>_<instanceId>.ipc and the plugin pushes
+//     json encoded tuples like {"message":{},"meta":{}}.
+//   - sink: the plugin listens (pull) on the same address pattern and eKuiper pushes the json encoded data.
+//   - function: eKuiper listens (rep) on ipc:///tmp/func_<symbolName>.ipc. The plugin dials (req) and
+//     sends "handshake", then receives FuncData requests and answers with FuncReply.
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"go.nanomsg.org/mangos/v3"
+	"go.nanomsg.org/mangos/v3/protocol/rep"
+	// introduce ipc
+	_ "go.nanomsg.org/mangos/v3/transport/ipc"
+
+	"github.com/lf-edge/ekuiper/sdk/go/runtime"
+)
+
+// Options of the harness. The zero value is usable.
+type Options struct {
+	// InitTimeout is the time to wait for the plugin handshake. Default to 5 seconds
+	InitTimeout time.Duration
+	// SendTimeout is passed to the plugin as the PortableConfig. Default to 1000 milliseconds
+	SendTimeout int64
+	// Env is appended to the environment of the plugin process
+	Env []string
+	// Dir is the working directory of the plugin process
+	Dir string
+}
+
+// Harness controls one plugin process
+type Harness struct {
+	sync.Mutex
+	name string
+	cmd  *exec.Cmd
+	ctrl mangos.Socket
+	done chan struct{}
+	err  error
+}
+
+// Start runs the plugin process by the command and waits for the control channel handshake.
+// The command can be any executable, e.g. ["./mirror"] for go plugins or ["python3", "main.py"]
+// for python plugins. The plugin config json is appended as the last argument like eKuiper does.
+func Start(name string, command []string, opts *Options) (_ *Harness, e error) {
+	if len(command) == 0 {
+		return nil, fmt.Errorf("command is required to start plugin %s", name)
+	}
+	if opts == nil {
+		opts = &Options{}
+	}
+	if opts.InitTimeout <= 0 {
+		opts.InitTimeout = 5 * time.Second
+	}
+	if opts.SendTimeout <= 0 {
+		opts.SendTimeout = 1000
+	}
+	ctrl, err := rep.NewSocket()
+	if err != nil {
+		return nil, fmt.Errorf("can't get new rep socket: %s", err)
+	}
+	defer func() {
+		if e != nil {
+			_ = ctrl.Close()
+		}
+	}()
+	if err = listen(ctrl, fmt.Sprintf("ipc:///tmp/plugin_%s.ipc", name)); err != nil {
+		return nil, err
+	}
+
+	jsonArg, err := json.Marshal(&runtime.PortableConfig{SendTimeout: opts.SendTimeout})
+	if err != nil {
+		return nil, err
+	}
+	args := append(append([]string{}, command[1:]...), string(jsonArg))
+	cmd := exec.Command(command[0], args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Dir = opts.Dir
+	cmd.Env = append(os.Environ(), opts.Env...)
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("fail to start plugin %s: %v", name, err)
+	}
+	h := &Harness{
+		name: name,
+		cmd:  cmd,
+		ctrl: ctrl,
+		done: make(chan struct{}),
+	}
+	go func() {
+		err := cmd.Wait()
+		h.Lock()
+		h.err = fmt.Errorf("plugin %s exited: %v", name, err)
+		h.Unlock()
+		close(h.done)
+	}()
+	defer func() {
+		if e != nil {
+			_ = h.kill()
+		}
+	}()
+	if _, err = recvWithin(ctrl, opts.InitTimeout, h.done); err != nil {
+		return nil, fmt.Errorf("plugin %s handshake error: %v", name, h.wrap(err))
+	}
+	return h, nil
+}
+
+// Err returns the exit error if the plugin process has exited
+func (h *Harness) Err() error {
+	h.Lock()
+	defer h.Unlock()
+	return h.err
+}
+
+// Done is closed when the plugin process exits
+func (h *Harness) Done() <-chan struct{} {
+	return h.done
+}
+
+// SendCmd sends a start or stop command of the symbol to the plugin and waits for the acknowledgement
+func (h *Harness) SendCmd(cmd string, ctrl *runtime.Control) error {
+	h.Lock()
+	defer h.Unlock()
+	arg, err := json.Marshal(ctrl)
+	if err != nil {
+		return err
+	}
+	c, err := json.Marshal(&runtime.Command{Cmd: cmd, Arg: string(arg)})
+	if err != nil {
+		return err
+	}
+	if err = h.ctrl.Send(c); err != nil {
+		return fmt.Errorf("can't send command %s: %v", cmd, err)
+	}
+	reply, err := recvWithin(h.ctrl, 5*time.Second, h.done)
+	if err != nil {
+		return fmt.Errorf("can't receive reply of command %s: %v", cmd, err)
+	}
+	if string(reply) != runtime.REPLY_OK {
+		return fmt.Errorf("command %s error: %s", cmd, string(reply))
+	}
+	return nil
+}
+
+// Close stops the plugin process and releases the control channel
+func (h *Harness) Close() error {
+	err := h.kill()
+	_ = h.ctrl.Close()
+	return err
+}
+
+func (h *Harness) kill() error {
+	select {
+	case <-h.done:
+		return nil
+	default:
+	}
+	if err := h.cmd.Process.Kill(); err != nil {
+		return err
+	}
+	<-h.done
+	return nil
+}
+
+// wrap reports the process exit error first because it is usually the root cause
+func (h *Harness) wrap(err error) error {
+	if pe := h.Err(); pe != nil {
+		return pe
+	}
+	return err
+}
+
+func symbolUrl(meta *runtime.Meta) string {
+	return fmt.Sprintf("ipc:///tmp/%s_%s_%d.ipc", meta.RuleId, meta.OpId, meta.InstanceId)
+}
+
+// recvWithin receives a message until timeout or the plugin exits
+func recvWithin(sock mangos.Socket, timeout time.Duration, done <-chan struct{}) ([]byte, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return nil, mangos.ErrRecvTimeout
+		}
+		if wait > 100*time.Millisecond {
+			wait = 100 * time.Millisecond
+		}
+		if err := sock.SetOption(mangos.OptionRecvDeadline, wait); err != nil {
+			return nil, err
+		}
+		msg, err := sock.Recv()
+		switch err {
+		case nil:
+			return msg, nil
+		case mangos.ErrRecvTimeout:
+			select {
+			case <-done:
+				return nil, fmt.Errorf("plugin exited")
+			default:
+			}
+		default:
+			return nil, err
+		}
+	}
+}
+
+func listen(sock mangos.Socket, url string) error {
+	var err error
+	for i := 0; i < 300; i++ {
+		if err = sock.Listen(url); err == nil {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("can't listen on %s: %v", url, err)
+}
+
+func dial(sock mangos.Socket, url string) error {
+	return sock.DialOptions(url, map[string]interface{}{
+		mangos.OptionDialAsynch:       false,
+		mangos.OptionMaxReconnectTime: 5 * time.Second,
+		mangos.OptionReconnectTime:    100 * time.Millisecond,
+	})
+}
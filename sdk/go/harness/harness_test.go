@@ -0,0 +1,221 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lf-edge/ekuiper/sdk/go/api"
+	"github.com/lf-edge/ekuiper/sdk/go/runtime"
+)
+
+const pluginEnv = "EKUIPER_HARNESS_TEST_PLUGIN"
+
+// TestMain runs the test binary itself as the plugin when started by the harness
+func TestMain(m *testing.M) {
+	if os.Getenv(pluginEnv) == "1" {
+		runtime.Start(os.Args, &runtime.PluginConfig{
+			Name: "harnesstest",
+			Sources: map[string]runtime.NewSourceFunc{
+				"counter": func() api.Source { return &counterSource{} },
+			},
+			Functions: map[string]runtime.NewFunctionFunc{
+				"echo": func() api.Function { return &echoFunc{} },
+			},
+			Sinks: map[string]runtime.NewSinkFunc{
+				"line": func() api.Sink { return &lineSink{} },
+			},
+		})
+		return
+	}
+	os.Exit(m.Run())
+}
+
+type counterSource struct {
+	prefix string
+}
+
+func (s *counterSource) Configure(datasource string, _ map[string]interface{}) error {
+	s.prefix = datasource
+	return nil
+}
+
+func (s *counterSource) Open(ctx api.StreamContext, consumer chan<- api.SourceTuple, _ chan<- error) {
+	for i := 0; ; i++ {
+		select {
+		case consumer <- api.NewDefaultSourceTuple(map[string]interface{}{"name": fmt.Sprintf("%s%d", s.prefix, i), "count": i}, nil):
+		case <-ctx.Done():
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (s *counterSource) Close(_ api.StreamContext) error {
+	return nil
+}
+
+type echoFunc struct{}
+
+func (f *echoFunc) Validate(args []interface{}) error {
+	if len(args) != 1 {
+		return fmt.Errorf("echo function only supports 1 parameter but got %d", len(args))
+	}
+	return nil
+}
+
+func (f *echoFunc) Exec(args []interface{}, _ api.FunctionContext) (interface{}, bool) {
+	return args[0], true
+}
+
+func (f *echoFunc) IsAggregate() bool {
+	return false
+}
+
+type lineSink struct {
+	path string
+}
+
+func (s *lineSink) Configure(props map[string]interface{}) error {
+	s.path, _ = props["path"].(string)
+	return nil
+}
+
+func (s *lineSink) Open(_ api.StreamContext) error {
+	return nil
+}
+
+func (s *lineSink) Collect(_ api.StreamContext, data interface{}) error {
+	if b, ok := data.([]byte); !ok || len(b) == 0 {
+		return nil
+	}
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s\n", data)
+	return err
+}
+
+func (s *lineSink) Close(_ api.StreamContext) error {
+	return nil
+}
+
+func startTestPlugin(t *testing.T) *Harness {
+	h, err := Start("harnesstest", []string{os.Args[0]}, &Options{Env: []string{pluginEnv + "=1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = h.Close()
+	})
+	return h
+}
+
+func TestSource(t *testing.T) {
+	h := startTestPlugin(t)
+	s, err := h.StartSource("counter", runtime.Meta{RuleId: "harnessRule", OpId: "op1"}, "c", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Expect(t, []map[string]interface{}{
+		{"name": "c0", "count": 0.0},
+		{"name": "c1", "count": 1.0},
+		{"name": "c2", "count": 2.0},
+	}, 5*time.Second)
+	if err := s.Stop(); err != nil {
+		t.Error(err)
+	}
+	// stop an unknown symbol
+	err = h.SendCmd(runtime.CMD_STOP, &runtime.Control{SymbolName: "counter", Meta: &runtime.Meta{RuleId: "none", OpId: "op1"}, PluginType: runtime.TYPE_SOURCE})
+	if err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expect symbol not found error but got %v", err)
+	}
+}
+
+func TestSink(t *testing.T) {
+	h := startTestPlugin(t)
+	path := filepath.Join(t.TempDir(), "out.txt")
+	s, err := h.StartSink("line", runtime.Meta{RuleId: "harnessRule", OpId: "op2"}, map[string]interface{}{"path": path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Send(map[string]interface{}{"a": 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SendRaw([]byte(`[{"a":2}]`)); err != nil {
+		t.Fatal(err)
+	}
+	exp := "{\"a\":1}\n[{\"a\":2}]\n"
+	var got string
+	for i := 0; i < 50; i++ {
+		b, _ := os.ReadFile(path)
+		got = string(b)
+		if got == exp {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if got != exp {
+		t.Errorf("sink result mismatch:\n  exp=%s\n  got=%s", exp, got)
+	}
+	if err := s.Stop(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFunction(t *testing.T) {
+	h := startTestPlugin(t)
+	f, err := h.StartFunction("echo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := f.Validate([]interface{}{"a"}); err != nil {
+		t.Error(err)
+	}
+	if err := f.Validate([]interface{}{"a", "b"}); err == nil {
+		t.Error("expect validate error but got nil")
+	}
+	agg, err := f.IsAggregate()
+	if err != nil || agg {
+		t.Errorf("expect not aggregate but got %v, %v", agg, err)
+	}
+	r, ok, err := f.Exec([]interface{}{map[string]interface{}{"a": "b"}})
+	if err != nil || !ok {
+		t.Fatalf("exec error %v %v", ok, err)
+	}
+	if !reflect.DeepEqual(map[string]interface{}{"a": "b"}, r) {
+		t.Errorf("exec result mismatch, got %v", r)
+	}
+}
+
+func TestStartError(t *testing.T) {
+	_, err := Start("harnesserr", []string{"/not/exist/plugin"}, nil)
+	if err == nil {
+		t.Error("expect start error but got nil")
+	}
+	_, err = Start("harnesserr", []string{"sh", "-c", "exit 1"}, &Options{InitTimeout: time.Second})
+	if err == nil || !strings.Contains(err.Error(), "handshake") {
+		t.Errorf("expect handshake error but got %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2023 EMQ Technologies Co., Ltd.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package harness
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.nanomsg.org/mangos/v3"
+	"go.nanomsg.org/mangos/v3/protocol/pull"
+	"go.nanomsg.org/mangos/v3/protocol/push"
+	"go.nanomsg.org/mangos/v3/protocol/rep"
+
+	"github.com/lf-edge/ekuiper/sdk/go/api"
+	"github.com/lf-edge/ekuiper/sdk/go/runtime"
+)
+
+// Source collects the output of a running source symbol
+type Source struct {
+	h    *Harness
+	ctrl *runtime.Control
+	sock mangos.Socket
+}
+
+// StartSource listens the source data channel and starts the source symbol with the data source and config
+func (h *Harness) StartSource(symbolName string, meta runtime.Meta, dataSource string, config map[string]interface{}) (_ *Source, e error) {
+	sock, err := pull.NewSocket()
+	if err != nil {
+		return nil, fmt.Errorf("can't get new pull socket: %s", err)
+	}
+	defer func() {
+		if e != nil {
+			_ = sock.Close()
+		}
+	}()
+	if err = listen(sock, symbolUrl(&meta)); err != nil {
+		return nil, err
+	}
+	c := &runtime.Control{
+		SymbolName: symbolName,
+		Meta:       &meta,
+		PluginType: runtime.TYPE_SOURCE,
+		DataSource: dataSource,
+		Config:     config,
+	}
+	if err = h.SendCmd(runtime.CMD_START, c); err != nil {
+		return nil, err
+	}
+	return &Source{h: h, ctrl: c, sock: sock}, nil
+}
+
+// Recv returns the next tuple sent by the source within the timeout
+func (s *Source) Recv(timeout time.Duration) (*api.DefaultSourceTuple, error) {
+	msg, err := recvWithin(s.sock, timeout, s.h.done)
+	if err != nil {
+		return nil, s.h.wrap(err)
+	}
+	result := &api.DefaultSourceTuple{}
+	if err = json.Unmarshal(msg, result); err != nil {
+		return nil, fmt.Errorf("invalid data format, cannot decode %s to json format with error %s", string(msg), err)
+	}
+	return result, nil
+}
+
+// Collect receives n tuples within the timeout
+func (s *Source) Collect(n int, timeout time.Duration) ([]*api.DefaultSourceTuple, error) {
+	deadline := time.Now().Add(timeout)
+	result := make([]*api.DefaultSourceTuple, 0, n)
+	for len(result) < n {
+		t, err := s.Recv(time.Until(deadline))
+		if err != nil {
+			return result, fmt.Errorf("received %d of %d tuples: %v", len(result), n, err)
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
+
+// Expect asserts the next messages of the source equal to exp. The values are compared after a json round trip,
+// so numbers in exp must be float64.
+func (s *Source) Expect(t *testing.T, exp []map[string]interface{}, timeout time.Duration) {
+	t.Helper()
+	tuples, err := s.Collect(len(exp), timeout)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	result := make([]map[string]interface{}, len(tuples))
+	for i, tuple := range tuples {
+		result[i] = tuple.Message()
+	}
+	if !reflect.DeepEqual(exp, result) {
+		t.Errorf("result mismatch:\n  exp=%v\n  got=%v\n\n", exp, result)
+	}
+}
+
+// Stop stops the source symbol and closes the data channel
+func (s *Source) Stop() error {
+	err := s.h.SendCmd(runtime.CMD_STOP, s.ctrl)
+	_ = s.sock.Close()
+	return err
+}
+
+// Sink feeds data into a running sink symbol
+type Sink struct {
+	h    *Harness
+	ctrl *runtime.Control
+	sock mangos.Socket
+}
+
+// StartSink starts the sink symbol with the config and then dials its data channel
+func (h *Harness) StartSink(symbolName string, meta runtime.Meta, config map[string]interface{}) (_ *Sink, e error) {
+	c := &runtime.Control{
+		SymbolName: symbolName,
+		Meta:       &meta,
+		PluginType: runtime.TYPE_SINK,
+		Config:     config,
+	}
+	if err := h.SendCmd(runtime.CMD_START, c); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if e != nil {
+			_ = h.SendCmd(runtime.CMD_STOP, c)
+		}
+	}()
+	sock, err := push.NewSocket()
+	if err != nil {
+		return nil, fmt.Errorf("can't get new push socket: %s", err)
+	}
+	if err = sock.SetOption(mangos.OptionSendDeadline, time.Second); err != nil {
+		_ = sock.Close()
+		return nil, err
+	}
+	if err = dial(sock, symbolUrl(&meta)); err != nil {
+		_ = sock.Close()
+		return nil, fmt.Errorf("can't dial on push socket: %s", err)
+	}
+	return &Sink{h: h, ctrl: c, sock: sock}, nil
+}
+
+// Send encodes the data in json like the default sink format and sends it to the sink.
+// The data is usually a map or a slice of maps.
+func (s *Sink) Send(data interface{}) error {
+	val, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return s.SendRaw(val)
+}
+
+// SendRaw sends the bytes to the sink as is
+func (s *Sink) SendRaw(val []byte) error {
+	if err := s.sock.Send(val); err != nil {
+		return s.h.wrap(err)
+	}
+	return nil
+}
+
+// Stop stops the sink symbol and closes the data channel
+func (s *Sink) Stop() error {
+	err := s.h.SendCmd(runtime.CMD_STOP, s.ctrl)
+	_ = s.sock.Close()
+	return err
+}
+
+// Function calls a function symbol. The function symbol is a singleton in the plugin and never stops.
+type Function struct {
+	h    *Harness
+	sock mangos.Socket
+	// Meta is sent as the function context in Exec
+	Meta runtime.FuncMeta
+}
+
+// StartFunction listens the function channel, starts the function symbol and waits for its handshake
+func (h *Harness) StartFunction(symbolName string) (_ *Function, e error) {
+	sock, err := rep.NewSocket()
+	if err != nil {
+		return nil, fmt.Errorf("can't get new rep socket: %s", err)
+	}
+	defer func() {
+		if e != nil {
+			_ = sock.Close()
+		}
+	}()
+	if err = sock.SetOption(mangos.OptionSendDeadline, time.Second); err != nil {
+		return nil, err
+	}
+	if err = listen(sock, fmt.Sprintf("ipc:///tmp/func_%s.ipc", symbolName)); err != nil {
+		return nil, err
+	}
+	c := &runtime.Control{
+		SymbolName: symbolName,
+		PluginType: runtime.TYPE_FUNC,
+	}
+	if err = h.SendCmd(runtime.CMD_START, c); err != nil {
+		return nil, err
+	}
+	if _, err = recvWithin(sock, 5*time.Second, h.done); err != nil {
+		return nil, fmt.Errorf("function %s handshake error: %v", symbolName, h.wrap(err))
+	}
+	return &Function{
+		h:    h,
+		sock: sock,
+		Meta: runtime.FuncMeta{
+			Meta: runtime.Meta{RuleId: "rule1", OpId: "op1"},
+		},
+	}, nil
+}
+
+// Validate returns nil if the plugin function accepts the args
+func (f *Function) Validate(args []interface{}) error {
+	fr, err := f.req("Validate", args)
+	if err != nil {
+		return err
+	}
+	if !fr.State {
+		return fmt.Errorf("validate return state is false, got %+v", fr)
+	}
+	return nil
+}
+
+// Exec runs the function with the args. Like a function in eKuiper, the bool result indicates success and the
+// result is the error message when it fails.
+func (f *Function) Exec(args []interface{}) (interface{}, bool, error) {
+	ctxRaw, err := json.Marshal(f.Meta)
+	if err != nil {
+		return nil, false, err
+	}
+	fr, err := f.req("Exec", append(append([]interface{}{}, args...), string(ctxRaw)))
+	if err != nil {
+		return nil, false, err
+	}
+	return fr.Result, fr.State, nil
+}
+
+// IsAggregate asks the plugin whether the function is an aggregate function
+func (f *Function) IsAggregate() (bool, error) {
+	fr, err := f.req("IsAggregate", nil)
+	if err != nil {
+		return false, err
+	}
+	r, ok := fr.Result.(bool)
+	if !fr.State || !ok {
+		return false, fmt.Errorf("invalid IsAggregate reply %+v", fr)
+	}
+	return r, nil
+}
+
+// Close closes the function channel
+func (f *Function) Close() error {
+	return f.sock.Close()
+}
+
+func (f *Function) req(name string, arg interface{}) (*runtime.FuncReply, error) {
+	data, err := json.Marshal(&runtime.FuncData{Func: name, Arg: arg})
+	if err != nil {
+		return nil, err
+	}
+	if err = f.sock.Send(data); err != nil {
+		return nil, fmt.Errorf("can't send request %s: %v", name, f.h.wrap(err))
+	}
+	res, err := recvWithin(f.sock, 5*time.Second, f.h.done)
+	if err != nil {
+		return nil, fmt.Errorf("can't receive reply of %s: %v", name, f.h.wrap(err))
+	}
+	fr := &runtime.FuncReply{}
+	if err = json.Unmarshal(res, fr); err != nil {
+		return nil, fmt.Errorf("invalid function reply %s: %v", string(res), err)
+	}
+	return fr, nil
+}